@@ -17,54 +17,81 @@ limitations under the License.
 package main
 
 import (
-	"context"
-	"log"
-	"net/http"
+	"errors"
 	"os"
-	"os/signal"
-	"syscall"
-	"time"
+	"strconv"
 
-	"github.com/sharedvolume/volume-syncer/internal/config"
-	"github.com/sharedvolume/volume-syncer/internal/server"
+	"github.com/sharedvolume/volume-syncer/internal/proxy"
+	"github.com/sharedvolume/volume-syncer/internal/sshutil"
+	"github.com/spf13/cobra"
 )
 
-func main() {
-	log.Printf("[MAIN] Starting Volume Syncer application")
-	log.Printf("[MAIN] Process ID: %d", os.Getpid())
+// Exit codes of the binary, which the pod failure policies of Kubernetes Jobs can tell apart
+const (
+	exitFailed    = 1 // the sync failed, or the server failed
+	exitUsage     = 2 // invalid flags, request or configuration, running again does not help
+	exitRetryable = 3 // the sync failed for a transient reason, running it again is likely to succeed
+)
 
-	// Load configuration
-	log.Printf("[MAIN] Loading configuration...")
-	cfg := config.Load()
-	log.Printf("[MAIN] Configuration loaded successfully")
+// exitError sets the exit code of the binary for the error
+type exitError struct {
+	code int
+	err  error
+}
 
-	// Create server
-	log.Printf("[MAIN] Creating server...")
-	srv := server.NewServer(cfg)
-	log.Printf("[MAIN] Server created successfully")
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
 
-	// Start server in a goroutine
-	log.Printf("[MAIN] Starting server...")
-	go func() {
-		if err := srv.Start(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("[MAIN] FATAL: Failed to start server: %v", err)
-		}
-	}()
+func (e *exitError) Unwrap() error {
+	return e.err
+}
 
-	// Wait for interrupt signal to gracefully shutdown
-	log.Printf("[MAIN] Server started successfully, waiting for shutdown signal...")
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+// exitCode returns the exit code of the binary for the error of the command
+func exitCode(err error) int {
+	var exitErr *exitError
+	if errors.As(err, &exitErr) {
+		return exitErr.code
+	}
+	return exitFailed
+}
 
-	log.Printf("[MAIN] Shutdown signal received, initiating graceful shutdown...")
-	// Give a timeout for graceful shutdown
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+func main() {
+	// The binary doubles as SSH_ASKPASS helper for password-based SSH syncs
+	if sshutil.RunAskPass() {
+		return
+	}
+	// ... and as ssh ProxyCommand for SSH connections through a proxy
+	if proxy.RunConnect() {
+		return
+	}
 
-	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatalf("[MAIN] FATAL: Server forced to shutdown: %v", err)
+	if err := newRootCommand().Execute(); err != nil {
+		os.Exit(exitCode(err))
 	}
+}
 
-	log.Printf("[MAIN] Server shutdown completed successfully")
+// newRootCommand creates the command line. Without a subcommand the binary runs the server, as
+// it did before the subcommands existed, or a single sync in one-shot mode.
+func newRootCommand() *cobra.Command {
+	var oneShot bool
+	root := &cobra.Command{
+		Use:          "volume-syncer",
+		Short:        "Synchronize data from SSH, Git, HTTP and S3 sources into volumes",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if enabled, _ := strconv.ParseBool(os.Getenv("ONE_SHOT")); oneShot || enabled {
+				// The request is read from SYNC_REQUEST or SYNC_REQUEST_FILE
+				return (&syncOptions{output: "text"}).run(cmd)
+			}
+			return runServer()
+		},
+	}
+	root.Flags().BoolVar(&oneShot, "one-shot", false, "run the sync request of SYNC_REQUEST or SYNC_REQUEST_FILE and exit, like the sync command (also ONE_SHOT=true)")
+	root.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return &exitError{code: exitUsage, err: err}
+	})
+	root.AddCommand(newServeCommand(), newSyncCommand())
+	return root
 }
@@ -0,0 +1,380 @@
+/*
+Copyright 2025 SharedVolume
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sharedvolume/volume-syncer/internal/client"
+	"github.com/sharedvolume/volume-syncer/internal/config"
+	"github.com/sharedvolume/volume-syncer/internal/models"
+	"github.com/sharedvolume/volume-syncer/internal/server"
+	syncerrors "github.com/sharedvolume/volume-syncer/pkg/errors"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+)
+
+// jobPollInterval is the interval at which the state of the started job is checked
+const jobPollInterval = 500 * time.Millisecond
+
+// syncOptions holds the flags of the sync command
+type syncOptions struct {
+	requestFile string
+	server      string
+	wait        bool
+	timeout     time.Duration
+	output      string
+
+	source  string
+	target  string
+	details []string
+	include []string
+	exclude []string
+	atomic  bool
+
+	url       string
+	branch    string
+	depth     int
+	host      string
+	port      int
+	user      string
+	password  string
+	token     string
+	path      string
+	keyPath   string
+	bucket    string
+	endpoint  string
+	region    string
+	accessKey string
+	secretKey string
+}
+
+func newSyncCommand() *cobra.Command {
+	opts := &syncOptions{}
+	cmd := &cobra.Command{
+		Use:   "sync",
+		Short: "Run a single sync in-process, or submit it to a running server, and exit",
+		Long: `Run a single sync and exit with a non-zero status when it fails.
+
+Without --server the sync runs in-process with the configuration of the environment, which makes
+the binary usable as a Kubernetes init container. With --server the request is submitted to a
+running server and the command waits for the job to finish.
+
+The request is built from the flags, or read from a JSON or YAML file (--request, "-" for stdin)
+in the format of the sync API, in which case the flags override its source and target. Without
+--request the request is read from the SYNC_REQUEST_FILE file or the SYNC_REQUEST variable, if
+set. Source details without a dedicated flag are set with --detail key=value; values are parsed
+as JSON when possible.
+
+The exit status is 0 when the sync succeeded, 1 when it failed, 2 for invalid flags, requests or
+configuration and 3 when the sync failed for a transient reason and running it again is likely to
+succeed.`,
+		Example: `  volume-syncer sync --source git --url https://github.com/org/repo.git --branch main --target /data
+  volume-syncer sync --source http --url https://example.com/dataset.tar.gz --target /data
+  volume-syncer sync --source s3 --endpoint https://s3.amazonaws.com --bucket data --region us-east-1 --target /data
+  volume-syncer sync --request sync.json --server http://volume-syncer:8080`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return opts.run(cmd)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&opts.requestFile, "request", "", "JSON or YAML file with the sync request, - for stdin (default: SYNC_REQUEST_FILE)")
+	flags.StringVar(&opts.server, "server", "", "URL of a running server to submit the sync to, e.g. http://localhost:8080")
+	flags.BoolVar(&opts.wait, "wait", true, "wait for the job submitted to the server to finish")
+	flags.DurationVar(&opts.timeout, "timeout", 0, "sync timeout, overrides SYNC_TIMEOUT in-process and bounds the wait for the server")
+	flags.StringVarP(&opts.output, "output", "o", "text", "output format of the finished job: text or json")
+
+	flags.StringVar(&opts.source, "source", "", "source type: ssh, git, http or s3")
+	flags.StringVar(&opts.target, "target", "", "target path")
+	flags.StringArrayVar(&opts.details, "detail", nil, "source detail as key=value, repeatable")
+	flags.StringArrayVar(&opts.include, "include", nil, "glob pattern of the files to sync, repeatable")
+	flags.StringArrayVar(&opts.exclude, "exclude", nil, "glob pattern of the files never synced, repeatable")
+	flags.BoolVar(&opts.atomic, "atomic", false, "stage the sync next to the target and swap it into place")
+
+	flags.StringVar(&opts.url, "url", "", "repository URL (git) or download URL (http)")
+	flags.StringVar(&opts.branch, "branch", "", "branch to check out (git)")
+	flags.IntVar(&opts.depth, "depth", 0, "clone depth (git)")
+	flags.StringVar(&opts.host, "host", "", "SSH host (ssh)")
+	flags.IntVar(&opts.port, "port", 0, "SSH port (ssh)")
+	flags.StringVar(&opts.user, "user", "", "SSH user (ssh)")
+	flags.StringVar(&opts.password, "password", "", "password (ssh, git)")
+	flags.StringVar(&opts.token, "token", "", "access token (git)")
+	flags.StringVar(&opts.path, "path", "", "remote path (ssh) or key prefix (s3)")
+	flags.StringVar(&opts.keyPath, "key-path", "", "private key file (ssh)")
+	flags.StringVar(&opts.bucket, "bucket", "", "bucket name (s3)")
+	flags.StringVar(&opts.endpoint, "endpoint", "", "endpoint URL (s3)")
+	flags.StringVar(&opts.region, "region", "", "region (s3)")
+	flags.StringVar(&opts.accessKey, "access-key", "", "access key, defaults to AWS_ACCESS_KEY_ID (s3)")
+	flags.StringVar(&opts.secretKey, "secret-key", "", "secret key, defaults to AWS_SECRET_ACCESS_KEY (s3)")
+	return cmd
+}
+
+// run builds the request, runs or submits it and reports the finished job
+func (o *syncOptions) run(cmd *cobra.Command) error {
+	if o.output != "text" && o.output != "json" {
+		return &exitError{code: exitUsage, err: fmt.Errorf("invalid output format %q, expected text or json", o.output)}
+	}
+	req, err := o.request(cmd)
+	if err != nil {
+		return &exitError{code: exitUsage, err: err}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	var job *models.SyncJob
+	if o.server != "" {
+		job, err = o.submit(ctx, req)
+	} else {
+		job, err = o.runInProcess(ctx, req)
+	}
+	if err != nil || job == nil {
+		return err
+	}
+
+	if err := printJob(cmd.OutOrStdout(), job, o.output); err != nil {
+		return err
+	}
+	if job.Status != models.JobStatusSucceeded {
+		err := fmt.Errorf("job %s failed: %s", job.ID, job.Error)
+		if job.Retryable {
+			return &exitError{code: exitRetryable, err: err}
+		}
+		return err
+	}
+	return nil
+}
+
+// request builds the sync request from the request file and the flags
+func (o *syncOptions) request(cmd *cobra.Command) (*models.SyncRequest, error) {
+	req := &models.SyncRequest{}
+	requestFile := o.requestFile
+	if requestFile == "" {
+		requestFile = os.Getenv("SYNC_REQUEST_FILE")
+	}
+	var data []byte
+	var err error
+	switch {
+	case requestFile == "-":
+		data, err = io.ReadAll(cmd.InOrStdin())
+	case requestFile != "":
+		data, err = os.ReadFile(requestFile)
+	default:
+		data = []byte(os.Getenv("SYNC_REQUEST"))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request: %w", err)
+	}
+	// YAML is converted to JSON first, so that source details decode like those of API requests
+	if len(strings.TrimSpace(string(data))) > 0 {
+		if err := yaml.Unmarshal(data, req); err != nil {
+			return nil, fmt.Errorf("invalid request: %w", err)
+		}
+	}
+
+	if o.source != "" {
+		details, err := o.sourceDetails(cmd)
+		if err != nil {
+			return nil, err
+		}
+		req.Source = models.Source{Type: o.source, Details: details}
+	} else if req.Source.Type == "" && len(req.Sources) == 0 {
+		return nil, errors.New("--source or a request with a source is required")
+	}
+	if o.target != "" {
+		req.Target.Path = o.target
+	}
+	if req.Target.Path == "" {
+		return nil, errors.New("--target or a request with a target is required")
+	}
+
+	if len(o.include) > 0 || len(o.exclude) > 0 {
+		if req.Filters == nil {
+			req.Filters = &models.Filters{}
+		}
+		req.Filters.Include = append(req.Filters.Include, o.include...)
+		req.Filters.Exclude = append(req.Filters.Exclude, o.exclude...)
+	}
+	if o.atomic {
+		req.Atomic = true
+	}
+	return req, nil
+}
+
+// sourceDetails returns the source details set by the flags
+func (o *syncOptions) sourceDetails(cmd *cobra.Command) (map[string]interface{}, error) {
+	details := map[string]interface{}{}
+	flags := cmd.Flags()
+	stringFlags := map[string]struct {
+		key   string
+		value string
+	}{
+		"url":        {"url", o.url},
+		"branch":     {"branch", o.branch},
+		"host":       {"host", o.host},
+		"user":       {"user", o.user},
+		"password":   {"password", o.password},
+		"token":      {"token", o.token},
+		"path":       {"path", o.path},
+		"key-path":   {"key_path", o.keyPath},
+		"bucket":     {"bucketName", o.bucket},
+		"endpoint":   {"endpointUrl", o.endpoint},
+		"region":     {"region", o.region},
+		"access-key": {"accessKey", o.accessKey},
+		"secret-key": {"secretKey", o.secretKey},
+	}
+	for flag, detail := range stringFlags {
+		if flags.Changed(flag) {
+			details[detail.key] = detail.value
+		}
+	}
+	// Numbers are passed as JSON numbers, as in API requests
+	if flags.Changed("depth") {
+		details["depth"] = float64(o.depth)
+	}
+	if flags.Changed("port") {
+		details["port"] = float64(o.port)
+	}
+	if o.source == "s3" {
+		for key, env := range map[string]string{"accessKey": "AWS_ACCESS_KEY_ID", "secretKey": "AWS_SECRET_ACCESS_KEY"} {
+			if _, ok := details[key]; !ok && os.Getenv(env) != "" {
+				details[key] = os.Getenv(env)
+			}
+		}
+	}
+
+	for _, detail := range o.details {
+		key, value, ok := cutDetail(detail)
+		if !ok {
+			return nil, fmt.Errorf("invalid --detail %q, expected key=value", detail)
+		}
+		var parsed interface{}
+		if err := json.Unmarshal([]byte(value), &parsed); err != nil {
+			parsed = value
+		}
+		details[key] = parsed
+	}
+	return details, nil
+}
+
+// cutDetail splits a key=value detail
+func cutDetail(detail string) (string, string, bool) {
+	key, value, ok := strings.Cut(detail, "=")
+	return key, value, ok && key != ""
+}
+
+// submit starts the sync on the server and waits for the job unless disabled
+func (o *syncOptions) submit(ctx context.Context, req *models.SyncRequest) (*models.SyncJob, error) {
+	c := client.New(o.server)
+	id, err := c.StartSync(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("[CLI] Job %s started on %s", id, o.server)
+	if !o.wait {
+		fmt.Println(id)
+		return nil, nil
+	}
+
+	if o.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.timeout)
+		defer cancel()
+	}
+	job, err := c.WaitJob(ctx, id, jobPollInterval)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wait for job %s: %w", id, err)
+	}
+	return job, nil
+}
+
+// runInProcess runs the sync with the sync service of this process and waits for it to finish
+func (o *syncOptions) runInProcess(ctx context.Context, req *models.SyncRequest) (*models.SyncJob, error) {
+	cfg := config.Load()
+	if o.timeout > 0 {
+		cfg.Sync.DefaultTimeout = o.timeout
+	}
+	// One-shot syncs are not persisted, a restarted init container starts the sync again
+	syncService, err := server.NewSyncService(cfg, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer syncService.Close()
+	req.Initiator = "cli"
+	started, err := syncService.StartSync(req)
+	var syncErr *syncerrors.SyncError
+	if errors.As(err, &syncErr) && syncErr.Type == syncerrors.ErrTypeValidation {
+		return nil, &exitError{code: exitUsage, err: err}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ticker := time.NewTicker(jobPollInterval)
+	defer ticker.Stop()
+	for {
+		job, _ := syncService.GetJob(started.ID)
+		if job.Finished() {
+			return job, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("sync of job %s interrupted: %w", started.ID, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// printJob writes the finished job in the output format
+func printJob(w io.Writer, job *models.SyncJob, output string) error {
+	if output == "json" {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(job)
+	}
+
+	duration := ""
+	if job.FinishedAt != nil {
+		duration = fmt.Sprintf(" in %v", job.FinishedAt.Sub(job.StartedAt).Round(time.Millisecond))
+	}
+	if job.Status != models.JobStatusSucceeded {
+		_, err := fmt.Fprintf(w, "job %s %s%s\n", job.ID, job.Status, duration)
+		return err
+	}
+	summary := ""
+	if result := job.Result; result != nil {
+		summary = fmt.Sprintf(": %d added, %d changed, %d deleted, %d bytes transferred",
+			result.FilesAdded, result.FilesChanged, result.FilesDeleted, result.BytesTransferred)
+		if result.Revision != "" {
+			summary += ", revision " + result.Revision
+		}
+	}
+	_, err := fmt.Fprintf(w, "job %s %s%s%s\n", job.ID, job.Status, duration, summary)
+	return err
+}
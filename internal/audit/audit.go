@@ -0,0 +1,229 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/sharedvolume/volume-syncer/internal/models"
+)
+
+// queueSize is the number of entries waiting to be written to the sinks, above which entries
+// are dropped instead of delaying the API and the syncs
+const queueSize = 1000
+
+// Results of the sync entries
+const (
+	ResultStarted   = "started"
+	ResultSucceeded = "succeeded"
+	ResultFailed    = "failed"
+)
+
+// Log records API calls and sync executions in append-only sinks and keeps the recent entries in
+// memory for the audit endpoint. Entries are written by a background worker in the order they
+// were recorded. A nil log is disabled.
+type Log struct {
+	sinks   []sink
+	mutex   sync.Mutex
+	recent  []models.AuditEntry // ring of the recent entries
+	next    int                 // position of the next entry in the ring
+	queue   chan []byte         // encoded entries waiting for the sinks, nil without sinks
+	closed  bool
+	done    chan struct{} // closed once the worker wrote the queued entries
+	dropped int           // entries dropped since the last warning
+}
+
+// Filter selects recent audit entries
+type Filter struct {
+	Event string    // api or sync, any when empty
+	JobID string    // entries of the job, any when empty
+	Since time.Time // entries recorded at or after the time, any when zero
+	Limit int       // most recent entries returned, all when 0
+}
+
+// New opens the comma-separated sinks of the spec and keeps the given number of recent entries.
+// Without sinks and recent entries the log is disabled and nil is returned.
+func New(spec string, recent int, httpToken string) (*Log, error) {
+	sinks, err := parseSinks(spec, httpToken)
+	if err != nil {
+		return nil, err
+	}
+	if len(sinks) == 0 && recent <= 0 {
+		return nil, nil
+	}
+	l := &Log{sinks: sinks, recent: make([]models.AuditEntry, 0, max(recent, 0)), done: make(chan struct{})}
+	if len(sinks) == 0 {
+		close(l.done)
+		return l, nil
+	}
+	l.queue = make(chan []byte, queueSize)
+	go l.write()
+	return l, nil
+}
+
+// Sinks returns the descriptions of the sinks, e.g. "file /var/log/volume-syncer/audit.log"
+func (l *Log) Sinks() []string {
+	if l == nil {
+		return nil
+	}
+	names := make([]string, len(l.sinks))
+	for i, sink := range l.sinks {
+		names[i] = sink.String()
+	}
+	return names
+}
+
+// Record adds the entry to the log, stamping it with the current time unless it is set
+func (l *Log) Record(entry models.AuditEntry) {
+	if l == nil {
+		return
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now().UTC()
+	}
+	entry.Error = RedactText(entry.Error)
+	data, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("[AUDIT] ERROR: Failed to encode audit entry: %v", err)
+		return
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if cap(l.recent) > 0 {
+		if len(l.recent) < cap(l.recent) {
+			l.recent = append(l.recent, entry)
+		} else {
+			l.recent[l.next] = entry
+		}
+		l.next = (l.next + 1) % cap(l.recent)
+	}
+	if l.queue == nil || l.closed {
+		return
+	}
+	select {
+	case l.queue <- data:
+		if l.dropped > 0 {
+			log.Printf("[AUDIT] WARNING: Dropped %d audit entries, the sinks did not keep up", l.dropped)
+			l.dropped = 0
+		}
+	default:
+		l.dropped++
+	}
+}
+
+// Recent returns the most recent entries matching the filter, oldest first
+func (l *Log) Recent(filter Filter) []models.AuditEntry {
+	entries := []models.AuditEntry{}
+	if l == nil {
+		return entries
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	start := 0
+	if len(l.recent) == cap(l.recent) {
+		start = l.next
+	}
+	for i := range l.recent {
+		entry := l.recent[(start+i)%len(l.recent)]
+		if filter.Event != "" && entry.Event != filter.Event {
+			continue
+		}
+		if filter.JobID != "" && entry.JobID != filter.JobID {
+			continue
+		}
+		if !filter.Since.IsZero() && entry.Time.Before(filter.Since) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if filter.Limit > 0 && len(entries) > filter.Limit {
+		entries = entries[len(entries)-filter.Limit:]
+	}
+	return entries
+}
+
+// SyncStarted records the start of the job
+func (l *Log) SyncStarted(job *models.SyncJob, req *models.SyncRequest) {
+	entry := syncEntry(job, req)
+	entry.Result = ResultStarted
+	l.Record(entry)
+}
+
+// SyncFinished records the outcome of the finished job
+func (l *Log) SyncFinished(job *models.SyncJob, req *models.SyncRequest) {
+	entry := syncEntry(job, req)
+	entry.Result = ResultSucceeded
+	if job.Status == models.JobStatusFailed {
+		entry.Result = ResultFailed
+		entry.Error, entry.ErrorCode = job.Error, job.ErrorCode
+	}
+	if job.Result != nil {
+		entry.Revision = job.Result.Revision
+	}
+	if job.FinishedAt != nil && !job.StartedAt.IsZero() {
+		entry.DurationMs = job.FinishedAt.Sub(job.StartedAt).Milliseconds()
+	}
+	l.Record(entry)
+}
+
+func syncEntry(job *models.SyncJob, req *models.SyncRequest) models.AuditEntry {
+	entry := models.AuditEntry{
+		Event:      models.AuditEventSync,
+		JobID:      job.ID,
+		SourceType: job.SourceType,
+		Target:     job.TargetPath,
+	}
+	if req != nil {
+		entry.Actor = req.Initiator
+		entry.Sources = Sources(req)
+	}
+	return entry
+}
+
+// Close writes the queued entries and closes the sinks. Entries recorded afterwards are only
+// kept in memory.
+func (l *Log) Close() {
+	if l == nil {
+		return
+	}
+	l.mutex.Lock()
+	if l.closed {
+		l.mutex.Unlock()
+		return
+	}
+	l.closed = true
+	if l.queue != nil {
+		close(l.queue)
+	}
+	l.mutex.Unlock()
+
+	<-l.done
+	for _, sink := range l.sinks {
+		if err := sink.close(); err != nil {
+			log.Printf("[AUDIT] WARNING: Failed to close audit sink %s: %v", sink, err)
+		}
+	}
+}
+
+// write passes the queued entries to every sink until the log is closed
+func (l *Log) write() {
+	defer close(l.done)
+	for data := range l.queue {
+		for _, sink := range l.sinks {
+			if err := sink.write(data); err != nil {
+				log.Printf("[AUDIT] ERROR: Failed to write audit entry to %s: %v", sink, err)
+			}
+		}
+	}
+}
+
+// sink receives the audit entries, each as a JSON document
+type sink interface {
+	fmt.Stringer
+	write(entry []byte) error
+	close() error
+}
@@ -0,0 +1,126 @@
+package audit
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/sharedvolume/volume-syncer/internal/models"
+)
+
+var (
+	// urlRegex matches the URLs in texts such as error messages
+	urlRegex = regexp.MustCompile(`[A-Za-z][A-Za-z0-9+.-]*://[^\s"'<>]+`)
+	// userinfoRegex matches the user information of a URL, e.g. the token of
+	// https://token@github.com/org/repo.git
+	userinfoRegex = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9+.-]*://)[^/@]+@`)
+)
+
+// RedactURL returns the URL without its user information, query and fragment, which may hold
+// credentials such as tokens or presigned signatures. Values that are not URLs with a scheme,
+// e.g. scp-like Git URLs, are returned as they are.
+func RedactURL(value string) string {
+	if !strings.Contains(value, "://") {
+		return value
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		value, _, _ = strings.Cut(value, "?")
+		return userinfoRegex.ReplaceAllString(value, "${1}***@")
+	}
+	hasUser := u.User != nil
+	u.User = nil
+	u.RawQuery, u.Fragment, u.RawFragment = "", "", ""
+	u.ForceQuery = false
+	if hasUser {
+		return strings.Replace(u.String(), "://", "://***@", 1)
+	}
+	return u.String()
+}
+
+// RedactText redacts the URLs in the text, e.g. an error message
+func RedactText(text string) string {
+	return urlRegex.ReplaceAllStringFunc(text, RedactURL)
+}
+
+// RedactError returns the error with its URLs redacted
+func RedactError(err error) error {
+	return errors.New(RedactText(err.Error()))
+}
+
+// RedactJob returns a copy of the job with the URLs in its errors redacted
+func RedactJob(job models.SyncJob) models.SyncJob {
+	job.Error = RedactText(job.Error)
+	job.Attempts = slices.Clone(job.Attempts)
+	for i := range job.Attempts {
+		job.Attempts[i].Error = RedactText(job.Attempts[i].Error)
+	}
+	return job
+}
+
+// Sources returns the locations the request syncs from, e.g. repository URLs, without
+// credentials. Multi-source requests list every source with the subdirectory it syncs into.
+func Sources(req *models.SyncRequest) []string {
+	if len(req.Sources) == 0 {
+		if req.Source.Type == "" {
+			return nil
+		}
+		return []string{location(req.Source)}
+	}
+	sources := make([]string, len(req.Sources))
+	for i, source := range req.Sources {
+		sources[i] = fmt.Sprintf("%s -> %s", location(source.Source), source.Path)
+	}
+	return sources
+}
+
+// location describes the source by the fields naming its location in the details: url, the
+// host and path of SSH sources or the bucket of S3 sources. Secret references are not resolved.
+func location(source models.Source) string {
+	details, _ := source.Details.(map[string]interface{})
+	field := func(key string) string {
+		value, _ := details[key].(string)
+		return value
+	}
+
+	var description string
+	switch {
+	case field("url") != "":
+		description = RedactURL(field("url"))
+		if branch := field("branch"); branch != "" {
+			description += " (branch " + branch + ")"
+		}
+	case field("bucketName") != "":
+		description = "s3://" + field("bucketName") + "/" + strings.TrimPrefix(field("path"), "/")
+		if endpoint := field("endpointUrl"); endpoint != "" {
+			description += " at " + RedactURL(endpoint)
+		}
+	case field("host") != "":
+		description = "ssh://" + field("host")
+		if user := field("user"); user != "" {
+			description = "ssh://" + user + "@" + field("host")
+		}
+		if port, ok := details["port"].(float64); ok {
+			description += fmt.Sprintf(":%d", int(port))
+		}
+		description += "/" + strings.TrimPrefix(field("path"), "/")
+	default:
+		if repos, ok := details["repos"].([]interface{}); ok {
+			urls := make([]string, 0, len(repos))
+			for _, repo := range repos {
+				repoMap, _ := repo.(map[string]interface{})
+				if repoURL, ok := repoMap["url"].(string); ok {
+					urls = append(urls, RedactURL(repoURL))
+				}
+			}
+			description = strings.Join(urls, ", ")
+		}
+	}
+	if description == "" {
+		return source.Type
+	}
+	return source.Type + " " + description
+}
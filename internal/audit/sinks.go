@@ -0,0 +1,133 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// httpTimeout limits the delivery of an entry to an HTTP sink
+const httpTimeout = 10 * time.Second
+
+// parseSinks opens the comma-separated sinks: absolute file paths, "syslog" for the local syslog
+// daemon, "syslog://host:514" or "syslog+tcp://host:514" for a remote one, and http or https URLs
+// entries are posted to
+func parseSinks(spec, httpToken string) ([]sink, error) {
+	var sinks []sink
+	for _, value := range strings.Split(spec, ",") {
+		value = strings.TrimSpace(value)
+		var s sink
+		var err error
+		switch {
+		case value == "":
+			continue
+		case filepath.IsAbs(value):
+			s, err = newFileSink(value)
+		case value == "syslog":
+			s, err = newSyslogSink("", "")
+		case strings.HasPrefix(value, "syslog://"), strings.HasPrefix(value, "syslog+tcp://"):
+			u, parseErr := url.Parse(value)
+			if parseErr != nil || u.Host == "" {
+				return nil, fmt.Errorf("invalid syslog audit sink %q, use syslog://host:514", value)
+			}
+			network := "udp"
+			if u.Scheme == "syslog+tcp" {
+				network = "tcp"
+			}
+			s, err = newSyslogSink(network, u.Host)
+		case strings.HasPrefix(value, "http://"), strings.HasPrefix(value, "https://"):
+			s, err = newHTTPSink(value, httpToken)
+		default:
+			return nil, fmt.Errorf("invalid audit sink %q: use an absolute file path, syslog, syslog://host:port or an http(s) URL", value)
+		}
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, s)
+	}
+	return sinks, nil
+}
+
+// fileSink appends the entries to a file as JSON lines. The file is never truncated, rotation is
+// left to tools such as logrotate with copytruncate.
+type fileSink struct {
+	file *os.File
+}
+
+func newFileSink(path string) (*fileSink, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return nil, fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	return &fileSink{file: file}, nil
+}
+
+func (s *fileSink) String() string {
+	return "file " + s.file.Name()
+}
+
+func (s *fileSink) write(entry []byte) error {
+	_, err := s.file.Write(append(entry, '\n'))
+	return err
+}
+
+func (s *fileSink) close() error {
+	return s.file.Close()
+}
+
+// httpSink posts each entry as a JSON document, e.g. to a log collector
+type httpSink struct {
+	url    *url.URL
+	token  string
+	client *http.Client
+}
+
+func newHTTPSink(value, token string) (*httpSink, error) {
+	u, err := url.Parse(value)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid HTTP audit sink %q", value)
+	}
+	return &httpSink{url: u, token: token, client: &http.Client{Timeout: httpTimeout}}, nil
+}
+
+func (s *httpSink) String() string {
+	return "URL " + RedactURL(s.url.String())
+}
+
+func (s *httpSink) write(entry []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), httpTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url.String(), bytes.NewReader(entry))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return RedactError(err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (s *httpSink) close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
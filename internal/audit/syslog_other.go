@@ -0,0 +1,10 @@
+//go:build !unix
+
+package audit
+
+import "errors"
+
+// newSyslogSink fails, syslog is not available on this platform
+func newSyslogSink(network, address string) (sink, error) {
+	return nil, errors.New("syslog audit sinks are not supported on this platform")
+}
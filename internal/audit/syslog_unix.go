@@ -0,0 +1,43 @@
+//go:build unix
+
+package audit
+
+import (
+	"fmt"
+	"log/syslog"
+)
+
+// syslogTag identifies the entries in the syslog
+const syslogTag = "volume-syncer"
+
+// syslogSink sends the entries to a syslog daemon with the authpriv facility, which is meant for
+// security records
+type syslogSink struct {
+	writer *syslog.Writer
+	name   string
+}
+
+// newSyslogSink connects to the syslog daemon at the address, the local one when empty
+func newSyslogSink(network, address string) (*syslogSink, error) {
+	writer, err := syslog.Dial(network, address, syslog.LOG_INFO|syslog.LOG_AUTHPRIV, syslogTag)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to syslog: %w", err)
+	}
+	name := "local syslog"
+	if address != "" {
+		name = "syslog " + network + "://" + address
+	}
+	return &syslogSink{writer: writer, name: name}, nil
+}
+
+func (s *syslogSink) String() string {
+	return s.name
+}
+
+func (s *syslogSink) write(entry []byte) error {
+	return s.writer.Info(string(entry))
+}
+
+func (s *syslogSink) close() error {
+	return s.writer.Close()
+}
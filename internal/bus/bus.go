@@ -0,0 +1,222 @@
+package bus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sharedvolume/volume-syncer/internal/audit"
+	"github.com/sharedvolume/volume-syncer/internal/models"
+)
+
+// queueSize is the number of events waiting to be published, above which events are dropped
+// instead of delaying the syncs
+const queueSize = 1000
+
+// publishTimeout bounds the delivery of an event to the broker
+const publishTimeout = 10 * time.Second
+
+// Publisher publishes the lifecycle events of sync jobs to a topic of a message bus. Events are
+// published by a background worker in the order they were recorded. A nil publisher is disabled.
+type Publisher struct {
+	broker   broker
+	host     string
+	interval time.Duration // interval of progress checkpoints, 0 disables them
+	mutex    sync.Mutex
+	queue    chan message
+	closed   bool
+	done     chan struct{} // closed once the worker published the queued events
+	dropped  int           // events dropped since the last warning
+}
+
+// message is an encoded event waiting to be published
+type message struct {
+	key       string // target path, so that the events of a target keep their order in Kafka
+	eventType string
+	data      []byte
+}
+
+// broker publishes messages to the topic of a message bus
+type broker interface {
+	fmt.Stringer
+	publish(ctx context.Context, msg message) error
+	close() error
+}
+
+// New connects to the message bus of the URL and publishes to the topic, with a progress
+// checkpoint of running jobs every interval. nats:// and tls:// URLs select NATS, kafka:// and
+// kafka+tls:// URLs Kafka; several servers or brokers are separated by commas. Without a URL the
+// publisher is disabled and nil is returned.
+func New(spec, topic string, interval time.Duration) (*Publisher, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, nil
+	}
+	if topic == "" {
+		return nil, fmt.Errorf("a topic is required")
+	}
+	scheme, _, _ := strings.Cut(spec, "://")
+	var b broker
+	var err error
+	switch scheme {
+	case "nats", "tls":
+		b, err = newNATSBroker(spec, topic)
+	case "kafka", "kafka+tls":
+		b, err = newKafkaBroker(spec, topic)
+	default:
+		return nil, fmt.Errorf("invalid event bus %q: use nats://host:4222 or kafka://host:9092", audit.RedactURL(spec))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Publisher{broker: b, interval: interval, queue: make(chan message, queueSize), done: make(chan struct{})}
+	p.host, _ = os.Hostname()
+	go p.write()
+	return p, nil
+}
+
+// String describes the broker and the topic, e.g. "NATS nats://nats:4222 subject volume-syncer.events"
+func (p *Publisher) String() string {
+	if p == nil {
+		return "disabled"
+	}
+	return p.broker.String()
+}
+
+// Submitted publishes the acceptance of the job
+func (p *Publisher) Submitted(job *models.SyncJob, req *models.SyncRequest) {
+	p.publish(models.SyncEventSubmitted, *job, req)
+}
+
+// Started publishes the start of the job
+func (p *Publisher) Started(job *models.SyncJob, req *models.SyncRequest) {
+	p.publish(models.SyncEventStarted, *job, req)
+}
+
+// Completed publishes the outcome of the finished job
+func (p *Publisher) Completed(job *models.SyncJob, req *models.SyncRequest) {
+	p.publish(models.SyncEventCompleted, *job, req)
+}
+
+// Progress publishes a checkpoint of the running job every interval until the returned function
+// is called. The job is read through the function, checkpoints without new progress are skipped.
+func (p *Publisher) Progress(job func() models.SyncJob, req *models.SyncRequest) (stop func()) {
+	if p == nil || p.interval <= 0 {
+		return func() {}
+	}
+	stopped := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+		var last time.Time
+		for {
+			select {
+			case <-stopped:
+				return
+			case <-ticker.C:
+				current := job()
+				if current.Progress == nil || current.Progress.UpdatedAt.Equal(last) {
+					continue
+				}
+				last = current.Progress.UpdatedAt
+				p.publish(models.SyncEventProgress, current, req)
+			}
+		}
+	}()
+	return sync.OnceFunc(func() { close(stopped) })
+}
+
+// publish encodes the event and queues it for the worker
+func (p *Publisher) publish(eventType string, job models.SyncJob, req *models.SyncRequest) {
+	if p == nil {
+		return
+	}
+	event := models.SyncEvent{
+		Type: eventType,
+		Time: time.Now().UTC(),
+		Host: p.host,
+		Job:  audit.RedactJob(job),
+	}
+	if req != nil {
+		event.Initiator = req.Initiator
+		event.Sources = audit.Sources(req)
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("[BUS] ERROR: Failed to encode %s event of job %s: %v", eventType, job.ID, err)
+		return
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.closed {
+		return
+	}
+	select {
+	case p.queue <- message{key: job.TargetPath, eventType: eventType, data: data}:
+		if p.dropped > 0 {
+			log.Printf("[BUS] WARNING: Dropped %d sync events, the event bus did not keep up", p.dropped)
+			p.dropped = 0
+		}
+	default:
+		p.dropped++
+	}
+}
+
+// Close publishes the queued events and disconnects from the message bus. Events recorded
+// afterwards are dropped.
+func (p *Publisher) Close() {
+	if p == nil {
+		return
+	}
+	p.mutex.Lock()
+	if p.closed {
+		p.mutex.Unlock()
+		return
+	}
+	p.closed = true
+	close(p.queue)
+	p.mutex.Unlock()
+
+	<-p.done
+	if err := p.broker.close(); err != nil {
+		log.Printf("[BUS] WARNING: Failed to disconnect from %s: %v", p.broker, err)
+	}
+}
+
+// write publishes the queued events until the publisher is closed
+func (p *Publisher) write() {
+	defer close(p.done)
+	for msg := range p.queue {
+		ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+		if err := p.broker.publish(ctx, msg); err != nil {
+			log.Printf("[BUS] ERROR: Failed to publish %s event to %s: %v", msg.eventType, p.broker, audit.RedactError(err))
+		}
+		cancel()
+	}
+}
+
+// splitURLs splits the comma-separated URLs of servers or brokers, checking they use the scheme
+// of the first one
+func splitURLs(spec string) ([]*url.URL, error) {
+	var urls []*url.URL
+	for _, value := range strings.Split(spec, ",") {
+		value = strings.TrimSpace(value)
+		u, err := url.Parse(value)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid event bus URL %q", audit.RedactURL(value))
+		}
+		if len(urls) > 0 && u.Scheme != urls[0].Scheme {
+			return nil, fmt.Errorf("event bus URLs must use the same scheme, got %s and %s", urls[0].Scheme, u.Scheme)
+		}
+		urls = append(urls, u)
+	}
+	return urls, nil
+}
@@ -0,0 +1,84 @@
+package bus
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+)
+
+// kafkaBroker publishes to a Kafka topic, keyed by the target path so that the events of a target
+// land on the same partition. Credentials are taken from the user information of the first URL
+// and authenticated with SASL PLAIN, or SCRAM with ?mechanism=scram-sha-256 or scram-sha-512.
+type kafkaBroker struct {
+	writer  *kafka.Writer
+	brokers string
+}
+
+func newKafkaBroker(spec, topic string) (*kafkaBroker, error) {
+	urls, err := splitURLs(spec)
+	if err != nil {
+		return nil, err
+	}
+	brokers := make([]string, len(urls))
+	for i, u := range urls {
+		brokers[i] = u.Host
+	}
+
+	transport := &kafka.Transport{}
+	if urls[0].Scheme == "kafka+tls" {
+		transport.TLS = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	if user := urls[0].User; user != nil {
+		password, _ := user.Password()
+		var mechanism sasl.Mechanism
+		switch name := strings.ToLower(urls[0].Query().Get("mechanism")); name {
+		case "", "plain":
+			mechanism = plain.Mechanism{Username: user.Username(), Password: password}
+		case "scram-sha-256":
+			mechanism, err = scram.Mechanism(scram.SHA256, user.Username(), password)
+		case "scram-sha-512":
+			mechanism, err = scram.Mechanism(scram.SHA512, user.Username(), password)
+		default:
+			return nil, fmt.Errorf("invalid Kafka SASL mechanism %q, expected plain, scram-sha-256 or scram-sha-512", name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure Kafka SASL: %w", err)
+		}
+		transport.SASL = mechanism
+	}
+
+	return &kafkaBroker{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(brokers...),
+			Topic:        topic,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireAll,
+			BatchTimeout: 10 * time.Millisecond, // events are written one at a time
+			Transport:    transport,
+		},
+		brokers: strings.Join(brokers, ","),
+	}, nil
+}
+
+func (b *kafkaBroker) String() string {
+	return "Kafka " + b.brokers + " topic " + b.writer.Topic
+}
+
+func (b *kafkaBroker) publish(ctx context.Context, msg message) error {
+	return b.writer.WriteMessages(ctx, kafka.Message{
+		Key:     []byte(msg.key),
+		Value:   msg.data,
+		Headers: []kafka.Header{{Key: "Sync-Event-Type", Value: []byte(msg.eventType)}},
+	})
+}
+
+func (b *kafkaBroker) close() error {
+	return b.writer.Close()
+}
@@ -0,0 +1,131 @@
+package bwlimit
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/sharedvolume/volume-syncer/internal/quota"
+	"golang.org/x/time/rate"
+)
+
+// maxBurst bounds the bytes a limiter lets through at once, which keeps the transfer smooth for
+// high rates
+const maxBurst = 256 * 1024
+
+// Limiter is a token bucket limiting the bytes per second of the transfers it throttles. A
+// limiter can be nested in a parent, e.g. a request limit in the global limit, in which case
+// transfers wait for both.
+type Limiter struct {
+	limiter *rate.Limiter
+	parent  *Limiter
+	rate    int64 // bytes per second
+}
+
+// Parse parses a rate in bytes per second, e.g. "10Mi" or "500K"; an empty spec is unlimited
+func Parse(spec string) (int64, error) {
+	if spec == "" {
+		return 0, nil
+	}
+	bytesPerSecond, err := quota.ParseSize(spec)
+	if err != nil {
+		return 0, fmt.Errorf("invalid bandwidth limit %q: %w", spec, err)
+	}
+	return bytesPerSecond, nil
+}
+
+// New creates a limiter of the bytes per second nested in the parent. The parent is returned
+// when the rate is not positive, nil when neither limits.
+func New(bytesPerSecond int64, parent *Limiter) *Limiter {
+	if bytesPerSecond <= 0 {
+		return parent
+	}
+	burst := min(bytesPerSecond, maxBurst)
+	return &Limiter{
+		limiter: rate.NewLimiter(rate.Limit(bytesPerSecond), int(burst)),
+		parent:  parent,
+		rate:    bytesPerSecond,
+	}
+}
+
+// Rate returns the lowest rate of the limiter and its parents in bytes per second, 0 when unlimited
+func (l *Limiter) Rate() int64 {
+	if l == nil {
+		return 0
+	}
+	if parent := l.parent.Rate(); parent > 0 && parent < l.rate {
+		return parent
+	}
+	return l.rate
+}
+
+// WaitN blocks until the limiter and its parents allow n bytes or the context is done
+func (l *Limiter) WaitN(ctx context.Context, n int) error {
+	for limiter := l; limiter != nil; limiter = limiter.parent {
+		for remaining := n; remaining > 0; {
+			chunk := min(remaining, limiter.limiter.Burst())
+			if err := limiter.limiter.WaitN(ctx, chunk); err != nil {
+				// The limiter fails right away when the wait would exceed the deadline, the
+				// transfer ends with the deadline instead, as an unthrottled one would
+				if _, ok := ctx.Deadline(); ok && ctx.Err() == nil {
+					<-ctx.Done()
+				}
+				if ctx.Err() != nil {
+					return context.Cause(ctx)
+				}
+				return err
+			}
+			remaining -= chunk
+		}
+	}
+	return nil
+}
+
+// Reader returns the reader throttled by the limiter, or r when the limiter is nil
+func (l *Limiter) Reader(ctx context.Context, r io.Reader) io.Reader {
+	if l == nil {
+		return r
+	}
+	return &reader{ctx: ctx, r: r, limiter: l}
+}
+
+// WriterAt returns the writer throttled by the limiter, or w when the limiter is nil. Concurrent
+// writers, e.g. the parts of a download, share the rate.
+func (l *Limiter) WriterAt(ctx context.Context, w io.WriterAt) io.WriterAt {
+	if l == nil {
+		return w
+	}
+	return &writerAt{ctx: ctx, w: w, limiter: l}
+}
+
+type reader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *Limiter
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	if len(p) > maxBurst {
+		p = p[:maxBurst]
+	}
+	n, err := r.r.Read(p)
+	if n > 0 {
+		if waitErr := r.limiter.WaitN(r.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+type writerAt struct {
+	ctx     context.Context
+	w       io.WriterAt
+	limiter *Limiter
+}
+
+func (w *writerAt) WriteAt(p []byte, off int64) (int, error) {
+	if err := w.limiter.WaitN(w.ctx, len(p)); err != nil {
+		return 0, err
+	}
+	return w.w.WriteAt(p, off)
+}
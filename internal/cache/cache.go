@@ -0,0 +1,244 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Cache stores downloaded files by the identity of their source version, e.g. URL and ETag, so
+// that syncing the same artifact into several targets downloads it once. A nil cache is disabled.
+type Cache struct {
+	dir       string
+	maxBytes  int64 // total size above which the least recently used objects are removed, 0 for unlimited
+	hardlinks bool  // populate targets with hard links to cached objects instead of copies
+	mutex     sync.Mutex
+}
+
+// Ref records the version of a URL stored in the cache
+type Ref struct {
+	ETag     string `json:"etag"`
+	Filename string `json:"filename"`
+}
+
+// New returns the cache in dir, or nil when dir is empty
+func New(dir string, maxBytes int64, hardlinks bool) (*Cache, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	for _, sub := range []string{"objects", "refs"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0700); err != nil {
+			return nil, fmt.Errorf("failed to create download cache: %w", err)
+		}
+	}
+	return &Cache{dir: dir, maxBytes: maxBytes, hardlinks: hardlinks}, nil
+}
+
+// Key derives the cache key of a source version from its identifying parts
+func Key(parts ...string) string {
+	hash := sha256.New()
+	for _, part := range parts {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func (c *Cache) objectPath(key string) string {
+	return filepath.Join(c.dir, "objects", key)
+}
+
+func (c *Cache) refPath(name string) string {
+	return filepath.Join(c.dir, "refs", Key(name))
+}
+
+// Open opens the cached object of the key. The error wraps fs.ErrNotExist on a cache miss.
+func (c *Cache) Open(key string) (*os.File, error) {
+	if c == nil || key == "" {
+		return nil, fs.ErrNotExist
+	}
+	file, err := os.Open(c.objectPath(key))
+	if err != nil {
+		return nil, err
+	}
+	// The modification time tracks the last use for eviction
+	now := time.Now()
+	os.Chtimes(c.objectPath(key), now, now)
+	return file, nil
+}
+
+// Populate hard links the cached object of the key to the destination when hard links are
+// enabled, and reports whether it did. Callers copy the object when it did not.
+func (c *Cache) Populate(key, dest string) (bool, error) {
+	if c == nil || !c.hardlinks {
+		return false, nil
+	}
+	if err := os.Remove(dest); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return false, err
+	}
+	if err := os.Link(c.objectPath(key), dest); err != nil {
+		// Targets on another filesystem than the cache are populated with copies
+		log.Printf("[DOWNLOAD CACHE] Cannot hard link cached object to %s, copying: %v", dest, err)
+		return false, nil
+	}
+	return true, nil
+}
+
+// Entry is an object being written to the cache. It becomes visible once committed.
+type Entry struct {
+	cache *Cache
+	key   string
+	file  *os.File
+}
+
+// Create starts writing the object of the key
+func (c *Cache) Create(key string) (*Entry, error) {
+	if c == nil {
+		return nil, nil
+	}
+	file, err := os.CreateTemp(filepath.Join(c.dir, "objects"), ".tmp-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cache entry: %w", err)
+	}
+	// Objects become the files of targets populated by hard links
+	if err := file.Chmod(0644); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, fmt.Errorf("failed to create cache entry: %w", err)
+	}
+	return &Entry{cache: c, key: key, file: file}, nil
+}
+
+// Write appends to the object
+func (e *Entry) Write(p []byte) (int, error) {
+	return e.file.Write(p)
+}
+
+// Commit makes the object available under its key
+func (e *Entry) Commit() error {
+	if err := e.file.Close(); err != nil {
+		os.Remove(e.file.Name())
+		return err
+	}
+	if err := os.Rename(e.file.Name(), e.cache.objectPath(e.key)); err != nil {
+		os.Remove(e.file.Name())
+		return err
+	}
+	e.cache.prune()
+	return nil
+}
+
+// Abort discards the object
+func (e *Entry) Abort() {
+	e.file.Close()
+	os.Remove(e.file.Name())
+}
+
+// Put adds a downloaded file to the cache, by hard link when enabled and possible
+func (c *Cache) Put(key, path string) error {
+	if c == nil {
+		return nil
+	}
+	if c.hardlinks {
+		os.Remove(c.objectPath(key))
+		if err := os.Link(path, c.objectPath(key)); err == nil {
+			c.prune()
+			return nil
+		}
+	}
+
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	entry, err := c.Create(key)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(entry, src); err != nil {
+		entry.Abort()
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	return entry.Commit()
+}
+
+// Ref returns the cached version of the named source, e.g. a URL
+func (c *Cache) Ref(name string) (Ref, bool) {
+	var ref Ref
+	if c == nil {
+		return ref, false
+	}
+	data, err := os.ReadFile(c.refPath(name))
+	if err != nil || json.Unmarshal(data, &ref) != nil || ref.ETag == "" {
+		return ref, false
+	}
+	if _, err := os.Stat(c.objectPath(Key(name, ref.ETag))); err != nil {
+		return ref, false
+	}
+	return ref, true
+}
+
+// SetRef records the cached version of the named source
+func (c *Cache) SetRef(name string, ref Ref) error {
+	if c == nil {
+		return nil
+	}
+	data, err := json.Marshal(ref)
+	if err != nil {
+		return err
+	}
+	tmp := c.refPath(name) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.refPath(name))
+}
+
+// prune removes the least recently used objects while the cache exceeds its size
+func (c *Cache) prune() {
+	if c.maxBytes <= 0 {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entries, err := os.ReadDir(filepath.Join(c.dir, "objects"))
+	if err != nil {
+		log.Printf("[DOWNLOAD CACHE] WARNING: Failed to list cached objects: %v", err)
+		return
+	}
+	var objects []fs.FileInfo
+	var total int64
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() || info.Name()[0] == '.' {
+			continue
+		}
+		objects = append(objects, info)
+		total += info.Size()
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].ModTime().Before(objects[j].ModTime()) })
+
+	for _, object := range objects {
+		if total <= c.maxBytes {
+			break
+		}
+		log.Printf("[DOWNLOAD CACHE] Evicting %s (%d bytes)", object.Name(), object.Size())
+		if err := os.Remove(c.objectPath(object.Name())); err != nil {
+			log.Printf("[DOWNLOAD CACHE] WARNING: Failed to evict %s: %v", object.Name(), err)
+			continue
+		}
+		total -= object.Size()
+	}
+}
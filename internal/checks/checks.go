@@ -0,0 +1,47 @@
+package checks
+
+import (
+	"time"
+
+	"github.com/sharedvolume/volume-syncer/internal/models"
+)
+
+// Report collects the results of the checks of a source. Once a check failed, the checks that
+// depend on it are not run.
+type Report struct {
+	Checks []models.SourceCheck
+	failed bool
+}
+
+// Run performs the check unless an earlier check failed, and records its outcome. The check
+// returns a message describing what it found.
+func (r *Report) Run(name string, check func() (string, error)) bool {
+	if r.failed {
+		return false
+	}
+	started := time.Now()
+	message, err := check()
+	result := models.SourceCheck{
+		Name:       name,
+		Status:     models.CheckPassed,
+		Message:    message,
+		DurationMs: time.Since(started).Milliseconds(),
+	}
+	if err != nil {
+		result.Status = models.CheckFailed
+		result.Error = err.Error()
+		r.failed = true
+	}
+	r.Checks = append(r.Checks, result)
+	return err == nil
+}
+
+// Failed reports whether a check failed
+func (r *Report) Failed() bool {
+	return r.failed
+}
+
+// Failed returns the result of a check that could not be run
+func Failed(name string, err error) []models.SourceCheck {
+	return []models.SourceCheck{{Name: name, Status: models.CheckFailed, Error: err.Error()}}
+}
@@ -0,0 +1,132 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sharedvolume/volume-syncer/internal/models"
+)
+
+// Client calls the API of a running volume syncer server
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// New creates a client for the server at baseURL, e.g. http://localhost:8080
+func New(baseURL string) *Client {
+	return &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// StartSync submits the sync request and returns the ID of the started or queued job, or of the
+// pending job an identical request was attached to
+func (c *Client) StartSync(ctx context.Context, req *models.SyncRequest) (string, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode request: %w", err)
+	}
+	var response models.SyncResponse
+	status, err := c.do(ctx, http.MethodPost, "/api/1.0/sync", body, &response)
+	if err != nil {
+		return "", err
+	}
+	if status != http.StatusCreated && status != http.StatusAccepted && status != http.StatusOK {
+		return "", responseError(status, response)
+	}
+	return response.JobID, nil
+}
+
+// GetJob returns the job with the given ID
+func (c *Client) GetJob(ctx context.Context, id string) (*models.SyncJob, error) {
+	resp, err := c.send(ctx, http.MethodGet, "/api/1.0/sync/jobs/"+url.PathEscape(id), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var response models.SyncResponse
+		json.Unmarshal(data, &response)
+		return nil, responseError(resp.StatusCode, response)
+	}
+	var job models.SyncJob
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, fmt.Errorf("invalid job response: %w", err)
+	}
+	return &job, nil
+}
+
+// WaitJob polls the job until it finished and returns its final state
+func (c *Client) WaitJob(ctx context.Context, id string, interval time.Duration) (*models.SyncJob, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		job, err := c.GetJob(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if job.Finished() {
+			return job, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body []byte, response any) (int, error) {
+	resp, err := c.send(ctx, method, path, body)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(response); err != nil && err != io.EOF {
+		return resp.StatusCode, fmt.Errorf("invalid response (HTTP %d): %w", resp.StatusCode, err)
+	}
+	return resp.StatusCode, nil
+}
+
+func (c *Client) send(ctx context.Context, method, path string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	log.Printf("[CLIENT] %s %s", method, req.URL.Redacted())
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach server: %w", err)
+	}
+	return resp, nil
+}
+
+// responseError describes an error response of the server
+func responseError(status int, response models.SyncResponse) error {
+	message := response.Error
+	if message == "" {
+		message = http.StatusText(status)
+	}
+	if response.Details != "" {
+		message += ": " + response.Details
+	}
+	return fmt.Errorf("server returned HTTP %d: %s", status, message)
+}
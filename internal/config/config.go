@@ -9,6 +9,7 @@ import (
 type Config struct {
 	Server ServerConfig
 	Sync   SyncConfig
+	HTTP   HTTPConfig
 }
 
 type ServerConfig struct {
@@ -16,10 +17,95 @@ type ServerConfig struct {
 	ReadTimeout  time.Duration
 	WriteTimeout time.Duration
 	IdleTimeout  time.Duration
+	AdminToken   string // Bearer token of the administrative endpoints, which are disabled when empty
+	SwaggerUI    bool   // Serve Swagger UI for the OpenAPI document at /docs
+	Debug        bool   // Serve the pprof and expvar endpoints below /debug, guarded by the admin token
+	MaxBodySize  string // Size of the largest request body accepted by the API, e.g. 1Mi
+}
+
+// HTTPConfig configures the transport shared by HTTP sources, HTTP hooks and notifications
+type HTTPConfig struct {
+	CAFile                string // PEM bundle of certificate authorities trusted in addition to the system roots
+	ClientCertFile        string // Client certificate presented to servers that request one, with its key
+	ClientKeyFile         string
+	TLSMinVersion         string // Oldest TLS version accepted: 1.0, 1.1, 1.2 or 1.3
+	DialTimeout           time.Duration
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration // Time to wait for the response headers after sending a request, 0 for no limit
+	IdleConnTimeout       time.Duration // Time idle connections are kept open for reuse
+	MaxIdleConnsPerHost   int           // Idle connections kept open to each server
+	MaxConnsPerHost       int           // Connections to each server, 0 for no limit
+	KeepAlives            bool          // Reuse connections for subsequent requests
 }
 
 type SyncConfig struct {
-	DefaultTimeout time.Duration
+	DefaultTimeout      time.Duration
+	DefinitionsFile     string
+	StartupDefinitions  string // Comma-separated definitions run at startup, which readiness waits for
+	TriggerFile         string // File whose modification triggers a resync, disabled when empty
+	TriggerDefinition   string // Definition run by resyncs instead of the last sync
+	GitImplementation   string // Git implementation: exec (git binary) or go-git
+	GitCacheDir         string // Shared Git object cache (reference repositories), disabled when empty
+	GitCacheDissociate  bool   // Copy borrowed objects into each target instead of keeping alternates
+	StrictHostKeys      bool   // Reject SSH connections without host key material instead of skipping verification
+	SSHKeyFiles         bool   // Pass private keys to ssh in temporary files instead of an in-process agent
+	SSHControlMaster    bool   // Share one SSH connection between the rsync processes of a sync unless the source sets controlMaster
+	HooksFile           string // JSON file with the commands allowed as pre- and post-sync hooks
+	NotificationsFile   string // YAML or JSON file with the sinks notified of finished jobs
+	HookTimeout         time.Duration
+	SecretsDir          string // Directory of the secrets referenced by name in requests, e.g. decryption keys
+	SecretEnvNames      string // Comma-separated patterns of the environment variables source details may reference
+	SecretFileRoots     string // Comma-separated directories below which source details may reference files
+	SecretManagerNames  string // Comma-separated patterns of the AWS or GCP secret manager secrets source details may reference
+	DefaultUID          int    // Owner applied to synced files unless the request sets one, -1 to keep
+	DefaultGID          int    // Group applied to synced files unless the request sets one, -1 to keep
+	DefaultChmod        string // Permission changes (rsync --chmod syntax) applied unless the request sets them
+	TargetQuotas        string // Comma-separated path=size byte budgets of target paths
+	RetryAttempts       int    // Attempts of syncs failing for transient reasons unless the request sets them, 1 disables retries
+	RetryBackoff        time.Duration
+	RetryMaxBackoff     time.Duration
+	MaxRuntime          time.Duration
+	StagingDir          string // Directory of staging directories and temporary clones, next to the targets when empty
+	CredentialsDir      string // Directory key material is written to, e.g. a tmpfs, the system temp directory when empty
+	DownloadCacheDir    string // Shared cache of HTTP and S3 downloads, disabled when empty
+	DownloadCacheSize   string // Size above which the least recently used cached downloads are removed, e.g. 10Gi
+	DownloadCacheLinks  bool   // Populate targets with hard links to cached downloads instead of copies
+	ExtractMaxSize      string // Bytes extracted from each archive at most, e.g. 10Gi, unlimited when empty
+	ExtractMaxEntries   int    // Entries extracted from each archive at most, 0 for no limit
+	HashAlgorithm       string // Algorithm of the file digests recorded in manifests, which then compare contents, metadata only when empty
+	Proxy               string // Proxy of the connections to the sources unless the request sets one, HTTP_PROXY and HTTPS_PROXY apply when empty
+	NoProxy             string // Comma-separated hosts, domains and CIDR ranges reached without the proxy
+	DNSServer           string // DNS server resolving the hosts of the sources, the servers of the node when empty
+	IPFamily            string // Address family preference of the connections to the sources: ipv4, ipv6, prefer-ipv4 or prefer-ipv6
+	StateDir            string // Directory the job records are persisted in, disabled when empty
+	ProfilesDir         string // Directory the sync profiles are persisted in, kept in memory when empty
+	CredentialStoreDir  string // Directory the encrypted credentials are persisted in, kept in memory when empty
+	CredentialKeyFile   string // File of the local master key sealing the stored credentials
+	CredentialKMSKey    string // AWS KMS key sealing the stored credentials, instead of a local master key
+	ResumeInterrupted   bool   // Resume jobs interrupted by a restart instead of failing them
+	PluginsDir          string // Directory of exec plugins providing additional source types, disabled when empty
+	KubernetesEvents    bool   // Record Kubernetes events of the jobs when running in a cluster
+	Controller          bool   // Reconcile the SyncSource resources of the namespace into sync jobs
+	ControllerNamespace string // Namespace watched by the controller, the namespace of the pod when empty
+	CoalesceRequests    bool   // Attach requests to an identical queued or running job instead of refusing or queueing them
+	PurgeRoots          string // Comma-separated directories below which targets may be purged, disabled when empty
+	TargetRoots         string // Comma-separated directories target volumes are mounted at, checked by the readiness endpoint
+	Preflight           bool   // Check the connection to the sources before accepting sync requests
+	BandwidthLimit      string // Bytes per second all downloads share, e.g. 50Mi, unlimited when empty
+	MaxListItems        int    // Items accepted in each list of a sync request, e.g. sources or filters, 0 for no limit
+	AuditLog            string // Comma-separated sinks of the audit log: a file path, syslog or an HTTP URL
+	AuditRecent         int    // Recent audit entries kept in memory for the audit endpoint
+	AuditHTTPToken      string // Bearer token sent to an HTTP audit sink
+	QueueWhenBusy       bool   // Queue sync requests behind a sync in progress instead of refusing them
+	EventBus            string // NATS or Kafka URLs the lifecycle events of jobs are published to, disabled when empty
+	EventTopic          string // NATS subject or Kafka topic of the lifecycle events
+	EventInterval       time.Duration
+	JobRetentionAge     time.Duration
+	JobRetentionCount   int    // Finished jobs kept in the history and the job state directory, 0 for no limit
+	JobRetentionSize    string // Total size of the finished job records kept, e.g. 50Mi, unlimited when empty
+	LogRetentionAge     time.Duration
+	LogRetentionCount   int    // Finished jobs keeping their hook outputs and rsync file lists, 0 for no limit
+	LogRetentionSize    string // Total size of the hook outputs and rsync file lists kept, e.g. 10Mi, unlimited when empty
 }
 
 func Load() *Config {
@@ -29,9 +115,92 @@ func Load() *Config {
 			ReadTimeout:  getDurationEnv("READ_TIMEOUT", 30*time.Second),
 			WriteTimeout: getDurationEnv("WRITE_TIMEOUT", 30*time.Second),
 			IdleTimeout:  getDurationEnv("IDLE_TIMEOUT", 120*time.Second),
+			AdminToken:   getEnv("SYNC_ADMIN_TOKEN", ""),
+			SwaggerUI:    getBoolEnv("SWAGGER_UI", false),
+			Debug:        getBoolEnv("SYNC_DEBUG_ENDPOINTS", false),
+			MaxBodySize:  getEnv("SYNC_MAX_REQUEST_BODY", "1Mi"),
 		},
 		Sync: SyncConfig{
-			DefaultTimeout: getDurationEnv("SYNC_TIMEOUT", 5*time.Minute),
+			DefaultTimeout:      getDurationEnv("SYNC_TIMEOUT", 5*time.Minute),
+			DefinitionsFile:     getEnv("SYNC_DEFINITIONS_FILE", ""),
+			StartupDefinitions:  getEnv("SYNC_STARTUP_DEFINITIONS", ""),
+			TriggerFile:         getEnv("SYNC_TRIGGER_FILE", ""),
+			TriggerDefinition:   getEnv("SYNC_TRIGGER_DEFINITION", ""),
+			GitImplementation:   getEnv("GIT_IMPLEMENTATION", "exec"),
+			GitCacheDir:         getEnv("GIT_CACHE_DIR", ""),
+			GitCacheDissociate:  getBoolEnv("GIT_CACHE_DISSOCIATE", true),
+			StrictHostKeys:      getBoolEnv("SSH_STRICT_HOST_KEYS", false),
+			SSHKeyFiles:         getBoolEnv("SSH_KEY_FILES", false),
+			SSHControlMaster:    getBoolEnv("SSH_CONTROL_MASTER", false),
+			HooksFile:           getEnv("SYNC_HOOKS_FILE", ""),
+			NotificationsFile:   getEnv("SYNC_NOTIFICATIONS_FILE", ""),
+			HookTimeout:         getDurationEnv("SYNC_HOOK_TIMEOUT", time.Minute),
+			SecretsDir:          getEnv("SYNC_SECRETS_DIR", ""),
+			SecretEnvNames:      getEnv("SYNC_SECRET_ENV_NAMES", ""),
+			SecretFileRoots:     getEnv("SYNC_SECRET_FILE_ROOTS", ""),
+			SecretManagerNames:  getEnv("SYNC_SECRET_MANAGER_NAMES", ""),
+			DefaultUID:          getIntEnv("SYNC_DEFAULT_UID", -1),
+			DefaultGID:          getIntEnv("SYNC_DEFAULT_GID", -1),
+			DefaultChmod:        getEnv("SYNC_DEFAULT_CHMOD", ""),
+			TargetQuotas:        getEnv("SYNC_TARGET_QUOTAS", ""),
+			RetryAttempts:       getIntEnv("SYNC_RETRY_ATTEMPTS", 1),
+			RetryBackoff:        getDurationEnv("SYNC_RETRY_BACKOFF", 10*time.Second),
+			RetryMaxBackoff:     getDurationEnv("SYNC_RETRY_MAX_BACKOFF", 5*time.Minute),
+			DownloadCacheDir:    getEnv("SYNC_DOWNLOAD_CACHE_DIR", ""),
+			DownloadCacheSize:   getEnv("SYNC_DOWNLOAD_CACHE_MAX_SIZE", ""),
+			ExtractMaxSize:      getEnv("SYNC_EXTRACT_MAX_SIZE", ""),
+			ExtractMaxEntries:   getIntEnv("SYNC_EXTRACT_MAX_ENTRIES", 0),
+			HashAlgorithm:       getEnv("SYNC_HASH_ALGORITHM", ""),
+			Proxy:               getEnv("SYNC_PROXY", ""),
+			NoProxy:             getEnv("SYNC_NO_PROXY", ""),
+			DNSServer:           getEnv("SYNC_DNS_SERVER", ""),
+			IPFamily:            getEnv("SYNC_IP_FAMILY", ""),
+			DownloadCacheLinks:  getBoolEnv("SYNC_DOWNLOAD_CACHE_HARDLINKS", false),
+			StateDir:            getEnv("SYNC_STATE_DIR", ""),
+			ProfilesDir:         getEnv("SYNC_PROFILES_DIR", ""),
+			CredentialStoreDir:  getEnv("SYNC_CREDENTIAL_STORE_DIR", ""),
+			CredentialKeyFile:   getEnv("SYNC_CREDENTIAL_MASTER_KEY_FILE", ""),
+			CredentialKMSKey:    getEnv("SYNC_CREDENTIAL_KMS_KEY_ID", ""),
+			ResumeInterrupted:   getBoolEnv("SYNC_RESUME_INTERRUPTED", true),
+			PluginsDir:          getEnv("SYNC_PLUGINS_DIR", ""),
+			KubernetesEvents:    getBoolEnv("SYNC_KUBERNETES_EVENTS", true),
+			Controller:          getBoolEnv("SYNC_CONTROLLER", false),
+			ControllerNamespace: getEnv("SYNC_CONTROLLER_NAMESPACE", ""),
+			CoalesceRequests:    getBoolEnv("SYNC_COALESCE_REQUESTS", false),
+			PurgeRoots:          getEnv("SYNC_PURGE_ROOTS", ""),
+			TargetRoots:         getEnv("SYNC_TARGET_ROOTS", ""),
+			Preflight:           getBoolEnv("SYNC_PREFLIGHT", false),
+			BandwidthLimit:      getEnv("SYNC_BWLIMIT", ""),
+			MaxListItems:        getIntEnv("SYNC_MAX_LIST_ITEMS", 100),
+			AuditLog:            getEnv("SYNC_AUDIT_LOG", ""),
+			AuditRecent:         getIntEnv("SYNC_AUDIT_RECENT", 1000),
+			AuditHTTPToken:      getEnv("SYNC_AUDIT_HTTP_TOKEN", ""),
+			QueueWhenBusy:       getBoolEnv("SYNC_QUEUE_WHEN_BUSY", true),
+			EventBus:            getEnv("SYNC_EVENT_BUS", ""),
+			EventTopic:          getEnv("SYNC_EVENT_TOPIC", "volume-syncer.events"),
+			EventInterval:       getDurationEnv("SYNC_EVENT_PROGRESS_INTERVAL", 30*time.Second),
+			MaxRuntime:          getDurationEnv("SYNC_MAX_RUNTIME", 0),
+			StagingDir:          getEnv("SYNC_STAGING_DIR", ""),
+			CredentialsDir:      getEnv("SYNC_CREDENTIALS_DIR", ""),
+			JobRetentionAge:     getDurationEnv("SYNC_JOB_RETENTION_MAX_AGE", 0),
+			JobRetentionCount:   getIntEnv("SYNC_JOB_RETENTION_MAX_COUNT", 100),
+			JobRetentionSize:    getEnv("SYNC_JOB_RETENTION_MAX_SIZE", ""),
+			LogRetentionAge:     getDurationEnv("SYNC_JOB_LOG_RETENTION_MAX_AGE", 0),
+			LogRetentionCount:   getIntEnv("SYNC_JOB_LOG_RETENTION_MAX_COUNT", 0),
+			LogRetentionSize:    getEnv("SYNC_JOB_LOG_RETENTION_MAX_SIZE", ""),
+		},
+		HTTP: HTTPConfig{
+			CAFile:                getEnv("SYNC_HTTP_CA_FILE", ""),
+			ClientCertFile:        getEnv("SYNC_HTTP_CLIENT_CERT_FILE", ""),
+			ClientKeyFile:         getEnv("SYNC_HTTP_CLIENT_KEY_FILE", ""),
+			TLSMinVersion:         getEnv("SYNC_HTTP_TLS_MIN_VERSION", "1.2"),
+			DialTimeout:           getDurationEnv("SYNC_HTTP_DIAL_TIMEOUT", 30*time.Second),
+			TLSHandshakeTimeout:   getDurationEnv("SYNC_HTTP_TLS_HANDSHAKE_TIMEOUT", 10*time.Second),
+			ResponseHeaderTimeout: getDurationEnv("SYNC_HTTP_RESPONSE_HEADER_TIMEOUT", 0),
+			IdleConnTimeout:       getDurationEnv("SYNC_HTTP_IDLE_CONN_TIMEOUT", 90*time.Second),
+			MaxIdleConnsPerHost:   getIntEnv("SYNC_HTTP_MAX_IDLE_CONNS_PER_HOST", 4),
+			MaxConnsPerHost:       getIntEnv("SYNC_HTTP_MAX_CONNS_PER_HOST", 0),
+			KeepAlives:            getBoolEnv("SYNC_HTTP_KEEP_ALIVES", true),
 		},
 	}
 }
@@ -43,6 +212,32 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+func getBoolEnv(key string, defaultValue bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	if b, err := strconv.ParseBool(value); err == nil {
+		return b
+	}
+
+	return defaultValue
+}
+
+func getIntEnv(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	if i, err := strconv.Atoi(value); err == nil {
+		return i
+	}
+
+	return defaultValue
+}
+
 func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
 	value := os.Getenv(key)
 	if value == "" {
@@ -0,0 +1,337 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/sharedvolume/volume-syncer/internal/definitions"
+	"github.com/sharedvolume/volume-syncer/internal/kube"
+	"github.com/sharedvolume/volume-syncer/internal/models"
+	"github.com/sharedvolume/volume-syncer/internal/retry"
+	"github.com/sharedvolume/volume-syncer/internal/service"
+	syncerrors "github.com/sharedvolume/volume-syncer/pkg/errors"
+)
+
+// Timing of the controller loops
+const (
+	pollInterval  = 2 * time.Second // checks for due syncs and finished jobs
+	retryInterval = 5 * time.Second // restarts failed lists and watches
+)
+
+// Controller reconciles the SyncSource resources of a namespace into sync jobs and reports the
+// outcome of each job in the status of its resource
+type Controller struct {
+	client      *kube.Client
+	namespace   string
+	syncService *service.SyncService
+	cron        *cron.Cron
+
+	mutex   sync.Mutex
+	sources map[string]*trackedSource // by name
+}
+
+// trackedSource is the controller state of a SyncSource
+type trackedSource struct {
+	source      SyncSource
+	due         bool   // a sync is pending
+	schedule    string // schedule the entry was created for
+	scheduleErr error  // the schedule is invalid, which suspends the resource
+	entry       cron.EntryID
+}
+
+// New creates a controller for the SyncSource resources of the namespace
+func New(client *kube.Client, namespace string, syncService *service.SyncService) *Controller {
+	return &Controller{
+		client:      client,
+		namespace:   namespace,
+		syncService: syncService,
+		cron:        cron.New(),
+		sources:     make(map[string]*trackedSource),
+	}
+}
+
+// collectionPath returns the API path of the SyncSource resources of the namespace
+func (c *Controller) collectionPath() string {
+	return fmt.Sprintf("/apis/%s/%s/namespaces/%s/%s", Group, Version, url.PathEscape(c.namespace), Resource)
+}
+
+// Run watches the SyncSource resources and runs their syncs until the context is cancelled
+func (c *Controller) Run(ctx context.Context) {
+	log.Printf("[CONTROLLER] Watching %s.%s in namespace %s", Resource, Group, c.namespace)
+	c.cron.Start()
+	defer c.cron.Stop()
+	go c.runSyncs(ctx)
+
+	for ctx.Err() == nil {
+		resourceVersion, err := c.list(ctx)
+		if err == nil {
+			err = c.client.Watch(ctx, c.collectionPath(), resourceVersion, c.handleEvent)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Printf("[CONTROLLER] WARNING: Watch of %s failed, listing again: %v", Resource, err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(retryInterval):
+			}
+		}
+	}
+}
+
+// list reconciles all resources and returns the resource version to watch from
+func (c *Controller) list(ctx context.Context) (string, error) {
+	var list syncSourceList
+	if err := c.client.Get(ctx, c.collectionPath(), &list); err != nil {
+		return "", err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	listed := make(map[string]bool, len(list.Items))
+	for _, source := range list.Items {
+		listed[source.Metadata.Name] = true
+		c.reconcile(source)
+	}
+	for name := range c.sources {
+		if !listed[name] {
+			c.forget(name)
+		}
+	}
+	return list.Metadata.ResourceVersion, nil
+}
+
+// handleEvent applies a change of a watched resource
+func (c *Controller) handleEvent(event kube.WatchEvent) error {
+	if event.Type == "BOOKMARK" {
+		return nil
+	}
+	var source SyncSource
+	if err := json.Unmarshal(event.Object, &source); err != nil {
+		log.Printf("[CONTROLLER] WARNING: Skipping invalid %s event: %v", event.Type, err)
+		return nil
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if event.Type == "DELETED" {
+		c.forget(source.Metadata.Name)
+		return nil
+	}
+	c.reconcile(source)
+	return nil
+}
+
+// reconcile records the resource, marks it due when its spec changed since the last sync and
+// schedules it. The mutex must be held.
+func (c *Controller) reconcile(source SyncSource) {
+	name := source.Metadata.Name
+	tracked, known := c.sources[name]
+	if !known {
+		tracked = &trackedSource{}
+		c.sources[name] = tracked
+	}
+	tracked.source = source
+	if source.Spec.Schedule != tracked.schedule || !known {
+		c.reschedule(name, tracked)
+	}
+
+	if source.Spec.Suspend || tracked.scheduleErr != nil {
+		tracked.due = false
+	} else if source.Metadata.Generation != source.Status.ObservedGeneration || (!known && source.Status.Phase == PhaseSyncing) {
+		// Syncs interrupted by a restart of the controller run again
+		if !tracked.due {
+			log.Printf("[CONTROLLER] SyncSource %s (generation %d) is due", name, source.Metadata.Generation)
+		}
+		tracked.due = true
+	}
+}
+
+// reschedule replaces the schedule entry of the resource. An invalid schedule is reported in the
+// status once. The mutex must be held.
+func (c *Controller) reschedule(name string, tracked *trackedSource) {
+	if tracked.entry != 0 {
+		c.cron.Remove(tracked.entry)
+		tracked.entry = 0
+	}
+	tracked.schedule = tracked.source.Spec.Schedule
+	tracked.scheduleErr = nil
+	if tracked.schedule == "" {
+		return
+	}
+	schedule, err := definitions.ParseSchedule(tracked.schedule, "")
+	if err != nil {
+		tracked.scheduleErr = err
+		log.Printf("[CONTROLLER] ERROR: Invalid schedule of SyncSource %s: %v", name, err)
+		status := tracked.source.Status
+		status.Phase, status.Error = PhaseFailed, fmt.Sprintf("invalid schedule: %v", err)
+		status.ErrorCode, status.Retryable = syncerrors.CodeValidation, false
+		c.updateStatus(tracked.source, status)
+		return
+	}
+	tracked.entry = c.cron.Schedule(schedule, cron.FuncJob(func() { c.markDue(name) }))
+	log.Printf("[CONTROLLER] Scheduled SyncSource %s: %s", name, tracked.schedule)
+}
+
+// forget stops tracking a deleted resource. The mutex must be held.
+func (c *Controller) forget(name string) {
+	tracked, ok := c.sources[name]
+	if !ok {
+		return
+	}
+	if tracked.entry != 0 {
+		c.cron.Remove(tracked.entry)
+	}
+	delete(c.sources, name)
+	log.Printf("[CONTROLLER] SyncSource %s removed", name)
+}
+
+// markDue queues a scheduled sync of the resource
+func (c *Controller) markDue(name string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if tracked, ok := c.sources[name]; ok && !tracked.source.Spec.Suspend {
+		tracked.due = true
+	}
+}
+
+// runSyncs starts the due syncs one at a time, as the sync service runs one job at a time. Due
+// syncs wait while the job queue is paused.
+func (c *Controller) runSyncs(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if c.syncService.IsSyncInProgress() || c.syncService.IsPaused() {
+			continue
+		}
+		if source, ok := c.nextDue(); ok {
+			c.sync(ctx, source)
+		}
+	}
+}
+
+// nextDue takes the due resource that comes first by name
+func (c *Controller) nextDue() (SyncSource, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	var names []string
+	for name, tracked := range c.sources {
+		if tracked.due {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return SyncSource{}, false
+	}
+	sort.Strings(names)
+	tracked := c.sources[names[0]]
+	tracked.due = false
+	return tracked.source, true
+}
+
+// sync runs the job of the resource and reports its outcome in the status
+func (c *Controller) sync(ctx context.Context, source SyncSource) {
+	name := source.Metadata.Name
+	log.Printf("[CONTROLLER] Syncing SyncSource %s", name)
+	status := source.Status
+	status.ObservedGeneration = source.Metadata.Generation
+
+	req := source.Spec.SyncRequest
+	req.Owner = &models.ObjectReference{
+		APIVersion: Group + "/" + Version,
+		Kind:       "SyncSource",
+		Namespace:  c.namespace,
+		Name:       name,
+		UID:        source.Metadata.UID,
+	}
+	req.Initiator = "SyncSource " + c.namespace + "/" + name
+	job, err := c.syncService.StartSync(&req)
+	if err != nil {
+		log.Printf("[CONTROLLER] ERROR: Failed to start sync of %s: %v", name, err)
+		now := time.Now().UTC()
+		status.Phase, status.Error, status.JobID, status.LastSyncTime = PhaseFailed, err.Error(), "", &now
+		status.ErrorCode, status.Retryable = retry.Classify(err)
+		if errors.Is(err, service.ErrSyncInProgress) {
+			status.ErrorCode, status.Retryable = syncerrors.CodeBusy, true
+		}
+		c.updateStatus(source, status)
+		return
+	}
+	status.Phase, status.JobID = PhaseSyncing, job.ID
+	c.updateStatus(source, status)
+
+	finished := c.waitJob(ctx, job.ID)
+	if finished == nil {
+		return
+	}
+	status.LastSyncTime = finished.FinishedAt
+	if finished.Status == models.JobStatusSucceeded {
+		status.Phase, status.Error, status.ErrorCode, status.Retryable = PhaseSucceeded, "", "", false
+		if finished.Result != nil && finished.Result.Revision != "" {
+			status.Revision = finished.Result.Revision
+		}
+		log.Printf("[CONTROLLER] SyncSource %s synced by job %s", name, job.ID)
+	} else {
+		status.Phase, status.Error = PhaseFailed, finished.Error
+		status.ErrorCode, status.Retryable = finished.ErrorCode, finished.Retryable
+		log.Printf("[CONTROLLER] ERROR: Sync of SyncSource %s failed: %s", name, finished.Error)
+	}
+	c.updateStatus(source, status)
+}
+
+// waitJob polls the job until it finished, or returns nil when the context is cancelled first
+func (c *Controller) waitJob(ctx context.Context, id string) *models.SyncJob {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		if job, ok := c.syncService.GetJob(id); ok && job.Finished() {
+			return job
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// updateStatus replaces the status of the resource
+func (c *Controller) updateStatus(source SyncSource, status SyncSourceStatus) {
+	path := c.collectionPath() + "/" + url.PathEscape(source.Metadata.Name) + "/status"
+	patch := map[string]any{"status": statusPatch(status)}
+	if err := c.client.MergePatch(context.Background(), path, patch); err != nil {
+		if kube.IsNotFound(err) {
+			return
+		}
+		log.Printf("[CONTROLLER] WARNING: Failed to update status of %s: %v", source.Metadata.Name, err)
+	}
+}
+
+// statusPatch returns the merge patch fields of the status. Empty fields are set to null, so that
+// the patch removes what the previous sync reported.
+func statusPatch(status SyncSourceStatus) map[string]any {
+	data, _ := json.Marshal(status)
+	fields := map[string]any{}
+	json.Unmarshal(data, &fields)
+	for _, field := range []string{"error", "errorCode", "retryable", "jobId", "revision"} {
+		if _, ok := fields[field]; !ok {
+			fields[field] = nil
+		}
+	}
+	return fields
+}
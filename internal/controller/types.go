@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/sharedvolume/volume-syncer/internal/models"
+)
+
+// API group, version and resource of the SyncSource custom resource
+const (
+	Group    = "sharedvolume.io"
+	Version  = "v1alpha1"
+	Resource = "syncsources"
+)
+
+// SyncSource phases
+const (
+	PhasePending   = "Pending"
+	PhaseSyncing   = "Syncing"
+	PhaseSucceeded = "Succeeded"
+	PhaseFailed    = "Failed"
+)
+
+// ObjectMeta holds the metadata of a resource used by the controller
+type ObjectMeta struct {
+	Name            string `json:"name"`
+	Namespace       string `json:"namespace,omitempty"`
+	UID             string `json:"uid,omitempty"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+	Generation      int64  `json:"generation,omitempty"`
+}
+
+// SyncSource declares a sync of a source into a target path of the volume
+type SyncSource struct {
+	APIVersion string           `json:"apiVersion,omitempty"`
+	Kind       string           `json:"kind,omitempty"`
+	Metadata   ObjectMeta       `json:"metadata"`
+	Spec       SyncSourceSpec   `json:"spec"`
+	Status     SyncSourceStatus `json:"status,omitempty"`
+}
+
+// SyncSourceSpec takes the fields of a sync request, plus when to sync
+type SyncSourceSpec struct {
+	models.SyncRequest
+
+	// Optional: cron expression or descriptor the source is synced on, in addition to every
+	// change of the spec
+	Schedule string `json:"schedule,omitempty"`
+
+	// Optional: suspends syncing until unset
+	Suspend bool `json:"suspend,omitempty"`
+}
+
+// SyncSourceStatus reports the last sync of a SyncSource
+type SyncSourceStatus struct {
+	Phase              string     `json:"phase,omitempty"`
+	ObservedGeneration int64      `json:"observedGeneration,omitempty"` // Generation of the spec last synced
+	JobID              string     `json:"jobId,omitempty"`
+	LastSyncTime       *time.Time `json:"lastSyncTime,omitempty"` // When the last sync finished
+	Revision           string     `json:"revision,omitempty"`     // Commit checked out by the last Git sync
+	Error              string     `json:"error,omitempty"`        // Error of the last sync, empty when it succeeded
+	ErrorCode          string     `json:"errorCode,omitempty"`    // Stable code of the error, e.g. AUTH_FAILED
+	Retryable          bool       `json:"retryable,omitempty"`    // Syncing again is likely to succeed
+}
+
+// syncSourceList is a page of a SyncSource list response
+type syncSourceList struct {
+	Metadata struct {
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	Items []SyncSource `json:"items"`
+}
@@ -0,0 +1,402 @@
+package credentials
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sharedvolume/volume-syncer/internal/models"
+)
+
+// credentialSuffix is the suffix of the credential files
+const credentialSuffix = ".json"
+
+// dataKeySize is the size of the AES-256 key encrypting the data of each credential
+const dataKeySize = 32
+
+var (
+	// idRegex matches the IDs of credentials, which name the credential files
+	idRegex = regexp.MustCompile(`^[a-z0-9]([a-z0-9._-]{0,61}[a-z0-9])?$`)
+	// fieldRegex matches the names of the data fields, which are keys of the source details
+	fieldRegex = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{0,63}$`)
+)
+
+var (
+	// ErrNotFound is returned for operations on a credential that does not exist
+	ErrNotFound = errors.New("credential not found")
+	// ErrExists is returned when creating a credential whose ID is taken
+	ErrExists = errors.New("credential already exists")
+	// ErrNotConfigured is returned by the store when no sealing key is configured
+	ErrNotConfigured = errors.New("the credential store requires SYNC_CREDENTIAL_MASTER_KEY_FILE or SYNC_CREDENTIAL_KMS_KEY_ID")
+)
+
+// record is a credential as it is kept in memory and persisted, with its data encrypted
+type record struct {
+	ID           string    `json:"id"`
+	Description  string    `json:"description,omitempty"`
+	Fields       []string  `json:"fields"`
+	AllowedHosts []string  `json:"allowedHosts,omitempty"` // destinations the credential may be sent to, unusable when empty
+	UpdatedAt    time.Time `json:"updatedAt"`
+	Sealer       string    `json:"sealer"`    // sealer of the data key
+	SealedKey    []byte    `json:"sealedKey"` // data key, sealed by the sealer
+	Data         []byte    `json:"data"`      // data fields as JSON, encrypted with the data key
+}
+
+// Store holds the credentials encrypted with a data key per credential, which the sealer
+// protects. Credentials are persisted in a directory with one file per credential when the
+// directory is set. A nil store has no credentials and refuses to store any.
+type Store struct {
+	dir     string
+	sealer  Sealer
+	mutex   sync.RWMutex
+	records map[string]record
+}
+
+// New opens the credentials directory and loads its credentials, which stay encrypted until a
+// sync uses them. Without a directory, credentials are kept in memory until the server stops.
+func New(dir string, sealer Sealer) (*Store, error) {
+	s := &Store{dir: dir, sealer: sealer, records: map[string]record{}}
+	if dir == "" {
+		return s, nil
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create credentials directory: %w", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credentials directory: %w", err)
+	}
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), credentialSuffix)
+		if !ok || !entry.Type().IsRegular() || !idRegex.MatchString(id) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			log.Printf("[CREDENTIALS] WARNING: Failed to read credential %s: %v", id, err)
+			continue
+		}
+		var rec record
+		if err := json.Unmarshal(data, &rec); err != nil || rec.ID != id {
+			log.Printf("[CREDENTIALS] WARNING: Skipping invalid credential %s", id)
+			continue
+		}
+		if rec.Sealer != sealer.Name() {
+			log.Printf("[CREDENTIALS] WARNING: Credential %s is sealed with the %s key, not the configured %s key", id, rec.Sealer, sealer.Name())
+		}
+		s.records[id] = rec
+	}
+	log.Printf("[CREDENTIALS] Loaded %d credentials from %s", len(s.records), dir)
+	return s, nil
+}
+
+// Open creates the sealer of the configured key and opens the store. Without a key the store is
+// disabled and nil is returned, unless a directory is set.
+func Open(ctx context.Context, dir, masterKeyFile, kmsKeyID string) (*Store, error) {
+	var sealer Sealer
+	switch {
+	case masterKeyFile != "" && kmsKeyID != "":
+		return nil, errors.New("SYNC_CREDENTIAL_MASTER_KEY_FILE and SYNC_CREDENTIAL_KMS_KEY_ID cannot be set at the same time")
+	case masterKeyFile != "":
+		masterKey, err := LoadMasterKey(masterKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		if sealer, err = NewLocalSealer(masterKey); err != nil {
+			return nil, err
+		}
+	case kmsKeyID != "":
+		var err error
+		if sealer, err = NewKMSSealer(ctx, kmsKeyID); err != nil {
+			return nil, err
+		}
+	case dir != "":
+		return nil, ErrNotConfigured
+	default:
+		return nil, nil
+	}
+	return New(dir, sealer)
+}
+
+// ValidateID checks that the ID can identify a credential
+func ValidateID(id string) error {
+	if !idRegex.MatchString(id) {
+		return fmt.Errorf("invalid credential ID %q: use up to 63 lowercase letters, digits, '.', '_' and '-', starting and ending with a letter or digit", id)
+	}
+	return nil
+}
+
+// ValidateAllowedHosts checks the patterns of the destinations a credential may be sent to: hosts
+// such as github.com or *.internal.example, optionally followed by a path such as /acme or
+// /acme/app.git, whose segments may hold the wildcards of path.Match
+func ValidateAllowedHosts(patterns []string) error {
+	if len(patterns) == 0 {
+		return errors.New("allowedHosts is required: list the hosts the sources referencing the credential may connect to")
+	}
+	for _, pattern := range patterns {
+		host, pathPattern, _ := strings.Cut(pattern, "/")
+		if host == "" || strings.Contains(pattern, "://") || strings.ContainsAny(pattern, " \t\r\n:@") {
+			return fmt.Errorf("invalid allowed host %q: use a host with an optional path, such as github.com/acme", pattern)
+		}
+		for _, segment := range append([]string{host}, strings.Split(pathPattern, "/")...) {
+			if _, err := path.Match(segment, ""); err != nil {
+				return fmt.Errorf("invalid allowed host %q: %w", pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Allows reports whether one of the patterns allows sending a credential to the destination, a
+// host with an optional path such as github.com/acme/app.git. Hosts compare case-insensitively;
+// a pattern path matches the destination paths below it, with or without a .git suffix.
+func Allows(patterns []string, destination string) bool {
+	host, destinationPath, _ := strings.Cut(destination, "/")
+	segments := pathSegments(destinationPath)
+	for _, pattern := range patterns {
+		hostPattern, pathPattern, _ := strings.Cut(pattern, "/")
+		if ok, _ := path.Match(strings.ToLower(hostPattern), strings.ToLower(host)); !ok {
+			continue
+		}
+		patternSegments := pathSegments(pathPattern)
+		if len(patternSegments) > len(segments) {
+			continue
+		}
+		matched := true
+		for i, segment := range patternSegments {
+			if ok, _ := path.Match(segment, segments[i]); !ok {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// pathSegments splits a path into its segments, without empty segments and a .git suffix
+func pathSegments(p string) []string {
+	var segments []string
+	for _, segment := range strings.Split(p, "/") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	if n := len(segments); n > 0 {
+		segments[n-1] = strings.TrimSuffix(segments[n-1], ".git")
+	}
+	return segments
+}
+
+// ValidateData checks that the data holds fields that can be added to source details
+func ValidateData(data map[string]string) error {
+	if len(data) == 0 {
+		return errors.New("credential data is required")
+	}
+	for field := range data {
+		if !fieldRegex.MatchString(field) {
+			return fmt.Errorf("invalid credential field %q: use a details field name such as privateKey", field)
+		}
+	}
+	return nil
+}
+
+// Get returns the credential with the given ID, without its data
+func (s *Store) Get(id string) (models.Credential, bool) {
+	if s == nil {
+		return models.Credential{}, false
+	}
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	rec, ok := s.records[id]
+	return rec.credential(), ok
+}
+
+// List returns all credentials ordered by ID, without their data
+func (s *Store) List() []models.Credential {
+	credentials := []models.Credential{}
+	if s == nil {
+		return credentials
+	}
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for _, rec := range s.records {
+		credentials = append(credentials, rec.credential())
+	}
+	sort.Slice(credentials, func(i, j int) bool { return credentials[i].ID < credentials[j].ID })
+	return credentials
+}
+
+// Create stores a new credential, failing with ErrExists when the ID is taken
+func (s *Store) Create(ctx context.Context, credential models.Credential) (models.Credential, error) {
+	if s == nil {
+		return models.Credential{}, ErrNotConfigured
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.records[credential.ID]; ok {
+		return models.Credential{}, ErrExists
+	}
+	return s.save(ctx, credential)
+}
+
+// Put creates or replaces the credential and reports whether it was created. Replacing the data
+// rotates the credential for every source referencing it.
+func (s *Store) Put(ctx context.Context, credential models.Credential) (models.Credential, bool, error) {
+	if s == nil {
+		return models.Credential{}, false, ErrNotConfigured
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	_, exists := s.records[credential.ID]
+	saved, err := s.save(ctx, credential)
+	return saved, !exists, err
+}
+
+// Delete removes the credential, failing with ErrNotFound when it does not exist
+func (s *Store) Delete(id string) error {
+	if s == nil {
+		return ErrNotFound
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.records[id]; !ok {
+		return ErrNotFound
+	}
+	if s.dir != "" {
+		if err := os.Remove(s.path(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove credential %s: %w", id, err)
+		}
+	}
+	delete(s.records, id)
+	return nil
+}
+
+// Data decrypts the data fields of the credential for a sync
+func (s *Store) Data(ctx context.Context, id string) (map[string]string, error) {
+	if s == nil {
+		return nil, ErrNotConfigured
+	}
+	s.mutex.RLock()
+	rec, ok := s.records[id]
+	s.mutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	if rec.Sealer != s.sealer.Name() {
+		return nil, fmt.Errorf("credential %s is sealed with the %s key, the server is configured with the %s key", id, rec.Sealer, s.sealer.Name())
+	}
+
+	dataKey, err := s.sealer.Open(ctx, id, rec.SealedKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unseal credential %s: %w", id, err)
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := open(aead, rec.Data, []byte(id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt credential %s: %w", id, err)
+	}
+	var data map[string]string
+	if err := json.Unmarshal(plaintext, &data); err != nil {
+		return nil, fmt.Errorf("failed to decode credential %s: %w", id, err)
+	}
+	return data, nil
+}
+
+func (s *Store) path(id string) string {
+	return filepath.Join(s.dir, id+credentialSuffix)
+}
+
+// save encrypts the credential with a new data key, writes it and keeps it in memory. The caller
+// holds the mutex.
+func (s *Store) save(ctx context.Context, credential models.Credential) (models.Credential, error) {
+	if err := ValidateID(credential.ID); err != nil {
+		return models.Credential{}, err
+	}
+	if err := ValidateData(credential.Data); err != nil {
+		return models.Credential{}, err
+	}
+	if err := ValidateAllowedHosts(credential.AllowedHosts); err != nil {
+		return models.Credential{}, err
+	}
+	rec := record{
+		ID:           credential.ID,
+		Description:  credential.Description,
+		AllowedHosts: credential.AllowedHosts,
+		UpdatedAt:    time.Now().UTC(),
+		Sealer:       s.sealer.Name(),
+	}
+	for field := range credential.Data {
+		rec.Fields = append(rec.Fields, field)
+	}
+	sort.Strings(rec.Fields)
+
+	plaintext, err := json.Marshal(credential.Data)
+	if err != nil {
+		return models.Credential{}, fmt.Errorf("failed to encode credential %s: %w", credential.ID, err)
+	}
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return models.Credential{}, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return models.Credential{}, err
+	}
+	if rec.Data, err = seal(aead, plaintext, []byte(credential.ID)); err != nil {
+		return models.Credential{}, err
+	}
+	if rec.SealedKey, err = s.sealer.Seal(ctx, credential.ID, dataKey); err != nil {
+		return models.Credential{}, fmt.Errorf("failed to seal credential %s: %w", credential.ID, err)
+	}
+
+	if s.dir != "" {
+		data, err := json.Marshal(rec)
+		if err != nil {
+			return models.Credential{}, fmt.Errorf("failed to encode credential %s: %w", credential.ID, err)
+		}
+		path := s.path(credential.ID)
+		tmp := path + ".tmp"
+		if err := os.WriteFile(tmp, data, 0600); err != nil {
+			return models.Credential{}, fmt.Errorf("failed to write credential %s: %w", credential.ID, err)
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			os.Remove(tmp)
+			return models.Credential{}, fmt.Errorf("failed to write credential %s: %w", credential.ID, err)
+		}
+	}
+	s.records[credential.ID] = rec
+	return rec.credential(), nil
+}
+
+// credential returns the credential of the record without its data
+func (r record) credential() models.Credential {
+	return models.Credential{
+		ID:           r.ID,
+		Description:  r.Description,
+		Fields:       r.Fields,
+		AllowedHosts: r.AllowedHosts,
+		UpdatedAt:    r.UpdatedAt,
+	}
+}
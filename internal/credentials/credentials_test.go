@@ -0,0 +1,219 @@
+package credentials
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sharedvolume/volume-syncer/internal/models"
+)
+
+// tamper rewrites the stored record of the credential
+func tamper(t *testing.T, dir, id string, change func(rec *record)) {
+	t.Helper()
+	path := filepath.Join(dir, id+credentialSuffix)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rec record
+	if err := json.Unmarshal(data, &rec); err != nil {
+		t.Fatal(err)
+	}
+	change(&rec)
+	if data, err = json.Marshal(rec); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStoreData(t *testing.T) {
+	ctx := context.Background()
+	data := map[string]string{"user": "ci", "token": "ghp_secret"}
+
+	tests := []struct {
+		name    string
+		key     []byte                         // master key the store is reopened with
+		change  func(t *testing.T, dir string) // applied to the stored credentials before reopening
+		errText string                         // contained in the error, "" when the data is recovered
+	}{
+		{name: "round trip", key: testKey(1)},
+		{name: "wrong key", key: testKey(2), errText: "failed to unseal credential github"},
+		{
+			name: "modified data",
+			key:  testKey(1),
+			change: func(t *testing.T, dir string) {
+				tamper(t, dir, "github", func(rec *record) { rec.Data[len(rec.Data)-1] ^= 1 })
+			},
+			errText: "failed to decrypt credential github",
+		},
+		{
+			name: "modified sealed key",
+			key:  testKey(1),
+			change: func(t *testing.T, dir string) {
+				tamper(t, dir, "github", func(rec *record) { rec.SealedKey[len(rec.SealedKey)-1] ^= 1 })
+			},
+			errText: "failed to unseal credential github",
+		},
+		{
+			name: "data of another credential",
+			key:  testKey(1),
+			change: func(t *testing.T, dir string) {
+				other, err := os.ReadFile(filepath.Join(dir, "gitlab"+credentialSuffix))
+				if err != nil {
+					t.Fatal(err)
+				}
+				var otherRec record
+				if err := json.Unmarshal(other, &otherRec); err != nil {
+					t.Fatal(err)
+				}
+				tamper(t, dir, "github", func(rec *record) { rec.SealedKey, rec.Data = otherRec.SealedKey, otherRec.Data })
+			},
+			errText: "failed to unseal credential github",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			sealer, err := NewLocalSealer(testKey(1))
+			if err != nil {
+				t.Fatal(err)
+			}
+			store, err := New(dir, sealer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, id := range []string{"github", "gitlab"} {
+				credential := models.Credential{ID: id, Data: data, AllowedHosts: []string{id + ".com"}}
+				if _, err := store.Create(ctx, credential); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.change != nil {
+				tt.change(t, dir)
+			}
+
+			reopenSealer, err := NewLocalSealer(tt.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			reopened, err := New(dir, reopenSealer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := reopened.Data(ctx, "github")
+			if tt.errText == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if !maps.Equal(got, data) {
+					t.Errorf("Data() = %v, want %v", got, data)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errText) {
+				t.Fatalf("error = %v, want one containing %q", err, tt.errText)
+			}
+		})
+	}
+}
+
+func TestStoreKeepsDataEncrypted(t *testing.T) {
+	dir := t.TempDir()
+	sealer, err := NewLocalSealer(testKey(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := New(dir, sealer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credential := models.Credential{ID: "github", Data: map[string]string{"token": "ghp_secret"}, AllowedHosts: []string{"github.com"}}
+	stored, err := store.Create(context.Background(), credential)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Data != nil {
+		t.Errorf("Create() returned the data %v", stored.Data)
+	}
+
+	file, err := os.ReadFile(filepath.Join(dir, "github"+credentialSuffix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(file), "ghp_secret") {
+		t.Error("the stored credential holds the token in plain text")
+	}
+}
+
+func TestAllows(t *testing.T) {
+	tests := []struct {
+		patterns    []string
+		destination string
+		want        bool
+	}{
+		{[]string{"github.com"}, "github.com", true},
+		{[]string{"github.com"}, "github.com/acme/app.git", true},
+		{[]string{"GitHub.com"}, "github.com/acme", true},
+		{[]string{"github.com/acme"}, "github.com/acme/app.git", true},
+		{[]string{"github.com/acme/app.git"}, "github.com/acme/app", true},
+		{[]string{"github.com/acme/app"}, "github.com/acme/app.git", true},
+		{[]string{"github.com//acme/"}, "github.com/acme/app", true},
+		{[]string{"*.internal.example"}, "git.internal.example/repo", true},
+		{[]string{"github.com/acme-*"}, "github.com/acme-tools/app", true},
+		{[]string{"gitlab.com", "github.com/acme"}, "github.com/acme/app", true},
+
+		{[]string{"github.com"}, "gitlab.com/acme", false},
+		{[]string{"github.com"}, "github.com.evil.example/acme", false},
+		{[]string{"github.com"}, "evil.example/github.com", false},
+		{[]string{"*.internal.example"}, "internal.example.evil.example", false},
+		{[]string{"github.com/acme"}, "github.com/acme-evil/app", false},
+		{[]string{"github.com/acme"}, "github.com/other/acme", false},
+		{[]string{"github.com/acme/app"}, "github.com/acme", false},
+		{[]string{"github.com/acme/app"}, "github.com/acme/application", false},
+		{[]string{"github.com/*"}, "github.com", false},
+		{nil, "github.com", false},
+	}
+
+	for _, tt := range tests {
+		if got := Allows(tt.patterns, tt.destination); got != tt.want {
+			t.Errorf("Allows(%q, %q) = %v, want %v", tt.patterns, tt.destination, got, tt.want)
+		}
+	}
+}
+
+func TestValidateAllowedHosts(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		errText  string
+	}{
+		{patterns: []string{"github.com", "*.internal.example", "github.com/acme/*"}},
+
+		{patterns: nil, errText: "allowedHosts is required"},
+		{patterns: []string{"https://github.com"}, errText: `invalid allowed host "https://github.com"`},
+		{patterns: []string{"git@github.com"}, errText: "invalid allowed host"},
+		{patterns: []string{"github.com:22"}, errText: "invalid allowed host"},
+		{patterns: []string{"/acme"}, errText: "invalid allowed host"},
+		{patterns: []string{"github.com/[acme"}, errText: "invalid allowed host"},
+	}
+
+	for _, tt := range tests {
+		err := ValidateAllowedHosts(tt.patterns)
+		if tt.errText == "" {
+			if err != nil {
+				t.Errorf("ValidateAllowedHosts(%q) unexpected error: %v", tt.patterns, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.errText) {
+			t.Errorf("ValidateAllowedHosts(%q) error = %v, want one containing %q", tt.patterns, err, tt.errText)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package credentials
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+)
+
+// masterKeySize is the size of local master keys, an AES-256 key
+const masterKeySize = 32
+
+// kmsContextKey names the credential in the KMS encryption context, which binds each sealed data
+// key to its credential
+const kmsContextKey = "volume-syncer-credential"
+
+// Sealer encrypts the data keys of the credentials. Each credential is encrypted with its own data
+// key; rotating the sealing key therefore only requires the data keys to be sealed again.
+type Sealer interface {
+	// Name identifies the sealer in the stored credentials, "local" or "kms"
+	Name() string
+	// Seal encrypts the data key of the credential
+	Seal(ctx context.Context, id string, dataKey []byte) ([]byte, error)
+	// Open decrypts the data key sealed for the credential
+	Open(ctx context.Context, id string, sealed []byte) ([]byte, error)
+}
+
+// localSealer seals data keys with AES-GCM under a master key held by the server
+type localSealer struct {
+	aead cipher.AEAD
+}
+
+// LoadMasterKey reads a local master key file holding 32 bytes, raw or base64 encoded, e.g.
+// generated with "openssl rand -base64 32"
+func LoadMasterKey(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read master key: %w", err)
+	}
+	if len(data) == masterKeySize {
+		return data, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(data)))
+	if err != nil || len(key) != masterKeySize {
+		return nil, fmt.Errorf("master key %s must hold %d bytes, raw or base64 encoded", path, masterKeySize)
+	}
+	return key, nil
+}
+
+// NewLocalSealer creates a sealer of the master key
+func NewLocalSealer(masterKey []byte) (Sealer, error) {
+	aead, err := newAEAD(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	return &localSealer{aead: aead}, nil
+}
+
+func (s *localSealer) Name() string {
+	return "local"
+}
+
+func (s *localSealer) Seal(_ context.Context, id string, dataKey []byte) ([]byte, error) {
+	return seal(s.aead, dataKey, []byte(id))
+}
+
+func (s *localSealer) Open(_ context.Context, id string, sealed []byte) ([]byte, error) {
+	return open(s.aead, sealed, []byte(id))
+}
+
+// kmsSealer seals data keys with an AWS KMS key, so that the server never holds the key that
+// protects the store
+type kmsSealer struct {
+	client *kms.Client
+	keyID  string
+}
+
+// NewKMSSealer creates a sealer of the AWS KMS key, given by ID, ARN or alias. Credentials,
+// region and endpoint come from the usual AWS configuration of the environment.
+func NewKMSSealer(ctx context.Context, keyID string) (Sealer, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+	return &kmsSealer{client: kms.NewFromConfig(cfg), keyID: keyID}, nil
+}
+
+func (s *kmsSealer) Name() string {
+	return "kms"
+}
+
+func (s *kmsSealer) Seal(ctx context.Context, id string, dataKey []byte) ([]byte, error) {
+	output, err := s.client.Encrypt(ctx, &kms.EncryptInput{
+		KeyId:             aws.String(s.keyID),
+		Plaintext:         dataKey,
+		EncryptionContext: map[string]string{kmsContextKey: id},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("KMS encryption failed: %w", err)
+	}
+	return output.CiphertextBlob, nil
+}
+
+func (s *kmsSealer) Open(ctx context.Context, id string, sealed []byte) ([]byte, error) {
+	output, err := s.client.Decrypt(ctx, &kms.DecryptInput{
+		KeyId:             aws.String(s.keyID),
+		CiphertextBlob:    sealed,
+		EncryptionContext: map[string]string{kmsContextKey: id},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("KMS decryption failed: %w", err)
+	}
+	return output.Plaintext, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts the plaintext with a random nonce, which prefixes the result
+func seal(aead cipher.AEAD, plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(aead cipher.AEAD, sealed, additionalData []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("sealed data is truncated")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, additionalData)
+	if err != nil {
+		return nil, errors.New("failed to decrypt, the data was sealed with another key or modified")
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,128 @@
+package credentials
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testKey returns a master key whose bytes are all b
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, masterKeySize)
+}
+
+func TestLocalSealer(t *testing.T) {
+	ctx := context.Background()
+	sealer, err := NewLocalSealer(testKey(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := NewLocalSealer(testKey(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dataKey := testKey(7)
+	sealed, err := sealer.Seal(ctx, "github", dataKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	modified := bytes.Clone(sealed)
+	modified[len(modified)-1] ^= 1
+	modifiedNonce := bytes.Clone(sealed)
+	modifiedNonce[0] ^= 1
+
+	tests := []struct {
+		name    string
+		sealer  Sealer
+		id      string
+		sealed  []byte
+		errText string // contained in the error, "" when the data key is recovered
+	}{
+		{name: "round trip", sealer: sealer, id: "github", sealed: sealed},
+		{name: "wrong key", sealer: otherKey, id: "github", sealed: sealed, errText: "sealed with another key or modified"},
+		{name: "other credential", sealer: sealer, id: "gitlab", sealed: sealed, errText: "sealed with another key or modified"},
+		{name: "modified ciphertext", sealer: sealer, id: "github", sealed: modified, errText: "sealed with another key or modified"},
+		{name: "modified nonce", sealer: sealer, id: "github", sealed: modifiedNonce, errText: "sealed with another key or modified"},
+		{name: "truncated", sealer: sealer, id: "github", sealed: sealed[:4], errText: "sealed data is truncated"},
+		{name: "empty", sealer: sealer, id: "github", errText: "sealed data is truncated"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opened, err := tt.sealer.Open(ctx, tt.id, tt.sealed)
+			if tt.errText == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if !bytes.Equal(opened, dataKey) {
+					t.Errorf("Open() = %x, want %x", opened, dataKey)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errText) {
+				t.Fatalf("error = %v, want one containing %q", err, tt.errText)
+			}
+		})
+	}
+}
+
+func TestLocalSealerUsesRandomNonces(t *testing.T) {
+	sealer, err := NewLocalSealer(testKey(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := sealer.Seal(context.Background(), "github", testKey(7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := sealer.Seal(context.Background(), "github", testKey(7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(first, second) {
+		t.Error("sealing the same data key twice gave the same result")
+	}
+}
+
+func TestLoadMasterKey(t *testing.T) {
+	key := testKey(3)
+	tests := []struct {
+		name    string
+		data    []byte
+		errText string
+	}{
+		{name: "raw", data: key},
+		{name: "base64", data: []byte(base64.StdEncoding.EncodeToString(key))},
+		{name: "base64 with a newline", data: []byte(base64.StdEncoding.EncodeToString(key) + "\n")},
+		{name: "short raw key", data: key[:16], errText: "must hold 32 bytes"},
+		{name: "short base64 key", data: []byte(base64.StdEncoding.EncodeToString(key[:16])), errText: "must hold 32 bytes"},
+		{name: "not base64", data: []byte(strings.Repeat("!", 44)), errText: "must hold 32 bytes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "master.key")
+			if err := os.WriteFile(path, tt.data, 0600); err != nil {
+				t.Fatal(err)
+			}
+			got, err := LoadMasterKey(path)
+			if tt.errText == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if !bytes.Equal(got, key) {
+					t.Errorf("LoadMasterKey() = %x, want %x", got, key)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errText) {
+				t.Fatalf("error = %v, want one containing %q", err, tt.errText)
+			}
+		})
+	}
+}
@@ -0,0 +1,333 @@
+package decrypt
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"filippo.io/age"
+	agearmor "filippo.io/age/armor"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	pgparmor "github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/sharedvolume/volume-syncer/internal/extract"
+	"github.com/sharedvolume/volume-syncer/internal/filter"
+	"github.com/sharedvolume/volume-syncer/internal/models"
+	"github.com/sharedvolume/volume-syncer/internal/utils"
+)
+
+// defaultFiles lists the files decrypted when no patterns are configured
+var defaultFiles = map[string][]string{
+	models.DecryptMethodAge: {"*.age"},
+	models.DecryptMethodGPG: {"*.gpg", "*.pgp", "*.asc"},
+}
+
+// encryptedSuffixes are removed from the names of decrypted files
+var encryptedSuffixes = []string{".age", ".gpg", ".pgp", ".asc", ".enc"}
+
+// Armor headers of ASCII-armored age and OpenPGP messages
+var (
+	ageArmorHeader = []byte("-----BEGIN AGE ENCRYPTED FILE-----")
+	pgpArmorHeader = []byte("-----BEGIN PGP")
+)
+
+// Decryptor decrypts age or OpenPGP encrypted files according to the decrypt options
+type Decryptor struct {
+	options    *models.DecryptOptions
+	files      *filter.Matcher
+	identities []age.Identity
+	keyring    openpgp.EntityList
+	passphrase []byte
+}
+
+// New validates the decrypt options and parses the resolved keys; nil is returned when
+// decryption is not configured
+func New(options *models.DecryptOptions) (*Decryptor, error) {
+	if options == nil {
+		return nil, nil
+	}
+	if options.KeyRef == "" && options.PassphraseRef == "" {
+		return nil, errors.New("keyRef or passphraseRef is required")
+	}
+
+	d := &Decryptor{
+		options:    options,
+		passphrase: []byte(strings.TrimRight(string(options.Passphrase), "\r\n")),
+	}
+
+	var err error
+	switch options.Method {
+	case models.DecryptMethodAge:
+		err = d.loadAgeIdentities()
+	case models.DecryptMethodGPG:
+		err = d.loadGPGKeys()
+	default:
+		return nil, fmt.Errorf("method must be %q or %q", models.DecryptMethodAge, models.DecryptMethodGPG)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	patterns := options.Files
+	if len(patterns) == 0 {
+		patterns = defaultFiles[options.Method]
+	}
+	d.files, err = filter.New(&models.Filters{Include: patterns})
+	if err != nil {
+		return nil, fmt.Errorf("invalid files pattern: %w", err)
+	}
+	return d, nil
+}
+
+// loadAgeIdentities parses the age identities and adds the passphrase as scrypt identity
+func (d *Decryptor) loadAgeIdentities() error {
+	if len(d.options.Key) > 0 {
+		identities, err := age.ParseIdentities(bytes.NewReader(d.options.Key))
+		if err != nil {
+			return fmt.Errorf("failed to parse age identities: %w", err)
+		}
+		d.identities = identities
+	}
+	if len(d.passphrase) > 0 {
+		identity, err := age.NewScryptIdentity(string(d.passphrase))
+		if err != nil {
+			return fmt.Errorf("invalid age passphrase: %w", err)
+		}
+		d.identities = append(d.identities, identity)
+	}
+	return nil
+}
+
+// loadGPGKeys parses the armored or binary GPG keyring and unlocks its private keys with the passphrase
+func (d *Decryptor) loadGPGKeys() error {
+	if len(d.options.Key) == 0 {
+		// Passphrase-encrypted (symmetric) messages only
+		return nil
+	}
+
+	var keyring openpgp.EntityList
+	var err error
+	if bytes.HasPrefix(bytes.TrimSpace(d.options.Key), pgpArmorHeader) {
+		keyring, err = openpgp.ReadArmoredKeyRing(bytes.NewReader(d.options.Key))
+	} else {
+		keyring, err = openpgp.ReadKeyRing(bytes.NewReader(d.options.Key))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse GPG key: %w", err)
+	}
+
+	privateKeys := 0
+	for _, entity := range keyring {
+		if entity.PrivateKey == nil {
+			continue
+		}
+		privateKeys++
+		if entity.PrivateKey.Encrypted && len(d.passphrase) == 0 {
+			return errors.New("GPG private key is passphrase protected, passphraseRef is required")
+		}
+		if len(d.passphrase) > 0 {
+			if err := entity.DecryptPrivateKeys(d.passphrase); err != nil {
+				return fmt.Errorf("failed to unlock GPG private key: %w", err)
+			}
+		}
+	}
+	if privateKeys == 0 {
+		return errors.New("GPG key does not contain a private key")
+	}
+	d.keyring = keyring
+	return nil
+}
+
+// Selects reports whether the file at the path relative to the target is a file to decrypt
+func (d *Decryptor) Selects(relPath string) bool {
+	return d != nil && d.files.MatchPath(filepath.ToSlash(relPath))
+}
+
+// OutputName returns the path of the decrypted file, without the encryption suffix
+func (d *Decryptor) OutputName(relPath string) string {
+	base := filepath.Base(relPath)
+	for _, suffix := range encryptedSuffixes {
+		if strings.HasSuffix(base, suffix) && base != suffix {
+			return strings.TrimSuffix(relPath, suffix)
+		}
+	}
+	return relPath
+}
+
+// NewReader returns a reader of the plaintext of the encrypted data read from r.
+// Both methods accept binary and ASCII-armored input.
+func (d *Decryptor) NewReader(r io.Reader) (io.Reader, error) {
+	buffered := bufio.NewReader(r)
+	header, _ := buffered.Peek(len(ageArmorHeader))
+
+	switch d.options.Method {
+	case models.DecryptMethodAge:
+		var src io.Reader = buffered
+		if bytes.HasPrefix(header, ageArmorHeader) {
+			src = agearmor.NewReader(buffered)
+		}
+		plaintext, err := age.Decrypt(src, d.identities...)
+		if err != nil {
+			return nil, fmt.Errorf("age decryption failed: %w", err)
+		}
+		return plaintext, nil
+	default:
+		var src io.Reader = buffered
+		if bytes.HasPrefix(header, pgpArmorHeader) {
+			block, err := pgparmor.Decode(buffered)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode armored GPG message: %w", err)
+			}
+			src = block.Body
+		}
+		message, err := openpgp.ReadMessage(src, d.keyring, d.prompt(), nil)
+		if err != nil {
+			return nil, fmt.Errorf("GPG decryption failed: %w", err)
+		}
+		return message.UnverifiedBody, nil
+	}
+}
+
+// prompt supplies the passphrase for symmetrically encrypted GPG messages once
+func (d *Decryptor) prompt() openpgp.PromptFunction {
+	tried := false
+	return func(keys []openpgp.Key, symmetric bool) ([]byte, error) {
+		if tried || !symmetric || len(d.passphrase) == 0 {
+			return nil, errors.New("no matching key or passphrase")
+		}
+		tried = true
+		return d.passphrase, nil
+	}
+}
+
+// DecryptAll decrypts every selected file below the target directory and returns the number decrypted.
+// Decrypted archives selected by the extractor are extracted instead of being written.
+func (d *Decryptor) DecryptAll(targetDir string, extractor *extract.Extractor) (int, error) {
+	if d == nil {
+		return 0, nil
+	}
+
+	// Files are collected first so that decrypted files are not visited
+	var files []string
+	err := filepath.WalkDir(targetDir, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if entry.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		relPath, err := filepath.Rel(targetDir, p)
+		if err != nil {
+			return err
+		}
+		if entry.Type().IsRegular() && d.Selects(relPath) {
+			files = append(files, relPath)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to scan target for encrypted files: %w", err)
+	}
+
+	for _, relPath := range files {
+		if err := d.DecryptFile(targetDir, relPath, extractor); err != nil {
+			return 0, err
+		}
+	}
+	return len(files), nil
+}
+
+// DecryptFile decrypts the file at the path relative to the target and removes it unless it is kept
+func (d *Decryptor) DecryptFile(targetDir, relPath string, extractor *extract.Extractor) error {
+	encryptedPath := filepath.Join(targetDir, relPath)
+	log.Printf("[DECRYPT] Decrypting %s", encryptedPath)
+
+	file, err := os.Open(encryptedPath)
+	if err != nil {
+		return fmt.Errorf("failed to open encrypted file %s: %w", relPath, err)
+	}
+	err = d.decrypt(file, targetDir, relPath, extractor)
+	file.Close()
+	if err != nil {
+		return err
+	}
+
+	if !d.options.KeepEncrypted && d.OutputName(relPath) != relPath {
+		if err := os.Remove(encryptedPath); err != nil {
+			return fmt.Errorf("failed to remove encrypted file %s: %w", relPath, err)
+		}
+	}
+	return nil
+}
+
+// DecryptStream decrypts the file read from r, named by its path relative to the target,
+// without storing the encrypted file. Decrypted archives selected by the extractor are extracted.
+func (d *Decryptor) DecryptStream(r io.Reader, targetDir, relPath string, extractor *extract.Extractor) error {
+	log.Printf("[DECRYPT] Decrypting %s while downloading", relPath)
+	return d.decrypt(r, targetDir, relPath, extractor)
+}
+
+// decrypt writes the plaintext to the output file, or extracts it when it is a selected archive
+func (d *Decryptor) decrypt(r io.Reader, targetDir, relPath string, extractor *extract.Extractor) error {
+	plaintext, err := d.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt %s: %w", relPath, err)
+	}
+
+	outputName := d.OutputName(relPath)
+	if extractor.Selects(outputName) {
+		return extractor.ExtractStream(plaintext, targetDir, outputName)
+	}
+
+	outputPath := filepath.Join(targetDir, outputName)
+	sparse := d.options.FileHandling != nil && d.options.FileHandling.Sparse
+	if err := writeFile(outputPath, plaintext, sparse); err != nil {
+		return fmt.Errorf("failed to decrypt %s: %w", relPath, err)
+	}
+	log.Printf("[DECRYPT] Decrypted %s to %s", relPath, outputName)
+	return nil
+}
+
+// writeFile writes the file through a temporary file, so that a failed or tampered decryption
+// never leaves partial plaintext behind. Sparse output keeps runs of zeros as holes.
+func writeFile(outputPath string, r io.Reader, sparse bool) error {
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(outputPath), ".decrypt-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if sparse {
+		_, err = utils.CopySparse(tmp, r)
+	} else {
+		_, err = io.Copy(tmp, r)
+	}
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to set file mode: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), outputPath); err != nil {
+		return fmt.Errorf("failed to move decrypted file into place: %w", err)
+	}
+	return nil
+}
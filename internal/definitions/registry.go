@@ -0,0 +1,267 @@
+package definitions
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	_ "time/tzdata" // the time zones of schedules do not depend on the zone files of the image
+
+	"github.com/robfig/cron/v3"
+	"github.com/sharedvolume/volume-syncer/internal/models"
+	"sigs.k8s.io/yaml"
+)
+
+// scheduleParser parses the schedules of definitions: standard five-field cron expressions and
+// descriptors such as @hourly or @every 10m
+var scheduleParser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// minWatchInterval is the shortest polling interval of watches, which keeps them from hammering
+// the sources
+const minWatchInterval = 10 * time.Second
+
+// Quiet time before a drift check, by default and at least; shorter ones would compare targets
+// while they are being written
+const (
+	defaultDriftDebounce = 30 * time.Second
+	minDriftDebounce     = time.Second
+)
+
+// definitionsFile is the on-disk format of the sync definitions file
+type definitionsFile struct {
+	Definitions []models.SyncDefinition `json:"definitions"`
+}
+
+// Registry holds the sync definitions known to the server
+type Registry struct {
+	mutex       sync.RWMutex
+	definitions []models.SyncDefinition
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// LoadFile loads definitions from a YAML or JSON file into a new registry
+func LoadFile(path string) (*Registry, error) {
+	log.Printf("[DEFINITIONS] Loading sync definitions from %s", path)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sync definitions file: %w", err)
+	}
+
+	// YAML is converted to JSON first, so that source details decode like those of requests
+	var file definitionsFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse sync definitions file: %w", err)
+	}
+
+	registry := NewRegistry()
+	for _, def := range file.Definitions {
+		if err := registry.Add(def); err != nil {
+			return nil, err
+		}
+	}
+
+	log.Printf("[DEFINITIONS] Loaded %d sync definitions", len(registry.definitions))
+	return registry, nil
+}
+
+// Add validates and registers a definition
+func (r *Registry) Add(def models.SyncDefinition) error {
+	if def.Name == "" {
+		return fmt.Errorf("sync definition name is required")
+	}
+	if def.Source.Type == "" || def.Source.Details == nil {
+		return fmt.Errorf("sync definition %s: source type and details are required", def.Name)
+	}
+	if def.Target.Path == "" {
+		return fmt.Errorf("sync definition %s: target path is required", def.Name)
+	}
+	if def.Schedule != "" {
+		if _, err := ParseSchedule(def.Schedule, def.TimeZone); err != nil {
+			return fmt.Errorf("sync definition %s: invalid schedule: %w", def.Name, err)
+		}
+		if _, err := ParseJitter(def.Jitter); err != nil {
+			return fmt.Errorf("sync definition %s: %w", def.Name, err)
+		}
+		switch def.ConcurrencyPolicy {
+		case "", models.ConcurrencyForbid, models.ConcurrencyReplace, models.ConcurrencyAllow:
+		default:
+			return fmt.Errorf("sync definition %s: invalid concurrency policy %q, use Forbid, Replace or Allow", def.Name, def.ConcurrencyPolicy)
+		}
+	} else if def.TimeZone != "" || def.Jitter != "" || def.ConcurrencyPolicy != "" {
+		return fmt.Errorf("sync definition %s: timeZone, jitter and concurrencyPolicy require a schedule", def.Name)
+	}
+	if def.Watch != nil {
+		if _, err := ParseWatchInterval(def.Watch.Interval); err != nil {
+			return fmt.Errorf("sync definition %s: %w", def.Name, err)
+		}
+	}
+	if def.Drift != nil {
+		if _, err := ParseDriftDebounce(def.Drift.Debounce); err != nil {
+			return fmt.Errorf("sync definition %s: %w", def.Name, err)
+		}
+	}
+	if def.Webhook != nil {
+		if def.Webhook.Repository == "" {
+			return fmt.Errorf("sync definition %s: webhook repository is required", def.Name)
+		}
+		if def.Webhook.Secret == "" {
+			return fmt.Errorf("sync definition %s: webhook secret is required", def.Name)
+		}
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, existing := range r.definitions {
+		if existing.Name == def.Name {
+			return fmt.Errorf("duplicate sync definition name: %s", def.Name)
+		}
+	}
+	r.definitions = append(r.definitions, def)
+	return nil
+}
+
+// Get returns the definition with the given name
+func (r *Registry) Get(name string) (models.SyncDefinition, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, def := range r.definitions {
+		if def.Name == name {
+			return def, true
+		}
+	}
+	return models.SyncDefinition{}, false
+}
+
+// All returns a copy of all registered definitions
+func (r *Registry) All() []models.SyncDefinition {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return append([]models.SyncDefinition(nil), r.definitions...)
+}
+
+// ParseSchedule parses the schedule of a definition in the time zone, if any. The time zone
+// replaces a CRON_TZ or TZ prefix of the expression, so that both cannot be combined.
+func ParseSchedule(spec, timeZone string) (cron.Schedule, error) {
+	if timeZone == "" {
+		return scheduleParser.Parse(spec)
+	}
+	if strings.HasPrefix(spec, "CRON_TZ=") || strings.HasPrefix(spec, "TZ=") {
+		return nil, fmt.Errorf("the schedule cannot set CRON_TZ or TZ together with a time zone")
+	}
+	if _, err := time.LoadLocation(timeZone); err != nil {
+		return nil, fmt.Errorf("invalid time zone %q: %w", timeZone, err)
+	}
+	return scheduleParser.Parse("CRON_TZ=" + timeZone + " " + spec)
+}
+
+// ParseJitter parses the jitter of a definition, 0 when unset
+func ParseJitter(jitter string) (time.Duration, error) {
+	if jitter == "" {
+		return 0, nil
+	}
+	duration, err := time.ParseDuration(jitter)
+	if err != nil || duration < 0 {
+		return 0, fmt.Errorf("invalid jitter %q, use a duration such as 15m", jitter)
+	}
+	return duration, nil
+}
+
+// ParseWatchInterval parses the polling interval of a watch, which is at least minWatchInterval
+func ParseWatchInterval(interval string) (time.Duration, error) {
+	duration, err := time.ParseDuration(interval)
+	if err != nil || duration < minWatchInterval {
+		return 0, fmt.Errorf("invalid watch interval %q, use a duration of at least %v such as 1m", interval, minWatchInterval)
+	}
+	return duration, nil
+}
+
+// ParseDriftDebounce parses the debounce of drift watches, which is at least minDriftDebounce and
+// defaults to defaultDriftDebounce
+func ParseDriftDebounce(debounce string) (time.Duration, error) {
+	if debounce == "" {
+		return defaultDriftDebounce, nil
+	}
+	duration, err := time.ParseDuration(debounce)
+	if err != nil || duration < minDriftDebounce {
+		return 0, fmt.Errorf("invalid drift debounce %q, use a duration of at least %v such as 1m", debounce, minDriftDebounce)
+	}
+	return duration, nil
+}
+
+// MatchWebhook returns the webhook-enabled definitions for the pushed repository and branch.
+// repoURLs may contain every URL form the provider reports for the repository.
+func (r *Registry) MatchWebhook(repoURLs []string, branch string) []models.SyncDefinition {
+	pushed := make(map[string]bool)
+	for _, u := range repoURLs {
+		if key := NormalizeRepoURL(u); key != "" {
+			pushed[key] = true
+		}
+	}
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var matches []models.SyncDefinition
+	for _, def := range r.definitions {
+		if def.Webhook == nil || !pushed[NormalizeRepoURL(def.Webhook.Repository)] {
+			continue
+		}
+		if wanted := webhookBranch(def); wanted != "" && wanted != branch {
+			continue
+		}
+		matches = append(matches, def)
+	}
+	return matches
+}
+
+// webhookBranch returns the branch a definition reacts to, falling back to the Git source branch
+func webhookBranch(def models.SyncDefinition) string {
+	if def.Webhook.Branch != "" {
+		return def.Webhook.Branch
+	}
+	if details, ok := def.Source.Details.(map[string]interface{}); ok && def.Source.Type == "git" {
+		if branch, ok := details["branch"].(string); ok {
+			return branch
+		}
+	}
+	return ""
+}
+
+// NormalizeRepoURL reduces a repository URL to "host/path" so that HTTPS, SSH and
+// scp-like forms of the same repository compare equal
+func NormalizeRepoURL(repoURL string) string {
+	repoURL = strings.TrimSpace(repoURL)
+	if repoURL == "" {
+		return ""
+	}
+
+	var host, path string
+	if strings.Contains(repoURL, "://") {
+		parsedURL, err := url.Parse(repoURL)
+		if err != nil {
+			return ""
+		}
+		host, path = parsedURL.Hostname(), parsedURL.Path
+	} else if colon := strings.Index(repoURL, ":"); colon > 0 {
+		host, path = repoURL[:colon], repoURL[colon+1:]
+		if at := strings.LastIndex(host, "@"); at != -1 {
+			host = host[at+1:]
+		}
+	} else {
+		return ""
+	}
+
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	return strings.ToLower(host) + "/" + strings.ToLower(path)
+}
@@ -0,0 +1,202 @@
+package drift
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/sharedvolume/volume-syncer/internal/definitions"
+	"github.com/sharedvolume/volume-syncer/internal/models"
+	"github.com/sharedvolume/volume-syncer/internal/service"
+)
+
+// Watcher watches the targets of the sync definitions setting drift for changes made outside of
+// syncs, e.g. by misconfigured jobs sharing the volume. Once a target was left unchanged for the
+// debounce it is compared with the manifest of its last successful sync; drifted targets are
+// reported to the sync service and repaired by running their definition if it asks for it.
+type Watcher struct {
+	syncService *service.SyncService
+	targets     []*target
+}
+
+// target is the watched target of a sync definition
+type target struct {
+	def       models.SyncDefinition
+	debounce  time.Duration
+	repairing bool // a repair was submitted and the target was not found clean since
+}
+
+// New creates a watcher of the targets of the definitions setting drift
+func New(syncService *service.SyncService, defs []models.SyncDefinition) (*Watcher, error) {
+	w := &Watcher{syncService: syncService}
+	for _, def := range defs {
+		if def.Drift == nil {
+			continue
+		}
+		debounce, err := definitions.ParseDriftDebounce(def.Drift.Debounce)
+		if err != nil {
+			return nil, fmt.Errorf("sync definition %s: %w", def.Name, err)
+		}
+		w.targets = append(w.targets, &target{def: def, debounce: debounce})
+	}
+	return w, nil
+}
+
+// Run watches the targets until the context is done
+func (w *Watcher) Run(ctx context.Context) {
+	var running sync.WaitGroup
+	for _, t := range w.targets {
+		running.Add(1)
+		go func(t *target) {
+			defer running.Done()
+			w.watch(ctx, t)
+		}(t)
+	}
+	running.Wait()
+}
+
+// watch checks the target for drift each time changes to it settled, until the context is done.
+// The watches are set up again after each check, as syncs may replace the directories of the
+// target.
+func (w *Watcher) watch(ctx context.Context, t *target) {
+	action := "reporting"
+	if t.def.Drift.Repair {
+		action = "repairing"
+	}
+	log.Printf("[DRIFT] Watching target %s of sync definition %s, %s drift after %v without changes", t.def.Target.Path, t.def.Name, action, t.debounce)
+
+	var failure string // last error watching the target, logged once
+	for {
+		err := w.settle(ctx, t)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			if err.Error() != failure {
+				if errors.Is(err, fs.ErrNotExist) {
+					log.Printf("[DRIFT] Target %s does not exist yet, waiting for its first sync", t.def.Target.Path)
+				} else {
+					log.Printf("[DRIFT] WARNING: Cannot watch target %s, retrying every %v: %v", t.def.Target.Path, t.debounce, err)
+				}
+				failure = err.Error()
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(t.debounce):
+			}
+			continue
+		}
+		failure = ""
+		w.check(t)
+	}
+}
+
+// settle watches the directories of the target and returns once the target changed and then was
+// left unchanged for the debounce while no sync was running or queued
+func (w *Watcher) settle(ctx context.Context, t *target) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create watcher: %w", err)
+	}
+	defer watcher.Close()
+	if err := addTree(watcher, t.def.Target.Path); err != nil {
+		return err
+	}
+
+	quiet := time.NewTimer(t.debounce)
+	quiet.Stop()
+	defer quiet.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return errors.New("watcher closed")
+			}
+			// Directories created outside of syncs are watched until the next check
+			if event.Has(fsnotify.Create) {
+				addTree(watcher, event.Name)
+			}
+			quiet.Reset(t.debounce)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return errors.New("watcher closed")
+			}
+			if !errors.Is(err, fsnotify.ErrEventOverflow) {
+				return err
+			}
+			// Events were lost, the comparison with the manifest does not need them
+			quiet.Reset(t.debounce)
+		case <-quiet.C:
+			// Syncs change the target themselves, the check waits for them to finish
+			if _, queued := w.syncService.QueueState(); queued > 0 || w.syncService.IsSyncInProgress() {
+				quiet.Reset(t.debounce)
+				continue
+			}
+			return nil
+		}
+	}
+}
+
+// addTree watches the directory and the directories below it, except Git metadata
+func addTree(watcher *fsnotify.Watcher, root string) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if d.Name() == ".git" && path != root {
+			return filepath.SkipDir
+		}
+		if err := watcher.Add(path); err != nil {
+			return fmt.Errorf("failed to watch %s (see fs.inotify.max_user_watches): %w", path, err)
+		}
+		return nil
+	})
+}
+
+// check compares the target with the manifest of its last successful sync, reports drift and
+// submits a repair unless one was submitted since the target was last found clean
+func (w *Watcher) check(t *target) {
+	path := t.def.Target.Path
+	drift, err := w.syncService.TargetDrift(path)
+	if err != nil {
+		log.Printf("[DRIFT] WARNING: Failed to check target %s for drift: %v", path, err)
+		return
+	}
+	if drift.Status != "drifted" {
+		t.repairing = false
+		return
+	}
+
+	w.syncService.ReportDrift(t.def.Name, drift)
+	log.Printf("[DRIFT] WARNING: Target %s of sync definition %s drifted: %d added, %d modified, %d removed",
+		path, t.def.Name, len(drift.Added), len(drift.Modified), len(drift.Removed))
+	if !t.def.Drift.Repair {
+		return
+	}
+	if t.repairing {
+		log.Printf("[DRIFT] Target %s drifted again before a successful sync, not repairing it again", path)
+		return
+	}
+
+	req := t.def.Request()
+	req.Initiator = "drift " + t.def.Name
+	job, _, err := w.syncService.QueueSync(req)
+	if err != nil {
+		log.Printf("[DRIFT] ERROR: Failed to start repair of %s: %v", path, err)
+		return
+	}
+	t.repairing = true
+	log.Printf("[DRIFT] Repair of %s submitted as %s job %s", path, job.Status, job.ID)
+}
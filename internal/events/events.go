@@ -0,0 +1,143 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/sharedvolume/volume-syncer/internal/kube"
+	"github.com/sharedvolume/volume-syncer/internal/models"
+)
+
+// component is the source component of the recorded events
+const component = "volume-syncer"
+
+// recordTimeout limits the creation of an event
+const recordTimeout = 10 * time.Second
+
+// Reasons of the recorded events
+const (
+	ReasonSyncStarted   = "SyncStarted"
+	ReasonSyncSucceeded = "SyncSucceeded"
+	ReasonSyncFailed    = "SyncFailed"
+)
+
+// Event types
+const (
+	typeNormal  = "Normal"
+	typeWarning = "Warning"
+)
+
+// Recorder publishes Kubernetes events for the lifecycle of sync jobs. A nil recorder is disabled.
+type Recorder struct {
+	client *kube.Client
+	pod    *models.ObjectReference // events of jobs without an owner are recorded on the pod
+	host   string
+}
+
+// event is a core/v1 Event
+type event struct {
+	Metadata struct {
+		GenerateName string `json:"generateName"`
+		Namespace    string `json:"namespace"`
+	} `json:"metadata"`
+	InvolvedObject models.ObjectReference `json:"involvedObject"`
+	Reason         string                 `json:"reason"`
+	Message        string                 `json:"message"`
+	Type           string                 `json:"type"`
+	Source         struct {
+		Component string `json:"component"`
+		Host      string `json:"host,omitempty"`
+	} `json:"source"`
+	FirstTimestamp     time.Time `json:"firstTimestamp"`
+	LastTimestamp      time.Time `json:"lastTimestamp"`
+	Count              int       `json:"count"`
+	ReportingComponent string    `json:"reportingComponent"`
+	ReportingInstance  string    `json:"reportingInstance,omitempty"`
+}
+
+// pod is the part of a core/v1 Pod the recorder reads
+type pod struct {
+	Metadata struct {
+		UID string `json:"uid"`
+	} `json:"metadata"`
+}
+
+// New creates a recorder for the pod the process runs in. The pod is named by POD_NAME, or by the
+// hostname; its UID is read from POD_UID, or from the API server.
+func New(client *kube.Client) *Recorder {
+	host, _ := os.Hostname()
+	name := os.Getenv("POD_NAME")
+	if name == "" {
+		name = host
+	}
+	ref := &models.ObjectReference{APIVersion: "v1", Kind: "Pod", Namespace: client.Namespace(), Name: name, UID: os.Getenv("POD_UID")}
+	if ref.UID == "" {
+		ctx, cancel := context.WithTimeout(context.Background(), recordTimeout)
+		defer cancel()
+		var current pod
+		path := fmt.Sprintf("/api/v1/namespaces/%s/pods/%s", url.PathEscape(ref.Namespace), url.PathEscape(ref.Name))
+		if err := client.Get(ctx, path, &current); err != nil {
+			log.Printf("[EVENTS] WARNING: Failed to look up pod %s, events may not show up in kubectl describe: %v", name, err)
+		}
+		ref.UID = current.Metadata.UID
+	}
+	log.Printf("[EVENTS] Recording sync events on pod %s/%s", ref.Namespace, ref.Name)
+	return &Recorder{client: client, pod: ref, host: host}
+}
+
+// SyncStarted records the start of the job
+func (r *Recorder) SyncStarted(job *models.SyncJob, owner *models.ObjectReference) {
+	message := fmt.Sprintf("Sync job %s started: %s source into %s", job.ID, job.SourceType, job.TargetPath)
+	if job.Resumed > 0 {
+		message = fmt.Sprintf("Sync job %s resumed after a restart: %s source into %s", job.ID, job.SourceType, job.TargetPath)
+	}
+	r.record(owner, typeNormal, ReasonSyncStarted, message)
+}
+
+// SyncFinished records the outcome of the finished job
+func (r *Recorder) SyncFinished(job *models.SyncJob, owner *models.ObjectReference) {
+	if job.Status == models.JobStatusFailed {
+		r.record(owner, typeWarning, ReasonSyncFailed, fmt.Sprintf("Sync job %s failed: %s", job.ID, job.Error))
+		return
+	}
+	message := fmt.Sprintf("Sync job %s succeeded, %s is up to date", job.ID, job.TargetPath)
+	if job.Result != nil && (job.Changed == nil || *job.Changed) {
+		message = fmt.Sprintf("Sync job %s succeeded: %d files added, %d changed, %d deleted in %s",
+			job.ID, job.Result.FilesAdded, job.Result.FilesChanged, job.Result.FilesDeleted, job.TargetPath)
+	}
+	r.record(owner, typeNormal, ReasonSyncSucceeded, message)
+}
+
+// record creates the event in the background, so that an unavailable API server does not delay syncs
+func (r *Recorder) record(owner *models.ObjectReference, eventType, reason, message string) {
+	if r == nil {
+		return
+	}
+	involved := r.pod
+	if owner != nil {
+		involved = owner
+	}
+
+	now := time.Now().UTC()
+	var e event
+	e.Metadata.GenerateName = involved.Name + "."
+	e.Metadata.Namespace = involved.Namespace
+	e.InvolvedObject = *involved
+	e.Reason, e.Message, e.Type = reason, message, eventType
+	e.Source.Component, e.Source.Host = component, r.host
+	e.FirstTimestamp, e.LastTimestamp, e.Count = now, now, 1
+	e.ReportingComponent, e.ReportingInstance = component, r.host
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), recordTimeout)
+		defer cancel()
+		path := fmt.Sprintf("/api/v1/namespaces/%s/events", url.PathEscape(involved.Namespace))
+		if err := r.client.Create(ctx, path, &e); err != nil {
+			log.Printf("[EVENTS] WARNING: Failed to record %s event on %s %s: %v", reason, involved.Kind, involved.Name, err)
+		}
+	}()
+}
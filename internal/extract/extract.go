@@ -0,0 +1,542 @@
+package extract
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/sharedvolume/volume-syncer/internal/filter"
+	"github.com/sharedvolume/volume-syncer/internal/models"
+	"github.com/sharedvolume/volume-syncer/internal/utils"
+	"github.com/ulikunitz/xz"
+)
+
+// Archive kinds
+const (
+	kindTar    = "tar"
+	kindZip    = "zip"
+	kindSingle = "single" // a single compressed file
+)
+
+// archiveFormat maps a file name suffix to the archive kind and compression
+type archiveFormat struct {
+	suffix      string
+	kind        string
+	compression string
+}
+
+// archiveFormats lists the recognized archive suffixes, longest first
+var archiveFormats = []archiveFormat{
+	{".tar.gz", kindTar, "gzip"},
+	{".tar.xz", kindTar, "xz"},
+	{".tar.zst", kindTar, "zstd"},
+	{".tgz", kindTar, "gzip"},
+	{".txz", kindTar, "xz"},
+	{".tzst", kindTar, "zstd"},
+	{".tar", kindTar, ""},
+	{".zip", kindZip, ""},
+	{".gz", kindSingle, "gzip"},
+	{".xz", kindSingle, "xz"},
+	{".zst", kindSingle, "zstd"},
+}
+
+// Extractor unpacks archives into the target according to the extract options
+type Extractor struct {
+	options *models.ExtractOptions
+	files   *filter.Matcher
+	budget  *budget // what the extraction of the current archive has written
+}
+
+// New validates the extract options; nil is returned when extraction is not configured
+func New(options *models.ExtractOptions) (*Extractor, error) {
+	if options == nil {
+		return nil, nil
+	}
+
+	switch options.Overwrite {
+	case "", models.OverwriteAlways, models.OverwriteNever, models.OverwriteNewer:
+	default:
+		return nil, fmt.Errorf("overwrite must be %q, %q or %q", models.OverwriteAlways, models.OverwriteNever, models.OverwriteNewer)
+	}
+	if options.StripComponents < 0 {
+		return nil, fmt.Errorf("stripComponents must not be negative")
+	}
+	if options.MaxSize < 0 {
+		return nil, fmt.Errorf("maxSize must not be negative")
+	}
+	if options.MaxEntries < 0 {
+		return nil, fmt.Errorf("maxEntries must not be negative")
+	}
+	if options.Destination != "" {
+		clean := filepath.ToSlash(filepath.Clean(options.Destination))
+		if filepath.IsAbs(options.Destination) || clean == ".." || strings.HasPrefix(clean, "../") {
+			return nil, fmt.Errorf("destination must be a path inside the target")
+		}
+	}
+
+	files, err := filter.New(&models.Filters{Include: options.Files})
+	if err != nil {
+		return nil, fmt.Errorf("invalid files pattern: %w", err)
+	}
+	return &Extractor{options: options, files: files}, nil
+}
+
+// IsArchive reports whether the file name has a recognized archive suffix
+func IsArchive(name string) bool {
+	_, ok := detectFormat(name)
+	return ok
+}
+
+// Selects reports whether the file at the path relative to the target is an archive to extract
+func (e *Extractor) Selects(relPath string) bool {
+	return e != nil && IsArchive(relPath) && e.files.MatchPath(filepath.ToSlash(relPath))
+}
+
+// KeepArchive reports whether archives are kept after extraction
+func (e *Extractor) KeepArchive() bool {
+	return e != nil && e.options.KeepArchive
+}
+
+// ExtractAll extracts every selected archive below the target directory and returns the number extracted
+func (e *Extractor) ExtractAll(targetDir string) (int, error) {
+	if e == nil {
+		return 0, nil
+	}
+
+	// Archives are collected first so that extracted files are not visited
+	var archives []string
+	err := filepath.WalkDir(targetDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		relPath, err := filepath.Rel(targetDir, p)
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() && e.Selects(relPath) {
+			archives = append(archives, relPath)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to scan target for archives: %w", err)
+	}
+
+	for _, relPath := range archives {
+		if err := e.ExtractFile(targetDir, relPath); err != nil {
+			return 0, err
+		}
+	}
+	return len(archives), nil
+}
+
+// ExtractFile extracts the archive at the path relative to the target and removes it unless it is kept
+func (e *Extractor) ExtractFile(targetDir, relPath string) error {
+	archivePath := filepath.Join(targetDir, relPath)
+	log.Printf("[EXTRACT] Extracting %s", archivePath)
+
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to open archive %s: %w", relPath, err)
+	}
+	err = e.extract(file, targetDir, relPath)
+	file.Close()
+	if err != nil {
+		return err
+	}
+
+	if !e.options.KeepArchive {
+		if err := os.Remove(archivePath); err != nil {
+			return fmt.Errorf("failed to remove archive %s: %w", relPath, err)
+		}
+	}
+	return nil
+}
+
+// ExtractStream extracts an archive read from r, named by its path relative to the target,
+// without storing the archive itself
+func (e *Extractor) ExtractStream(r io.Reader, targetDir, relPath string) error {
+	log.Printf("[EXTRACT] Extracting %s while downloading", relPath)
+	return e.extract(r, targetDir, relPath)
+}
+
+// extract unpacks the archive into its destination directory
+func (e *Extractor) extract(r io.Reader, targetDir, relPath string) error {
+	format, ok := detectFormat(relPath)
+	if !ok {
+		return fmt.Errorf("%s is not a supported archive", relPath)
+	}
+
+	destDir := filepath.Join(targetDir, filepath.Dir(relPath))
+	if e.options.Destination != "" {
+		destDir = filepath.Join(targetDir, e.options.Destination)
+	}
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return fmt.Errorf("failed to create extraction directory: %w", err)
+	}
+
+	// Each archive is counted against the limits on its own, also when archives are extracted concurrently
+	e = &Extractor{options: e.options, files: e.files, budget: &budget{}}
+
+	var err error
+	var count int
+	var links []string
+	switch format.kind {
+	case kindZip:
+		count, err = e.extractZip(r, destDir, &links)
+	default:
+		var decompressed io.Reader
+		var closeReader func()
+		decompressed, closeReader, err = decompress(r, format.compression)
+		if err != nil {
+			return fmt.Errorf("failed to read archive %s: %w", relPath, err)
+		}
+		defer closeReader()
+
+		if format.kind == kindTar {
+			count, err = e.extractTar(decompressed, destDir, &links)
+		} else {
+			name := strings.TrimSuffix(filepath.Base(relPath), format.suffix)
+			count = 1
+			if err = e.countEntry(); err == nil {
+				err = e.writeFile(destDir, name, 0644, time.Time{}, decompressed)
+			}
+		}
+	}
+	if err == nil && len(links) > 0 {
+		err = e.followLinks(destDir, links)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to extract archive %s: %w", relPath, err)
+	}
+
+	log.Printf("[EXTRACT] Extracted %d entries from %s into %s", count, relPath, destDir)
+	return nil
+}
+
+// extractTar unpacks a tar stream; the symbolic links to dereference are added to links
+func (e *Extractor) extractTar(r io.Reader, destDir string, links *[]string) (int, error) {
+	tr := tar.NewReader(r)
+	count := 0
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return count, err
+		}
+
+		name, ok := e.entryPath(header.Name)
+		if !ok {
+			continue
+		}
+		if err := e.countEntry(); err != nil {
+			return count, err
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = e.makeDir(destDir, name)
+		case tar.TypeReg, tar.TypeGNUSparse:
+			// The reader expands the holes of GNU sparse entries
+			err = e.writeFile(destDir, name, header.FileInfo().Mode(), header.ModTime, tr)
+		case tar.TypeSymlink:
+			err = e.makeSymlink(destDir, name, header.Linkname, links)
+		case tar.TypeLink:
+			target, linkOK := e.entryPath(header.Linkname)
+			if !linkOK {
+				return count, fmt.Errorf("hard link %s points outside the archive", header.Name)
+			}
+			err = e.makeHardLink(destDir, name, target)
+		default:
+			log.Printf("[EXTRACT] Skipping unsupported entry %s (type %c)", header.Name, header.Typeflag)
+			continue
+		}
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+}
+
+// extractZip unpacks a zip archive; the symbolic links to dereference are added to links
+func (e *Extractor) extractZip(r io.Reader, destDir string, links *[]string) (int, error) {
+	file, ok := r.(*os.File)
+	if !ok {
+		// Zip archives keep their index at the end, streams are read entry by entry instead
+		return e.extractZipStream(r, destDir, links)
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	zr, err := zip.NewReader(file, stat.Size())
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, entry := range zr.File {
+		name, ok := e.entryPath(entry.Name)
+		if !ok {
+			continue
+		}
+		if err := e.countEntry(); err != nil {
+			return count, err
+		}
+		mode := entry.Mode()
+
+		switch {
+		case mode.IsDir():
+			err = e.makeDir(destDir, name)
+		case mode&fs.ModeSymlink != 0:
+			err = e.zipSymlink(destDir, name, entry, links)
+		case mode.IsRegular():
+			err = e.zipFile(destDir, name, entry)
+		default:
+			log.Printf("[EXTRACT] Skipping unsupported entry %s", entry.Name)
+			continue
+		}
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+func (e *Extractor) zipFile(destDir, name string, entry *zip.File) error {
+	rc, err := entry.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return e.writeFile(destDir, name, entry.Mode(), entry.Modified, rc)
+}
+
+func (e *Extractor) zipSymlink(destDir, name string, entry *zip.File, links *[]string) error {
+	rc, err := entry.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	target, err := io.ReadAll(io.LimitReader(rc, 4096))
+	if err != nil {
+		return err
+	}
+	return e.makeSymlink(destDir, name, string(target), links)
+}
+
+// entryPath normalizes an archive entry name and removes the stripped leading components.
+// Entries that are empty after stripping are skipped.
+func (e *Extractor) entryPath(name string) (string, bool) {
+	clean := path.Clean("/" + strings.ReplaceAll(name, `\`, "/"))
+	parts := strings.Split(strings.TrimPrefix(clean, "/"), "/")
+	if len(parts) <= e.options.StripComponents || (len(parts) == 1 && parts[0] == "") {
+		return "", false
+	}
+	return path.Join(parts[e.options.StripComponents:]...), true
+}
+
+// resolve returns the local path of an entry after checking that no parent directory is a symlink,
+// so that entries cannot be written outside the destination
+func resolve(destDir, name string) (string, error) {
+	parts := strings.Split(name, "/")
+	current := destDir
+	for _, part := range parts[:len(parts)-1] {
+		current = filepath.Join(current, part)
+		info, err := os.Lstat(current)
+		if errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&fs.ModeSymlink != 0 {
+			return "", fmt.Errorf("entry %s is below a symbolic link", name)
+		}
+	}
+	return filepath.Join(destDir, filepath.FromSlash(name)), nil
+}
+
+// shouldWrite applies the overwrite policy to an existing file
+func (e *Extractor) shouldWrite(localPath string, modTime time.Time) (bool, error) {
+	info, err := os.Lstat(localPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if info.IsDir() {
+		return false, fmt.Errorf("%s already exists as a directory", localPath)
+	}
+
+	switch e.options.Overwrite {
+	case models.OverwriteNever:
+		return false, nil
+	case models.OverwriteNewer:
+		return modTime.After(info.ModTime()), nil
+	default:
+		return true, nil
+	}
+}
+
+func (e *Extractor) makeDir(destDir, name string) error {
+	localPath, err := resolve(destDir, name+"/x")
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(filepath.Dir(localPath), 0755)
+}
+
+// writeFile writes an entry through a temporary file that replaces the existing file atomically
+func (e *Extractor) writeFile(destDir, name string, mode fs.FileMode, modTime time.Time, r io.Reader) error {
+	localPath, err := resolve(destDir, name)
+	if err != nil {
+		return err
+	}
+	if write, err := e.shouldWrite(localPath, modTime); err != nil || !write {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(localPath), ".extract-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := e.copyContents(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode.Perm()); err != nil {
+		return err
+	}
+	if !modTime.IsZero() {
+		if err := os.Chtimes(tmp.Name(), modTime, modTime); err != nil {
+			return err
+		}
+	}
+	return os.Rename(tmp.Name(), localPath)
+}
+
+// makeSymlink creates a symbolic link whose target stays inside the destination. Skipped links
+// are not created; links to dereference are created and added to links.
+func (e *Extractor) makeSymlink(destDir, name, target string, links *[]string) error {
+	if e.symlinks() == models.SymlinksSkip {
+		log.Printf("[EXTRACT] Skipping symbolic link %s", name)
+		return nil
+	}
+	if filepath.IsAbs(target) {
+		return fmt.Errorf("symbolic link %s has an absolute target", name)
+	}
+	resolved := path.Join(path.Dir(name), filepath.ToSlash(target))
+	if resolved == ".." || strings.HasPrefix(resolved, "../") {
+		return fmt.Errorf("symbolic link %s points outside the destination", name)
+	}
+
+	localPath, err := resolve(destDir, name)
+	if err != nil {
+		return err
+	}
+	if write, err := e.shouldWrite(localPath, time.Time{}); err != nil || !write {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+		return err
+	}
+	os.Remove(localPath)
+	if err := os.Symlink(target, localPath); err != nil {
+		return err
+	}
+	if e.symlinks() == models.SymlinksFollow {
+		*links = append(*links, name)
+	}
+	return nil
+}
+
+// makeHardLink links an entry to a previously extracted file, or copies the file unless hard links are preserved
+func (e *Extractor) makeHardLink(destDir, name, target string) error {
+	targetPath, err := resolve(destDir, target)
+	if err != nil {
+		return err
+	}
+	localPath, err := resolve(destDir, name)
+	if err != nil {
+		return err
+	}
+	if !e.hardlinks() {
+		return e.copyHardLink(destDir, name, targetPath)
+	}
+	if write, err := e.shouldWrite(localPath, time.Time{}); err != nil || !write {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+		return err
+	}
+	os.Remove(localPath)
+	return os.Link(targetPath, localPath)
+}
+
+// detectFormat returns the archive format of the file name
+func detectFormat(name string) (archiveFormat, bool) {
+	lower := strings.ToLower(name)
+	for _, format := range archiveFormats {
+		if strings.HasSuffix(lower, format.suffix) && len(lower) > len(format.suffix) {
+			return format, true
+		}
+	}
+	return archiveFormat{}, false
+}
+
+// decompress wraps r with the decompressor for the compression
+func decompress(r io.Reader, compression string) (io.Reader, func(), error) {
+	switch compression {
+	case "gzip":
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, nil, err
+		}
+		return gz, func() { gz.Close() }, nil
+	case "xz":
+		xr, err := xz.NewReader(r)
+		if err != nil {
+			return nil, nil, err
+		}
+		return xr, utils.NoCleanup, nil
+	case "zstd":
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, nil, err
+		}
+		return zr, zr.Close, nil
+	default:
+		return r, utils.NoCleanup, nil
+	}
+}
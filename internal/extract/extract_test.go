@@ -0,0 +1,361 @@
+package extract
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sharedvolume/volume-syncer/internal/models"
+)
+
+// tarEntry is an entry of an archive built by buildTar
+type tarEntry struct {
+	name     string
+	body     string
+	link     string // target of symbolic and hard links
+	typeflag byte   // tar.TypeReg when unset
+}
+
+// buildTar returns a tar archive of the entries
+func buildTar(t *testing.T, entries ...tarEntry) []byte {
+	t.Helper()
+	var archive bytes.Buffer
+	w := tar.NewWriter(&archive)
+	for _, entry := range entries {
+		header := &tar.Header{Name: entry.name, Linkname: entry.link, Typeflag: entry.typeflag, Mode: 0644, Size: int64(len(entry.body))}
+		switch entry.typeflag {
+		case 0:
+			header.Typeflag = tar.TypeReg
+		case tar.TypeDir:
+			header.Mode = 0755
+		case tar.TypeSymlink, tar.TypeLink:
+			header.Mode = 0777
+		}
+		if err := w.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(entry.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return archive.Bytes()
+}
+
+// writeZipEntries returns an archive written by archive/zip, recording the modes of the entries
+func writeZipEntries(t *testing.T, entries ...zipEntry) []byte {
+	t.Helper()
+	var archive bytes.Buffer
+	w := zip.NewWriter(&archive)
+	for _, entry := range entries {
+		header := &zip.FileHeader{Name: entry.name, Method: zip.Deflate}
+		mode := entry.mode
+		if mode == 0 {
+			mode = 0644
+		}
+		header.SetMode(mode)
+		f, err := w.CreateHeader(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(entry.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return archive.Bytes()
+}
+
+func gzipped(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var compressed bytes.Buffer
+	w := gzip.NewWriter(&compressed)
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return compressed.Bytes()
+}
+
+func TestExtract(t *testing.T) {
+	symlink := fs.ModeSymlink | 0777
+
+	tests := []struct {
+		name    string
+		archive string // file name, selecting the format
+		data    []byte
+		stream  bool // extracted with ExtractStream instead of from a file
+		options models.ExtractOptions
+		want    map[string]string
+		err     error  // matched with errors.Is
+		errText string // contained in the error
+	}{
+		{
+			name:    "tar entries with parent directories",
+			archive: "a.tar",
+			data:    buildTar(t, tarEntry{name: "../../evil.txt", body: "evil"}, tarEntry{name: "dir/../../up.txt", body: "up"}),
+			want:    map[string]string{"evil.txt": "evil", "up.txt": "up"},
+		},
+		{
+			name:    "tar entry with an absolute path",
+			archive: "a.tar",
+			data:    buildTar(t, tarEntry{name: "/etc/evil.txt", body: "evil"}),
+			want:    map[string]string{"etc/evil.txt": "evil"},
+		},
+		{
+			name:    "tar symbolic link",
+			archive: "a.tar",
+			data:    buildTar(t, tarEntry{name: "a.txt", body: "alpha"}, tarEntry{name: "dir/link", link: "../a.txt", typeflag: tar.TypeSymlink}),
+			want:    map[string]string{"a.txt": "alpha", "dir/link": "-> ../a.txt"},
+		},
+		{
+			name:    "tar symbolic link with an absolute target",
+			archive: "a.tar",
+			data:    buildTar(t, tarEntry{name: "link", link: "/etc/passwd", typeflag: tar.TypeSymlink}),
+			errText: "symbolic link link has an absolute target",
+		},
+		{
+			name:    "tar symbolic link pointing outside the destination",
+			archive: "a.tar",
+			data:    buildTar(t, tarEntry{name: "dir/link", link: "../../outside", typeflag: tar.TypeSymlink}),
+			errText: "symbolic link dir/link points outside the destination",
+		},
+		{
+			name:    "tar entry below a symbolic link",
+			archive: "a.tar",
+			data: buildTar(t,
+				tarEntry{name: "sub/", typeflag: tar.TypeDir},
+				tarEntry{name: "link", link: "sub", typeflag: tar.TypeSymlink},
+				tarEntry{name: "link/evil.txt", body: "evil"},
+			),
+			errText: "entry link/evil.txt is below a symbolic link",
+		},
+		{
+			name:    "tar directory below a symbolic link",
+			archive: "a.tar",
+			data: buildTar(t,
+				tarEntry{name: "link", link: ".", typeflag: tar.TypeSymlink},
+				tarEntry{name: "link/dir/", typeflag: tar.TypeDir},
+			),
+			errText: "is below a symbolic link",
+		},
+		{
+			name:    "tar hard link",
+			archive: "a.tar",
+			data:    buildTar(t, tarEntry{name: "a.txt", body: "alpha"}, tarEntry{name: "b.txt", link: "a.txt", typeflag: tar.TypeLink}),
+			want:    map[string]string{"a.txt": "alpha", "b.txt": "alpha"},
+		},
+		{
+			name:    "tar hard link with parent directories",
+			archive: "a.tar",
+			data:    buildTar(t, tarEntry{name: "passwd", link: "../../etc/passwd", typeflag: tar.TypeLink}),
+			err:     fs.ErrNotExist,
+		},
+		{
+			name:    "tar hard link below a symbolic link",
+			archive: "a.tar",
+			data: buildTar(t,
+				tarEntry{name: "sub/a.txt", body: "alpha"},
+				tarEntry{name: "link", link: "sub", typeflag: tar.TypeSymlink},
+				tarEntry{name: "b.txt", link: "link/a.txt", typeflag: tar.TypeLink},
+			),
+			errText: "entry link/a.txt is below a symbolic link",
+		},
+		{
+			name:    "tar hard link to a stripped entry",
+			archive: "a.tar",
+			data:    buildTar(t, tarEntry{name: "top/a.txt", body: "alpha"}, tarEntry{name: "top/b.txt", link: "a.txt", typeflag: tar.TypeLink}),
+			options: models.ExtractOptions{StripComponents: 1},
+			errText: "hard link top/b.txt points outside the archive",
+		},
+		{
+			name:    "zip entries with parent directories",
+			archive: "a.zip",
+			data:    writeZipEntries(t, zipEntry{name: "../../evil.txt", body: "evil"}, zipEntry{name: `..\win.txt`, body: "win"}),
+			want:    map[string]string{"evil.txt": "evil", "win.txt": "win"},
+		},
+		{
+			name:    "zip symbolic link with an absolute target",
+			archive: "a.zip",
+			data:    writeZipEntries(t, zipEntry{name: "link", body: "/etc/passwd", mode: symlink}),
+			errText: "symbolic link link has an absolute target",
+		},
+		{
+			name:    "zip symbolic link pointing outside the destination",
+			archive: "a.zip",
+			data:    writeZipEntries(t, zipEntry{name: "dir/link", body: "../../outside", mode: symlink}),
+			errText: "symbolic link dir/link points outside the destination",
+		},
+		{
+			name:    "zip entry below a symbolic link",
+			archive: "a.zip",
+			data: writeZipEntries(t,
+				zipEntry{name: "sub/", mode: fs.ModeDir | 0755},
+				zipEntry{name: "link", body: "sub", mode: symlink},
+				zipEntry{name: "link/evil.txt", body: "evil"},
+			),
+			errText: "entry link/evil.txt is below a symbolic link",
+		},
+		{
+			name:    "streamed tar entry below a symbolic link",
+			archive: "a.tar.gz",
+			data: gzipped(t, buildTar(t,
+				tarEntry{name: "link", link: ".", typeflag: tar.TypeSymlink},
+				tarEntry{name: "link/evil.txt", body: "evil"},
+			)),
+			stream:  true,
+			errText: "entry link/evil.txt is below a symbolic link",
+		},
+		{
+			name:    "entries within the limits",
+			archive: "a.tar",
+			data:    buildTar(t, tarEntry{name: "a.txt", body: "alpha"}, tarEntry{name: "b.txt", body: "beta"}),
+			options: models.ExtractOptions{MaxSize: 9, MaxEntries: 2},
+			want:    map[string]string{"a.txt": "alpha", "b.txt": "beta"},
+		},
+		{
+			name:    "tar with too many entries",
+			archive: "a.tar",
+			data:    buildTar(t, tarEntry{name: "dir/", typeflag: tar.TypeDir}, tarEntry{name: "dir/a.txt", body: "alpha"}, tarEntry{name: "b.txt", body: "beta"}),
+			options: models.ExtractOptions{MaxEntries: 2},
+			errText: "archive has more than the maximum of 2 entries",
+		},
+		{
+			name:    "tar larger than the maximum size",
+			archive: "a.tar",
+			data:    buildTar(t, tarEntry{name: "a.txt", body: "alpha"}, tarEntry{name: "b.txt", body: "beta!"}),
+			options: models.ExtractOptions{MaxSize: 9},
+			errText: "archive exceeds the maximum extracted size of 9 bytes",
+		},
+		{
+			name:    "streamed tar larger than the maximum size",
+			archive: "a.tar.gz",
+			data:    gzipped(t, buildTar(t, tarEntry{name: "zeros", body: strings.Repeat("\x00", 1<<20)})),
+			stream:  true,
+			options: models.ExtractOptions{MaxSize: 1 << 16},
+			errText: "archive exceeds the maximum extracted size of 65536 bytes",
+		},
+		{
+			name:    "hard link copies count against the maximum size",
+			archive: "a.tar",
+			data:    buildTar(t, tarEntry{name: "a.txt", body: "alpha"}, tarEntry{name: "b.txt", link: "a.txt", typeflag: tar.TypeLink}),
+			options: models.ExtractOptions{MaxSize: 9},
+			errText: "archive exceeds the maximum extracted size of 9 bytes",
+		},
+		{
+			name:    "zip with too many entries",
+			archive: "a.zip",
+			data:    writeZipEntries(t, zipEntry{name: "a.txt", body: "alpha"}, zipEntry{name: "b.txt", body: "beta"}),
+			options: models.ExtractOptions{MaxEntries: 1},
+			errText: "archive has more than the maximum of 1 entries",
+		},
+		{
+			name:    "zip larger than the maximum size",
+			archive: "a.zip",
+			data:    writeZipEntries(t, zipEntry{name: "a.txt", body: strings.Repeat("a", 100)}),
+			options: models.ExtractOptions{MaxSize: 99},
+			errText: "archive exceeds the maximum extracted size of 99 bytes",
+		},
+		{
+			name:    "streamed zip with too many entries",
+			archive: "a.zip",
+			data:    writeZipEntries(t, zipEntry{name: "a.txt", body: "alpha"}, zipEntry{name: "b.txt", body: "beta"}),
+			stream:  true,
+			options: models.ExtractOptions{MaxEntries: 1},
+			errText: "archive has more than the maximum of 1 entries",
+		},
+		{
+			name:    "streamed zip larger than the maximum size",
+			archive: "a.zip",
+			data:    writeZipEntries(t, zipEntry{name: "a.txt", body: strings.Repeat("a", 100)}),
+			stream:  true,
+			options: models.ExtractOptions{MaxSize: 99},
+			errText: "archive exceeds the maximum extracted size of 99 bytes",
+		},
+		{
+			name:    "compressed file larger than the maximum size",
+			archive: "data.bin.gz",
+			data:    gzipped(t, bytes.Repeat([]byte{0}, 1<<20)),
+			options: models.ExtractOptions{MaxSize: 1 << 10},
+			errText: "archive exceeds the maximum extracted size of 1024 bytes",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			targetDir := filepath.Join(root, "target")
+			if err := os.Mkdir(targetDir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			e, err := New(&tt.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.stream {
+				err = e.ExtractStream(bytes.NewReader(tt.data), targetDir, tt.archive)
+			} else {
+				if err := os.WriteFile(filepath.Join(targetDir, tt.archive), tt.data, 0644); err != nil {
+					t.Fatal(err)
+				}
+				err = e.ExtractFile(targetDir, tt.archive)
+			}
+			switch {
+			case tt.err != nil:
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("error = %v, want %v", err, tt.err)
+				}
+			case tt.errText != "":
+				if err == nil || !strings.Contains(err.Error(), tt.errText) {
+					t.Fatalf("error = %v, want one containing %q", err, tt.errText)
+				}
+			case err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			default:
+				if got := extracted(t, targetDir); !maps.Equal(got, tt.want) {
+					t.Errorf("extracted %q, want %q", got, tt.want)
+				}
+			}
+
+			// Nothing is written outside the target
+			entries, err := os.ReadDir(root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 1 || entries[0].Name() != "target" {
+				t.Errorf("root holds %v, want only the target", entries)
+			}
+		})
+	}
+}
+
+func TestNewRejectsNegativeLimits(t *testing.T) {
+	tests := []struct {
+		options models.ExtractOptions
+		errText string
+	}{
+		{models.ExtractOptions{MaxSize: -1}, "maxSize must not be negative"},
+		{models.ExtractOptions{MaxEntries: -1}, "maxEntries must not be negative"},
+	}
+
+	for _, tt := range tests {
+		if _, err := New(&tt.options); err == nil || !strings.Contains(err.Error(), tt.errText) {
+			t.Errorf("New(%+v) error = %v, want one containing %q", tt.options, err, tt.errText)
+		}
+	}
+}
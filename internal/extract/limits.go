@@ -0,0 +1,49 @@
+package extract
+
+import (
+	"fmt"
+	"io"
+)
+
+// budget counts what an extraction has written so far against the maxSize and maxEntries
+// options, so that a small archive cannot fill the target
+type budget struct {
+	size    int64
+	entries int
+}
+
+// countEntry counts an extracted entry against the maximum number of entries
+func (e *Extractor) countEntry() error {
+	if e.budget == nil {
+		return nil
+	}
+	e.budget.entries++
+	if e.options.MaxEntries > 0 && e.budget.entries > e.options.MaxEntries {
+		return fmt.Errorf("archive has more than the maximum of %d entries", e.options.MaxEntries)
+	}
+	return nil
+}
+
+// limitContents counts the bytes read from r against the maximum extracted size
+func (e *Extractor) limitContents(r io.Reader) io.Reader {
+	if e.budget == nil || e.options.MaxSize <= 0 {
+		return r
+	}
+	return &budgetReader{reader: r, budget: e.budget, max: e.options.MaxSize}
+}
+
+// budgetReader fails once more than max bytes are extracted in total
+type budgetReader struct {
+	reader io.Reader
+	budget *budget
+	max    int64
+}
+
+func (b *budgetReader) Read(p []byte) (int, error) {
+	n, err := b.reader.Read(p)
+	b.budget.size += int64(n)
+	if b.budget.size > b.max {
+		return n, fmt.Errorf("archive exceeds the maximum extracted size of %d bytes", b.max)
+	}
+	return n, err
+}
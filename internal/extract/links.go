@@ -0,0 +1,170 @@
+package extract
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sharedvolume/volume-syncer/internal/models"
+	"github.com/sharedvolume/volume-syncer/internal/utils"
+)
+
+// maxLinkDepth limits the nesting of dereferenced directory links, which also breaks link cycles
+const maxLinkDepth = 16
+
+// symlinks returns how symbolic links in archives are handled
+func (e *Extractor) symlinks() string {
+	if e.options.FileHandling == nil || e.options.FileHandling.Symlinks == "" {
+		return models.SymlinksPreserve
+	}
+	return e.options.FileHandling.Symlinks
+}
+
+// hardlinks reports whether hard links in archives are preserved instead of copied
+func (e *Extractor) hardlinks() bool {
+	return e.options.FileHandling != nil && e.options.FileHandling.Hardlinks
+}
+
+// copyContents copies r to the file, keeping runs of zeros as holes when sparse files are requested
+func (e *Extractor) copyContents(dst *os.File, r io.Reader) (int64, error) {
+	r = e.limitContents(r)
+	if e.options.FileHandling != nil && e.options.FileHandling.Sparse {
+		return utils.CopySparse(dst, r)
+	}
+	return io.Copy(dst, r)
+}
+
+// copyHardLink writes a copy of the previously extracted file linked by a hard link entry
+func (e *Extractor) copyHardLink(destDir, name, targetPath string) error {
+	file, err := os.Open(targetPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("hard link %s does not point to a regular file", name)
+	}
+	return e.writeFile(destDir, name, info.Mode(), info.ModTime(), file)
+}
+
+// followLinks replaces the extracted symbolic links with copies of the files or directories they point to.
+// Links whose target is missing are removed, since a dereferenced copy would not contain them either.
+func (e *Extractor) followLinks(destDir string, links []string) error {
+	root, err := filepath.EvalSymlinks(destDir)
+	if err != nil {
+		return err
+	}
+
+	count := 0
+	for _, name := range links {
+		localPath := filepath.Join(destDir, filepath.FromSlash(name))
+		resolved, err := resolveLink(root, localPath)
+		if errors.Is(err, fs.ErrNotExist) {
+			log.Printf("[EXTRACT] Removing dangling symbolic link %s", name)
+			if err := os.Remove(localPath); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("symbolic link %s: %w", name, err)
+		}
+
+		tmp := localPath + ".extract-link"
+		os.RemoveAll(tmp)
+		if err := e.copyResolved(root, resolved, tmp, 0); err != nil {
+			os.RemoveAll(tmp)
+			return fmt.Errorf("failed to dereference symbolic link %s: %w", name, err)
+		}
+		if err := os.Remove(localPath); err != nil {
+			os.RemoveAll(tmp)
+			return err
+		}
+		if err := os.Rename(tmp, localPath); err != nil {
+			return err
+		}
+		count++
+	}
+	log.Printf("[EXTRACT] Dereferenced %d symbolic links", count)
+	return nil
+}
+
+// copyResolved copies a file or directory, dereferencing the symbolic links below it
+func (e *Extractor) copyResolved(root, src, dst string, depth int) error {
+	if depth > maxLinkDepth {
+		return errors.New("too many levels of symbolic links")
+	}
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case info.Mode().IsRegular():
+		file, err := os.Open(src)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, info.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		if _, err := e.copyContents(out, file); err != nil {
+			out.Close()
+			return err
+		}
+		if err := out.Close(); err != nil {
+			return err
+		}
+		return os.Chtimes(dst, info.ModTime(), info.ModTime())
+	case info.IsDir():
+		if err := os.MkdirAll(dst, info.Mode().Perm()|0700); err != nil {
+			return err
+		}
+		entries, err := os.ReadDir(src)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			child := filepath.Join(src, entry.Name())
+			nextDepth := depth
+			if entry.Type()&fs.ModeSymlink != 0 {
+				if child, err = resolveLink(root, child); errors.Is(err, fs.ErrNotExist) {
+					continue
+				} else if err != nil {
+					return err
+				}
+				nextDepth++
+			}
+			if err := e.copyResolved(root, child, filepath.Join(dst, entry.Name()), nextDepth); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("%s is not a regular file or directory", src)
+	}
+}
+
+// resolveLink returns the final target of a symbolic link, which must be inside the root
+func resolveLink(root, linkPath string) (string, error) {
+	resolved, err := filepath.EvalSymlinks(linkPath)
+	if err != nil {
+		return "", err
+	}
+	if resolved != root && !strings.HasPrefix(resolved, root+string(filepath.Separator)) {
+		return "", errors.New("target is outside the destination")
+	}
+	return resolved, nil
+}
@@ -0,0 +1,393 @@
+package extract
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"time"
+)
+
+// Signatures of the zip records
+const (
+	localHeaderSignature   = 0x04034b50
+	centralHeaderSignature = 0x02014b50
+	descriptorSignature    = 0x08074b50
+	endSignature           = 0x06054b50
+	zip64EndSignature      = 0x06064b50
+)
+
+// Flags of the zip local headers
+const (
+	flagEncrypted  = 0x1
+	flagDescriptor = 0x8 // sizes and checksum follow the contents in a data descriptor
+)
+
+// Extra fields of the zip local headers
+const (
+	zip64ExtraID     = 0x0001
+	timestampExtraID = 0x5455
+)
+
+// localHeader is the fixed part of a zip local file header, after its signature
+type localHeader struct {
+	Version          uint16
+	Flags            uint16
+	Method           uint16
+	ModTime          uint16
+	ModDate          uint16
+	CRC32            uint32
+	CompressedSize   uint32
+	UncompressedSize uint32
+	NameLength       uint16
+	ExtraLength      uint16
+}
+
+// centralHeader is the fixed part of a zip central directory header, after its signature
+type centralHeader struct {
+	CreatorVersion   uint16
+	ReaderVersion    uint16
+	Flags            uint16
+	Method           uint16
+	ModTime          uint16
+	ModDate          uint16
+	CRC32            uint32
+	CompressedSize   uint32
+	UncompressedSize uint32
+	NameLength       uint16
+	ExtraLength      uint16
+	CommentLength    uint16
+	DiskNumberStart  uint16
+	InternalAttrs    uint16
+	ExternalAttrs    uint32
+	Offset           uint32
+}
+
+// extractZipStream unpacks a zip archive read sequentially, e.g. while it is downloaded. Entries
+// are found by their local headers; the modes and symbolic links of the entries are only recorded
+// in the central directory at the end of the archive and are applied once it is read.
+func (e *Extractor) extractZipStream(r io.Reader, destDir string, links *[]string) (int, error) {
+	br := bufio.NewReaderSize(r, 1<<16)
+	written := map[string]bool{} // files written by the extraction, by entry path
+	count := 0
+	for {
+		var signature uint32
+		if err := binary.Read(br, binary.LittleEndian, &signature); err != nil {
+			return count, fmt.Errorf("failed to read zip header: %w", err)
+		}
+		switch signature {
+		case localHeaderSignature:
+			extracted, err := e.streamZipEntry(br, destDir, written)
+			if err != nil {
+				return count, err
+			}
+			if extracted {
+				count++
+			}
+		case centralHeaderSignature, endSignature, zip64EndSignature:
+			if err := e.applyCentralDirectory(br, signature, destDir, written, links); err != nil {
+				return count, err
+			}
+			// The rest of the archive is read, so that size limits and the download cache see all of it
+			if _, err := io.Copy(io.Discard, br); err != nil {
+				return count, err
+			}
+			return count, nil
+		default:
+			return count, fmt.Errorf("invalid zip header signature %#x", signature)
+		}
+	}
+}
+
+// streamZipEntry extracts the entry of the local header and reads up to the next header. Files
+// are written with default permissions until the central directory is read.
+func (e *Extractor) streamZipEntry(br *bufio.Reader, destDir string, written map[string]bool) (bool, error) {
+	var header localHeader
+	if err := binary.Read(br, binary.LittleEndian, &header); err != nil {
+		return false, fmt.Errorf("failed to read zip header: %w", err)
+	}
+	nameExtra := make([]byte, int(header.NameLength)+int(header.ExtraLength))
+	if _, err := io.ReadFull(br, nameExtra); err != nil {
+		return false, fmt.Errorf("failed to read zip header: %w", err)
+	}
+	entryName := string(nameExtra[:header.NameLength])
+	if header.Flags&flagEncrypted != 0 {
+		return false, fmt.Errorf("zip entry %s is encrypted", entryName)
+	}
+
+	compressedSize, size := uint64(header.CompressedSize), uint64(header.UncompressedSize)
+	modTime := msDosTime(header.ModDate, header.ModTime)
+	zip64 := false
+	for extra := nameExtra[header.NameLength:]; len(extra) >= 4; {
+		id, length := binary.LittleEndian.Uint16(extra), int(binary.LittleEndian.Uint16(extra[2:]))
+		if len(extra) < 4+length {
+			break
+		}
+		data := extra[4 : 4+length]
+		extra = extra[4+length:]
+		switch id {
+		case zip64ExtraID:
+			// The 64-bit sizes replace the sizes saturated in the header, in this order
+			zip64 = true
+			if size == 0xFFFFFFFF && len(data) >= 8 {
+				size, data = binary.LittleEndian.Uint64(data), data[8:]
+			}
+			if compressedSize == 0xFFFFFFFF && len(data) >= 8 {
+				compressedSize = binary.LittleEndian.Uint64(data)
+			}
+		case timestampExtraID:
+			if len(data) >= 5 && data[0]&1 != 0 {
+				modTime = time.Unix(int64(int32(binary.LittleEndian.Uint32(data[1:]))), 0)
+			}
+		}
+	}
+
+	described := header.Flags&flagDescriptor != 0
+	var raw io.Reader
+	switch {
+	case !described:
+		raw = io.LimitReader(br, int64(compressedSize))
+	case header.Method == zip.Deflate:
+		// Deflate streams mark their end, the decompressor reads no further through the buffered reader
+		raw = br
+	case header.Method == zip.Store:
+		raw = &storedReader{br: br, zip64: zip64}
+	}
+	var contents io.Reader
+	switch header.Method {
+	case zip.Store:
+		contents = raw
+	case zip.Deflate:
+		decompressor := flate.NewReader(raw)
+		defer decompressor.Close()
+		contents = decompressor
+	default:
+		return false, fmt.Errorf("zip entry %s uses unsupported compression method %d", entryName, header.Method)
+	}
+	checked := &checksumReader{reader: contents, crc: crc32.NewIEEE()}
+
+	extracted := false
+	if name, ok := e.entryPath(entryName); ok {
+		err := e.countEntry()
+		if err != nil {
+			return false, err
+		}
+		if strings.HasSuffix(entryName, "/") {
+			err = e.makeDir(destDir, name)
+		} else {
+			var localPath string
+			var write bool
+			if localPath, err = resolve(destDir, name); err == nil {
+				write, err = e.shouldWrite(localPath, modTime)
+			}
+			if err == nil && write {
+				err = e.writeFile(destDir, name, 0644, modTime, checked)
+				written[name] = true
+			}
+		}
+		if err != nil {
+			return false, err
+		}
+		extracted = true
+	}
+
+	// Skipped entries are read as well, to reach the next header
+	if _, err := io.Copy(io.Discard, checked); err != nil {
+		return false, fmt.Errorf("failed to read zip entry %s: %w", entryName, err)
+	}
+	// Described deflate streams were read up to their end, which is followed by the descriptor
+	if !described || header.Method != zip.Deflate {
+		if _, err := io.Copy(io.Discard, raw); err != nil {
+			return false, fmt.Errorf("failed to read zip entry %s: %w", entryName, err)
+		}
+	}
+	crc := header.CRC32
+	if described {
+		var err error
+		if crc, size, err = readDescriptor(br, zip64); err != nil {
+			return false, fmt.Errorf("failed to read data descriptor of zip entry %s: %w", entryName, err)
+		}
+	}
+	if checked.crc.Sum32() != crc || checked.count != size {
+		return false, fmt.Errorf("zip entry %s: %w", entryName, zip.ErrChecksum)
+	}
+	return extracted, nil
+}
+
+// applyCentralDirectory reads the central directory headers, starting with the one of the
+// signature, and applies the recorded modes to the written files. Files recorded as symbolic
+// links hold the link target and are replaced by the link.
+func (e *Extractor) applyCentralDirectory(br *bufio.Reader, signature uint32, destDir string, written map[string]bool, links *[]string) error {
+	for signature == centralHeaderSignature {
+		var header centralHeader
+		if err := binary.Read(br, binary.LittleEndian, &header); err != nil {
+			return fmt.Errorf("failed to read zip central directory: %w", err)
+		}
+		nameExtra := make([]byte, int(header.NameLength)+int(header.ExtraLength)+int(header.CommentLength))
+		if _, err := io.ReadFull(br, nameExtra); err != nil {
+			return fmt.Errorf("failed to read zip central directory: %w", err)
+		}
+		entryName := string(nameExtra[:header.NameLength])
+
+		if name, ok := e.entryPath(entryName); ok && written[name] {
+			fileHeader := zip.FileHeader{Name: entryName, CreatorVersion: header.CreatorVersion, ExternalAttrs: header.ExternalAttrs}
+			if err := e.applyMode(destDir, name, fileHeader.Mode(), links); err != nil {
+				return fmt.Errorf("failed to extract zip entry %s: %w", entryName, err)
+			}
+		}
+
+		if err := binary.Read(br, binary.LittleEndian, &signature); err != nil {
+			return fmt.Errorf("failed to read zip central directory: %w", err)
+		}
+	}
+	return nil
+}
+
+// applyMode sets the mode of an extracted file, or replaces it by the symbolic link it holds
+func (e *Extractor) applyMode(destDir, name string, mode fs.FileMode, links *[]string) error {
+	localPath, err := resolve(destDir, name)
+	if err != nil {
+		return err
+	}
+	if mode&fs.ModeSymlink == 0 {
+		return os.Chmod(localPath, mode.Perm())
+	}
+
+	file, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	target, err := io.ReadAll(io.LimitReader(file, 4096))
+	file.Close()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(localPath); err != nil {
+		return err
+	}
+	return e.makeSymlink(destDir, name, string(target), links)
+}
+
+// readDescriptor reads the data descriptor following the contents of an entry, whose signature
+// is optional
+func readDescriptor(br *bufio.Reader, zip64 bool) (crc uint32, size uint64, err error) {
+	if err := binary.Read(br, binary.LittleEndian, &crc); err != nil {
+		return 0, 0, err
+	}
+	if crc == descriptorSignature {
+		if err := binary.Read(br, binary.LittleEndian, &crc); err != nil {
+			return 0, 0, err
+		}
+	}
+	if zip64 {
+		var sizes struct{ Compressed, Uncompressed uint64 }
+		err = binary.Read(br, binary.LittleEndian, &sizes)
+		return crc, sizes.Uncompressed, err
+	}
+	var sizes struct{ Compressed, Uncompressed uint32 }
+	err = binary.Read(br, binary.LittleEndian, &sizes)
+	return crc, uint64(sizes.Uncompressed), err
+}
+
+// msDosTime converts the MS-DOS date and time of a zip header, which have no time zone
+func msDosTime(date, t uint16) time.Time {
+	return time.Date(int(date>>9)+1980, time.Month(date>>5&0xf), int(date&0x1f),
+		int(t>>11), int(t>>5&0x3f), int(t&0x1f)*2, 0, time.UTC)
+}
+
+// checksumReader computes the CRC-32 and size of the contents read through it
+type checksumReader struct {
+	reader io.Reader
+	crc    hash.Hash32
+	count  uint64
+}
+
+func (c *checksumReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	c.crc.Write(p[:n])
+	c.count += uint64(n)
+	return n, err
+}
+
+// storedReader reads the contents of an uncompressed entry whose size is only recorded in the data
+// descriptor following it. The contents end at the first descriptor signature whose checksum and
+// size match the contents before it and that is followed by the next header.
+type storedReader struct {
+	br    *bufio.Reader
+	zip64 bool
+	crc   uint32
+	count uint64
+	done  bool
+}
+
+func (s *storedReader) Read(p []byte) (int, error) {
+	if s.done {
+		return 0, io.EOF
+	}
+	// Descriptor signature, checksum, sizes and the signature of the next header
+	tail := 4 + 4 + 8 + 4
+	if s.zip64 {
+		tail += 8
+	}
+	window, err := s.br.Peek(tail)
+	if err == nil {
+		window, _ = s.br.Peek(s.br.Buffered())
+	}
+
+	// Positions that may start a descriptor without enough bytes buffered to check it are kept
+	safe := max(len(window)-tail+1, 0)
+	end := -1
+	var signature [4]byte
+	binary.LittleEndian.PutUint32(signature[:], descriptorSignature)
+	for i := 0; i < safe; i++ {
+		if !bytes.Equal(window[i:i+4], signature[:]) {
+			continue
+		}
+		if s.descriptorAt(window[:i], window[i+4:i+tail]) {
+			end = i
+			break
+		}
+	}
+	if end >= 0 {
+		safe = end
+	}
+	if safe == 0 && end < 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+
+	n := copy(p, window[:safe])
+	s.crc = crc32.Update(s.crc, crc32.IEEETable, p[:n])
+	s.count += uint64(n)
+	s.br.Discard(n)
+	if n == end {
+		s.done = true
+	}
+	if n == 0 && s.done {
+		return 0, io.EOF
+	}
+	return n, nil
+}
+
+// descriptorAt reports whether the descriptor fields match the contents read so far followed by
+// the pending bytes, and are followed by a header signature
+func (s *storedReader) descriptorAt(pending, fields []byte) bool {
+	if crc32.Update(s.crc, crc32.IEEETable, pending) != binary.LittleEndian.Uint32(fields) {
+		return false
+	}
+	size := s.count + uint64(len(pending))
+	var compressed uint64
+	if s.zip64 {
+		compressed = binary.LittleEndian.Uint64(fields[4:])
+	} else {
+		compressed = uint64(binary.LittleEndian.Uint32(fields[4:]))
+	}
+	return compressed == size && bytes.HasPrefix(fields[len(fields)-4:], []byte("PK"))
+}
@@ -0,0 +1,293 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/sharedvolume/volume-syncer/internal/models"
+)
+
+// zipEntry is an entry of an archive built by buildZip, stored without compression
+type zipEntry struct {
+	name       string
+	body       string
+	mode       fs.FileMode // 0644 when unset
+	descriptor bool        // the checksum and sizes follow the contents in a data descriptor
+	zip64      bool        // the sizes are recorded in the zip64 extra field
+	crc        uint32      // recorded checksum instead of the one of the body
+}
+
+// buildZip returns an archive of the entries with a central directory recording their modes
+func buildZip(entries ...zipEntry) []byte {
+	var archive, central bytes.Buffer
+	write := func(buf *bytes.Buffer, values ...any) {
+		for _, value := range values {
+			binary.Write(buf, binary.LittleEndian, value)
+		}
+	}
+	for _, entry := range entries {
+		offset := uint32(archive.Len())
+		crc := entry.crc
+		if crc == 0 {
+			crc = crc32.ChecksumIEEE([]byte(entry.body))
+		}
+		size := uint64(len(entry.body))
+
+		header := localHeader{Version: 20, Method: zip.Store, ModDate: 0x21, NameLength: uint16(len(entry.name))}
+		if entry.descriptor {
+			header.Flags = flagDescriptor
+		} else {
+			header.CRC32, header.CompressedSize, header.UncompressedSize = crc, uint32(size), uint32(size)
+		}
+		var extra []byte
+		if entry.zip64 {
+			header.Version = 45
+			header.CompressedSize, header.UncompressedSize = 0xFFFFFFFF, 0xFFFFFFFF
+			extra = binary.LittleEndian.AppendUint16(extra, zip64ExtraID)
+			extra = binary.LittleEndian.AppendUint16(extra, 16)
+			recorded := size
+			if entry.descriptor {
+				recorded = 0
+			}
+			extra = binary.LittleEndian.AppendUint64(extra, recorded)
+			extra = binary.LittleEndian.AppendUint64(extra, recorded)
+		}
+		header.ExtraLength = uint16(len(extra))
+		write(&archive, uint32(localHeaderSignature), header, []byte(entry.name), extra, []byte(entry.body))
+		if entry.descriptor {
+			write(&archive, uint32(descriptorSignature), crc)
+			if entry.zip64 {
+				write(&archive, size, size)
+			} else {
+				write(&archive, uint32(size), uint32(size))
+			}
+		}
+
+		mode := entry.mode
+		if mode == 0 {
+			mode = 0644
+		}
+		var fileHeader zip.FileHeader
+		fileHeader.SetMode(mode)
+		centralSize := uint32(size)
+		if entry.zip64 {
+			centralSize = 0xFFFFFFFF
+		}
+		write(&central, uint32(centralHeaderSignature), centralHeader{
+			CreatorVersion:   fileHeader.CreatorVersion,
+			ReaderVersion:    header.Version,
+			Flags:            header.Flags,
+			Method:           zip.Store,
+			ModDate:          header.ModDate,
+			CRC32:            crc,
+			CompressedSize:   centralSize,
+			UncompressedSize: centralSize,
+			NameLength:       uint16(len(entry.name)),
+			ExternalAttrs:    fileHeader.ExternalAttrs,
+			Offset:           offset,
+		}, []byte(entry.name))
+	}
+	archive.Write(central.Bytes())
+	write(&archive, uint32(endSignature), make([]byte, 18))
+	return archive.Bytes()
+}
+
+// writeZip returns an archive written by archive/zip, which deflates the entries and follows
+// them with data descriptors
+func writeZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var archive bytes.Buffer
+	w := zip.NewWriter(&archive)
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(f, files[name])
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return archive.Bytes()
+}
+
+// extracted returns the contents of the files below the directory by relative path, symbolic
+// links as "-> target"
+func extracted(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	files := map[string]string{}
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, p)
+		if d.Type()&fs.ModeSymlink != 0 {
+			target, err := os.Readlink(p)
+			files[filepath.ToSlash(rel)] = "-> " + target
+			return err
+		}
+		data, err := os.ReadFile(p)
+		files[filepath.ToSlash(rel)] = string(data)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestExtractZipStream(t *testing.T) {
+	deflated := map[string]string{"a.txt": "alpha\n", "dir/b.txt": strings.Repeat("beta ", 1000)}
+	// Descriptor signatures inside the contents, some of them across the read buffer boundary
+	signatures := strings.Repeat("data PK\x07\x08 more PK\x03\x04 ", 5000)
+	stored := zipEntry{name: "stored.bin", body: signatures, descriptor: true}
+	truncatedDescriptor := buildZip(stored)
+	truncatedDeflated := writeZip(t, deflated)
+	truncatedDeflated = truncatedDeflated[:bytes.Index(truncatedDeflated, []byte("dir/b.txt"))+len("dir/b.txt")+4]
+
+	tests := []struct {
+		name    string
+		archive []byte
+		want    map[string]string
+		err     error  // matched with errors.Is
+		errText string // contained in the error
+	}{
+		{
+			name:    "deflated entries with data descriptors",
+			archive: writeZip(t, deflated),
+			want:    deflated,
+		},
+		{
+			name:    "stored entry",
+			archive: buildZip(zipEntry{name: "a.txt", body: "alpha"}, zipEntry{name: "dir/", mode: fs.ModeDir | 0755}),
+			want:    map[string]string{"a.txt": "alpha"},
+		},
+		{
+			name:    "stored entry with a data descriptor signature in its contents",
+			archive: buildZip(stored, zipEntry{name: "next.txt", body: "next"}),
+			want:    map[string]string{"stored.bin": signatures, "next.txt": "next"},
+		},
+		{
+			name:    "stored entry with a data descriptor and no contents",
+			archive: buildZip(zipEntry{name: "empty.txt", descriptor: true}, zipEntry{name: "next.txt", body: "next"}),
+			want:    map[string]string{"empty.txt": "", "next.txt": "next"},
+		},
+		{
+			name:    "zip64 sizes",
+			archive: buildZip(zipEntry{name: "a.txt", body: "alpha", zip64: true}),
+			want:    map[string]string{"a.txt": "alpha"},
+		},
+		{
+			name:    "zip64 sizes in a data descriptor",
+			archive: buildZip(zipEntry{name: "stored.bin", body: signatures, descriptor: true, zip64: true}, zipEntry{name: "next.txt", body: "next"}),
+			want:    map[string]string{"stored.bin": signatures, "next.txt": "next"},
+		},
+		{
+			name:    "crc mismatch",
+			archive: buildZip(zipEntry{name: "a.txt", body: "alpha", crc: 0xDEADBEEF}),
+			err:     zip.ErrChecksum,
+		},
+		{
+			name: "entry paths with parent directories",
+			archive: buildZip(
+				zipEntry{name: "../../evil.txt", body: "evil"},
+				zipEntry{name: "dir/../../up.txt", body: "up"},
+				zipEntry{name: `..\win.txt`, body: "win"},
+			),
+			want: map[string]string{"evil.txt": "evil", "up.txt": "up", "win.txt": "win"},
+		},
+		{
+			name:    "symbolic link",
+			archive: buildZip(zipEntry{name: "a.txt", body: "alpha"}, zipEntry{name: "dir/link", body: "../a.txt", mode: fs.ModeSymlink | 0777}),
+			want:    map[string]string{"a.txt": "alpha", "dir/link": "-> ../a.txt"},
+		},
+		{
+			name:    "symbolic link pointing outside the destination",
+			archive: buildZip(zipEntry{name: "dir/link", body: "../../outside", mode: fs.ModeSymlink | 0777}),
+			errText: "points outside the destination",
+		},
+		{
+			name:    "symbolic link with an absolute target",
+			archive: buildZip(zipEntry{name: "link", body: "/etc/passwd", mode: fs.ModeSymlink | 0777}),
+			errText: "has an absolute target",
+		},
+		{
+			name:    "truncated stored entry",
+			archive: buildZip(zipEntry{name: "a.txt", body: "alpha"})[:36],
+			err:     zip.ErrChecksum,
+		},
+		{
+			name:    "truncated stored entry with a data descriptor",
+			archive: truncatedDescriptor[:len(truncatedDescriptor)/2],
+			err:     io.ErrUnexpectedEOF,
+		},
+		{
+			name:    "truncated deflated entry",
+			archive: truncatedDeflated,
+			err:     io.ErrUnexpectedEOF,
+		},
+		{
+			name:    "truncated before the central directory",
+			archive: buildZip(zipEntry{name: "a.txt", body: "alpha"})[:30+len("a.txt")+len("alpha")],
+			err:     io.EOF,
+		},
+		{
+			name:    "invalid header signature",
+			archive: []byte("not a zip archive"),
+			errText: "invalid zip header signature",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			destDir := filepath.Join(root, "dest")
+			if err := os.Mkdir(destDir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			e, err := New(&models.ExtractOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var links []string
+			_, err = e.extractZipStream(bytes.NewReader(tt.archive), destDir, &links)
+			switch {
+			case tt.err != nil:
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("error = %v, want %v", err, tt.err)
+				}
+			case tt.errText != "":
+				if err == nil || !strings.Contains(err.Error(), tt.errText) {
+					t.Fatalf("error = %v, want one containing %q", err, tt.errText)
+				}
+			case err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			default:
+				if got := extracted(t, destDir); !maps.Equal(got, tt.want) {
+					t.Errorf("extracted %q, want %q", got, tt.want)
+				}
+			}
+
+			// Nothing is written outside the destination
+			entries, err := os.ReadDir(root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 1 || entries[0].Name() != "dest" {
+				t.Errorf("root holds %v, want only the destination", entries)
+			}
+		})
+	}
+}
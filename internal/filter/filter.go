@@ -0,0 +1,198 @@
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/sharedvolume/volume-syncer/internal/models"
+)
+
+// Matcher decides which files of a source are synced. A nil Matcher matches every file.
+type Matcher struct {
+	include     []*pattern
+	exclude     []*pattern
+	regex       *regexp.Regexp
+	maxFileSize int64
+}
+
+// pattern is a compiled glob pattern
+type pattern struct {
+	re       *regexp.Regexp
+	anchored bool // matched against the path from the root instead of single path components
+	dirOnly  bool // only matches directories (pattern ending in "/")
+}
+
+// IsEmpty reports whether the filters select every file
+func IsEmpty(filters *models.Filters) bool {
+	return filters == nil ||
+		(len(filters.Include) == 0 && len(filters.Exclude) == 0 && filters.Regex == "" && filters.MaxFileSize == 0)
+}
+
+// New compiles the filters; nil is returned when they select every file
+func New(filters *models.Filters) (*Matcher, error) {
+	if IsEmpty(filters) {
+		return nil, nil
+	}
+	if filters.MaxFileSize < 0 {
+		return nil, fmt.Errorf("maxFileSize must not be negative")
+	}
+
+	m := &Matcher{maxFileSize: filters.MaxFileSize}
+	for _, glob := range filters.Include {
+		p, err := compileGlob(glob)
+		if err != nil {
+			return nil, fmt.Errorf("invalid include pattern %q: %w", glob, err)
+		}
+		m.include = append(m.include, p)
+	}
+	for _, glob := range filters.Exclude {
+		p, err := compileGlob(glob)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exclude pattern %q: %w", glob, err)
+		}
+		m.exclude = append(m.exclude, p)
+	}
+	if filters.Regex != "" {
+		re, err := regexp.Compile(filters.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex: %w", err)
+		}
+		m.regex = re
+	}
+	return m, nil
+}
+
+// MatchPath reports whether the file at the slash separated path relative to the source root
+// passes the include, exclude and regex filters
+func (m *Matcher) MatchPath(relPath string) bool {
+	if m == nil {
+		return true
+	}
+	relPath = strings.Trim(relPath, "/")
+
+	for _, p := range m.exclude {
+		if p.match(relPath) {
+			return false
+		}
+	}
+	if len(m.include) > 0 {
+		included := false
+		for _, p := range m.include {
+			if p.match(relPath) {
+				included = true
+				break
+			}
+		}
+		if !included {
+			return false
+		}
+	}
+	if m.regex != nil && !m.regex.MatchString(relPath) {
+		return false
+	}
+	return true
+}
+
+// Match reports whether the file passes all filters, including the file size limit
+func (m *Matcher) Match(relPath string, size int64) bool {
+	if m == nil {
+		return true
+	}
+	if m.maxFileSize > 0 && size > m.maxFileSize {
+		return false
+	}
+	return m.MatchPath(relPath)
+}
+
+// MaxFileSize returns the size limit in bytes, or 0 when files of any size are synced
+func (m *Matcher) MaxFileSize() int64 {
+	if m == nil {
+		return 0
+	}
+	return m.maxFileSize
+}
+
+// HasRegex reports whether a regular expression filter is configured
+func (m *Matcher) HasRegex() bool {
+	return m != nil && m.regex != nil
+}
+
+// match reports whether the pattern matches the file or one of its parent directories
+func (p *pattern) match(relPath string) bool {
+	parts := strings.Split(relPath, "/")
+	for i := range parts {
+		isDir := i < len(parts)-1
+		if p.dirOnly && !isDir {
+			continue
+		}
+		candidate := parts[i]
+		if p.anchored {
+			candidate = strings.Join(parts[:i+1], "/")
+		}
+		if p.re.MatchString(candidate) {
+			return true
+		}
+	}
+	return false
+}
+
+// compileGlob translates a glob to a regular expression. Patterns containing a slash
+// are anchored at the source root; other patterns match any single path component.
+func compileGlob(glob string) (*pattern, error) {
+	if strings.TrimSpace(glob) == "" {
+		return nil, fmt.Errorf("pattern must not be empty")
+	}
+
+	p := &pattern{}
+	if strings.HasSuffix(glob, "/") {
+		p.dirOnly = true
+		glob = strings.TrimRight(glob, "/")
+	}
+	if strings.Contains(glob, "/") {
+		p.anchored = true
+		glob = strings.TrimLeft(glob, "/")
+	}
+
+	var expr strings.Builder
+	expr.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case c == '*' && strings.HasPrefix(glob[i:], "**/"):
+			expr.WriteString("(.*/)?")
+			i += 2
+		case c == '*' && strings.HasPrefix(glob[i:], "**"):
+			expr.WriteString(".*")
+			i++
+		case c == '*':
+			expr.WriteString("[^/]*")
+		case c == '?':
+			expr.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated character class")
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			expr.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(glob):
+			i++
+			expr.WriteString(regexp.QuoteMeta(string(glob[i])))
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	expr.WriteString("$")
+
+	re, err := regexp.Compile(expr.String())
+	if err != nil {
+		return nil, err
+	}
+	p.re = re
+	return p, nil
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2025 SharedVolume
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sharedvolume/volume-syncer/internal/audit"
+	"github.com/sharedvolume/volume-syncer/internal/models"
+)
+
+// Keys of the values the handlers pass to the audit middleware
+const (
+	auditJobKey   = "audit.jobId" // job created by the request
+	auditAdminKey = "audit.admin" // the request presented the admin token
+)
+
+// defaultAuditLimit is the number of entries the audit endpoint returns unless the request asks
+// for another number
+const defaultAuditLimit = 100
+
+// Audit records every API call and access to the debug endpoints in the audit log once it was
+// handled: the client, the method and path without the query, the status and the job the call
+// created
+func Audit(auditLog *audit.Log) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path := c.Request.URL.Path
+		if auditLog == nil || (!strings.HasPrefix(path, "/api/") && !strings.HasPrefix(path, "/debug/")) {
+			c.Next()
+			return
+		}
+		started := time.Now()
+		c.Next()
+		auditLog.Record(models.AuditEntry{
+			Time:       started.UTC(),
+			Event:      models.AuditEventAPI,
+			Actor:      c.ClientIP(),
+			Admin:      c.GetBool(auditAdminKey),
+			UserAgent:  c.Request.UserAgent(),
+			Method:     c.Request.Method,
+			Path:       path,
+			Status:     c.Writer.Status(),
+			JobID:      c.GetString(auditJobKey),
+			DurationMs: time.Since(started).Milliseconds(),
+		})
+	}
+}
+
+// ListAudit handles requests for the recent entries of the audit log, filtered by the event, job
+// and time given in the query
+func (h *SyncHandler) ListAudit(c *gin.Context) {
+	log.Printf("[SYNC HANDLER] Audit log requested from %s", c.ClientIP())
+	filter := audit.Filter{Event: c.Query("event"), JobID: c.Query("jobId"), Limit: defaultAuditLimit}
+	respondInvalid := func(message string) {
+		c.JSON(http.StatusBadRequest, models.AuditResponse{Status: "error", Error: message, Timestamp: time.Now().UTC()})
+	}
+	if filter.Event != "" && filter.Event != models.AuditEventAPI && filter.Event != models.AuditEventSync {
+		respondInvalid("event must be api or sync")
+		return
+	}
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			respondInvalid("limit must be a positive number")
+			return
+		}
+		filter.Limit = limit
+	}
+	if value := c.Query("since"); value != "" {
+		since, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			respondInvalid("since must be an RFC 3339 time such as 2025-01-02T15:04:05Z")
+			return
+		}
+		filter.Since = since
+	}
+
+	c.JSON(http.StatusOK, models.AuditResponse{
+		Status:    "found",
+		Entries:   h.syncService.AuditLog().Recent(filter),
+		Timestamp: time.Now().UTC(),
+	})
+}
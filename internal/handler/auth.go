@@ -0,0 +1,60 @@
+/*
+Copyright 2025 SharedVolume
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sharedvolume/volume-syncer/internal/models"
+	syncerrors "github.com/sharedvolume/volume-syncer/pkg/errors"
+)
+
+// RequireToken guards administrative endpoints with a bearer token. Without a configured token
+// the endpoints are disabled.
+func RequireToken(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			log.Printf("[SYNC HANDLER] ERROR: %s %s refused, no admin token is configured", c.Request.Method, c.FullPath())
+			c.AbortWithStatusJSON(http.StatusForbidden, models.SyncResponse{
+				Status:    "error",
+				Error:     "administrative endpoints are disabled, SYNC_ADMIN_TOKEN is not set",
+				ErrorCode: syncerrors.CodeAuthFailed,
+				Timestamp: time.Now().UTC(),
+			})
+			return
+		}
+		provided, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			log.Printf("[SYNC HANDLER] ERROR: Unauthorized %s %s from %s", c.Request.Method, c.FullPath(), c.ClientIP())
+			c.Header("WWW-Authenticate", "Bearer")
+			c.AbortWithStatusJSON(http.StatusUnauthorized, models.SyncResponse{
+				Status:    "error",
+				Error:     "missing or invalid admin token",
+				ErrorCode: syncerrors.CodeAuthFailed,
+				Timestamp: time.Now().UTC(),
+			})
+			return
+		}
+		c.Set(auditAdminKey, true)
+		c.Next()
+	}
+}
@@ -0,0 +1,166 @@
+/*
+Copyright 2025 SharedVolume
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sharedvolume/volume-syncer/internal/credentials"
+	"github.com/sharedvolume/volume-syncer/internal/models"
+	"github.com/sharedvolume/volume-syncer/internal/retry"
+	syncerrors "github.com/sharedvolume/volume-syncer/pkg/errors"
+)
+
+// ListCredentials handles requests for the stored credentials, which are listed without their data
+func (h *SyncHandler) ListCredentials(c *gin.Context) {
+	log.Printf("[SYNC HANDLER] Credentials requested from %s", c.ClientIP())
+	c.JSON(http.StatusOK, models.CredentialsResponse{
+		Credentials: h.syncService.ListCredentials(),
+		Timestamp:   time.Now().UTC(),
+	})
+}
+
+// GetCredential handles requests for a single stored credential, returned without its data
+func (h *SyncHandler) GetCredential(c *gin.Context) {
+	id := c.Param("id")
+	log.Printf("[SYNC HANDLER] Credential %s requested from %s", id, c.ClientIP())
+
+	credential, ok := h.syncService.GetCredential(id)
+	if !ok {
+		respondCredentialNotFound(c, id)
+		return
+	}
+	c.JSON(http.StatusOK, models.CredentialResponse{Status: "found", Credential: &credential, Timestamp: time.Now().UTC()})
+}
+
+// CreateCredential handles requests to store a new credential
+func (h *SyncHandler) CreateCredential(c *gin.Context) {
+	log.Printf("[SYNC HANDLER] Credential creation requested from %s", c.ClientIP())
+
+	var credential models.Credential
+	if err := c.ShouldBindJSON(&credential); err != nil {
+		respondInvalidCredential(c, err)
+		return
+	}
+	h.saveCredential(c, &credential, false)
+}
+
+// PutCredential handles requests to create or replace the credential identified in the path,
+// which rotates it for every source referencing it
+func (h *SyncHandler) PutCredential(c *gin.Context) {
+	id := c.Param("id")
+	log.Printf("[SYNC HANDLER] Credential %s update requested from %s", id, c.ClientIP())
+
+	var credential models.Credential
+	if err := c.ShouldBindJSON(&credential); err != nil {
+		respondInvalidCredential(c, err)
+		return
+	}
+	if credential.ID != "" && credential.ID != id {
+		respondInvalidCredential(c, errors.New("credential ID does not match the path"))
+		return
+	}
+	credential.ID = id
+	h.saveCredential(c, &credential, true)
+}
+
+// saveCredential stores the parsed credential and answers with it, without its data
+func (h *SyncHandler) saveCredential(c *gin.Context, credential *models.Credential, replace bool) {
+	saved, created, err := h.syncService.SaveCredential(c.Request.Context(), credential, replace)
+	if errors.Is(err, credentials.ErrExists) {
+		log.Printf("[SYNC HANDLER] ERROR: Credential %s already exists", credential.ID)
+		c.JSON(http.StatusConflict, models.CredentialResponse{
+			Status:    "error",
+			Error:     err.Error(),
+			ErrorCode: syncerrors.CodeValidation,
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+	if err != nil {
+		log.Printf("[SYNC HANDLER] ERROR: Failed to store credential %s: %v", credential.ID, err)
+		response := models.CredentialResponse{
+			Status:    "error",
+			Error:     "invalid credential",
+			Details:   err.Error(),
+			Timestamp: time.Now().UTC(),
+		}
+		response.ErrorCode, _ = retry.Classify(err)
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	status, code := "replaced", http.StatusOK
+	if created {
+		status, code = "created", http.StatusCreated
+	}
+	log.Printf("[SYNC HANDLER] Credential %s %s", saved.ID, status)
+	c.JSON(code, models.CredentialResponse{Status: status, Credential: &saved, Timestamp: time.Now().UTC()})
+}
+
+// DeleteCredential handles requests to remove a stored credential
+func (h *SyncHandler) DeleteCredential(c *gin.Context) {
+	id := c.Param("id")
+	log.Printf("[SYNC HANDLER] Credential %s deletion requested from %s", id, c.ClientIP())
+
+	err := h.syncService.DeleteCredential(id)
+	if errors.Is(err, credentials.ErrNotFound) {
+		respondCredentialNotFound(c, id)
+		return
+	}
+	if err != nil {
+		log.Printf("[SYNC HANDLER] ERROR: Failed to delete credential %s: %v", id, err)
+		c.JSON(http.StatusInternalServerError, models.CredentialResponse{
+			Status:    "error",
+			Error:     err.Error(),
+			ErrorCode: syncerrors.CodeUnknown,
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, models.CredentialResponse{Status: "deleted", Timestamp: time.Now().UTC()})
+}
+
+// respondCredentialNotFound answers a request for a credential that does not exist
+func respondCredentialNotFound(c *gin.Context, id string) {
+	log.Printf("[SYNC HANDLER] ERROR: Credential %s not found", id)
+	c.JSON(http.StatusNotFound, models.CredentialResponse{
+		Status:    "error",
+		Error:     "credential not found",
+		ErrorCode: syncerrors.CodeNotFound,
+		Timestamp: time.Now().UTC(),
+	})
+}
+
+// respondInvalidCredential answers a credential request whose body could not be parsed
+func respondInvalidCredential(c *gin.Context, err error) {
+	if tooLarge(c, err) {
+		return
+	}
+	log.Printf("[SYNC HANDLER] ERROR: Invalid credential format: %v", err)
+	c.JSON(http.StatusBadRequest, models.CredentialResponse{
+		Status:    "error",
+		Error:     "invalid credential format",
+		ErrorCode: syncerrors.CodeValidation,
+		Details:   err.Error(),
+		Timestamp: time.Now().UTC(),
+	})
+}
@@ -0,0 +1,55 @@
+/*
+Copyright 2025 SharedVolume
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"expvar"
+	"log"
+	"net/http/pprof"
+	"runtime"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	expvar.Publish("goroutines", expvar.Func(func() any { return runtime.NumGoroutine() }))
+}
+
+// Pprof serves the runtime profiles of net/http/pprof below /debug/pprof/, e.g. the heap and
+// goroutine profiles, a CPU profile of the given seconds or an execution trace
+func Pprof(c *gin.Context) {
+	log.Printf("[SYNC HANDLER] Profile %s requested from %s", c.Param("profile"), c.ClientIP())
+	switch c.Param("profile") {
+	case "/cmdline":
+		pprof.Cmdline(c.Writer, c.Request)
+	case "/profile":
+		pprof.Profile(c.Writer, c.Request)
+	case "/symbol":
+		pprof.Symbol(c.Writer, c.Request)
+	case "/trace":
+		pprof.Trace(c.Writer, c.Request)
+	default:
+		// Named profiles such as /heap or /goroutine, and the index at /
+		pprof.Index(c.Writer, c.Request)
+	}
+}
+
+// ExpVar serves the variables published with expvar as JSON: the memory statistics of the runtime,
+// the command line and the number of goroutines
+func ExpVar(c *gin.Context) {
+	expvar.Handler().ServeHTTP(c.Writer, c.Request)
+}
@@ -0,0 +1,56 @@
+/*
+Copyright 2025 SharedVolume
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sharedvolume/volume-syncer/internal/models"
+	"github.com/sharedvolume/volume-syncer/internal/readiness"
+)
+
+// Liveness handles liveness probes, which only tell that the server answers requests
+func (h *SyncHandler) Liveness(c *gin.Context) {
+	paused, queued := h.syncService.QueueState()
+	c.JSON(http.StatusOK, models.HealthResponse{Status: "alive", Paused: paused, Queued: queued, Drifted: h.syncService.DriftedTargets(), Timestamp: time.Now().UTC()})
+}
+
+// Readiness returns the handler of readiness probes, which checks the dependencies of the syncs
+// and answers 503 while one of them fails
+func Readiness(checker *readiness.Checker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		checks, ready := checker.Check()
+		response := models.ReadinessResponse{Status: "ready", Checks: checks, Timestamp: time.Now().UTC()}
+		if !ready {
+			var failed []string
+			for _, check := range checks {
+				if check.Status == models.CheckFailed {
+					failed = append(failed, check.Name+": "+check.Error)
+				}
+			}
+			log.Printf("[SYNC HANDLER] WARNING: Not ready, %s", strings.Join(failed, "; "))
+			response.Status = "not ready"
+			c.JSON(http.StatusServiceUnavailable, response)
+			return
+		}
+		c.JSON(http.StatusOK, response)
+	}
+}
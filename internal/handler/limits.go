@@ -0,0 +1,69 @@
+/*
+Copyright 2025 SharedVolume
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sharedvolume/volume-syncer/internal/models"
+	syncerrors "github.com/sharedvolume/volume-syncer/pkg/errors"
+)
+
+// LimitBody refuses request bodies larger than maxBytes: bodies announcing a larger size are
+// refused right away, others fail to be read once they exceed it. A limit of 0 disables it.
+func LimitBody(maxBytes int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if maxBytes <= 0 {
+			c.Next()
+			return
+		}
+		if c.Request.ContentLength > maxBytes {
+			respondTooLarge(c, maxBytes)
+			c.Abort()
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes)
+		c.Next()
+	}
+}
+
+// tooLarge answers the request when reading its body failed for exceeding the limit. It returns
+// true when the request was answered.
+func tooLarge(c *gin.Context, err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	if !errors.As(err, &maxBytesErr) {
+		return false
+	}
+	respondTooLarge(c, maxBytesErr.Limit)
+	return true
+}
+
+// respondTooLarge answers a request whose body exceeds the limit
+func respondTooLarge(c *gin.Context, maxBytes int64) {
+	log.Printf("[SYNC HANDLER] ERROR: Body of %s %s from %s exceeds %d bytes", c.Request.Method, c.FullPath(), c.ClientIP(), maxBytes)
+	c.JSON(http.StatusRequestEntityTooLarge, models.SyncResponse{
+		Status:    "error",
+		Error:     fmt.Sprintf("request body exceeds %d bytes, see SYNC_MAX_REQUEST_BODY", maxBytes),
+		ErrorCode: syncerrors.CodeValidation,
+		Timestamp: time.Now().UTC(),
+	})
+}
@@ -0,0 +1,205 @@
+/*
+Copyright 2025 SharedVolume
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sharedvolume/volume-syncer/internal/models"
+	"github.com/sharedvolume/volume-syncer/internal/profiles"
+	"github.com/sharedvolume/volume-syncer/internal/retry"
+	"github.com/sharedvolume/volume-syncer/internal/service"
+	syncerrors "github.com/sharedvolume/volume-syncer/pkg/errors"
+)
+
+// ListProfiles handles requests for the sync profiles
+func (h *SyncHandler) ListProfiles(c *gin.Context) {
+	log.Printf("[SYNC HANDLER] Sync profiles requested from %s", c.ClientIP())
+	c.JSON(http.StatusOK, models.SyncProfilesResponse{
+		Profiles:  h.syncService.ListProfiles(),
+		Timestamp: time.Now().UTC(),
+	})
+}
+
+// GetProfile handles requests for a single sync profile
+func (h *SyncHandler) GetProfile(c *gin.Context) {
+	name := c.Param("name")
+	log.Printf("[SYNC HANDLER] Sync profile %s requested from %s", name, c.ClientIP())
+
+	profile, ok := h.syncService.GetProfile(name)
+	if !ok {
+		respondProfileNotFound(c, name)
+		return
+	}
+	c.JSON(http.StatusOK, models.SyncProfileResponse{Status: "found", Profile: &profile, Timestamp: time.Now().UTC()})
+}
+
+// CreateProfile handles requests to store a new sync profile
+func (h *SyncHandler) CreateProfile(c *gin.Context) {
+	log.Printf("[SYNC HANDLER] Sync profile creation requested from %s", c.ClientIP())
+
+	var profile models.SyncProfile
+	if err := c.ShouldBindJSON(&profile); err != nil {
+		respondInvalidProfile(c, err)
+		return
+	}
+	h.saveProfile(c, &profile, false)
+}
+
+// PutProfile handles requests to create or replace the sync profile named in the path
+func (h *SyncHandler) PutProfile(c *gin.Context) {
+	name := c.Param("name")
+	log.Printf("[SYNC HANDLER] Sync profile %s update requested from %s", name, c.ClientIP())
+
+	var profile models.SyncProfile
+	if err := c.ShouldBindJSON(&profile); err != nil {
+		respondInvalidProfile(c, err)
+		return
+	}
+	if profile.Name != "" && profile.Name != name {
+		respondInvalidProfile(c, errors.New("profile name does not match the path"))
+		return
+	}
+	profile.Name = name
+	h.saveProfile(c, &profile, true)
+}
+
+// saveProfile stores the parsed profile and answers with it
+func (h *SyncHandler) saveProfile(c *gin.Context, profile *models.SyncProfile, replace bool) {
+	saved, created, err := h.syncService.SaveProfile(profile, replace)
+	if errors.Is(err, profiles.ErrExists) {
+		log.Printf("[SYNC HANDLER] ERROR: Sync profile %s already exists", profile.Name)
+		c.JSON(http.StatusConflict, models.SyncProfileResponse{
+			Status:    "error",
+			Error:     err.Error(),
+			ErrorCode: syncerrors.CodeValidation,
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+	if err != nil {
+		log.Printf("[SYNC HANDLER] ERROR: Failed to store sync profile %s: %v", profile.Name, err)
+		response := models.SyncProfileResponse{
+			Status:    "error",
+			Error:     "invalid profile",
+			Details:   err.Error(),
+			Timestamp: time.Now().UTC(),
+		}
+		response.ErrorCode, _ = retry.Classify(err)
+		status := http.StatusBadRequest
+		var invalidErr *service.InvalidRequestError
+		if errors.As(err, &invalidErr) {
+			status, response.Errors = http.StatusUnprocessableEntity, invalidErr.Errors
+		}
+		c.JSON(status, response)
+		return
+	}
+
+	status, code := "replaced", http.StatusOK
+	if created {
+		status, code = "created", http.StatusCreated
+	}
+	log.Printf("[SYNC HANDLER] Sync profile %s %s", saved.Name, status)
+	c.JSON(code, models.SyncProfileResponse{Status: status, Profile: &saved, Timestamp: time.Now().UTC()})
+}
+
+// DeleteProfile handles requests to remove a sync profile
+func (h *SyncHandler) DeleteProfile(c *gin.Context) {
+	name := c.Param("name")
+	log.Printf("[SYNC HANDLER] Sync profile %s deletion requested from %s", name, c.ClientIP())
+
+	err := h.syncService.DeleteProfile(name)
+	if errors.Is(err, profiles.ErrNotFound) {
+		respondProfileNotFound(c, name)
+		return
+	}
+	if err != nil {
+		log.Printf("[SYNC HANDLER] ERROR: Failed to delete sync profile %s: %v", name, err)
+		c.JSON(http.StatusInternalServerError, models.SyncProfileResponse{
+			Status:    "error",
+			Error:     err.Error(),
+			ErrorCode: syncerrors.CodeUnknown,
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, models.SyncProfileResponse{Status: "deleted", Timestamp: time.Now().UTC()})
+}
+
+// RunProfile handles requests to sync a profile. The optional body overrides fields of the
+// profile like those of a sync request naming it.
+func (h *SyncHandler) RunProfile(c *gin.Context) {
+	name := c.Param("name")
+	log.Printf("[SYNC HANDLER] Sync of profile %s requested from %s", name, c.ClientIP())
+
+	if _, ok := h.syncService.GetProfile(name); !ok {
+		respondProfileNotFound(c, name)
+		return
+	}
+	if h.refuseBusy(c) {
+		return
+	}
+
+	var request models.SyncRequest
+	if err := c.ShouldBindJSON(&request); err != nil && !errors.Is(err, io.EOF) {
+		if tooLarge(c, err) {
+			return
+		}
+		log.Printf("[SYNC HANDLER] ERROR: Invalid request format: %v", err)
+		c.JSON(http.StatusBadRequest, models.SyncResponse{
+			Status:    "error",
+			Error:     "invalid request format",
+			ErrorCode: syncerrors.CodeValidation,
+			Details:   err.Error(),
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+	request.Profile = name
+	h.submit(c, &request)
+}
+
+// respondProfileNotFound answers a request for a profile that does not exist
+func respondProfileNotFound(c *gin.Context, name string) {
+	log.Printf("[SYNC HANDLER] ERROR: Sync profile %s not found", name)
+	c.JSON(http.StatusNotFound, models.SyncProfileResponse{
+		Status:    "error",
+		Error:     "sync profile not found",
+		ErrorCode: syncerrors.CodeNotFound,
+		Timestamp: time.Now().UTC(),
+	})
+}
+
+// respondInvalidProfile answers a profile request whose body could not be parsed
+func respondInvalidProfile(c *gin.Context, err error) {
+	if tooLarge(c, err) {
+		return
+	}
+	log.Printf("[SYNC HANDLER] ERROR: Invalid profile format: %v", err)
+	c.JSON(http.StatusBadRequest, models.SyncProfileResponse{
+		Status:    "error",
+		Error:     "invalid profile format",
+		ErrorCode: syncerrors.CodeValidation,
+		Details:   err.Error(),
+		Timestamp: time.Now().UTC(),
+	})
+}
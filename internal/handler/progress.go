@@ -0,0 +1,94 @@
+/*
+Copyright 2025 SharedVolume
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sharedvolume/volume-syncer/internal/models"
+	syncerrors "github.com/sharedvolume/volume-syncer/pkg/errors"
+)
+
+// progressInterval is how often the progress stream polls the job
+const progressInterval = 500 * time.Millisecond
+
+// progressKeepAlive is the longest the progress stream stays silent, so that proxies keep it open
+// while a job is queued or a phase reports nothing
+const progressKeepAlive = 15 * time.Second
+
+// StreamProgress streams the progress of a sync job as server-sent events. A progress event is
+// sent whenever the progress changed, a final job event with the finished job ends the stream.
+func (h *SyncHandler) StreamProgress(c *gin.Context) {
+	id := c.Param("id")
+	log.Printf("[SYNC HANDLER] Progress stream of job %s requested from %s", id, c.ClientIP())
+
+	job, ok := h.syncService.GetJob(id)
+	if !ok {
+		log.Printf("[SYNC HANDLER] ERROR: Job %s not found", id)
+		c.JSON(http.StatusNotFound, models.SyncResponse{
+			Status:    "error",
+			Error:     "job not found",
+			ErrorCode: syncerrors.CodeNotFound,
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+
+	// The stream lasts as long as the job, beyond the write timeout of the server
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		log.Printf("[SYNC HANDLER] WARNING: Failed to lift the write timeout of the progress stream: %v", err)
+	}
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+	var sent models.SyncProgress
+	lastWrite := time.Now()
+	for {
+		switch {
+		case job.Finished():
+			c.SSEvent("job", job)
+			c.Writer.Flush()
+			return
+		case job.Progress != nil && *job.Progress != sent:
+			sent = *job.Progress
+			c.SSEvent("progress", sent)
+			c.Writer.Flush()
+			lastWrite = time.Now()
+		case time.Since(lastWrite) >= progressKeepAlive:
+			_, _ = c.Writer.WriteString(": keep-alive\n\n")
+			c.Writer.Flush()
+			lastWrite = time.Now()
+		}
+
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-ticker.C:
+		}
+		if job, ok = h.syncService.GetJob(id); !ok {
+			// Dropped from the history, which only happens long after it finished
+			return
+		}
+	}
+}
@@ -17,15 +17,24 @@ limitations under the License.
 package handler
 
 import (
+	"errors"
 	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sharedvolume/volume-syncer/internal/models"
+	"github.com/sharedvolume/volume-syncer/internal/retry"
 	"github.com/sharedvolume/volume-syncer/internal/service"
+	syncerrors "github.com/sharedvolume/volume-syncer/pkg/errors"
 )
 
+// defaultRetryAfter is the wait suggested to clients when it cannot be estimated from earlier jobs
+const defaultRetryAfter = 30 * time.Second
+
 // SyncHandler handles sync-related HTTP requests
 type SyncHandler struct {
 	syncService *service.SyncService
@@ -41,51 +50,167 @@ func NewSyncHandler(syncService *service.SyncService) *SyncHandler {
 // HealthCheck handles health check requests
 func (h *SyncHandler) HealthCheck(c *gin.Context) {
 	log.Printf("[SYNC HANDLER] Health check requested from %s", c.ClientIP())
+	paused, queued := h.syncService.QueueState()
 	response := models.HealthResponse{
 		Status:    "healthy",
+		Paused:    paused,
+		Queued:    queued,
+		Drifted:   h.syncService.DriftedTargets(),
 		Timestamp: time.Now().UTC(),
 	}
 	log.Printf("[SYNC HANDLER] Health check response sent: %s", response.Status)
 	c.JSON(http.StatusOK, response)
 }
 
+// Pause handles requests to stop dispatching sync jobs
+func (h *SyncHandler) Pause(c *gin.Context) {
+	log.Printf("[SYNC HANDLER] Pause of the job queue requested from %s", c.ClientIP())
+	h.syncService.Pause()
+	h.respondQueue(c, "paused")
+}
+
+// Resume handles requests to dispatch the queued sync jobs again
+func (h *SyncHandler) Resume(c *gin.Context) {
+	log.Printf("[SYNC HANDLER] Resume of the job queue requested from %s", c.ClientIP())
+	h.syncService.Resume()
+	h.respondQueue(c, "resumed")
+}
+
+// respondQueue writes the state of the job queue
+func (h *SyncHandler) respondQueue(c *gin.Context, status string) {
+	paused, queued := h.syncService.QueueState()
+	c.JSON(http.StatusOK, models.QueueResponse{
+		Status:    status,
+		Paused:    paused,
+		Queued:    queued,
+		Timestamp: time.Now().UTC(),
+	})
+}
+
 // Sync handles synchronization requests
 func (h *SyncHandler) Sync(c *gin.Context) {
 	log.Printf("[SYNC HANDLER] Sync request received from %s", c.ClientIP())
 
-	// Check if sync is already in progress
-	log.Printf("[SYNC HANDLER] Checking if sync is already in progress...")
-	if h.syncService.IsSyncInProgress() {
-		log.Printf("[SYNC HANDLER] ERROR: Sync already in progress")
-		response := models.SyncResponse{
-			Status:    "busy",
-			Error:     "syncing in progress already",
-			Timestamp: time.Now().UTC(),
-		}
-		c.JSON(http.StatusServiceUnavailable, response)
+	if h.refuseBusy(c) {
 		return
 	}
-	log.Printf("[SYNC HANDLER] No sync in progress, proceeding...")
 
 	// Parse request
 	log.Printf("[SYNC HANDLER] Parsing request body...")
 	var request models.SyncRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
+		if tooLarge(c, err) {
+			return
+		}
 		log.Printf("[SYNC HANDLER] ERROR: Invalid request format: %v", err)
 		response := models.SyncResponse{
 			Status:    "error",
 			Error:     "invalid request format",
+			ErrorCode: syncerrors.CodeValidation,
 			Details:   err.Error(),
 			Timestamp: time.Now().UTC(),
 		}
 		c.JSON(http.StatusBadRequest, response)
 		return
 	}
-	log.Printf("[SYNC HANDLER] Request parsed successfully - Type: %s, Target: %s", request.Source.Type, request.Target.Path)
+	log.Printf("[SYNC HANDLER] Request parsed successfully - Type: %s, Target: %s", request.SourceType(), request.Target.Path)
+	h.submit(c, &request)
+}
 
+// refuseBusy answers the request when a sync request can be neither started, queued nor attached.
+// It returns true when the request was refused.
+func (h *SyncHandler) refuseBusy(c *gin.Context) bool {
+	log.Printf("[SYNC HANDLER] Checking if sync is already in progress...")
+	if !h.syncService.AcceptsSync() {
+		log.Printf("[SYNC HANDLER] ERROR: Sync already in progress")
+		wait, known := h.syncService.BusyFor()
+		setRetryAfter(c, wait, known)
+		response := models.SyncResponse{
+			Status:    "busy",
+			Error:     "syncing in progress already",
+			ErrorCode: syncerrors.CodeBusy,
+			Retryable: true,
+			Timestamp: time.Now().UTC(),
+		}
+		c.JSON(http.StatusServiceUnavailable, response)
+		return true
+	}
+	log.Printf("[SYNC HANDLER] No sync in progress, proceeding...")
+	return false
+}
+
+// setRetryAfter sets the Retry-After header to the estimated wait in whole seconds, at least one,
+// or to defaultRetryAfter when the wait is unknown
+func setRetryAfter(c *gin.Context, wait time.Duration, known bool) {
+	if !known {
+		wait = defaultRetryAfter
+	}
+	c.Header("Retry-After", strconv.Itoa(max(int(math.Ceil(wait.Seconds())), 1)))
+}
+
+// submit starts, queues or attaches the parsed sync request and answers with its job
+func (h *SyncHandler) submit(c *gin.Context, request *models.SyncRequest) {
 	// Start sync
 	log.Printf("[SYNC HANDLER] Starting sync operation...")
-	if err := h.syncService.StartSync(&request); err != nil {
+	request.Initiator = "api " + c.ClientIP()
+	job, attached, err := h.syncService.SubmitSync(request)
+	if job != nil {
+		c.Set(auditJobKey, job.ID)
+	}
+	status, response := h.submitResponse(job, attached, err)
+	switch status {
+	case http.StatusServiceUnavailable:
+		wait, known := h.syncService.BusyFor()
+		setRetryAfter(c, wait, known)
+	case http.StatusAccepted:
+		// Clients poll the job from the estimated start on
+		if job.EstimatedStartAt != nil {
+			setRetryAfter(c, time.Until(*job.EstimatedStartAt), true)
+		} else {
+			setRetryAfter(c, 0, false)
+		}
+	}
+	c.JSON(status, response)
+}
+
+// submitResponse returns the status code and the response of a submitted sync request
+func (h *SyncHandler) submitResponse(job *models.SyncJob, attached bool, err error) (int, models.SyncResponse) {
+	if errors.Is(err, service.ErrSyncInProgress) {
+		log.Printf("[SYNC HANDLER] ERROR: Sync already in progress")
+		return http.StatusServiceUnavailable, models.SyncResponse{
+			Status:    "busy",
+			Error:     "syncing in progress already",
+			ErrorCode: syncerrors.CodeBusy,
+			Retryable: true,
+			Timestamp: time.Now().UTC(),
+		}
+	}
+	var invalidErr *service.InvalidRequestError
+	if errors.As(err, &invalidErr) {
+		log.Printf("[SYNC HANDLER] ERROR: Invalid request: %v", err)
+		return http.StatusUnprocessableEntity, models.SyncResponse{
+			Status:    "error",
+			Error:     "invalid request",
+			ErrorCode: syncerrors.CodeValidation,
+			Details:   err.Error(),
+			Errors:    invalidErr.Errors,
+			Timestamp: time.Now().UTC(),
+		}
+	}
+	var preflightErr *service.PreflightError
+	if errors.As(err, &preflightErr) {
+		log.Printf("[SYNC HANDLER] ERROR: Sync refused: %v", err)
+		response := models.SyncResponse{
+			Status:    "error",
+			Error:     "source preflight failed",
+			Details:   err.Error(),
+			Checks:    preflightErr.Checks,
+			Timestamp: time.Now().UTC(),
+		}
+		response.ErrorCode, response.Retryable = retry.Classify(err)
+		return http.StatusUnprocessableEntity, response
+	}
+	if err != nil {
 		log.Printf("[SYNC HANDLER] ERROR: Failed to start sync: %v", err)
 		response := models.SyncResponse{
 			Status:    "error",
@@ -93,16 +218,535 @@ func (h *SyncHandler) Sync(c *gin.Context) {
 			Details:   err.Error(),
 			Timestamp: time.Now().UTC(),
 		}
-		c.JSON(http.StatusBadRequest, response)
-		return
+		response.ErrorCode, response.Retryable = retry.Classify(err)
+		return http.StatusBadRequest, response
+	}
+
+	if attached {
+		log.Printf("[SYNC HANDLER] Request attached to pending job %s", job.ID)
+		return http.StatusOK, models.SyncResponse{
+			Status:           "sync attached",
+			JobID:            job.ID,
+			Message:          "an identical synchronization is already " + job.Status + ", the request was attached to its job",
+			QueuePosition:    job.QueuePosition,
+			EstimatedStartAt: job.EstimatedStartAt,
+			Timestamp:        time.Now().UTC(),
+		}
+	}
+	if job.Status == models.JobStatusQueued {
+		log.Printf("[SYNC HANDLER] Sync job %s queued at position %d", job.ID, job.QueuePosition)
+		message := "synchronization is queued and starts once earlier jobs finished"
+		if h.syncService.IsPaused() {
+			message = "synchronization is queued and starts once the queue is resumed and earlier jobs finished"
+		}
+		return http.StatusAccepted, models.SyncResponse{
+			Status:           "sync queued",
+			JobID:            job.ID,
+			Message:          message,
+			QueuePosition:    job.QueuePosition,
+			EstimatedStartAt: job.EstimatedStartAt,
+			Timestamp:        time.Now().UTC(),
+		}
 	}
 
 	// Return success response
 	log.Printf("[SYNC HANDLER] Sync operation started successfully")
-	response := models.SyncResponse{
+	return http.StatusCreated, models.SyncResponse{
 		Status:    "sync started",
+		JobID:     job.ID,
 		Message:   "synchronization process has been initiated",
 		Timestamp: time.Now().UTC(),
 	}
-	c.JSON(http.StatusCreated, response)
+}
+
+// SyncBatch handles requests submitting several syncs at once
+func (h *SyncHandler) SyncBatch(c *gin.Context) {
+	log.Printf("[SYNC HANDLER] Batch sync request received from %s", c.ClientIP())
+
+	if h.refuseBusy(c) {
+		return
+	}
+
+	var request models.BatchSyncRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		if tooLarge(c, err) {
+			return
+		}
+		log.Printf("[SYNC HANDLER] ERROR: Invalid batch request format: %v", err)
+		c.JSON(http.StatusBadRequest, models.BatchSyncResponse{
+			Status:    "error",
+			Error:     "invalid request format: " + err.Error(),
+			ErrorCode: syncerrors.CodeValidation,
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+	h.submitBatch(c, &request)
+}
+
+// submitBatch submits the requests of the parsed batch and answers with the outcome of each
+func (h *SyncHandler) submitBatch(c *gin.Context, request *models.BatchSyncRequest) {
+	results, err := h.syncService.SubmitBatch(request, "api "+c.ClientIP())
+	if err != nil {
+		response := models.BatchSyncResponse{Status: "error", Error: err.Error(), Timestamp: time.Now().UTC()}
+		var invalidErr *service.InvalidRequestError
+		if errors.As(err, &invalidErr) {
+			response.Error, response.ErrorCode, response.Errors = "invalid request", syncerrors.CodeValidation, invalidErr.Errors
+		}
+		c.JSON(http.StatusUnprocessableEntity, response)
+		return
+	}
+
+	response := models.BatchSyncResponse{JobIDs: make([]string, len(results)), Results: make([]models.SyncResponse, len(results))}
+	failed := 0
+	for i, result := range results {
+		_, response.Results[i] = h.submitResponse(result.Job, result.Attached, result.Err)
+		if result.Err != nil {
+			failed++
+			continue
+		}
+		response.JobIDs[i] = result.Job.ID
+	}
+	switch failed {
+	case 0:
+		response.Status = "submitted"
+	case len(results):
+		response.Status = "failed"
+	default:
+		response.Status = "partial"
+	}
+	log.Printf("[SYNC HANDLER] Batch of %d sync requests submitted, %d failed", len(results), failed)
+	response.Timestamp = time.Now().UTC()
+	c.JSON(http.StatusOK, response)
+}
+
+// Validate handles requests to check the connection to a source without syncing it
+func (h *SyncHandler) Validate(c *gin.Context) {
+	log.Printf("[SYNC HANDLER] Connection validation requested from %s", c.ClientIP())
+
+	var request models.ValidateRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		if tooLarge(c, err) {
+			return
+		}
+		log.Printf("[SYNC HANDLER] ERROR: Invalid validation request format: %v", err)
+		c.JSON(http.StatusBadRequest, models.ValidateResponse{
+			Status:    "error",
+			Error:     "invalid request format: " + err.Error(),
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+
+	h.validate(c, &request)
+}
+
+// validate runs the connection checks of the parsed request
+func (h *SyncHandler) validate(c *gin.Context, request *models.ValidateRequest) {
+	response := h.syncService.ValidateConnection(c.Request.Context(), request.Source)
+	response.Timestamp = time.Now().UTC()
+	log.Printf("[SYNC HANDLER] Connection validation finished: %s", response.Status)
+	c.JSON(http.StatusOK, response)
+}
+
+// Probe handles requests to time the connection to the server of a source and check its
+// credentials
+func (h *SyncHandler) Probe(c *gin.Context) {
+	log.Printf("[SYNC HANDLER] Source probe requested from %s", c.ClientIP())
+
+	var request models.ProbeRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		if tooLarge(c, err) {
+			return
+		}
+		log.Printf("[SYNC HANDLER] ERROR: Invalid probe request format: %v", err)
+		c.JSON(http.StatusBadRequest, models.ProbeResponse{
+			Status:    "error",
+			Error:     "invalid request format: " + err.Error(),
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+
+	h.probe(c, &request)
+}
+
+// probe runs the stages of the parsed request
+func (h *SyncHandler) probe(c *gin.Context, request *models.ProbeRequest) {
+	response := h.syncService.ProbeSource(c.Request.Context(), request)
+	response.Timestamp = time.Now().UTC()
+	log.Printf("[SYNC HANDLER] Source probe finished: %s", response.Status)
+	c.JSON(http.StatusOK, response)
+}
+
+// ProbeURL handles requests to time the connection to the server of the url query parameter,
+// through the proxy of the proxy query parameter when given
+func (h *SyncHandler) ProbeURL(c *gin.Context) {
+	url := c.Query("url")
+	log.Printf("[SYNC HANDLER] URL probe requested from %s (url: %q)", c.ClientIP(), url)
+
+	if url == "" {
+		c.JSON(http.StatusBadRequest, models.ProbeResponse{
+			Status:    "error",
+			Error:     "url query parameter is required",
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+	var proxyOptions *models.ProxyOptions
+	if proxyURL, ok := c.GetQuery("proxy"); ok {
+		proxyOptions = &models.ProxyOptions{URL: proxyURL}
+	}
+
+	response, err := h.syncService.ProbeURL(c.Request.Context(), url, proxyOptions)
+	if err != nil {
+		log.Printf("[SYNC HANDLER] ERROR: Invalid probe URL: %v", err)
+		c.JSON(http.StatusBadRequest, models.ProbeResponse{
+			Status:    "error",
+			Error:     err.Error(),
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+	response.Timestamp = time.Now().UTC()
+	log.Printf("[SYNC HANDLER] URL probe finished: %s", response.Status)
+	c.JSON(http.StatusOK, response)
+}
+
+// Browse handles requests to list the branches, tags, directories or keys of a source
+func (h *SyncHandler) Browse(c *gin.Context) {
+	log.Printf("[SYNC HANDLER] Source browsing requested from %s", c.ClientIP())
+
+	var request models.BrowseRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		if tooLarge(c, err) {
+			return
+		}
+		log.Printf("[SYNC HANDLER] ERROR: Invalid browse request format: %v", err)
+		c.JSON(http.StatusBadRequest, models.BrowseResponse{
+			Status:    "error",
+			Error:     "invalid request format: " + err.Error(),
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+
+	h.browse(c, &request)
+}
+
+// browse lists the source of the parsed request
+func (h *SyncHandler) browse(c *gin.Context, request *models.BrowseRequest) {
+	response, err := h.syncService.BrowseSource(c.Request.Context(), request)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.BrowseResponse{
+			Status:     "error",
+			SourceType: request.Source.Type,
+			Path:       request.Path,
+			Error:      err.Error(),
+			Timestamp:  time.Now().UTC(),
+		})
+		return
+	}
+	response.Timestamp = time.Now().UTC()
+	log.Printf("[SYNC HANDLER] Source browsing finished with %d entries", len(response.Entries))
+	c.JSON(http.StatusOK, response)
+}
+
+// RollbackTarget handles requests to activate a previous version of a versioned target
+func (h *SyncHandler) RollbackTarget(c *gin.Context) {
+	log.Printf("[SYNC HANDLER] Target rollback requested from %s", c.ClientIP())
+
+	if h.syncService.IsSyncInProgress() {
+		log.Printf("[SYNC HANDLER] ERROR: Sync in progress, rollback refused")
+		c.JSON(http.StatusServiceUnavailable, models.TargetRollbackResponse{
+			Status:    "busy",
+			Error:     "syncing in progress already",
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+
+	var request models.TargetRollbackRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		if tooLarge(c, err) {
+			return
+		}
+		log.Printf("[SYNC HANDLER] ERROR: Invalid rollback request format: %v", err)
+		c.JSON(http.StatusBadRequest, models.TargetRollbackResponse{
+			Status:    "error",
+			Error:     "invalid request format: " + err.Error(),
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+
+	response, err := h.syncService.RollbackTarget(&request)
+	if err != nil {
+		log.Printf("[SYNC HANDLER] ERROR: Failed to roll back target: %v", err)
+		c.JSON(http.StatusBadRequest, models.TargetRollbackResponse{
+			Status:    "error",
+			Path:      request.Path,
+			Error:     err.Error(),
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+
+	log.Printf("[SYNC HANDLER] Target %s rolled back to version %s", response.Path, response.Current)
+	response.Timestamp = time.Now().UTC()
+	c.JSON(http.StatusOK, response)
+}
+
+// TargetDrift handles requests for the changes made to a target since its last successful sync
+func (h *SyncHandler) TargetDrift(c *gin.Context) {
+	path := c.Query("path")
+	log.Printf("[SYNC HANDLER] Target drift requested from %s (path: %q)", c.ClientIP(), path)
+
+	if path == "" {
+		c.JSON(http.StatusBadRequest, models.TargetDriftResponse{
+			Status:    "error",
+			Error:     "path query parameter is required",
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+	if h.syncService.IsSyncInProgress() {
+		log.Printf("[SYNC HANDLER] ERROR: Sync in progress, drift detection refused")
+		c.JSON(http.StatusServiceUnavailable, models.TargetDriftResponse{
+			Status:    "busy",
+			Path:      path,
+			Error:     "syncing in progress already",
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+
+	response, err := h.syncService.TargetDrift(path)
+	if err != nil {
+		log.Printf("[SYNC HANDLER] ERROR: Failed to detect drift: %v", err)
+		c.JSON(http.StatusNotFound, models.TargetDriftResponse{
+			Status:    "error",
+			Path:      path,
+			Error:     err.Error(),
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+	response.Timestamp = time.Now().UTC()
+	c.JSON(http.StatusOK, response)
+}
+
+// TargetStatus handles requests for the last sync of a target
+func (h *SyncHandler) TargetStatus(c *gin.Context) {
+	path := c.Query("path")
+	log.Printf("[SYNC HANDLER] Target status requested from %s (path: %q)", c.ClientIP(), path)
+
+	if path == "" {
+		c.JSON(http.StatusBadRequest, models.TargetStatus{
+			Status:    "error",
+			Error:     "path query parameter is required",
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+
+	response, err := h.syncService.TargetStatus(path)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.TargetStatus{
+			Status:    "error",
+			Path:      path,
+			Error:     err.Error(),
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+	response.Timestamp = time.Now().UTC()
+	c.JSON(http.StatusOK, response)
+}
+
+// InspectTarget handles requests for the contents and last successful sync of a target
+func (h *SyncHandler) InspectTarget(c *gin.Context) {
+	path := c.Query("path")
+	log.Printf("[SYNC HANDLER] Target inspection requested from %s (path: %q)", c.ClientIP(), path)
+
+	if path == "" {
+		c.JSON(http.StatusBadRequest, models.TargetInspection{
+			Status:    "error",
+			Error:     "path query parameter is required",
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+
+	response, err := h.syncService.InspectTarget(path)
+	if err != nil {
+		log.Printf("[SYNC HANDLER] ERROR: Failed to inspect target: %v", err)
+		c.JSON(http.StatusNotFound, models.TargetInspection{
+			Status:    "error",
+			Path:      path,
+			Error:     err.Error(),
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+	response.Timestamp = time.Now().UTC()
+	c.JSON(http.StatusOK, response)
+}
+
+// PurgeTarget handles requests to empty a target. The first request returns a confirmation token,
+// which a second request passes as confirm to purge the target.
+func (h *SyncHandler) PurgeTarget(c *gin.Context) {
+	path, confirm := c.Query("path"), c.Query("confirm")
+	log.Printf("[SYNC HANDLER] Target purge requested from %s (path: %q, confirmed: %t)", c.ClientIP(), path, confirm != "")
+
+	if path == "" {
+		c.JSON(http.StatusBadRequest, models.TargetPurgeResponse{
+			Status:    "error",
+			Error:     "path query parameter is required",
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+	if confirm != "" && h.syncService.IsSyncInProgress() {
+		log.Printf("[SYNC HANDLER] ERROR: Sync in progress, purge refused")
+		c.JSON(http.StatusServiceUnavailable, models.TargetPurgeResponse{
+			Status:    "busy",
+			Path:      path,
+			Error:     "syncing in progress already",
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+
+	response, err := h.syncService.PurgeTarget(path, confirm)
+	if err != nil {
+		log.Printf("[SYNC HANDLER] ERROR: Failed to purge target: %v", err)
+		status := http.StatusInternalServerError
+		var syncErr *syncerrors.SyncError
+		if errors.As(err, &syncErr) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, models.TargetPurgeResponse{
+			Status:    "error",
+			Path:      path,
+			Error:     err.Error(),
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+	response.Timestamp = time.Now().UTC()
+	c.JSON(http.StatusOK, response)
+}
+
+// TargetUsage handles requests for the usage of the target quotas
+func (h *SyncHandler) TargetUsage(c *gin.Context) {
+	path := c.Query("path")
+	log.Printf("[SYNC HANDLER] Target usage requested from %s (path: %q)", c.ClientIP(), path)
+
+	usages, err := h.syncService.TargetUsage(path)
+	if err != nil {
+		log.Printf("[SYNC HANDLER] ERROR: %v", err)
+		c.JSON(http.StatusNotFound, models.SyncResponse{
+			Status:    "error",
+			Error:     err.Error(),
+			ErrorCode: syncerrors.CodeNotFound,
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, models.TargetUsageResponse{
+		Targets:   usages,
+		Timestamp: time.Now().UTC(),
+	})
+}
+
+// ListJobs handles requests for the sync job history, filtered by labels, status and source type
+// and paginated with limit and offset
+func (h *SyncHandler) ListJobs(c *gin.Context) {
+	log.Printf("[SYNC HANDLER] Job list requested from %s", c.ClientIP())
+	filter := service.JobFilter{Status: c.Query("status"), SourceType: c.Query("sourceType")}
+	respondInvalid := func(message string) {
+		c.JSON(http.StatusBadRequest, models.SyncJobsResponse{Status: "error", Jobs: []models.SyncJob{}, Error: message, Timestamp: time.Now().UTC()})
+	}
+	switch filter.Status {
+	case "", models.JobStatusQueued, models.JobStatusRunning, models.JobStatusSucceeded, models.JobStatusFailed:
+	default:
+		respondInvalid("status must be queued, running, succeeded or failed")
+		return
+	}
+	for _, selector := range c.QueryArray("label") {
+		key, value, _ := strings.Cut(selector, "=")
+		if key == "" {
+			respondInvalid("label must be a key=value pair or a key, such as volume=models")
+			return
+		}
+		if filter.Labels == nil {
+			filter.Labels = map[string]string{}
+		}
+		filter.Labels[key] = value
+	}
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			respondInvalid("limit must be a positive number")
+			return
+		}
+		filter.Limit = limit
+	}
+	if value := c.Query("offset"); value != "" {
+		offset, err := strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			respondInvalid("offset must be a number of at least 0")
+			return
+		}
+		filter.Offset = offset
+	}
+
+	jobs, total := h.syncService.ListJobs(filter)
+	response := models.SyncJobsResponse{Jobs: jobs, Total: total, Timestamp: time.Now().UTC()}
+	if next := filter.Offset + len(jobs); filter.Limit > 0 && next < total {
+		response.NextOffset = &next
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// Stats handles requests for the statistics of the finished jobs, by source type and target
+func (h *SyncHandler) Stats(c *gin.Context) {
+	log.Printf("[SYNC HANDLER] Job statistics requested from %s", c.ClientIP())
+	filter := service.StatsFilter{SourceType: c.Query("sourceType")}
+	if value := c.Query("since"); value != "" {
+		since, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.StatsResponse{
+				Status:    "error",
+				Error:     "since must be an RFC 3339 time such as 2025-01-02T15:04:05Z",
+				Timestamp: time.Now().UTC(),
+			})
+			return
+		}
+		filter.Since = since
+	}
+
+	response := h.syncService.Stats(filter)
+	response.Timestamp = time.Now().UTC()
+	c.JSON(http.StatusOK, response)
+}
+
+// GetJob handles requests for the status of a single sync job
+func (h *SyncHandler) GetJob(c *gin.Context) {
+	id := c.Param("id")
+	log.Printf("[SYNC HANDLER] Job %s requested from %s", id, c.ClientIP())
+
+	job, ok := h.syncService.GetJob(id)
+	if !ok {
+		log.Printf("[SYNC HANDLER] ERROR: Job %s not found", id)
+		c.JSON(http.StatusNotFound, models.SyncResponse{
+			Status:    "error",
+			Error:     "job not found",
+			ErrorCode: syncerrors.CodeNotFound,
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, job)
 }
@@ -0,0 +1,159 @@
+/*
+Copyright 2025 SharedVolume
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sharedvolume/volume-syncer/internal/models"
+	"github.com/sharedvolume/volume-syncer/internal/syncer"
+	"github.com/sharedvolume/volume-syncer/internal/validation"
+	syncerrors "github.com/sharedvolume/volume-syncer/pkg/errors"
+)
+
+// namedSource is a source of a request with the JSON path it was given at
+type namedSource struct {
+	path   string
+	source models.Source
+}
+
+// bindV2 decodes the body strictly into the request, checks its required fields and decodes the
+// details of its sources into their typed details. Invalid requests are answered with every
+// invalid field found, and false is returned.
+func bindV2(c *gin.Context, request any, sources func() []namedSource) bool {
+	body, err := io.ReadAll(c.Request.Body)
+	if tooLarge(c, err) {
+		return false
+	}
+	if err != nil {
+		respondInvalid(c, http.StatusBadRequest, []models.FieldError{{Message: "failed to read request body"}})
+		return false
+	}
+	if fieldErrs := validation.Decode(body, request); len(fieldErrs) > 0 {
+		respondInvalid(c, http.StatusBadRequest, fieldErrs)
+		return false
+	}
+	fieldErrs := validation.Struct(request)
+	for _, named := range sources() {
+		if named.source.Type == "" {
+			fieldErrs = append(fieldErrs, models.FieldError{Field: named.path + ".type", Message: "is required"})
+			continue
+		}
+		fieldErrs = append(fieldErrs, validation.Prefix(named.path, syncer.DecodeDetails(named.source))...)
+	}
+	if len(fieldErrs) > 0 {
+		respondInvalid(c, http.StatusUnprocessableEntity, fieldErrs)
+		return false
+	}
+	return true
+}
+
+// respondInvalid answers a request to the 2.0 API with its invalid fields: 400 when the body is
+// no valid document of the request, 422 when its values are invalid
+func respondInvalid(c *gin.Context, status int, fieldErrs []models.FieldError) {
+	log.Printf("[SYNC HANDLER] ERROR: Invalid request: %v", fieldErrs)
+	c.JSON(status, models.SyncResponse{
+		Status:    "error",
+		Error:     "invalid request",
+		ErrorCode: syncerrors.CodeValidation,
+		Errors:    fieldErrs,
+		Timestamp: time.Now().UTC(),
+	})
+}
+
+// SyncV2 handles synchronization requests of the 2.0 API, which decodes source details strictly
+func (h *SyncHandler) SyncV2(c *gin.Context) {
+	log.Printf("[SYNC HANDLER] Sync request (2.0) received from %s", c.ClientIP())
+	if h.refuseBusy(c) {
+		return
+	}
+
+	var request models.SyncRequest
+	if !bindV2(c, &request, func() []namedSource { return syncSources("", &request) }) {
+		return
+	}
+	log.Printf("[SYNC HANDLER] Request parsed successfully - Type: %s, Target: %s", request.SourceType(), request.Target.Path)
+	h.submit(c, &request)
+}
+
+// SyncBatchV2 handles batch sync requests of the 2.0 API
+func (h *SyncHandler) SyncBatchV2(c *gin.Context) {
+	log.Printf("[SYNC HANDLER] Batch sync request (2.0) received from %s", c.ClientIP())
+	if h.refuseBusy(c) {
+		return
+	}
+
+	var request models.BatchSyncRequest
+	if !bindV2(c, &request, func() []namedSource {
+		var sources []namedSource
+		for i := range request.Requests {
+			sources = append(sources, syncSources(fmt.Sprintf("requests[%d].", i), &request.Requests[i].SyncRequest)...)
+		}
+		return sources
+	}) {
+		return
+	}
+	h.submitBatch(c, &request)
+}
+
+// syncSources returns the sources of the sync request, whose JSON paths start with the prefix
+func syncSources(prefix string, request *models.SyncRequest) []namedSource {
+	if request.Profile != "" && request.Source.Type == "" && len(request.Sources) == 0 {
+		// The sources of the profile were validated when it was stored
+		return nil
+	}
+	if len(request.Sources) == 0 {
+		return []namedSource{{path: prefix + "source", source: request.Source}}
+	}
+	sources := make([]namedSource, len(request.Sources))
+	for i, source := range request.Sources {
+		sources[i] = namedSource{path: fmt.Sprintf("%ssources[%d]", prefix, i), source: source.Source}
+	}
+	return sources
+}
+
+// ValidateV2 handles connection validation requests of the 2.0 API
+func (h *SyncHandler) ValidateV2(c *gin.Context) {
+	log.Printf("[SYNC HANDLER] Connection validation (2.0) requested from %s", c.ClientIP())
+	var request models.ValidateRequest
+	if bindV2(c, &request, func() []namedSource { return []namedSource{{path: "source", source: request.Source}} }) {
+		h.validate(c, &request)
+	}
+}
+
+// ProbeV2 handles source probe requests of the 2.0 API
+func (h *SyncHandler) ProbeV2(c *gin.Context) {
+	log.Printf("[SYNC HANDLER] Source probe (2.0) requested from %s", c.ClientIP())
+	var request models.ProbeRequest
+	if bindV2(c, &request, func() []namedSource { return []namedSource{{path: "source", source: request.Source}} }) {
+		h.probe(c, &request)
+	}
+}
+
+// BrowseV2 handles source browsing requests of the 2.0 API
+func (h *SyncHandler) BrowseV2(c *gin.Context) {
+	log.Printf("[SYNC HANDLER] Source browsing (2.0) requested from %s", c.ClientIP())
+	var request models.BrowseRequest
+	if bindV2(c, &request, func() []namedSource { return []namedSource{{path: "source", source: request.Source}} }) {
+		h.browse(c, &request)
+	}
+}
@@ -0,0 +1,324 @@
+/*
+Copyright 2025 SharedVolume
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sharedvolume/volume-syncer/internal/definitions"
+	"github.com/sharedvolume/volume-syncer/internal/models"
+	"github.com/sharedvolume/volume-syncer/internal/service"
+)
+
+// maxWebhookBodySize bounds the size of accepted webhook payloads
+const maxWebhookBodySize = 5 << 20
+
+// Supported Git providers
+const (
+	providerGitHub    = "github"
+	providerGitLab    = "gitlab"
+	providerBitbucket = "bitbucket"
+)
+
+// pushEvent is the provider-independent view of a push webhook
+type pushEvent struct {
+	RepoURLs []string
+	Branch   string
+}
+
+// WebhookHandler handles Git provider webhook deliveries
+type WebhookHandler struct {
+	registry    *definitions.Registry
+	syncService *service.SyncService
+}
+
+// NewWebhookHandler creates a new webhook handler
+func NewWebhookHandler(registry *definitions.Registry, syncService *service.SyncService) *WebhookHandler {
+	return &WebhookHandler{
+		registry:    registry,
+		syncService: syncService,
+	}
+}
+
+// GitWebhook handles push webhooks from GitHub, GitLab and Bitbucket
+func (h *WebhookHandler) GitWebhook(c *gin.Context) {
+	log.Printf("[WEBHOOK HANDLER] Git webhook received from %s", c.ClientIP())
+
+	provider, event := detectProvider(c.Request.Header)
+	if provider == "" {
+		log.Printf("[WEBHOOK HANDLER] ERROR: Unknown webhook provider")
+		h.respond(c, http.StatusBadRequest, "error", "unsupported webhook provider", nil)
+		return
+	}
+	log.Printf("[WEBHOOK HANDLER] Provider: %s, Event: %s", provider, event)
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookBodySize+1))
+	if err != nil {
+		log.Printf("[WEBHOOK HANDLER] ERROR: Failed to read webhook body: %v", err)
+		h.respond(c, http.StatusBadRequest, "error", "failed to read request body", nil)
+		return
+	}
+	if len(body) > maxWebhookBodySize {
+		log.Printf("[WEBHOOK HANDLER] ERROR: Webhook body too large")
+		h.respond(c, http.StatusRequestEntityTooLarge, "error", "webhook payload too large", nil)
+		return
+	}
+
+	if provider == providerGitHub && event == "ping" {
+		h.respond(c, http.StatusOK, "ok", "pong", nil)
+		return
+	}
+	if !isPushEvent(provider, event) {
+		log.Printf("[WEBHOOK HANDLER] Ignoring non-push event: %s", event)
+		h.respond(c, http.StatusAccepted, "ignored", "event is not a push event", nil)
+		return
+	}
+
+	push, err := parsePushEvent(provider, body)
+	if err != nil {
+		log.Printf("[WEBHOOK HANDLER] ERROR: Failed to parse push event: %v", err)
+		h.respond(c, http.StatusBadRequest, "error", err.Error(), nil)
+		return
+	}
+	if push.Branch == "" {
+		log.Printf("[WEBHOOK HANDLER] Push is not for a branch, ignoring")
+		h.respond(c, http.StatusAccepted, "ignored", "push is not for a branch", nil)
+		return
+	}
+	log.Printf("[WEBHOOK HANDLER] Push to branch %s of %v", push.Branch, push.RepoURLs)
+
+	// Unknown repositories and branches are answered like invalid signatures, so that callers
+	// without a secret cannot tell which ones are configured
+	matches := h.registry.MatchWebhook(push.RepoURLs, push.Branch)
+	if len(matches) == 0 {
+		log.Printf("[WEBHOOK HANDLER] ERROR: No sync definition registered for this repository and branch")
+		h.respond(c, http.StatusUnauthorized, "error", "invalid webhook signature", nil)
+		return
+	}
+
+	var triggers []models.WebhookTrigger
+	authenticated := false
+	for _, def := range matches {
+		if !verifySecret(provider, c.Request.Header, body, def.Webhook.Secret) {
+			log.Printf("[WEBHOOK HANDLER] Secret validation failed for definition %s", def.Name)
+			continue
+		}
+		authenticated = true
+
+		log.Printf("[WEBHOOK HANDLER] Triggering sync definition %s", def.Name)
+		trigger := models.WebhookTrigger{Definition: def.Name, Status: "sync started"}
+		request := def.Request()
+		request.Initiator = "webhook " + def.Name + " (" + provider + ")"
+		job, attached, err := h.syncService.SubmitSync(request)
+		if err != nil {
+			log.Printf("[WEBHOOK HANDLER] ERROR: Failed to trigger definition %s: %v", def.Name, err)
+			trigger.Status = "error"
+			trigger.Error = err.Error()
+		} else {
+			trigger.JobID = job.ID
+			if attached {
+				trigger.Status = "sync attached"
+			} else if job.Status == models.JobStatusQueued {
+				trigger.Status = "sync queued"
+			}
+		}
+		triggers = append(triggers, trigger)
+	}
+
+	if !authenticated {
+		log.Printf("[WEBHOOK HANDLER] ERROR: Webhook secret validation failed")
+		h.respond(c, http.StatusUnauthorized, "error", "invalid webhook signature", nil)
+		return
+	}
+
+	h.respond(c, http.StatusAccepted, "accepted", "webhook processed", triggers)
+}
+
+func (h *WebhookHandler) respond(c *gin.Context, code int, status, message string, triggers []models.WebhookTrigger) {
+	c.JSON(code, models.WebhookResponse{
+		Status:    status,
+		Message:   message,
+		Triggered: triggers,
+		Timestamp: time.Now().UTC(),
+	})
+}
+
+// detectProvider identifies the webhook provider and event name from request headers
+func detectProvider(header http.Header) (string, string) {
+	switch {
+	case header.Get("X-GitHub-Event") != "":
+		return providerGitHub, header.Get("X-GitHub-Event")
+	case header.Get("X-Gitlab-Event") != "":
+		return providerGitLab, header.Get("X-Gitlab-Event")
+	case header.Get("X-Event-Key") != "":
+		return providerBitbucket, header.Get("X-Event-Key")
+	default:
+		return "", ""
+	}
+}
+
+func isPushEvent(provider, event string) bool {
+	switch provider {
+	case providerGitHub:
+		return event == "push"
+	case providerGitLab:
+		return event == "Push Hook"
+	case providerBitbucket:
+		return event == "repo:push" || event == "repo:refs_changed"
+	}
+	return false
+}
+
+// verifySecret validates the delivery against the shared secret of a definition
+func verifySecret(provider string, header http.Header, body []byte, secret string) bool {
+	switch provider {
+	case providerGitLab:
+		token := header.Get("X-Gitlab-Token")
+		return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(secret)) == 1
+	case providerGitHub:
+		return verifyHMAC(header.Get("X-Hub-Signature-256"), body, secret)
+	case providerBitbucket:
+		return verifyHMAC(header.Get("X-Hub-Signature"), body, secret)
+	}
+	return false
+}
+
+// verifyHMAC checks a "sha256=<hex>" signature of body
+func verifyHMAC(signature string, body []byte, secret string) bool {
+	const prefix = "sha256="
+	if !strings.HasPrefix(signature, prefix) {
+		return false
+	}
+	expected, err := hex.DecodeString(strings.TrimPrefix(signature, prefix))
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// parsePushEvent extracts repository URLs and the pushed branch from a provider payload
+func parsePushEvent(provider string, body []byte) (*pushEvent, error) {
+	switch provider {
+	case providerGitHub:
+		var payload struct {
+			Ref        string `json:"ref"`
+			Repository struct {
+				CloneURL string `json:"clone_url"`
+				SSHURL   string `json:"ssh_url"`
+				HTMLURL  string `json:"html_url"`
+			} `json:"repository"`
+		}
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return nil, errors.New("invalid GitHub push payload")
+		}
+		return &pushEvent{
+			RepoURLs: []string{payload.Repository.CloneURL, payload.Repository.SSHURL, payload.Repository.HTMLURL},
+			Branch:   branchFromRef(payload.Ref),
+		}, nil
+
+	case providerGitLab:
+		var payload struct {
+			Ref     string `json:"ref"`
+			Project struct {
+				GitHTTPURL string `json:"git_http_url"`
+				GitSSHURL  string `json:"git_ssh_url"`
+				WebURL     string `json:"web_url"`
+			} `json:"project"`
+		}
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return nil, errors.New("invalid GitLab push payload")
+		}
+		return &pushEvent{
+			RepoURLs: []string{payload.Project.GitHTTPURL, payload.Project.GitSSHURL, payload.Project.WebURL},
+			Branch:   branchFromRef(payload.Ref),
+		}, nil
+
+	case providerBitbucket:
+		var payload struct {
+			// Bitbucket Cloud
+			Push struct {
+				Changes []struct {
+					New *struct {
+						Type string `json:"type"`
+						Name string `json:"name"`
+					} `json:"new"`
+				} `json:"changes"`
+			} `json:"push"`
+			// Bitbucket Server / Data Center
+			Changes []struct {
+				RefID string `json:"refId"`
+			} `json:"changes"`
+			Repository struct {
+				Links struct {
+					HTML struct {
+						Href string `json:"href"`
+					} `json:"html"`
+					Clone []struct {
+						Href string `json:"href"`
+					} `json:"clone"`
+				} `json:"links"`
+			} `json:"repository"`
+		}
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return nil, errors.New("invalid Bitbucket push payload")
+		}
+
+		event := &pushEvent{RepoURLs: []string{payload.Repository.Links.HTML.Href}}
+		for _, clone := range payload.Repository.Links.Clone {
+			event.RepoURLs = append(event.RepoURLs, clone.Href)
+		}
+		for _, change := range payload.Push.Changes {
+			if change.New != nil && change.New.Type == "branch" {
+				event.Branch = change.New.Name
+				break
+			}
+		}
+		if event.Branch == "" {
+			for _, change := range payload.Changes {
+				if branch := branchFromRef(change.RefID); branch != "" {
+					event.Branch = branch
+					break
+				}
+			}
+		}
+		return event, nil
+	}
+
+	return nil, errors.New("unsupported webhook provider")
+}
+
+// branchFromRef returns the branch name of a refs/heads/ ref, or "" for other refs
+func branchFromRef(ref string) string {
+	const prefix = "refs/heads/"
+	if !strings.HasPrefix(ref, prefix) {
+		return ""
+	}
+	return strings.TrimPrefix(ref, prefix)
+}
@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"testing"
+)
+
+// signature returns the "sha256=<hex>" HMAC of the body
+func signature(body, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifySecret(t *testing.T) {
+	const secret = "s3cret"
+	const body = `{"ref":"refs/heads/main"}`
+	valid := signature(body, secret)
+
+	tests := []struct {
+		name     string
+		provider string
+		header   map[string]string
+		body     string // the delivered body, body when empty
+		want     bool
+	}{
+		{name: "github valid signature", provider: providerGitHub, header: map[string]string{"X-Hub-Signature-256": valid}, want: true},
+		{name: "github signature of another secret", provider: providerGitHub, header: map[string]string{"X-Hub-Signature-256": signature(body, "other")}},
+		{name: "github modified body", provider: providerGitHub, header: map[string]string{"X-Hub-Signature-256": valid}, body: `{"ref":"refs/heads/evil"}`},
+		{name: "github missing signature", provider: providerGitHub},
+		{name: "github signature in the bitbucket header", provider: providerGitHub, header: map[string]string{"X-Hub-Signature": valid}},
+		{name: "github signature without prefix", provider: providerGitHub, header: map[string]string{"X-Hub-Signature-256": valid[len("sha256="):]}},
+		{name: "github sha1 signature", provider: providerGitHub, header: map[string]string{"X-Hub-Signature-256": "sha1=" + valid[len("sha256="):]}},
+		{name: "github signature that is not hex", provider: providerGitHub, header: map[string]string{"X-Hub-Signature-256": "sha256=zz"}},
+		{name: "github empty signature", provider: providerGitHub, header: map[string]string{"X-Hub-Signature-256": "sha256="}},
+
+		{name: "bitbucket valid signature", provider: providerBitbucket, header: map[string]string{"X-Hub-Signature": valid}, want: true},
+		{name: "bitbucket signature of another secret", provider: providerBitbucket, header: map[string]string{"X-Hub-Signature": signature(body, "other")}},
+		{name: "bitbucket missing signature", provider: providerBitbucket},
+		{name: "bitbucket signature in the github header", provider: providerBitbucket, header: map[string]string{"X-Hub-Signature-256": valid}},
+
+		{name: "gitlab valid token", provider: providerGitLab, header: map[string]string{"X-Gitlab-Token": secret}, want: true},
+		{name: "gitlab wrong token", provider: providerGitLab, header: map[string]string{"X-Gitlab-Token": "other"}},
+		{name: "gitlab token prefix", provider: providerGitLab, header: map[string]string{"X-Gitlab-Token": secret[:3]}},
+		{name: "gitlab missing token", provider: providerGitLab},
+		{name: "gitlab signature instead of a token", provider: providerGitLab, header: map[string]string{"X-Hub-Signature-256": valid}},
+
+		{name: "unknown provider", provider: "gitea", header: map[string]string{"X-Hub-Signature-256": valid, "X-Gitlab-Token": secret}},
+		{name: "no provider", header: map[string]string{"X-Hub-Signature-256": valid, "X-Gitlab-Token": secret}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			for key, value := range tt.header {
+				header.Set(key, value)
+			}
+			delivered := tt.body
+			if delivered == "" {
+				delivered = body
+			}
+			if got := verifySecret(tt.provider, header, []byte(delivered), secret); got != tt.want {
+				t.Errorf("verifySecret() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifySecretEmptyGitLabToken(t *testing.T) {
+	header := http.Header{}
+	header.Set("X-Gitlab-Token", "")
+	if verifySecret(providerGitLab, header, nil, "") {
+		t.Error("an empty GitLab token matched an empty secret")
+	}
+}
+
+func TestDetectProvider(t *testing.T) {
+	tests := []struct {
+		header   map[string]string
+		provider string
+		event    string
+	}{
+		{map[string]string{"X-GitHub-Event": "push"}, providerGitHub, "push"},
+		{map[string]string{"X-Gitlab-Event": "Push Hook"}, providerGitLab, "Push Hook"},
+		{map[string]string{"X-Event-Key": "repo:push"}, providerBitbucket, "repo:push"},
+		{map[string]string{"X-Gitea-Event": "push"}, "", ""},
+		{nil, "", ""},
+	}
+
+	for _, tt := range tests {
+		header := http.Header{}
+		for key, value := range tt.header {
+			header.Set(key, value)
+		}
+		if provider, event := detectProvider(header); provider != tt.provider || event != tt.event {
+			t.Errorf("detectProvider(%v) = %q, %q, want %q, %q", tt.header, provider, event, tt.provider, tt.event)
+		}
+	}
+}
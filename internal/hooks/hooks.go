@@ -0,0 +1,276 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sharedvolume/volume-syncer/internal/metrics"
+	"github.com/sharedvolume/volume-syncer/internal/models"
+	"github.com/sharedvolume/volume-syncer/internal/procgroup"
+	"github.com/sharedvolume/volume-syncer/internal/transport"
+	"github.com/sharedvolume/volume-syncer/internal/validation"
+)
+
+// maxOutputSize is the number of bytes of hook output kept in the job record
+const maxOutputSize = 16 * 1024
+
+// hooksFile is the on-disk format of the hooks file
+type hooksFile struct {
+	Commands map[string][]string `json:"commands"`
+}
+
+// JobInfo describes the job a hook runs for. It is passed to commands as environment
+// variables and to HTTP hooks as the JSON request body.
+type JobInfo struct {
+	JobID      string `json:"jobId"`
+	Phase      string `json:"phase"`
+	SourceType string `json:"sourceType"`
+	TargetPath string `json:"targetPath"`
+	Status     string `json:"status,omitempty"`  // post-sync only
+	Changed    *bool  `json:"changed,omitempty"` // post-sync only
+	Error      string `json:"error,omitempty"`   // post-sync only
+
+	Result *models.SyncResult `json:"result,omitempty"` // post-sync only, after successful syncs
+}
+
+// Runner executes pre- and post-sync hooks
+type Runner struct {
+	commands map[string][]string
+	timeout  time.Duration
+	client   *http.Client
+}
+
+// NewRunner creates a runner for the given allowlisted commands, whose HTTP hooks use the
+// transports of the factory
+func NewRunner(commands map[string][]string, timeout time.Duration, transports *transport.Factory) *Runner {
+	return &Runner{
+		commands: commands,
+		timeout:  timeout,
+		client:   transports.Client(0),
+	}
+}
+
+// LoadFile loads the allowlisted hook commands from a JSON file into a new runner
+func LoadFile(path string, timeout time.Duration, transports *transport.Factory) (*Runner, error) {
+	log.Printf("[HOOKS] Loading hook commands from %s", path)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read hooks file: %w", err)
+	}
+
+	var file hooksFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse hooks file: %w", err)
+	}
+	for name, command := range file.Commands {
+		if len(command) == 0 || command[0] == "" {
+			return nil, fmt.Errorf("hook command %s: command is required", name)
+		}
+	}
+
+	log.Printf("[HOOKS] Loaded %d hook commands", len(file.Commands))
+	return NewRunner(file.Commands, timeout, transports), nil
+}
+
+// Validate checks that every hook is either a known command or a valid HTTP call
+func (r *Runner) Validate(hooks *models.Hooks) error {
+	if hooks == nil {
+		return nil
+	}
+	for phase, list := range map[string][]models.Hook{models.HookPhasePre: hooks.Pre, models.HookPhasePost: hooks.Post} {
+		for i, hook := range list {
+			if err := r.validateHook(hook); err != nil {
+				return validation.Nest(fmt.Sprintf("%s[%d]", phase, i), err)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *Runner) validateHook(hook models.Hook) error {
+	switch {
+	case hook.Command != "" && hook.HTTP != nil:
+		return fmt.Errorf("command and http cannot be provided at the same time")
+	case hook.Command != "":
+		if _, ok := r.commands[hook.Command]; !ok {
+			return validation.Field("command", fmt.Errorf("hook command %q is not configured", hook.Command))
+		}
+	case hook.HTTP != nil:
+		if err := validation.URL(hook.HTTP.URL, "http", "https"); err != nil {
+			return validation.Field("http.url", err)
+		}
+	default:
+		return fmt.Errorf("command or http is required")
+	}
+	return nil
+}
+
+// Run executes the hooks of one phase in order and stops at the first failure
+func (r *Runner) Run(hooks []models.Hook, info JobInfo) ([]models.HookResult, error) {
+	var results []models.HookResult
+	for _, hook := range hooks {
+		result := models.HookResult{Phase: info.Phase}
+
+		var output string
+		var err error
+		if hook.HTTP != nil {
+			result.Name = hook.HTTP.URL
+			log.Printf("[HOOKS] Running %s-sync HTTP hook %s %s", info.Phase, httpMethod(hook.HTTP), hook.HTTP.URL)
+			output, err = r.runHTTP(hook.HTTP, info)
+		} else {
+			result.Name = hook.Command
+			log.Printf("[HOOKS] Running %s-sync command %s", info.Phase, hook.Command)
+			output, err = r.runCommand(hook.Command, info)
+		}
+
+		result.Output = output
+		if err != nil {
+			log.Printf("[HOOKS] ERROR: Hook %s failed: %v", result.Name, err)
+			result.Status = models.JobStatusFailed
+			result.Error = err.Error()
+			results = append(results, result)
+			return results, fmt.Errorf("%s-sync hook %s failed: %w", info.Phase, result.Name, err)
+		}
+		log.Printf("[HOOKS] Hook %s completed successfully", result.Name)
+		result.Status = models.JobStatusSucceeded
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// runCommand runs an allowlisted command in the target directory
+func (r *Runner) runCommand(name string, info JobInfo) (string, error) {
+	command, ok := r.commands[name]
+	if !ok {
+		return "", fmt.Errorf("hook command %q is not configured", name)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+	ctx = metrics.WithTarget(ctx, info.SourceType, info.TargetPath)
+
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	procgroup.Configure(cmd)
+	if stat, err := os.Stat(info.TargetPath); err == nil && stat.IsDir() {
+		cmd.Dir = info.TargetPath
+	}
+	cmd.Env = append(os.Environ(), info.env()...)
+	output := &limitedBuffer{limit: maxOutputSize}
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	err := cmd.Run()
+	metrics.CommandExited(ctx, "hook", err)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return output.String(), fmt.Errorf("command timed out after %v", r.timeout)
+		}
+		return output.String(), err
+	}
+	return output.String(), nil
+}
+
+// runHTTP sends the job summary to the hook URL
+func (r *Runner) runHTTP(hook *models.HTTPHook, info JobInfo) (string, error) {
+	body, err := json.Marshal(info)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode hook payload: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, httpMethod(hook), hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range hook.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", fmt.Errorf("request timed out after %v", r.timeout)
+		}
+		return "", fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	output := &limitedBuffer{limit: maxOutputSize}
+	fmt.Fprintf(output, "%s\n", resp.Status)
+	io.Copy(output, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return output.String(), fmt.Errorf("unexpected response status: %s", resp.Status)
+	}
+	return output.String(), nil
+}
+
+// env returns the job information as environment variables for hook commands
+func (info JobInfo) env() []string {
+	env := []string{
+		"SYNC_JOB_ID=" + info.JobID,
+		"SYNC_HOOK_PHASE=" + info.Phase,
+		"SYNC_SOURCE_TYPE=" + info.SourceType,
+		"SYNC_TARGET_PATH=" + info.TargetPath,
+	}
+	if info.Status != "" {
+		env = append(env, "SYNC_STATUS="+info.Status, "SYNC_ERROR="+info.Error)
+	}
+	if info.Changed != nil {
+		env = append(env, "SYNC_CHANGED="+strconv.FormatBool(*info.Changed))
+	}
+	if info.Result != nil {
+		env = append(env,
+			"SYNC_FILES_ADDED="+strconv.Itoa(info.Result.FilesAdded),
+			"SYNC_FILES_CHANGED="+strconv.Itoa(info.Result.FilesChanged),
+			"SYNC_FILES_DELETED="+strconv.Itoa(info.Result.FilesDeleted),
+			"SYNC_BYTES_TRANSFERRED="+strconv.FormatInt(info.Result.BytesTransferred, 10),
+			"SYNC_REVISION="+info.Result.Revision,
+		)
+	}
+	return env
+}
+
+func httpMethod(hook *models.HTTPHook) string {
+	if hook.Method == "" {
+		return http.MethodPost
+	}
+	return strings.ToUpper(hook.Method)
+}
+
+// limitedBuffer keeps the first limit bytes written to it and discards the rest
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if remaining := b.limit - b.buf.Len(); remaining < len(p) {
+		b.buf.Write(p[:max(remaining, 0)])
+		b.truncated = true
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+func (b *limitedBuffer) String() string {
+	if b.truncated {
+		return b.buf.String() + "\n... (output truncated)"
+	}
+	return b.buf.String()
+}
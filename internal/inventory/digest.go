@@ -0,0 +1,60 @@
+package inventory
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/sharedvolume/volume-syncer/internal/digest"
+)
+
+// AddDigests records the digest of the contents of each regular file of the inventory, read
+// below root with the algorithm. Files whose metadata match their entry in previous, which may be
+// nil, take its digest without being read, so that manifests are updated incrementally.
+func (i Inventory) AddDigests(root, algorithm string, previous Inventory) error {
+	for path, entry := range i {
+		if !entry.Mode.IsRegular() {
+			continue
+		}
+		if old, ok := previous[path]; ok && old.Digest != "" && sameMetadata(old, entry) {
+			entry.Digest = old.Digest
+		} else if err := entry.read(root, path, algorithm); err != nil {
+			return err
+		}
+		i[path] = entry
+	}
+	return nil
+}
+
+// ReadDigests records the digests of the regular files whose metadata match their entry in
+// recorded, so that Diff also finds content changes that kept the size, modification time and
+// mode. Files with other metadata differ from recorded anyway and are not read.
+func (i Inventory) ReadDigests(root, algorithm string, recorded Inventory) error {
+	for path, entry := range i {
+		if old, ok := recorded[path]; !ok || old.Digest == "" || !entry.Mode.IsRegular() || !sameMetadata(old, entry) {
+			continue
+		}
+		if err := entry.read(root, path, algorithm); err != nil {
+			return err
+		}
+		i[path] = entry
+	}
+	return nil
+}
+
+// read sets the digest of the entry to that of the file at the slash-separated path below root
+func (e *Entry) read(root, path, algorithm string) error {
+	h, ok := digest.New(algorithm)
+	if !ok {
+		return fmt.Errorf("unsupported hash algorithm %q", algorithm)
+	}
+	sum, err := digest.File(filepath.Join(root, filepath.FromSlash(path)), h)
+	if err != nil {
+		return fmt.Errorf("failed to hash %s: %w", path, err)
+	}
+	e.Digest = sum
+	return nil
+}
+
+func sameMetadata(a, b Entry) bool {
+	return a.Size == b.Size && a.ModTime.Equal(b.ModTime) && a.Mode == b.Mode
+}
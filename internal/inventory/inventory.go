@@ -0,0 +1,159 @@
+package inventory
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Entry describes one file of an inventory
+type Entry struct {
+	Size    int64       `json:"size"`
+	ModTime time.Time   `json:"modTime"`
+	Mode    fs.FileMode `json:"mode"`
+	Digest  string      `json:"digest,omitempty"` // Hex digest of the contents of regular files, when hashed
+}
+
+// Inventory maps the paths of the files below a directory, relative and slash-separated, to their metadata
+type Inventory map[string]Entry
+
+// Changes summarizes the differences between two inventories
+type Changes struct {
+	Added    int
+	Changed  int
+	Deleted  int
+	Bytes    int64 // Size of the added and changed files
+	Files    int   // Number of files in the newer inventory
+	Total    int64 // Size of the files in the newer inventory
+	ListHash string
+}
+
+// Scan lists the files below root, following root itself when it is a symbolic link. Git
+// metadata is left out, since it does not belong to the synced contents. A missing root
+// yields an empty inventory.
+func Scan(root string) (Inventory, error) {
+	inventory := Inventory{}
+	resolved, err := filepath.EvalSymlinks(root)
+	if errors.Is(err, os.ErrNotExist) {
+		return inventory, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = filepath.WalkDir(resolved, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" && path != resolved {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Name() == ".git" {
+			// Gitfile of a submodule
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(resolved, path)
+		if err != nil {
+			return err
+		}
+		inventory[filepath.ToSlash(rel)] = Entry{Size: info.Size(), ModTime: info.ModTime(), Mode: info.Mode()}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", root, err)
+	}
+	return inventory, nil
+}
+
+// Compare returns the changes from the older inventory to the newer one. Files count as changed
+// when their size, modification time or type differ.
+func Compare(older, newer Inventory) Changes {
+	var changes Changes
+	for path, entry := range newer {
+		changes.Files++
+		changes.Total += entry.Size
+		previous, ok := older[path]
+		switch {
+		case !ok:
+			changes.Added++
+			changes.Bytes += entry.Size
+		case previous.Size != entry.Size || !previous.ModTime.Equal(entry.ModTime) || previous.Mode.Type() != entry.Mode.Type():
+			changes.Changed++
+			changes.Bytes += entry.Size
+		}
+	}
+	for path := range older {
+		if _, ok := newer[path]; !ok {
+			changes.Deleted++
+		}
+	}
+	changes.ListHash = newer.Hash()
+	return changes
+}
+
+// Differences lists the paths that differ between two inventories, sorted
+type Differences struct {
+	Added    []string
+	Modified []string
+	Removed  []string
+}
+
+// Empty reports whether the inventories are identical
+func (d Differences) Empty() bool {
+	return len(d.Added) == 0 && len(d.Modified) == 0 && len(d.Removed) == 0
+}
+
+// Diff returns the paths added, modified and removed from the older inventory to the newer one.
+// Unlike Compare, permission changes count as modifications, and so do different digests when
+// both inventories hold them.
+func Diff(older, newer Inventory) Differences {
+	var differences Differences
+	for path, entry := range newer {
+		previous, ok := older[path]
+		switch {
+		case !ok:
+			differences.Added = append(differences.Added, path)
+		case !sameMetadata(previous, entry) || (previous.Digest != "" && entry.Digest != "" && previous.Digest != entry.Digest):
+			differences.Modified = append(differences.Modified, path)
+		}
+	}
+	for path := range older {
+		if _, ok := newer[path]; !ok {
+			differences.Removed = append(differences.Removed, path)
+		}
+	}
+	sort.Strings(differences.Added)
+	sort.Strings(differences.Modified)
+	sort.Strings(differences.Removed)
+	return differences
+}
+
+// Hash returns a hex SHA-256 digest of the sorted file paths, types and sizes, which identifies
+// the file list independent of modification times
+func (i Inventory) Hash() string {
+	paths := make([]string, 0, len(i))
+	for path := range i {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	hash := sha256.New()
+	for _, path := range paths {
+		entry := i[path]
+		fmt.Fprintf(hash, "%s\x00%s\x00%d\n", path, entry.Mode.Type(), entry.Size)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
@@ -0,0 +1,61 @@
+package inventory
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// manifestSuffix is the suffix of the manifest file next to the target
+const manifestSuffix = ".sync-manifest.json"
+
+// Manifest records the files of a target after a successful sync, so that later changes to the
+// target can be detected
+type Manifest struct {
+	JobID     string    `json:"jobId,omitempty"` // Job that synced the target, empty after a rollback
+	SyncedAt  time.Time `json:"syncedAt"`
+	Revision  string    `json:"revision,omitempty"`  // Source revision synced, the commit of Git syncs
+	Algorithm string    `json:"algorithm,omitempty"` // Hash algorithm of the digests of the files, if any
+	Files     Inventory `json:"files"`
+}
+
+// ManifestPath returns the manifest file of the target. It is a sibling of the target, so that
+// it is neither synced over nor part of the target contents.
+func ManifestPath(targetPath string) string {
+	return filepath.Clean(targetPath) + manifestSuffix
+}
+
+// SaveManifest replaces the manifest of the target
+func SaveManifest(targetPath string, manifest *Manifest) error {
+	path := ManifestPath(targetPath)
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return nil
+}
+
+// LoadManifest reads the manifest of the target. The error wraps os.ErrNotExist when the target
+// was never synced.
+func LoadManifest(targetPath string) (*Manifest, error) {
+	data, err := os.ReadFile(ManifestPath(targetPath))
+	if err != nil {
+		return nil, err
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %w", ManifestPath(targetPath), err)
+	}
+	return &manifest, nil
+}
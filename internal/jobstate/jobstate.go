@@ -0,0 +1,148 @@
+package jobstate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sharedvolume/volume-syncer/internal/models"
+)
+
+// recordSuffix is the suffix of the job record files
+const recordSuffix = ".json"
+
+// jobIDRegex matches the IDs of jobs, which name the record files
+var jobIDRegex = regexp.MustCompile(`^[0-9a-f]+$`)
+
+// Record is the persisted state of a job
+type Record struct {
+	Job models.SyncJob `json:"job"`
+
+	// Request is kept while the job runs, so that it can be resumed after a restart. It
+	// holds the credentials of the request.
+	Request *models.SyncRequest `json:"request,omitempty"`
+
+	// Initiator of the request, which the request does not persist
+	Initiator string `json:"initiator,omitempty"`
+}
+
+// Store persists job records in a directory, one file per job. A nil store is disabled.
+type Store struct {
+	dir string
+}
+
+// New opens the job state directory, or returns nil when dir is empty
+func New(dir string) (*Store, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	// Records of running jobs hold request credentials
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create job state directory: %w", err)
+	}
+	return &Store{dir: dir}, nil
+}
+
+func (s *Store) path(id string) string {
+	return filepath.Join(s.dir, id+recordSuffix)
+}
+
+// Save replaces the record of the job
+func (s *Store) Save(record *Record) error {
+	if s == nil {
+		return nil
+	}
+	if !jobIDRegex.MatchString(record.Job.ID) {
+		return fmt.Errorf("invalid job ID %q", record.Job.ID)
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode job %s: %w", record.Job.ID, err)
+	}
+
+	path := s.path(record.Job.ID)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write job %s: %w", record.Job.ID, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write job %s: %w", record.Job.ID, err)
+	}
+	return nil
+}
+
+// Check verifies that records can be listed and written, e.g. that the volume of the directory
+// is still mounted and writable
+func (s *Store) Check() error {
+	if s == nil {
+		return nil
+	}
+	if _, err := os.ReadDir(s.dir); err != nil {
+		return fmt.Errorf("failed to read job state directory: %w", err)
+	}
+	probe, err := os.CreateTemp(s.dir, ".probe-*")
+	if err != nil {
+		return fmt.Errorf("job state directory is not writable: %w", err)
+	}
+	probe.Close()
+	return os.Remove(probe.Name())
+}
+
+// Remove deletes the record of the job
+func (s *Store) Remove(id string) error {
+	if s == nil {
+		return nil
+	}
+	if err := os.Remove(s.path(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove job %s: %w", id, err)
+	}
+	return nil
+}
+
+// Load reads all job records, oldest first. Unreadable records are skipped.
+func (s *Store) Load() ([]Record, error) {
+	if s == nil {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read job state directory: %w", err)
+	}
+
+	var records []Record
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), recordSuffix)
+		if !ok || !entry.Type().IsRegular() || !jobIDRegex.MatchString(id) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.dir, entry.Name()))
+		if err != nil {
+			log.Printf("[JOB STATE] WARNING: Failed to read job %s: %v", id, err)
+			continue
+		}
+		var record Record
+		if err := json.Unmarshal(data, &record); err != nil || record.Job.ID != id {
+			log.Printf("[JOB STATE] WARNING: Skipping invalid record of job %s", id)
+			continue
+		}
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool { return requestedAt(records[i]).Before(requestedAt(records[j])) })
+	return records, nil
+}
+
+// requestedAt returns when the job of the record was requested
+func requestedAt(record Record) time.Time {
+	if record.Job.QueuedAt != nil {
+		return *record.Job.QueuedAt
+	}
+	return record.Job.StartedAt
+}
@@ -0,0 +1,201 @@
+package kube
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// serviceAccountDir holds the credentials mounted into pods
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// requestTimeout limits API requests other than watches
+const requestTimeout = 30 * time.Second
+
+// ErrNotInCluster is returned when the process does not run in a Kubernetes pod
+var ErrNotInCluster = errors.New("not running in a Kubernetes cluster")
+
+// Client calls the Kubernetes API server with the service account of the pod
+type Client struct {
+	baseURL    string
+	tokenPath  string
+	namespace  string
+	httpClient *http.Client
+}
+
+// StatusError is an error response of the API server
+type StatusError struct {
+	Code    int    `json:"code"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("kubernetes API returned HTTP %d (%s): %s", e.Code, e.Reason, e.Message)
+}
+
+// IsNotFound reports whether the error is a 404 response of the API server
+func IsNotFound(err error) bool {
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && statusErr.Code == http.StatusNotFound
+}
+
+// InCluster creates a client from the service account mounted into the pod
+func InCluster() (*Client, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, ErrNotInCluster
+	}
+	ca, err := os.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cluster CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.New("invalid cluster CA")
+	}
+	namespace, err := os.ReadFile(serviceAccountDir + "/namespace")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pod namespace: %w", err)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	return &Client{
+		baseURL:    "https://" + net.JoinHostPort(host, port),
+		tokenPath:  serviceAccountDir + "/token",
+		namespace:  strings.TrimSpace(string(namespace)),
+		httpClient: &http.Client{Transport: transport},
+	}, nil
+}
+
+// Namespace returns the namespace of the pod
+func (c *Client) Namespace() string {
+	return c.namespace
+}
+
+// Get decodes the resource at the API path into out
+func (c *Client) Get(ctx context.Context, path string, out any) error {
+	return c.do(ctx, http.MethodGet, path, "", nil, out)
+}
+
+// Create posts the resource to the collection at the API path
+func (c *Client) Create(ctx context.Context, path string, resource any) error {
+	body, err := json.Marshal(resource)
+	if err != nil {
+		return err
+	}
+	return c.do(ctx, http.MethodPost, path, "application/json", body, nil)
+}
+
+// MergePatch applies a JSON merge patch to the resource at the API path
+func (c *Client) MergePatch(ctx context.Context, path string, patch any) error {
+	body, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+	return c.do(ctx, http.MethodPatch, path, "application/merge-patch+json", body, nil)
+}
+
+// WatchEvent is a change of a watched resource
+type WatchEvent struct {
+	Type   string          `json:"type"` // ADDED, MODIFIED, DELETED, BOOKMARK or ERROR
+	Object json.RawMessage `json:"object"`
+}
+
+// Watch streams the changes of the collection at the API path after the resource version to the
+// handler until the context is cancelled, the server closes the watch or the handler fails
+func (c *Client) Watch(ctx context.Context, path, resourceVersion string, handle func(WatchEvent) error) error {
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+	path += separator + "watch=true&allowWatchBookmarks=true&resourceVersion=" + resourceVersion
+	resp, err := c.send(ctx, http.MethodGet, path, "", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(bufio.NewReader(resp.Body))
+	for {
+		var event WatchEvent
+		if err := decoder.Decode(&event); err != nil {
+			if errors.Is(err, io.EOF) || ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("failed to read watch event: %w", err)
+		}
+		if event.Type == "ERROR" {
+			statusErr := &StatusError{}
+			json.Unmarshal(event.Object, statusErr)
+			return statusErr
+		}
+		if err := handle(event); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *Client) do(ctx context.Context, method, path, contentType string, body []byte, out any) error {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	resp, err := c.send(ctx, method, path, contentType, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("invalid response of %s: %w", path, err)
+	}
+	return nil
+}
+
+// send performs the request and returns the response of successful requests
+func (c *Client) send(ctx context.Context, method, path, contentType string, body []byte) (*http.Response, error) {
+	token, err := os.ReadFile(c.tokenPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account token: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	req.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach kubernetes API: %w", err)
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		statusErr := &StatusError{}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		if json.Unmarshal(data, statusErr) != nil || statusErr.Message == "" {
+			statusErr.Message = strings.TrimSpace(string(data))
+		}
+		statusErr.Code = resp.StatusCode
+		return nil, statusErr
+	}
+	return resp, nil
+}
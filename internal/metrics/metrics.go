@@ -0,0 +1,192 @@
+package metrics
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sharedvolume/volume-syncer/internal/models"
+)
+
+// Sync results used as metric label values
+const (
+	ResultChanged   = "changed"
+	ResultUnchanged = "unchanged"
+	ResultFailed    = "failed"
+)
+
+var (
+	syncTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "volume_syncer_sync_total",
+		Help: "Total number of completed sync operations by source type and result.",
+	}, []string{"source", "result"})
+
+	syncDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "volume_syncer_sync_duration_seconds",
+		Help:    "Duration of sync operations by source type and result.",
+		Buckets: prometheus.ExponentialBuckets(0.5, 2, 14),
+	}, []string{"source", "result"})
+
+	syncRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "volume_syncer_sync_retries_total",
+		Help: "Total number of sync attempts repeated after transient failures by source type.",
+	}, []string{"source"})
+
+	targetSyncTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "volume_syncer_target_sync_total",
+		Help: "Total number of completed sync operations by source type, hashed target path and result.",
+	}, []string{"source", "target", "result"})
+
+	targetBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "volume_syncer_target_bytes_transferred_total",
+		Help: "Size of the files added and changed by successful syncs by source type and hashed target path.",
+	}, []string{"source", "target"})
+
+	targetObjects = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "volume_syncer_target_objects_transferred_total",
+		Help: "Number of files added and changed by successful syncs by source type and hashed target path.",
+	}, []string{"source", "target"})
+
+	targetRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "volume_syncer_target_retries_total",
+		Help: "Total number of sync attempts repeated after transient failures by source type and hashed target path.",
+	}, []string{"source", "target"})
+
+	targetLastSuccess = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "volume_syncer_target_last_success_timestamp_seconds",
+		Help: "Unix time of the last successful sync by source type and hashed target path.",
+	}, []string{"source", "target"})
+
+	subprocessExits = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "volume_syncer_subprocess_exits_total",
+		Help: "Exits of the git, rsync, plugin and hook commands run by syncs by source type, hashed target path, command and exit code.",
+	}, []string{"source", "target", "command", "code"})
+
+	syncInProgress = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "volume_syncer_sync_in_progress",
+		Help: "Number of sync operations currently running.",
+	})
+
+	progressMutex  sync.Mutex
+	progressSource ProgressFunc
+)
+
+// ProgressFunc returns the progress of the running sync, false while idle
+type ProgressFunc func() (models.SyncProgress, bool)
+
+func init() {
+	gauges := []struct {
+		name, help string
+		value      func(models.SyncProgress) int64
+	}{
+		{"volume_syncer_sync_progress_files", "Files completed by the running sync.", func(p models.SyncProgress) int64 { return p.FilesDone }},
+		{"volume_syncer_sync_progress_files_total", "Files known to the running sync, growing while its sources are listed.", func(p models.SyncProgress) int64 { return p.FilesTotal }},
+		{"volume_syncer_sync_progress_bytes", "Bytes transferred by the running sync.", func(p models.SyncProgress) int64 { return p.BytesDone }},
+		{"volume_syncer_sync_progress_bytes_total", "Bytes known to the running sync, 0 when its sources cannot tell.", func(p models.SyncProgress) int64 { return p.BytesTotal }},
+	}
+	for _, gauge := range gauges {
+		promauto.NewGaugeFunc(prometheus.GaugeOpts{Name: gauge.name, Help: gauge.help}, func() float64 {
+			progressMutex.Lock()
+			source := progressSource
+			progressMutex.Unlock()
+			if source == nil {
+				return 0
+			}
+			if progress, ok := source(); ok {
+				return float64(gauge.value(progress))
+			}
+			return 0
+		})
+	}
+}
+
+// ObserveProgress sets the source of the progress gauges
+func ObserveProgress(source ProgressFunc) {
+	progressMutex.Lock()
+	defer progressMutex.Unlock()
+	progressSource = source
+}
+
+// SyncStarted records the start of a sync operation
+func SyncStarted() {
+	syncInProgress.Inc()
+}
+
+// SyncFinished records the outcome of the finished job, with the files and bytes it transferred
+func SyncFinished(job *models.SyncJob, result string) {
+	syncInProgress.Dec()
+	var duration time.Duration
+	if job.FinishedAt != nil {
+		duration = job.FinishedAt.Sub(job.StartedAt)
+	}
+	syncTotal.WithLabelValues(job.SourceType, result).Inc()
+	syncDuration.WithLabelValues(job.SourceType, result).Observe(duration.Seconds())
+
+	target := TargetLabel(job.TargetPath)
+	targetSyncTotal.WithLabelValues(job.SourceType, target, result).Inc()
+	if result == ResultFailed {
+		return
+	}
+	if job.FinishedAt != nil {
+		targetLastSuccess.WithLabelValues(job.SourceType, target).Set(float64(job.FinishedAt.Unix()))
+	}
+	if job.Result != nil {
+		targetBytes.WithLabelValues(job.SourceType, target).Add(float64(job.Result.BytesTransferred))
+		targetObjects.WithLabelValues(job.SourceType, target).Add(float64(job.Result.FilesAdded + job.Result.FilesChanged))
+	}
+}
+
+// SyncRetried records a sync attempt repeated after a transient failure
+func SyncRetried(source, targetPath string) {
+	syncRetries.WithLabelValues(source).Inc()
+	targetRetries.WithLabelValues(source, TargetLabel(targetPath)).Inc()
+}
+
+// TargetLabel returns the label value of a target path: the first 12 hex digits of its SHA-256,
+// which keeps the paths out of the metrics and the label values short
+func TargetLabel(targetPath string) string {
+	sum := sha256.Sum256([]byte(targetPath))
+	return hex.EncodeToString(sum[:6])
+}
+
+type contextKey struct{}
+
+// jobLabels are the labels of the job whose commands run with the context
+type jobLabels struct {
+	source, target string
+}
+
+// WithTarget returns a context carrying the source type and target path of the job to the
+// syncers, which record the exits of their commands with it
+func WithTarget(ctx context.Context, source, targetPath string) context.Context {
+	return context.WithValue(ctx, contextKey{}, jobLabels{source: source, target: TargetLabel(targetPath)})
+}
+
+// CommandExited records the exit of a command with the error Run or Wait returned. The code is
+// "signal" for commands killed by a signal, e.g. on timeouts, and "error" for commands that could
+// not be started. Commands run with contexts without a target are not recorded.
+func CommandExited(ctx context.Context, command string, err error) {
+	labels, ok := ctx.Value(contextKey{}).(jobLabels)
+	if !ok {
+		return
+	}
+	code := "0"
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+	case errors.As(err, &exitErr) && exitErr.ExitCode() >= 0:
+		code = strconv.Itoa(exitErr.ExitCode())
+	case errors.As(err, &exitErr):
+		code = "signal"
+	default:
+		code = "error"
+	}
+	subprocessExits.WithLabelValues(labels.source, labels.target, command, code).Inc()
+}
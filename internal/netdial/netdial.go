@@ -0,0 +1,179 @@
+package netdial
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// Address family preferences of the connections to the sources
+const (
+	FamilyAny        = ""            // the order of the resolver, both families
+	FamilyIPv4       = "ipv4"        // IPv4 addresses only
+	FamilyIPv6       = "ipv6"        // IPv6 addresses only
+	FamilyPreferIPv4 = "prefer-ipv4" // IPv4 addresses first
+	FamilyPreferIPv6 = "prefer-ipv6" // IPv6 addresses first
+)
+
+const (
+	dnsServerEnv = "VOLUME_SYNCER_DNS_SERVER"
+	ipFamilyEnv  = "VOLUME_SYNCER_IP_FAMILY"
+)
+
+// Resolver resolves the host names of the sources with its DNS server and orders their addresses
+// by the family preference. A nil resolver uses the resolver of the system and the order of its
+// answers.
+type Resolver struct {
+	server   string // host:port of the DNS server, the servers of the system when empty
+	family   string
+	resolver *net.Resolver
+}
+
+// current is the resolver of the connections to the sources, configured at startup
+var current atomic.Pointer[Resolver]
+
+// New creates the resolver of the DNS server, an IP address with an optional port, and the
+// address family preference. It returns nil when neither is set.
+func New(server, family string) (*Resolver, error) {
+	switch family {
+	case FamilyAny, FamilyIPv4, FamilyIPv6, FamilyPreferIPv4, FamilyPreferIPv6:
+	default:
+		return nil, fmt.Errorf("address family %q is invalid, expected ipv4, ipv6, prefer-ipv4 or prefer-ipv6", family)
+	}
+	if server == "" && family == FamilyAny {
+		return nil, nil
+	}
+
+	r := &Resolver{family: family, resolver: net.DefaultResolver}
+	if server != "" {
+		host, port, err := net.SplitHostPort(server)
+		if err != nil {
+			host, port = strings.Trim(server, "[]"), "53"
+		}
+		if net.ParseIP(host) == nil {
+			return nil, fmt.Errorf("DNS server %q must be an IP address with an optional port, e.g. 10.96.0.10:53", server)
+		}
+		r.server = net.JoinHostPort(host, port)
+		r.resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, network, r.server)
+			},
+		}
+	}
+	return r, nil
+}
+
+// FromEnv returns the resolver passed to a helper process with Env
+func FromEnv() (*Resolver, error) {
+	return New(os.Getenv(dnsServerEnv), os.Getenv(ipFamilyEnv))
+}
+
+// SetDefault sets the resolver of the connections to the sources
+func SetDefault(r *Resolver) {
+	current.Store(r)
+}
+
+// Default returns the resolver of the connections to the sources, nil for the system resolver
+func Default() *Resolver {
+	return current.Load()
+}
+
+// String describes the resolver, e.g. "DNS server 10.96.0.10:53, prefer-ipv4"
+func (r *Resolver) String() string {
+	if r == nil {
+		return "system"
+	}
+	var settings []string
+	if r.server != "" {
+		settings = append(settings, "DNS server "+r.server)
+	}
+	if r.family != FamilyAny {
+		settings = append(settings, r.family)
+	}
+	return strings.Join(settings, ", ")
+}
+
+// Env returns the environment passing the resolver to a helper process
+func (r *Resolver) Env() []string {
+	if r == nil {
+		return nil
+	}
+	return []string{dnsServerEnv + "=" + r.server, ipFamilyEnv + "=" + r.family}
+}
+
+// LookupHost returns the addresses of the host in the order of the family preference
+func (r *Resolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if r == nil {
+		return net.DefaultResolver.LookupHost(ctx, host)
+	}
+
+	network := "ip"
+	switch r.family {
+	case FamilyIPv4:
+		network = "ip4"
+	case FamilyIPv6:
+		network = "ip6"
+	}
+	ips, err := r.resolver.LookupIP(ctx, network, host)
+	if err != nil {
+		// The resolver reports the servers of the system, whose address it dialed
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && r.server != "" {
+			dnsErr.Server = r.server
+		}
+		return nil, err
+	}
+
+	var first, second []string
+	for _, ip := range ips {
+		if (ip.To4() != nil) == (r.family != FamilyPreferIPv6) {
+			first = append(first, ip.String())
+		} else {
+			second = append(second, ip.String())
+		}
+	}
+	return append(first, second...), nil
+}
+
+// DialContext connects to the address with the dialer, trying the addresses of its host in the
+// order of the family preference
+func (r *Resolver) DialContext(ctx context.Context, dialer *net.Dialer, network, address string) (net.Conn, error) {
+	if r == nil {
+		return dialer.DialContext(ctx, network, address)
+	}
+	host, port, err := net.SplitHostPort(address)
+	if err != nil || net.ParseIP(host) != nil {
+		return dialer.DialContext(ctx, network, address)
+	}
+
+	addresses, err := r.LookupHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(addresses) == 0 {
+		return nil, &net.DNSError{Err: "no suitable address", Name: host, IsNotFound: true}
+	}
+	var errs []error
+	for _, ip := range addresses {
+		conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip, port))
+		if err == nil {
+			return conn, nil
+		}
+		errs = append(errs, err)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, errors.Join(errs...)
+}
+
+// DialContext connects to the address with the dialer and the default resolver
+func DialContext(ctx context.Context, dialer *net.Dialer, network, address string) (net.Conn, error) {
+	return Default().DialContext(ctx, dialer, network, address)
+}
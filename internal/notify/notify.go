@@ -0,0 +1,309 @@
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/sharedvolume/volume-syncer/internal/audit"
+	"github.com/sharedvolume/volume-syncer/internal/models"
+	"github.com/sharedvolume/volume-syncer/internal/transport"
+	"github.com/sharedvolume/volume-syncer/internal/validation"
+	"sigs.k8s.io/yaml"
+)
+
+// Events notifications are sent for
+const (
+	EventFailure = "failure"
+	EventSuccess = "success"
+)
+
+// Types of notification sinks
+const (
+	TypeSlack   = "slack"
+	TypeEmail   = "email"
+	TypeWebhook = "webhook"
+)
+
+// sendAttempts is the number of attempts to deliver a notification
+const sendAttempts = 3
+
+// sendTimeout bounds each attempt to deliver a notification
+const sendTimeout = 30 * time.Second
+
+// Templates of the messages and email subjects of sinks that do not set their own
+const (
+	defaultMessage = `Sync job {{.Job.ID}} {{.Job.Status}} on {{.Host}}: {{.Job.SourceType}} source into {{.Job.TargetPath}}` +
+		`{{with .Initiator}}, requested by {{.}}{{end}}{{with .Job.Error}}` + "\n" + `Error: {{.}}{{end}}`
+	defaultSubject = `[volume-syncer] Sync of {{.Job.TargetPath}} {{.Job.Status}}`
+)
+
+// notificationsFile is the on-disk format of the notifications file
+type notificationsFile struct {
+	Notifications []Config `json:"notifications"`
+}
+
+// Config configures a notification sink
+type Config struct {
+	Name     string   `json:"name"`
+	Type     string   `json:"type"`               // slack, email or webhook
+	Events   []string `json:"events,omitempty"`   // failure and/or success (default: failure)
+	Template string   `json:"template,omitempty"` // text/template of the message, rendering Data
+
+	Slack   *SlackConfig   `json:"slack,omitempty"`
+	Email   *EmailConfig   `json:"email,omitempty"`
+	Webhook *WebhookConfig `json:"webhook,omitempty"`
+}
+
+// SlackConfig posts the message to a Slack incoming webhook
+type SlackConfig struct {
+	WebhookURL string `json:"webhookUrl"`
+}
+
+// EmailConfig sends the message by email through an SMTP server, with STARTTLS when the server
+// offers it
+type EmailConfig struct {
+	Server       string   `json:"server"` // host:port, e.g. smtp.example.com:587
+	From         string   `json:"from"`
+	To           []string `json:"to"`
+	Username     string   `json:"username,omitempty"`
+	Password     string   `json:"password,omitempty"`
+	PasswordFile string   `json:"passwordFile,omitempty"` // File holding the password, e.g. of a mounted Secret
+	Subject      string   `json:"subject,omitempty"`      // text/template of the subject, rendering Data
+}
+
+// WebhookConfig posts the message and the job as JSON to a URL
+type WebhookConfig struct {
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// Data is what the templates render
+type Data struct {
+	Event     string         // failure or success
+	Job       models.SyncJob // finished job, with its error redacted
+	Initiator string         // who requested the sync, e.g. "schedule nightly"
+	Sources   []string       // locations synced from, without credentials
+	Host      string         // host name of the server
+	Duration  time.Duration  // time the job ran, rounded to the second
+}
+
+// Notifier sends notifications about finished sync jobs to the configured sinks. A nil notifier is
+// disabled.
+type Notifier struct {
+	sinks   []*sink
+	host    string
+	pending sync.WaitGroup
+}
+
+// sink is a configured notification sink with its parsed templates
+type sink struct {
+	Config
+	events  map[string]bool
+	message *template.Template
+	subject *template.Template // email only
+	send    func(data Data, message, subject string) error
+}
+
+// LoadFile loads the notification sinks from a YAML or JSON file. Slack and webhook sinks use the
+// transports of the factory.
+func LoadFile(path string, transports *transport.Factory) (*Notifier, error) {
+	log.Printf("[NOTIFY] Loading notifications from %s", path)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read notifications file: %w", err)
+	}
+	var file notificationsFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse notifications file: %w", err)
+	}
+
+	n := &Notifier{}
+	n.host, _ = os.Hostname()
+	names := map[string]bool{}
+	for i, config := range file.Notifications {
+		if config.Name == "" {
+			return nil, fmt.Errorf("notification %d: name is required", i+1)
+		}
+		if names[config.Name] {
+			return nil, fmt.Errorf("notification %s is configured twice", config.Name)
+		}
+		names[config.Name] = true
+		s, err := newSink(config, transports)
+		if err != nil {
+			return nil, fmt.Errorf("notification %s: %w", config.Name, err)
+		}
+		n.sinks = append(n.sinks, s)
+	}
+
+	log.Printf("[NOTIFY] Loaded %d notification sinks", len(n.sinks))
+	return n, nil
+}
+
+// newSink checks the configuration of the sink and parses its templates
+func newSink(config Config, transports *transport.Factory) (*sink, error) {
+	s := &sink{Config: config, events: map[string]bool{}}
+	if len(config.Events) == 0 {
+		s.events[EventFailure] = true
+	}
+	for _, event := range config.Events {
+		if event != EventFailure && event != EventSuccess {
+			return nil, fmt.Errorf("invalid event %q, expected failure or success", event)
+		}
+		s.events[event] = true
+	}
+
+	var err error
+	if s.message, err = parseTemplate("template", config.Template, defaultMessage); err != nil {
+		return nil, err
+	}
+	if _, err := render(s.message, Data{}); err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+
+	configured := 0
+	for _, set := range []bool{config.Slack != nil, config.Email != nil, config.Webhook != nil} {
+		if set {
+			configured++
+		}
+	}
+	if configured > 1 {
+		return nil, fmt.Errorf("only the settings of the %s type can be set", config.Type)
+	}
+	switch config.Type {
+	case TypeSlack:
+		if config.Slack == nil {
+			return nil, fmt.Errorf("slack settings are required")
+		}
+		if err := validation.URL(config.Slack.WebhookURL, "https", "http"); err != nil {
+			return nil, fmt.Errorf("slack.webhookUrl %w", err)
+		}
+		s.send = newHTTPSender(transports, config.Slack.WebhookURL, nil, slackPayload)
+	case TypeWebhook:
+		if config.Webhook == nil {
+			return nil, fmt.Errorf("webhook settings are required")
+		}
+		if err := validation.URL(config.Webhook.URL, "https", "http"); err != nil {
+			return nil, fmt.Errorf("webhook.url %w", err)
+		}
+		s.send = newHTTPSender(transports, config.Webhook.URL, config.Webhook.Headers, webhookPayload)
+	case TypeEmail:
+		if config.Email == nil {
+			return nil, fmt.Errorf("email settings are required")
+		}
+		if s.subject, err = parseTemplate("email.subject", config.Email.Subject, defaultSubject); err != nil {
+			return nil, err
+		}
+		if _, err := render(s.subject, Data{}); err != nil {
+			return nil, fmt.Errorf("invalid email.subject: %w", err)
+		}
+		if s.send, err = newEmailSender(config.Email); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("invalid type %q, expected slack, email or webhook", config.Type)
+	}
+	return s, nil
+}
+
+func parseTemplate(field, text, defaultText string) (*template.Template, error) {
+	if text == "" {
+		text = defaultText
+	}
+	parsed, err := template.New(field).Funcs(template.FuncMap{"join": strings.Join}).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", field, err)
+	}
+	return parsed, nil
+}
+
+// Sinks returns the names of the sinks
+func (n *Notifier) Sinks() []string {
+	if n == nil {
+		return nil
+	}
+	names := make([]string, len(n.sinks))
+	for i, s := range n.sinks {
+		names[i] = s.Name + " (" + s.Type + ")"
+	}
+	return names
+}
+
+// SyncFinished notifies the sinks of the event of the finished job in the background
+func (n *Notifier) SyncFinished(job *models.SyncJob, req *models.SyncRequest) {
+	if n == nil {
+		return
+	}
+	data := Data{Event: EventSuccess, Job: audit.RedactJob(*job), Host: n.host}
+	if job.Status == models.JobStatusFailed {
+		data.Event = EventFailure
+	}
+	if req != nil {
+		data.Initiator = req.Initiator
+		data.Sources = audit.Sources(req)
+	}
+	if job.FinishedAt != nil && !job.StartedAt.IsZero() {
+		data.Duration = job.FinishedAt.Sub(job.StartedAt).Round(time.Second)
+	}
+
+	for _, s := range n.sinks {
+		if !s.events[data.Event] {
+			continue
+		}
+		n.pending.Add(1)
+		go func(s *sink) {
+			defer n.pending.Done()
+			s.notify(data)
+		}(s)
+	}
+}
+
+// Wait waits for the notifications being sent
+func (n *Notifier) Wait() {
+	if n == nil {
+		return
+	}
+	n.pending.Wait()
+}
+
+// notify renders the templates and sends the notification, attempting it again after failures
+func (s *sink) notify(data Data) {
+	message, err := render(s.message, data)
+	if err != nil {
+		log.Printf("[NOTIFY] ERROR: Failed to render the message of %s: %v", s.Name, err)
+		return
+	}
+	var subject string
+	if s.subject != nil {
+		if subject, err = render(s.subject, data); err != nil {
+			log.Printf("[NOTIFY] ERROR: Failed to render the subject of %s: %v", s.Name, err)
+			return
+		}
+	}
+
+	for attempt := 1; attempt <= sendAttempts; attempt++ {
+		if err = s.send(data, message, subject); err == nil {
+			log.Printf("[NOTIFY] Sent %s notification of job %s to %s", data.Event, data.Job.ID, s.Name)
+			return
+		}
+		log.Printf("[NOTIFY] WARNING: Attempt %d of %d to notify %s failed: %v", attempt, sendAttempts, s.Name, err)
+		if attempt < sendAttempts {
+			time.Sleep(time.Duration(attempt) * 5 * time.Second)
+		}
+	}
+	log.Printf("[NOTIFY] ERROR: Failed to send %s notification of job %s to %s", data.Event, data.Job.ID, s.Name)
+}
+
+func render(tmpl *template.Template, data Data) (string, error) {
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out.String()), nil
+}
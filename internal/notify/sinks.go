@@ -0,0 +1,141 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sharedvolume/volume-syncer/internal/audit"
+	"github.com/sharedvolume/volume-syncer/internal/models"
+	"github.com/sharedvolume/volume-syncer/internal/transport"
+)
+
+// webhookBody is the JSON document posted to generic webhooks
+type webhookBody struct {
+	Event     string         `json:"event"`
+	Message   string         `json:"message"`
+	Job       models.SyncJob `json:"job"`
+	Initiator string         `json:"initiator,omitempty"`
+	Sources   []string       `json:"sources,omitempty"`
+	Host      string         `json:"host,omitempty"`
+}
+
+func slackPayload(_ Data, message string) any {
+	return map[string]string{"text": message}
+}
+
+func webhookPayload(data Data, message string) any {
+	return webhookBody{
+		Event:     data.Event,
+		Message:   message,
+		Job:       data.Job,
+		Initiator: data.Initiator,
+		Sources:   data.Sources,
+		Host:      data.Host,
+	}
+}
+
+// newHTTPSender returns a sender posting the payload of each notification as JSON to the URL
+func newHTTPSender(transports *transport.Factory, url string, headers map[string]string, payload func(Data, string) any) func(Data, string, string) error {
+	client := transports.Client(sendTimeout)
+	return func(data Data, message, _ string) error {
+		body, err := json.Marshal(payload(data, message))
+		if err != nil {
+			return fmt.Errorf("failed to encode notification: %w", err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("request failed: %w", audit.RedactError(err))
+		}
+		defer resp.Body.Close()
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("unexpected response status: %s", resp.Status)
+		}
+		return nil
+	}
+}
+
+// newEmailSender checks the email settings and returns a sender of plain text emails. The
+// password is read from its file for every email, so that rotated passwords are picked up.
+func newEmailSender(config *EmailConfig) (func(Data, string, string) error, error) {
+	host, _, err := net.SplitHostPort(config.Server)
+	if err != nil || host == "" {
+		return nil, fmt.Errorf("email.server must be host:port, e.g. smtp.example.com:587")
+	}
+	from, err := mail.ParseAddress(config.From)
+	if err != nil {
+		return nil, fmt.Errorf("email.from is not an email address: %w", err)
+	}
+	if len(config.To) == 0 {
+		return nil, errors.New("email.to requires at least one recipient")
+	}
+	to := make([]string, len(config.To))
+	for i, recipient := range config.To {
+		address, err := mail.ParseAddress(recipient)
+		if err != nil {
+			return nil, fmt.Errorf("email.to[%d] is not an email address: %w", i, err)
+		}
+		to[i] = address.Address
+	}
+	if config.Password != "" && config.PasswordFile != "" {
+		return nil, errors.New("email.password and email.passwordFile cannot be set at the same time")
+	}
+
+	return func(_ Data, message, subject string) error {
+		var auth smtp.Auth
+		if config.Username != "" {
+			password := config.Password
+			if config.PasswordFile != "" {
+				data, err := os.ReadFile(config.PasswordFile)
+				if err != nil {
+					return fmt.Errorf("failed to read email password: %w", err)
+				}
+				password = strings.TrimSpace(string(data))
+			}
+			// PlainAuth refuses to send the password without TLS, except to localhost
+			auth = smtp.PlainAuth("", config.Username, password, host)
+		}
+		return smtp.SendMail(config.Server, auth, from.Address, to, emailMessage(from, config.To, subject, message))
+	}, nil
+}
+
+// emailMessage formats a plain text email with CRLF line endings
+func emailMessage(from *mail.Address, to []string, subject, body string) []byte {
+	// Line breaks in the rendered subject would end the header
+	subject = strings.Join(strings.Fields(subject), " ")
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", from.String())
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	for _, line := range strings.Split(body, "\n") {
+		msg.WriteString(strings.TrimSuffix(line, "\r"))
+		msg.WriteString("\r\n")
+	}
+	return msg.Bytes()
+}
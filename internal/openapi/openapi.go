@@ -0,0 +1,179 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sharedvolume/volume-syncer/internal/models"
+)
+
+// sourceTypes maps the in-tree source types to their details, which make up the discriminated
+// union of the source schema
+var sourceTypes = []struct {
+	name    string
+	schema  string
+	details any
+}{
+	{"ssh", "SSHSource", models.SSHDetails{}},
+	{"git", "GitSource", models.GitCloneDetails{}},
+	{"http", "HTTPSource", models.HTTPDownloadDetails{}},
+	{"s3", "S3Source", models.S3Details{}},
+}
+
+var (
+	timeType   = reflect.TypeOf(time.Time{})
+	sourceType = reflect.TypeOf(models.Source{})
+)
+
+// Document returns the OpenAPI 3 document of the API. The schemas are generated from the request
+// and response models, so that the document follows them.
+var Document = sync.OnceValue(func() []byte {
+	g := &generator{schemas: map[string]any{}}
+	g.sourceSchemas()
+	document := map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "Volume Syncer API",
+			"version":     "1.0",
+			"description": "Synchronizes data from SSH, Git, HTTP and S3 sources into volume paths. Errors are reported in the response schema of the endpoint, with status \"error\" (or \"busy\" while a sync is in progress) and a message in error.",
+		},
+		"paths": g.paths(),
+		"components": map[string]any{
+			"schemas": g.schemas,
+			"securitySchemes": map[string]any{
+				"adminToken": map[string]any{"type": "http", "scheme": "bearer", "description": "SYNC_ADMIN_TOKEN"},
+			},
+		},
+	}
+	data, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	return data
+})
+
+// generator collects the component schemas referenced by the paths
+type generator struct {
+	schemas map[string]any
+}
+
+// ref returns a reference to the component schema of the model, generating it on first use
+func (g *generator) ref(model any) map[string]any {
+	return g.schema(reflect.TypeOf(model))
+}
+
+// schema returns the schema of the type, with structs as references to component schemas
+func (g *generator) schema(t reflect.Type) map[string]any {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Struct:
+		name := t.Name()
+		if _, ok := g.schemas[name]; !ok {
+			g.schemas[name] = nil // placeholder for recursive types
+			g.schemas[name] = g.object(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		return map[string]any{"type": "string", "format": "byte"}
+	case t.Kind() == reflect.Slice:
+		return map[string]any{"type": "array", "items": g.schema(t.Elem())}
+	case t.Kind() == reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case t.Kind() == reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case t.Kind() == reflect.Int64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return map[string]any{"type": "number"}
+	case t.Kind() == reflect.String:
+		return map[string]any{"type": "string"}
+	}
+	return map[string]any{}
+}
+
+// object returns the object schema of the struct. Fields are required when they are bound as
+// required, embedded structs are inlined like encoding/json does. Structs embedding the source
+// extend the source schema.
+func (g *generator) object(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	var required []string
+	embedsSource := g.fields(t, properties, &required)
+	object := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		sort.Strings(required)
+		object["required"] = required
+	}
+	if embedsSource {
+		return map[string]any{"allOf": []any{g.schema(sourceType), object}}
+	}
+	return object
+}
+
+// fields adds the properties of the struct fields and reports whether the struct embeds the source
+func (g *generator) fields(t reflect.Type, properties map[string]any, required *[]string) bool {
+	embedsSource := false
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if !field.IsExported() || tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			if field.Type == sourceType {
+				embedsSource = true
+			} else if g.fields(field.Type, properties, required) {
+				embedsSource = true
+			}
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if field.Type.Kind() == reflect.Interface {
+			// Source details are described by the source schema
+			properties[name] = map[string]any{"type": "object"}
+		} else {
+			properties[name] = g.schema(field.Type)
+		}
+		if slices.Contains(strings.Split(field.Tag.Get("binding"), ","), "required") {
+			*required = append(*required, name)
+		}
+	}
+	return embedsSource
+}
+
+// sourceSchemas replaces the source schema with a union of the in-tree source types,
+// discriminated by type. Source plugins accept details of their own.
+func (g *generator) sourceSchemas() {
+	var variants []any
+	mapping := map[string]any{}
+	for _, source := range sourceTypes {
+		g.schemas[source.schema] = map[string]any{
+			"type":     "object",
+			"required": []string{"type", "details"},
+			"properties": map[string]any{
+				"type":    map[string]any{"type": "string", "enum": []string{source.name}},
+				"details": g.ref(source.details),
+			},
+		}
+		ref := "#/components/schemas/" + source.schema
+		variants = append(variants, map[string]any{"$ref": ref})
+		mapping[source.name] = ref
+	}
+	g.schemas["Source"] = map[string]any{
+		"oneOf":         variants,
+		"discriminator": map[string]any{"propertyName": "type", "mapping": mapping},
+	}
+}
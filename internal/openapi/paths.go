@@ -0,0 +1,417 @@
+package openapi
+
+import (
+	"strings"
+
+	"github.com/sharedvolume/volume-syncer/internal/models"
+)
+
+// operation describes an endpoint
+type operation struct {
+	id        string // operationId, names the methods of generated clients
+	summary   string
+	tag       string
+	request   any               // JSON request body model, nil without a body
+	optional  bool              // the request body may be omitted
+	query     []parameter       // query parameters
+	responses map[string]string // descriptions by status code, the responses share the response model
+	response  any               // JSON response model
+	others    map[string]any    // response models of the codes answered with another model
+	admin     bool              // requires the admin token
+	stream    bool              // the 200 response is a text/event-stream of events carrying the response model
+}
+
+// parameter is a string query parameter
+type parameter struct {
+	name        string
+	description string
+	required    bool
+}
+
+// targetPath is the query parameter of the target endpoints
+var targetPath = parameter{name: "path", description: "Target path", required: true}
+
+// jobQuery are the query parameters of the job listings
+var jobQuery = []parameter{
+	{name: "label", description: "Only jobs with the label, key=value or a key for any value; repeat for several labels"},
+	{name: "status", description: "queued, running, succeeded or failed"},
+	{name: "sourceType", description: "Only jobs of the source type"},
+	{name: "limit", description: "Jobs returned at most, all when omitted"},
+	{name: "offset", description: "Matching jobs skipped, e.g. the nextOffset of the previous page"},
+}
+
+// paths returns the paths of the API
+func (g *generator) paths() map[string]any {
+	paths := map[string]map[string]operation{
+		"/health": {
+			"get": {id: "getHealth", summary: "Report the health of the service and the state of the job queue", tag: "service",
+				responses: map[string]string{"200": "Healthy"}, response: models.HealthResponse{}},
+		},
+		"/livez": {
+			"get": {id: "getLiveness", summary: "Report that the service answers requests, for liveness probes", tag: "service",
+				responses: map[string]string{"200": "Alive"}, response: models.HealthResponse{}},
+		},
+		"/readyz": {
+			"get": {id: "getReadiness", summary: "Check the binaries, directories, target volumes and job store the syncs depend on, for readiness probes", tag: "service",
+				responses: map[string]string{"200": "Ready", "503": "A check failed, see checks"}, response: models.ReadinessResponse{}},
+		},
+		"/api/1.0/sync": {
+			"get": {id: "listSyncJobs", summary: "List the sync jobs, most recent first, filtered by labels, status and source type", tag: "sync", query: jobQuery,
+				responses: map[string]string{"200": "Jobs", "400": "Invalid query parameter"}, response: models.SyncJobsResponse{}},
+			"post": {id: "startSync", summary: "Start a sync job", tag: "sync", request: models.SyncRequest{},
+				responses: map[string]string{
+					"200": "Attached to an identical queued or running job",
+					"201": "Sync started",
+					"202": "Sync queued, see queuePosition, estimatedStartAt and the Retry-After header",
+					"400": "Invalid request",
+					"422": "Invalid fields, see errors, or connection checks of a source failed, see checks",
+					"503": "A sync is in progress and SYNC_QUEUE_WHEN_BUSY is disabled, retry after the Retry-After header",
+				}, response: models.SyncResponse{}},
+		},
+		"/api/1.0/sync/batch": {
+			"post": {id: "startSyncBatch", summary: "Start or queue several sync jobs in the order of the requests", tag: "sync", request: models.BatchSyncRequest{},
+				responses: map[string]string{
+					"200": "Requests submitted, see the outcome of each in results",
+					"400": "Invalid request",
+					"422": "Too many requests",
+					"503": "A sync is in progress and SYNC_QUEUE_WHEN_BUSY is disabled, retry after the Retry-After header",
+				}, response: models.BatchSyncResponse{},
+				others: map[string]any{"503": models.SyncResponse{}}},
+		},
+		"/api/1.0/sync/jobs": {
+			"get": {id: "listJobs", summary: "List the sync jobs, most recent first, filtered by labels, status and source type", tag: "sync", query: jobQuery,
+				responses: map[string]string{"200": "Jobs", "400": "Invalid query parameter"}, response: models.SyncJobsResponse{}},
+		},
+		"/api/1.0/stats": {
+			"get": {id: "getStats", summary: "Aggregate the finished jobs in the history by source type and target", tag: "sync",
+				query: []parameter{
+					{name: "sourceType", description: "Only jobs of the source type"},
+					{name: "since", description: "Only jobs finished since the RFC 3339 time"},
+				},
+				responses: map[string]string{"200": "Statistics", "400": "Invalid query parameter"}, response: models.StatsResponse{}},
+		},
+		"/api/1.0/sync/jobs/{id}": {
+			"get": {id: "getJob", summary: "Get a sync job", tag: "sync",
+				responses: map[string]string{"200": "Job", "404": "Job not found"}, response: models.SyncJob{},
+				others: map[string]any{"404": models.SyncResponse{}}},
+		},
+		"/api/1.0/sync/jobs/{id}/progress": {
+			"get": {id: "streamJobProgress", summary: "Stream the progress of a sync job as server-sent events, ending with a job event once it finished", tag: "sync",
+				responses: map[string]string{"200": "progress events with the SyncProgress, then a job event with the SyncJob", "404": "Job not found"},
+				response:  models.SyncProgress{}, stream: true,
+				others: map[string]any{"404": models.SyncResponse{}}},
+		},
+		"/api/1.0/pause": {
+			"post": {id: "pauseQueue", summary: "Pause dispatching sync jobs", tag: "sync",
+				responses: map[string]string{"200": "Paused"}, response: models.QueueResponse{}},
+		},
+		"/api/1.0/resume": {
+			"post": {id: "resumeQueue", summary: "Resume dispatching sync jobs", tag: "sync",
+				responses: map[string]string{"200": "Resumed"}, response: models.QueueResponse{}},
+		},
+		"/api/1.0/profiles": {
+			"get": {id: "listProfiles", summary: "List the sync profiles, ordered by name", tag: "profiles", admin: true,
+				responses: map[string]string{
+					"200": "Profiles",
+					"401": "Missing or invalid admin token",
+					"403": "Administrative endpoints are disabled",
+				}, response: models.SyncProfilesResponse{},
+				others: map[string]any{"401": models.SyncResponse{}, "403": models.SyncResponse{}}},
+			"post": {id: "createProfile", summary: "Store a new sync profile", tag: "profiles", admin: true, request: models.SyncProfile{},
+				responses: map[string]string{
+					"201": "Profile created",
+					"400": "Invalid profile",
+					"401": "Missing or invalid admin token",
+					"403": "Administrative endpoints are disabled",
+					"422": "Invalid fields of the profile, see errors",
+					"409": "A profile of the name exists",
+				}, response: models.SyncProfileResponse{},
+				others: map[string]any{"401": models.SyncResponse{}, "403": models.SyncResponse{}}},
+		},
+		"/api/1.0/profiles/{name}": {
+			"get": {id: "getProfile", summary: "Get a sync profile", tag: "profiles", admin: true,
+				responses: map[string]string{
+					"200": "Profile",
+					"401": "Missing or invalid admin token",
+					"403": "Administrative endpoints are disabled",
+					"404": "Profile not found",
+				}, response: models.SyncProfileResponse{},
+				others: map[string]any{"401": models.SyncResponse{}, "403": models.SyncResponse{}}},
+			"put": {id: "putProfile", summary: "Create or replace a sync profile", tag: "profiles", admin: true, request: models.SyncProfile{},
+				responses: map[string]string{
+					"200": "Profile replaced",
+					"201": "Profile created",
+					"400": "Invalid profile",
+					"401": "Missing or invalid admin token",
+					"403": "Administrative endpoints are disabled",
+					"422": "Invalid fields of the profile, see errors",
+				}, response: models.SyncProfileResponse{},
+				others: map[string]any{"401": models.SyncResponse{}, "403": models.SyncResponse{}}},
+			"delete": {id: "deleteProfile", summary: "Delete a sync profile", tag: "profiles", admin: true,
+				responses: map[string]string{
+					"200": "Profile deleted",
+					"401": "Missing or invalid admin token",
+					"403": "Administrative endpoints are disabled",
+					"404": "Profile not found",
+				}, response: models.SyncProfileResponse{},
+				others: map[string]any{"401": models.SyncResponse{}, "403": models.SyncResponse{}}},
+		},
+		"/api/1.0/profiles/{name}/run": {
+			"post": {id: "runProfile", summary: "Start a sync job of a profile, with the fields of the optional body overriding the profile", tag: "profiles", request: models.SyncRequest{}, optional: true,
+				responses: map[string]string{
+					"200": "Attached to an identical queued or running job",
+					"201": "Sync started",
+					"202": "Sync queued, see queuePosition, estimatedStartAt and the Retry-After header",
+					"400": "Invalid request",
+					"404": "Profile not found",
+					"422": "Invalid fields, see errors, or connection checks of a source failed, see checks",
+					"503": "A sync is in progress and SYNC_QUEUE_WHEN_BUSY is disabled, retry after the Retry-After header",
+				}, response: models.SyncResponse{},
+				others: map[string]any{"404": models.SyncProfileResponse{}}},
+		},
+		"/api/1.0/credentials": {
+			"get": {id: "listCredentials", summary: "List the stored credentials, ordered by ID, without their data", tag: "credentials", admin: true,
+				responses: map[string]string{
+					"200": "Credentials",
+					"401": "Missing or invalid admin token",
+					"403": "Administrative endpoints are disabled",
+				}, response: models.CredentialsResponse{},
+				others: map[string]any{"401": models.SyncResponse{}, "403": models.SyncResponse{}}},
+			"post": {id: "createCredential", summary: "Encrypt and store a new credential", tag: "credentials", admin: true, request: models.Credential{},
+				responses: map[string]string{
+					"201": "Credential created",
+					"400": "Invalid credential, or no sealing key is configured",
+					"401": "Missing or invalid admin token",
+					"403": "Administrative endpoints are disabled",
+					"409": "A credential of the ID exists",
+				}, response: models.CredentialResponse{},
+				others: map[string]any{"401": models.SyncResponse{}, "403": models.SyncResponse{}}},
+		},
+		"/api/1.0/credentials/{id}": {
+			"get": {id: "getCredential", summary: "Get a stored credential without its data", tag: "credentials", admin: true,
+				responses: map[string]string{
+					"200": "Credential",
+					"401": "Missing or invalid admin token",
+					"403": "Administrative endpoints are disabled",
+					"404": "Credential not found",
+				}, response: models.CredentialResponse{},
+				others: map[string]any{"401": models.SyncResponse{}, "403": models.SyncResponse{}}},
+			"put": {id: "putCredential", summary: "Create or replace (rotate) a stored credential", tag: "credentials", admin: true, request: models.Credential{},
+				responses: map[string]string{
+					"200": "Credential replaced",
+					"201": "Credential created",
+					"400": "Invalid credential, or no sealing key is configured",
+					"401": "Missing or invalid admin token",
+					"403": "Administrative endpoints are disabled",
+				}, response: models.CredentialResponse{},
+				others: map[string]any{"401": models.SyncResponse{}, "403": models.SyncResponse{}}},
+			"delete": {id: "deleteCredential", summary: "Delete a stored credential", tag: "credentials", admin: true,
+				responses: map[string]string{
+					"200": "Credential deleted",
+					"401": "Missing or invalid admin token",
+					"403": "Administrative endpoints are disabled",
+					"404": "Credential not found",
+				}, response: models.CredentialResponse{},
+				others: map[string]any{"401": models.SyncResponse{}, "403": models.SyncResponse{}}},
+		},
+		"/api/1.0/audit": {
+			"get": {id: "listAudit", summary: "List the recent audit entries of API calls and sync executions, oldest first", tag: "audit", admin: true,
+				query: []parameter{
+					{name: "event", description: "api or sync, both when omitted"},
+					{name: "jobId", description: "Entries of the job"},
+					{name: "since", description: "RFC 3339 time of the oldest entry"},
+					{name: "limit", description: "Most recent entries returned (default: 100)"},
+				},
+				responses: map[string]string{
+					"200": "Audit entries",
+					"400": "Invalid query parameter",
+					"401": "Missing or invalid admin token",
+					"403": "Administrative endpoints are disabled",
+				}, response: models.AuditResponse{},
+				others: map[string]any{"401": models.SyncResponse{}, "403": models.SyncResponse{}}},
+		},
+		"/api/1.0/validate": {
+			"post": {id: "validateSource", summary: "Check the connection to a source without syncing it", tag: "sources", request: models.ValidateRequest{},
+				responses: map[string]string{"200": "Checks ran, see status", "400": "Invalid request"}, response: models.ValidateResponse{}},
+		},
+		"/api/1.0/browse": {
+			"post": {id: "browseSource", summary: "List the branches, tags, directories or keys of a source", tag: "sources", request: models.BrowseRequest{},
+				responses: map[string]string{"200": "Listing", "400": "Invalid request or listing failed"}, response: models.BrowseResponse{}},
+		},
+		"/api/1.0/probe": {
+			"get": {id: "probeURL", summary: "Time the DNS, TCP and TLS or SSH stages of a connection to the server of a URL", tag: "sources",
+				query: []parameter{
+					{name: "url", description: "http(s)://, ssh:// or tcp:// URL, or scp-like Git URL", required: true},
+					{name: "proxy", description: "Proxy URL of the connection, an empty value for a direct connection"},
+				},
+				responses: map[string]string{"200": "Stages ran, see status", "400": "Missing or invalid URL"}, response: models.ProbeResponse{}},
+			"post": {id: "probeSource", summary: "Time the DNS, TCP, TLS or SSH and authentication stages of a connection to a source", tag: "sources", request: models.ProbeRequest{},
+				responses: map[string]string{"200": "Stages ran, see status", "400": "Invalid request"}, response: models.ProbeResponse{}},
+		},
+		"/api/1.0/hooks/git": {
+			"post": {id: "receiveGitWebhook", summary: "Receive a GitHub, GitLab or Bitbucket push webhook", tag: "sources",
+				responses: map[string]string{
+					"200": "Syncs triggered, or ping answered",
+					"202": "Event ignored",
+					"400": "Invalid delivery",
+					"401": "Invalid signature or token, or no definition matches the repository and branch",
+					"413": "Payload too large",
+				}, response: models.WebhookResponse{}},
+		},
+		"/api/2.0/sync": {
+			"post": {id: "startSyncV2", summary: "Start a sync job, decoding the source details strictly", tag: "sync", request: models.SyncRequest{},
+				responses: map[string]string{
+					"200": "Attached to an identical queued or running job",
+					"201": "Sync started",
+					"202": "Sync queued, see queuePosition, estimatedStartAt and the Retry-After header",
+					"400": "Malformed request, with the invalid fields in errors",
+					"422": "Invalid fields, see errors, or connection checks of a source failed, see checks",
+					"503": "A sync is in progress and SYNC_QUEUE_WHEN_BUSY is disabled, retry after the Retry-After header",
+				}, response: models.SyncResponse{}},
+		},
+		"/api/2.0/sync/batch": {
+			"post": {id: "startSyncBatchV2", summary: "Start or queue several sync jobs, decoding the source details strictly", tag: "sync", request: models.BatchSyncRequest{},
+				responses: map[string]string{
+					"200": "Requests submitted, see the outcome of each in results",
+					"400": "Malformed request, with the invalid fields in errors",
+					"422": "Invalid fields, see errors",
+					"503": "A sync is in progress and SYNC_QUEUE_WHEN_BUSY is disabled, retry after the Retry-After header",
+				}, response: models.BatchSyncResponse{},
+				others: map[string]any{"400": models.SyncResponse{}, "422": models.SyncResponse{}, "503": models.SyncResponse{}}},
+		},
+		"/api/2.0/validate": {
+			"post": {id: "validateSourceV2", summary: "Check the connection to a source, decoding its details strictly", tag: "sources", request: models.ValidateRequest{},
+				responses: map[string]string{"200": "Checks ran, see status", "400": "Malformed request, with the invalid fields in errors", "422": "Invalid fields, see errors"}, response: models.ValidateResponse{},
+				others: map[string]any{"400": models.SyncResponse{}, "422": models.SyncResponse{}}},
+		},
+		"/api/2.0/browse": {
+			"post": {id: "browseSourceV2", summary: "List the contents of a source, decoding its details strictly", tag: "sources", request: models.BrowseRequest{},
+				responses: map[string]string{"200": "Listing", "400": "Malformed request, with the invalid fields in errors, or listing failed", "422": "Invalid fields, see errors"}, response: models.BrowseResponse{},
+				others: map[string]any{"422": models.SyncResponse{}}},
+		},
+		"/api/2.0/probe": {
+			"post": {id: "probeSourceV2", summary: "Time the stages of a connection to a source, decoding its details strictly", tag: "sources", request: models.ProbeRequest{},
+				responses: map[string]string{"200": "Stages ran, see status", "400": "Malformed request, with the invalid fields in errors", "422": "Invalid fields, see errors"}, response: models.ProbeResponse{},
+				others: map[string]any{"400": models.SyncResponse{}, "422": models.SyncResponse{}}},
+		},
+		"/api/1.0/status": {
+			"get": {id: "getTargetStatus", summary: "Report the last sync of a target: its time, revision, result, duration and data age", tag: "targets", query: []parameter{targetPath},
+				responses: map[string]string{"200": "Status", "400": "Missing path", "404": "No sync of the target is recorded"}, response: models.TargetStatus{}},
+		},
+		"/api/1.0/target": {
+			"get": {id: "inspectTarget", summary: "Summarize the contents and last successful sync of a target", tag: "targets", query: []parameter{targetPath},
+				responses: map[string]string{"200": "Inspection", "400": "Missing path", "404": "Target not found"}, response: models.TargetInspection{}},
+			"delete": {id: "purgeTarget", summary: "Empty a target below the purge roots", tag: "targets", admin: true,
+				query: []parameter{targetPath, {name: "confirm", description: "Confirmation token returned by a request without it"}},
+				responses: map[string]string{
+					"200": "Confirmation token issued, or target purged",
+					"400": "Invalid path or token",
+					"401": "Missing or invalid admin token",
+					"403": "Administrative endpoints are disabled",
+					"500": "Purge failed",
+					"503": "A sync is in progress",
+				}, response: models.TargetPurgeResponse{},
+				others: map[string]any{"401": models.SyncResponse{}, "403": models.SyncResponse{}}},
+		},
+		"/api/1.0/target/rollback": {
+			"post": {id: "rollbackTarget", summary: "Activate a previous version of a versioned target", tag: "targets", request: models.TargetRollbackRequest{},
+				responses: map[string]string{"200": "Rolled back", "400": "Invalid request", "503": "A sync is in progress"}, response: models.TargetRollbackResponse{}},
+		},
+		"/api/1.0/target/usage": {
+			"get": {id: "getTargetUsage", summary: "Report the usage of the target quotas", tag: "targets",
+				query:     []parameter{{name: "path", description: "Quota path, all quotas when omitted"}},
+				responses: map[string]string{"200": "Usage", "404": "No quota is configured for the path"}, response: models.TargetUsageResponse{},
+				others: map[string]any{"404": models.SyncResponse{}}},
+		},
+		"/api/1.0/target/drift": {
+			"get": {id: "getTargetDrift", summary: "Report the changes made to a target since its last successful sync", tag: "targets", query: []parameter{targetPath},
+				responses: map[string]string{"200": "Drift", "400": "Missing path", "404": "No successful sync is recorded", "503": "A sync is in progress"}, response: models.TargetDriftResponse{}},
+		},
+	}
+
+	document := map[string]any{
+		"/metrics": map[string]any{
+			"get": map[string]any{
+				"operationId": "getMetrics",
+				"summary":     "Prometheus metrics",
+				"tags":        []string{"service"},
+				"responses": map[string]any{
+					"200": map[string]any{"description": "Metrics", "content": map[string]any{"text/plain": map[string]any{"schema": map[string]any{"type": "string"}}}},
+				},
+			},
+		},
+		"/openapi.json": map[string]any{
+			"get": map[string]any{
+				"operationId": "getOpenAPI",
+				"summary":     "This document",
+				"tags":        []string{"service"},
+				"responses": map[string]any{
+					"200": map[string]any{"description": "OpenAPI document", "content": map[string]any{"application/json": map[string]any{"schema": map[string]any{"type": "object"}}}},
+				},
+			},
+		},
+	}
+	for path, methods := range paths {
+		item := map[string]any{}
+		for method, op := range methods {
+			item[method] = g.operation(path, op)
+		}
+		document[path] = item
+	}
+	return document
+}
+
+// operation returns the OpenAPI operation object of the endpoint
+func (g *generator) operation(path string, op operation) map[string]any {
+	responses := map[string]any{}
+	for code, description := range op.responses {
+		model := op.response
+		if other, ok := op.others[code]; ok {
+			model = other
+		}
+		mediaType := "application/json"
+		if op.stream && code == "200" {
+			mediaType = "text/event-stream"
+		}
+		responses[code] = map[string]any{
+			"description": description,
+			"content":     map[string]any{mediaType: map[string]any{"schema": g.ref(model)}},
+		}
+	}
+	operation := map[string]any{"operationId": op.id, "summary": op.summary, "tags": []string{op.tag}, "responses": responses}
+
+	var parameters []any
+	for _, segment := range strings.Split(path, "/") {
+		if name, ok := strings.CutPrefix(segment, "{"); ok {
+			parameters = append(parameters, map[string]any{
+				"name": strings.TrimSuffix(name, "}"), "in": "path", "required": true,
+				"schema": map[string]any{"type": "string"},
+			})
+		}
+	}
+	for _, param := range op.query {
+		parameters = append(parameters, map[string]any{
+			"name": param.name, "in": "query", "required": param.required, "description": param.description,
+			"schema": map[string]any{"type": "string"},
+		})
+	}
+	if len(parameters) > 0 {
+		operation["parameters"] = parameters
+	}
+	if op.request != nil {
+		if _, ok := responses["413"]; !ok {
+			responses["413"] = map[string]any{
+				"description": "Request body exceeds SYNC_MAX_REQUEST_BODY",
+				"content":     map[string]any{"application/json": map[string]any{"schema": g.ref(models.SyncResponse{})}},
+			}
+		}
+		operation["requestBody"] = map[string]any{
+			"required": !op.optional,
+			"content":  map[string]any{"application/json": map[string]any{"schema": g.ref(op.request)}},
+		}
+	}
+	if op.admin {
+		operation["security"] = []any{map[string]any{"adminToken": []string{}}}
+	}
+	return operation
+}
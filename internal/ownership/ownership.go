@@ -0,0 +1,294 @@
+package ownership
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/sharedvolume/volume-syncer/internal/models"
+)
+
+// Unix permission bits used by chmod rules
+const (
+	modeSetuid = 04000
+	modeSetgid = 02000
+	modeSticky = 01000
+)
+
+// clause is one symbolic chmod operation, e.g. "g+w"
+type clause struct {
+	who   uint32 // permission bits of the user classes the clause applies to
+	op    byte   // '+', '-' or '='
+	perms string // permission letters
+}
+
+// rule is one comma-separated item of a chmod spec, optionally restricted to directories or files
+type rule struct {
+	dirs, files bool
+	octal       *uint32
+	clauses     []clause
+}
+
+// Mapping applies an owner and permission changes to a directory tree
+type Mapping struct {
+	options *models.Ownership
+	rules   []rule
+}
+
+// New validates the ownership options; nil is returned when nothing is configured
+func New(options *models.Ownership) (*Mapping, error) {
+	if options == nil || (options.UID == nil && options.GID == nil && options.Chmod == "") {
+		return nil, nil
+	}
+	if options.UID != nil && *options.UID < 0 {
+		return nil, fmt.Errorf("uid must not be negative")
+	}
+	if options.GID != nil && *options.GID < 0 {
+		return nil, fmt.Errorf("gid must not be negative")
+	}
+
+	rules, err := parseChmod(options.Chmod)
+	if err != nil {
+		return nil, fmt.Errorf("invalid chmod %q: %w", options.Chmod, err)
+	}
+	return &Mapping{options: options, rules: rules}, nil
+}
+
+// RsyncArgs maps the ownership options to rsync flags
+func (m *Mapping) RsyncArgs() []string {
+	if m == nil {
+		return nil
+	}
+
+	var args []string
+	if m.options.UID != nil || m.options.GID != nil {
+		var owner string
+		if m.options.UID != nil {
+			owner = strconv.Itoa(*m.options.UID)
+		}
+		if m.options.GID != nil {
+			owner += ":" + strconv.Itoa(*m.options.GID)
+		}
+		args = append(args, "--chown="+owner)
+	}
+	if m.options.Chmod != "" {
+		args = append(args, "--chmod="+m.options.Chmod)
+	}
+	return args
+}
+
+// Apply changes the owner and permissions of the directory and everything below it.
+// Symbolic links are re-owned but never followed.
+func (m *Mapping) Apply(root string) error {
+	if m == nil {
+		return nil
+	}
+	log.Printf("[OWNERSHIP] Applying ownership to %s", root)
+
+	uid, gid := -1, -1
+	if m.options.UID != nil {
+		uid = *m.options.UID
+	}
+	if m.options.GID != nil {
+		gid = *m.options.GID
+	}
+
+	count := 0
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if uid >= 0 || gid >= 0 {
+			if err := os.Lchown(path, uid, gid); err != nil {
+				return fmt.Errorf("failed to change owner of %s: %w", path, err)
+			}
+		}
+		if len(m.rules) > 0 && d.Type()&fs.ModeSymlink == 0 {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			mode := toUnixMode(info.Mode())
+			newMode := m.chmod(mode, d.IsDir())
+			if newMode != mode {
+				if err := os.Chmod(path, fromUnixMode(newMode)); err != nil {
+					return fmt.Errorf("failed to change mode of %s: %w", path, err)
+				}
+			}
+		}
+		count++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("[OWNERSHIP] Ownership applied to %d entries", count)
+	return nil
+}
+
+// chmod applies the rules to the unix mode of a file or directory
+func (m *Mapping) chmod(mode uint32, isDir bool) uint32 {
+	for _, r := range m.rules {
+		if (r.dirs && !isDir) || (r.files && isDir) {
+			continue
+		}
+		if r.octal != nil {
+			mode = *r.octal
+			continue
+		}
+		for _, c := range r.clauses {
+			mode = c.apply(mode, isDir)
+		}
+	}
+	return mode
+}
+
+// apply applies the clause to the unix mode
+func (c clause) apply(mode uint32, isDir bool) uint32 {
+	var bits uint32
+	for _, p := range c.perms {
+		switch p {
+		case 'r':
+			bits |= 0444 & c.who
+		case 'w':
+			bits |= 0222 & c.who
+		case 'x':
+			bits |= 0111 & c.who
+		case 'X':
+			if isDir || mode&0111 != 0 {
+				bits |= 0111 & c.who
+			}
+		case 's':
+			bits |= (modeSetuid | modeSetgid) & c.who
+		case 't':
+			bits |= modeSticky & c.who
+		}
+	}
+
+	switch c.op {
+	case '+':
+		return mode | bits
+	case '-':
+		return mode &^ bits
+	default:
+		return mode&^c.who | bits
+	}
+}
+
+// parseChmod parses a chmod spec in rsync --chmod syntax: comma-separated octal modes or
+// symbolic clauses, each optionally prefixed with D (directories only) or F (files only)
+func parseChmod(spec string) ([]rule, error) {
+	if spec == "" {
+		return nil, nil
+	}
+
+	var rules []rule
+	for _, item := range strings.Split(spec, ",") {
+		var r rule
+		switch {
+		case strings.HasPrefix(item, "D"):
+			r.dirs = true
+			item = item[1:]
+		case strings.HasPrefix(item, "F"):
+			r.files = true
+			item = item[1:]
+		}
+		if item == "" {
+			return nil, fmt.Errorf("empty mode")
+		}
+
+		if item[0] >= '0' && item[0] <= '7' {
+			octal, err := strconv.ParseUint(item, 8, 32)
+			if err != nil || octal > 07777 {
+				return nil, fmt.Errorf("invalid octal mode %q", item)
+			}
+			mode := uint32(octal)
+			r.octal = &mode
+			rules = append(rules, r)
+			continue
+		}
+
+		clauses, err := parseClauses(item)
+		if err != nil {
+			return nil, err
+		}
+		r.clauses = clauses
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// parseClauses parses a symbolic mode such as "ug+rw" or "u=rwx,g=rx" (one comma-separated item)
+func parseClauses(item string) ([]clause, error) {
+	i := 0
+	var who uint32
+	for ; i < len(item) && strings.IndexByte("ugoa", item[i]) >= 0; i++ {
+		switch item[i] {
+		case 'u':
+			who |= 0700 | modeSetuid
+		case 'g':
+			who |= 0070 | modeSetgid
+		case 'o':
+			who |= 0007 | modeSticky
+		case 'a':
+			who |= 0777 | modeSetuid | modeSetgid | modeSticky
+		}
+	}
+	if who == 0 {
+		who = 0777 | modeSetuid | modeSetgid | modeSticky
+	}
+	if i == len(item) {
+		return nil, fmt.Errorf("missing operator in %q", item)
+	}
+
+	var clauses []clause
+	for i < len(item) {
+		op := item[i]
+		if op != '+' && op != '-' && op != '=' {
+			return nil, fmt.Errorf("invalid operator %q in %q", op, item)
+		}
+		i++
+		start := i
+		for ; i < len(item) && strings.IndexByte("rwxXst", item[i]) >= 0; i++ {
+		}
+		if i < len(item) && item[i] != '+' && item[i] != '-' && item[i] != '=' {
+			return nil, fmt.Errorf("invalid permission %q in %q", item[i], item)
+		}
+		clauses = append(clauses, clause{who: who, op: op, perms: item[start:i]})
+	}
+	return clauses, nil
+}
+
+// toUnixMode converts a Go file mode to unix permission bits
+func toUnixMode(mode fs.FileMode) uint32 {
+	bits := uint32(mode.Perm())
+	if mode&fs.ModeSetuid != 0 {
+		bits |= modeSetuid
+	}
+	if mode&fs.ModeSetgid != 0 {
+		bits |= modeSetgid
+	}
+	if mode&fs.ModeSticky != 0 {
+		bits |= modeSticky
+	}
+	return bits
+}
+
+// fromUnixMode converts unix permission bits to a Go file mode
+func fromUnixMode(bits uint32) fs.FileMode {
+	mode := fs.FileMode(bits & 0777)
+	if bits&modeSetuid != 0 {
+		mode |= fs.ModeSetuid
+	}
+	if bits&modeSetgid != 0 {
+		mode |= fs.ModeSetgid
+	}
+	if bits&modeSticky != 0 {
+		mode |= fs.ModeSticky
+	}
+	return mode
+}
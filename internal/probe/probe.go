@@ -0,0 +1,202 @@
+package probe
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/sharedvolume/volume-syncer/internal/checks"
+	"github.com/sharedvolume/volume-syncer/internal/models"
+	"github.com/sharedvolume/volume-syncer/internal/netdial"
+	"github.com/sharedvolume/volume-syncer/internal/proxy"
+	"github.com/sharedvolume/volume-syncer/internal/sshutil"
+)
+
+// Protocols spoken by endpoints after the TCP connection
+const (
+	ProtocolTCP  = "tcp"  // the TCP connection only
+	ProtocolHTTP = "http" // plain HTTP, which is not checked beyond the TCP connection
+	ProtocolTLS  = "tls"  // a TLS handshake, e.g. HTTPS
+	ProtocolSSH  = "ssh"  // an SSH handshake up to authentication
+)
+
+// Endpoint is the server a source connects to
+type Endpoint struct {
+	Host     string
+	Port     int
+	Protocol string
+	TLS      *tls.Config // verification settings of the TLS handshake, nil for the defaults
+}
+
+// Address returns the host:port of the endpoint
+func (e Endpoint) Address() string {
+	return net.JoinHostPort(e.Host, strconv.Itoa(e.Port))
+}
+
+// ParseURL returns the endpoint of an http(s)://, ssh:// or tcp:// URL or of a scp-like Git URL
+// such as git@github.com:org/app.git
+func ParseURL(raw string) (Endpoint, error) {
+	if host, port, ok := sshutil.ParseGitSSHEndpoint(raw); ok {
+		return Endpoint{Host: host, Port: port, Protocol: ProtocolSSH}, nil
+	}
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Hostname() == "" {
+		return Endpoint{}, fmt.Errorf("%q is not an http, https, ssh or tcp URL", raw)
+	}
+
+	endpoint := Endpoint{Host: parsed.Hostname()}
+	var defaultPort int
+	switch strings.ToLower(parsed.Scheme) {
+	case "http":
+		endpoint.Protocol, defaultPort = ProtocolHTTP, 80
+	case "https":
+		endpoint.Protocol, defaultPort = ProtocolTLS, 443
+	case "tcp":
+		endpoint.Protocol = ProtocolTCP
+	default:
+		return Endpoint{}, fmt.Errorf("URL scheme %q is not supported, expected http, https, ssh or tcp", parsed.Scheme)
+	}
+	endpoint.Port = defaultPort
+	if port := parsed.Port(); port != "" {
+		if endpoint.Port, err = strconv.Atoi(port); err != nil {
+			return Endpoint{}, fmt.Errorf("invalid port %q", port)
+		}
+	} else if defaultPort == 0 {
+		return Endpoint{}, errors.New("tcp URLs need a port, e.g. tcp://db.internal:5432")
+	}
+	return endpoint, nil
+}
+
+// Run adds the network stages of a connection to the endpoint to the report: resolving its
+// host (dns), connecting to it (tcp) and the handshake of its protocol (tls or ssh). Connections
+// go through the proxy unless the endpoint is on its no-proxy list; a nil proxy uses the
+// HTTP_PROXY and HTTPS_PROXY variables for HTTP and HTTPS endpoints, as HTTP clients do.
+func Run(ctx context.Context, report *checks.Report, endpoint Endpoint, p *proxy.Proxy, timeout time.Duration) {
+	if p == nil {
+		p = environmentProxy(endpoint)
+	}
+	address := endpoint.Address()
+	route := p.Route(address)
+
+	report.Run("dns", func() (string, error) {
+		if net.ParseIP(endpoint.Host) != nil {
+			return endpoint.Host + " is an IP address", nil
+		}
+		if route != nil {
+			return fmt.Sprintf("%s is resolved by the proxy %s", endpoint.Host, route.Redacted()), nil
+		}
+		lookupCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		addresses, err := netdial.Default().LookupHost(lookupCtx, endpoint.Host)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s resolves to %s", endpoint.Host, strings.Join(addresses, ", ")), nil
+	})
+
+	var conn net.Conn
+	report.Run("tcp", func() (string, error) {
+		dialCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		var err error
+		if conn, err = p.DialContext(dialCtx, "tcp", address); err != nil {
+			return "", err
+		}
+		if route != nil {
+			return fmt.Sprintf("connected to %s through the proxy %s", address, route.Redacted()), nil
+		}
+		return fmt.Sprintf("connected to %s (%s)", address, conn.RemoteAddr()), nil
+	})
+	if conn == nil {
+		return
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	switch endpoint.Protocol {
+	case ProtocolTLS:
+		report.Run("tls", func() (string, error) {
+			return handshakeTLS(ctx, conn, endpoint)
+		})
+	case ProtocolSSH:
+		report.Run("ssh", func() (string, error) {
+			return handshakeSSH(conn, address, timeout)
+		})
+	}
+}
+
+// handshakeTLS performs the TLS handshake and describes the session and the server certificate
+func handshakeTLS(ctx context.Context, conn net.Conn, endpoint Endpoint) (string, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if endpoint.TLS != nil {
+		config = endpoint.TLS.Clone()
+	}
+	if config.ServerName == "" {
+		config.ServerName = endpoint.Host
+	}
+	tlsConn := tls.Client(conn, config)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return "", err
+	}
+
+	state := tlsConn.ConnectionState()
+	message := fmt.Sprintf("%s, %s", tls.VersionName(state.Version), tls.CipherSuiteName(state.CipherSuite))
+	if len(state.PeerCertificates) > 0 {
+		certificate := state.PeerCertificates[0]
+		message += fmt.Sprintf(", certificate %s issued by %s, valid until %s", certificate.Subject.CommonName, certificate.Issuer.CommonName, certificate.NotAfter.UTC().Format(time.RFC3339))
+	}
+	if config.InsecureSkipVerify {
+		message += ", certificate not verified"
+	}
+	return message, nil
+}
+
+// handshakeSSH performs the SSH handshake up to authentication, which is expected to fail without
+// credentials, and describes the server and its host key
+func handshakeSSH(conn net.Conn, address string, timeout time.Duration) (string, error) {
+	var hostKey ssh.PublicKey
+	config := &ssh.ClientConfig{
+		User: "volume-syncer",
+		HostKeyCallback: func(_ string, _ net.Addr, key ssh.PublicKey) error {
+			hostKey = key
+			return nil
+		},
+		Timeout: timeout,
+	}
+	clientConn, channels, requests, err := ssh.NewClientConn(conn, address, config)
+	if err == nil {
+		// The server accepted the connection without authentication
+		ssh.NewClient(clientConn, channels, requests).Close()
+	}
+	if hostKey == nil {
+		if err == nil {
+			err = errors.New("no host key received")
+		}
+		return "", err
+	}
+	return fmt.Sprintf("host key %s %s", hostKey.Type(), ssh.FingerprintSHA256(hostKey)), nil
+}
+
+// environmentProxy returns the proxy of the HTTP_PROXY, HTTPS_PROXY and NO_PROXY variables for
+// HTTP and HTTPS endpoints
+func environmentProxy(endpoint Endpoint) *proxy.Proxy {
+	scheme := map[string]string{ProtocolHTTP: "http", ProtocolTLS: "https"}[endpoint.Protocol]
+	if scheme == "" {
+		return nil
+	}
+	route, err := http.ProxyFromEnvironment(&http.Request{URL: &url.URL{Scheme: scheme, Host: endpoint.Address()}})
+	if err != nil || route == nil {
+		return nil
+	}
+	p, _ := proxy.New(&models.ProxyOptions{URL: route.String()})
+	return p
+}
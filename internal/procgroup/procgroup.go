@@ -0,0 +1,6 @@
+package procgroup
+
+import "time"
+
+// waitDelay bounds the wait for the output pipes of a killed command
+const waitDelay = 5 * time.Second
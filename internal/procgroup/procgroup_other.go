@@ -0,0 +1,11 @@
+//go:build !unix
+
+package procgroup
+
+import "os/exec"
+
+// Configure bounds the wait for the output of the command once its context is done. Process
+// groups are not available on this platform, so only the command itself is killed.
+func Configure(cmd *exec.Cmd) {
+	cmd.WaitDelay = waitDelay
+}
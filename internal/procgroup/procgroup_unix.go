@@ -0,0 +1,29 @@
+//go:build unix
+
+package procgroup
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// Configure starts the command in a process group of its own and kills the whole group when the
+// context of the command is done, so that children such as ssh and sshpass do not outlive it and
+// hold on to locks. Wait returns at most waitDelay later even if a child kept the output open.
+func Configure(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+	cmd.Cancel = func() error {
+		// The negative pid signals the group, whose id is that of the command
+		err := syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		if errors.Is(err, syscall.ESRCH) {
+			return os.ErrProcessDone
+		}
+		return err
+	}
+	cmd.WaitDelay = waitDelay
+}
@@ -0,0 +1,171 @@
+package profiles
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sharedvolume/volume-syncer/internal/models"
+)
+
+// profileSuffix is the suffix of the profile files
+const profileSuffix = ".json"
+
+// nameRegex matches the names of profiles, which name the profile files
+var nameRegex = regexp.MustCompile(`^[a-z0-9]([a-z0-9._-]{0,61}[a-z0-9])?$`)
+
+var (
+	// ErrNotFound is returned for operations on a profile that does not exist
+	ErrNotFound = errors.New("sync profile not found")
+	// ErrExists is returned when creating a profile whose name is taken
+	ErrExists = errors.New("sync profile already exists")
+)
+
+// Store holds the sync profiles, persisted in a directory with one file per profile when the
+// directory is set
+type Store struct {
+	dir      string
+	mutex    sync.RWMutex
+	profiles map[string]models.SyncProfile
+}
+
+// New opens the profiles directory and loads its profiles. Without a directory, profiles are
+// kept in memory until the server stops.
+func New(dir string) (*Store, error) {
+	s := &Store{dir: dir, profiles: map[string]models.SyncProfile{}}
+	if dir == "" {
+		return s, nil
+	}
+	// Profiles hold source credentials
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create profiles directory: %w", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read profiles directory: %w", err)
+	}
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), profileSuffix)
+		if !ok || !entry.Type().IsRegular() || !nameRegex.MatchString(name) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			log.Printf("[PROFILES] WARNING: Failed to read profile %s: %v", name, err)
+			continue
+		}
+		var profile models.SyncProfile
+		if err := json.Unmarshal(data, &profile); err != nil || profile.Name != name {
+			log.Printf("[PROFILES] WARNING: Skipping invalid profile %s", name)
+			continue
+		}
+		s.profiles[name] = profile
+	}
+	log.Printf("[PROFILES] Loaded %d sync profiles from %s", len(s.profiles), dir)
+	return s, nil
+}
+
+// ValidateName checks that the name can identify a profile
+func ValidateName(name string) error {
+	if !nameRegex.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q: use up to 63 lowercase letters, digits, '.', '_' and '-', starting and ending with a letter or digit", name)
+	}
+	return nil
+}
+
+// Get returns the profile with the given name
+func (s *Store) Get(name string) (models.SyncProfile, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	profile, ok := s.profiles[name]
+	return profile, ok
+}
+
+// List returns all profiles ordered by name
+func (s *Store) List() []models.SyncProfile {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	profiles := make([]models.SyncProfile, 0, len(s.profiles))
+	for _, profile := range s.profiles {
+		profiles = append(profiles, profile)
+	}
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].Name < profiles[j].Name })
+	return profiles
+}
+
+// Create stores a new profile, failing with ErrExists when the name is taken
+func (s *Store) Create(profile models.SyncProfile) (models.SyncProfile, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.profiles[profile.Name]; ok {
+		return models.SyncProfile{}, ErrExists
+	}
+	return s.save(profile)
+}
+
+// Put creates or replaces the profile and reports whether it was created
+func (s *Store) Put(profile models.SyncProfile) (models.SyncProfile, bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	_, exists := s.profiles[profile.Name]
+	saved, err := s.save(profile)
+	return saved, !exists, err
+}
+
+// Delete removes the profile, failing with ErrNotFound when it does not exist
+func (s *Store) Delete(name string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.profiles[name]; !ok {
+		return ErrNotFound
+	}
+	if s.dir != "" {
+		if err := os.Remove(s.path(name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove profile %s: %w", name, err)
+		}
+	}
+	delete(s.profiles, name)
+	return nil
+}
+
+func (s *Store) path(name string) string {
+	return filepath.Join(s.dir, name+profileSuffix)
+}
+
+// save writes the profile and keeps it in memory. The caller holds the mutex.
+func (s *Store) save(profile models.SyncProfile) (models.SyncProfile, error) {
+	if err := ValidateName(profile.Name); err != nil {
+		return models.SyncProfile{}, err
+	}
+	profile.UpdatedAt = time.Now().UTC()
+	if s.dir != "" {
+		data, err := json.Marshal(profile)
+		if err != nil {
+			return models.SyncProfile{}, fmt.Errorf("failed to encode profile %s: %w", profile.Name, err)
+		}
+		path := s.path(profile.Name)
+		tmp := path + ".tmp"
+		if err := os.WriteFile(tmp, data, 0600); err != nil {
+			return models.SyncProfile{}, fmt.Errorf("failed to write profile %s: %w", profile.Name, err)
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			os.Remove(tmp)
+			return models.SyncProfile{}, fmt.Errorf("failed to write profile %s: %w", profile.Name, err)
+		}
+	}
+	s.profiles[profile.Name] = profile
+	return profile, nil
+}
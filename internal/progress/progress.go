@@ -0,0 +1,210 @@
+package progress
+
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sharedvolume/volume-syncer/internal/models"
+)
+
+// Phases of a job, reported by the sync service and the syncers
+const (
+	PhasePreHooks     = "preHooks"
+	PhaseConnecting   = "connecting"
+	PhaseListing      = "listing"
+	PhaseTransferring = "transferring"
+	PhaseVerifying    = "verifying"
+	PhasePostHooks    = "postHooks"
+)
+
+// Reporter receives the progress of a sync. Its methods are safe for concurrent use, so that
+// the sources of a multi-source sync and the parts of a parallel transfer report to the same
+// reporter.
+type Reporter interface {
+	// Phase reports the stage the sync entered
+	Phase(phase string)
+	// AddTotal adds files and bytes to the known amount of work, e.g. once a listing page arrived
+	AddTotal(files int, bytes int64)
+	// File reports the file being transferred
+	File(name string)
+	// FilesDone reports files that were transferred or found up to date
+	FilesDone(files int)
+	// Bytes reports transferred bytes
+	Bytes(n int64)
+}
+
+type contextKey struct{}
+
+// WithReporter returns a context carrying the reporter to the syncers
+func WithReporter(ctx context.Context, reporter Reporter) context.Context {
+	return context.WithValue(ctx, contextKey{}, reporter)
+}
+
+// From returns the reporter of the context, which discards the progress when none is set
+func From(ctx context.Context) Reporter {
+	if reporter, ok := ctx.Value(contextKey{}).(Reporter); ok {
+		return reporter
+	}
+	return discard{}
+}
+
+type discard struct{}
+
+func (discard) Phase(string)        {}
+func (discard) AddTotal(int, int64) {}
+func (discard) File(string)         {}
+func (discard) FilesDone(int)       {}
+func (discard) Bytes(int64)         {}
+
+// Reader reports the bytes read from r
+func Reader(r io.Reader, reporter Reporter) io.Reader {
+	if _, ok := reporter.(discard); ok {
+		return r
+	}
+	return &reader{r: r, reporter: reporter}
+}
+
+// WriterAt reports the bytes written to w, e.g. by the concurrent parts of a download
+func WriterAt(w io.WriterAt, reporter Reporter) io.WriterAt {
+	if _, ok := reporter.(discard); ok {
+		return w
+	}
+	return &writerAt{w: w, reporter: reporter}
+}
+
+type reader struct {
+	r        io.Reader
+	reporter Reporter
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.reporter.Bytes(int64(n))
+	return n, err
+}
+
+type writerAt struct {
+	w        io.WriterAt
+	reporter Reporter
+}
+
+func (w *writerAt) WriteAt(p []byte, off int64) (int, error) {
+	n, err := w.w.WriteAt(p, off)
+	w.reporter.Bytes(int64(n))
+	return n, err
+}
+
+// Attempt forwards the progress of one attempt of a transfer to the reporter and takes back its
+// bytes when the attempt failed and is repeated
+type Attempt struct {
+	Reporter
+	bytes atomic.Int64
+}
+
+// NewAttempt starts an attempt reporting to the reporter
+func NewAttempt(reporter Reporter) *Attempt {
+	return &Attempt{Reporter: reporter}
+}
+
+// Bytes counts the bytes of the attempt
+func (a *Attempt) Bytes(n int64) {
+	a.bytes.Add(n)
+	a.Reporter.Bytes(n)
+}
+
+// Discard takes back the bytes reported by the attempt
+func (a *Attempt) Discard() {
+	a.Reporter.Bytes(-a.bytes.Swap(0))
+}
+
+// Tracker records the progress of the running job for the status API and the metrics
+type Tracker struct {
+	filesDone  atomic.Int64
+	filesTotal atomic.Int64
+	bytesDone  atomic.Int64
+	bytesTotal atomic.Int64
+	updated    atomic.Int64 // Unix nanoseconds of the last report
+
+	mu      sync.Mutex
+	phase   string
+	current string
+}
+
+// NewTracker creates a tracker of a job
+func NewTracker() *Tracker {
+	t := &Tracker{}
+	t.touch()
+	return t
+}
+
+// Reset clears the counters before another attempt of the sync
+func (t *Tracker) Reset() {
+	t.filesDone.Store(0)
+	t.filesTotal.Store(0)
+	t.bytesDone.Store(0)
+	t.bytesTotal.Store(0)
+	t.mu.Lock()
+	t.current = ""
+	t.mu.Unlock()
+	t.touch()
+}
+
+func (t *Tracker) touch() {
+	t.updated.Store(time.Now().UnixNano())
+}
+
+// Phase records the stage the job entered
+func (t *Tracker) Phase(phase string) {
+	t.mu.Lock()
+	t.phase = phase
+	t.mu.Unlock()
+	t.touch()
+}
+
+// AddTotal adds to the known amount of work
+func (t *Tracker) AddTotal(files int, bytes int64) {
+	t.filesTotal.Add(int64(files))
+	t.bytesTotal.Add(bytes)
+	t.touch()
+}
+
+// File records the file being transferred
+func (t *Tracker) File(name string) {
+	t.mu.Lock()
+	t.current = name
+	t.mu.Unlock()
+	t.touch()
+}
+
+// FilesDone counts completed files
+func (t *Tracker) FilesDone(files int) {
+	t.filesDone.Add(int64(files))
+	t.touch()
+}
+
+// Bytes counts transferred bytes, negative counts take back those of a failed attempt
+func (t *Tracker) Bytes(n int64) {
+	if n != 0 {
+		t.bytesDone.Add(n)
+		t.touch()
+	}
+}
+
+// Snapshot returns the recorded progress
+func (t *Tracker) Snapshot() models.SyncProgress {
+	t.mu.Lock()
+	phase, current := t.phase, t.current
+	t.mu.Unlock()
+	return models.SyncProgress{
+		Phase:       phase,
+		FilesDone:   t.filesDone.Load(),
+		FilesTotal:  t.filesTotal.Load(),
+		BytesDone:   t.bytesDone.Load(),
+		BytesTotal:  t.bytesTotal.Load(),
+		CurrentFile: current,
+		UpdatedAt:   time.Unix(0, t.updated.Load()).UTC(),
+	}
+}
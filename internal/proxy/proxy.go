@@ -0,0 +1,245 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+	xproxy "golang.org/x/net/proxy"
+
+	"github.com/sharedvolume/volume-syncer/internal/models"
+	"github.com/sharedvolume/volume-syncer/internal/netdial"
+)
+
+// Proxy routes the connections of a sync through an HTTP, HTTPS or SOCKS5 proxy, except those to
+// the hosts of its no-proxy list. A nil proxy leaves HTTP connections to the HTTP_PROXY,
+// HTTPS_PROXY and NO_PROXY variables of the server and connects everything else directly.
+type Proxy struct {
+	url     *url.URL // nil when every connection is direct
+	noProxy []string
+	match   func(*url.URL) (*url.URL, error)
+}
+
+// New parses the proxy options, nil options returning a nil proxy. Options without a URL connect
+// directly, ignoring the proxy variables of the server.
+func New(options *models.ProxyOptions) (*Proxy, error) {
+	if options == nil {
+		return nil, nil
+	}
+	p := &Proxy{noProxy: options.NoProxy}
+	if options.URL != "" {
+		parsed, err := url.Parse(options.URL)
+		if err != nil || parsed.Hostname() == "" || parsed.Path != "" && parsed.Path != "/" {
+			return nil, errors.New("url must be a proxy URL such as http://proxy.internal:3128 or socks5://proxy.internal:1080")
+		}
+		switch parsed.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return nil, fmt.Errorf("url scheme %q is not supported, expected http, https, socks5 or socks5h", parsed.Scheme)
+		}
+		parsed.Path = ""
+		p.url = parsed
+	}
+	for _, entry := range options.NoProxy {
+		if entry == "" || strings.ContainsAny(entry, ", \t\r\n") {
+			return nil, fmt.Errorf("noProxy entry %q is invalid, expected a host, a domain such as .example.com or a CIDR range", entry)
+		}
+	}
+
+	config := httpproxy.Config{NoProxy: strings.Join(options.NoProxy, ",")}
+	if p.url != nil {
+		config.HTTPProxy, config.HTTPSProxy = p.url.String(), p.url.String()
+	}
+	p.match = config.ProxyFunc()
+	return p, nil
+}
+
+// String describes the proxy without its credentials, e.g. "http://proxy.internal:3128, not for
+// .svc,10.0.0.0/8"
+func (p *Proxy) String() string {
+	if p == nil {
+		return "environment"
+	}
+	description := "direct"
+	if p.url != nil {
+		description = p.url.Redacted()
+	}
+	if len(p.noProxy) > 0 {
+		description += ", not for " + strings.Join(p.noProxy, ",")
+	}
+	return description
+}
+
+// URL returns the URL of the proxy with its credentials, "" when connections are direct
+func (p *Proxy) URL() string {
+	if p == nil || p.url == nil {
+		return ""
+	}
+	return p.url.String()
+}
+
+// NoProxy returns the hosts, domains and CIDR ranges reached directly, comma-separated
+func (p *Proxy) NoProxy() string {
+	if p == nil {
+		return ""
+	}
+	return strings.Join(p.noProxy, ",")
+}
+
+// ForRequest returns the proxy of an HTTP request, nil for a direct connection. It is meant for
+// http.Transport.Proxy.
+func (p *Proxy) ForRequest(req *http.Request) (*url.URL, error) {
+	if p == nil {
+		return http.ProxyFromEnvironment(req)
+	}
+	return p.match(req.URL)
+}
+
+// Route returns the proxy of a TCP connection to the address, nil for a direct connection
+func (p *Proxy) Route(address string) *url.URL {
+	if p == nil || p.url == nil {
+		return nil
+	}
+	route, _ := p.match(&url.URL{Scheme: "https", Host: address})
+	return route
+}
+
+// DialContext connects to the address through the proxy, or directly when the address is on the
+// no-proxy list. Host names are resolved with the default resolver of netdial.
+func (p *Proxy) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	dialer := resolvingDialer{}
+	route := p.Route(address)
+	if route == nil {
+		return dialer.DialContext(ctx, network, address)
+	}
+
+	switch route.Scheme {
+	case "socks5", "socks5h":
+		var auth *xproxy.Auth
+		if route.User != nil {
+			password, _ := route.User.Password()
+			auth = &xproxy.Auth{User: route.User.Username(), Password: password}
+		}
+		socks, err := xproxy.SOCKS5("tcp", hostPort(route), auth, dialer)
+		if err != nil {
+			return nil, err
+		}
+		conn, err := socks.(xproxy.ContextDialer).DialContext(ctx, network, address)
+		if err != nil {
+			return nil, fmt.Errorf("proxy %s: %w", route.Redacted(), err)
+		}
+		return conn, nil
+	default:
+		return connect(ctx, dialer, route, address)
+	}
+}
+
+// connect opens a tunnel to the address with an HTTP CONNECT request to the proxy
+func connect(ctx context.Context, dialer resolvingDialer, route *url.URL, address string) (net.Conn, error) {
+	conn, err := dialer.DialContext(ctx, "tcp", hostPort(route))
+	if err != nil {
+		return nil, fmt.Errorf("proxy %s: %w", route.Redacted(), err)
+	}
+	if route.Scheme == "https" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: route.Hostname(), MinVersion: tls.VersionTLS12})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("proxy %s: %w", route.Redacted(), err)
+		}
+		conn = tlsConn
+	}
+	// The deadline of the context also bounds the CONNECT exchange
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	req := &http.Request{Method: http.MethodConnect, URL: &url.URL{Opaque: address}, Host: address, Header: http.Header{}}
+	if route.User != nil {
+		password, _ := route.User.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(route.User.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("proxy %s: %w", route.Redacted(), err)
+	}
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("proxy %s: %w", route.Redacted(), err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy %s refused to connect to %s: %s", route.Redacted(), address, resp.Status)
+	}
+	conn.SetDeadline(time.Time{})
+	if reader.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, reader: reader}, nil
+	}
+	return conn, nil
+}
+
+// resolvingDialer dials with the default resolver of netdial
+type resolvingDialer struct {
+	dialer net.Dialer
+}
+
+func (d resolvingDialer) Dial(network, address string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, address)
+}
+
+func (d resolvingDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	return netdial.DialContext(ctx, &d.dialer, network, address)
+}
+
+// bufferedConn is a tunnel whose first bytes were read with the response of the proxy
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+// hostPort returns the address of the proxy, with the default port of its scheme
+func hostPort(route *url.URL) string {
+	if port := route.Port(); port != "" {
+		return route.Host
+	}
+	port := 1080
+	switch route.Scheme {
+	case "http":
+		port = 80
+	case "https":
+		port = 443
+	}
+	return net.JoinHostPort(route.Hostname(), strconv.Itoa(port))
+}
+
+// contextKey is the key of the proxy options carried by contexts
+type contextKey struct{}
+
+// NewContext returns a context carrying the proxy options of a request, e.g. for the preflight
+// checks of its sources
+func NewContext(ctx context.Context, options *models.ProxyOptions) context.Context {
+	return context.WithValue(ctx, contextKey{}, options)
+}
+
+// FromContext returns the proxy options carried by the context
+func FromContext(ctx context.Context) (*models.ProxyOptions, bool) {
+	options, ok := ctx.Value(contextKey{}).(*models.ProxyOptions)
+	return options, ok
+}
@@ -0,0 +1,75 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/sharedvolume/volume-syncer/internal/models"
+	"github.com/sharedvolume/volume-syncer/internal/netdial"
+)
+
+const (
+	connectURLEnv     = "VOLUME_SYNCER_PROXY_URL"
+	connectNoProxyEnv = "VOLUME_SYNCER_NO_PROXY"
+)
+
+// RunConnect relays stdin and stdout to the host and port of its arguments through the proxy when
+// the binary is invoked as ssh ProxyCommand. It returns false when the process was started
+// normally.
+func RunConnect() bool {
+	proxyURL, ok := os.LookupEnv(connectURLEnv)
+	if !ok || len(os.Args) != 3 {
+		return false
+	}
+
+	options := &models.ProxyOptions{URL: proxyURL}
+	if noProxy := os.Getenv(connectNoProxyEnv); noProxy != "" {
+		options.NoProxy = strings.Split(noProxy, ",")
+	}
+	resolver, err := netdial.FromEnv()
+	var p *Proxy
+	if err == nil {
+		netdial.SetDefault(resolver)
+		p, err = New(options)
+	}
+	if err == nil {
+		var conn net.Conn
+		if conn, err = p.DialContext(context.Background(), "tcp", net.JoinHostPort(os.Args[1], os.Args[2])); err == nil {
+			go func() {
+				io.Copy(conn, os.Stdin)
+				if tcp, ok := conn.(interface{ CloseWrite() error }); ok {
+					tcp.CloseWrite()
+				}
+			}()
+			io.Copy(os.Stdout, conn)
+			conn.Close()
+			return true
+		}
+	}
+	fmt.Fprintf(os.Stderr, "volume-syncer proxy: %v\n", err)
+	os.Exit(1)
+	return true
+}
+
+// SSHOptions returns the ssh option and the environment that route the connection of ssh to the
+// address through the proxy, with this binary as ProxyCommand. The connection also goes through
+// the ProxyCommand when the default resolver of netdial is set, so that the host is resolved
+// with it. Nothing is returned for other direct connections. The proxy URL is passed in the
+// environment, so that its credentials do not appear in process arguments.
+func (p *Proxy) SSHOptions(address string) (string, []string, error) {
+	resolver := netdial.Default()
+	if p.Route(address) == nil && resolver == nil {
+		return "", nil, nil
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to resolve executable for ssh ProxyCommand: %w", err)
+	}
+	option := fmt.Sprintf("-o 'ProxyCommand=%s %%h %%p'", executable)
+	env := []string{connectURLEnv + "=" + p.URL(), connectNoProxyEnv + "=" + p.NoProxy()}
+	return option, append(env, resolver.Env()...), nil
+}
@@ -0,0 +1,134 @@
+package purge
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TokenTTL is how long a confirmation token stays valid
+const TokenTTL = 5 * time.Minute
+
+// ErrInvalidToken is returned for confirmation tokens that are malformed, expired or issued for
+// another path
+var ErrInvalidToken = errors.New("invalid or expired confirmation token")
+
+// Purger empties target directories below the allowed roots. Each purge is confirmed with a
+// short-lived token issued for the path, so that a single request cannot empty a target.
+type Purger struct {
+	roots  []string // resolved allowed roots
+	secret []byte   // signs the confirmation tokens, regenerated on every start
+}
+
+// New parses a comma-separated list of absolute directories below which targets may be purged.
+// An empty list returns nil, which disables purging.
+func New(spec string) (*Purger, error) {
+	p := &Purger{}
+	for _, root := range strings.Split(spec, ",") {
+		root = strings.TrimSpace(root)
+		if root == "" {
+			continue
+		}
+		if !filepath.IsAbs(root) {
+			return nil, fmt.Errorf("purge root %q must be an absolute path", root)
+		}
+		// Symbolic links are resolved, so that targets cannot escape the roots through them
+		resolved, err := filepath.EvalSymlinks(root)
+		if err != nil {
+			return nil, fmt.Errorf("invalid purge root %s: %w", root, err)
+		}
+		p.roots = append(p.roots, resolved)
+	}
+	if len(p.roots) == 0 {
+		return nil, nil
+	}
+	p.secret = make([]byte, 32)
+	if _, err := rand.Read(p.secret); err != nil {
+		return nil, fmt.Errorf("failed to generate confirmation secret: %w", err)
+	}
+	return p, nil
+}
+
+// Roots returns the resolved allowed roots
+func (p *Purger) Roots() []string {
+	return p.roots
+}
+
+// Resolve returns the target directory the path resolves to, which must lie strictly below one of
+// the allowed roots
+func (p *Purger) Resolve(path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		return "", fmt.Errorf("target %s must be an absolute path", path)
+	}
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", fmt.Errorf("invalid target %s: %w", path, err)
+	}
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("target %s is not a directory", path)
+	}
+	for _, root := range p.roots {
+		if rel, err := filepath.Rel(root, resolved); err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, "../") {
+			return resolved, nil
+		}
+	}
+	return "", fmt.Errorf("target %s is not below an allowed purge root", path)
+}
+
+// Token issues a confirmation token for purging the resolved target before the returned expiry
+func (p *Purger) Token(target string, now time.Time) (string, time.Time) {
+	expires := now.Add(TokenTTL).UTC().Truncate(time.Second)
+	expiry := strconv.FormatInt(expires.Unix(), 10)
+	return expiry + "." + p.sign(target, expiry), expires
+}
+
+// Verify checks that the token was issued for the resolved target and has not expired
+func (p *Purger) Verify(target, token string, now time.Time) error {
+	expiry, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return ErrInvalidToken
+	}
+	seconds, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil || now.After(time.Unix(seconds, 0)) {
+		return ErrInvalidToken
+	}
+	if !hmac.Equal([]byte(signature), []byte(p.sign(target, expiry))) {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+func (p *Purger) sign(target, expiry string) string {
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write([]byte(target + "\x00" + expiry))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Empty removes the contents of the directory, keeping the directory itself, and returns the
+// number of removed entries
+func Empty(dir string) (int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, entry := range entries {
+		if err := os.RemoveAll(filepath.Join(dir, entry.Name())); err != nil {
+			return removed, fmt.Errorf("failed to remove %s: %w", entry.Name(), err)
+		}
+		removed++
+	}
+	return removed, nil
+}
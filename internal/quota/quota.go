@@ -0,0 +1,109 @@
+package quota
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// sizeUnits maps the accepted size suffixes to their multipliers
+var sizeUnits = map[string]int64{
+	"":   1,
+	"K":  1000,
+	"M":  1000 * 1000,
+	"G":  1000 * 1000 * 1000,
+	"T":  1000 * 1000 * 1000 * 1000,
+	"Ki": 1 << 10,
+	"Mi": 1 << 20,
+	"Gi": 1 << 30,
+	"Ti": 1 << 40,
+}
+
+// Limit is the byte budget of a directory tree
+type Limit struct {
+	Path  string
+	Bytes int64
+}
+
+// Quotas holds the configured byte budgets by path
+type Quotas struct {
+	limits []Limit // longest path first
+}
+
+// Parse parses a comma-separated list of path=size budgets, e.g. "/data/team-a=500Gi,/data/team-b=100G"
+func Parse(spec string) (*Quotas, error) {
+	q := &Quotas{}
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		path, size, ok := strings.Cut(item, "=")
+		if !ok || !filepath.IsAbs(strings.TrimSpace(path)) {
+			return nil, fmt.Errorf("invalid quota %q, expected <absolute path>=<size>", item)
+		}
+		path = filepath.Clean(strings.TrimSpace(path))
+		if seen[path] {
+			return nil, fmt.Errorf("duplicate quota for %s", path)
+		}
+		seen[path] = true
+
+		bytes, err := ParseSize(strings.TrimSpace(size))
+		if err != nil {
+			return nil, fmt.Errorf("invalid quota for %s: %w", path, err)
+		}
+		q.limits = append(q.limits, Limit{Path: path, Bytes: bytes})
+	}
+	sort.Slice(q.limits, func(i, j int) bool { return len(q.limits[i].Path) > len(q.limits[j].Path) })
+	return q, nil
+}
+
+// ParseSize parses a positive byte count with an optional decimal (K, M, G, T) or binary (Ki, Mi, Gi, Ti) suffix
+func ParseSize(size string) (int64, error) {
+	number := strings.TrimRight(size, "KMGTi")
+	multiplier, ok := sizeUnits[size[len(number):]]
+	if !ok {
+		return 0, fmt.Errorf("invalid size unit in %q", size)
+	}
+	value, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("invalid size %q", size)
+	}
+	if value > (1<<63-1)/multiplier {
+		return 0, fmt.Errorf("size %q is too large", size)
+	}
+	return value * multiplier, nil
+}
+
+// For returns the budget of the closest configured path containing the target, or nil when none applies
+func (q *Quotas) For(targetPath string) *Limit {
+	if q == nil {
+		return nil
+	}
+	targetPath = filepath.Clean(targetPath)
+	for i := range q.limits {
+		limit := &q.limits[i]
+		if targetPath == limit.Path || strings.HasPrefix(targetPath, limit.Path+string(filepath.Separator)) {
+			return limit
+		}
+	}
+	return nil
+}
+
+// Limits returns the configured budgets
+func (q *Quotas) Limits() []Limit {
+	if q == nil {
+		return nil
+	}
+	limits := make([]Limit, len(q.limits))
+	copy(limits, q.limits)
+	sort.Slice(limits, func(i, j int) bool { return limits[i].Path < limits[j].Path })
+	return limits
+}
+
+// ErrExceeded is returned when a sync would exceed the budget of its target
+var ErrExceeded = errors.New("quota exceeded")
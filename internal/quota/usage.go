@@ -0,0 +1,42 @@
+package quota
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Usage returns the bytes allocated by the files below the path. Hard links are counted once
+// and a missing path uses nothing.
+func Usage(path string) (int64, error) {
+	var total int64
+	seen := make(map[fileID]bool)
+	err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		info, err := d.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if id, ok := inode(info); ok {
+			if seen[id] {
+				return nil
+			}
+			seen[id] = true
+		}
+		total += allocated(info)
+		return nil
+	})
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return 0, err
+	}
+	return total, nil
+}
@@ -0,0 +1,18 @@
+//go:build !unix
+
+package quota
+
+import "io/fs"
+
+// fileID identifies a file across hard links
+type fileID struct{}
+
+// inode reports no identity; hard links cannot be detected on this platform
+func inode(info fs.FileInfo) (fileID, bool) {
+	return fileID{}, false
+}
+
+// allocated returns the file size, the allocated size is not available on this platform
+func allocated(info fs.FileInfo) int64 {
+	return info.Size()
+}
@@ -0,0 +1,30 @@
+//go:build unix
+
+package quota
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// fileID identifies a file across hard links
+type fileID struct {
+	dev, ino uint64
+}
+
+// inode returns the identity of files with several hard links
+func inode(info fs.FileInfo) (fileID, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || stat.Nlink <= 1 {
+		return fileID{}, false
+	}
+	return fileID{dev: uint64(stat.Dev), ino: stat.Ino}, true
+}
+
+// allocated returns the bytes allocated on disk, so that sparse files count their data only
+func allocated(info fs.FileInfo) int64 {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return stat.Blocks * 512
+	}
+	return info.Size()
+}
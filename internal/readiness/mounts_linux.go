@@ -0,0 +1,56 @@
+//go:build linux
+
+package readiness
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// mountPoint reports whether a filesystem is mounted at the directory, as listed in
+// /proc/self/mountinfo. Bind mounts of a directory, as used for most Kubernetes volumes, are
+// listed as well.
+func mountPoint(dir string) (bool, error) {
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return false, err
+	}
+	file, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// The fifth field is the mount point, with spaces and other special characters escaped
+		// as octal sequences
+		fields := strings.Fields(scanner.Text())
+		if len(fields) > 4 && unescapeOctal(fields[4]) == resolved {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
+
+// unescapeOctal replaces the octal escape sequences of a mountinfo field, e.g. \040 for a space
+func unescapeOctal(field string) string {
+	if !strings.Contains(field, `\`) {
+		return field
+	}
+	var out strings.Builder
+	for i := 0; i < len(field); i++ {
+		if field[i] == '\\' && i+3 < len(field) {
+			if value, err := strconv.ParseUint(field[i+1:i+4], 8, 8); err == nil {
+				out.WriteByte(byte(value))
+				i += 3
+				continue
+			}
+		}
+		out.WriteByte(field[i])
+	}
+	return out.String()
+}
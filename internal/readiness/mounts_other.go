@@ -0,0 +1,10 @@
+//go:build !linux
+
+package readiness
+
+import "errors"
+
+// mountPoint cannot list the mounts on this platform, so only the directory is checked
+func mountPoint(dir string) (bool, error) {
+	return false, errors.ErrUnsupported
+}
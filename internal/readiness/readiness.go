@@ -0,0 +1,126 @@
+package readiness
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/sharedvolume/volume-syncer/internal/jobstate"
+	"github.com/sharedvolume/volume-syncer/internal/models"
+)
+
+// Checker verifies the dependencies the server needs to run syncs: the binaries the syncers
+// execute, the staging directory, the volumes of the target roots and the job store. Servers
+// seeding their targets at startup are only ready once the startup syncs succeeded.
+type Checker struct {
+	binaries    []string
+	stagingDir  string   // empty when staging next to the targets
+	targetRoots []string // directories the target volumes are mounted at
+	state       *jobstate.Store
+	startup     func() (string, error)
+}
+
+// New creates a checker of the comma-separated target roots. The git binary is only required when
+// Git sources use it. startup reports the startup syncs, with an error until all of them succeeded
+// and ErrSkipped without startup syncs.
+func New(gitBinary bool, stagingDir, targetRoots string, state *jobstate.Store, startup func() (string, error)) (*Checker, error) {
+	c := &Checker{binaries: []string{"rsync", "ssh"}, stagingDir: stagingDir, state: state, startup: startup}
+	if gitBinary {
+		c.binaries = append([]string{"git"}, c.binaries...)
+	}
+	for _, root := range strings.Split(targetRoots, ",") {
+		if root = strings.TrimSpace(root); root == "" {
+			continue
+		}
+		if !filepath.IsAbs(root) {
+			return nil, fmt.Errorf("target root %s is not an absolute path", root)
+		}
+		c.targetRoots = append(c.targetRoots, filepath.Clean(root))
+	}
+	return c, nil
+}
+
+// Check runs every check and reports whether all of them passed or were skipped
+func (c *Checker) Check() ([]models.ReadinessCheck, bool) {
+	var checks []models.ReadinessCheck
+	ready := true
+	add := func(name string, check func() (string, error)) {
+		result := models.ReadinessCheck{Name: name, Status: models.CheckPassed}
+		message, err := check()
+		switch {
+		case errors.Is(err, ErrSkipped):
+			result.Status = models.CheckSkipped
+		case err != nil:
+			result.Status = models.CheckFailed
+			result.Error = err.Error()
+			ready = false
+		}
+		result.Message = message
+		checks = append(checks, result)
+	}
+
+	for _, binary := range c.binaries {
+		add(binary, func() (string, error) {
+			path, err := exec.LookPath(binary)
+			if err != nil {
+				return "", fmt.Errorf("%s not found in PATH", binary)
+			}
+			return path, nil
+		})
+	}
+	add("stagingDir", func() (string, error) {
+		if c.stagingDir == "" {
+			return "staging next to the targets", ErrSkipped
+		}
+		return c.stagingDir, writable(c.stagingDir)
+	})
+	if len(c.targetRoots) == 0 {
+		add("targetRoot", func() (string, error) { return "SYNC_TARGET_ROOTS not set", ErrSkipped })
+	}
+	for _, root := range c.targetRoots {
+		add("targetRoot", func() (string, error) { return root, checkTargetRoot(root) })
+	}
+	add("jobStore", func() (string, error) {
+		if c.state == nil {
+			return "job persistence disabled", ErrSkipped
+		}
+		return "", c.state.Check()
+	})
+	add("startupSyncs", c.startup)
+	return checks, ready
+}
+
+// ErrSkipped reports a check of a dependency that is not configured
+var ErrSkipped = errors.New("skipped")
+
+// checkTargetRoot verifies that a volume is mounted at the root and that files can be created in it
+func checkTargetRoot(root string) error {
+	info, err := os.Stat(root)
+	if err != nil {
+		return fmt.Errorf("target root %s not found: %w", root, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("target root %s is not a directory", root)
+	}
+	mounted, err := mountPoint(root)
+	if err != nil && !errors.Is(err, errors.ErrUnsupported) {
+		return fmt.Errorf("failed to list mounts: %w", err)
+	}
+	if err == nil && !mounted {
+		return fmt.Errorf("no volume is mounted at target root %s", root)
+	}
+	return writable(root)
+}
+
+// writable checks that files can be created in the directory
+func writable(dir string) error {
+	probe, err := os.CreateTemp(dir, ".probe-*")
+	if err != nil {
+		return fmt.Errorf("%s is not writable: %w", dir, err)
+	}
+	probe.Close()
+	return os.Remove(probe.Name())
+}
@@ -0,0 +1,145 @@
+package retention
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sharedvolume/volume-syncer/internal/models"
+	"github.com/sharedvolume/volume-syncer/internal/quota"
+)
+
+// Limits bound the finished jobs whose records, or whose logs, are kept. Zero limits are
+// unlimited.
+type Limits struct {
+	MaxAge   time.Duration // age since the job finished
+	MaxCount int           // most recent finished jobs
+	MaxSize  int64         // bytes of the most recent finished jobs together
+}
+
+// Policy is the retention of the finished jobs in the history and the job state directory, and
+// of the logs recorded in them: the outputs of the hooks and the files itemized by rsync
+type Policy struct {
+	Jobs Limits
+	Logs Limits
+}
+
+// Parse returns the limits of a maximum age, count and size such as "50Mi", an empty size
+// being unlimited
+func Parse(maxAge time.Duration, maxCount int, maxSize string) (Limits, error) {
+	if maxAge < 0 {
+		return Limits{}, fmt.Errorf("max age cannot be negative")
+	}
+	if maxCount < 0 {
+		return Limits{}, fmt.Errorf("max count cannot be negative")
+	}
+	limits := Limits{MaxAge: maxAge, MaxCount: maxCount}
+	if maxSize != "" {
+		size, err := quota.ParseSize(maxSize)
+		if err != nil {
+			return Limits{}, fmt.Errorf("invalid max size %q: %w", maxSize, err)
+		}
+		limits.MaxSize = size
+	}
+	return limits, nil
+}
+
+// String describes the limits, e.g. "max age 168h0m0s, max count 100, max size 52428800 bytes"
+func (l Limits) String() string {
+	var settings []string
+	if l.MaxAge > 0 {
+		settings = append(settings, fmt.Sprintf("max age %v", l.MaxAge))
+	}
+	if l.MaxCount > 0 {
+		settings = append(settings, fmt.Sprintf("max count %d", l.MaxCount))
+	}
+	if l.MaxSize > 0 {
+		settings = append(settings, fmt.Sprintf("max size %d bytes", l.MaxSize))
+	}
+	if len(settings) == 0 {
+		return "unlimited"
+	}
+	return strings.Join(settings, ", ")
+}
+
+// Counter applies limits to finished jobs counted from the most recent to the oldest. Once a
+// job is beyond the limits, so are all older ones.
+type Counter struct {
+	limits   Limits
+	size     func(*models.SyncJob) int64
+	now      time.Time
+	count    int
+	bytes    int64
+	exceeded bool
+}
+
+// Counter returns a counter of the limits measuring jobs with the size function
+func (l Limits) Counter(size func(*models.SyncJob) int64, now time.Time) *Counter {
+	return &Counter{limits: l, size: size, now: now}
+}
+
+// Keep counts the next older finished job and reports whether it is within the limits
+func (c *Counter) Keep(job *models.SyncJob) bool {
+	if c.exceeded {
+		return false
+	}
+	c.count++
+	if c.limits.MaxCount > 0 && c.count > c.limits.MaxCount {
+		c.exceeded = true
+	}
+	if c.limits.MaxAge > 0 && job.FinishedAt != nil && c.now.Sub(*job.FinishedAt) > c.limits.MaxAge {
+		c.exceeded = true
+	}
+	if c.limits.MaxSize > 0 {
+		c.bytes += c.size(job)
+		if c.bytes > c.limits.MaxSize {
+			c.exceeded = true
+		}
+	}
+	return !c.exceeded
+}
+
+// RecordSize returns the size of the job as its finished record persists it
+func RecordSize(job *models.SyncJob) int64 {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return 0
+	}
+	return int64(len(data))
+}
+
+// LogSize returns the size of the logs of the job
+func LogSize(job *models.SyncJob) int64 {
+	var size int64
+	for _, hook := range job.Hooks {
+		size += int64(len(hook.Output))
+	}
+	if job.Result != nil && job.Result.Transfer != nil {
+		for _, files := range [][]string{job.Result.Transfer.Added, job.Result.Transfer.Updated, job.Result.Transfer.Deleted} {
+			for _, file := range files {
+				size += int64(len(file))
+			}
+		}
+	}
+	return size
+}
+
+// DropLogs removes the logs of the job, keeping its outcome and counts. Snapshots of the job
+// taken before keep theirs.
+func DropLogs(job *models.SyncJob) {
+	if len(job.Hooks) > 0 {
+		job.Hooks = slices.Clone(job.Hooks)
+		for i := range job.Hooks {
+			job.Hooks[i].Output = ""
+		}
+	}
+	if job.Result != nil && job.Result.Transfer != nil {
+		result, transfer := *job.Result, *job.Result.Transfer
+		transfer.Added, transfer.Updated, transfer.Deleted, transfer.Truncated = nil, nil, nil, false
+		result.Transfer = &transfer
+		job.Result = &result
+	}
+	job.LogsPruned = true
+}
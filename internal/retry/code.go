@@ -0,0 +1,105 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"syscall"
+
+	"github.com/sharedvolume/volume-syncer/internal/quota"
+	syncerrors "github.com/sharedvolume/volume-syncer/pkg/errors"
+)
+
+// codeMessages are fragments of error messages and tool output by error code, checked in order
+var codeMessages = []struct {
+	code      string
+	fragments []string
+}{
+	{syncerrors.CodeDiskFull, []string{
+		"no space left on device",
+		"disk quota exceeded",
+		"quota exceeded",
+	}},
+	{syncerrors.CodeAuthFailed, []string{
+		"authentication failed",
+		"permission denied (publickey",
+		"unable to authenticate",
+		"could not read username",
+		"invalid username or password",
+		"the requested url returned error: 401",
+		"the requested url returned error: 403",
+		"http 401",
+		"http 403",
+		"accessdenied",
+		"invalidaccesskeyid",
+		"signaturedoesnotmatch",
+	}},
+	{syncerrors.CodeNotFound, []string{
+		"repository not found",
+		"does not appear to be a git repository",
+		"couldn't find remote ref",
+		"no such file or directory",
+		"the requested url returned error: 404",
+		"http 404",
+		"nosuchbucket",
+		"nosuchkey",
+		"not found",
+	}},
+	{syncerrors.CodeTimeout, []string{
+		"timed out",
+		"i/o timeout",
+		"handshake timeout",
+		"requesttimeout",
+	}},
+}
+
+// Classify returns the error code of a failed sync and whether running it again is likely to
+// succeed, derived from the type of the sync error and the failure it wraps
+func Classify(err error) (string, bool) {
+	if err == nil {
+		return "", false
+	}
+	retryable := IsTransient(err)
+
+	// Exhausted disks and quotas are reported whatever layer wrapped them
+	if errors.Is(err, syscall.ENOSPC) || errors.Is(err, syscall.EDQUOT) || errors.Is(err, quota.ErrExceeded) {
+		return syncerrors.CodeDiskFull, retryable
+	}
+
+	var syncErr *syncerrors.SyncError
+	if errors.As(err, &syncErr) {
+		switch syncErr.Type {
+		case syncerrors.ErrTypeValidation:
+			return syncerrors.CodeValidation, false
+		case syncerrors.ErrTypeAuth:
+			return syncerrors.CodeAuthFailed, false
+		case syncerrors.ErrTypeTimeout:
+			return syncerrors.CodeTimeout, retryable
+		case syncerrors.ErrTypeConflict:
+			return syncerrors.CodeConflict, false
+		}
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return syncerrors.CodeTimeout, retryable
+	}
+	if errors.Is(err, context.Canceled) {
+		return syncerrors.CodeCanceled, false
+	}
+	message := strings.ToLower(err.Error())
+	for _, entry := range codeMessages {
+		for _, fragment := range entry.fragments {
+			if strings.Contains(message, fragment) {
+				return entry.code, retryable
+			}
+		}
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return syncerrors.CodeNotFound, retryable
+	}
+	if retryable || (syncErr != nil && syncErr.Type == syncerrors.ErrTypeNetwork) {
+		return syncerrors.CodeNetwork, retryable
+	}
+	return syncerrors.CodeUnknown, retryable
+}
@@ -0,0 +1,88 @@
+package retry
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/sharedvolume/volume-syncer/internal/models"
+	"github.com/sharedvolume/volume-syncer/internal/validation"
+)
+
+// maxAttempts bounds the attempts of a sync, so that a request cannot keep the syncer busy indefinitely
+const maxAttempts = 20
+
+// Policy describes how often and how fast a failed sync is attempted again
+type Policy struct {
+	Attempts   int           // Total number of attempts, 1 disables retries
+	Backoff    time.Duration // Delay before the first retry, doubled for every further retry
+	MaxBackoff time.Duration // Upper bound of the delay
+}
+
+// Enabled reports whether failed syncs are attempted again
+func (p Policy) Enabled() bool {
+	return p.Attempts > 1
+}
+
+// Delay returns the delay before the given retry, counting from 1
+func (p Policy) Delay(retry int) time.Duration {
+	delay := p.Backoff
+	for i := 1; i < retry && (p.MaxBackoff == 0 || delay < p.MaxBackoff); i++ {
+		delay *= 2
+	}
+	if p.MaxBackoff > 0 && delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+	return delay
+}
+
+// Jitter returns a random delay between half the delay and the full delay, so that clients
+// failing together do not retry in lockstep
+func Jitter(delay time.Duration) time.Duration {
+	if delay <= 1 {
+		return delay
+	}
+	return delay/2 + rand.N(delay-delay/2)
+}
+
+// Resolve applies the retry options of a request to the default policy and validates the result
+func Resolve(defaults Policy, options *models.RetryOptions) (Policy, error) {
+	policy := defaults
+	if options != nil {
+		if options.Attempts < 0 {
+			return policy, validation.Field("attempts", errors.New("must not be negative"))
+		}
+		if options.Attempts > 0 {
+			policy.Attempts = options.Attempts
+		}
+		var err error
+		if options.Backoff != "" {
+			if policy.Backoff, err = time.ParseDuration(options.Backoff); err != nil {
+				return policy, validation.Field("backoff", fmt.Errorf("must be a duration such as 10s, not %q", options.Backoff))
+			}
+		}
+		if options.MaxBackoff != "" {
+			if policy.MaxBackoff, err = time.ParseDuration(options.MaxBackoff); err != nil {
+				return policy, validation.Field("maxBackoff", fmt.Errorf("must be a duration such as 5m, not %q", options.MaxBackoff))
+			}
+		}
+	}
+
+	if policy.Attempts < 1 {
+		policy.Attempts = 1
+	}
+	if policy.Attempts > maxAttempts {
+		return policy, validation.Field("attempts", fmt.Errorf("must not exceed %d", maxAttempts))
+	}
+	if policy.Backoff < 0 {
+		return policy, validation.Field("backoff", errors.New("must not be negative"))
+	}
+	if policy.MaxBackoff < 0 {
+		return policy, validation.Field("maxBackoff", errors.New("must not be negative"))
+	}
+	if policy.MaxBackoff > 0 && policy.MaxBackoff < policy.Backoff {
+		policy.MaxBackoff = policy.Backoff
+	}
+	return policy, nil
+}
@@ -0,0 +1,101 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os/exec"
+	"strings"
+	"syscall"
+
+	syncerrors "github.com/sharedvolume/volume-syncer/pkg/errors"
+)
+
+// transientRsyncExitCodes are the rsync exit codes of interrupted connections and timeouts
+var transientRsyncExitCodes = map[int]bool{
+	10:  true, // error in socket I/O
+	12:  true, // error in rsync protocol data stream
+	30:  true, // timeout in data send/receive
+	35:  true, // timeout waiting for daemon connection
+	255: true, // ssh connection failed
+}
+
+// transientMessages are fragments of error messages and tool output reporting network failures
+var transientMessages = []string{
+	"connection refused",
+	"connection reset",
+	"connection timed out",
+	"broken pipe",
+	"no route to host",
+	"network is unreachable",
+	"temporary failure in name resolution",
+	"could not resolve host",
+	"failed to connect to",
+	"couldn't connect to server",
+	"operation timed out",
+	"the requested url returned error: 5", // git over HTTP(S), server errors
+	"i/o timeout",
+	"timed out after",
+	"tls handshake timeout",
+	"unexpected eof",
+	"the remote end hung up unexpectedly",
+	"early eof",
+	"requesttimeout",
+	"slowdown",
+	"serviceunavailable",
+	"internalerror",
+}
+
+// IsTransient reports whether a sync failed for a reason that is likely to go away on its own,
+// such as a network failure or a timeout, so that attempting the sync again can succeed
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var syncErr *syncerrors.SyncError
+	if errors.As(err, &syncErr) {
+		switch syncErr.Type {
+		case syncerrors.ErrTypeNetwork, syncerrors.ErrTypeTimeout:
+			return true
+		case syncerrors.ErrTypeValidation, syncerrors.ErrTypeAuth, syncerrors.ErrTypeConflict:
+			return false
+		}
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	for _, errno := range []syscall.Errno{syscall.ECONNREFUSED, syscall.ECONNRESET, syscall.ECONNABORTED, syscall.EPIPE, syscall.ETIMEDOUT, syscall.EHOSTUNREACH, syscall.ENETUNREACH} {
+		if errors.Is(err, errno) {
+			return true
+		}
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && (dnsErr.IsTemporary || dnsErr.IsTimeout) {
+		return true
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && strings.Contains(err.Error(), "rsync") && transientRsyncExitCodes[exitErr.ExitCode()] {
+		return true
+	}
+	return IsTransientOutput(err.Error())
+}
+
+// IsTransientOutput reports whether an error message or the output of a failed command
+// reports a network failure
+func IsTransientOutput(output string) bool {
+	output = strings.ToLower(output)
+	for _, message := range transientMessages {
+		if strings.Contains(output, message) {
+			return true
+		}
+	}
+	return false
+}
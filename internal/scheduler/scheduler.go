@@ -0,0 +1,267 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/sharedvolume/volume-syncer/internal/audit"
+	"github.com/sharedvolume/volume-syncer/internal/definitions"
+	"github.com/sharedvolume/volume-syncer/internal/models"
+	"github.com/sharedvolume/volume-syncer/internal/readiness"
+	"github.com/sharedvolume/volume-syncer/internal/service"
+	"github.com/sharedvolume/volume-syncer/internal/syncer"
+)
+
+// Scheduler runs the sync definitions with a schedule, the startup definitions once it starts and
+// the watched definitions when their source changed
+type Scheduler struct {
+	cron        *cron.Cron
+	syncService *service.SyncService
+	ctx         context.Context // done once stopped, which ends the delays of jittered runs
+	stop        context.CancelFunc
+	startup     []models.SyncDefinition
+	watches     []watch
+
+	mutex       sync.Mutex
+	lastJobs    map[string]string // job of the last scheduled run per definition
+	startupRuns map[string]*startupRun
+}
+
+// startupRun is the run of a startup definition
+type startupRun struct {
+	jobID  string
+	status string // status of the job once it finished
+	err    string
+}
+
+// New schedules the definitions of the registry that set a schedule. Times are local to the
+// server, or to the time zone of the definition or the one set by CRON_TZ or TZ in the expression.
+// The definitions setting startup and those named in the comma-separated startup definitions are
+// run when the scheduler starts, definitions setting watch whenever their source changed.
+func New(registry *definitions.Registry, syncService *service.SyncService, startupDefinitions string) (*Scheduler, error) {
+	ctx, stop := context.WithCancel(context.Background())
+	s := &Scheduler{
+		cron:        cron.New(),
+		syncService: syncService,
+		ctx:         ctx,
+		stop:        stop,
+		lastJobs:    map[string]string{},
+		startupRuns: map[string]*startupRun{},
+	}
+	startup := map[string]bool{}
+	for _, name := range strings.Split(startupDefinitions, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		if _, ok := registry.Get(name); !ok {
+			stop()
+			return nil, fmt.Errorf("startup definition %s is not defined", name)
+		}
+		startup[name] = true
+	}
+	for _, def := range registry.All() {
+		if def.Startup || startup[def.Name] {
+			s.startup = append(s.startup, def)
+			log.Printf("[SCHEDULER] Sync definition %s runs at startup", def.Name)
+		}
+		if def.Watch != nil {
+			if !syncer.HasRevision(def.Source.Type) {
+				stop()
+				return nil, fmt.Errorf("sync definition %s: %s sources cannot be watched", def.Name, def.Source.Type)
+			}
+			interval, err := definitions.ParseWatchInterval(def.Watch.Interval)
+			if err != nil {
+				stop()
+				return nil, err
+			}
+			s.watches = append(s.watches, watch{def: def, interval: interval})
+			log.Printf("[SCHEDULER] Watching the source of sync definition %s every %v", def.Name, interval)
+		}
+		if def.Schedule == "" {
+			continue
+		}
+		schedule, err := definitions.ParseSchedule(def.Schedule, def.TimeZone)
+		if err != nil {
+			stop()
+			return nil, err
+		}
+		jitter, err := definitions.ParseJitter(def.Jitter)
+		if err != nil {
+			stop()
+			return nil, err
+		}
+		s.cron.Schedule(schedule, s.runner(def, jitter))
+		log.Printf("[SCHEDULER] Scheduled sync definition %s: %s (time zone: %s, jitter: %v, concurrency policy: %s)",
+			def.Name, def.Schedule, timeZoneName(def.TimeZone), jitter, concurrencyPolicy(def))
+	}
+	return s, nil
+}
+
+// Start submits the runs of the startup definitions and starts running the scheduled and watched
+// definitions
+func (s *Scheduler) Start() {
+	s.runStartup()
+	s.cron.Start()
+	for _, w := range s.watches {
+		go s.watch(w)
+	}
+}
+
+// runStartup submits a run of each startup definition. A run that cannot be submitted fails the
+// startup syncs.
+func (s *Scheduler) runStartup() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, def := range s.startup {
+		req := def.Request()
+		req.Initiator = "startup " + def.Name
+		job, _, err := s.syncService.QueueSync(req)
+		if err != nil {
+			log.Printf("[SCHEDULER] ERROR: Failed to start startup run of %s: %v", def.Name, err)
+			s.startupRuns[def.Name] = &startupRun{status: models.JobStatusFailed, err: err.Error()}
+			continue
+		}
+		s.startupRuns[def.Name] = &startupRun{jobID: job.ID}
+		s.lastJobs[def.Name] = job.ID
+		log.Printf("[SCHEDULER] Startup run of %s submitted as %s job %s", def.Name, job.Status, job.ID)
+	}
+}
+
+// StartupStatus reports the runs of the startup definitions to readiness probes, with an error
+// while one of them has not succeeded. Failed runs are not repeated, the server stays not ready
+// until it is restarted.
+func (s *Scheduler) StartupStatus() (string, error) {
+	if len(s.startup) == 0 {
+		return "no startup definitions", readiness.ErrSkipped
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	succeeded := 0
+	var pending []string
+	for _, def := range s.startup {
+		run, ok := s.startupRuns[def.Name]
+		if !ok {
+			pending = append(pending, def.Name+" not submitted")
+			continue
+		}
+		if run.status == "" {
+			job, ok := s.syncService.GetJob(run.jobID)
+			switch {
+			case !ok:
+				run.status, run.err = models.JobStatusFailed, "job "+run.jobID+" not found"
+			case job.Finished():
+				run.status, run.err = job.Status, job.Error
+			default:
+				pending = append(pending, def.Name+" "+job.Status)
+				continue
+			}
+		}
+		if run.status == models.JobStatusSucceeded {
+			succeeded++
+			continue
+		}
+		// The probes are not authenticated, so credentials in URLs are redacted
+		pending = append(pending, fmt.Sprintf("%s %s: %s", def.Name, run.status, audit.RedactText(run.err)))
+	}
+
+	message := fmt.Sprintf("%d of %d startup syncs succeeded", succeeded, len(s.startup))
+	if len(pending) > 0 {
+		return message, errors.New(strings.Join(pending, ", "))
+	}
+	return message, nil
+}
+
+// Stop stops scheduling further runs, drops runs waiting for their jitter and ends the watches;
+// running syncs are not interrupted
+func (s *Scheduler) Stop() {
+	s.stop()
+	s.cron.Stop()
+}
+
+// runner returns the job starting a sync of the definition after a random delay of up to the
+// jitter. Runs are skipped while the job queue is paused, the next run follows the schedule. While
+// the previous run of the definition is queued or running, the concurrency policy of the
+// definition decides.
+func (s *Scheduler) runner(def models.SyncDefinition, jitter time.Duration) cron.FuncJob {
+	policy := concurrencyPolicy(def)
+	return func() {
+		if jitter > 0 {
+			delay := rand.N(jitter)
+			log.Printf("[SCHEDULER] Delaying scheduled run of %s by %v", def.Name, delay.Round(time.Second))
+			timer := time.NewTimer(delay)
+			select {
+			case <-s.ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+		}
+		if s.syncService.IsPaused() {
+			log.Printf("[SCHEDULER] WARNING: Skipping scheduled run of %s, the job queue is paused", def.Name)
+			return
+		}
+
+		if previous, ok := s.pendingRun(def.Name); ok {
+			switch policy {
+			case models.ConcurrencyForbid:
+				log.Printf("[SCHEDULER] WARNING: Skipping scheduled run of %s, its previous run %s is %s", def.Name, previous.ID, previous.Status)
+				return
+			case models.ConcurrencyReplace:
+				log.Printf("[SCHEDULER] Replacing %s run %s of %s", previous.Status, previous.ID, def.Name)
+				s.syncService.CancelJob(previous.ID, "replaced by a newer scheduled run of "+def.Name)
+			}
+		}
+
+		log.Printf("[SCHEDULER] Starting scheduled run of %s", def.Name)
+		req := def.Request()
+		req.Initiator = "schedule " + def.Name
+		job, _, err := s.syncService.QueueSync(req)
+		if err != nil {
+			log.Printf("[SCHEDULER] ERROR: Failed to start scheduled run of %s: %v", def.Name, err)
+			return
+		}
+		s.mutex.Lock()
+		s.lastJobs[def.Name] = job.ID
+		s.mutex.Unlock()
+		log.Printf("[SCHEDULER] Scheduled run of %s submitted as %s job %s", def.Name, job.Status, job.ID)
+	}
+}
+
+// pendingRun returns the last scheduled run of the definition while it is queued or running
+func (s *Scheduler) pendingRun(name string) (*models.SyncJob, bool) {
+	s.mutex.Lock()
+	id, ok := s.lastJobs[name]
+	s.mutex.Unlock()
+	if !ok {
+		return nil, false
+	}
+	job, ok := s.syncService.GetJob(id)
+	if !ok || job.Finished() {
+		return nil, false
+	}
+	return job, true
+}
+
+// concurrencyPolicy returns the concurrency policy of the definition. Unlike for CronJobs the
+// default is Forbid, as the runs of all definitions share one worker.
+func concurrencyPolicy(def models.SyncDefinition) string {
+	if def.ConcurrencyPolicy == "" {
+		return models.ConcurrencyForbid
+	}
+	return def.ConcurrencyPolicy
+}
+
+func timeZoneName(timeZone string) string {
+	if timeZone == "" {
+		return "server"
+	}
+	return timeZone
+}
@@ -0,0 +1,90 @@
+package scheduler
+
+import (
+	"log"
+	"time"
+
+	"github.com/sharedvolume/volume-syncer/internal/models"
+	"github.com/sharedvolume/volume-syncer/internal/proxy"
+)
+
+// watch is a sync definition whose source is polled for changes
+type watch struct {
+	def      models.SyncDefinition
+	interval time.Duration
+}
+
+// watch polls the revision of the source of the definition every interval until the scheduler
+// stops, and runs the definition when the revision differs from the one its last successful watch
+// run detected. Failed runs are repeated on the next poll.
+func (s *Scheduler) watch(w watch) {
+	synced := s.syncService.LastRevision(w.def.Target.Path)
+	var runID, runRevision string // last watch run, until it finished
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		if runID != "" {
+			if job, ok := s.syncService.GetJob(runID); !ok || job.Finished() {
+				if ok && job.Status == models.JobStatusSucceeded {
+					synced = runRevision
+				}
+				runID = ""
+			}
+		}
+		if runID == "" {
+			runID, runRevision = s.poll(w.def, synced)
+		}
+
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll submits a run of the definition when the revision of its source differs from the synced
+// one, returning the job and the revision it syncs. Polls are skipped while the job queue is
+// paused or another run of the definition is queued or running.
+func (s *Scheduler) poll(def models.SyncDefinition, synced string) (string, string) {
+	if s.syncService.IsPaused() {
+		return "", ""
+	}
+	if previous, ok := s.pendingRun(def.Name); ok {
+		log.Printf("[SCHEDULER] Skipping poll of %s, its run %s is %s", def.Name, previous.ID, previous.Status)
+		return "", ""
+	}
+
+	ctx := s.ctx
+	if def.Proxy != nil {
+		ctx = proxy.NewContext(ctx, def.Proxy)
+	}
+	revision, err := s.syncService.SourceRevision(ctx, def.Source)
+	if err != nil {
+		log.Printf("[SCHEDULER] WARNING: Failed to poll the source of %s: %v", def.Name, err)
+		return "", ""
+	}
+	if revision == synced {
+		return "", ""
+	}
+	if synced == "" {
+		log.Printf("[SCHEDULER] Source of %s at %s, no earlier watch run synced it", def.Name, revision)
+	} else {
+		log.Printf("[SCHEDULER] Source of %s changed from %s to %s", def.Name, synced, revision)
+	}
+
+	req := def.Request()
+	req.Initiator = "watch " + def.Name
+	req.DetectedRevision = revision
+	job, _, err := s.syncService.QueueSync(req)
+	if err != nil {
+		log.Printf("[SCHEDULER] ERROR: Failed to start watch run of %s: %v", def.Name, err)
+		return "", ""
+	}
+	s.mutex.Lock()
+	s.lastJobs[def.Name] = job.ID
+	s.mutex.Unlock()
+	log.Printf("[SCHEDULER] Watch run of %s submitted as %s job %s", def.Name, job.Status, job.ID)
+	return job.ID, revision
+}
@@ -0,0 +1,183 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"golang.org/x/oauth2/google"
+)
+
+// secretManagerTimeout bounds the retrieval of a secret from a secret manager
+const secretManagerTimeout = 30 * time.Second
+
+// gcpSecretManagerURL is the endpoint of the GCP Secret Manager API, followed by the resource name
+// of the secret version
+const gcpSecretManagerURL = "https://secretmanager.googleapis.com/v1/"
+
+// maxSecretResponse limits the responses read from GCP Secret Manager, whose secrets hold up to
+// 64 KiB
+const maxSecretResponse = 1 << 20
+
+var (
+	// awsSecretRegex matches the names and ARNs of AWS Secrets Manager secrets
+	awsSecretRegex = regexp.MustCompile(`^(arn:aws[a-z-]*:secretsmanager:[a-z0-9-]+:[0-9]{12}:secret:)?[A-Za-z0-9/_+=.@-]{1,512}$`)
+	// gcpSecretRegex matches the resource names of GCP Secret Manager secrets and secret versions
+	gcpSecretRegex = regexp.MustCompile(`^projects/[a-z0-9-]+/secrets/[A-Za-z0-9_-]{1,255}(/versions/(latest|[0-9]+))?$`)
+)
+
+// cloudClients reads secrets from the secret managers with the cloud identity of the server, e.g.
+// the IAM role of the service account of the pod (IRSA or EKS Pod Identity) or its GKE Workload
+// Identity. The clients are created on first use, so that servers not referencing secret manager
+// secrets need no cloud configuration.
+type cloudClients struct {
+	awsOnce sync.Once
+	aws     *secretsmanager.Client
+	awsErr  error
+
+	gcpOnce sync.Once
+	gcp     *http.Client
+	gcpErr  error
+}
+
+// readSecretManager returns the value of the secret manager secret of the reference, or of the
+// field of the JSON object the secret holds
+func (r *Resolver) readSecretManager(ctx context.Context, ref Reference) (string, error) {
+	name, secret := ref.AWSSecret, ref.AWSSecret
+	if ref.GCPSecret != "" {
+		name = ref.GCPSecret
+		secret, _, _ = strings.Cut(ref.GCPSecret, "/versions/")
+	}
+	if !matchAny(r.secretNames, secret) {
+		return "", fmt.Errorf("secret %q is not allowed, see SYNC_SECRET_MANAGER_NAMES", secret)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, secretManagerTimeout)
+	defer cancel()
+	var value string
+	var err error
+	if ref.AWSSecret != "" {
+		value, err = r.cloud.readAWS(ctx, ref.AWSSecret)
+	} else {
+		value, err = r.cloud.readGCP(ctx, ref.GCPSecret)
+	}
+	if err != nil {
+		return "", err
+	}
+	if ref.Key == "" {
+		return value, nil
+	}
+	return secretField(value, ref.Key, name)
+}
+
+// readAWS returns the current version of an AWS Secrets Manager secret. Secrets referenced by ARN
+// are read from the region of the ARN, others from the configured region.
+func (c *cloudClients) readAWS(ctx context.Context, id string) (string, error) {
+	c.awsOnce.Do(func() {
+		cfg, err := config.LoadDefaultConfig(context.Background())
+		if err != nil {
+			c.awsErr = fmt.Errorf("failed to load AWS configuration: %w", err)
+			return
+		}
+		c.aws = secretsmanager.NewFromConfig(cfg)
+	})
+	if c.awsErr != nil {
+		return "", c.awsErr
+	}
+
+	var options []func(*secretsmanager.Options)
+	if parsed, err := arn.Parse(id); err == nil {
+		options = append(options, func(o *secretsmanager.Options) { o.Region = parsed.Region })
+	}
+	output, err := c.aws.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{SecretId: aws.String(id)}, options...)
+	if err != nil {
+		return "", fmt.Errorf("failed to read AWS secret %s: %w", id, err)
+	}
+	if output.SecretString != nil {
+		return *output.SecretString, nil
+	}
+	return string(output.SecretBinary), nil
+}
+
+// readGCP returns the data of a GCP Secret Manager secret version, checking its checksum
+func (c *cloudClients) readGCP(ctx context.Context, name string) (string, error) {
+	c.gcpOnce.Do(func() {
+		c.gcp, c.gcpErr = google.DefaultClient(context.Background(), "https://www.googleapis.com/auth/cloud-platform")
+		if c.gcpErr != nil {
+			c.gcpErr = fmt.Errorf("failed to find GCP credentials: %w", c.gcpErr)
+		}
+	})
+	if c.gcpErr != nil {
+		return "", c.gcpErr
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, gcpSecretManagerURL+name+":access", nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := c.gcp.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to read GCP secret %s: %w", name, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSecretResponse))
+	if err != nil {
+		return "", fmt.Errorf("failed to read GCP secret %s: %w", name, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		json.Unmarshal(body, &failure)
+		return "", fmt.Errorf("failed to read GCP secret %s: %s %s", name, resp.Status, failure.Error.Message)
+	}
+
+	var version struct {
+		Payload struct {
+			Data       []byte `json:"data"`       // base64 encoded
+			DataCrc32c string `json:"dataCrc32c"` // int64 encoded as a string
+		} `json:"payload"`
+	}
+	if err := json.Unmarshal(body, &version); err != nil {
+		return "", fmt.Errorf("failed to decode GCP secret %s: %w", name, err)
+	}
+	if version.Payload.DataCrc32c != "" {
+		checksum, err := strconv.ParseInt(version.Payload.DataCrc32c, 10, 64)
+		if err != nil || uint32(checksum) != crc32.Checksum(version.Payload.Data, crc32.MakeTable(crc32.Castagnoli)) {
+			return "", fmt.Errorf("GCP secret %s failed its checksum", name)
+		}
+	}
+	return string(version.Payload.Data), nil
+}
+
+// secretField returns the string field of the JSON object held by a secret, e.g. the password of
+// an AWS secret with {"username": "...", "password": "..."}
+func secretField(value, key, name string) (string, error) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(value), &fields); err != nil {
+		return "", fmt.Errorf("secret %s does not hold a JSON object, as %s requires", name, secretKey)
+	}
+	field, ok := fields[key]
+	if !ok {
+		return "", fmt.Errorf("secret %s has no field %q", name, key)
+	}
+	text, ok := field.(string)
+	if !ok {
+		return "", fmt.Errorf("field %q of secret %s is not a string", key, name)
+	}
+	return text, nil
+}
@@ -0,0 +1,271 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Keys of the objects referencing a secret in place of a value of the source details
+const (
+	fromEnv               = "fromEnv"
+	fromFile              = "fromFile"
+	fromAWSSecretsManager = "fromAWSSecretsManager"
+	fromGCPSecretManager  = "fromGCPSecretManager"
+	secretKey             = "key" // field of a JSON secret of a secret manager
+)
+
+// Placeholder stands in for referenced values while the details are validated, so that the
+// validation sees the field as set
+const Placeholder = "secret-reference"
+
+// Reference points to the value of a field of the source details: an environment variable of
+// the server, a file, e.g. of a mounted Kubernetes Secret, or a secret of AWS Secrets Manager or
+// GCP Secret Manager
+type Reference struct {
+	Env       string
+	File      string
+	AWSSecret string // name or ARN of the secret
+	GCPSecret string // resource name of the secret version, projects/*/secrets/*/versions/*
+	Key       string // field of the JSON object held by the secret of a secret manager, the whole secret when empty
+}
+
+// parseReference reports whether the object is a reference and checks its form
+func parseReference(object map[string]interface{}) (Reference, bool, error) {
+	env, hasEnv := object[fromEnv]
+	file, hasFile := object[fromFile]
+	awsSecret, hasAWS := object[fromAWSSecretsManager]
+	gcpSecret, hasGCP := object[fromGCPSecretManager]
+	key, hasKey := object[secretKey]
+	if !hasEnv && !hasFile && !hasAWS && !hasGCP {
+		return Reference{}, false, nil
+	}
+	fields := 1
+	if hasKey && (hasAWS || hasGCP) {
+		fields++
+	}
+	if len(object) != fields {
+		return Reference{}, true, fmt.Errorf("a secret reference takes exactly one of %s, %s, %s and %s, and %s only with a secret manager",
+			fromEnv, fromFile, fromAWSSecretsManager, fromGCPSecretManager, secretKey)
+	}
+	var ref Reference
+	if hasKey {
+		name, ok := key.(string)
+		if !ok || name == "" {
+			return ref, true, fmt.Errorf("%s must be the name of a field of the secret", secretKey)
+		}
+		ref.Key = name
+	}
+	switch {
+	case hasEnv:
+		name, ok := env.(string)
+		if !ok || name == "" || strings.ContainsAny(name, "=\x00") {
+			return ref, true, fmt.Errorf("%s must be the name of an environment variable", fromEnv)
+		}
+		ref.Env = name
+	case hasFile:
+		name, ok := file.(string)
+		if !ok || !filepath.IsAbs(name) {
+			return ref, true, fmt.Errorf("%s must be an absolute path", fromFile)
+		}
+		ref.File = filepath.Clean(name)
+	case hasAWS:
+		name, ok := awsSecret.(string)
+		if !ok || !awsSecretRegex.MatchString(name) {
+			return ref, true, fmt.Errorf("%s must be the name or ARN of a secret", fromAWSSecretsManager)
+		}
+		ref.AWSSecret = name
+	default:
+		name, ok := gcpSecret.(string)
+		if !ok || !gcpSecretRegex.MatchString(name) {
+			return ref, true, fmt.Errorf("%s must be a secret or secret version, e.g. projects/my-project/secrets/git-token/versions/latest", fromGCPSecretManager)
+		}
+		if !strings.Contains(name, "/versions/") {
+			name += "/versions/latest"
+		}
+		ref.GCPSecret = name
+	}
+	return ref, true, nil
+}
+
+// Substitute returns a copy of the details in which every reference is replaced by the value
+// resolve returns for it. The details are left untouched, so that stored requests keep their
+// references.
+func Substitute(details interface{}, resolve func(Reference) (string, error)) (interface{}, error) {
+	return substitute(details, "", resolve)
+}
+
+func substitute(value interface{}, field string, resolve func(Reference) (string, error)) (interface{}, error) {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		ref, ok, err := parseReference(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", field, err)
+		}
+		if ok {
+			resolved, err := resolve(ref)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", field, err)
+			}
+			return resolved, nil
+		}
+		copied := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			if copied[key], err = substitute(item, joinField(field, key), resolve); err != nil {
+				return nil, err
+			}
+		}
+		return copied, nil
+	case []interface{}:
+		copied := make([]interface{}, len(value))
+		for i, item := range value {
+			var err error
+			if copied[i], err = substitute(item, fmt.Sprintf("%s[%d]", field, i), resolve); err != nil {
+				return nil, err
+			}
+		}
+		return copied, nil
+	default:
+		return value, nil
+	}
+}
+
+func joinField(field, key string) string {
+	if field == "" {
+		return key
+	}
+	return field + "." + key
+}
+
+// Placeholders returns a copy of the details with placeholders for the references, checking
+// only their form. Validation runs on the copy; the values are resolved when the sync runs.
+func Placeholders(details interface{}) (interface{}, error) {
+	return Substitute(details, func(Reference) (string, error) { return Placeholder, nil })
+}
+
+// Resolver reads the referenced values of source details. Only environment variables matching
+// the allowed names, files below the allowed roots and secret manager secrets matching the
+// allowed names can be referenced, so that requests cannot read other configuration or files of
+// the server, or other secrets its cloud identity has access to.
+type Resolver struct {
+	envNames    []string // path.Match patterns of the environment variables
+	fileRoots   []string
+	secretNames []string // path.Match patterns of the secret manager secrets
+	cloud       cloudClients
+}
+
+// NewResolver parses the comma-separated patterns of the environment variables (e.g. "GIT_*"),
+// directories and secret manager secrets that references may read. The secrets directory is
+// always a file root. Relative roots are ignored.
+func NewResolver(envNames, fileRoots, secretNames, secretsDir string) *Resolver {
+	r := &Resolver{
+		envNames:    parsePatterns(envNames, "environment variable"),
+		secretNames: parsePatterns(secretNames, "secret manager"),
+	}
+	for _, root := range append(strings.Split(fileRoots, ","), secretsDir) {
+		if root = strings.TrimSpace(root); root == "" {
+			continue
+		}
+		if !filepath.IsAbs(root) {
+			log.Printf("[SECRETS] WARNING: Ignoring secret file root %q, it must be an absolute path", root)
+			continue
+		}
+		r.fileRoots = append(r.fileRoots, filepath.Clean(root))
+	}
+	return r
+}
+
+// parsePatterns parses comma-separated path.Match patterns, ignoring invalid ones
+func parsePatterns(patterns, kind string) []string {
+	var valid []string
+	for _, pattern := range strings.Split(patterns, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern == "" {
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			log.Printf("[SECRETS] WARNING: Ignoring invalid %s pattern %q", kind, pattern)
+			continue
+		}
+		valid = append(valid, pattern)
+	}
+	return valid
+}
+
+// Resolve returns a copy of the details with the referenced values
+func (r *Resolver) Resolve(ctx context.Context, details interface{}) (interface{}, error) {
+	return Substitute(details, func(ref Reference) (string, error) {
+		return r.read(ctx, ref)
+	})
+}
+
+// read returns the value of the reference. A trailing line break of files is dropped, as most
+// tools writing secret files end them with one.
+func (r *Resolver) read(ctx context.Context, ref Reference) (string, error) {
+	if ref.AWSSecret != "" || ref.GCPSecret != "" {
+		return r.readSecretManager(ctx, ref)
+	}
+	if ref.Env != "" {
+		if !matchAny(r.envNames, ref.Env) {
+			return "", fmt.Errorf("environment variable %q is not allowed, see SYNC_SECRET_ENV_NAMES", ref.Env)
+		}
+		value, ok := os.LookupEnv(ref.Env)
+		if !ok {
+			return "", fmt.Errorf("environment variable %q is not set", ref.Env)
+		}
+		return value, nil
+	}
+
+	notAllowed := fmt.Errorf("secret file %s is not below an allowed root, see SYNC_SECRET_FILE_ROOTS", ref.File)
+	if !r.fileAllowed(ref.File, false) {
+		return "", notAllowed
+	}
+	// Symbolic links are resolved, so that references cannot escape the roots through them.
+	// Mounted Kubernetes Secrets link their keys to a directory below the mount.
+	resolved, err := filepath.EvalSymlinks(ref.File)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("secret file %s not found", ref.File)
+		}
+		return "", fmt.Errorf("failed to read secret file %s: %w", ref.File, err)
+	}
+	if !r.fileAllowed(resolved, true) {
+		return "", notAllowed
+	}
+	data, err := os.ReadFile(resolved)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret file %s: %w", ref.File, err)
+	}
+	value := strings.TrimSuffix(string(data), "\n")
+	return strings.TrimSuffix(value, "\r"), nil
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// fileAllowed reports whether the file lies below one of the roots, whose symbolic links are
+// resolved when the file's are
+func (r *Resolver) fileAllowed(file string, resolved bool) bool {
+	for _, root := range r.fileRoots {
+		if resolved {
+			var err error
+			if root, err = filepath.EvalSymlinks(root); err != nil {
+				continue
+			}
+		}
+		if rel, err := filepath.Rel(root, file); err == nil && rel != "." && filepath.IsLocal(rel) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,42 @@
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// nameRegex matches valid secret names, the same characters Kubernetes allows for Secret keys
+var nameRegex = regexp.MustCompile(`^[-._a-zA-Z0-9]+$`)
+
+// Store resolves secret references to the files of a secrets directory, e.g. a mounted
+// Kubernetes Secret volume where every key is a file
+type Store struct {
+	dir string
+}
+
+// NewStore creates a store for the given directory; with an empty directory every lookup fails
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Read returns the contents of the named secret
+func (s *Store) Read(name string) ([]byte, error) {
+	if s.dir == "" {
+		return nil, errors.New("secret references require SYNC_SECRETS_DIR to be configured")
+	}
+	if !nameRegex.MatchString(name) || name == "." || name == ".." {
+		return nil, fmt.Errorf("invalid secret name %q", name)
+	}
+
+	data, err := os.ReadFile(filepath.Join(s.dir, name))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("secret %q not found", name)
+		}
+		return nil, fmt.Errorf("failed to read secret %q: %w", name, err)
+	}
+	return data, nil
+}
@@ -18,23 +18,62 @@ package server
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sharedvolume/volume-syncer/internal/audit"
+	"github.com/sharedvolume/volume-syncer/internal/bus"
+	"github.com/sharedvolume/volume-syncer/internal/bwlimit"
+	"github.com/sharedvolume/volume-syncer/internal/cache"
 	"github.com/sharedvolume/volume-syncer/internal/config"
+	"github.com/sharedvolume/volume-syncer/internal/controller"
+	"github.com/sharedvolume/volume-syncer/internal/credentials"
+	"github.com/sharedvolume/volume-syncer/internal/definitions"
+	"github.com/sharedvolume/volume-syncer/internal/digest"
+	"github.com/sharedvolume/volume-syncer/internal/drift"
+	"github.com/sharedvolume/volume-syncer/internal/events"
 	"github.com/sharedvolume/volume-syncer/internal/handler"
+	"github.com/sharedvolume/volume-syncer/internal/hooks"
+	"github.com/sharedvolume/volume-syncer/internal/jobstate"
+	"github.com/sharedvolume/volume-syncer/internal/kube"
+	"github.com/sharedvolume/volume-syncer/internal/models"
+	"github.com/sharedvolume/volume-syncer/internal/netdial"
+	"github.com/sharedvolume/volume-syncer/internal/notify"
+	"github.com/sharedvolume/volume-syncer/internal/profiles"
+	"github.com/sharedvolume/volume-syncer/internal/proxy"
+	"github.com/sharedvolume/volume-syncer/internal/purge"
+	"github.com/sharedvolume/volume-syncer/internal/quota"
+	"github.com/sharedvolume/volume-syncer/internal/readiness"
+	"github.com/sharedvolume/volume-syncer/internal/retention"
+	"github.com/sharedvolume/volume-syncer/internal/scheduler"
 	"github.com/sharedvolume/volume-syncer/internal/service"
+	"github.com/sharedvolume/volume-syncer/internal/syncer"
+	"github.com/sharedvolume/volume-syncer/internal/syncer/git"
+	"github.com/sharedvolume/volume-syncer/internal/transport"
+	"github.com/sharedvolume/volume-syncer/internal/trigger"
+	"github.com/sharedvolume/volume-syncer/internal/workdirs"
 )
 
 // Server represents the HTTP server
 type Server struct {
 	httpServer *http.Server
 	cfg        *config.Config
+	scheduler  *scheduler.Scheduler
+	controller *controller.Controller
+	trigger    *trigger.Trigger
+	drift      *drift.Watcher
+	service    *service.SyncService
+	ctx        context.Context // context of the controller, cancelled on shutdown
+	stop       context.CancelFunc
 }
 
 // NewServer creates a new HTTP server
-func NewServer(cfg *config.Config) *Server {
+func NewServer(cfg *config.Config) (*Server, error) {
 	log.Printf("[SERVER] Initializing HTTP server")
 	log.Printf("[SERVER] Port: %s", cfg.Server.Port)
 	log.Printf("[SERVER] Read timeout: %v", cfg.Server.ReadTimeout)
@@ -45,25 +84,159 @@ func NewServer(cfg *config.Config) *Server {
 	gin.SetMode(gin.ReleaseMode)
 	log.Printf("[SERVER] Gin mode set to: %s", gin.Mode())
 
+	// Open the job state directory
+	state, err := jobstate.New(cfg.Sync.StateDir)
+	if err != nil {
+		log.Printf("[SERVER] ERROR: Failed to open job state directory: %v", err)
+		return nil, err
+	}
+
 	// Create services
 	log.Printf("[SERVER] Creating sync service...")
-	syncService := service.NewSyncService(cfg)
+	syncService, err := NewSyncService(cfg, state)
+	if err != nil {
+		return nil, err
+	}
+	if err := syncService.Recover(); err != nil {
+		log.Printf("[SERVER] ERROR: Failed to load persisted jobs: %v", err)
+		return nil, err
+	}
 	log.Printf("[SERVER] Sync service created")
 
+	// Load sync definitions
+	registry := definitions.NewRegistry()
+	if cfg.Sync.DefinitionsFile != "" {
+		loaded, err := definitions.LoadFile(cfg.Sync.DefinitionsFile)
+		if err != nil {
+			log.Printf("[SERVER] ERROR: Failed to load sync definitions: %v", err)
+			return nil, err
+		}
+		registry = loaded
+	}
+	definitionScheduler, err := scheduler.New(registry, syncService, cfg.Sync.StartupDefinitions)
+	if err != nil {
+		log.Printf("[SERVER] ERROR: Failed to schedule sync definitions: %v", err)
+		return nil, err
+	}
+
+	// Resync on SIGUSR1 and on changes of the trigger file
+	var triggerDefinition *models.SyncDefinition
+	if name := cfg.Sync.TriggerDefinition; name != "" {
+		def, ok := registry.Get(name)
+		if !ok {
+			err := fmt.Errorf("trigger definition %s is not defined", name)
+			log.Printf("[SERVER] ERROR: Invalid SYNC_TRIGGER_DEFINITION: %v", err)
+			return nil, err
+		}
+		triggerDefinition = &def
+	}
+	resyncTrigger := trigger.New(syncService, cfg.Sync.TriggerFile, triggerDefinition)
+
+	// Watch the targets of the definitions setting drift for out-of-band changes
+	driftWatcher, err := drift.New(syncService, registry.All())
+	if err != nil {
+		log.Printf("[SERVER] ERROR: Failed to watch targets for drift: %v", err)
+		return nil, err
+	}
+
+	// Reconcile SyncSource resources in controller mode
+	var syncController *controller.Controller
+	if cfg.Sync.Controller {
+		client, err := kube.InCluster()
+		if err != nil {
+			log.Printf("[SERVER] ERROR: Controller mode requires in-cluster credentials: %v", err)
+			return nil, err
+		}
+		namespace := cfg.Sync.ControllerNamespace
+		if namespace == "" {
+			namespace = client.Namespace()
+		}
+		syncController = controller.New(client, namespace, syncService)
+	}
+
+	// Check the dependencies of the syncs for readiness probes
+	checker, err := readiness.New(cfg.Sync.GitImplementation != git.ImplementationGoGit, cfg.Sync.StagingDir, cfg.Sync.TargetRoots, state, definitionScheduler.StartupStatus)
+	if err != nil {
+		log.Printf("[SERVER] ERROR: Invalid SYNC_TARGET_ROOTS: %v", err)
+		return nil, err
+	}
+
 	// Create handlers
 	log.Printf("[SERVER] Creating sync handler...")
 	syncHandler := handler.NewSyncHandler(syncService)
+	webhookHandler := handler.NewWebhookHandler(registry, syncService)
 	log.Printf("[SERVER] Sync handler created")
 
+	// Bound the request bodies; webhook payloads have their own limit
+	maxBodySize, err := quota.ParseSize(cfg.Server.MaxBodySize)
+	if err != nil {
+		log.Printf("[SERVER] ERROR: Invalid SYNC_MAX_REQUEST_BODY: %v", err)
+		return nil, err
+	}
+	limitBody := handler.LimitBody(maxBodySize)
+
 	// Create router
 	log.Printf("[SERVER] Creating Gin router...")
 	router := gin.Default()
+	router.Use(handler.Audit(syncService.AuditLog()))
 
 	// Setup routes
 	log.Printf("[SERVER] Setting up routes...")
 	router.GET("/health", syncHandler.HealthCheck)
-	router.POST("/api/1.0/sync", syncHandler.Sync)
-	log.Printf("[SERVER] Routes configured: GET /health, POST /api/1.0/sync")
+	router.GET("/livez", syncHandler.Liveness)
+	router.GET("/readyz", handler.Readiness(checker))
+	router.POST("/api/1.0/sync", limitBody, syncHandler.Sync)
+	router.GET("/api/1.0/sync", syncHandler.ListJobs)
+	router.POST("/api/1.0/sync/batch", limitBody, syncHandler.SyncBatch)
+	router.GET("/api/1.0/sync/jobs", syncHandler.ListJobs)
+	router.GET("/api/1.0/sync/jobs/:id", syncHandler.GetJob)
+	router.GET("/api/1.0/sync/jobs/:id/progress", syncHandler.StreamProgress)
+	router.GET("/api/1.0/stats", syncHandler.Stats)
+	router.POST("/api/1.0/validate", limitBody, syncHandler.Validate)
+	router.POST("/api/1.0/browse", limitBody, syncHandler.Browse)
+	router.GET("/api/1.0/probe", syncHandler.ProbeURL)
+	router.POST("/api/1.0/probe", limitBody, syncHandler.Probe)
+	router.POST("/api/1.0/pause", syncHandler.Pause)
+	router.POST("/api/1.0/resume", syncHandler.Resume)
+	adminToken := handler.RequireToken(cfg.Server.AdminToken)
+	router.GET("/api/1.0/profiles", adminToken, syncHandler.ListProfiles)
+	router.POST("/api/1.0/profiles", adminToken, limitBody, syncHandler.CreateProfile)
+	router.GET("/api/1.0/profiles/:name", adminToken, syncHandler.GetProfile)
+	router.PUT("/api/1.0/profiles/:name", adminToken, limitBody, syncHandler.PutProfile)
+	router.DELETE("/api/1.0/profiles/:name", adminToken, syncHandler.DeleteProfile)
+	router.POST("/api/1.0/profiles/:name/run", limitBody, syncHandler.RunProfile)
+	router.GET("/api/1.0/credentials", adminToken, syncHandler.ListCredentials)
+	router.POST("/api/1.0/credentials", adminToken, limitBody, syncHandler.CreateCredential)
+	router.GET("/api/1.0/credentials/:id", adminToken, syncHandler.GetCredential)
+	router.PUT("/api/1.0/credentials/:id", adminToken, limitBody, syncHandler.PutCredential)
+	router.DELETE("/api/1.0/credentials/:id", adminToken, syncHandler.DeleteCredential)
+	router.GET("/api/1.0/audit", adminToken, syncHandler.ListAudit)
+	router.POST("/api/1.0/hooks/git", webhookHandler.GitWebhook)
+	router.POST("/api/2.0/sync", limitBody, syncHandler.SyncV2)
+	router.POST("/api/2.0/sync/batch", limitBody, syncHandler.SyncBatchV2)
+	router.POST("/api/2.0/validate", limitBody, syncHandler.ValidateV2)
+	router.POST("/api/2.0/browse", limitBody, syncHandler.BrowseV2)
+	router.POST("/api/2.0/probe", limitBody, syncHandler.ProbeV2)
+	router.GET("/api/1.0/status", syncHandler.TargetStatus)
+	router.GET("/api/1.0/target", syncHandler.InspectTarget)
+	router.DELETE("/api/1.0/target", adminToken, syncHandler.PurgeTarget)
+	router.POST("/api/1.0/target/rollback", limitBody, syncHandler.RollbackTarget)
+	router.GET("/api/1.0/target/usage", syncHandler.TargetUsage)
+	router.GET("/api/1.0/target/drift", syncHandler.TargetDrift)
+	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	router.GET("/openapi.json", handler.OpenAPI)
+	routes := "GET /health, GET /livez, GET /readyz, POST /api/1.0/sync, GET /api/1.0/sync, POST /api/1.0/sync/batch, GET /api/1.0/sync/jobs, GET /api/1.0/sync/jobs/:id, GET /api/1.0/sync/jobs/:id/progress, GET /api/1.0/stats, POST /api/1.0/validate, POST /api/1.0/browse, GET /api/1.0/probe, POST /api/1.0/probe, POST /api/1.0/pause, POST /api/1.0/resume, GET /api/1.0/profiles, POST /api/1.0/profiles, GET /api/1.0/profiles/:name, PUT /api/1.0/profiles/:name, DELETE /api/1.0/profiles/:name, POST /api/1.0/profiles/:name/run, GET /api/1.0/credentials, POST /api/1.0/credentials, GET /api/1.0/credentials/:id, PUT /api/1.0/credentials/:id, DELETE /api/1.0/credentials/:id, GET /api/1.0/audit, POST /api/1.0/hooks/git, POST /api/2.0/sync, POST /api/2.0/sync/batch, POST /api/2.0/validate, POST /api/2.0/browse, POST /api/2.0/probe, GET /api/1.0/status, GET /api/1.0/target, DELETE /api/1.0/target, POST /api/1.0/target/rollback, GET /api/1.0/target/usage, GET /api/1.0/target/drift, GET /metrics, GET /openapi.json"
+	if cfg.Server.SwaggerUI {
+		router.GET("/docs", handler.SwaggerUI)
+		routes += ", GET /docs"
+	}
+	if cfg.Server.Debug {
+		router.GET("/debug/pprof/*profile", adminToken, handler.Pprof)
+		router.POST("/debug/pprof/*profile", adminToken, handler.Pprof) // symbol lookups
+		router.GET("/debug/vars", adminToken, handler.ExpVar)
+		routes += ", GET /debug/pprof/, GET /debug/vars"
+	}
+	log.Printf("[SERVER] Routes configured: %s", routes)
 
 	// Create HTTP server
 	log.Printf("[SERVER] Creating HTTP server...")
@@ -76,14 +249,226 @@ func NewServer(cfg *config.Config) *Server {
 	}
 
 	log.Printf("[SERVER] HTTP server created successfully")
+	ctx, stop := context.WithCancel(context.Background())
 	return &Server{
 		httpServer: httpServer,
 		cfg:        cfg,
+		scheduler:  definitionScheduler,
+		controller: syncController,
+		trigger:    resyncTrigger,
+		drift:      driftWatcher,
+		service:    syncService,
+		ctx:        ctx,
+		stop:       stop,
+	}, nil
+}
+
+// NewSyncService creates the sync service with the hook commands, quotas and download cache of
+// the configuration. Jobs are persisted in the state store, if any.
+func NewSyncService(cfg *config.Config, state *jobstate.Store) (*service.SyncService, error) {
+	// Configure the transport of HTTP sources, hooks and notifications
+	transports, err := transport.New(cfg.HTTP)
+	if err != nil {
+		log.Printf("[SERVER] ERROR: Invalid SYNC_HTTP_* settings: %v", err)
+		return nil, err
+	}
+	log.Printf("[SERVER] HTTP transport: %s", transports)
+	defaultProxy, err := proxy.New(syncer.DefaultProxy(&cfg.Sync))
+	if err != nil {
+		log.Printf("[SERVER] ERROR: Invalid SYNC_PROXY settings: %v", err)
+		return nil, err
+	}
+	if defaultProxy != nil {
+		log.Printf("[SERVER] Default proxy of the sources: %s", defaultProxy)
+	}
+	resolver, err := netdial.New(cfg.Sync.DNSServer, cfg.Sync.IPFamily)
+	if err != nil {
+		log.Printf("[SERVER] ERROR: Invalid SYNC_DNS_SERVER or SYNC_IP_FAMILY: %v", err)
+		return nil, err
+	}
+	netdial.SetDefault(resolver)
+	if resolver != nil {
+		log.Printf("[SERVER] Name resolution of the sources: %s", resolver)
+	}
+
+	// Load hook commands; HTTP hooks are available without a hooks file
+	hookRunner := hooks.NewRunner(nil, cfg.Sync.HookTimeout, transports)
+	if cfg.Sync.HooksFile != "" {
+		loaded, err := hooks.LoadFile(cfg.Sync.HooksFile, cfg.Sync.HookTimeout, transports)
+		if err != nil {
+			log.Printf("[SERVER] ERROR: Failed to load hook commands: %v", err)
+			return nil, err
+		}
+		hookRunner = loaded
+	}
+
+	// Parse target quotas
+	quotas, err := quota.Parse(cfg.Sync.TargetQuotas)
+	if err != nil {
+		log.Printf("[SERVER] ERROR: Invalid SYNC_TARGET_QUOTAS: %v", err)
+		return nil, err
+	}
+	for _, limit := range quotas.Limits() {
+		log.Printf("[SERVER] Quota of %s: %d bytes", limit.Path, limit.Bytes)
+	}
+
+	// Open the download cache
+	var cacheSize int64
+	if cfg.Sync.DownloadCacheSize != "" {
+		if cacheSize, err = quota.ParseSize(cfg.Sync.DownloadCacheSize); err != nil {
+			log.Printf("[SERVER] ERROR: Invalid SYNC_DOWNLOAD_CACHE_MAX_SIZE: %v", err)
+			return nil, err
+		}
+	}
+	downloads, err := cache.New(cfg.Sync.DownloadCacheDir, cacheSize, cfg.Sync.DownloadCacheLinks)
+	if err != nil {
+		log.Printf("[SERVER] ERROR: Failed to open download cache: %v", err)
+		return nil, err
+	}
+	if downloads != nil {
+		log.Printf("[SERVER] Download cache: %s (max size %d bytes, hard links %t)", cfg.Sync.DownloadCacheDir, cacheSize, cfg.Sync.DownloadCacheLinks)
 	}
+
+	// Check the extraction limits
+	if cfg.Sync.ExtractMaxSize != "" {
+		if _, err := quota.ParseSize(cfg.Sync.ExtractMaxSize); err != nil {
+			log.Printf("[SERVER] ERROR: Invalid SYNC_EXTRACT_MAX_SIZE: %v", err)
+			return nil, err
+		}
+	}
+	if cfg.Sync.ExtractMaxEntries < 0 {
+		err := fmt.Errorf("%d must not be negative", cfg.Sync.ExtractMaxEntries)
+		log.Printf("[SERVER] ERROR: Invalid SYNC_EXTRACT_MAX_ENTRIES: %v", err)
+		return nil, err
+	}
+
+	if cfg.Sync.HashAlgorithm != "" {
+		if _, ok := digest.New(cfg.Sync.HashAlgorithm); !ok {
+			err := fmt.Errorf("unsupported hash algorithm %q, must be one of %s", cfg.Sync.HashAlgorithm, digest.Names)
+			log.Printf("[SERVER] ERROR: Invalid SYNC_HASH_ALGORITHM: %v", err)
+			return nil, err
+		}
+		log.Printf("[SERVER] Manifests record %s digests of the target files", strings.ToLower(cfg.Sync.HashAlgorithm))
+	}
+
+	// Limit the bandwidth shared by all downloads
+	bandwidthLimit, err := bwlimit.Parse(cfg.Sync.BandwidthLimit)
+	if err != nil {
+		log.Printf("[SERVER] ERROR: Invalid SYNC_BWLIMIT: %v", err)
+		return nil, err
+	}
+	if bandwidthLimit > 0 {
+		log.Printf("[SERVER] Bandwidth limit: %d bytes per second", bandwidthLimit)
+	}
+
+	// Register the source types of exec plugins
+	plugins, err := syncer.LoadPlugins(cfg.Sync.PluginsDir)
+	if err != nil {
+		log.Printf("[SERVER] ERROR: Failed to load plugins: %v", err)
+		return nil, err
+	}
+	if len(plugins) > 0 {
+		log.Printf("[SERVER] Plugin source types: %v", plugins)
+	}
+
+	// Allow purging targets below the configured roots
+	purger, err := purge.New(cfg.Sync.PurgeRoots)
+	if err != nil {
+		log.Printf("[SERVER] ERROR: Invalid SYNC_PURGE_ROOTS: %v", err)
+		return nil, err
+	}
+	if purger != nil {
+		log.Printf("[SERVER] Purge roots: %v", purger.Roots())
+	}
+
+	// Record job events when running in a cluster
+	var recorder *events.Recorder
+	if cfg.Sync.KubernetesEvents {
+		client, err := kube.InCluster()
+		if err == nil {
+			recorder = events.New(client)
+		} else if !errors.Is(err, kube.ErrNotInCluster) {
+			log.Printf("[SERVER] WARNING: Kubernetes events disabled: %v", err)
+		}
+	}
+
+	// Load the sync profiles
+	profileStore, err := profiles.New(cfg.Sync.ProfilesDir)
+	if err != nil {
+		log.Printf("[SERVER] ERROR: Failed to open sync profiles: %v", err)
+		return nil, err
+	}
+
+	// Open the encrypted credential store
+	credentialStore, err := credentials.Open(context.Background(), cfg.Sync.CredentialStoreDir, cfg.Sync.CredentialKeyFile, cfg.Sync.CredentialKMSKey)
+	if err != nil {
+		log.Printf("[SERVER] ERROR: Failed to open credential store: %v", err)
+		return nil, err
+	}
+	if credentialStore == nil {
+		log.Printf("[SERVER] Credential store disabled, no sealing key configured")
+	}
+
+	// Check the directories of staging directories and key material
+	dirs, err := workdirs.New(cfg.Sync.StagingDir, cfg.Sync.CredentialsDir)
+	if err != nil {
+		log.Printf("[SERVER] ERROR: Invalid SYNC_STAGING_DIR or SYNC_CREDENTIALS_DIR: %v", err)
+		return nil, err
+	}
+
+	// Open the sinks of the audit log
+	auditLog, err := audit.New(cfg.Sync.AuditLog, cfg.Sync.AuditRecent, cfg.Sync.AuditHTTPToken)
+	if err != nil {
+		log.Printf("[SERVER] ERROR: Invalid SYNC_AUDIT_LOG: %v", err)
+		return nil, err
+	}
+	if sinks := auditLog.Sinks(); len(sinks) > 0 {
+		log.Printf("[SERVER] Audit log: %s", strings.Join(sinks, ", "))
+	}
+
+	// Load the sinks notified of finished jobs
+	var notifier *notify.Notifier
+	if cfg.Sync.NotificationsFile != "" {
+		if notifier, err = notify.LoadFile(cfg.Sync.NotificationsFile, transports); err != nil {
+			log.Printf("[SERVER] ERROR: Failed to load notifications: %v", err)
+			return nil, err
+		}
+		log.Printf("[SERVER] Notification sinks: %s", strings.Join(notifier.Sinks(), ", "))
+	}
+
+	// Connect to the message bus the job events are published to
+	publisher, err := bus.New(cfg.Sync.EventBus, cfg.Sync.EventTopic, cfg.Sync.EventInterval)
+	if err != nil {
+		log.Printf("[SERVER] ERROR: Invalid SYNC_EVENT_BUS: %v", err)
+		return nil, err
+	}
+	if publisher != nil {
+		log.Printf("[SERVER] Event bus: %s (progress every %v)", publisher, cfg.Sync.EventInterval)
+	}
+
+	// Parse the retention of the job history and the job logs
+	var jobRetention retention.Policy
+	if jobRetention.Jobs, err = retention.Parse(cfg.Sync.JobRetentionAge, cfg.Sync.JobRetentionCount, cfg.Sync.JobRetentionSize); err != nil {
+		log.Printf("[SERVER] ERROR: Invalid SYNC_JOB_RETENTION_* settings: %v", err)
+		return nil, err
+	}
+	if jobRetention.Logs, err = retention.Parse(cfg.Sync.LogRetentionAge, cfg.Sync.LogRetentionCount, cfg.Sync.LogRetentionSize); err != nil {
+		log.Printf("[SERVER] ERROR: Invalid SYNC_JOB_LOG_RETENTION_* settings: %v", err)
+		return nil, err
+	}
+	log.Printf("[SERVER] Job retention: %s; job log retention: %s", jobRetention.Jobs, jobRetention.Logs)
+
+	return service.NewSyncService(cfg, hookRunner, quotas, downloads, bwlimit.New(bandwidthLimit, nil), state, recorder, purger, profileStore, credentialStore, dirs, auditLog, notifier, publisher, transports, jobRetention), nil
 }
 
-// Start starts the HTTP server
+// Start starts the HTTP server and the scheduled sync definitions
 func (s *Server) Start() error {
+	s.scheduler.Start()
+	if s.controller != nil {
+		go s.controller.Run(s.ctx)
+	}
+	go s.trigger.Run(s.ctx)
+	go s.drift.Run(s.ctx)
 	log.Printf("[SERVER] Starting HTTP server on port %s...", s.cfg.Server.Port)
 	log.Printf("[SERVER] Server address: %s", s.httpServer.Addr)
 	err := s.httpServer.ListenAndServe()
@@ -96,7 +481,10 @@ func (s *Server) Start() error {
 // Shutdown gracefully shuts down the server
 func (s *Server) Shutdown(ctx context.Context) error {
 	log.Printf("[SERVER] Initiating graceful shutdown...")
+	s.scheduler.Stop()
+	s.stop()
 	err := s.httpServer.Shutdown(ctx)
+	s.service.Close()
 	if err != nil {
 		log.Printf("[SERVER] ERROR: Failed to shutdown gracefully: %v", err)
 	} else {
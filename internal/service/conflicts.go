@@ -0,0 +1,92 @@
+/*
+Copyright 2025 SharedVolume
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sharedvolume/volume-syncer/internal/inventory"
+	"github.com/sharedvolume/volume-syncer/internal/models"
+	"github.com/sharedvolume/volume-syncer/internal/utils"
+	"github.com/sharedvolume/volume-syncer/pkg/errors"
+)
+
+// conflictsSuffix is the suffix of the directory next to the target that conflicting files are
+// backed up into, by job
+const conflictsSuffix = ".conflicts"
+
+// conflictExamples is the number of conflicting paths named by the error of failed jobs
+const conflictExamples = 3
+
+// checkConflicts compares the files of the target, listed before the sync, with the manifest of
+// its last successful sync. Files created or modified since then fail the job or are backed up,
+// as the conflict policy of the request decides. Targets never synced have no conflicts.
+func checkConflicts(job *models.SyncJob, policy, contentPath string, files inventory.Inventory) (*models.ConflictReport, error) {
+	if policy == "" || policy == models.ConflictOverwrite || files == nil {
+		return nil, nil
+	}
+	manifest, err := inventory.LoadManifest(job.TargetPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to check target for conflicts: %w", err)
+	}
+	if manifest.Algorithm != "" {
+		if err := files.ReadDigests(contentPath, manifest.Algorithm, manifest.Files); err != nil {
+			return nil, fmt.Errorf("failed to check target for conflicts: %w", err)
+		}
+	}
+
+	differences := inventory.Diff(manifest.Files, files)
+	conflicts := append(differences.Added, differences.Modified...)
+	sort.Strings(conflicts)
+	if len(conflicts) == 0 {
+		return nil, nil
+	}
+	log.Printf("[SYNC SERVICE] %d files of target %s changed since its last successful sync", len(conflicts), job.TargetPath)
+
+	if policy == models.ConflictFail {
+		examples := conflicts
+		if len(examples) > conflictExamples {
+			examples = examples[:conflictExamples]
+		}
+		return nil, errors.NewConflictError(fmt.Sprintf("%d files of the target changed since its last successful sync, e.g. %s",
+			len(conflicts), strings.Join(examples, ", ")))
+	}
+
+	backupPath := filepath.Join(filepath.Clean(job.TargetPath)+conflictsSuffix, job.ID)
+	for _, rel := range conflicts {
+		dst := filepath.Join(backupPath, rel)
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return nil, fmt.Errorf("failed to back up conflicting files: %w", err)
+		}
+		if err := utils.CopyFile(filepath.Join(contentPath, rel), dst); err != nil {
+			return nil, fmt.Errorf("failed to back up conflicting file %s: %w", rel, err)
+		}
+	}
+	log.Printf("[SYNC SERVICE] Backed up %d conflicting files to %s", len(conflicts), backupPath)
+
+	report := &models.ConflictReport{BackupPath: backupPath}
+	report.Files, report.Truncated = truncatePaths(conflicts, false)
+	return report, nil
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2025 SharedVolume
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"time"
+
+	"github.com/sharedvolume/volume-syncer/internal/models"
+)
+
+// estimateHistory is the number of recently finished jobs whose average duration estimates jobs
+// without an earlier run of their own
+const estimateHistory = 20
+
+// BusyFor estimates how long the running job and the queued jobs take to finish, which is when a
+// refused sync request is likely to be accepted. It reports false when no estimate is possible,
+// e.g. without finished jobs or while the queue is paused.
+func (s *SyncService) BusyFor() (time.Duration, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.waitFor(len(s.queue), time.Now())
+}
+
+// queueEstimate returns the position of the queued job, 1 for the job dispatched next, and the
+// estimated time it starts, nil when unknown. The caller holds the mutex.
+func (s *SyncService) queueEstimate(job *models.SyncJob, now time.Time) (int, *time.Time) {
+	for i, queued := range s.queue {
+		if queued.job != job {
+			continue
+		}
+		wait, ok := s.waitFor(i, now)
+		if !ok {
+			return i + 1, nil
+		}
+		startAt := now.Add(wait).UTC().Truncate(time.Second)
+		return i + 1, &startAt
+	}
+	return 0, nil
+}
+
+// waitFor estimates the time until the running job and the first n queued jobs finished. The
+// caller holds the mutex.
+func (s *SyncService) waitFor(n int, now time.Time) (time.Duration, bool) {
+	if s.paused {
+		return 0, false
+	}
+	var wait time.Duration
+	if s.running != nil {
+		remaining, ok := s.remaining(now)
+		if !ok {
+			return 0, false
+		}
+		wait += remaining
+	}
+	for _, queued := range s.queue[:n] {
+		duration, ok := s.jobDuration(queued.job)
+		if !ok {
+			return 0, false
+		}
+		wait += duration
+	}
+	return wait, true
+}
+
+// remaining estimates the time the running job still takes: from the share of the bytes it
+// transferred so far, or from its estimated duration. The caller holds the mutex.
+func (s *SyncService) remaining(now time.Time) (time.Duration, bool) {
+	elapsed := now.Sub(s.running.StartedAt)
+	if s.progress != nil {
+		current := s.progress.Snapshot()
+		if current.BytesTotal > 0 && current.BytesDone > 0 && current.BytesDone <= current.BytesTotal {
+			return time.Duration(float64(elapsed) * float64(current.BytesTotal-current.BytesDone) / float64(current.BytesDone)), true
+		}
+	}
+	duration, ok := s.jobDuration(s.running)
+	if !ok {
+		return 0, false
+	}
+	// A job running longer than expected is assumed to finish soon
+	return max(duration-elapsed, 0), true
+}
+
+// jobDuration estimates how long the job runs: as long as the last finished job of the same
+// source type into the same target, or as the recently finished jobs on average. The caller
+// holds the mutex.
+func (s *SyncService) jobDuration(job *models.SyncJob) (time.Duration, bool) {
+	var total time.Duration
+	count := 0
+	for i := len(s.jobs) - 1; i >= 0; i-- {
+		finished := s.jobs[i]
+		if finished.FinishedAt == nil || finished.StartedAt.IsZero() {
+			continue
+		}
+		duration := finished.FinishedAt.Sub(finished.StartedAt)
+		if finished.SourceType == job.SourceType && finished.TargetPath == job.TargetPath {
+			return duration, true
+		}
+		if count < estimateHistory {
+			total += duration
+			count++
+		}
+	}
+	if count == 0 {
+		return 0, false
+	}
+	return total / time.Duration(count), true
+}
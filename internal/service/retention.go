@@ -0,0 +1,86 @@
+/*
+Copyright 2025 SharedVolume
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"log"
+	"slices"
+	"time"
+
+	"github.com/sharedvolume/volume-syncer/internal/models"
+	"github.com/sharedvolume/volume-syncer/internal/retention"
+)
+
+// pruneInterval is how often jobs are checked against the max ages of the retention
+const pruneInterval = time.Minute
+
+// pruneByAge prunes the jobs periodically, so that jobs pass their max age without new jobs
+// being recorded
+func (s *SyncService) pruneByAge() {
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stopPruning:
+			return
+		case <-ticker.C:
+			s.mutex.Lock()
+			s.pruneJobs()
+			s.mutex.Unlock()
+		}
+	}
+}
+
+// pruneJobs removes the finished jobs beyond the job retention from the history and the job
+// records, and the logs of the finished jobs beyond the log retention. Queued and running jobs
+// are kept and not counted. The caller holds the mutex.
+func (s *SyncService) pruneJobs() {
+	now := time.Now()
+	records := s.retention.Jobs.Counter(retention.RecordSize, now)
+	logs := s.retention.Logs.Counter(retention.LogSize, now)
+
+	pruned, trimmed := 0, 0
+	kept := make([]*models.SyncJob, 0, len(s.jobs))
+	for i := len(s.jobs) - 1; i >= 0; i-- {
+		job := s.jobs[i]
+		if !job.Finished() {
+			kept = append(kept, job)
+			continue
+		}
+		if !records.Keep(job) {
+			if err := s.state.Remove(job.ID); err != nil {
+				log.Printf("[SYNC SERVICE] WARNING: %v", err)
+			}
+			pruned++
+			continue
+		}
+		if !logs.Keep(job) && !job.LogsPruned {
+			retention.DropLogs(job)
+			s.saveJob(job, nil)
+			trimmed++
+		}
+		kept = append(kept, job)
+	}
+	if pruned == 0 && trimmed == 0 {
+		return
+	}
+
+	// The jobs were collected most recent first
+	slices.Reverse(kept)
+	s.jobs = kept
+	log.Printf("[SYNC SERVICE] Pruned %d finished jobs and the logs of %d jobs, %d jobs kept", pruned, trimmed, len(kept))
+}
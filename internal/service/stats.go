@@ -0,0 +1,142 @@
+/*
+Copyright 2025 SharedVolume
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"sort"
+	"time"
+
+	"github.com/sharedvolume/volume-syncer/internal/models"
+)
+
+// StatsFilter selects the finished jobs of the statistics
+type StatsFilter struct {
+	SourceType string    // only jobs of the source type, all when empty
+	Since      time.Time // only jobs finished since, all when zero
+}
+
+// statsTotals accumulates the jobs of one group of the statistics
+type statsTotals struct {
+	stats    models.SyncStats
+	duration time.Duration
+	timed    int // jobs with a start time, whose duration is known
+	bytes    int64
+	measured int // successful jobs with a result, whose transferred bytes are known
+}
+
+// Stats aggregates the finished jobs in the history by source type and by source type and target
+// path
+func (s *SyncService) Stats(filter StatsFilter) *models.StatsResponse {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	response := &models.StatsResponse{Status: "found", Sources: []models.SyncStats{}, Targets: []models.SyncStats{}}
+	bySource := map[string]*statsTotals{}
+	byTarget := map[[2]string]*statsTotals{}
+	for _, job := range s.jobs {
+		if !job.Finished() || job.FinishedAt == nil {
+			continue
+		}
+		if filter.SourceType != "" && job.SourceType != filter.SourceType {
+			continue
+		}
+		if !filter.Since.IsZero() && job.FinishedAt.Before(filter.Since) {
+			continue
+		}
+
+		response.Jobs++
+		if started := job.StartedAt; !started.IsZero() && (response.Since == nil || started.Before(*response.Since)) {
+			response.Since = &started
+		}
+		source, ok := bySource[job.SourceType]
+		if !ok {
+			source = &statsTotals{stats: models.SyncStats{SourceType: job.SourceType}}
+			bySource[job.SourceType] = source
+		}
+		key := [2]string{job.SourceType, job.TargetPath}
+		target, ok := byTarget[key]
+		if !ok {
+			target = &statsTotals{stats: models.SyncStats{SourceType: job.SourceType, TargetPath: job.TargetPath}}
+			byTarget[key] = target
+		}
+		source.add(job)
+		target.add(job)
+	}
+	if !filter.Since.IsZero() {
+		since := filter.Since.UTC()
+		response.Since = &since
+	}
+
+	for _, totals := range bySource {
+		response.Sources = append(response.Sources, totals.summary())
+	}
+	for _, totals := range byTarget {
+		response.Targets = append(response.Targets, totals.summary())
+	}
+	sort.Slice(response.Sources, func(i, j int) bool {
+		return response.Sources[i].SourceType < response.Sources[j].SourceType
+	})
+	sort.Slice(response.Targets, func(i, j int) bool {
+		a, b := response.Targets[i], response.Targets[j]
+		if a.SourceType != b.SourceType {
+			return a.SourceType < b.SourceType
+		}
+		return a.TargetPath < b.TargetPath
+	})
+	return response
+}
+
+// add counts the finished job
+func (t *statsTotals) add(job *models.SyncJob) {
+	t.stats.Jobs++
+	if !job.StartedAt.IsZero() {
+		t.duration += job.FinishedAt.Sub(job.StartedAt)
+		t.timed++
+	}
+	finishedAt := job.FinishedAt.UTC()
+	switch job.Status {
+	case models.JobStatusSucceeded:
+		t.stats.Succeeded++
+		if t.stats.LastSuccessAt == nil || finishedAt.After(*t.stats.LastSuccessAt) {
+			t.stats.LastSuccessAt = &finishedAt
+		}
+		if job.Result != nil {
+			t.bytes += job.Result.BytesTransferred
+			t.measured++
+		}
+	case models.JobStatusFailed:
+		t.stats.Failed++
+		if t.stats.LastFailure == nil || finishedAt.After(t.stats.LastFailure.FinishedAt) {
+			t.stats.LastFailure = &models.JobFailure{JobID: job.ID, Error: job.Error, ErrorCode: job.ErrorCode, FinishedAt: finishedAt}
+		}
+	}
+}
+
+// summary returns the statistics with their averages
+func (t *statsTotals) summary() models.SyncStats {
+	stats := t.stats
+	if stats.Jobs > 0 {
+		stats.SuccessRate = float64(stats.Succeeded) / float64(stats.Jobs)
+	}
+	if t.timed > 0 {
+		stats.AvgDurationMs = (t.duration / time.Duration(t.timed)).Milliseconds()
+	}
+	if t.measured > 0 {
+		stats.AvgBytes = t.bytes / int64(t.measured)
+	}
+	return stats
+}
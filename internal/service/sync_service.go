@@ -17,29 +17,202 @@ limitations under the License.
 package service
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/sharedvolume/volume-syncer/internal/audit"
+	"github.com/sharedvolume/volume-syncer/internal/bus"
+	"github.com/sharedvolume/volume-syncer/internal/bwlimit"
+	"github.com/sharedvolume/volume-syncer/internal/cache"
 	"github.com/sharedvolume/volume-syncer/internal/config"
+	"github.com/sharedvolume/volume-syncer/internal/credentials"
+	"github.com/sharedvolume/volume-syncer/internal/events"
+	"github.com/sharedvolume/volume-syncer/internal/hooks"
+	"github.com/sharedvolume/volume-syncer/internal/inventory"
+	"github.com/sharedvolume/volume-syncer/internal/jobstate"
+	"github.com/sharedvolume/volume-syncer/internal/metrics"
 	"github.com/sharedvolume/volume-syncer/internal/models"
+	"github.com/sharedvolume/volume-syncer/internal/notify"
+	"github.com/sharedvolume/volume-syncer/internal/probe"
+	"github.com/sharedvolume/volume-syncer/internal/profiles"
+	"github.com/sharedvolume/volume-syncer/internal/progress"
+	"github.com/sharedvolume/volume-syncer/internal/proxy"
+	"github.com/sharedvolume/volume-syncer/internal/purge"
+	"github.com/sharedvolume/volume-syncer/internal/quota"
+	"github.com/sharedvolume/volume-syncer/internal/retention"
+	"github.com/sharedvolume/volume-syncer/internal/retry"
 	"github.com/sharedvolume/volume-syncer/internal/syncer"
+	"github.com/sharedvolume/volume-syncer/internal/transport"
+	"github.com/sharedvolume/volume-syncer/internal/validation"
+	"github.com/sharedvolume/volume-syncer/internal/verify"
+	"github.com/sharedvolume/volume-syncer/internal/versions"
+	"github.com/sharedvolume/volume-syncer/internal/workdirs"
 	"github.com/sharedvolume/volume-syncer/pkg/errors"
 )
 
+// maxDriftPaths bounds the paths of each kind reported by drift detection
+const maxDriftPaths = 1000
+
+// maxBrowseEntries bounds the entries of a source listing
+const maxBrowseEntries = 1000
+
+// preflightTimeout bounds the connection checks of a source before its job is created
+const preflightTimeout = 30 * time.Second
+
+// abandonGrace is the time a canceled syncer gets to return before its job is finished without it
+const abandonGrace = 30 * time.Second
+
+// ErrSyncInProgress is returned when a sync request cannot be started, queued or attached to a
+// pending job because another sync operation is running
+var ErrSyncInProgress = errors.NewValidationError("sync operation already in progress")
+
+// ErrNoLastSync is returned by resyncs before a sync was submitted
+var ErrNoLastSync = errors.NewValidationError("no sync was submitted since the server started")
+
+// PreflightError is returned when the connection checks of a source fail before the job of the
+// request is created. It wraps an error typed after the failed check.
+type PreflightError struct {
+	Source string               // source of the request whose checks failed, e.g. source or sources[1]
+	Checks []models.SourceCheck // checks that ran, ending with the failed one
+	Err    error
+}
+
+func (e *PreflightError) Error() string {
+	return fmt.Sprintf("preflight check of %s failed: %v", e.Source, e.Err)
+}
+
+func (e *PreflightError) Unwrap() error {
+	return e.Err
+}
+
+// InvalidRequestError is returned when fields of a sync request are invalid. It lists every
+// invalid field and is classified as a validation error.
+type InvalidRequestError struct {
+	Errors []models.FieldError
+}
+
+func (e *InvalidRequestError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i := range e.Errors {
+		messages[i] = e.Errors[i].Error()
+	}
+	return "invalid request: " + strings.Join(messages, "; ")
+}
+
+func (e *InvalidRequestError) Unwrap() error {
+	return errors.NewValidationError(e.Error())
+}
+
+// changeReporter is implemented by syncers that can tell whether a sync modified the target
+type changeReporter interface {
+	Changed() bool
+}
+
+// syncResultReporter is implemented by syncers that report source details, e.g. the synced revision
+type syncResultReporter interface {
+	Result() models.SyncResult
+}
+
+// sourceResultReporter is implemented by syncers that report results per source
+type sourceResultReporter interface {
+	Results() []models.SourceResult
+}
+
 // SyncService handles synchronization operations
 type SyncService struct {
 	factory        *syncer.SyncerFactory
+	hooks          *hooks.Runner
 	syncInProgress bool
 	mutex          sync.Mutex
+	jobs           []*models.SyncJob // oldest first
+	quotas         *quota.Quotas
+	retry          retry.Policy            // applied unless the request sets retry options
+	state          *jobstate.Store         // persisted job records, nil when disabled
+	retention      retention.Policy        // finished jobs and job logs kept in the history and the job records
+	stopPruning    chan struct{}           // stops pruning jobs by age, nil when no max age is set
+	resume         bool                    // resume jobs interrupted by a restart instead of failing them
+	events         *events.Recorder        // Kubernetes events of the jobs, nil when disabled
+	paused         bool                    // queued jobs are not dispatched until resumed
+	queue          []queuedJob             // jobs waiting to be dispatched, oldest first
+	coalesce       bool                    // attach requests to an identical queued or running job
+	preflight      bool                    // check the connection to the sources unless the request decides
+	running        *models.SyncJob         // job in progress, nil when idle
+	runningKey     string                  // request key of the job in progress
+	cancel         context.CancelCauseFunc // cancels the job in progress, nil when idle
+	purger         *purge.Purger           // empties targets below the allowed roots, nil when disabled
+	bandwidth      *bwlimit.Limiter        // bandwidth shared by all downloads, nil when unlimited
+	progress       *progress.Tracker       // progress of the job in progress, nil when idle
+	profiles       *profiles.Store         // named sync requests
+	credentials    *credentials.Store      // encrypted credentials referenced by sources, nil when disabled
+	maxRuntime     time.Duration           // wall-clock limit of jobs unless the request sets one, 0 when unlimited
+	maxListItems   int                     // items allowed in each list of a request, 0 when unlimited
+	audit          *audit.Log              // audit log of the API calls and syncs, nil when disabled
+	queueBusy      bool                    // queue submitted requests behind a sync in progress instead of refusing them
+	notifier       *notify.Notifier        // notification sinks of finished jobs, nil when disabled
+	bus            *bus.Publisher          // publishes the lifecycle events of jobs to a message bus, nil when disabled
+	hashAlgorithm  string                  // algorithm of the file digests recorded in manifests, none when empty
+	lastRequest    *models.SyncRequest     // request of the last submitted job, repeated by resyncs
+	drifted        map[string]models.DriftedTarget
+}
+
+// queuedJob is a job waiting for the queue to be resumed or for the running job to finish
+type queuedJob struct {
+	job    *models.SyncJob
+	req    *models.SyncRequest
+	syncer syncer.Syncer
+	key    string
 }
 
 // NewSyncService creates a new sync service
-func NewSyncService(cfg *config.Config) *SyncService {
-	return &SyncService{
-		factory:        syncer.NewSyncerFactory(cfg.Sync.DefaultTimeout),
+func NewSyncService(cfg *config.Config, hookRunner *hooks.Runner, quotas *quota.Quotas, downloads *cache.Cache, bandwidth *bwlimit.Limiter, state *jobstate.Store, recorder *events.Recorder, purger *purge.Purger, profileStore *profiles.Store, credentialStore *credentials.Store, dirs *workdirs.Dirs, auditLog *audit.Log, notifier *notify.Notifier, publisher *bus.Publisher, transports *transport.Factory, jobRetention retention.Policy) *SyncService {
+	s := &SyncService{
+		factory: syncer.NewSyncerFactory(&cfg.Sync, quotas, downloads, dirs, credentialStore, transports),
+		hooks:   hookRunner,
+		quotas:  quotas,
+		retry: retry.Policy{
+			Attempts:   cfg.Sync.RetryAttempts,
+			Backoff:    cfg.Sync.RetryBackoff,
+			MaxBackoff: cfg.Sync.RetryMaxBackoff,
+		},
+		state:          state,
+		retention:      jobRetention,
+		resume:         cfg.Sync.ResumeInterrupted,
+		events:         recorder,
+		coalesce:       cfg.Sync.CoalesceRequests,
+		preflight:      cfg.Sync.Preflight,
+		purger:         purger,
+		bandwidth:      bandwidth,
+		profiles:       profileStore,
+		credentials:    credentialStore,
+		maxRuntime:     cfg.Sync.MaxRuntime,
+		hashAlgorithm:  strings.ToLower(cfg.Sync.HashAlgorithm),
+		maxListItems:   cfg.Sync.MaxListItems,
+		audit:          auditLog,
+		queueBusy:      cfg.Sync.QueueWhenBusy,
+		notifier:       notifier,
+		bus:            publisher,
 		syncInProgress: false,
 	}
+	metrics.ObserveProgress(s.Progress)
+	if jobRetention.Jobs.MaxAge > 0 || jobRetention.Logs.MaxAge > 0 {
+		s.stopPruning = make(chan struct{})
+		go s.pruneByAge()
+	}
+	return s
 }
 
 // IsSyncInProgress returns true if a sync operation is currently in progress
@@ -49,94 +222,1685 @@ func (s *SyncService) IsSyncInProgress() bool {
 	return s.syncInProgress
 }
 
-// StartSync starts the synchronization process
-func (s *SyncService) StartSync(req *models.SyncRequest) error {
-	log.Printf("[SYNC SERVICE] Starting sync operation")
-	log.Printf("[SYNC SERVICE] Source type: %s", req.Source.Type)
-	log.Printf("[SYNC SERVICE] Target path: %s", req.Target.Path)
+// AcceptsSync returns true if a sync request may be started, queued or attached to a pending job
+// rather than refused because a sync operation is in progress
+func (s *SyncService) AcceptsSync() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return !s.syncInProgress || s.paused || len(s.queue) > 0 || s.coalesce || s.queueBusy
+}
 
+// IsPaused returns true if dispatching of sync jobs is paused
+func (s *SyncService) IsPaused() bool {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
+	return s.paused
+}
 
-	if s.syncInProgress {
-		log.Printf("[SYNC SERVICE] ERROR: Sync operation already in progress")
-		return errors.NewValidationError("sync operation already in progress")
+// QueueState returns whether dispatching is paused and the number of queued jobs
+func (s *SyncService) QueueState() (bool, int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.paused, len(s.queue)
+}
+
+// Pause stops dispatching sync jobs. Sync requests are queued until resumed, a running job is
+// not interrupted.
+func (s *SyncService) Pause() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if !s.paused {
+		log.Printf("[SYNC SERVICE] Pausing the job queue, %d jobs queued", len(s.queue))
+	}
+	s.paused = true
+}
+
+// Resume dispatches the queued jobs again, one at a time in the order they were requested
+func (s *SyncService) Resume() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.paused {
+		log.Printf("[SYNC SERVICE] Resuming the job queue, %d jobs queued", len(s.queue))
+	}
+	s.paused = false
+	s.dispatchNext()
+}
+
+// GetJob returns a snapshot of the job with the given ID
+func (s *SyncService) GetJob(id string) (*models.SyncJob, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, job := range s.jobs {
+		if job.ID == id {
+			jobCopy := s.snapshot(job)
+			return &jobCopy, true
+		}
+	}
+	return nil, false
+}
+
+// JobFilter selects the jobs of a job listing and the page returned
+type JobFilter struct {
+	Labels     map[string]string // labels the jobs carry, any value for an empty value
+	Status     string            // only jobs of the status, all when empty
+	SourceType string            // only jobs of the source type, all when empty
+	Offset     int               // matching jobs skipped, most recent first
+	Limit      int               // matching jobs returned at most, all when 0
+}
+
+// Matches reports whether the job passes the filter
+func (f JobFilter) Matches(job *models.SyncJob) bool {
+	if f.Status != "" && job.Status != f.Status {
+		return false
+	}
+	if f.SourceType != "" && job.SourceType != f.SourceType {
+		return false
+	}
+	for key, value := range f.Labels {
+		actual, ok := job.Labels[key]
+		if !ok || (value != "" && actual != value) {
+			return false
+		}
+	}
+	return true
+}
+
+// ListJobs returns snapshots of the known jobs matching the filter, most recent first, with the
+// number of matching jobs across all pages
+func (s *SyncService) ListJobs(filter JobFilter) ([]models.SyncJob, int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	jobs := []models.SyncJob{}
+	total := 0
+	for i := len(s.jobs) - 1; i >= 0; i-- {
+		if !filter.Matches(s.jobs[i]) {
+			continue
+		}
+		total++
+		if total > filter.Offset && (filter.Limit == 0 || len(jobs) < filter.Limit) {
+			jobs = append(jobs, s.snapshot(s.jobs[i]))
+		}
+	}
+	return jobs, total
+}
+
+// Progress returns the progress of the running job, false while idle
+func (s *SyncService) Progress() (models.SyncProgress, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.progress == nil {
+		return models.SyncProgress{}, false
+	}
+	return s.progress.Snapshot(), true
+}
+
+// snapshot copies the job, adding the progress of the running job and the queue position of
+// queued jobs. The caller holds the mutex.
+func (s *SyncService) snapshot(job *models.SyncJob) models.SyncJob {
+	jobCopy := *job
+	if job == s.running && s.progress != nil {
+		current := s.progress.Snapshot()
+		jobCopy.Progress = &current
+	}
+	if job.Status == models.JobStatusQueued {
+		jobCopy.QueuePosition, jobCopy.EstimatedStartAt = s.queueEstimate(job, time.Now())
+	}
+	return jobCopy
+}
+
+// StartSync starts the synchronization process and returns the created job. While the queue is
+// paused or holds jobs, or with SYNC_QUEUE_WHEN_BUSY while a sync is in progress, the job is
+// queued instead.
+func (s *SyncService) StartSync(req *models.SyncRequest) (*models.SyncJob, error) {
+	job, _, err := s.SubmitSync(req)
+	return job, err
+}
+
+// SubmitSync starts or queues a job like StartSync. With coalescing enabled, a request identical
+// to that of a queued or running job is attached to that job instead, which is reported by the
+// returned flag.
+func (s *SyncService) SubmitSync(req *models.SyncRequest) (*models.SyncJob, bool, error) {
+	return s.submit(req, s.queueBusy)
+}
+
+// QueueSync submits the request like SubmitSync, but queues the job behind a sync in progress
+// instead of failing with ErrSyncInProgress even when SYNC_QUEUE_WHEN_BUSY is disabled
+func (s *SyncService) QueueSync(req *models.SyncRequest) (*models.SyncJob, bool, error) {
+	return s.submit(req, true)
+}
+
+// BatchResult is the outcome of a request of a batch, as returned by SubmitSync
+type BatchResult struct {
+	Job      *models.SyncJob
+	Attached bool
+	Err      error
+}
+
+// SubmitBatch submits the requests of the batch in order with QueueSync, so that their jobs queue
+// behind each other, and returns the outcome of each. A failed request does not stop the others.
+func (s *SyncService) SubmitBatch(batch *models.BatchSyncRequest, initiator string) ([]BatchResult, error) {
+	if s.maxListItems > 0 && len(batch.Requests) > s.maxListItems {
+		return nil, &InvalidRequestError{Errors: []models.FieldError{{Field: "requests", Message: fmt.Sprintf("holds %d items, at most %d are allowed", len(batch.Requests), s.maxListItems)}}}
+	}
+	log.Printf("[SYNC SERVICE] Submitting a batch of %d sync requests", len(batch.Requests))
+	results := make([]BatchResult, len(batch.Requests))
+	for i := range batch.Requests {
+		req := batch.Requests[i].SyncRequest
+		req.Initiator = initiator
+		req.Callback = batch.Callback
+		if batch.Requests[i].Callback != nil {
+			req.Callback = batch.Requests[i].Callback
+		}
+		job, attached, err := s.QueueSync(&req)
+		if err != nil {
+			log.Printf("[SYNC SERVICE] ERROR: Request %d of the batch failed: %v", i, err)
+		}
+		results[i] = BatchResult{Job: job, Attached: attached, Err: err}
+	}
+	return results, nil
+}
+
+// withCallback returns a copy of the request whose post-sync hooks end with its callback
+func withCallback(req *models.SyncRequest) *models.SyncRequest {
+	reqCopy := *req
+	hooks := models.Hooks{}
+	if req.Hooks != nil {
+		hooks = *req.Hooks
+	}
+	hooks.Post = append(slices.Clone(hooks.Post), models.Hook{HTTP: req.Callback})
+	reqCopy.Hooks, reqCopy.Callback = &hooks, nil
+	return &reqCopy
+}
+
+func (s *SyncService) submit(req *models.SyncRequest, queueBusy bool) (*models.SyncJob, bool, error) {
+	log.Printf("[SYNC SERVICE] Starting sync operation")
+	if req.Profile != "" {
+		profile, ok := s.profiles.Get(req.Profile)
+		if !ok {
+			log.Printf("[SYNC SERVICE] ERROR: Sync profile %s not found", req.Profile)
+			return nil, false, errors.NewValidationError(fmt.Sprintf("sync profile %q not found", req.Profile))
+		}
+		log.Printf("[SYNC SERVICE] Running sync profile %s", profile.Name)
+		req = profile.Request(req)
 	}
+	if req.Callback != nil {
+		req = withCallback(req)
+	}
+	log.Printf("[SYNC SERVICE] Source type: %s", req.SourceType())
+	log.Printf("[SYNC SERVICE] Target path: %s", req.Target.Path)
 
-	// Validate request
+	// Validation only reads the request and the connection checks take a while, so both run
+	// before the lock is taken
 	log.Printf("[SYNC SERVICE] Validating sync request...")
 	if err := s.validateRequest(req); err != nil {
 		log.Printf("[SYNC SERVICE] ERROR: Request validation failed: %v", err)
-		return err
+		return nil, false, err
 	}
 	log.Printf("[SYNC SERVICE] Request validation passed")
+	if s.preflightEnabled(req) {
+		if err := s.runPreflight(req); err != nil {
+			log.Printf("[SYNC SERVICE] ERROR: %v", err)
+			return nil, false, err
+		}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key := requestKey(req)
+	if pending := s.pendingJob(key); pending != nil {
+		pending.Coalesced++
+		log.Printf("[SYNC SERVICE] Identical request attached to %s job %s", pending.Status, pending.ID)
+		jobSnapshot := s.snapshot(pending)
+		return &jobSnapshot, true, nil
+	}
+
+	queueing := s.paused || len(s.queue) > 0 || (queueBusy && s.syncInProgress)
+	if s.syncInProgress && !queueing {
+		log.Printf("[SYNC SERVICE] ERROR: Sync operation already in progress")
+		return nil, false, ErrSyncInProgress
+	}
+
+	syncer, err := s.createSyncer(req, false)
+	if err != nil {
+		return nil, false, err
+	}
+
+	job := &models.SyncJob{
+		ID:         newJobID(),
+		SourceType: req.SourceType(),
+		TargetPath: req.Target.Path,
+		Labels:     maps.Clone(req.Labels),
+
+		DetectedRevision: req.DetectedRevision,
+	}
+	s.addJob(job)
+	s.lastRequest = req
+	if queueing {
+		queuedAt := time.Now().UTC()
+		job.Status = models.JobStatusQueued
+		job.QueuedAt = &queuedAt
+		s.saveJob(job, req)
+		s.bus.Submitted(job, req)
+		s.queue = append(s.queue, queuedJob{job: job, req: req, syncer: syncer, key: key})
+		log.Printf("[SYNC SERVICE] Job %s queued, %d jobs waiting (paused: %t)", job.ID, len(s.queue), s.paused)
+		// An idle service that is not paused dispatches the job right away
+		s.dispatchNext()
+		jobSnapshot := s.snapshot(job)
+		return &jobSnapshot, false, nil
+	}
+
+	s.startJob(job, req, syncer)
+	jobSnapshot := *job
+	s.bus.Submitted(&jobSnapshot, req)
+	log.Printf("[SYNC SERVICE] Sync operation started successfully")
+	return &jobSnapshot, false, nil
+}
+
+// Resync submits the request of the last submitted job again, queued behind a sync in progress
+func (s *SyncService) Resync(initiator string) (*models.SyncJob, bool, error) {
+	s.mutex.Lock()
+	last := s.lastRequest
+	s.mutex.Unlock()
+	if last == nil {
+		return nil, false, ErrNoLastSync
+	}
+	req := *last
+	req.Initiator = initiator
+	return s.QueueSync(&req)
+}
+
+// SourceRevision returns the current revision of the source, which watches compare with the
+// revision of their last run
+func (s *SyncService) SourceRevision(ctx context.Context, source models.Source) (string, error) {
+	return s.factory.SourceRevision(ctx, source)
+}
+
+// LastRevision returns the source revision detected for the last successful job of the target
+// started by a watch, "" when there is none
+func (s *SyncService) LastRevision(targetPath string) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i := len(s.jobs) - 1; i >= 0; i-- {
+		job := s.jobs[i]
+		if job.TargetPath == targetPath && job.Status == models.JobStatusSucceeded && job.DetectedRevision != "" {
+			return job.DetectedRevision
+		}
+	}
+	return ""
+}
+
+// preflightEnabled reports whether the connection to the sources of the request is checked
+// before its job is created
+func (s *SyncService) preflightEnabled(req *models.SyncRequest) bool {
+	if req.Preflight != nil {
+		return *req.Preflight
+	}
+	return s.preflight
+}
+
+// runPreflight checks the connection to every source of the validated request and returns a
+// PreflightError for the first source whose checks fail
+func (s *SyncService) runPreflight(req *models.SyncRequest) error {
+	type namedSource struct {
+		name   string
+		source models.Source
+	}
+	sources := []namedSource{{"source", req.Source}}
+	if len(req.Sources) > 0 {
+		sources = sources[:0]
+		for i, sub := range req.Sources {
+			sources = append(sources, namedSource{fmt.Sprintf("sources[%d]", i), sub.Source})
+		}
+	}
+	for _, named := range sources {
+		log.Printf("[SYNC SERVICE] Running preflight checks of %s (%s)", named.name, named.source.Type)
+		ctx, cancel := context.WithTimeout(context.Background(), preflightTimeout)
+		if req.Proxy != nil {
+			ctx = proxy.NewContext(ctx, req.Proxy)
+		}
+		results := s.factory.CheckSource(ctx, named.source)
+		cancel()
+		for _, check := range results {
+			if check.Status == models.CheckFailed {
+				return &PreflightError{Source: named.name, Checks: results, Err: checkError(check)}
+			}
+		}
+	}
+	return nil
+}
+
+// checkError returns the error of a failed connection check, typed after the check so that it
+// is reported with the matching error code
+func checkError(check models.SourceCheck) error {
+	switch {
+	case retry.IsTransientOutput(check.Error):
+		return errors.NewNetworkError(check.Name+" check failed", fmt.Errorf("%s", check.Error))
+	case check.Name == "details":
+		return errors.NewValidationError(check.Error)
+	case check.Name == "credentials" || check.Name == "authentication":
+		return errors.NewAuthError(check.Error, nil)
+	}
+	return fmt.Errorf("%s check failed: %s", check.Name, check.Error)
+}
+
+// pendingJob returns the queued or running job of an identical request when coalescing is
+// enabled. Queued jobs are preferred, as they see changes made since the running job started.
+// The caller holds the mutex.
+func (s *SyncService) pendingJob(key string) *models.SyncJob {
+	if !s.coalesce || key == "" {
+		return nil
+	}
+	for _, queued := range s.queue {
+		if queued.key == key {
+			return queued.job
+		}
+	}
+	if s.running != nil && s.runningKey == key {
+		return s.running
+	}
+	return nil
+}
+
+// requestKey identifies identical requests by their encoding, or returns an empty key when the
+// request cannot be encoded
+func requestKey(req *models.SyncRequest) string {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// startJob runs the job in the background. The caller holds the mutex.
+func (s *SyncService) startJob(job *models.SyncJob, req *models.SyncRequest, jobSyncer syncer.Syncer) {
+	job.Status = models.JobStatusRunning
+	job.StartedAt = time.Now().UTC()
+	s.saveJob(job, req)
+
+	// The job can be canceled as soon as it is running
+	ctx, cancel := context.WithCancelCause(context.Background())
+	s.syncInProgress = true
+	s.running, s.runningKey = job, requestKey(req)
+	s.cancel = cancel
+	s.progress = progress.NewTracker()
+	metrics.SyncStarted()
+	log.Printf("[SYNC SERVICE] Starting background sync process for job %s...", job.ID)
+	go s.runJob(ctx, cancel, job, req, jobSyncer, false)
+}
+
+// dispatchNext starts the oldest queued job, unless the queue is paused or a sync is in
+// progress. The caller holds the mutex.
+func (s *SyncService) dispatchNext() {
+	if s.paused || s.syncInProgress || len(s.queue) == 0 {
+		return
+	}
+	next := s.queue[0]
+	s.queue = s.queue[1:]
+	log.Printf("[SYNC SERVICE] Dispatching queued job %s, %d jobs waiting", next.job.ID, len(s.queue))
+	s.startJob(next.job, next.req, next.syncer)
+}
+
+// CancelJob cancels the queued or running job and reports whether it was pending. A queued job
+// fails right away, a running job fails once its syncer returned; hooks of the job still run.
+func (s *SyncService) CancelJob(id, reason string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	cause := fmt.Errorf("job canceled, %s: %w", reason, context.Canceled)
+	for i, queued := range s.queue {
+		if queued.job.ID != id {
+			continue
+		}
+		s.queue = append(s.queue[:i], s.queue[i+1:]...)
+		finishedAt := time.Now().UTC()
+		queued.job.Status = models.JobStatusFailed
+		queued.job.FinishedAt = &finishedAt
+		queued.job.Error = cause.Error()
+		queued.job.ErrorCode, queued.job.Retryable = errors.CodeCanceled, false
+		s.saveJob(queued.job, queued.req)
+		log.Printf("[SYNC SERVICE] Queued job %s canceled, %s", id, reason)
+		return true
+	}
+	if s.running == nil || s.running.ID != id || s.cancel == nil {
+		return false
+	}
+	// Requests arriving from now on are not attached to the canceled job
+	s.runningKey = ""
+	s.cancel(cause)
+	log.Printf("[SYNC SERVICE] Running job %s canceled, %s", id, reason)
+	return true
+}
 
-	// Create syncer
-	log.Printf("[SYNC SERVICE] Creating syncer for type: %s", req.Source.Type)
-	syncer, err := s.factory.CreateSyncer(req.Source, req.Target.Path)
+// createSyncer creates the syncer of the validated request
+func (s *SyncService) createSyncer(req *models.SyncRequest, resume bool) (syncer.Syncer, error) {
+	log.Printf("[SYNC SERVICE] Creating syncer for type: %s", req.SourceType())
+	// Validated with the request; the sources of the request share its limit
+	bandwidthLimit, _ := bwlimit.Parse(req.BandwidthLimit)
+	opts := syncer.SourceOptions{
+		Filters:      req.Filters,
+		PostProcess:  req.PostProcess,
+		Ownership:    req.Ownership,
+		FileHandling: req.FileHandling,
+		Atomic:       req.Atomic,
+		Versions:     req.Versions,
+		Resume:       resume,
+		Bandwidth:    bwlimit.New(bandwidthLimit, s.bandwidth),
+		Proxy:        req.Proxy,
+	}
+	var created syncer.Syncer
+	var err error
+	if len(req.Sources) > 0 {
+		created, err = s.factory.CreateCompositeSyncer(req.Sources, req.Target.Path, req.Parallelism, opts)
+	} else {
+		created, err = s.factory.CreateSyncer(req.Source, req.Target.Path, opts)
+	}
 	if err != nil {
 		log.Printf("[SYNC SERVICE] ERROR: Failed to create syncer: %v", err)
-		return fmt.Errorf("failed to create syncer: %w", err)
+		return nil, fmt.Errorf("failed to create syncer: %w", err)
 	}
 	log.Printf("[SYNC SERVICE] Syncer created successfully")
+	return created, nil
+}
 
-	// Start sync process in background
-	s.syncInProgress = true
-	log.Printf("[SYNC SERVICE] Starting background sync process...")
-	go func() {
-		defer func() {
-			s.mutex.Lock()
-			s.syncInProgress = false
-			s.mutex.Unlock()
-			log.Printf("[SYNC SERVICE] Background sync process completed, status reset")
-		}()
+// runJob runs the hooks, the sync and the verification of a job and records the outcome. The
+// context is canceled through cancel, which the caller stored with the running job. A resumed
+// job continues from what its interrupted run left behind.
+func (s *SyncService) runJob(ctx context.Context, cancel context.CancelCauseFunc, job *models.SyncJob, req *models.SyncRequest, jobSyncer syncer.Syncer, resumed bool) {
+	s.events.SyncStarted(job, req.Owner)
+	s.audit.SyncStarted(job, req)
+	s.bus.Started(job, req)
+	defer cancel(nil)
+	if limit := s.runtimeLimit(req); limit > 0 {
+		var stop context.CancelFunc
+		ctx, stop = context.WithTimeoutCause(ctx, limit, fmt.Errorf("job exceeded its maximum runtime of %v: %w", limit, context.DeadlineExceeded))
+		defer stop()
+	}
+	s.mutex.Lock()
+	tracker := s.progress
+	s.mutex.Unlock()
+	stopCheckpoints := s.bus.Progress(func() models.SyncJob {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		return s.snapshot(job)
+	}, req)
+
+	// The options were validated with the request
+	policy, _ := retry.Resolve(s.retry, req.Retry)
+
+	var preHooks, postHooks []models.Hook
+	if req.Hooks != nil {
+		preHooks, postHooks = req.Hooks.Pre, req.Hooks.Post
+	}
+	info := hooks.JobInfo{JobID: job.ID, SourceType: job.SourceType, TargetPath: job.TargetPath}
+
+	info.Phase = models.HookPhasePre
+	if len(preHooks) > 0 {
+		tracker.Phase(progress.PhasePreHooks)
+	}
+	err := s.runHooks(job, preHooks, info)
+
+	// Versioned targets are verified and rolled back through their current link
+	contentPath := job.TargetPath
+	var store *versions.Store
+	if req.Versions != nil {
+		store = versions.New(job.TargetPath)
+		contentPath = store.CurrentPath()
+	}
 
+	var snapshot *verify.Snapshot
+	var rollback func() error
+	if err == nil && req.Verify != nil && req.Verify.Rollback {
+		if store != nil {
+			var previous string
+			previous, err = store.Current()
+			rollback = func() error { return restoreVersion(store, previous) }
+		} else {
+			// The snapshot taken before an interruption holds the contents prior to the job
+			if resumed {
+				snapshot = verify.OpenSnapshot(job.TargetPath, job.ID)
+			}
+			if snapshot == nil {
+				snapshot, err = verify.TakeSnapshot(job.TargetPath, job.ID)
+			}
+			if err == nil {
+				rollback = snapshot.Restore
+			}
+		}
+	}
+
+	var before inventory.Inventory
+	if err == nil {
+		if before, err = inventory.Scan(contentPath); err != nil {
+			log.Printf("[SYNC SERVICE] WARNING: Failed to list target files, the result will count every file as added: %v", err)
+			before, err = nil, nil
+		}
+	}
+
+	// The target of a resumed job holds what its interrupted run synced, which was checked then
+	var conflicts *models.ConflictReport
+	if err == nil && !resumed {
+		conflicts, err = checkConflicts(job, req.ConflictPolicy, contentPath, before)
+	}
+
+	changed := true
+	abandoned := false
+	var result *models.SyncResult
+	var after inventory.Inventory
+	if err == nil {
 		log.Printf("[SYNC SERVICE] Executing sync operation...")
-		if err := syncer.Sync(); err != nil {
-			log.Printf("[SYNC SERVICE] ERROR: Sync failed: %v", err)
+		syncStarted := time.Now()
+		abandoned, err = s.runLimited(ctx, job, jobSyncer, policy, tracker)
+		if err != nil && ctx.Err() != nil {
+			err = context.Cause(ctx)
+		}
+		if reporter, ok := jobSyncer.(changeReporter); ok && !abandoned {
+			changed = reporter.Changed()
+		}
+		if err == nil {
+			result, after = syncResult(jobSyncer, contentPath, before, time.Since(syncStarted))
+			result.Conflicts = conflicts
+		}
+	}
+
+	if err == nil && req.Verify != nil {
+		tracker.Phase(progress.PhaseVerifying)
+		err = s.verifyTarget(job, contentPath, req.Verify, rollback)
+	}
+	manifestRecorded := false
+	if err == nil && after != nil {
+		manifestRecorded = s.recordManifest(job.TargetPath, contentPath, job.ID, result.Revision, after)
+	}
+	if snapshot != nil {
+		snapshot.Remove()
+	}
+	if err != nil {
+		// Partial downloads and staging directories are only kept for jobs resumed after a restart
+		if cleanupErr := s.factory.RemoveInterrupted(job.TargetPath); cleanupErr != nil {
+			log.Printf("[SYNC SERVICE] WARNING: Failed to clean up after the failed sync: %v", cleanupErr)
+		}
+	}
+
+	if len(postHooks) > 0 {
+		tracker.Phase(progress.PhasePostHooks)
+		info.Phase = models.HookPhasePost
+		if err != nil {
+			info.Status = models.JobStatusFailed
+			info.Error = err.Error()
 		} else {
+			info.Status = models.JobStatusSucceeded
+			info.Changed = &changed
+			info.Result = result
+		}
+		if hookErr := s.runHooks(job, postHooks, info); hookErr != nil && err == nil {
+			err = hookErr
+		}
+	}
+
+	var usage *models.TargetUsage
+	if limit := s.quotas.For(job.TargetPath); limit != nil {
+		usage = targetUsage(limit)
+	}
+
+	stopCheckpoints()
+	s.mutex.Lock()
+	if manifestRecorded {
+		delete(s.drifted, job.TargetPath)
+	}
+	finishedAt := time.Now().UTC()
+	job.Usage = usage
+	job.FinishedAt = &finishedAt
+	job.Result = result
+	if reporter, ok := jobSyncer.(sourceResultReporter); ok && !abandoned {
+		job.Sources = reporter.Results()
+	}
+	outcome := metrics.ResultChanged
+	if err != nil {
+		log.Printf("[SYNC SERVICE] ERROR: Sync failed: %v", err)
+		job.Status = models.JobStatusFailed
+		job.Error = err.Error()
+		job.ErrorCode, job.Retryable = retry.Classify(err)
+		job.Result = nil
+		outcome = metrics.ResultFailed
+	} else {
+		job.Status = models.JobStatusSucceeded
+		job.Changed = &changed
+		if changed {
 			log.Printf("[SYNC SERVICE] Sync completed successfully")
+		} else {
+			log.Printf("[SYNC SERVICE] Sync completed successfully, target was already up to date")
+			outcome = metrics.ResultUnchanged
 		}
-	}()
+	}
+	s.saveJob(job, req)
+	finished := *job
+	s.pruneJobs()
+	s.audit.SyncFinished(&finished, req)
+	s.syncInProgress = false
+	s.running, s.runningKey = nil, ""
+	s.cancel = nil
+	s.progress = nil
+	s.dispatchNext()
+	s.mutex.Unlock()
 
-	log.Printf("[SYNC SERVICE] Sync operation started successfully")
-	return nil
+	s.events.SyncFinished(&finished, req.Owner)
+	s.notifier.SyncFinished(&finished, req)
+	s.bus.Completed(&finished, req)
+	metrics.SyncFinished(&finished, outcome)
+	log.Printf("[SYNC SERVICE] Background sync process completed for job %s, status reset", job.ID)
 }
 
-// validateRequest validates the sync request
-func (s *SyncService) validateRequest(req *models.SyncRequest) error {
-	log.Printf("[SYNC SERVICE] Validating sync request structure...")
+// syncResult compares the target files with the listing taken before the sync and adds the
+// source details reported by the syncer. The listing of the synced files is returned as well.
+func syncResult(syncer syncer.Syncer, contentPath string, before inventory.Inventory, duration time.Duration) (*models.SyncResult, inventory.Inventory) {
+	result := models.SyncResult{}
+	if reporter, ok := syncer.(syncResultReporter); ok {
+		result = reporter.Result()
+	}
+	result.DurationMs = duration.Milliseconds()
 
-	if req == nil {
-		log.Printf("[SYNC SERVICE] ERROR: Sync request is nil")
-		return errors.NewValidationError("sync request is required")
+	after, err := inventory.Scan(contentPath)
+	if err != nil {
+		log.Printf("[SYNC SERVICE] WARNING: Failed to list target files after the sync: %v", err)
+		return &result, nil
 	}
+	changes := inventory.Compare(before, after)
+	result.FilesAdded = changes.Added
+	result.FilesChanged = changes.Changed
+	result.FilesDeleted = changes.Deleted
+	result.BytesTransferred = changes.Bytes
+	result.TotalFiles = changes.Files
+	result.TotalBytes = changes.Total
+	result.FileListHash = changes.ListHash
+	log.Printf("[SYNC SERVICE] Sync result: %d added, %d changed, %d deleted, %d bytes transferred",
+		changes.Added, changes.Changed, changes.Deleted, changes.Bytes)
+	return &result, after
+}
 
-	if req.Source.Type == "" {
-		log.Printf("[SYNC SERVICE] ERROR: Source type is empty")
-		return errors.NewValidationError("source type is required")
+// recordManifest stores the files of a synced target, read from its content path, for drift
+// detection and reports whether it was recorded, after which the caller clears the drift reported
+// for the target under the mutex. With a hash algorithm the digests of the files are recorded as
+// well; files whose metadata did not change since the previous manifest keep their digest without
+// being read again.
+func (s *SyncService) recordManifest(targetPath, contentPath, jobID, revision string, files inventory.Inventory) bool {
+	return s.saveManifest(targetPath, s.newManifest(targetPath, contentPath, jobID, revision, files))
+}
+
+// newManifest returns the manifest of the files of a target, hashed from its content path with a
+// hash algorithm. Hashing reads the files, so the mutex must not be held.
+func (s *SyncService) newManifest(targetPath, contentPath, jobID, revision string, files inventory.Inventory) *inventory.Manifest {
+	manifest := &inventory.Manifest{JobID: jobID, SyncedAt: time.Now().UTC(), Revision: revision, Files: files}
+	if s.hashAlgorithm != "" {
+		var previous inventory.Inventory
+		if recorded, err := inventory.LoadManifest(targetPath); err == nil && recorded.Algorithm == s.hashAlgorithm {
+			previous = recorded.Files
+		}
+		hashStarted := time.Now()
+		if err := files.AddDigests(contentPath, s.hashAlgorithm, previous); err != nil {
+			log.Printf("[SYNC SERVICE] WARNING: Failed to hash files of %s, the manifest records their metadata only: %v", targetPath, err)
+		} else {
+			manifest.Algorithm = s.hashAlgorithm
+			log.Printf("[SYNC SERVICE] Hashed %d files of %s with %s in %v", len(files), targetPath, s.hashAlgorithm, time.Since(hashStarted).Round(time.Millisecond))
+		}
 	}
+	return manifest
+}
 
-	if req.Source.Details == nil {
-		log.Printf("[SYNC SERVICE] ERROR: Source details are nil")
-		return errors.NewValidationError("source details are required")
+// saveManifest stores the manifest of a target and reports whether it was stored
+func (s *SyncService) saveManifest(targetPath string, manifest *inventory.Manifest) bool {
+	if err := inventory.SaveManifest(targetPath, manifest); err != nil {
+		log.Printf("[SYNC SERVICE] WARNING: Failed to record manifest of %s, drift detection unavailable: %v", targetPath, err)
+		return false
 	}
+	return true
+}
 
-	if req.Target.Path == "" {
-		log.Printf("[SYNC SERVICE] ERROR: Target path is empty")
-		return errors.NewValidationError("target path is required")
+// ReportDrift records that the drift watch of the definition found its target drifted, until
+// the next successful sync of the target
+func (s *SyncService) ReportDrift(definition string, drift *models.TargetDriftResponse) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.drifted == nil {
+		s.drifted = map[string]models.DriftedTarget{}
 	}
+	s.drifted[drift.Path] = models.DriftedTarget{
+		Path:       drift.Path,
+		Definition: definition,
+		DetectedAt: time.Now().UTC(),
+		Added:      len(drift.Added),
+		Modified:   len(drift.Modified),
+		Removed:    len(drift.Removed),
+		Truncated:  drift.Truncated,
+	}
+}
 
-	// Validate source type
-	log.Printf("[SYNC SERVICE] Validating source type: %s", req.Source.Type)
-	switch req.Source.Type {
-	case "ssh", "git", "http", "s3":
-		log.Printf("[SYNC SERVICE] Source type is valid")
-	default:
-		log.Printf("[SYNC SERVICE] ERROR: Unsupported source type: %s", req.Source.Type)
-		return errors.NewValidationError(fmt.Sprintf("unsupported source type: %s", req.Source.Type))
+// DriftedTargets returns the targets reported drifted since their last successful sync, by path
+func (s *SyncService) DriftedTargets() []models.DriftedTarget {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	targets := make([]models.DriftedTarget, 0, len(s.drifted))
+	for _, target := range s.drifted {
+		targets = append(targets, target)
 	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].Path < targets[j].Path })
+	return targets
+}
 
-	log.Printf("[SYNC SERVICE] Request validation completed successfully")
+// ListProfiles returns the sync profiles ordered by name
+func (s *SyncService) ListProfiles() []models.SyncProfile {
+	return s.profiles.List()
+}
+
+// GetProfile returns the sync profile with the given name
+func (s *SyncService) GetProfile(name string) (models.SyncProfile, bool) {
+	return s.profiles.Get(name)
+}
+
+// SaveProfile validates the request of the profile like a sync request and stores the profile.
+// An existing profile of the name is replaced when replace is set, otherwise profiles.ErrExists
+// is returned. The stored profile is returned with whether it was created.
+func (s *SyncService) SaveProfile(profile *models.SyncProfile, replace bool) (models.SyncProfile, bool, error) {
+	if err := profiles.ValidateName(profile.Name); err != nil {
+		return models.SyncProfile{}, false, errors.NewValidationError(err.Error())
+	}
+	if profile.Profile != "" {
+		return models.SyncProfile{}, false, errors.NewValidationError("a sync profile cannot run another profile")
+	}
+	if err := s.validateRequest(&profile.SyncRequest); err != nil {
+		log.Printf("[SYNC SERVICE] ERROR: Invalid sync profile %s: %v", profile.Name, err)
+		return models.SyncProfile{}, false, err
+	}
+	if !replace {
+		saved, err := s.profiles.Create(*profile)
+		return saved, err == nil, err
+	}
+	return s.profiles.Put(*profile)
+}
+
+// DeleteProfile removes the sync profile, failing with profiles.ErrNotFound when it does not exist
+func (s *SyncService) DeleteProfile(name string) error {
+	return s.profiles.Delete(name)
+}
+
+// ListCredentials returns the stored credentials ordered by ID, without their data
+func (s *SyncService) ListCredentials() []models.Credential {
+	return s.credentials.List()
+}
+
+// GetCredential returns the stored credential with the given ID, without its data
+func (s *SyncService) GetCredential(id string) (models.Credential, bool) {
+	return s.credentials.Get(id)
+}
+
+// SaveCredential encrypts and stores the credential. An existing credential of the ID is replaced
+// when replace is set, otherwise credentials.ErrExists is returned. The stored credential is
+// returned without its data, with whether it was created.
+func (s *SyncService) SaveCredential(ctx context.Context, credential *models.Credential, replace bool) (models.Credential, bool, error) {
+	if s.credentials == nil {
+		return models.Credential{}, false, errors.NewValidationError(credentials.ErrNotConfigured.Error())
+	}
+	if err := credentials.ValidateID(credential.ID); err != nil {
+		return models.Credential{}, false, errors.NewValidationError(err.Error())
+	}
+	if err := credentials.ValidateData(credential.Data); err != nil {
+		return models.Credential{}, false, errors.NewValidationError(err.Error())
+	}
+	if err := credentials.ValidateAllowedHosts(credential.AllowedHosts); err != nil {
+		return models.Credential{}, false, errors.NewValidationError(err.Error())
+	}
+	if !replace {
+		saved, err := s.credentials.Create(ctx, *credential)
+		return saved, err == nil, err
+	}
+	return s.credentials.Put(ctx, *credential)
+}
+
+// DeleteCredential removes the stored credential, failing with credentials.ErrNotFound when it
+// does not exist. Sources referencing it fail from then on.
+func (s *SyncService) DeleteCredential(id string) error {
+	return s.credentials.Delete(id)
+}
+
+// AuditLog returns the audit log the jobs are recorded in, nil when disabled
+func (s *SyncService) AuditLog() *audit.Log {
+	return s.audit
+}
+
+// Close waits for the notifications being sent, publishes the pending events and writes the
+// pending audit entries. Running jobs are not waited for.
+func (s *SyncService) Close() {
+	if s.stopPruning != nil {
+		close(s.stopPruning)
+	}
+	s.notifier.Wait()
+	s.bus.Close()
+	s.audit.Close()
+}
+
+// ValidateConnection runs the connectivity, authentication and permission checks of the source
+// without transferring data. It does not wait for running syncs.
+func (s *SyncService) ValidateConnection(ctx context.Context, source models.Source) *models.ValidateResponse {
+	log.Printf("[SYNC SERVICE] Validating connection to %s source", source.Type)
+	response := &models.ValidateResponse{
+		Status:     "valid",
+		SourceType: source.Type,
+		Checks:     s.factory.CheckSource(ctx, source),
+	}
+	for _, check := range response.Checks {
+		if check.Status == models.CheckFailed {
+			log.Printf("[SYNC SERVICE] Connection check %s failed: %s", check.Name, check.Error)
+			response.Status = "invalid"
+			break
+		}
+	}
+	return response
+}
+
+// ProbeSource times the connection to the server of the source of the request and checks its
+// credentials once the server is reachable
+func (s *SyncService) ProbeSource(ctx context.Context, req *models.ProbeRequest) *models.ProbeResponse {
+	log.Printf("[SYNC SERVICE] Probing %s source", req.Source.Type)
+	if req.Proxy != nil {
+		ctx = proxy.NewContext(ctx, req.Proxy)
+	}
+	endpoint, stages := s.factory.ProbeSource(ctx, req.Source)
+	response := probeResponse(endpoint, stages)
+	response.SourceType = req.Source.Type
+	return response
+}
+
+// ProbeURL times the connection to the server of the URL, without authentication
+func (s *SyncService) ProbeURL(ctx context.Context, url string, proxyOptions *models.ProxyOptions) (*models.ProbeResponse, error) {
+	log.Printf("[SYNC SERVICE] Probing %s", url)
+	if proxyOptions != nil {
+		ctx = proxy.NewContext(ctx, proxyOptions)
+	}
+	endpoint, stages, err := s.factory.ProbeURL(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	return probeResponse(endpoint, stages), nil
+}
+
+// probeResponse summarizes the stages of a probe
+func probeResponse(endpoint probe.Endpoint, stages []models.SourceCheck) *models.ProbeResponse {
+	response := &models.ProbeResponse{Status: models.ProbeReachable, Stages: stages}
+	if endpoint.Host != "" {
+		response.Endpoint, response.Protocol = endpoint.Address(), endpoint.Protocol
+	}
+	for _, stage := range stages {
+		response.TotalMs += stage.DurationMs
+		if stage.Status != models.CheckFailed {
+			continue
+		}
+		switch stage.Name {
+		case "details", "proxy":
+			response.Status = "invalid"
+		case "authentication":
+			response.Status = models.ProbeDenied
+		default:
+			response.Status = models.ProbeUnreachable
+		}
+		response.Error = stage.Error
+		log.Printf("[SYNC SERVICE] Probe stage %s failed: %s", stage.Name, stage.Error)
+	}
+	return response
+}
+
+// BrowseSource lists the entries of the source at the path of the request, cut to
+// maxBrowseEntries
+func (s *SyncService) BrowseSource(ctx context.Context, req *models.BrowseRequest) (*models.BrowseResponse, error) {
+	log.Printf("[SYNC SERVICE] Browsing %s source at %q", req.Source.Type, req.Path)
+	entries, err := s.factory.BrowseSource(ctx, req.Source, req.Path, maxBrowseEntries)
+	if err != nil {
+		log.Printf("[SYNC SERVICE] ERROR: Browsing %s source failed: %v", req.Source.Type, err)
+		return nil, err
+	}
+	response := &models.BrowseResponse{
+		Status:     "ok",
+		SourceType: req.Source.Type,
+		Path:       req.Path,
+		Entries:    entries,
+	}
+	if len(entries) > maxBrowseEntries {
+		response.Entries, response.Truncated = entries[:maxBrowseEntries], true
+	}
+	log.Printf("[SYNC SERVICE] Listed %d entries of %s source (truncated: %v)", len(response.Entries), req.Source.Type, response.Truncated)
+	return response, nil
+}
+
+// TargetDrift compares the target with the manifest recorded by its last successful sync
+func (s *SyncService) TargetDrift(path string) (*models.TargetDriftResponse, error) {
+	log.Printf("[SYNC SERVICE] Detecting drift of target %s", path)
+	manifest, err := inventory.LoadManifest(path)
+	if os.IsNotExist(err) {
+		return nil, errors.NewValidationError(fmt.Sprintf("no successful sync is recorded for %s", path))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Versioned targets are compared through their current link
+	contentPath, _ := targetContents(path)
+	files, err := inventory.Scan(contentPath)
+	if err != nil {
+		return nil, err
+	}
+	if manifest.Algorithm != "" {
+		if err := files.ReadDigests(contentPath, manifest.Algorithm, manifest.Files); err != nil {
+			return nil, err
+		}
+	}
+
+	differences := inventory.Diff(manifest.Files, files)
+	response := &models.TargetDriftResponse{
+		Status:   "clean",
+		Path:     path,
+		JobID:    manifest.JobID,
+		SyncedAt: &manifest.SyncedAt,
+	}
+	if !differences.Empty() {
+		response.Status = "drifted"
+		log.Printf("[SYNC SERVICE] Target %s drifted: %d added, %d modified, %d removed",
+			path, len(differences.Added), len(differences.Modified), len(differences.Removed))
+	}
+	response.Added, response.Truncated = truncatePaths(differences.Added, response.Truncated)
+	response.Modified, response.Truncated = truncatePaths(differences.Modified, response.Truncated)
+	response.Removed, response.Truncated = truncatePaths(differences.Removed, response.Truncated)
+	return response, nil
+}
+
+// targetContents returns the directory holding the contents of the target, which is the current
+// version of a versioned target, and that version
+func targetContents(path string) (string, string) {
+	store := versions.New(path)
+	if current, err := store.Current(); err == nil && current != "" {
+		return store.CurrentPath(), current
+	}
+	return path, ""
+}
+
+// InspectTarget summarizes the files of the target by top-level entry and reports its last
+// successful sync
+func (s *SyncService) InspectTarget(path string) (*models.TargetInspection, error) {
+	log.Printf("[SYNC SERVICE] Inspecting target %s", path)
+	if info, err := os.Stat(path); err != nil || !info.IsDir() {
+		return nil, errors.NewValidationError(fmt.Sprintf("target %s is not a directory", path))
+	}
+	contentPath, version := targetContents(path)
+	files, err := inventory.Scan(contentPath)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &models.TargetInspection{Status: "ok", Path: path, Version: version, TotalFiles: len(files)}
+	entries := map[string]*models.TargetEntry{}
+	for file, entry := range files {
+		response.TotalBytes += entry.Size
+		name, _, nested := strings.Cut(file, "/")
+		top, ok := entries[name]
+		if !ok {
+			top = &models.TargetEntry{Name: name, Type: "file"}
+			entries[name] = top
+		}
+		if nested {
+			top.Type = "dir"
+		}
+		top.Files++
+		top.Bytes += entry.Size
+	}
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	names, response.Truncated = truncatePaths(names, false)
+	for _, name := range names {
+		response.Entries = append(response.Entries, *entries[name])
+	}
+
+	manifest, err := inventory.LoadManifest(path)
+	if err != nil && !os.IsNotExist(err) {
+		log.Printf("[SYNC SERVICE] WARNING: Failed to load manifest of %s: %v", path, err)
+	}
+	if manifest != nil {
+		response.LastSync = &models.TargetLastSync{JobID: manifest.JobID, SyncedAt: manifest.SyncedAt, Files: len(manifest.Files), Revision: manifest.Revision}
+		if job, ok := s.GetJob(manifest.JobID); manifest.JobID != "" && ok {
+			response.LastSync.SourceType = job.SourceType
+			if job.Result != nil {
+				response.LastSync.Revision = job.Result.Revision
+			}
+		}
+	}
+	log.Printf("[SYNC SERVICE] Target %s holds %d files (%d bytes)", path, response.TotalFiles, response.TotalBytes)
+	return response, nil
+}
+
+// TargetStatus reports the last successful sync of the target, from its manifest, and the last
+// job of the target in the job history, without scanning its files
+func (s *SyncService) TargetStatus(path string) (*models.TargetStatus, error) {
+	response := &models.TargetStatus{Status: "ok", Path: path}
+	manifest, err := inventory.LoadManifest(path)
+	if err != nil && !os.IsNotExist(err) {
+		log.Printf("[SYNC SERVICE] WARNING: Failed to load manifest of %s: %v", path, err)
+	}
+	now := time.Now()
+	if manifest != nil {
+		syncedAt := manifest.SyncedAt.UTC()
+		age := int64(now.Sub(syncedAt).Seconds())
+		response.LastSyncTime, response.Revision, response.DataAgeSeconds = &syncedAt, manifest.Revision, &age
+		response.State = models.TargetStateSynced
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	target := filepath.Clean(path)
+	for i := len(s.jobs) - 1; i >= 0; i-- {
+		job := s.jobs[i]
+		if filepath.Clean(job.TargetPath) != target {
+			continue
+		}
+		if !job.Finished() {
+			// The oldest queued or running job is the one in progress
+			response.ActiveJobID = job.ID
+			response.State = models.TargetStateSyncing
+			if job.Status == models.JobStatusQueued {
+				response.State = models.TargetStateQueued
+			}
+			continue
+		}
+		response.JobID, response.SourceType, response.Result = job.ID, job.SourceType, job.Status
+		response.Changed, response.Error, response.ErrorCode = job.Changed, job.Error, job.ErrorCode
+		response.FinishedAt = job.FinishedAt
+		if job.FinishedAt != nil && !job.StartedAt.IsZero() {
+			response.DurationMs = job.FinishedAt.Sub(job.StartedAt).Milliseconds()
+		}
+		if response.ActiveJobID == "" {
+			response.State = models.TargetStateSynced
+			if job.Status == models.JobStatusFailed {
+				response.State = models.TargetStateFailed
+			}
+		}
+		// Manifests of earlier versions do not record the revision
+		if response.Revision == "" && job.Result != nil && (manifest == nil || manifest.JobID == job.ID) {
+			response.Revision = job.Result.Revision
+		}
+		break
+	}
+	if response.State == "" {
+		return nil, errors.NewValidationError(fmt.Sprintf("no sync of target %s is recorded", path))
+	}
+	return response, nil
+}
+
+// PurgeTarget empties a target below the allowed purge roots. Without a confirmation token, the
+// target is left untouched and a token confirming its purge is returned.
+func (s *SyncService) PurgeTarget(path, confirm string) (*models.TargetPurgeResponse, error) {
+	if s.purger == nil {
+		return nil, errors.NewValidationError("target purging is disabled, no purge roots are configured")
+	}
+	target, err := s.purger.Resolve(path)
+	if err != nil {
+		return nil, errors.NewValidationError(err.Error())
+	}
+	if confirm == "" {
+		token, expires := s.purger.Token(target, time.Now())
+		log.Printf("[SYNC SERVICE] Purge of target %s requested, awaiting confirmation", path)
+		return &models.TargetPurgeResponse{Status: "confirmation required", Path: path, ConfirmToken: token, ExpiresAt: &expires}, nil
+	}
+	if err := s.purger.Verify(target, confirm, time.Now()); err != nil {
+		return nil, errors.NewValidationError(err.Error())
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.syncInProgress {
+		log.Printf("[SYNC SERVICE] ERROR: Sync operation in progress, purge refused")
+		return nil, errors.NewValidationError("sync operation in progress")
+	}
+
+	log.Printf("[SYNC SERVICE] Purging target %s", path)
+	removed, err := purge.Empty(target)
+	if err != nil {
+		log.Printf("[SYNC SERVICE] ERROR: Purge of %s failed after removing %d entries: %v", path, removed, err)
+		return nil, err
+	}
+	// The target has no synced state to detect drift against anymore
+	for _, manifestTarget := range []string{path, target} {
+		if err := os.Remove(inventory.ManifestPath(manifestTarget)); err != nil && !os.IsNotExist(err) {
+			log.Printf("[SYNC SERVICE] WARNING: Failed to remove manifest of %s: %v", manifestTarget, err)
+		}
+	}
+	log.Printf("[SYNC SERVICE] Target %s purged, %d entries removed", path, removed)
+	return &models.TargetPurgeResponse{Status: "purged", Path: path, Removed: removed}, nil
+}
+
+// truncatePaths cuts a path list to maxDriftPaths entries
+func truncatePaths(paths []string, truncated bool) ([]string, bool) {
+	if len(paths) > maxDriftPaths {
+		return paths[:maxDriftPaths], true
+	}
+	return paths, truncated
+}
+
+// runAttempts runs the syncer until it succeeds, fails for a reason that is not transient or
+// runs out of attempts, and records the attempts in the job when retries are enabled. Each attempt
+// reports its progress to the tracker from the start.
+func (s *SyncService) runAttempts(ctx context.Context, job *models.SyncJob, syncer syncer.Syncer, policy retry.Policy, tracker *progress.Tracker) error {
+	ctx = metrics.WithTarget(progress.WithReporter(ctx, tracker), job.SourceType, job.TargetPath)
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 {
+			tracker.Reset()
+		}
+		result := models.SyncAttempt{Attempt: attempt, Status: models.JobStatusRunning, StartedAt: time.Now().UTC()}
+		if policy.Enabled() {
+			s.recordAttempt(job, result)
+		}
+
+		err := syncOnce(ctx, syncer)
+		finishedAt := time.Now().UTC()
+		result.FinishedAt = &finishedAt
+		if err == nil {
+			result.Status = models.JobStatusSucceeded
+		} else {
+			result.Status = models.JobStatusFailed
+			result.Error = err.Error()
+			result.ErrorCode, result.Transient = retry.Classify(err)
+		}
+
+		retrying := err != nil && result.Transient && attempt < policy.Attempts && ctx.Err() == nil
+		var delay time.Duration
+		if retrying {
+			delay = policy.Delay(attempt)
+			retryAt := finishedAt.Add(delay)
+			result.RetryAt = &retryAt
+		}
+		if policy.Enabled() {
+			s.recordAttempt(job, result)
+		}
+		if !retrying {
+			if err != nil && result.Transient && policy.Enabled() {
+				log.Printf("[SYNC SERVICE] Attempt %d of %d failed, no attempts left", attempt, policy.Attempts)
+			}
+			return err
+		}
+
+		log.Printf("[SYNC SERVICE] Attempt %d of %d failed with a transient error, retrying in %v: %v", attempt, policy.Attempts, delay, err)
+		metrics.SyncRetried(job.SourceType, job.TargetPath)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// runLimited runs the attempts like runAttempts, but gives up on a syncer that does not return
+// within abandonGrace once the job was canceled or ran out of time, so that a hung transfer does
+// not hold the worker. It reports whether the syncer was abandoned, which may still be running.
+func (s *SyncService) runLimited(ctx context.Context, job *models.SyncJob, syncer syncer.Syncer, policy retry.Policy, tracker *progress.Tracker) (bool, error) {
+	done := make(chan error, 1)
+	go func() {
+		done <- s.runAttempts(ctx, job, syncer, policy, tracker)
+	}()
+	select {
+	case err := <-done:
+		return false, err
+	case <-ctx.Done():
+	}
+
+	timer := time.NewTimer(abandonGrace)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return false, err
+	case <-timer.C:
+		log.Printf("[SYNC SERVICE] WARNING: Syncer of job %s did not stop within %v, finishing the job without it", job.ID, abandonGrace)
+		return true, context.Cause(ctx)
+	}
+}
+
+// runtimeLimit returns the wall-clock limit of the job of the validated request, 0 when unlimited
+func (s *SyncService) runtimeLimit(req *models.SyncRequest) time.Duration {
+	if req.MaxRuntime == "" {
+		return s.maxRuntime
+	}
+	limit, _ := time.ParseDuration(req.MaxRuntime)
+	return limit
+}
+
+// syncOnce runs the syncer, turning a panic into an error so that it fails the job instead of
+// the service
+func syncOnce(ctx context.Context, syncer syncer.Syncer) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("[SYNC SERVICE] ERROR: Sync panicked: %v\n%s", r, debug.Stack())
+			err = fmt.Errorf("sync panicked: %v", r)
+		}
+	}()
+	return syncer.Sync(ctx)
+}
+
+// recordAttempt adds the attempt to the job or replaces the entry of the same attempt
+func (s *SyncService) recordAttempt(job *models.SyncJob, attempt models.SyncAttempt) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// Job snapshots share the slice, so entries are never replaced in place
+	attempts := make([]models.SyncAttempt, 0, len(job.Attempts)+1)
+	for _, recorded := range job.Attempts {
+		if recorded.Attempt != attempt.Attempt {
+			attempts = append(attempts, recorded)
+		}
+	}
+	job.Attempts = append(attempts, attempt)
+}
+
+// runHooks runs the hooks of one phase and records their results in the job
+func (s *SyncService) runHooks(job *models.SyncJob, list []models.Hook, info hooks.JobInfo) error {
+	if len(list) == 0 {
+		return nil
+	}
+
+	log.Printf("[SYNC SERVICE] Running %d %s-sync hooks for job %s", len(list), info.Phase, job.ID)
+	results, err := s.hooks.Run(list, info)
+
+	s.mutex.Lock()
+	job.Hooks = append(job.Hooks, results...)
+	s.mutex.Unlock()
+	return err
+}
+
+// verifyTarget verifies the contents at the path against the manifest, rolls them back on failure
+// when a rollback is given and records the result in the job
+func (s *SyncService) verifyTarget(job *models.SyncJob, path string, options *models.VerifyOptions, rollback func() error) error {
+	log.Printf("[SYNC SERVICE] Verifying target for job %s...", job.ID)
+	result, err := verify.Verify(path, options)
+	if err != nil && rollback != nil {
+		if restoreErr := rollback(); restoreErr != nil {
+			log.Printf("[SYNC SERVICE] ERROR: Rollback failed: %v", restoreErr)
+			err = fmt.Errorf("%w, rollback failed: %v", err, restoreErr)
+		} else {
+			result.RolledBack = true
+			err = fmt.Errorf("%w, target rolled back", err)
+		}
+	}
+
+	s.mutex.Lock()
+	job.Verification = result
+	s.mutex.Unlock()
+	return err
+}
+
+// TargetUsage returns the usage of the configured quotas, restricted to the quota covering the
+// path when one is given
+func (s *SyncService) TargetUsage(path string) ([]models.TargetUsage, error) {
+	if path != "" {
+		limit := s.quotas.For(path)
+		if limit == nil {
+			return nil, errors.NewValidationError(fmt.Sprintf("no quota is configured for %s", path))
+		}
+		return []models.TargetUsage{*targetUsage(limit)}, nil
+	}
+
+	usages := make([]models.TargetUsage, 0)
+	for _, limit := range s.quotas.Limits() {
+		usages = append(usages, *targetUsage(&limit))
+	}
+	return usages, nil
+}
+
+// targetUsage measures the usage of the quota path
+func targetUsage(limit *quota.Limit) *models.TargetUsage {
+	usage := &models.TargetUsage{Path: limit.Path, QuotaBytes: limit.Bytes}
+	used, err := quota.Usage(limit.Path)
+	if err != nil {
+		log.Printf("[SYNC SERVICE] ERROR: Failed to measure usage of %s: %v", limit.Path, err)
+		usage.Error = err.Error()
+		return usage
+	}
+	usage.UsedBytes = used
+	return usage
+}
+
+// restoreVersion activates the version that was current before the sync, or removes the current
+// link when the sync published the first version
+func restoreVersion(store *versions.Store, previous string) error {
+	if previous == "" {
+		return store.Unpublish()
+	}
+	return store.Switch(previous)
+}
+
+// RollbackTarget activates a previous version of a versioned target. The version before the
+// current one is activated when no version is given.
+func (s *SyncService) RollbackTarget(req *models.TargetRollbackRequest) (*models.TargetRollbackResponse, error) {
+	log.Printf("[SYNC SERVICE] Rolling back target %s (version: %q)", req.Path, req.Version)
+
+	// The activated version is the synced state drift is detected against from now on. Published
+	// versions do not change, so its files are hashed before the lock is taken.
+	store := versions.New(req.Path)
+	version, err := store.RollbackVersion(req.Version)
+	var manifest *inventory.Manifest
+	if err == nil {
+		if files, err := inventory.Scan(store.Path(version)); err == nil {
+			manifest = s.newManifest(req.Path, store.Path(version), "", "", files)
+		} else {
+			log.Printf("[SYNC SERVICE] WARNING: Failed to list files of version %s: %v", version, err)
+		}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.syncInProgress {
+		log.Printf("[SYNC SERVICE] ERROR: Sync operation in progress, rollback refused")
+		return nil, errors.NewValidationError("sync operation in progress")
+	}
+
+	available, err := store.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list versions: %w", err)
+	}
+	if len(available) == 0 {
+		return nil, errors.NewValidationError(fmt.Sprintf("target %s has no versions", req.Path))
+	}
+	previous, err := store.Current()
+	if err != nil {
+		return nil, err
+	}
+
+	// A sync may have published a version since the files were hashed
+	if resolved, err := store.RollbackVersion(req.Version); err == nil && resolved != version {
+		log.Printf("[SYNC SERVICE] ERROR: Target %s changed during the rollback", req.Path)
+		return nil, errors.NewValidationError(fmt.Sprintf("target %s changed during the rollback, retry it", req.Path))
+	}
+	current, err := store.Rollback(req.Version)
+	if err != nil {
+		log.Printf("[SYNC SERVICE] ERROR: Rollback failed: %v", err)
+		return nil, errors.NewValidationError(err.Error())
+	}
+	if manifest != nil && s.saveManifest(req.Path, manifest) {
+		delete(s.drifted, req.Path)
+	}
+	return &models.TargetRollbackResponse{
+		Status:   "rolled back",
+		Path:     req.Path,
+		Previous: previous,
+		Current:  current,
+		Versions: available,
+	}, nil
+}
+
+// addJob records a job and trims the history; the caller must hold the mutex
+func (s *SyncService) addJob(job *models.SyncJob) {
+	s.jobs = append(s.jobs, job)
+	s.pruneJobs()
+}
+
+// saveJob persists the job. Queued and running jobs are stored with their request, so that they
+// can be queued again or resumed after a restart. The caller holds the mutex.
+func (s *SyncService) saveJob(job *models.SyncJob, req *models.SyncRequest) {
+	record := &jobstate.Record{Job: *job}
+	if !job.Finished() {
+		record.Request = req
+		if req != nil {
+			record.Initiator = req.Initiator
+		}
+	}
+	if err := s.state.Save(record); err != nil {
+		log.Printf("[SYNC SERVICE] WARNING: Failed to persist job %s: %v", job.ID, err)
+	}
+}
+
+// Recover loads the persisted jobs. A job interrupted by a restart is resumed when enabled and
+// possible; otherwise it fails and what it left behind is removed. Queued jobs are queued again.
+func (s *SyncService) Recover() error {
+	records, err := s.state.Load()
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var interrupted, queued []jobstate.Record
+	for _, record := range records {
+		if record.Request != nil {
+			record.Request.Initiator = record.Initiator
+		}
+		switch record.Job.Status {
+		case models.JobStatusRunning:
+			interrupted = append(interrupted, record)
+		case models.JobStatusQueued:
+			queued = append(queued, record)
+		default:
+			job := record.Job
+			s.addJob(&job)
+		}
+	}
+	log.Printf("[SYNC SERVICE] Loaded %d persisted jobs, %d interrupted, %d queued", len(records), len(interrupted), len(queued))
+
+	// Only one job runs at a time, older interrupted jobs cannot be resumed
+	for i, record := range interrupted {
+		s.recoverJob(record, i == len(interrupted)-1)
+	}
+	for _, record := range queued {
+		s.requeueJob(record)
+	}
+	s.dispatchNext()
+	return nil
+}
+
+// requeueJob queues a job that was waiting when the server stopped again, or fails it when its
+// request is no longer valid. The caller holds the mutex.
+func (s *SyncService) requeueJob(record jobstate.Record) {
+	job := record.Job
+	req := record.Request
+	s.addJob(&job)
+
+	var err error = errors.NewValidationError("the queued request was not persisted")
+	var queuedSyncer syncer.Syncer
+	if req != nil {
+		if err = s.validateRequest(req); err == nil {
+			queuedSyncer, err = s.createSyncer(req, false)
+		}
+	}
+	if err != nil {
+		log.Printf("[SYNC SERVICE] ERROR: Failed to queue job %s again: %v", job.ID, err)
+		finishedAt := time.Now().UTC()
+		job.Status = models.JobStatusFailed
+		job.Error = fmt.Sprintf("queued before a restart, queueing it again failed: %v", err)
+		job.ErrorCode, job.Retryable = errors.CodeInterrupted, true
+		job.FinishedAt = &finishedAt
+		s.saveJob(&job, nil)
+		return
+	}
+	s.queue = append(s.queue, queuedJob{job: &job, req: req, syncer: queuedSyncer, key: requestKey(req)})
+}
+
+// recoverJob resumes or fails a job interrupted by a restart. The caller holds the mutex.
+func (s *SyncService) recoverJob(record jobstate.Record, resumable bool) {
+	job := record.Job
+	req := record.Request
+	reason := "interrupted by a restart"
+	switch {
+	case !s.resume:
+		reason += ", resuming is disabled"
+	case req == nil || !resumable:
+		reason += ", the job cannot be resumed"
+	default:
+		err := s.validateRequest(req)
+		var resumedSyncer syncer.Syncer
+		if err == nil {
+			resumedSyncer, err = s.createSyncer(req, true)
+		}
+		if err == nil {
+			log.Printf("[SYNC SERVICE] Resuming job %s interrupted by a restart", job.ID)
+			job.Resumed++
+			s.addJob(&job)
+			s.saveJob(&job, req)
+			ctx, cancel := context.WithCancelCause(context.Background())
+			s.syncInProgress = true
+			s.running, s.runningKey = &job, requestKey(req)
+			s.cancel = cancel
+			s.progress = progress.NewTracker()
+			metrics.SyncStarted()
+			go s.runJob(ctx, cancel, &job, req, resumedSyncer, true)
+			return
+		}
+		log.Printf("[SYNC SERVICE] ERROR: Failed to resume job %s: %v", job.ID, err)
+		reason += fmt.Sprintf(", resuming failed: %v", err)
+	}
+
+	log.Printf("[SYNC SERVICE] Job %s was %s, marking it failed", job.ID, reason)
+	if req != nil && req.Verify != nil && req.Verify.Rollback && req.Versions == nil {
+		if snapshot := verify.OpenSnapshot(job.TargetPath, job.ID); snapshot != nil {
+			if err := snapshot.Restore(); err != nil {
+				log.Printf("[SYNC SERVICE] ERROR: Failed to roll back %s: %v", job.TargetPath, err)
+			} else {
+				reason += ", target rolled back"
+			}
+			snapshot.Remove()
+		}
+	}
+	if err := s.factory.RemoveInterrupted(job.TargetPath); err != nil {
+		log.Printf("[SYNC SERVICE] WARNING: Failed to clean up after job %s: %v", job.ID, err)
+	}
+
+	finishedAt := time.Now().UTC()
+	job.Status = models.JobStatusFailed
+	job.Error = reason
+	job.ErrorCode, job.Retryable = errors.CodeInterrupted, true
+	job.FinishedAt = &finishedAt
+	s.addJob(&job)
+	s.saveJob(&job, nil)
+	s.audit.SyncFinished(&job, req)
+	s.notifier.SyncFinished(&job, req)
+	s.bus.Completed(&job, req)
+}
+
+// newJobID generates a random job identifier
+func newJobID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// validateRequest validates the sync request, reporting every invalid field in an
+// InvalidRequestError
+func (s *SyncService) validateRequest(req *models.SyncRequest) error {
+	log.Printf("[SYNC SERVICE] Validating sync request structure...")
+
+	if req == nil {
+		log.Printf("[SYNC SERVICE] ERROR: Sync request is nil")
+		return errors.NewValidationError("sync request is required")
+	}
+
+	fieldErrs := validation.Items(req, s.maxListItems)
+
+	if req.Target.Path == "" {
+		fieldErrs = append(fieldErrs, models.FieldError{Field: "target.path", Message: "is required"})
+	}
+
+	if err := s.hooks.Validate(req.Hooks); err != nil {
+		fieldErrs = append(fieldErrs, validation.Errors("hooks", err)...)
+	}
+
+	if _, err := retry.Resolve(s.retry, req.Retry); err != nil {
+		fieldErrs = append(fieldErrs, validation.Errors("retry", err)...)
+	}
+
+	if err := verify.Validate(req.Verify); err != nil {
+		fieldErrs = append(fieldErrs, validation.Errors("verify", err)...)
+	}
+
+	if _, err := bwlimit.Parse(req.BandwidthLimit); err != nil {
+		fieldErrs = append(fieldErrs, validation.Errors("bandwidthLimit", err)...)
+	}
+
+	if _, err := proxy.New(req.Proxy); err != nil {
+		fieldErrs = append(fieldErrs, validation.Errors("proxy", err)...)
+	}
+
+	if req.MaxRuntime != "" {
+		if err := validation.Duration(req.MaxRuntime); err != nil {
+			fieldErrs = append(fieldErrs, validation.Errors("maxRuntime", err)...)
+		}
+	}
+
+	if len(req.Labels) > validation.MaxLabels {
+		fieldErrs = append(fieldErrs, models.FieldError{Field: "labels", Message: fmt.Sprintf("has %d labels, at most %d are allowed", len(req.Labels), validation.MaxLabels)})
+	}
+	for _, key := range slices.Sorted(maps.Keys(req.Labels)) {
+		if err := validation.Label(key, req.Labels[key]); err != nil {
+			fieldErrs = append(fieldErrs, validation.Errors("labels."+key, err)...)
+		}
+	}
+
+	switch req.ConflictPolicy {
+	case "", models.ConflictOverwrite, models.ConflictFail, models.ConflictBackup:
+	default:
+		fieldErrs = append(fieldErrs, models.FieldError{Field: "conflictPolicy", Message: fmt.Sprintf("must be %q, %q or %q",
+			models.ConflictOverwrite, models.ConflictFail, models.ConflictBackup)})
+	}
+
+	if len(req.Sources) == 0 {
+		fieldErrs = append(fieldErrs, s.validateSource(req.Source, "source")...)
+	} else {
+		if req.Source.Type != "" || req.Source.Details != nil {
+			fieldErrs = append(fieldErrs, models.FieldError{Field: "source", Message: "cannot be provided together with sources"})
+		}
+		if req.Parallelism < 0 {
+			fieldErrs = append(fieldErrs, models.FieldError{Field: "parallelism", Message: "must be at least 1"})
+		}
+		for i, source := range req.Sources {
+			field := fmt.Sprintf("sources[%d]", i)
+			if source.Path == "" {
+				fieldErrs = append(fieldErrs, models.FieldError{Field: field + ".path", Message: "is required"})
+			}
+			fieldErrs = append(fieldErrs, s.validateSource(source.Source, field)...)
+		}
+	}
+
+	if len(fieldErrs) > 0 {
+		err := &InvalidRequestError{Errors: fieldErrs}
+		log.Printf("[SYNC SERVICE] ERROR: %v", err)
+		return err
+	}
+	log.Printf("[SYNC SERVICE] Request validation completed successfully")
+	return nil
+}
+
+// validateSource validates the type, details and credential of a single source
+func (s *SyncService) validateSource(source models.Source, field string) []models.FieldError {
+	if source.Type == "" {
+		return []models.FieldError{{Field: field + ".type", Message: "is required"}}
+	}
+
+	if source.Details == nil {
+		return []models.FieldError{{Field: field + ".details", Message: "is required"}}
+	}
+
+	// The fields of a stored credential are only decrypted when the syncer is created
+	source, err := s.factory.CredentialPlaceholders(source)
+	if err != nil {
+		return validation.Errors(field+".credentialRef", err)
+	}
+
+	// Validate source type
+	log.Printf("[SYNC SERVICE] Validating source type: %s", source.Type)
+	if err := syncer.ValidateSource(source); err != nil {
+		return validation.Errors(field, err)
+	}
+	log.Printf("[SYNC SERVICE] Source type is valid")
 	return nil
 }
@@ -0,0 +1,53 @@
+package sshutil
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// ResolveAgentSocket returns the SSH agent socket to use: the requested path, or SSH_AUTH_SOCK
+// when none was requested. An empty string means no agent is available; an unusable
+// SSH_AUTH_SOCK is ignored while an unusable requested socket is an error.
+func ResolveAgentSocket(requested string) (string, error) {
+	if requested == "" {
+		socket := os.Getenv("SSH_AUTH_SOCK")
+		if socket == "" {
+			return "", nil
+		}
+		if _, err := checkAgentSocket(socket); err != nil {
+			log.Printf("[SSH UTIL] WARNING: Ignoring SSH_AUTH_SOCK: %v", err)
+			return "", nil
+		}
+		return socket, nil
+	}
+	return checkAgentSocket(requested)
+}
+
+// checkAgentSocket verifies that socket is an absolute path to a unix socket
+func checkAgentSocket(socket string) (string, error) {
+	if !filepath.IsAbs(socket) {
+		return "", fmt.Errorf("SSH agent socket must be an absolute path: %s", socket)
+	}
+	info, err := os.Stat(socket)
+	if err != nil {
+		return "", fmt.Errorf("SSH agent socket %s is not accessible: %w", socket, err)
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return "", fmt.Errorf("SSH agent socket %s is not a socket", socket)
+	}
+	return socket, nil
+}
+
+// DialAgent connects to the SSH agent listening on socket. The returned function closes the connection.
+func DialAgent(socket string) (agent.ExtendedAgent, func(), error) {
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to SSH agent: %w", err)
+	}
+	return agent.NewClient(conn), func() { conn.Close() }, nil
+}
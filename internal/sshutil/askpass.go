@@ -0,0 +1,46 @@
+package sshutil
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	askPassModeEnv   = "VOLUME_SYNCER_ASKPASS"
+	askPassSecretEnv = "VOLUME_SYNCER_ASKPASS_SECRET"
+)
+
+// RunAskPass answers an ssh password prompt when the binary is invoked as SSH_ASKPASS helper.
+// It returns false when the process was started normally.
+func RunAskPass() bool {
+	if os.Getenv(askPassModeEnv) != "1" {
+		return false
+	}
+
+	// Never confirm unknown host keys; host key trust is decided by the known_hosts setup
+	if len(os.Args) > 1 && strings.Contains(os.Args[1], "(yes/no") {
+		fmt.Println("no")
+		return true
+	}
+
+	fmt.Println(os.Getenv(askPassSecretEnv))
+	return true
+}
+
+// AskPassEnv returns the environment that makes ssh read the password from this binary
+// through SSH_ASKPASS, so the password never appears in process arguments.
+func AskPassEnv(password string) ([]string, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve executable for SSH_ASKPASS: %w", err)
+	}
+
+	return []string{
+		"SSH_ASKPASS=" + executable,
+		"SSH_ASKPASS_REQUIRE=force",
+		"DISPLAY=volume-syncer:0", // required by OpenSSH releases without SSH_ASKPASS_REQUIRE
+		askPassModeEnv + "=1",
+		askPassSecretEnv + "=" + password,
+	}, nil
+}
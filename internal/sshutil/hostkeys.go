@@ -0,0 +1,190 @@
+package sshutil
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/sharedvolume/volume-syncer/internal/netdial"
+	"github.com/sharedvolume/volume-syncer/internal/proxy"
+)
+
+// ParseGitSSHEndpoint extracts the host and port from an SSH Git URL.
+// Both ssh://[user@]host[:port]/path and scp-like [user@]host:path forms are supported.
+func ParseGitSSHEndpoint(repoURL string) (string, int, bool) {
+	if strings.HasPrefix(repoURL, "ssh://") || strings.HasPrefix(repoURL, "git+ssh://") {
+		parsedURL, err := url.Parse(repoURL)
+		if err != nil || parsedURL.Hostname() == "" {
+			return "", 0, false
+		}
+		port := 22
+		if p := parsedURL.Port(); p != "" {
+			if n, err := strconv.Atoi(p); err == nil {
+				port = n
+			}
+		}
+		return parsedURL.Hostname(), port, true
+	}
+
+	if strings.Contains(repoURL, "://") {
+		return "", 0, false
+	}
+
+	// scp-like syntax: [user@]host:path
+	colon := strings.Index(repoURL, ":")
+	if colon <= 0 {
+		return "", 0, false
+	}
+	host := repoURL[:colon]
+	if at := strings.LastIndex(host, "@"); at != -1 {
+		host = host[at+1:]
+	}
+	if host == "" || strings.Contains(host, "/") {
+		return "", 0, false
+	}
+	return host, 22, true
+}
+
+// BuildKnownHosts validates user supplied known_hosts material for the given endpoint.
+// Lines in known_hosts format are kept as-is; bare public keys ("ssh-ed25519 AAAA...")
+// are bound to the endpoint address.
+func BuildKnownHosts(knownHosts, host string, port int) (string, error) {
+	address := knownhosts.Normalize(net.JoinHostPort(host, strconv.Itoa(port)))
+
+	var lines []string
+	scanner := bufio.NewScanner(strings.NewReader(knownHosts))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if _, _, _, _, _, err := ssh.ParseKnownHosts([]byte(line)); err == nil {
+			lines = append(lines, line)
+			continue
+		}
+
+		key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(line))
+		if err != nil {
+			return "", fmt.Errorf("invalid known hosts entry %q: %w", truncate(line, 40), err)
+		}
+		lines = append(lines, knownhosts.Line([]string{address}, key))
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to read known hosts: %w", err)
+	}
+
+	if len(lines) == 0 {
+		return "", fmt.Errorf("known hosts material does not contain any host keys")
+	}
+
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+// KnownHostsForFingerprint connects to the endpoint, verifies that the presented host key
+// matches the expected fingerprint and returns a known_hosts line for it.
+// Fingerprints are accepted in OpenSSH SHA256:<base64> or legacy MD5 (aa:bb:...) form.
+func KnownHostsForFingerprint(host string, port int, fingerprint string, timeout time.Duration, p *proxy.Proxy) (string, error) {
+	address := net.JoinHostPort(host, strconv.Itoa(port))
+	log.Printf("[SSH UTIL] Fetching host key from %s for fingerprint verification", address)
+
+	var hostKey ssh.PublicKey
+	config := &ssh.ClientConfig{
+		User: "volume-syncer",
+		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			if !FingerprintMatches(key, fingerprint) {
+				return fmt.Errorf("host key fingerprint mismatch for %s: got %s", address, ssh.FingerprintSHA256(key))
+			}
+			hostKey = key
+			return nil
+		},
+		Timeout: timeout,
+	}
+
+	client, err := Dial(p, address, config)
+	if client != nil {
+		client.Close()
+	}
+	if hostKey == nil {
+		if err == nil {
+			err = fmt.Errorf("no host key received")
+		}
+		return "", fmt.Errorf("failed to verify host key for %s: %w", address, err)
+	}
+
+	log.Printf("[SSH UTIL] Host key for %s matches expected fingerprint", address)
+	return knownhosts.Line([]string{knownhosts.Normalize(address)}, hostKey) + "\n", nil
+}
+
+// Dial connects to the SSH server at the address through the proxy, within the timeout of the
+// client configuration. Host names are resolved with the default resolver of netdial.
+func Dial(p *proxy.Proxy, address string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	if p.Route(address) == nil && netdial.Default() == nil {
+		return ssh.Dial("tcp", address, config)
+	}
+
+	ctx := context.Background()
+	if config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.Timeout)
+		defer cancel()
+	}
+	conn, err := p.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	// The timeout also bounds the SSH handshake, as with ssh.Dial
+	if config.Timeout > 0 {
+		conn.SetDeadline(time.Now().Add(config.Timeout))
+	}
+	clientConn, channels, requests, err := ssh.NewClientConn(conn, address, config)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return ssh.NewClient(clientConn, channels, requests), nil
+}
+
+// FingerprintMatches reports whether key matches the given fingerprint
+func FingerprintMatches(key ssh.PublicKey, fingerprint string) bool {
+	fingerprint = strings.TrimSpace(fingerprint)
+	if strings.HasPrefix(fingerprint, "SHA256:") {
+		return ssh.FingerprintSHA256(key) == fingerprint
+	}
+	return ssh.FingerprintLegacyMD5(key) == strings.ToLower(strings.TrimPrefix(fingerprint, "MD5:"))
+}
+
+// WriteKnownHostsFile writes known_hosts content to a new file in dir ("" means the default temp dir)
+func WriteKnownHostsFile(dir, content string) (string, error) {
+	tmpFile, err := os.CreateTemp(dir, "known_hosts_*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create known hosts file: %w", err)
+	}
+	defer tmpFile.Close()
+
+	if _, err := tmpFile.WriteString(content); err != nil {
+		os.Remove(tmpFile.Name())
+		return "", fmt.Errorf("failed to write known hosts file: %w", err)
+	}
+
+	return filepath.Clean(tmpFile.Name()), nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
@@ -0,0 +1,57 @@
+package sshutil
+
+import (
+	"log"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// KeepAlive sends a keep-alive request to the server every interval and closes the client once
+// countMax requests in a row remain unanswered, like ServerAliveInterval and ServerAliveCountMax
+// of ssh. It stops when the client is closed.
+func KeepAlive(client *ssh.Client, interval time.Duration, countMax int) {
+	if interval <= 0 {
+		return
+	}
+	if countMax <= 0 {
+		countMax = 3
+	}
+
+	done := make(chan struct{})
+	go func() {
+		client.Wait()
+		close(done)
+	}()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		replies := make(chan error, 1)
+		pending, missed := false, 0
+		for {
+			select {
+			case <-done:
+				return
+			case err := <-replies:
+				pending = false
+				if err == nil {
+					missed = 0
+				}
+			case <-ticker.C:
+				if pending {
+					if missed++; missed >= countMax {
+						log.Printf("[SSH UTIL] WARNING: %d keep-alive requests unanswered, closing the connection to %s", missed, client.RemoteAddr())
+						client.Close()
+						return
+					}
+					continue
+				}
+				pending = true
+				go func() {
+					_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+					replies <- err
+				}()
+			}
+		}
+	}()
+}
@@ -0,0 +1,118 @@
+package sshutil
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// errReadOnlyAgent is returned to clients trying to change the keys of a key agent
+var errReadOnlyAgent = errors.New("the keys of this agent cannot be changed")
+
+// KeyAgent serves a private key held in memory to ssh processes through an agent socket, so that
+// the key is never written to the filesystem. Only the socket is created, in a directory
+// accessible by the server only.
+type KeyAgent struct {
+	dir      string
+	listener net.Listener
+	keyring  agent.Agent
+
+	mutex sync.Mutex
+	conns map[net.Conn]struct{}
+	wg    sync.WaitGroup
+}
+
+// StartKeyAgent serves the private key, signed by the certificate if one is given, on a socket in
+// a new directory below dir ("" means the default temp dir)
+func StartKeyAgent(dir string, privateKey []byte, certificate *ssh.Certificate) (*KeyAgent, error) {
+	key, err := ssh.ParseRawPrivateKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+	keyring := agent.NewKeyring()
+	if err := keyring.Add(agent.AddedKey{PrivateKey: key, Certificate: certificate}); err != nil {
+		return nil, fmt.Errorf("failed to load private key into the agent: %w", err)
+	}
+
+	socketDir, err := os.MkdirTemp(dir, "volume-syncer-agent-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create agent directory: %w", err)
+	}
+	listener, err := net.Listen("unix", filepath.Join(socketDir, "agent.sock"))
+	if err != nil {
+		os.RemoveAll(socketDir)
+		return nil, fmt.Errorf("failed to listen on agent socket: %w", err)
+	}
+
+	a := &KeyAgent{
+		dir:      socketDir,
+		listener: listener,
+		keyring:  readOnlyAgent{keyring},
+		conns:    map[net.Conn]struct{}{},
+	}
+	a.wg.Add(1)
+	go a.serve()
+	return a, nil
+}
+
+// Socket returns the path ssh connects to through SSH_AUTH_SOCK
+func (a *KeyAgent) Socket() string {
+	return a.listener.Addr().String()
+}
+
+// Close stops serving the key, closes the open connections and removes the socket
+func (a *KeyAgent) Close() {
+	a.listener.Close()
+	a.mutex.Lock()
+	for conn := range a.conns {
+		conn.Close()
+	}
+	a.mutex.Unlock()
+	a.wg.Wait()
+	os.RemoveAll(a.dir)
+}
+
+func (a *KeyAgent) serve() {
+	defer a.wg.Done()
+	for {
+		conn, err := a.listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Printf("[SSH UTIL] WARNING: Key agent stopped accepting connections: %v", err)
+			}
+			return
+		}
+		a.mutex.Lock()
+		a.conns[conn] = struct{}{}
+		a.mutex.Unlock()
+
+		a.wg.Add(1)
+		go func() {
+			defer a.wg.Done()
+			// ServeAgent returns once the client closed the connection
+			agent.ServeAgent(a.keyring, conn)
+			conn.Close()
+			a.mutex.Lock()
+			delete(a.conns, conn)
+			a.mutex.Unlock()
+		}()
+	}
+}
+
+// readOnlyAgent lists and signs with the keys of the agent, but refuses to change them
+type readOnlyAgent struct {
+	agent.Agent
+}
+
+func (readOnlyAgent) Add(agent.AddedKey) error       { return errReadOnlyAgent }
+func (readOnlyAgent) Remove(ssh.PublicKey) error     { return errReadOnlyAgent }
+func (readOnlyAgent) RemoveAll() error               { return errReadOnlyAgent }
+func (readOnlyAgent) Lock(passphrase []byte) error   { return errReadOnlyAgent }
+func (readOnlyAgent) Unlock(passphrase []byte) error { return errReadOnlyAgent }
@@ -0,0 +1,177 @@
+package syncer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/sharedvolume/volume-syncer/internal/models"
+	"github.com/sharedvolume/volume-syncer/internal/utils"
+)
+
+// Suffixes of the directories next to the target used by atomic syncs
+const (
+	stagingSuffix  = ".sync-staging"
+	previousSuffix = ".sync-previous"
+)
+
+// atomicSyncer runs the wrapped syncer against a staging directory next to the target and
+// swaps it into place once the sync completed, so that consumers never observe a partial sync
+type atomicSyncer struct {
+	syncer     Syncer
+	targetPath string
+	stagingDir string
+	seed       bool // copy the current target into the staging directory before the sync
+	resume     bool // continue in the staging directory left by an interrupted sync
+}
+
+// newAtomicSyncer wraps a syncer created for the staging directory of the target
+func newAtomicSyncer(syncer Syncer, targetPath, stagingDir string, seed, resume bool) *atomicSyncer {
+	return &atomicSyncer{
+		syncer:     syncer,
+		targetPath: targetPath,
+		stagingDir: stagingDir,
+		seed:       seed,
+		resume:     resume,
+	}
+}
+
+// stagingPath returns the staging directory next to the target. As a sibling of the target, it
+// is on the same filesystem and the swap is a rename.
+func stagingPath(targetPath string) string {
+	return filepath.Clean(targetPath) + stagingSuffix
+}
+
+// stagingPath returns the staging directory of the target, in the configured staging directory
+// when it is on the filesystem of the target and next to the target otherwise
+func (f *SyncerFactory) stagingPath(targetPath string) string {
+	if dir := f.dirs.StagingFor(targetPath); dir != "" {
+		return filepath.Join(dir, stagingName(targetPath))
+	}
+	return stagingPath(targetPath)
+}
+
+// stagingName names the staging directory of the target in the configured staging directory.
+// The name is derived from the target path, so that an interrupted sync finds it again.
+func stagingName(targetPath string) string {
+	sum := sha256.Sum256([]byte(filepath.Clean(targetPath)))
+	return filepath.Base(targetPath) + "-" + hex.EncodeToString(sum[:8]) + stagingSuffix
+}
+
+// Changed reports whether the wrapped syncer modified the target
+func (a *atomicSyncer) Changed() bool {
+	if reporter, ok := a.syncer.(interface{ Changed() bool }); ok {
+		return reporter.Changed()
+	}
+	return true
+}
+
+// Result returns the source details reported by the wrapped syncer
+func (a *atomicSyncer) Result() models.SyncResult {
+	if reporter, ok := a.syncer.(interface{ Result() models.SyncResult }); ok {
+		return reporter.Result()
+	}
+	return models.SyncResult{}
+}
+
+// Results returns the per-source results of the wrapped composite syncer
+func (a *atomicSyncer) Results() []models.SourceResult {
+	if reporter, ok := a.syncer.(interface{ Results() []models.SourceResult }); ok {
+		return reporter.Results()
+	}
+	return nil
+}
+
+// Sync seeds the staging directory with the current target, so that incremental syncers only
+// transfer changes, runs the wrapped syncer and replaces the target with the staging directory.
+// Mirroring syncs start from an empty staging directory, so that files the source no longer
+// provides disappear with the swap. A resumed sync continues in the staging directory it left.
+func (a *atomicSyncer) Sync(ctx context.Context) error {
+	log.Printf("[ATOMIC SYNC] Staging sync of %s in %s", a.targetPath, a.stagingDir)
+	if _, err := os.Stat(a.stagingDir); a.resume && err == nil {
+		log.Printf("[ATOMIC SYNC] Resuming interrupted sync in %s", a.stagingDir)
+		defer os.RemoveAll(a.stagingDir)
+		return a.run(ctx)
+	}
+	if err := os.RemoveAll(a.stagingDir); err != nil {
+		return fmt.Errorf("failed to remove stale staging directory: %w", err)
+	}
+	defer os.RemoveAll(a.stagingDir)
+
+	if !a.seed {
+		log.Printf("[ATOMIC SYNC] Staging directory starts empty, extraneous files of %s are deleted", a.targetPath)
+	} else if _, err := os.Stat(a.targetPath); err == nil {
+		if err := utils.CopyDir(a.targetPath, a.stagingDir); err != nil {
+			log.Printf("[ATOMIC SYNC] ERROR: Failed to seed staging directory: %v", err)
+			return fmt.Errorf("failed to seed staging directory: %w", err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to stat target: %w", err)
+	}
+	return a.run(ctx)
+}
+
+// run syncs into the prepared staging directory and swaps it into place
+func (a *atomicSyncer) run(ctx context.Context) error {
+	if err := a.syncer.Sync(ctx); err != nil {
+		log.Printf("[ATOMIC SYNC] Sync failed, target %s left unchanged", a.targetPath)
+		return err
+	}
+	if !a.Changed() {
+		log.Printf("[ATOMIC SYNC] Target unchanged, discarding staging directory")
+		return nil
+	}
+	return a.swap()
+}
+
+// swap moves the staging directory into place. The directories are exchanged in one step where
+// the filesystem supports it; otherwise the previous target is kept until the move succeeded.
+func (a *atomicSyncer) swap() error {
+	err := utils.ExchangePaths(a.stagingDir, a.targetPath)
+	if err == nil {
+		// The staging directory now holds the previous target and is removed
+		log.Printf("[ATOMIC SYNC] Target %s replaced atomically", a.targetPath)
+		return nil
+	}
+	if !errors.Is(err, errors.ErrUnsupported) && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("[ATOMIC SYNC] ERROR: Failed to exchange staging directory and target: %v", err)
+		return fmt.Errorf("failed to exchange staging directory and target, target left unchanged: %w", err)
+	}
+
+	previousDir := filepath.Clean(a.targetPath) + previousSuffix
+	if err := os.RemoveAll(previousDir); err != nil {
+		return fmt.Errorf("failed to remove stale previous target: %w", err)
+	}
+
+	hadTarget := true
+	if err := os.Rename(a.targetPath, previousDir); errors.Is(err, os.ErrNotExist) {
+		hadTarget = false
+	} else if err != nil {
+		// A mount point cannot be renamed; the volume has to be mounted above the target
+		log.Printf("[ATOMIC SYNC] ERROR: Failed to move target aside: %v", err)
+		return fmt.Errorf("failed to move target aside, target left unchanged: %w", err)
+	}
+
+	if err := os.Rename(a.stagingDir, a.targetPath); err != nil {
+		log.Printf("[ATOMIC SYNC] ERROR: Failed to move staging directory into place: %v", err)
+		if hadTarget {
+			if restoreErr := os.Rename(previousDir, a.targetPath); restoreErr != nil {
+				return fmt.Errorf("failed to move staging directory into place: %w, and failed to restore the previous target from %s: %v", err, previousDir, restoreErr)
+			}
+		}
+		return fmt.Errorf("failed to move staging directory into place, target left unchanged: %w", err)
+	}
+
+	if hadTarget {
+		if err := os.RemoveAll(previousDir); err != nil {
+			log.Printf("[ATOMIC SYNC] WARNING: Failed to remove previous target %s: %v", previousDir, err)
+		}
+	}
+	log.Printf("[ATOMIC SYNC] Target %s replaced atomically", a.targetPath)
+	return nil
+}
@@ -0,0 +1,91 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/sharedvolume/volume-syncer/internal/models"
+	"github.com/sharedvolume/volume-syncer/internal/syncer/git"
+	"github.com/sharedvolume/volume-syncer/internal/syncer/http"
+	"github.com/sharedvolume/volume-syncer/internal/syncer/s3"
+	"github.com/sharedvolume/volume-syncer/internal/syncer/ssh"
+)
+
+// BrowseSource validates the source details and lists the entries of the source at the relative
+// path, "" for the top level. At most limit+1 entries are returned, so that a truncated listing
+// can be told apart. Nothing is written to any target.
+func (f *SyncerFactory) BrowseSource(ctx context.Context, source models.Source, path string, limit int) ([]models.BrowseEntry, error) {
+	sourceType, ok := lookupSource(source.Type)
+	if !ok {
+		return nil, fmt.Errorf("unsupported source type: %s", source.Type)
+	}
+	validated, err := f.CredentialPlaceholders(source)
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidateSource(validated); err != nil {
+		return nil, err
+	}
+	if sourceType.Browse == nil {
+		return nil, fmt.Errorf("source type %s does not support browsing", source.Type)
+	}
+	if path != "" {
+		cleanPath, err := cleanRelativePath(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid browse path: %w", err)
+		}
+		path = cleanPath
+	}
+	details, err := f.sourceDetails(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+	return sourceType.Browse(f, ctx, details, path, limit)
+}
+
+func (f *SyncerFactory) browseSSH(ctx context.Context, details interface{}, path string, limit int) ([]models.BrowseEntry, error) {
+	sshDetails, err := parseSSHDetails(details)
+	if err != nil {
+		return nil, err
+	}
+	sshDetails.Proxy = f.contextProxy(ctx)
+	return ssh.NewSSHSyncer(sshDetails, "", f.timeout, f.strictHostKeys).Browse(ctx, path, limit)
+}
+
+func (f *SyncerFactory) browseGit(ctx context.Context, details interface{}, path string, _ int) ([]models.BrowseEntry, error) {
+	gitDetails, err := parseGitDetails(details)
+	if err != nil {
+		return nil, err
+	}
+	gitDetails.Proxy = f.contextProxy(ctx)
+	if len(gitDetails.Repos) > 0 {
+		return nil, errors.New("browsing lists the branches and tags of a single repository, repos are not supported")
+	}
+	if path != "" {
+		return nil, errors.New("browsing a git source lists its branches and tags, path is not supported")
+	}
+	return git.NewGitSyncer(gitDetails, "", f.timeout, f.gitOptions).Browse(ctx)
+}
+
+func (f *SyncerFactory) browseHTTP(ctx context.Context, details interface{}, path string, limit int) ([]models.BrowseEntry, error) {
+	httpDetails, err := parseHTTPDetails(details)
+	if err != nil {
+		return nil, err
+	}
+	httpDetails.Proxy = f.contextProxy(ctx)
+	return http.NewHTTPSyncer(httpDetails, "", f.timeout, nil, nil, f.transports).Browse(ctx, path, limit)
+}
+
+func (f *SyncerFactory) browseS3(ctx context.Context, details interface{}, path string, limit int) ([]models.BrowseEntry, error) {
+	s3Details, err := parseS3Details(details)
+	if err != nil {
+		return nil, err
+	}
+	s3Details.Proxy = f.contextProxy(ctx)
+	s3Syncer, err := s3.NewS3Syncer(s3Details, "", f.timeout, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return s3Syncer.Browse(ctx, path, limit)
+}
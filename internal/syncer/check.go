@@ -0,0 +1,89 @@
+package syncer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sharedvolume/volume-syncer/internal/checks"
+	"github.com/sharedvolume/volume-syncer/internal/models"
+	"github.com/sharedvolume/volume-syncer/internal/syncer/git"
+	"github.com/sharedvolume/volume-syncer/internal/syncer/http"
+	"github.com/sharedvolume/volume-syncer/internal/syncer/s3"
+	"github.com/sharedvolume/volume-syncer/internal/syncer/ssh"
+)
+
+// CheckSource validates the source details and runs the connection checks of its source type.
+// Nothing is written to any target.
+func (f *SyncerFactory) CheckSource(ctx context.Context, source models.Source) []models.SourceCheck {
+	var report checks.Report
+	var sourceType SourceType
+	var details interface{}
+	report.Run("details", func() (string, error) {
+		var ok bool
+		if sourceType, ok = lookupSource(source.Type); !ok {
+			return "", fmt.Errorf("unsupported source type: %s", source.Type)
+		}
+		validated, err := f.CredentialPlaceholders(source)
+		if err != nil {
+			return "", err
+		}
+		if err := ValidateSource(validated); err != nil {
+			return "", err
+		}
+		if details, err = f.sourceDetails(ctx, source); err != nil {
+			return "", err
+		}
+		if sourceType.Check == nil {
+			return "details are valid, the source type has no connection checks", nil
+		}
+		return "details are valid", nil
+	})
+	if report.Failed() || sourceType.Check == nil {
+		return report.Checks
+	}
+	return append(report.Checks, sourceType.Check(f, ctx, details)...)
+}
+
+func (f *SyncerFactory) checkSSH(ctx context.Context, details interface{}) []models.SourceCheck {
+	sshDetails, err := parseSSHDetails(details)
+	if err != nil {
+		return checks.Failed("details", err)
+	}
+	sshDetails.Proxy = f.contextProxy(ctx)
+	return ssh.NewSSHSyncer(sshDetails, "", f.timeout, f.strictHostKeys).Check(ctx)
+}
+
+func (f *SyncerFactory) checkGit(ctx context.Context, details interface{}) []models.SourceCheck {
+	gitDetails, err := parseGitDetails(details)
+	if err != nil {
+		return checks.Failed("details", err)
+	}
+	gitDetails.Proxy = f.contextProxy(ctx)
+	if len(gitDetails.Repos) > 0 {
+		return git.NewMultiGitSyncer(gitDetails, "", f.timeout, f.gitOptions).Check(ctx)
+	}
+	return git.NewGitSyncer(gitDetails, "", f.timeout, f.gitOptions).Check(ctx)
+}
+
+func (f *SyncerFactory) checkHTTP(ctx context.Context, details interface{}) []models.SourceCheck {
+	httpDetails, err := parseHTTPDetails(details)
+	if err != nil {
+		return checks.Failed("details", err)
+	}
+	httpDetails.Proxy = f.contextProxy(ctx)
+	return http.NewHTTPSyncer(httpDetails, "", f.timeout, nil, nil, f.transports).Check(ctx)
+}
+
+func (f *SyncerFactory) checkS3(ctx context.Context, details interface{}) []models.SourceCheck {
+	s3Details, err := parseS3Details(details)
+	if err != nil {
+		return checks.Failed("details", err)
+	}
+	s3Details.Proxy = f.contextProxy(ctx)
+	// Creating the syncer connects to the bucket, trying both path styles of S3-compatible services
+	s3Syncer, err := s3.NewS3Syncer(s3Details, "", f.timeout, nil, nil)
+	if err != nil {
+		return checks.Failed("bucket", err)
+	}
+	return s3Syncer.Check(ctx)
+}
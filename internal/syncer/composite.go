@@ -0,0 +1,174 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/sharedvolume/volume-syncer/internal/models"
+	"github.com/sharedvolume/volume-syncer/internal/utils"
+)
+
+// defaultCompositeParallelism is the number of sources synced concurrently when not configured
+const defaultCompositeParallelism = 4
+
+// compositeEntry is one source of a composite sync
+type compositeEntry struct {
+	path       string
+	sourceType string
+	syncer     Syncer
+}
+
+// CompositeSyncer syncs several sources into subdirectories of one target
+type CompositeSyncer struct {
+	entries     []compositeEntry
+	targetDir   string
+	parallelism int
+	changed     bool
+	results     []models.SourceResult
+}
+
+// CreateCompositeSyncer creates a syncer for each source, targeting its subdirectory of the target path.
+// Sources without their own filters or post-processing use the given options. Atomic and versioned
+// syncs stage all sources together, so that the target is replaced once every source completed.
+func (f *SyncerFactory) CreateCompositeSyncer(sources []models.SubSource, targetPath string, parallelism int, opts SourceOptions) (Syncer, error) {
+	if limit := f.quotaFor(targetPath, opts); opts.Atomic || opts.Versions != nil || limit != nil {
+		sourceOpts := opts
+		sourceOpts.Atomic, sourceOpts.Versions, sourceOpts.staged = false, nil, true
+		return f.stage(targetPath, opts, limit, func(stagingDir string) (Syncer, error) {
+			return f.CreateCompositeSyncer(sources, stagingDir, parallelism, sourceOpts)
+		})
+	}
+	log.Printf("[SYNCER FACTORY] Creating composite syncer with %d sources", len(sources))
+
+	if len(sources) == 0 {
+		return nil, errors.New("sources must contain at least one source")
+	}
+	if parallelism <= 0 {
+		parallelism = defaultCompositeParallelism
+	}
+
+	composite := &CompositeSyncer{
+		targetDir:   targetPath,
+		parallelism: parallelism,
+	}
+
+	seenPaths := make(map[string]bool)
+	for i, source := range sources {
+		cleanPath, err := cleanRelativePath(source.Path)
+		if err != nil {
+			return nil, fmt.Errorf("sources[%d]: invalid path %q: %w", i, source.Path, err)
+		}
+		for existing := range seenPaths {
+			if existing == cleanPath || strings.HasPrefix(cleanPath, existing+"/") || strings.HasPrefix(existing, cleanPath+"/") {
+				return nil, fmt.Errorf("sources[%d]: path %q overlaps with another source path", i, source.Path)
+			}
+		}
+		seenPaths[cleanPath] = true
+
+		sourceOpts := opts
+		if source.Filters != nil {
+			sourceOpts.Filters = source.Filters
+		}
+		if source.PostProcess != nil {
+			sourceOpts.PostProcess = source.PostProcess
+		}
+		sourceSyncer, err := f.CreateSyncer(source.Source, filepath.Join(targetPath, cleanPath), sourceOpts)
+		if err != nil {
+			return nil, fmt.Errorf("sources[%d]: %w", i, err)
+		}
+		composite.entries = append(composite.entries, compositeEntry{
+			path:       cleanPath,
+			sourceType: source.Type,
+			syncer:     sourceSyncer,
+		})
+	}
+
+	log.Printf("[SYNCER FACTORY] Composite syncer created successfully")
+	return composite, nil
+}
+
+// Changed reports whether the last Sync modified any source
+func (c *CompositeSyncer) Changed() bool {
+	return c.changed
+}
+
+// Results returns the per-source results of the last Sync
+func (c *CompositeSyncer) Results() []models.SourceResult {
+	return c.results
+}
+
+// Sync syncs every source with bounded parallelism
+func (c *CompositeSyncer) Sync(ctx context.Context) error {
+	c.changed = false
+	c.results = make([]models.SourceResult, len(c.entries))
+
+	log.Printf("[COMPOSITE SYNC] Starting sync of %d sources into %s (parallelism: %d)", len(c.entries), c.targetDir, c.parallelism)
+
+	if err := utils.EnsureDir(c.targetDir); err != nil {
+		log.Printf("[COMPOSITE SYNC] ERROR: Failed to create target directory: %v", err)
+		return fmt.Errorf("failed to create target directory: %w", err)
+	}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		errs    []error
+		workers = make(chan struct{}, c.parallelism)
+	)
+
+	for i := range c.entries {
+		entry := c.entries[i]
+
+		wg.Add(1)
+		workers <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-workers }()
+
+			log.Printf("[COMPOSITE SYNC] Syncing %s source into %s", entry.sourceType, entry.path)
+			err := entry.syncer.Sync(ctx)
+
+			changed := true
+			if reporter, ok := entry.syncer.(interface{ Changed() bool }); ok {
+				changed = reporter.Changed()
+			}
+
+			result := models.SourceResult{
+				Path:       entry.path,
+				SourceType: entry.sourceType,
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil || changed {
+				c.changed = true
+			}
+			if err != nil {
+				log.Printf("[COMPOSITE SYNC] ERROR: Source %s failed: %v", entry.path, err)
+				result.Status = models.JobStatusFailed
+				result.Error = err.Error()
+				errs = append(errs, fmt.Errorf("source %s: %w", entry.path, err))
+			} else {
+				log.Printf("[COMPOSITE SYNC] Source %s synced successfully", entry.path)
+				result.Status = models.JobStatusSucceeded
+				result.Changed = &changed
+			}
+			c.results[i] = result
+		}()
+	}
+
+	wg.Wait()
+
+	if len(errs) > 0 {
+		log.Printf("[COMPOSITE SYNC] ERROR: %d of %d sources failed", len(errs), len(c.entries))
+		return fmt.Errorf("%d of %d sources failed to sync: %w", len(errs), len(c.entries), errors.Join(errs...))
+	}
+
+	log.Printf("[COMPOSITE SYNC] All %d sources synced successfully", len(c.entries))
+	return nil
+}
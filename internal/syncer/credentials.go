@@ -0,0 +1,235 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+
+	"github.com/sharedvolume/volume-syncer/internal/credentials"
+	"github.com/sharedvolume/volume-syncer/internal/models"
+	"github.com/sharedvolume/volume-syncer/internal/secrets"
+	"github.com/sharedvolume/volume-syncer/internal/sshutil"
+	"github.com/sharedvolume/volume-syncer/internal/syncer/s3"
+)
+
+// CredentialPlaceholders checks the credential the source references and returns the source with
+// placeholders for the fields of the credential, so that validation sees them as set. Sources
+// without credentialRef are returned as they are.
+func (f *SyncerFactory) CredentialPlaceholders(source models.Source) (models.Source, error) {
+	if source.CredentialRef == "" {
+		return source, nil
+	}
+	if f.credentials == nil {
+		return source, fmt.Errorf("credentialRef cannot be used: %w", credentials.ErrNotConfigured)
+	}
+	credential, sourceType, err := f.referencedCredential(source)
+	if err != nil {
+		return source, err
+	}
+	data := make(map[string]string, len(credential.Fields))
+	for _, field := range credential.Fields {
+		data[field] = secrets.Placeholder
+	}
+	details, err := mergeCredential(source.Details, source.CredentialRef, data)
+	if err != nil {
+		return source, err
+	}
+	// The destinations of details with secret references are only known once the sync starts
+	if !hasReferences(source.Details) {
+		if err := allowedDestinations(sourceType, credential, details); err != nil {
+			return source, err
+		}
+	}
+	source.Details = details
+	return source, nil
+}
+
+// hasReferences reports whether the details hold secret references, counting invalid ones
+func hasReferences(details interface{}) bool {
+	found := false
+	_, err := secrets.Substitute(details, func(secrets.Reference) (string, error) {
+		found = true
+		return secrets.Placeholder, nil
+	})
+	return found || err != nil
+}
+
+// referencedCredential returns the credential the source references with the type of the source,
+// which must be able to name the destinations of the credential
+func (f *SyncerFactory) referencedCredential(source models.Source) (models.Credential, SourceType, error) {
+	credential, ok := f.credentials.Get(source.CredentialRef)
+	if !ok {
+		return credential, SourceType{}, fmt.Errorf("credential %q not found", source.CredentialRef)
+	}
+	if len(credential.AllowedHosts) == 0 {
+		return credential, SourceType{}, fmt.Errorf("credential %q has no allowedHosts and cannot be used until they are set", source.CredentialRef)
+	}
+	sourceType, ok := lookupSource(source.Type)
+	if !ok {
+		return credential, SourceType{}, fmt.Errorf("unsupported source type: %s", source.Type)
+	}
+	if sourceType.Destinations == nil {
+		return credential, SourceType{}, fmt.Errorf("%s sources cannot reference credentials", source.Type)
+	}
+	return credential, sourceType, nil
+}
+
+// sourceDetails returns the details the syncer of the source is created from: the fields of the
+// credential the source references are added and the secret references are resolved. The
+// destinations of the resolved details must be allowed by the credential. The details of the
+// request are left untouched.
+func (f *SyncerFactory) sourceDetails(ctx context.Context, source models.Source) (interface{}, error) {
+	details := source.Details
+	var credential models.Credential
+	var sourceType SourceType
+	if source.CredentialRef != "" {
+		if f.credentials == nil {
+			return nil, fmt.Errorf("credentialRef cannot be used: %w", credentials.ErrNotConfigured)
+		}
+		var err error
+		if credential, sourceType, err = f.referencedCredential(source); err != nil {
+			return nil, err
+		}
+		data, err := f.credentials.Data(ctx, source.CredentialRef)
+		if err != nil {
+			log.Printf("[SYNCER FACTORY] ERROR: Failed to load credential %s: %v", source.CredentialRef, err)
+			return nil, fmt.Errorf("failed to load credential %q: %w", source.CredentialRef, err)
+		}
+		log.Printf("[SYNCER FACTORY] Using credential %s", source.CredentialRef)
+		if details, err = mergeCredential(details, source.CredentialRef, data); err != nil {
+			return nil, err
+		}
+	}
+
+	details, err := f.references.Resolve(ctx, details)
+	if err != nil {
+		log.Printf("[SYNCER FACTORY] ERROR: Failed to resolve secret references: %v", err)
+		return nil, fmt.Errorf("failed to resolve secret reference: %w", err)
+	}
+	if source.CredentialRef != "" {
+		if err := allowedDestinations(sourceType, credential, details); err != nil {
+			log.Printf("[SYNCER FACTORY] ERROR: %v", err)
+			return nil, err
+		}
+	}
+	return details, nil
+}
+
+// allowedDestinations checks that the credential allows every destination of the details
+func allowedDestinations(sourceType SourceType, credential models.Credential, details interface{}) error {
+	destinations, err := sourceType.Destinations(details)
+	if err != nil {
+		return err
+	}
+	for _, destination := range destinations {
+		if !credentials.Allows(credential.AllowedHosts, destination) {
+			return fmt.Errorf("credential %q is not allowed for %s, its allowedHosts are %s", credential.ID, destination, strings.Join(credential.AllowedHosts, ", "))
+		}
+	}
+	return nil
+}
+
+func destinationsSSH(details interface{}) ([]string, error) {
+	sshDetails, err := parseSSHDetails(details)
+	if err != nil {
+		return nil, err
+	}
+	return []string{destination(sshDetails.Host, sshDetails.Path)}, nil
+}
+
+func destinationsGit(details interface{}) ([]string, error) {
+	gitDetails, err := parseGitDetails(details)
+	if err != nil {
+		return nil, err
+	}
+	var destinations []string
+	urls := []string{gitDetails.URL}
+	for _, repo := range gitDetails.Repos {
+		urls = append(urls, repo.URL)
+	}
+	for _, repoURL := range urls {
+		if repoURL == "" {
+			continue
+		}
+		repoDestination, err := urlDestination(repoURL)
+		if err != nil {
+			return nil, err
+		}
+		destinations = append(destinations, repoDestination)
+	}
+	return destinations, nil
+}
+
+func destinationsHTTP(details interface{}) ([]string, error) {
+	httpDetails, err := parseHTTPDetails(details)
+	if err != nil {
+		return nil, err
+	}
+	httpDestination, err := urlDestination(httpDetails.URL)
+	if err != nil {
+		return nil, err
+	}
+	return []string{httpDestination}, nil
+}
+
+func destinationsS3(details interface{}) ([]string, error) {
+	s3Details, err := parseS3Details(details)
+	if err != nil {
+		return nil, err
+	}
+	endpoint, _ := s3.ProbeEndpoint(s3Details)
+	s3Destination, err := urlDestination(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	host, _, _ := strings.Cut(s3Destination, "/")
+	return []string{destination(host, s3Details.BucketName)}, nil
+}
+
+// urlDestination returns the host and path of a URL or of a scp-like Git address such as
+// git@github.com:acme/app.git
+func urlDestination(raw string) (string, error) {
+	if !strings.Contains(raw, "://") {
+		if host, _, ok := sshutil.ParseGitSSHEndpoint(raw); ok {
+			_, repoPath, _ := strings.Cut(raw, ":")
+			return destination(host, repoPath), nil
+		}
+	}
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Hostname() == "" {
+		return "", fmt.Errorf("%q names no host a credential can be sent to", raw)
+	}
+	return destination(parsed.Hostname(), parsed.Path), nil
+}
+
+// destination returns the destination of a host and a path, e.g. github.com/acme/app.git
+func destination(host, p string) string {
+	host = strings.ToLower(host)
+	if p = strings.Trim(p, "/"); p == "" {
+		return host
+	}
+	return host + "/" + p
+}
+
+// mergeCredential returns a copy of the details with the fields of the credential, which must not
+// be set in the details as well
+func mergeCredential(details interface{}, id string, data map[string]string) (interface{}, error) {
+	detailsMap, ok := details.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("credentialRef requires the details to be an object")
+	}
+	merged := make(map[string]interface{}, len(detailsMap)+len(data))
+	for key, value := range detailsMap {
+		merged[key] = value
+	}
+	for field, value := range data {
+		if _, ok := merged[field]; ok {
+			return nil, fmt.Errorf("details field %s is also provided by credential %q", field, id)
+		}
+		merged[field] = value
+	}
+	return merged, nil
+}
@@ -0,0 +1,160 @@
+package syncer
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sharedvolume/volume-syncer/internal/credentials"
+	"github.com/sharedvolume/volume-syncer/internal/models"
+	"github.com/sharedvolume/volume-syncer/internal/secrets"
+)
+
+// credentialFactory returns a factory whose store holds a Git token allowed for github.com/acme,
+// an SSH password allowed for backup.internal and S3 keys allowed for the backups bucket of
+// minio.internal
+func credentialFactory(t *testing.T) *SyncerFactory {
+	t.Helper()
+	sealer, err := credentials.NewLocalSealer(bytes.Repeat([]byte{1}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := credentials.New("", sealer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, credential := range []models.Credential{
+		{ID: "github", Data: map[string]string{"token": "ghp_secret"}, AllowedHosts: []string{"github.com/acme"}},
+		{ID: "backup", Data: map[string]string{"password": "secret"}, AllowedHosts: []string{"backup.internal"}},
+		{ID: "minio", Data: map[string]string{"accessKey": "key", "secretKey": "secret"}, AllowedHosts: []string{"minio.internal/backups"}},
+	} {
+		if _, err := store.Create(context.Background(), credential); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &SyncerFactory{credentials: store, references: secrets.NewResolver("SYNC_TEST_*", "", "", "")}
+}
+
+func TestCredentialAllowedHosts(t *testing.T) {
+	s3Details := func(endpoint, bucket string) map[string]interface{} {
+		return map[string]interface{}{"endpointUrl": endpoint, "bucketName": bucket, "path": "/", "region": "us-east-1"}
+	}
+
+	tests := []struct {
+		name    string
+		source  models.Source
+		env     string // value of SYNC_TEST_URL
+		errText string // contained in the error, "" when the credential is added
+		atStart bool   // the destination is only known once the sync starts, not when the request is validated
+	}{
+		{
+			name:   "git repository of the allowed organization",
+			source: models.Source{Type: "git", CredentialRef: "github", Details: map[string]interface{}{"url": "https://github.com/acme/app.git"}},
+		},
+		{
+			name:   "scp-like git address of the allowed organization",
+			source: models.Source{Type: "git", CredentialRef: "github", Details: map[string]interface{}{"url": "git@GitHub.com:acme/app.git"}},
+		},
+		{
+			name:    "git repository of another host",
+			source:  models.Source{Type: "git", CredentialRef: "github", Details: map[string]interface{}{"url": "https://gitlab.com/acme/app.git"}},
+			errText: `credential "github" is not allowed for gitlab.com/acme/app.git`,
+		},
+		{
+			name:    "git repository of another organization",
+			source:  models.Source{Type: "git", CredentialRef: "github", Details: map[string]interface{}{"url": "https://github.com/other/app.git"}},
+			errText: `credential "github" is not allowed for github.com/other/app.git`,
+		},
+		{
+			name:    "git host with the allowed host as prefix",
+			source:  models.Source{Type: "git", CredentialRef: "github", Details: map[string]interface{}{"url": "https://github.com.evil.example/acme/app.git"}},
+			errText: "is not allowed for github.com.evil.example/acme/app.git",
+		},
+		{
+			name:    "scp-like git address of another host",
+			source:  models.Source{Type: "git", CredentialRef: "github", Details: map[string]interface{}{"url": "git@evil.example:acme/app.git"}},
+			errText: "is not allowed for evil.example/acme/app.git",
+		},
+		{
+			name: "one of several git repositories on another host",
+			source: models.Source{Type: "git", CredentialRef: "github", Details: map[string]interface{}{"repos": []interface{}{
+				map[string]interface{}{"url": "https://github.com/acme/app.git", "path": "app"},
+				map[string]interface{}{"url": "https://evil.example/acme/lib.git", "path": "lib"},
+			}}},
+			errText: "is not allowed for evil.example/acme/lib.git",
+		},
+		{
+			name:   "ssh host allowed",
+			source: models.Source{Type: "ssh", CredentialRef: "backup", Details: map[string]interface{}{"host": "backup.internal", "user": "sync", "path": "/data"}},
+		},
+		{
+			name:    "ssh host not allowed",
+			source:  models.Source{Type: "ssh", CredentialRef: "backup", Details: map[string]interface{}{"host": "evil.internal", "user": "sync", "path": "/data"}},
+			errText: `credential "backup" is not allowed for evil.internal/data`,
+		},
+		{
+			name:   "s3 bucket allowed",
+			source: models.Source{Type: "s3", CredentialRef: "minio", Details: s3Details("https://minio.internal", "backups")},
+		},
+		{
+			name:    "s3 bucket not allowed",
+			source:  models.Source{Type: "s3", CredentialRef: "minio", Details: s3Details("https://minio.internal", "other")},
+			errText: `credential "minio" is not allowed for minio.internal/other`,
+		},
+		{
+			name:    "s3 endpoint not allowed",
+			source:  models.Source{Type: "s3", CredentialRef: "minio", Details: s3Details("https://evil.example", "backups")},
+			errText: `credential "minio" is not allowed for evil.example/backups`,
+		},
+		{
+			name:    "referenced URL of another host",
+			source:  models.Source{Type: "git", CredentialRef: "github", Details: map[string]interface{}{"url": map[string]interface{}{"fromEnv": "SYNC_TEST_URL"}}},
+			env:     "https://evil.example/acme/app.git",
+			errText: "is not allowed for evil.example/acme/app.git",
+			atStart: true,
+		},
+		{
+			name:    "field also set in the details",
+			source:  models.Source{Type: "git", CredentialRef: "github", Details: map[string]interface{}{"url": "https://github.com/acme/app.git", "token": "other"}},
+			errText: `details field token is also provided by credential "github"`,
+		},
+		{
+			name:    "unknown credential",
+			source:  models.Source{Type: "git", CredentialRef: "missing", Details: map[string]interface{}{"url": "https://github.com/acme/app.git"}},
+			errText: `credential "missing" not found`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SYNC_TEST_URL", tt.env)
+			f := credentialFactory(t)
+
+			details, err := f.sourceDetails(context.Background(), tt.source)
+			if tt.errText != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errText) {
+					t.Fatalf("sourceDetails() error = %v, want one containing %q", err, tt.errText)
+				}
+				// The request is refused before it is accepted unless the destination is referenced
+				if _, err := f.CredentialPlaceholders(tt.source); (err == nil) != tt.atStart {
+					t.Errorf("CredentialPlaceholders() error = %v, want an error %v", err, !tt.atStart)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("sourceDetails() unexpected error: %v", err)
+			}
+			credential, _ := f.credentials.Get(tt.source.CredentialRef)
+			for _, field := range credential.Fields {
+				if value, _ := details.(map[string]interface{})[field].(string); value == "" || value == secrets.Placeholder {
+					t.Errorf("details field %s = %q, want the stored value", field, value)
+				}
+			}
+
+			if _, err := f.CredentialPlaceholders(tt.source); err != nil {
+				t.Errorf("CredentialPlaceholders() unexpected error: %v", err)
+			}
+		})
+	}
+}
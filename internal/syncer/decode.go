@@ -0,0 +1,39 @@
+package syncer
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/sharedvolume/volume-syncer/internal/models"
+	"github.com/sharedvolume/volume-syncer/internal/secrets"
+	"github.com/sharedvolume/volume-syncer/internal/validation"
+)
+
+// DecodeDetails decodes the details of the source strictly into the typed details of its source
+// type and checks their required fields. Fields are reported relative to the details. Source types
+// without typed details, such as plugins, are left to Validate.
+func DecodeDetails(source models.Source) []models.FieldError {
+	sourceType, ok := lookupSource(source.Type)
+	if !ok {
+		return []models.FieldError{{Field: "type", Message: fmt.Sprintf("unsupported source type %q, supported: %v", source.Type, SourceTypes())}}
+	}
+	if sourceType.Details == nil {
+		return nil
+	}
+	if _, ok := source.Details.(map[string]interface{}); !ok {
+		return []models.FieldError{{Field: "details", Message: "must be an object"}}
+	}
+	details, err := secrets.Placeholders(source.Details)
+	if err != nil {
+		return []models.FieldError{{Field: "details", Message: "invalid secret reference: " + err.Error()}}
+	}
+	data, err := json.Marshal(details)
+	if err != nil {
+		return []models.FieldError{{Field: "details", Message: err.Error()}}
+	}
+	typed := sourceType.Details()
+	if fieldErrs := validation.Decode(data, typed); len(fieldErrs) > 0 {
+		return validation.Prefix("details", fieldErrs)
+	}
+	return validation.Prefix("details", validation.Struct(typed))
+}
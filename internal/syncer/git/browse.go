@@ -0,0 +1,103 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/sharedvolume/volume-syncer/internal/models"
+)
+
+// Browse lists the branches and tags of the repository with git ls-remote, marking the branch
+// the remote HEAD points to. Tags report the commit they point to.
+func (g *GitSyncer) Browse(ctx context.Context) ([]models.BrowseEntry, error) {
+	if err := g.validate(); err != nil {
+		return nil, err
+	}
+	cleanup, err := g.setupCredentials()
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	if _, err := g.prepareCredentials(); err != nil {
+		return nil, err
+	}
+
+	cmdCtx, cancel := context.WithTimeout(ctx, g.timeout)
+	defer cancel()
+	output, err := g.gitCommand(cmdCtx, "ls-remote", "--symref", g.details.URL).Output()
+	if err != nil {
+		if cmdCtx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("git ls-remote timed out after %v", g.timeout)
+		}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			err = commandError(err, string(exitErr.Stderr))
+			if stderr := strings.TrimSpace(string(exitErr.Stderr)); stderr != "" {
+				err = fmt.Errorf("%w: %s", err, maskCredentials(lastLine(stderr)))
+			}
+		}
+		return nil, fmt.Errorf("git ls-remote failed: %w", err)
+	}
+	return parseRemoteRefs(string(output)), nil
+}
+
+// parseRemoteRefs converts the output of git ls-remote --symref into branch and tag entries,
+// branches first, each sorted by name
+func parseRemoteRefs(output string) []models.BrowseEntry {
+	var defaultRef string
+	entries := map[string]*models.BrowseEntry{}
+	for _, line := range strings.Split(output, "\n") {
+		value, ref, ok := strings.Cut(strings.TrimSpace(line), "\t")
+		if !ok {
+			continue
+		}
+		if target, isSymref := strings.CutPrefix(value, "ref: "); isSymref {
+			if ref == "HEAD" {
+				defaultRef = target
+			}
+			continue
+		}
+
+		// Annotated tags are followed by the commit they point to
+		ref, peeled := strings.CutSuffix(ref, "^{}")
+		var entry models.BrowseEntry
+		if name, isBranch := strings.CutPrefix(ref, "refs/heads/"); isBranch {
+			entry = models.BrowseEntry{Name: name, Type: models.BrowseEntryBranch}
+		} else if name, isTag := strings.CutPrefix(ref, "refs/tags/"); isTag {
+			entry = models.BrowseEntry{Name: name, Type: models.BrowseEntryTag}
+		} else {
+			continue
+		}
+		if existing, seen := entries[ref]; seen {
+			if peeled {
+				existing.Revision = value
+			}
+			continue
+		}
+		entry.Revision = value
+		entry.Default = ref == defaultRef
+		entries[ref] = &entry
+	}
+
+	list := make([]models.BrowseEntry, 0, len(entries))
+	for _, entry := range entries {
+		list = append(list, *entry)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Type != list[j].Type {
+			return list[i].Type == models.BrowseEntryBranch
+		}
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+// lastLine returns the last line of the output of a command
+func lastLine(output string) string {
+	lines := strings.Split(output, "\n")
+	return lines[len(lines)-1]
+}
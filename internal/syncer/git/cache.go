@@ -0,0 +1,84 @@
+package git
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/sharedvolume/volume-syncer/internal/metrics"
+)
+
+// cacheLocks serializes updates of the same reference repository across concurrent jobs
+var cacheLocks sync.Map
+
+// cacheRepoPath returns the reference repository used for the configured repository URL
+func (g *GitSyncer) cacheRepoPath() string {
+	sum := sha256.Sum256([]byte(stripURLCredentials(g.details.URL)))
+	return filepath.Join(g.options.CacheDir, hex.EncodeToString(sum[:8])+".git")
+}
+
+// prepareCache updates the shared reference repository for this source and returns its path.
+// An empty string is returned when the cache is disabled or cannot be used; the clone then
+// proceeds without borrowing objects.
+func (g *GitSyncer) prepareCache(ctx context.Context, repoURL, branch string) string {
+	if g.options.CacheDir == "" {
+		return ""
+	}
+	if g.details.Filter != "" {
+		log.Printf("[GIT SYNC] Partial clone filter set, not using the object cache")
+		return ""
+	}
+
+	cachePath := g.cacheRepoPath()
+	lock, _ := cacheLocks.LoadOrStore(cachePath, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	log.Printf("[GIT SYNC] Updating object cache at %s", cachePath)
+	if err := g.updateCache(ctx, cachePath, repoURL, branch); err != nil {
+		log.Printf("[GIT SYNC] WARNING: Failed to update object cache, cloning without it: %v", err)
+		return ""
+	}
+	log.Printf("[GIT SYNC] Object cache updated successfully")
+	return cachePath
+}
+
+// updateCache initializes the bare reference repository if needed and fetches the requested branch into it
+func (g *GitSyncer) updateCache(ctx context.Context, cachePath, repoURL, branch string) error {
+	ctx, cancel := context.WithTimeout(ctx, g.timeout)
+	defer cancel()
+
+	if _, err := os.Stat(filepath.Join(cachePath, "HEAD")); err != nil {
+		if err := os.MkdirAll(g.options.CacheDir, 0755); err != nil {
+			return fmt.Errorf("failed to create cache directory: %w", err)
+		}
+		if output, err := g.gitCommand(ctx, "init", "--bare", "--quiet", cachePath).CombinedOutput(); err != nil {
+			return fmt.Errorf("git init failed: %w, output: %s", err, string(output))
+		}
+	}
+
+	// Refs are kept under refs/cache so that fetched objects are not pruned
+	refspec := "+HEAD:refs/cache/default"
+	if branch != "" {
+		refspec = fmt.Sprintf("+refs/heads/%s:refs/cache/heads/%s", branch, branch)
+	}
+
+	// The cache always holds full history since git refuses shallow reference repositories.
+	// The URL is passed on the command line only, so credentials are never stored in the cache.
+	fetchArgs := []string{"-C", cachePath, "fetch", "--quiet", "--no-tags", repoURL, refspec}
+
+	output, err := g.gitCommand(ctx, fetchArgs...).CombinedOutput()
+	metrics.CommandExited(ctx, "git", err)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("git fetch timed out after %v", g.timeout)
+		}
+		return fmt.Errorf("git fetch failed: %w, output: %s", err, maskCredentials(string(output)))
+	}
+	return nil
+}
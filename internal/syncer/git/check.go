@@ -0,0 +1,113 @@
+package git
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/sharedvolume/volume-syncer/internal/checks"
+	"github.com/sharedvolume/volume-syncer/internal/models"
+	"github.com/sharedvolume/volume-syncer/internal/utils"
+)
+
+// Check verifies the credentials and resolves the branch with git ls-remote, without fetching
+// any objects
+func (g *GitSyncer) Check(ctx context.Context) []models.SourceCheck {
+	var report checks.Report
+
+	cleanup := utils.NoCleanup
+	report.Run("credentials", func() (string, error) {
+		if err := g.validate(); err != nil {
+			return "", err
+		}
+		setupCleanup, err := g.setupCredentials()
+		if err != nil {
+			return "", err
+		}
+		cleanup = setupCleanup
+		if _, err := g.prepareCredentials(); err != nil {
+			return "", err
+		}
+		return g.authMethod(), nil
+	})
+	defer cleanup()
+
+	report.Run("remote", func() (string, error) {
+		head, err := g.remoteHead(ctx, g.details.URL, g.details.Branch)
+		if err != nil {
+			return "", err
+		}
+		if g.details.Branch == "" {
+			return fmt.Sprintf("default branch of %s at %s", maskCredentials(g.details.URL), head), nil
+		}
+		return fmt.Sprintf("branch %s of %s at %s", g.details.Branch, maskCredentials(g.details.URL), head), nil
+	})
+
+	return report.Checks
+}
+
+// Revision returns the commit the branch, or the default branch, of the remote points to, without
+// fetching any objects
+func (g *GitSyncer) Revision(ctx context.Context) (string, error) {
+	if err := g.validate(); err != nil {
+		return "", err
+	}
+	cleanup, err := g.setupCredentials()
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+	if _, err := g.prepareCredentials(); err != nil {
+		return "", err
+	}
+	return g.remoteHead(ctx, g.details.URL, g.details.Branch)
+}
+
+// authMethod describes how the repository is accessed
+func (g *GitSyncer) authMethod() string {
+	if g.details.PrivateKey != "" {
+		return "SSH private key"
+	}
+	if user, _, ok := g.httpCredentials(); ok {
+		return "HTTP(S) credentials of user " + user
+	}
+	for _, env := range g.env {
+		if strings.HasPrefix(env, "SSH_AUTH_SOCK=") {
+			return "ssh-agent"
+		}
+	}
+	return "no credentials provided"
+}
+
+// Check checks every repository, prefixing the check names with the repository path
+func (m *MultiGitSyncer) Check(ctx context.Context) []models.SourceCheck {
+	var results []models.SourceCheck
+	for i := range m.details.Repos {
+		repo := m.details.Repos[i]
+		repoDetails := mergeRepoDefaults(m.details, &repo.GitCloneDetails)
+		repoSyncer := NewGitSyncer(repoDetails, filepath.Join(m.targetDir, repo.Path), m.timeout, m.options)
+		for _, result := range repoSyncer.Check(ctx) {
+			result.Name = repo.Path + "/" + result.Name
+			results = append(results, result)
+		}
+	}
+	return results
+}
+
+// Revision returns a digest of the commits the repositories point to
+func (m *MultiGitSyncer) Revision(ctx context.Context) (string, error) {
+	digest := sha256.New()
+	for i := range m.details.Repos {
+		repo := m.details.Repos[i]
+		repoDetails := mergeRepoDefaults(m.details, &repo.GitCloneDetails)
+		head, err := NewGitSyncer(repoDetails, "", m.timeout, m.options).Revision(ctx)
+		if err != nil {
+			return "", fmt.Errorf("repository %s: %w", repo.Path, err)
+		}
+		fmt.Fprintf(digest, "%s %s\n", repo.Path, head)
+	}
+	return "sha256:" + hex.EncodeToString(digest.Sum(nil)), nil
+}
@@ -0,0 +1,168 @@
+package git
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ExportManifestFile is written to the target root in export mode to record what was exported
+const ExportManifestFile = ".volume-syncer-export.json"
+
+// ExportManifest describes the revision materialized by an export
+type ExportManifest struct {
+	Repository string    `json:"repository"`
+	Branch     string    `json:"branch,omitempty"`
+	Commit     string    `json:"commit"`
+	ExportedAt time.Time `json:"exportedAt"`
+}
+
+// exportRepo produces a worktree-only copy of the repository in the target directory
+func (g *GitSyncer) exportRepo(ctx context.Context, branch string) error {
+	entries, err := os.ReadDir(g.targetDir)
+	if err != nil {
+		log.Printf("[GIT SYNC] ERROR: Failed to read target directory: %v", err)
+		return fmt.Errorf("failed to read target directory %s: %w", g.targetDir, err)
+	}
+
+	if g.exportIsCurrent(ctx, branch) {
+		log.Printf("[GIT SYNC] Exported revision matches remote head, skipping export")
+		g.changed = false
+		return nil
+	}
+
+	if len(entries) > 0 {
+		log.Printf("[GIT SYNC] Target directory is not empty (%d entries), exporting via temporary location", len(entries))
+		return g.safeCloneWithReplace(ctx, branch)
+	}
+
+	log.Printf("[GIT SYNC] Target directory is empty, exporting directly")
+	if err := g.cloneRepo(ctx, branch); err != nil {
+		return err
+	}
+	return g.finalizeExport(ctx, branch)
+}
+
+// exportIsCurrent reports whether the export manifest in the target already records the remote head
+func (g *GitSyncer) exportIsCurrent(ctx context.Context, branch string) bool {
+	data, err := os.ReadFile(filepath.Join(g.targetDir, ExportManifestFile))
+	if err != nil {
+		return false
+	}
+
+	var manifest ExportManifest
+	if err := json.Unmarshal(data, &manifest); err != nil || manifest.Commit == "" {
+		return false
+	}
+	if stripURLCredentials(manifest.Repository) != stripURLCredentials(g.details.URL) {
+		return false
+	}
+
+	cleanup, err := g.setupCredentials()
+	if err != nil {
+		return false
+	}
+	defer cleanup()
+
+	repoURL, err := g.prepareCredentials()
+	if err != nil {
+		return false
+	}
+
+	remote, err := g.remoteHead(ctx, repoURL, branch)
+	if err != nil {
+		log.Printf("[GIT SYNC] WARNING: Failed to resolve remote head, performing full export: %v", err)
+		return false
+	}
+	log.Printf("[GIT SYNC] Remote head: %s, exported commit: %s", remote, manifest.Commit)
+	return remote == manifest.Commit
+}
+
+// finalizeExport records the exported revision and removes all Git metadata from the worktree
+func (g *GitSyncer) finalizeExport(ctx context.Context, branch string) error {
+	log.Printf("[GIT SYNC] Finalizing export in %s", g.targetDir)
+
+	ctx, cancel := context.WithTimeout(ctx, g.timeout)
+	defer cancel()
+
+	commitOutput, err := g.gitCommand(ctx, "-C", g.targetDir, "rev-parse", "HEAD").Output()
+	if err != nil {
+		log.Printf("[GIT SYNC] ERROR: Failed to resolve exported commit: %v", err)
+		return fmt.Errorf("failed to resolve exported commit: %w", err)
+	}
+
+	if branch == "" {
+		if branchOutput, err := g.gitCommand(ctx, "-C", g.targetDir, "branch", "--show-current").Output(); err == nil {
+			branch = strings.TrimSpace(string(branchOutput))
+		}
+	}
+
+	manifest := ExportManifest{
+		Repository: stripURLCredentials(g.details.URL),
+		Branch:     branch,
+		Commit:     strings.TrimSpace(string(commitOutput)),
+		ExportedAt: time.Now().UTC(),
+	}
+
+	// Remove the object database and any submodule gitfiles
+	if err := removeGitMetadata(g.targetDir); err != nil {
+		log.Printf("[GIT SYNC] ERROR: Failed to remove Git metadata: %v", err)
+		return fmt.Errorf("failed to remove Git metadata: %w", err)
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode export manifest: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(g.targetDir, ExportManifestFile), append(data, '\n'), 0644); err != nil {
+		log.Printf("[GIT SYNC] ERROR: Failed to write export manifest: %v", err)
+		return fmt.Errorf("failed to write export manifest: %w", err)
+	}
+
+	log.Printf("[GIT SYNC] Export completed: commit %s", manifest.Commit)
+	return nil
+}
+
+// removeGitMetadata deletes every .git directory or gitfile below root
+func removeGitMetadata(root string) error {
+	var gitPaths []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Name() == ".git" {
+			gitPaths = append(gitPaths, path)
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, path := range gitPaths {
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stripURLCredentials removes user information from a repository URL
+func stripURLCredentials(repoURL string) string {
+	parsedURL, err := url.Parse(repoURL)
+	if err != nil || parsedURL.User == nil || parsedURL.Scheme == "" {
+		return repoURL
+	}
+	parsedURL.User = nil
+	return parsedURL.String()
+}
@@ -0,0 +1,102 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/sharedvolume/volume-syncer/internal/filter"
+	"github.com/sharedvolume/volume-syncer/internal/models"
+)
+
+// symlinkMode is the tree entry mode of symbolic links
+const symlinkMode = "120000"
+
+// filteredCheckout reports whether the worktree is restricted to the files selected by
+// configureFilteredCheckout, for the file filters or to leave out symbolic links
+func (g *GitSyncer) filteredCheckout() bool {
+	return g.details.Filters != nil || g.skipSymlinks()
+}
+
+// skipSymlinks reports whether symbolic links are left out of the worktree
+func (g *GitSyncer) skipSymlinks() bool {
+	return g.details.FileHandling != nil && g.details.FileHandling.Symlinks == models.SymlinksSkip
+}
+
+// configureFilteredCheckout restricts the worktree to the files of HEAD selected by the filters.
+// Every selected file is listed in a non-cone sparse checkout, so that globs, regular expressions
+// and the file size limit behave exactly as for other source types.
+func (g *GitSyncer) configureFilteredCheckout(ctx context.Context) error {
+	matcher, err := filter.New(g.details.Filters)
+	if err != nil {
+		return fmt.Errorf("invalid filters: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, g.timeout)
+	defer cancel()
+
+	// Sparse paths, when configured, limit the files the filters are applied to
+	lsArgs := []string{"-C", g.targetDir, "ls-tree", "-r", "-l", "-z", "HEAD"}
+	if len(g.details.Paths) > 0 {
+		lsArgs = append(append(lsArgs, "--"), g.details.Paths...)
+	}
+	output, err := g.gitCommand(ctx, lsArgs...).Output()
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("git ls-tree timed out after %v", g.timeout)
+		}
+		return fmt.Errorf("git ls-tree failed: %w", err)
+	}
+
+	var patterns []string
+	total := 0
+	for _, entry := range bytes.Split(output, []byte{0}) {
+		// Entries have the form "<mode> <type> <object> <size>\t<path>"
+		meta, path, ok := strings.Cut(string(entry), "\t")
+		if !ok {
+			continue
+		}
+		total++
+		if strings.HasPrefix(meta, symlinkMode+" ") && g.skipSymlinks() {
+			continue
+		}
+		fields := strings.Fields(meta)
+		var size int64
+		if len(fields) == 4 {
+			size, _ = strconv.ParseInt(fields[3], 10, 64) // "-" for submodule entries
+		}
+		if matcher.Match(path, size) {
+			patterns = append(patterns, sparsePattern(path))
+		}
+	}
+
+	log.Printf("[GIT SYNC] Configuring filtered sparse checkout: %d of %d files selected", len(patterns), total)
+	cmd := g.gitCommand(ctx, "-C", g.targetDir, "sparse-checkout", "set", "--no-cone", "--stdin")
+	cmd.Stdin = strings.NewReader(strings.Join(patterns, "\n") + "\n")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		log.Printf("[GIT SYNC] ERROR: Git sparse-checkout set failed: %v", err)
+		return fmt.Errorf("git sparse-checkout set failed: %w, output: %s", err, string(output))
+	}
+	log.Printf("[GIT SYNC] Filtered sparse checkout configured successfully")
+	return nil
+}
+
+// sparsePattern returns a non-cone sparse checkout pattern matching exactly the given path
+func sparsePattern(path string) string {
+	trimmed := strings.TrimRight(path, " ")
+
+	var b strings.Builder
+	b.WriteByte('/')
+	for _, r := range trimmed {
+		if strings.ContainsRune(`*?[\`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	// Trailing spaces are ignored in patterns unless escaped
+	b.WriteString(strings.Repeat(`\ `, len(path)-len(trimmed)))
+	return b.String()
+}
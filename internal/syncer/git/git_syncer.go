@@ -1,20 +1,37 @@
 package git
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
+	gogit "github.com/go-git/go-git/v5"
+
+	"github.com/sharedvolume/volume-syncer/internal/metrics"
 	"github.com/sharedvolume/volume-syncer/internal/models"
+	"github.com/sharedvolume/volume-syncer/internal/procgroup"
+	"github.com/sharedvolume/volume-syncer/internal/progress"
+	"github.com/sharedvolume/volume-syncer/internal/proxy"
+	"github.com/sharedvolume/volume-syncer/internal/retry"
+	"github.com/sharedvolume/volume-syncer/internal/sshutil"
 	"github.com/sharedvolume/volume-syncer/internal/utils"
+	"github.com/sharedvolume/volume-syncer/internal/workdirs"
+	syncerrors "github.com/sharedvolume/volume-syncer/pkg/errors"
 )
 
 // GitSyncer handles git-based synchronization
@@ -22,6 +39,23 @@ type GitSyncer struct {
 	details   *models.GitCloneDetails
 	targetDir string
 	timeout   time.Duration
+	options   Options
+	env       []string // per-job environment passed to every git command
+	config    []string // per-job configuration passed to every git command, e.g. the TLS files
+	unsetEnv  []string // variables of the server environment left out of git commands
+	changed   bool     // whether the last Sync modified the target
+	revision  string   // commit checked out by the last successful Sync
+}
+
+// Options holds server-wide settings shared by all Git syncers
+type Options struct {
+	Implementation  string // Git implementation: exec (git binary, default) or go-git
+	CacheDir        string // Directory holding shared reference repositories (disabled when empty)
+	CacheDissociate bool   // Copy objects borrowed from the cache into the target after cloning
+	StrictHostKeys  bool   // Require knownHosts or hostKeyFingerprint for SSH repositories
+	KeyFiles        bool   // Pass private keys to ssh in temporary files instead of an in-process agent
+
+	WorkDirs *workdirs.Dirs // Directories of temporary clones and key material, nil for the defaults
 }
 
 // maskCredentials masks passwords and sensitive information in URLs and commands
@@ -48,16 +82,35 @@ func maskGitCommand(args []string) []string {
 }
 
 // NewGitSyncer creates a new Git syncer
-func NewGitSyncer(details *models.GitCloneDetails, targetDir string, timeout time.Duration) *GitSyncer {
+func NewGitSyncer(details *models.GitCloneDetails, targetDir string, timeout time.Duration, options Options) *GitSyncer {
 	return &GitSyncer{
 		details:   details,
 		targetDir: targetDir,
 		timeout:   timeout,
+		options:   options,
 	}
 }
 
+// Changed reports whether the last Sync modified the target directory
+func (g *GitSyncer) Changed() bool {
+	return g.changed
+}
+
+// Result reports the commit checked out by the last successful Sync
+func (g *GitSyncer) Result() models.SyncResult {
+	return models.SyncResult{Revision: g.revision}
+}
+
 // Sync clones the repository to the target directory
-func (g *GitSyncer) Sync() error {
+func (g *GitSyncer) Sync(ctx context.Context) (err error) {
+	g.changed = true
+	g.revision = ""
+	defer func() {
+		if err == nil {
+			g.revision = g.headRevision()
+		}
+	}()
+
 	log.Printf("[GIT SYNC] Starting git sync: repo=%s targetDir=%s timeout=%v", g.details.URL, g.targetDir, g.timeout)
 	log.Printf("[GIT SYNC] Git details - Branch: %s, Depth: %d", g.details.Branch, g.details.Depth)
 
@@ -83,13 +136,21 @@ func (g *GitSyncer) Sync() error {
 		log.Printf("[GIT SYNC] Using specified branch: %s", branch)
 	}
 
+	// Git reports no file or byte counts, the fetch is a single step
+	progress.From(ctx).Phase(progress.PhaseTransferring)
+
+	if g.details.Export {
+		log.Printf("[GIT SYNC] Export mode enabled, target will contain the worktree only")
+		return g.exportRepo(ctx, branch)
+	}
+
 	// Check if target directory exists
 	gitDir := g.targetDir + "/.git"
 	log.Printf("[GIT SYNC] Checking if target directory is an existing git repository...")
 	if stat, err := os.Stat(g.targetDir); err == nil && stat.IsDir() {
 		if _, err := os.Stat(gitDir); err == nil {
 			log.Printf("[GIT SYNC] Found existing git repository, performing sync...")
-			return g.syncExistingRepo(branch)
+			return g.syncExistingRepo(ctx, branch)
 		}
 
 		// Directory exists but is not a git repository
@@ -105,7 +166,7 @@ func (g *GitSyncer) Sync() error {
 		if len(entries) > 0 {
 			log.Printf("[GIT SYNC] Target directory is not empty (%d entries)", len(entries))
 			log.Printf("[GIT SYNC] SAFETY: Will attempt clone to temporary location first to verify operation before modifying target")
-			return g.safeCloneWithReplace(branch)
+			return g.safeCloneWithReplace(ctx, branch)
 		} else {
 			log.Printf("[GIT SYNC] Target directory is empty, proceeding with clone")
 		}
@@ -115,15 +176,15 @@ func (g *GitSyncer) Sync() error {
 
 	// Do a shallow clone
 	log.Printf("[GIT SYNC] Performing fresh clone...")
-	return g.cloneRepo(branch)
+	return g.cloneRepo(ctx, branch)
 }
 
 // safeCloneWithReplace safely clones to a temporary location first, then replaces target
-func (g *GitSyncer) safeCloneWithReplace(branch string) error {
+func (g *GitSyncer) safeCloneWithReplace(ctx context.Context, branch string) error {
 	log.Printf("[GIT SYNC] Starting safe clone with replace for non-empty target directory")
 
 	// Create temporary directory in the same filesystem as target
-	targetParent := filepath.Dir(g.targetDir)
+	targetParent := g.options.WorkDirs.TempParent(g.targetDir)
 	tmpDir, err := os.MkdirTemp(targetParent, "volume-syncer-git-*")
 	if err != nil {
 		log.Printf("[GIT SYNC] ERROR: Failed to create temporary directory in %s: %v", targetParent, err)
@@ -141,11 +202,12 @@ func (g *GitSyncer) safeCloneWithReplace(branch string) error {
 		details:   g.details,
 		targetDir: tmpDir,
 		timeout:   g.timeout,
+		options:   g.options,
 	}
 
 	// Attempt clone to temporary location
 	log.Printf("[GIT SYNC] Attempting clone to temporary location to verify operation before modifying target...")
-	if err := tempSyncer.cloneRepo(branch); err != nil {
+	if err := tempSyncer.cloneRepo(ctx, branch); err != nil {
 		log.Printf("[GIT SYNC] ERROR: Clone to temporary location failed: %v", err)
 		log.Printf("[GIT SYNC] SAFETY: Target directory preserved due to clone failure")
 		return fmt.Errorf("clone failed, target directory preserved: %w", err)
@@ -153,6 +215,13 @@ func (g *GitSyncer) safeCloneWithReplace(branch string) error {
 
 	log.Printf("[GIT SYNC] Clone to temporary location successful, operation verified")
 
+	if g.details.Export {
+		if err := tempSyncer.finalizeExport(ctx, branch); err != nil {
+			log.Printf("[GIT SYNC] SAFETY: Target directory preserved due to export failure")
+			return fmt.Errorf("export failed, target directory preserved: %w", err)
+		}
+	}
+
 	// Create backup name for current target
 	backupDir := g.targetDir + ".backup-" + fmt.Sprintf("%d", time.Now().Unix())
 
@@ -192,30 +261,34 @@ func (g *GitSyncer) safeCloneWithReplace(branch string) error {
 }
 
 // syncExistingRepo syncs an existing git repository
-func (g *GitSyncer) syncExistingRepo(branch string) error {
+func (g *GitSyncer) syncExistingRepo(ctx context.Context, branch string) error {
+	if g.useGoGit() {
+		return g.goGitSyncExisting(ctx, branch)
+	}
+
 	log.Printf("[GIT SYNC] Syncing existing repository at %s", g.targetDir)
 
 	// Setup authentication
-	cleanup, err := g.setupSSHKey()
+	cleanup, err := g.setupCredentials()
 	if err != nil {
 		return err
 	}
 	defer cleanup()
 
-	// Prepare authenticated URL if using username/password
-	repoURL, err := g.prepareAuthenticatedURL()
+	// Credentials are supplied through a per-command credential helper, never through the URL
+	repoURL, err := g.prepareCredentials()
 	if err != nil {
 		return err
 	}
 
 	// Check if the remote URL matches (compare base URL without credentials)
 	log.Printf("[GIT SYNC] Checking remote URL...")
-	ctx, cancel := context.WithTimeout(context.Background(), g.timeout)
+	cmdCtx, cancel := context.WithTimeout(ctx, g.timeout)
 	defer cancel()
 
-	remoteURLBytes, err := exec.CommandContext(ctx, "git", "-C", g.targetDir, "config", "--get", "remote.origin.url").Output()
+	remoteURLBytes, err := g.gitCommand(cmdCtx, "-C", g.targetDir, "config", "--get", "remote.origin.url").Output()
 	if err != nil {
-		if ctx.Err() == context.DeadlineExceeded {
+		if cmdCtx.Err() == context.DeadlineExceeded {
 			log.Printf("[GIT SYNC] ERROR: Git config command timed out after %v", g.timeout)
 			return fmt.Errorf("git config command timed out after %v", g.timeout)
 		}
@@ -231,25 +304,33 @@ func (g *GitSyncer) syncExistingRepo(branch string) error {
 	if !g.urlsMatch(remoteURL, g.details.URL) {
 		log.Printf("[GIT SYNC] Remote URL mismatch, need to replace with different repository")
 		log.Printf("[GIT SYNC] SAFETY: Will attempt clone to temporary location first to verify operation")
-		return g.safeCloneWithReplace(branch)
+		return g.safeCloneWithReplace(ctx, branch)
 	}
 
-	// Update remote URL if authentication is needed
-	if g.details.User != "" && g.details.Password != "" {
-		log.Printf("[GIT SYNC] Updating remote URL with username/password authentication")
-		if err := g.runGitInTarget([]string{"remote", "set-url", "origin", repoURL}); err != nil {
+	// Remove credentials persisted in the remote URL by earlier versions
+	if remoteURL != repoURL && stripURLCredentials(remoteURL) == repoURL {
+		log.Printf("[GIT SYNC] Removing credentials from stored remote URL")
+		if err := g.runGitInTarget(ctx, []string{"remote", "set-url", "origin", repoURL}); err != nil {
 			log.Printf("[GIT SYNC] ERROR: Failed to update remote URL: %v", err)
 			return fmt.Errorf("failed to update remote URL: %w", err)
 		}
-	} else if g.details.PrivateKey != "" {
-		log.Printf("[GIT SYNC] Using SSH authentication with private key (no URL update needed)")
 	}
 
 	log.Printf("[GIT SYNC] Remote URL matches, proceeding with sync")
 
+	if g.isUpToDate(ctx, repoURL, branch) {
+		log.Printf("[GIT SYNC] Local HEAD matches remote and worktree is clean, skipping fetch")
+		g.changed = false
+		return nil
+	}
+
 	// git fetch
 	log.Printf("[GIT SYNC] Fetching latest changes...")
-	if err := g.runGitInTarget([]string{"fetch", "--all"}); err != nil {
+	fetchArgs := []string{"fetch", "--all"}
+	if g.details.Filter != "" {
+		fetchArgs = append(fetchArgs, "--filter="+g.details.Filter)
+	}
+	if err := g.runGitInTarget(ctx, fetchArgs); err != nil {
 		log.Printf("[GIT SYNC] ERROR: Git fetch failed: %v", err)
 		return fmt.Errorf("git fetch failed: %w", err)
 	}
@@ -258,7 +339,7 @@ func (g *GitSyncer) syncExistingRepo(branch string) error {
 	// Force local branch to match remote
 	if branch == "" {
 		// If no branch specified, get the default branch
-		defaultBranch, err := g.getDefaultBranch()
+		defaultBranch, err := g.getDefaultBranch(ctx)
 		if err != nil {
 			log.Printf("[GIT SYNC] ERROR: Failed to get default branch: %v", err)
 			return fmt.Errorf("failed to get default branch: %w", err)
@@ -269,12 +350,12 @@ func (g *GitSyncer) syncExistingRepo(branch string) error {
 
 	log.Printf("[GIT SYNC] Checking out branch %s...", branch)
 	const originPrefix = "origin/"
-	if err := g.runGitInTarget([]string{"checkout", "-B", branch, originPrefix + branch}); err != nil {
+	if err := g.runGitInTarget(ctx, []string{"checkout", "-B", branch, originPrefix + branch}); err != nil {
 		// Try fallback to master if main fails
 		if branch == "main" {
 			log.Printf("[GIT SYNC] Branch 'main' not found, falling back to 'master'")
 			branch = "master"
-			if err := g.runGitInTarget([]string{"checkout", "-B", branch, originPrefix + branch}); err != nil {
+			if err := g.runGitInTarget(ctx, []string{"checkout", "-B", branch, originPrefix + branch}); err != nil {
 				log.Printf("[GIT SYNC] ERROR: Git checkout -B master failed: %v", err)
 				return fmt.Errorf("git checkout -B master failed: %w", err)
 			}
@@ -285,17 +366,26 @@ func (g *GitSyncer) syncExistingRepo(branch string) error {
 	}
 	log.Printf("[GIT SYNC] Branch checkout completed successfully")
 
+	// File filters select files of the new revision, so sparse checkout is applied after checkout
+	if err := g.configureSparseCheckout(ctx); err != nil {
+		return err
+	}
+
 	// git reset --hard origin/<branch>
 	log.Printf("[GIT SYNC] Resetting to origin/%s...", branch)
-	if err := g.runGitInTarget([]string{"reset", "--hard", originPrefix + branch}); err != nil {
+	if err := g.runGitInTarget(ctx, []string{"reset", "--hard", originPrefix + branch}); err != nil {
 		log.Printf("[GIT SYNC] ERROR: Git reset failed: %v", err)
 		return fmt.Errorf("git reset failed: %w", err)
 	}
 	log.Printf("[GIT SYNC] Reset completed successfully")
 
+	if err := g.updateSubmodules(ctx); err != nil {
+		return err
+	}
+
 	// git clean -fdx (always run clean)
 	log.Printf("[GIT SYNC] Cleaning untracked files...")
-	if err := g.runGitInTarget([]string{"clean", "-fdx"}); err != nil {
+	if err := g.runGitInTarget(ctx, []string{"clean", "-fdx"}); err != nil {
 		log.Printf("[GIT SYNC] ERROR: Git clean failed: %v", err)
 		return fmt.Errorf("git clean failed: %w", err)
 	}
@@ -306,29 +396,44 @@ func (g *GitSyncer) syncExistingRepo(branch string) error {
 }
 
 // cloneRepo clones a new repository
-func (g *GitSyncer) cloneRepo(branch string) error {
+func (g *GitSyncer) cloneRepo(ctx context.Context, branch string) error {
+	if g.useGoGit() {
+		return g.goGitClone(ctx, branch)
+	}
+
 	log.Printf("[GIT SYNC] Starting fresh clone of repository")
 
 	// Setup authentication
-	cleanup, err := g.setupSSHKey()
+	cleanup, err := g.setupCredentials()
 	if err != nil {
 		return err
 	}
 	defer cleanup()
 
-	// Prepare authenticated URL if using username/password
-	repoURL, err := g.prepareAuthenticatedURL()
+	// Credentials are supplied through a per-command credential helper, never through the URL
+	repoURL, err := g.prepareCredentials()
 	if err != nil {
 		return err
 	}
 
+	gitCmd := []string{"clone"}
+
 	depth := g.details.Depth
-	if depth == 0 {
+	if depth == 0 && g.details.Filter == "" {
 		depth = 1 // default to shallow clone
 	}
 
-	gitCmd := []string{"clone", "--depth", fmt.Sprintf("%d", depth)}
-	log.Printf("[GIT SYNC] Using clone depth: %d", depth)
+	if depth > 0 {
+		gitCmd = append(gitCmd, "--depth", fmt.Sprintf("%d", depth))
+		log.Printf("[GIT SYNC] Using clone depth: %d", depth)
+	} else {
+		log.Printf("[GIT SYNC] Cloning full history")
+	}
+
+	if g.details.Filter != "" {
+		gitCmd = append(gitCmd, "--filter="+g.details.Filter)
+		log.Printf("[GIT SYNC] Using partial clone filter: %s", g.details.Filter)
+	}
 
 	if branch != "" {
 		gitCmd = append(gitCmd, "--branch", branch)
@@ -337,51 +442,71 @@ func (g *GitSyncer) cloneRepo(branch string) error {
 		log.Printf("[GIT SYNC] Cloning repository's default branch")
 	}
 
+	if reference := g.prepareCache(ctx, repoURL, branch); reference != "" {
+		gitCmd = append(gitCmd, "--reference-if-able", reference)
+		if g.options.CacheDissociate {
+			gitCmd = append(gitCmd, "--dissociate")
+		}
+		log.Printf("[GIT SYNC] Borrowing objects from cache: %s (dissociate: %v)", reference, g.options.CacheDissociate)
+	}
+
+	if len(g.details.Paths) > 0 {
+		gitCmd = append(gitCmd, "--sparse")
+		log.Printf("[GIT SYNC] Using sparse checkout for paths: %v", g.details.Paths)
+	} else if g.filteredCheckout() {
+		gitCmd = append(gitCmd, "--sparse")
+		log.Printf("[GIT SYNC] Using sparse checkout for file filters")
+	}
+
+	cloneOptions := strings.Join(gitCmd[1:], " ")
 	gitCmd = append(gitCmd, repoURL, g.targetDir)
 
 	// Log the command appropriately based on authentication type
 	if g.details.PrivateKey != "" {
-		if branch != "" {
-			log.Printf("[GIT SYNC] Executing git command with SSH key authentication: git clone --depth %d --branch %s [SSH_URL] %s", depth, branch, g.targetDir)
-		} else {
-			log.Printf("[GIT SYNC] Executing git command with SSH key authentication: git clone --depth %d [SSH_URL] %s", depth, g.targetDir)
-		}
-	} else if g.details.User != "" && g.details.Password != "" {
-		if branch != "" {
-			log.Printf("[GIT SYNC] Executing git command with username/password authentication: git clone --depth %d --branch %s [URL_WITH_CREDENTIALS] %s", depth, branch, g.targetDir)
-		} else {
-			log.Printf("[GIT SYNC] Executing git command with username/password authentication: git clone --depth %d [URL_WITH_CREDENTIALS] %s", depth, g.targetDir)
-		}
+		log.Printf("[GIT SYNC] Executing git command with SSH key authentication: git clone %s [SSH_URL] %s", cloneOptions, g.targetDir)
+	} else if _, _, ok := g.httpCredentials(); ok {
+		log.Printf("[GIT SYNC] Executing git command with HTTP(S) credential helper: git clone %s %s %s", cloneOptions, repoURL, g.targetDir)
 	} else {
 		// Mask credentials in git command logging
 		maskedGitCmd := maskGitCommand(gitCmd)
 		log.Printf("[GIT SYNC] Executing git command: git %v", maskedGitCmd)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), g.timeout)
+	cmdCtx, cancel := context.WithTimeout(ctx, g.timeout)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, "git", gitCmd...)
+	var stderr bytes.Buffer
+	cmd := g.gitCommand(cmdCtx, gitCmd...)
 	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd.Stderr = io.MultiWriter(os.Stderr, &stderr)
 
 	log.Printf("[GIT SYNC] Starting clone process...")
-	if err := cmd.Run(); err != nil {
-		if ctx.Err() == context.DeadlineExceeded {
+	err = cmd.Run()
+	metrics.CommandExited(ctx, "git", err)
+	if err != nil {
+		if cmdCtx.Err() == context.DeadlineExceeded {
 			log.Printf("[GIT SYNC] ERROR: Git clone timed out after %v", g.timeout)
 			return fmt.Errorf("git clone timed out after %v", g.timeout)
 		}
 		log.Printf("[GIT SYNC] ERROR: Git clone failed: %v", err)
-		return fmt.Errorf("git clone failed: %w", err)
+		return fmt.Errorf("git clone failed: %w", commandError(err, stderr.String()))
+	}
+
+	if err := g.configureSparseCheckout(ctx); err != nil {
+		return err
+	}
+
+	if err := g.updateSubmodules(ctx); err != nil {
+		return err
 	}
 
 	// If no branch was specified, log the current branch after clone
 	if branch == "" {
 		// Get the current branch name with timeout
-		branchCtx, branchCancel := context.WithTimeout(context.Background(), g.timeout)
+		branchCtx, branchCancel := context.WithTimeout(ctx, g.timeout)
 		defer branchCancel()
 
-		currentBranchOutput, err := exec.CommandContext(branchCtx, "git", "-C", g.targetDir, "branch", "--show-current").Output()
+		currentBranchOutput, err := g.gitCommand(branchCtx, "-C", g.targetDir, "branch", "--show-current").Output()
 		if err == nil {
 			currentBranch := strings.TrimSpace(string(currentBranchOutput))
 			log.Printf("[GIT SYNC] Cloned to default branch: %s", currentBranch)
@@ -396,34 +521,278 @@ func (g *GitSyncer) cloneRepo(branch string) error {
 	return nil
 }
 
+// gitCommand creates a git command that carries the per-job environment
+func (g *GitSyncer) gitCommand(ctx context.Context, args ...string) *exec.Cmd {
+	// The target may be owned by another user through the ownership options; the syncer manages
+	// it, so git's protection against repositories owned by other users does not apply
+	gitArgs := []string{"-c", "safe.directory=*"}
+	for _, setting := range g.config {
+		gitArgs = append(gitArgs, "-c", setting)
+	}
+	cmd := exec.CommandContext(ctx, "git", append(gitArgs, args...)...)
+	cmd.Env = append(append(g.processEnv(), g.env...), g.credentialEnv()...)
+	procgroup.Configure(cmd)
+	return cmd
+}
+
+// processEnv returns the environment of the server without the variables overriding the settings
+// of the job: the GIT_SSL_* variables of its TLS settings, and no_proxy, which git prefers to the
+// NO_PROXY of its proxy
+func (g *GitSyncer) processEnv() []string {
+	unset := g.unsetEnv
+	if g.details.Proxy != nil {
+		unset = append(slices.Clip(unset), "no_proxy")
+	}
+	env := os.Environ()
+	if len(unset) == 0 {
+		return env
+	}
+	kept := env[:0:0]
+	for _, entry := range env {
+		name, _, _ := strings.Cut(entry, "=")
+		if !slices.Contains(unset, name) {
+			kept = append(kept, entry)
+		}
+	}
+	return kept
+}
+
+// commandError marks failures of git commands whose output reports a network failure, so that
+// the sync is retried, and reports rejected credentials and missing repositories or refs. The
+// output itself is left out, as it may contain the remote URL with its credentials.
+func commandError(err error, stderr string) error {
+	if retry.IsTransientOutput(stderr) {
+		return syncerrors.NewNetworkError("remote unreachable", err)
+	}
+	switch code, _ := retry.Classify(errors.New(stderr)); code {
+	case syncerrors.CodeAuthFailed:
+		return syncerrors.NewAuthError("remote rejected the credentials", err)
+	case syncerrors.CodeNotFound:
+		return fmt.Errorf("repository or ref not found: %w", err)
+	}
+	return err
+}
+
 // runGitInTarget runs a git command in the target directory
-func (g *GitSyncer) runGitInTarget(args []string) error {
+func (g *GitSyncer) runGitInTarget(ctx context.Context, args []string) error {
 	// Mask credentials in the log output
 	maskedArgs := maskGitCommand(args)
 	log.Printf("[GIT SYNC] Executing in %s: git %v", g.targetDir, maskedArgs)
 
-	ctx, cancel := context.WithTimeout(context.Background(), g.timeout)
+	ctx, cancel := context.WithTimeout(ctx, g.timeout)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, "git", args...)
+	var stderr bytes.Buffer
+	cmd := g.gitCommand(ctx, args...)
 	cmd.Dir = g.targetDir
 	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd.Stderr = io.MultiWriter(os.Stderr, &stderr)
 
 	err := cmd.Run()
+	metrics.CommandExited(ctx, "git", err)
 	if err != nil {
 		if ctx.Err() == context.DeadlineExceeded {
 			log.Printf("[GIT SYNC] ERROR: Git command timed out after %v", g.timeout)
 			return fmt.Errorf("git command timed out after %v", g.timeout)
 		}
 		log.Printf("[GIT SYNC] ERROR: Git command failed: %v", err)
-		return err
+		return commandError(err, stderr.String())
 	}
 
 	log.Printf("[GIT SYNC] Git command completed successfully: %v", args)
 	return nil
 }
 
+// remoteHead resolves the commit the remote branch (or the remote default branch) points to
+func (g *GitSyncer) remoteHead(ctx context.Context, repoURL, branch string) (string, error) {
+	ref := "HEAD"
+	if branch != "" {
+		ref = "refs/heads/" + branch
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, g.timeout)
+	defer cancel()
+
+	output, err := g.gitCommand(ctx, "ls-remote", repoURL, ref).Output()
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", fmt.Errorf("git ls-remote timed out after %v", g.timeout)
+		}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			err = commandError(err, string(exitErr.Stderr))
+		}
+		return "", fmt.Errorf("git ls-remote failed: %w", err)
+	}
+
+	fields := strings.Fields(string(output))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("remote ref %s not found", ref)
+	}
+	return fields[0], nil
+}
+
+// headRevision returns the commit checked out in the target directory, or recorded by the
+// export manifest of exported worktrees
+func (g *GitSyncer) headRevision() string {
+	if g.details.Export {
+		data, err := os.ReadFile(filepath.Join(g.targetDir, ExportManifestFile))
+		if err != nil {
+			return ""
+		}
+		var manifest ExportManifest
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			return ""
+		}
+		return manifest.Commit
+	}
+
+	repo, err := gogit.PlainOpen(g.targetDir)
+	if err != nil {
+		log.Printf("[GIT SYNC] WARNING: Failed to open repository to resolve HEAD: %v", err)
+		return ""
+	}
+	head, err := repo.Head()
+	if err != nil {
+		log.Printf("[GIT SYNC] WARNING: Failed to resolve HEAD: %v", err)
+		return ""
+	}
+	return head.Hash().String()
+}
+
+// isUpToDate reports whether the local checkout already matches the remote head
+// and has no local modifications, so that fetch/reset/clean can be skipped
+func (g *GitSyncer) isUpToDate(ctx context.Context, repoURL, branch string) bool {
+	log.Printf("[GIT SYNC] Comparing remote head with local HEAD...")
+	remote, err := g.remoteHead(ctx, repoURL, branch)
+	if err != nil {
+		log.Printf("[GIT SYNC] WARNING: Failed to resolve remote head, performing full sync: %v", err)
+		return false
+	}
+
+	cmdCtx, cancel := context.WithTimeout(ctx, g.timeout)
+	defer cancel()
+
+	localOutput, err := g.gitCommand(cmdCtx, "-C", g.targetDir, "rev-parse", "HEAD").Output()
+	if err != nil {
+		log.Printf("[GIT SYNC] WARNING: Failed to resolve local HEAD, performing full sync: %v", err)
+		return false
+	}
+	local := strings.TrimSpace(string(localOutput))
+	log.Printf("[GIT SYNC] Remote head: %s, local HEAD: %s", remote, local)
+	if local != remote {
+		return false
+	}
+
+	// Sparse paths and filters may have changed even if the revision did not
+	sparseBefore := g.sparseState(ctx)
+	if err := g.configureSparseCheckout(ctx); err != nil {
+		log.Printf("[GIT SYNC] WARNING: Failed to apply sparse checkout, performing full sync: %v", err)
+		return false
+	}
+	if g.sparseState(ctx) != sparseBefore {
+		log.Printf("[GIT SYNC] Sparse checkout changed, performing full sync")
+		return false
+	}
+
+	statusOutput, err := g.gitCommand(cmdCtx, "-C", g.targetDir, "status", "--porcelain", "--ignored").Output()
+	if err != nil {
+		log.Printf("[GIT SYNC] WARNING: Failed to check worktree status, performing full sync: %v", err)
+		return false
+	}
+	if strings.TrimSpace(string(statusOutput)) != "" {
+		log.Printf("[GIT SYNC] Worktree has local modifications, performing full sync")
+		return false
+	}
+
+	return true
+}
+
+// sparseState returns the sparse checkout configuration of the target repository
+func (g *GitSyncer) sparseState(ctx context.Context) string {
+	ctx, cancel := context.WithTimeout(ctx, g.timeout)
+	defer cancel()
+
+	enabled, _ := g.gitCommand(ctx, "-C", g.targetDir, "config", "--get", "core.sparseCheckout").Output()
+	if strings.TrimSpace(string(enabled)) != "true" {
+		return ""
+	}
+	patterns, _ := os.ReadFile(filepath.Join(g.targetDir, ".git", "info", "sparse-checkout"))
+	return string(patterns)
+}
+
+// configureSparseCheckout restricts the worktree to the requested paths and filters, or restores
+// a full checkout when a previously sparse repository no longer requests any paths
+func (g *GitSyncer) configureSparseCheckout(ctx context.Context) error {
+	if g.filteredCheckout() {
+		return g.configureFilteredCheckout(ctx)
+	}
+
+	if len(g.details.Paths) == 0 {
+		cmdCtx, cancel := context.WithTimeout(ctx, g.timeout)
+		defer cancel()
+
+		output, err := g.gitCommand(cmdCtx, "-C", g.targetDir, "config", "--get", "core.sparseCheckout").Output()
+		if err != nil || strings.TrimSpace(string(output)) != "true" {
+			return nil
+		}
+
+		log.Printf("[GIT SYNC] No sparse paths requested, disabling sparse checkout")
+		if err := g.runGitInTarget(ctx, []string{"sparse-checkout", "disable"}); err != nil {
+			log.Printf("[GIT SYNC] ERROR: Git sparse-checkout disable failed: %v", err)
+			return fmt.Errorf("git sparse-checkout disable failed: %w", err)
+		}
+		return nil
+	}
+
+	log.Printf("[GIT SYNC] Configuring sparse checkout (cone mode) for paths: %v", g.details.Paths)
+	args := append([]string{"sparse-checkout", "set", "--cone"}, g.details.Paths...)
+	if err := g.runGitInTarget(ctx, args); err != nil {
+		log.Printf("[GIT SYNC] ERROR: Git sparse-checkout set failed: %v", err)
+		return fmt.Errorf("git sparse-checkout set failed: %w", err)
+	}
+	log.Printf("[GIT SYNC] Sparse checkout configured successfully")
+	return nil
+}
+
+// updateSubmodules initializes and updates submodules according to the configured mode
+func (g *GitSyncer) updateSubmodules(ctx context.Context) error {
+	mode := g.details.Submodules
+	if mode == "" || mode == models.GitSubmodulesNone {
+		return nil
+	}
+
+	if _, err := os.Stat(filepath.Join(g.targetDir, ".gitmodules")); err != nil {
+		log.Printf("[GIT SYNC] No .gitmodules file found, skipping submodule update")
+		return nil
+	}
+
+	log.Printf("[GIT SYNC] Updating submodules (mode: %s)...", mode)
+
+	syncArgs := []string{"submodule", "sync"}
+	updateArgs := []string{"submodule", "update", "--init", "--force"}
+	switch mode {
+	case models.GitSubmodulesShallow:
+		updateArgs = append(updateArgs, "--depth", "1")
+	case models.GitSubmodulesRecursive:
+		syncArgs = append(syncArgs, "--recursive")
+		updateArgs = append(updateArgs, "--recursive")
+	}
+
+	if err := g.runGitInTarget(ctx, syncArgs); err != nil {
+		log.Printf("[GIT SYNC] ERROR: Git submodule sync failed: %v", err)
+		return fmt.Errorf("git submodule sync failed: %w", err)
+	}
+
+	if err := g.runGitInTarget(ctx, updateArgs); err != nil {
+		log.Printf("[GIT SYNC] ERROR: Git submodule update failed: %v", err)
+		return fmt.Errorf("git submodule update failed: %w", err)
+	}
+
+	log.Printf("[GIT SYNC] Submodules updated successfully")
+	return nil
+}
+
 // validate validates the git details
 func (g *GitSyncer) validate() error {
 	if g.details == nil {
@@ -437,13 +806,18 @@ func (g *GitSyncer) validate() error {
 	// Check that both privateKey and username/password are not provided at the same time
 	hasPrivateKey := g.details.PrivateKey != ""
 	hasUsernamePassword := g.details.User != "" && g.details.Password != ""
+	hasToken := g.details.Token != ""
 
-	if hasPrivateKey && hasUsernamePassword {
-		return fmt.Errorf("cannot provide both private key and username/password authentication")
+	if hasPrivateKey && (hasUsernamePassword || hasToken) {
+		return fmt.Errorf("cannot provide both private key and username/password or token authentication")
 	}
 
-	// If username is provided, password must also be provided
-	if g.details.User != "" && g.details.Password == "" {
+	if hasToken && g.details.Password != "" {
+		return fmt.Errorf("cannot provide both password and token authentication")
+	}
+
+	// If username is provided, password must also be provided (unless it is the token username)
+	if g.details.User != "" && g.details.Password == "" && !hasToken {
 		return fmt.Errorf("password is required when username is provided")
 	}
 
@@ -455,31 +829,21 @@ func (g *GitSyncer) validate() error {
 	return nil
 }
 
-// prepareAuthenticatedURL prepares the URL with authentication credentials
-func (g *GitSyncer) prepareAuthenticatedURL() (string, error) {
-	// If private key is provided, use SSH authentication (no URL modification needed)
+// prepareCredentials logs the authentication method and returns the URL passed to git.
+// HTTP(S) credentials are provided by credentialEnv so they never end up in .git/config
+// or in process arguments.
+func (g *GitSyncer) prepareCredentials() (string, error) {
 	if g.details.PrivateKey != "" {
 		log.Printf("[GIT SYNC] Using SSH authentication with private key")
 		return g.details.URL, nil
 	}
 
-	// If username/password is provided, use HTTP authentication
-	if g.details.User != "" && g.details.Password != "" {
-		log.Printf("[GIT SYNC] Preparing URL with username/password authentication")
-
-		// Parse the URL to inject credentials
-		parsedURL, err := url.Parse(g.details.URL)
-		if err != nil {
-			return "", fmt.Errorf("failed to parse Git URL: %w", err)
+	if user, _, ok := g.httpCredentials(); ok {
+		if _, err := g.credentialScope(); err != nil {
+			return "", err
 		}
-
-		// Add credentials to URL
-		parsedURL.User = url.UserPassword(g.details.User, g.details.Password)
-		authenticatedURL := parsedURL.String()
-
-		// Log without showing credentials
-		log.Printf("[GIT SYNC] URL prepared with credentials for user: %s", g.details.User)
-		return authenticatedURL, nil
+		log.Printf("[GIT SYNC] Using HTTP(S) credential helper for user: %s", user)
+		return g.details.URL, nil
 	}
 
 	// No authentication provided
@@ -487,49 +851,267 @@ func (g *GitSyncer) prepareAuthenticatedURL() (string, error) {
 	return g.details.URL, nil
 }
 
-// setupSSHKey sets up SSH key authentication if private key is provided
+// credentialScope returns the scheme://host URL the credential helper answers for.
+// Submodules on the same host therefore receive the same credentials.
+func (g *GitSyncer) credentialScope() (string, error) {
+	parsedURL, err := url.Parse(g.details.URL)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse Git URL: %w", err)
+	}
+	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
+		return "", fmt.Errorf("username/password or token authentication requires an HTTP(S) repository URL")
+	}
+	return fmt.Sprintf("%s://%s", parsedURL.Scheme, parsedURL.Host), nil
+}
+
+// credentialEnv returns the environment installing an ephemeral credential helper for the
+// repository host and the proxy of the job. The helper reads the credentials from the
+// environment of the git process, and any configured helpers are reset so credentials are not
+// stored anywhere. The settings are passed as GIT_CONFIG_* variables, so that neither the
+// credentials nor the proxy URL appear in process arguments.
+func (g *GitSyncer) credentialEnv() []string {
+	var env []string
+	var config [][2]string
+	if user, password, ok := g.httpCredentials(); ok {
+		if scope, err := g.credentialScope(); err == nil {
+			env = append(env,
+				"GIT_TERMINAL_PROMPT=0",
+				"VOLUME_SYNCER_GIT_USERNAME="+user,
+				"VOLUME_SYNCER_GIT_PASSWORD="+password,
+			)
+			config = append(config,
+				[2]string{"credential.helper", ""},
+				[2]string{"credential." + scope + ".helper", credentialHelperScript},
+			)
+		}
+	}
+	if g.details.Proxy != nil {
+		// An empty http.proxy connects directly, ignoring the proxy variables of the server
+		config = append(config, [2]string{"http.proxy", g.details.Proxy.URL})
+		env = append(env, "NO_PROXY="+strings.Join(g.details.Proxy.NoProxy, ","))
+	}
+	if len(config) == 0 {
+		return env
+	}
+
+	env = append(env, fmt.Sprintf("GIT_CONFIG_COUNT=%d", len(config)))
+	for i, setting := range config {
+		env = append(env, fmt.Sprintf("GIT_CONFIG_KEY_%d=%s", i, setting[0]), fmt.Sprintf("GIT_CONFIG_VALUE_%d=%s", i, setting[1]))
+	}
+	return env
+}
+
+// credentialHelperScript answers git credential "get" requests from the job environment
+const credentialHelperScript = `!f() { test "$1" = get && printf 'username=%s\npassword=%s\n' "$VOLUME_SYNCER_GIT_USERNAME" "$VOLUME_SYNCER_GIT_PASSWORD"; }; f`
+
+// httpCredentials returns the username and password used for HTTP(S) authentication.
+// A personal access token is paired with the username convention of the hosting provider
+// unless an explicit user is given.
+func (g *GitSyncer) httpCredentials() (string, string, bool) {
+	if g.details.Token != "" {
+		user := g.details.User
+		if user == "" {
+			user = tokenUsername(g.details.URL)
+		}
+		return user, g.details.Token, true
+	}
+
+	if g.details.User != "" && g.details.Password != "" {
+		return g.details.User, g.details.Password, true
+	}
+
+	return "", "", false
+}
+
+// tokenUsername returns the username that the hosting provider expects for token authentication
+func tokenUsername(repoURL string) string {
+	host := repoURL
+	if parsedURL, err := url.Parse(repoURL); err == nil {
+		host = parsedURL.Hostname()
+	}
+	host = strings.ToLower(host)
+
+	switch {
+	case strings.Contains(host, "github"):
+		return "x-access-token"
+	case strings.Contains(host, "bitbucket"):
+		return "x-token-auth"
+	default:
+		// GitLab and most other providers accept the oauth2 username for tokens
+		return "oauth2"
+	}
+}
+
+// setupSSHKey sets up SSH key authentication and host key verification if configured
 func (g *GitSyncer) setupSSHKey() (func(), error) {
-	if g.details.PrivateKey == "" {
-		// No private key provided, return empty cleanup function
-		return func() { /* no cleanup needed */ }, nil
+	hasHostKeyMaterial := g.details.KnownHosts != "" || g.details.HostKeyFingerprint != ""
+	if !hasHostKeyMaterial && g.options.StrictHostKeys && g.isSSHURL() {
+		log.Printf("[GIT SYNC] ERROR: Strict host key checking is enabled but no host key material was provided")
+		return nil, fmt.Errorf("strict host key checking is enabled: knownHosts or hostKeyFingerprint is required for SSH repositories")
 	}
 
-	log.Printf("[GIT SYNC] Setting up SSH key authentication")
+	if agentSocket, err := g.agentSocket(); err != nil {
+		log.Printf("[GIT SYNC] ERROR: ssh-agent setup failed: %v", err)
+		return nil, fmt.Errorf("ssh-agent setup failed: %w", err)
+	} else if agentSocket != "" {
+		log.Printf("[GIT SYNC] Using ssh-agent authentication via %s", agentSocket)
+		g.env = append(g.env, "SSH_AUTH_SOCK="+agentSocket)
+	}
 
-	// Decode base64 private key
-	privateKeyBytes, err := base64.StdEncoding.DecodeString(g.details.PrivateKey)
+	proxyOption, proxyEnv, err := g.sshProxy()
 	if err != nil {
-		log.Printf("[GIT SYNC] ERROR: Failed to decode base64 private key: %v", err)
-		return func() { /* no cleanup needed */ }, fmt.Errorf("failed to decode base64 private key: %w", err)
+		return nil, err
 	}
-	log.Printf("[GIT SYNC] Base64 private key decoded successfully (%d bytes)", len(privateKeyBytes))
 
-	// Create temporary key file
-	tmpKeyFile, err := g.createTempKeyFile(privateKeyBytes)
+	if g.details.PrivateKey == "" && !hasHostKeyMaterial {
+		if proxyOption != "" {
+			g.env = append(g.env, proxyEnv...)
+			g.env = append(g.env, "GIT_SSH_COMMAND=ssh "+proxyOption)
+		}
+		// No private key or host key material provided, only reset the job environment
+		return func() { g.env = nil }, nil
+	}
+
+	// Keep all key material for this job in its own private directory
+	jobDir, err := os.MkdirTemp(g.options.WorkDirs.Credentials(), "volume-syncer-git-ssh-*")
 	if err != nil {
-		log.Printf("[GIT SYNC] ERROR: Failed to create temporary key file: %v", err)
-		return func() { /* no cleanup needed */ }, fmt.Errorf("failed to create temporary key file: %w", err)
+		log.Printf("[GIT SYNC] ERROR: Failed to create SSH working directory: %v", err)
+		return nil, fmt.Errorf("failed to create SSH working directory: %w", err)
+	}
+	var keyAgent *sshutil.KeyAgent
+	cleanupFiles := func() {
+		if keyAgent != nil {
+			keyAgent.Close()
+		}
+		os.RemoveAll(jobDir)
+	}
+
+	sshCommand := "ssh"
+
+	if g.details.PrivateKey != "" {
+		log.Printf("[GIT SYNC] Setting up SSH key authentication")
+
+		// Decode base64 private key
+		privateKeyBytes, err := base64.StdEncoding.DecodeString(g.details.PrivateKey)
+		if err != nil {
+			cleanupFiles()
+			log.Printf("[GIT SYNC] ERROR: Failed to decode base64 private key: %v", err)
+			return nil, fmt.Errorf("failed to decode base64 private key: %w", err)
+		}
+		log.Printf("[GIT SYNC] Base64 private key decoded successfully (%d bytes)", len(privateKeyBytes))
+
+		// Serve the key from memory unless key files are configured, the temporary key file is
+		// the fallback when the agent cannot be started
+		if !g.options.KeyFiles {
+			if keyAgent, err = sshutil.StartKeyAgent(jobDir, privateKeyBytes, nil); err != nil {
+				log.Printf("[GIT SYNC] WARNING: Failed to start in-process key agent, falling back to a temporary key file: %v", err)
+			}
+		}
+		if keyAgent != nil {
+			log.Printf("[GIT SYNC] Serving SSH key through in-process agent %s", keyAgent.Socket())
+			g.env = append(g.env, "SSH_AUTH_SOCK="+keyAgent.Socket())
+			sshCommand += " -o PreferredAuthentications=publickey"
+		} else {
+			// Create temporary key file
+			tmpKeyFile, err := g.createTempKeyFile(jobDir, privateKeyBytes)
+			if err != nil {
+				cleanupFiles()
+				log.Printf("[GIT SYNC] ERROR: Failed to create temporary key file: %v", err)
+				return nil, fmt.Errorf("failed to create temporary key file: %w", err)
+			}
+			log.Printf("[GIT SYNC] Temporary SSH key file created: %s", tmpKeyFile)
+
+			sshCommand += " -i " + tmpKeyFile
+		}
+	}
+
+	if hasHostKeyMaterial {
+		knownHostsFile, err := g.createKnownHostsFile(jobDir)
+		if err != nil {
+			cleanupFiles()
+			log.Printf("[GIT SYNC] ERROR: Host key verification setup failed: %v", err)
+			return nil, fmt.Errorf("host key verification setup failed: %w", err)
+		}
+		log.Printf("[GIT SYNC] Temporary known_hosts file created: %s", knownHostsFile)
+
+		sshCommand += fmt.Sprintf(" -o StrictHostKeyChecking=yes -o UserKnownHostsFile=%s", knownHostsFile)
+	} else {
+		sshCommand += " -o StrictHostKeyChecking=no"
 	}
-	log.Printf("[GIT SYNC] Temporary SSH key file created: %s", tmpKeyFile)
 
-	// Setup SSH command to use the key
-	sshCommand := fmt.Sprintf("ssh -i %s -o StrictHostKeyChecking=no", tmpKeyFile)
-	os.Setenv("GIT_SSH_COMMAND", sshCommand)
-	log.Printf("[GIT SYNC] GIT_SSH_COMMAND set: %s", sshCommand)
+	if proxyOption != "" {
+		sshCommand += " " + proxyOption
+		g.env = append(g.env, proxyEnv...)
+	}
+
+	// Setup SSH command for this job only; the process environment is left untouched
+	// so that concurrent syncs cannot pick up each other's keys
+	g.env = append(g.env, "GIT_SSH_COMMAND="+sshCommand)
+	log.Printf("[GIT SYNC] GIT_SSH_COMMAND set for job: %s", sshCommand)
 
 	// Return cleanup function
 	cleanup := func() {
 		log.Printf("[GIT SYNC] Cleaning up SSH key and environment")
-		os.Remove(tmpKeyFile)
-		os.Unsetenv("GIT_SSH_COMMAND")
+		cleanupFiles()
+		g.env = nil
 	}
 
 	return cleanup, nil
 }
 
-// createTempKeyFile creates a temporary file for the SSH private key
-func (g *GitSyncer) createTempKeyFile(privateKeyBytes []byte) (string, error) {
-	tmpFile, err := os.CreateTemp("", "git_ssh_key_*")
+// sshProxy returns the ssh option and the environment routing the SSH connection to the
+// repository host through the proxy, nothing for HTTP(S) repositories and direct connections
+func (g *GitSyncer) sshProxy() (string, []string, error) {
+	host, port, ok := sshutil.ParseGitSSHEndpoint(g.details.URL)
+	if !ok {
+		return "", nil, nil
+	}
+	p, err := proxy.New(g.details.Proxy)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid proxy: %w", err)
+	}
+	return p.SSHOptions(net.JoinHostPort(host, strconv.Itoa(port)))
+}
+
+// agentSocket returns the ssh-agent socket used for SSH repositories without a private key
+func (g *GitSyncer) agentSocket() (string, error) {
+	if g.details.PrivateKey != "" || !g.isSSHURL() {
+		return "", nil
+	}
+	return sshutil.ResolveAgentSocket(g.details.AgentSocket)
+}
+
+// createKnownHostsFile writes a temporary known_hosts file for the repository host,
+// built from the supplied known hosts blob or a verified host key fingerprint
+func (g *GitSyncer) createKnownHostsFile(dir string) (string, error) {
+	host, port, ok := sshutil.ParseGitSSHEndpoint(g.details.URL)
+	if !ok {
+		return "", fmt.Errorf("host key verification requires an SSH repository URL, got %s", maskCredentials(g.details.URL))
+	}
+
+	var content string
+	var err error
+	if g.details.KnownHosts != "" {
+		log.Printf("[GIT SYNC] Using provided known hosts for %s:%d", host, port)
+		content, err = sshutil.BuildKnownHosts(g.details.KnownHosts, host, port)
+	} else {
+		log.Printf("[GIT SYNC] Verifying host key fingerprint for %s:%d", host, port)
+		var p *proxy.Proxy
+		if p, err = proxy.New(g.details.Proxy); err != nil {
+			return "", fmt.Errorf("invalid proxy: %w", err)
+		}
+		content, err = sshutil.KnownHostsForFingerprint(host, port, g.details.HostKeyFingerprint, 10*time.Second, p)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return sshutil.WriteKnownHostsFile(dir, content)
+}
+
+// createTempKeyFile creates a temporary file for the SSH private key in dir
+func (g *GitSyncer) createTempKeyFile(dir string, privateKeyBytes []byte) (string, error) {
+	tmpFile, err := os.CreateTemp(dir, "git_ssh_key_*")
 	if err != nil {
 		return "", fmt.Errorf("failed to create temporary key file: %w", err)
 	}
@@ -572,27 +1154,27 @@ func (g *GitSyncer) urlsMatch(url1, url2 string) bool {
 }
 
 // getDefaultBranch gets the default branch from the remote repository
-func (g *GitSyncer) getDefaultBranch() (string, error) {
+func (g *GitSyncer) getDefaultBranch(ctx context.Context) (string, error) {
 	log.Printf("[GIT SYNC] Getting default branch from remote repository")
 
 	// Try to get the default branch from remote HEAD with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), g.timeout)
+	cmdCtx, cancel := context.WithTimeout(ctx, g.timeout)
 	defer cancel()
 
-	output, err := exec.CommandContext(ctx, "git", "-C", g.targetDir, "symbolic-ref", "refs/remotes/origin/HEAD").Output()
+	output, err := g.gitCommand(cmdCtx, "-C", g.targetDir, "symbolic-ref", "refs/remotes/origin/HEAD").Output()
 	if err != nil {
-		if ctx.Err() == context.DeadlineExceeded {
+		if cmdCtx.Err() == context.DeadlineExceeded {
 			log.Printf("[GIT SYNC] ERROR: Git symbolic-ref command timed out after %v", g.timeout)
 			return "", fmt.Errorf("git symbolic-ref command timed out after %v", g.timeout)
 		}
 
 		// If that fails, try to set the remote HEAD first
 		log.Printf("[GIT SYNC] Failed to get remote HEAD, trying to set it")
-		if err := g.runGitInTarget([]string{"remote", "set-head", "origin", "--auto"}); err != nil {
+		if err := g.runGitInTarget(ctx, []string{"remote", "set-head", "origin", "--auto"}); err != nil {
 			log.Printf("[GIT SYNC] Failed to set remote HEAD, falling back to common branch names")
 			// Try common branch names
 			for _, branchName := range []string{"main", "master", "develop"} {
-				if err := g.runGitInTarget([]string{"checkout", "-B", branchName, "origin/" + branchName}); err == nil {
+				if err := g.runGitInTarget(ctx, []string{"checkout", "-B", branchName, "origin/" + branchName}); err == nil {
 					log.Printf("[GIT SYNC] Successfully checked out branch: %s", branchName)
 					return branchName, nil
 				}
@@ -601,10 +1183,10 @@ func (g *GitSyncer) getDefaultBranch() (string, error) {
 		}
 
 		// Try again after setting remote HEAD with timeout
-		retryCtx, retryCancel := context.WithTimeout(context.Background(), g.timeout)
+		retryCtx, retryCancel := context.WithTimeout(ctx, g.timeout)
 		defer retryCancel()
 
-		output, err = exec.CommandContext(retryCtx, "git", "-C", g.targetDir, "symbolic-ref", "refs/remotes/origin/HEAD").Output()
+		output, err = g.gitCommand(retryCtx, "-C", g.targetDir, "symbolic-ref", "refs/remotes/origin/HEAD").Output()
 		if err != nil {
 			if retryCtx.Err() == context.DeadlineExceeded {
 				log.Printf("[GIT SYNC] ERROR: Git symbolic-ref retry command timed out after %v", g.timeout)
@@ -0,0 +1,462 @@
+package git
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	gogit "github.com/go-git/go-git/v5"
+	gogitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/sharedvolume/volume-syncer/internal/sshutil"
+	"github.com/sharedvolume/volume-syncer/internal/utils"
+)
+
+// Git implementations selectable through Options.Implementation
+const (
+	ImplementationExec  = "exec"
+	ImplementationGoGit = "go-git"
+)
+
+// useGoGit reports whether this sync can run with the in-process go-git implementation.
+// Features go-git does not support fall back to the git binary.
+func (g *GitSyncer) useGoGit() bool {
+	if g.options.Implementation != ImplementationGoGit {
+		return false
+	}
+
+	var reason string
+	switch {
+	case g.details.Submodules != "" && g.details.Submodules != "none":
+		reason = "submodules"
+	case len(g.details.Paths) > 0:
+		reason = "sparse checkout"
+	case g.filteredCheckout():
+		reason = "file filters"
+	case g.details.Filter != "":
+		reason = "partial clone filter"
+	case g.details.Export:
+		reason = "export mode"
+	case g.options.CacheDir != "":
+		reason = "object cache"
+	case g.details.TLS != nil && g.details.TLS.MinVersion != "":
+		reason = "tls.minVersion"
+	case g.details.Proxy != nil:
+		reason = "proxy"
+	case g.isSSHURL() && g.details.PrivateKey == "" && !g.hasAgent():
+		reason = "SSH without private key or ssh-agent"
+	case usesLFS(g.targetDir):
+		reason = "Git LFS"
+	default:
+		return true
+	}
+
+	log.Printf("[GIT SYNC] go-git does not support %s, falling back to the git binary", reason)
+	return false
+}
+
+// isSSHURL reports whether the repository URL uses the SSH transport
+func (g *GitSyncer) isSSHURL() bool {
+	_, _, ok := sshutil.ParseGitSSHEndpoint(g.details.URL)
+	return ok
+}
+
+// hasAgent reports whether an ssh-agent socket is available for this repository
+func (g *GitSyncer) hasAgent() bool {
+	socket, err := g.agentSocket()
+	return err == nil && socket != ""
+}
+
+// usesLFS reports whether the worktree in dir declares Git LFS attributes
+func usesLFS(dir string) bool {
+	data, err := os.ReadFile(filepath.Join(dir, ".gitattributes"))
+	if err != nil {
+		return false
+	}
+	return strings.Contains(string(data), "filter=lfs")
+}
+
+// goGitAuth builds the go-git authentication method for the configured credentials.
+// The returned function releases the ssh-agent connection, if any.
+func (g *GitSyncer) goGitAuth() (transport.AuthMethod, func(), error) {
+	if user, pass, ok := g.httpCredentials(); ok {
+		log.Printf("[GIT SYNC] Using HTTP(S) basic authentication for user: %s", user)
+		return &githttp.BasicAuth{Username: user, Password: pass}, utils.NoCleanup, nil
+	}
+
+	if !g.isSSHURL() {
+		return nil, utils.NoCleanup, nil
+	}
+
+	endpoint, err := transport.NewEndpoint(g.details.URL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse repository URL: %w", err)
+	}
+	user := endpoint.User
+	if user == "" {
+		user = "git"
+	}
+
+	hostKeyCallback, err := g.goGitHostKeyCallback()
+	if err != nil {
+		return nil, nil, fmt.Errorf("host key verification setup failed: %w", err)
+	}
+
+	if g.details.PrivateKey == "" {
+		socket, err := g.agentSocket()
+		if err != nil {
+			return nil, nil, fmt.Errorf("ssh-agent setup failed: %w", err)
+		}
+		log.Printf("[GIT SYNC] Using ssh-agent authentication via %s", socket)
+		agentClient, closeAgent, err := sshutil.DialAgent(socket)
+		if err != nil {
+			return nil, nil, err
+		}
+		auth := &gitssh.PublicKeysCallback{User: user, Callback: agentClient.Signers}
+		auth.HostKeyCallback = hostKeyCallback
+		return auth, closeAgent, nil
+	}
+
+	log.Printf("[GIT SYNC] Setting up SSH key authentication")
+	privateKeyBytes, err := base64.StdEncoding.DecodeString(g.details.PrivateKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decode base64 private key: %w", err)
+	}
+
+	keys, err := gitssh.NewPublicKeys(user, privateKeyBytes, "")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+	keys.HostKeyCallback = hostKeyCallback
+	return keys, utils.NoCleanup, nil
+}
+
+// goGitTLS returns the CA certificates trusted in addition to the system roots and the client
+// certificate and key of the repository
+func (g *GitSyncer) goGitTLS() (caBundle, clientCert, clientKey []byte, err error) {
+	options := g.details.TLS
+	if options == nil {
+		return nil, nil, nil, nil
+	}
+	if _, err := g.tlsScope(); err != nil {
+		return nil, nil, nil, err
+	}
+	if (options.ClientCert == "") != (options.ClientKey == "") {
+		return nil, nil, nil, fmt.Errorf("tls.clientCert and tls.clientKey must be given together")
+	}
+	if options.CACert != "" {
+		caBundle = []byte(options.CACert)
+	}
+	if options.ClientCert != "" {
+		clientCert, clientKey = []byte(options.ClientCert), []byte(options.ClientKey)
+	}
+	return caBundle, clientCert, clientKey, nil
+}
+
+// goGitHostKeyCallback returns the SSH host key callback matching the configured host key material
+func (g *GitSyncer) goGitHostKeyCallback() (ssh.HostKeyCallback, error) {
+	if g.details.HostKeyFingerprint != "" {
+		log.Printf("[GIT SYNC] Verifying host key fingerprint")
+		fingerprint := g.details.HostKeyFingerprint
+		return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			if !sshutil.FingerprintMatches(key, fingerprint) {
+				return fmt.Errorf("host key fingerprint mismatch for %s: got %s", hostname, ssh.FingerprintSHA256(key))
+			}
+			return nil
+		}, nil
+	}
+
+	if g.details.KnownHosts == "" {
+		if g.options.StrictHostKeys {
+			return nil, fmt.Errorf("strict host key checking is enabled: knownHosts or hostKeyFingerprint is required for SSH repositories")
+		}
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+
+	// knownhosts parses the file when the callback is created, so it can be removed right away
+	dir, err := os.MkdirTemp(g.options.WorkDirs.Credentials(), "volume-syncer-git-ssh-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create SSH working directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	knownHostsFile, err := g.createKnownHostsFile(dir)
+	if err != nil {
+		return nil, err
+	}
+	return gitssh.NewKnownHostsCallback(knownHostsFile)
+}
+
+// goGitDepth returns the clone/fetch depth, defaulting to a shallow clone
+func (g *GitSyncer) goGitDepth() int {
+	if g.details.Depth > 0 {
+		return g.details.Depth
+	}
+	return 1
+}
+
+// goGitClone clones the repository into the target directory using go-git
+func (g *GitSyncer) goGitClone(ctx context.Context, branch string) error {
+	log.Printf("[GIT SYNC] Starting fresh clone of repository using go-git")
+
+	auth, closeAuth, err := g.goGitAuth()
+	if err != nil {
+		return err
+	}
+	defer closeAuth()
+	caBundle, clientCert, clientKey, err := g.goGitTLS()
+	if err != nil {
+		return err
+	}
+
+	cmdCtx, cancel := context.WithTimeout(ctx, g.timeout)
+	defer cancel()
+
+	opts := &gogit.CloneOptions{
+		URL:          g.details.URL,
+		Auth:         auth,
+		CABundle:     caBundle,
+		ClientCert:   clientCert,
+		ClientKey:    clientKey,
+		Depth:        g.goGitDepth(),
+		SingleBranch: true,
+		NoCheckout:   true,
+		Progress:     os.Stdout,
+	}
+	if branch != "" {
+		opts.ReferenceName = plumbing.NewBranchReferenceName(branch)
+		log.Printf("[GIT SYNC] Cloning specific branch: %s", branch)
+	} else {
+		log.Printf("[GIT SYNC] Cloning repository's default branch")
+	}
+	log.Printf("[GIT SYNC] Using clone depth: %d", opts.Depth)
+
+	repo, err := gogit.PlainCloneContext(cmdCtx, g.targetDir, false, opts)
+	if err != nil {
+		if cmdCtx.Err() == context.DeadlineExceeded {
+			log.Printf("[GIT SYNC] ERROR: Git clone timed out after %v", g.timeout)
+			return fmt.Errorf("git clone timed out after %v", g.timeout)
+		}
+		log.Printf("[GIT SYNC] ERROR: Git clone failed: %v", err)
+		return fmt.Errorf("git clone failed: %w", err)
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return fmt.Errorf("failed to resolve HEAD: %w", err)
+	}
+
+	// LFS content needs the git-lfs filters, which only the git binary provides
+	if commitUsesLFS(repo, head.Hash()) {
+		log.Printf("[GIT SYNC] Repository uses Git LFS, falling back to the git binary")
+		if err := os.RemoveAll(filepath.Join(g.targetDir, ".git")); err != nil {
+			return fmt.Errorf("failed to remove go-git clone: %w", err)
+		}
+		g.options.Implementation = ImplementationExec
+		return g.cloneRepo(ctx, branch)
+	}
+
+	if err := g.goGitReset(repo, head.Hash()); err != nil {
+		return err
+	}
+
+	log.Printf("[GIT SYNC] Git clone completed successfully: repo=%s targetDir=%s branch=%s commit=%s",
+		maskCredentials(g.details.URL), g.targetDir, head.Name().Short(), head.Hash())
+	return nil
+}
+
+// goGitSyncExisting updates an existing repository using go-git
+func (g *GitSyncer) goGitSyncExisting(ctx context.Context, branch string) error {
+	log.Printf("[GIT SYNC] Syncing existing repository at %s using go-git", g.targetDir)
+
+	repo, err := gogit.PlainOpen(g.targetDir)
+	if err != nil {
+		log.Printf("[GIT SYNC] ERROR: Failed to open repository: %v", err)
+		return fmt.Errorf("failed to open repository: %w", err)
+	}
+
+	log.Printf("[GIT SYNC] Checking remote URL...")
+	remote, err := repo.Remote("origin")
+	if err != nil {
+		log.Printf("[GIT SYNC] ERROR: Failed to get remote: %v", err)
+		return fmt.Errorf("failed to get remote URL: %w", err)
+	}
+	urls := remote.Config().URLs
+	if len(urls) == 0 || !g.urlsMatch(urls[0], g.details.URL) {
+		log.Printf("[GIT SYNC] Remote URL mismatch, need to replace with different repository")
+		log.Printf("[GIT SYNC] SAFETY: Will attempt clone to temporary location first to verify operation")
+		return g.safeCloneWithReplace(ctx, branch)
+	}
+	log.Printf("[GIT SYNC] Remote URL matches, proceeding with sync")
+
+	auth, closeAuth, err := g.goGitAuth()
+	if err != nil {
+		return err
+	}
+	defer closeAuth()
+	caBundle, clientCert, clientKey, err := g.goGitTLS()
+	if err != nil {
+		return err
+	}
+
+	cmdCtx, cancel := context.WithTimeout(ctx, g.timeout)
+	defer cancel()
+
+	log.Printf("[GIT SYNC] Comparing remote head with local HEAD...")
+	refs, err := remote.ListContext(cmdCtx, &gogit.ListOptions{Auth: auth, CABundle: caBundle, ClientCert: clientCert, ClientKey: clientKey})
+	if err != nil {
+		if cmdCtx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("git ls-remote timed out after %v", g.timeout)
+		}
+		log.Printf("[GIT SYNC] ERROR: Failed to list remote references: %v", err)
+		return fmt.Errorf("git ls-remote failed: %w", err)
+	}
+	branchRef, remoteHash, err := resolveRemoteBranch(refs, branch)
+	if err != nil {
+		return err
+	}
+
+	if head, err := repo.Head(); err == nil && head.Hash() == remoteHash && head.Name() == branchRef {
+		if clean, err := g.goGitWorktreeClean(repo); err == nil && clean {
+			log.Printf("[GIT SYNC] Local HEAD matches remote and worktree is clean, skipping fetch")
+			g.changed = false
+			return nil
+		}
+	}
+
+	log.Printf("[GIT SYNC] Fetching latest changes...")
+	remoteRef := plumbing.NewRemoteReferenceName("origin", branchRef.Short())
+	err = repo.FetchContext(cmdCtx, &gogit.FetchOptions{
+		RemoteName: "origin",
+		RefSpecs:   []gogitconfig.RefSpec{gogitconfig.RefSpec(fmt.Sprintf("+%s:%s", branchRef, remoteRef))},
+		Auth:       auth,
+		CABundle:   caBundle,
+		ClientCert: clientCert,
+		ClientKey:  clientKey,
+		Depth:      g.goGitDepth(),
+		Force:      true,
+		Progress:   os.Stdout,
+	})
+	if err != nil && !errors.Is(err, gogit.NoErrAlreadyUpToDate) {
+		if cmdCtx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("git fetch timed out after %v", g.timeout)
+		}
+		log.Printf("[GIT SYNC] ERROR: Git fetch failed: %v", err)
+		return fmt.Errorf("git fetch failed: %w", err)
+	}
+	log.Printf("[GIT SYNC] Fetch completed successfully")
+
+	// Force the local branch to the remote commit and check it out
+	log.Printf("[GIT SYNC] Checking out branch %s...", branchRef.Short())
+	if err := repo.Storer.SetReference(plumbing.NewHashReference(branchRef, remoteHash)); err != nil {
+		return fmt.Errorf("failed to update branch %s: %w", branchRef.Short(), err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return fmt.Errorf("failed to open worktree: %w", err)
+	}
+	if err := worktree.Checkout(&gogit.CheckoutOptions{Branch: branchRef, Force: true}); err != nil {
+		log.Printf("[GIT SYNC] ERROR: Git checkout failed: %v", err)
+		return fmt.Errorf("git checkout %s failed: %w", branchRef.Short(), err)
+	}
+
+	if err := g.goGitReset(repo, remoteHash); err != nil {
+		return err
+	}
+
+	log.Printf("[GIT SYNC] Git repo synced to origin/%s (%s)", branchRef.Short(), remoteHash)
+	return nil
+}
+
+// goGitReset hard-resets the worktree to commit and removes untracked files
+func (g *GitSyncer) goGitReset(repo *gogit.Repository, commit plumbing.Hash) error {
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return fmt.Errorf("failed to open worktree: %w", err)
+	}
+
+	log.Printf("[GIT SYNC] Resetting to %s...", commit)
+	if err := worktree.Reset(&gogit.ResetOptions{Commit: commit, Mode: gogit.HardReset}); err != nil {
+		log.Printf("[GIT SYNC] ERROR: Git reset failed: %v", err)
+		return fmt.Errorf("git reset failed: %w", err)
+	}
+
+	log.Printf("[GIT SYNC] Cleaning untracked files...")
+	if err := worktree.Clean(&gogit.CleanOptions{Dir: true}); err != nil {
+		log.Printf("[GIT SYNC] ERROR: Git clean failed: %v", err)
+		return fmt.Errorf("git clean failed: %w", err)
+	}
+	return nil
+}
+
+// goGitWorktreeClean reports whether the worktree has no local modifications
+func (g *GitSyncer) goGitWorktreeClean(repo *gogit.Repository) (bool, error) {
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return false, err
+	}
+	status, err := worktree.Status()
+	if err != nil {
+		return false, err
+	}
+	return status.IsClean(), nil
+}
+
+// resolveRemoteBranch finds the branch reference and commit to sync from a remote reference listing.
+// An empty branch resolves the remote default branch through its symbolic HEAD.
+func resolveRemoteBranch(refs []*plumbing.Reference, branch string) (plumbing.ReferenceName, plumbing.Hash, error) {
+	var target plumbing.ReferenceName
+	if branch != "" {
+		target = plumbing.NewBranchReferenceName(branch)
+	} else {
+		for _, ref := range refs {
+			if ref.Name() == plumbing.HEAD && ref.Type() == plumbing.SymbolicReference {
+				target = ref.Target()
+				break
+			}
+		}
+		if target == "" {
+			return "", plumbing.ZeroHash, fmt.Errorf("failed to determine remote default branch")
+		}
+		log.Printf("[GIT SYNC] Using default branch: %s", target.Short())
+	}
+
+	for _, ref := range refs {
+		if ref.Name() == target && ref.Type() == plumbing.HashReference {
+			return target, ref.Hash(), nil
+		}
+	}
+	return "", plumbing.ZeroHash, fmt.Errorf("remote ref %s not found", target)
+}
+
+// commitUsesLFS reports whether the .gitattributes file of commit declares Git LFS attributes
+func commitUsesLFS(repo *gogit.Repository, hash plumbing.Hash) bool {
+	commit, err := repo.CommitObject(hash)
+	if err != nil {
+		return false
+	}
+	file, err := commit.File(".gitattributes")
+	if err != nil {
+		if !errors.Is(err, object.ErrFileNotFound) {
+			log.Printf("[GIT SYNC] WARNING: Failed to read .gitattributes: %v", err)
+		}
+		return false
+	}
+	contents, err := file.Contents()
+	if err != nil {
+		return false
+	}
+	return strings.Contains(contents, "filter=lfs")
+}
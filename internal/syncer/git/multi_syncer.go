@@ -0,0 +1,146 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sharedvolume/volume-syncer/internal/models"
+	"github.com/sharedvolume/volume-syncer/internal/utils"
+)
+
+// defaultParallelism is the number of repositories synced concurrently when not configured
+const defaultParallelism = 4
+
+// MultiGitSyncer syncs several Git repositories into subdirectories of one target
+type MultiGitSyncer struct {
+	details   *models.GitCloneDetails
+	targetDir string
+	timeout   time.Duration
+	options   Options
+	changed   bool
+}
+
+// NewMultiGitSyncer creates a new multi-repository Git syncer
+func NewMultiGitSyncer(details *models.GitCloneDetails, targetDir string, timeout time.Duration, options Options) *MultiGitSyncer {
+	return &MultiGitSyncer{
+		details:   details,
+		targetDir: targetDir,
+		timeout:   timeout,
+		options:   options,
+	}
+}
+
+// Changed reports whether the last Sync modified any repository
+func (m *MultiGitSyncer) Changed() bool {
+	return m.changed
+}
+
+// Sync syncs every configured repository with bounded parallelism
+func (m *MultiGitSyncer) Sync(ctx context.Context) error {
+	m.changed = false
+
+	parallelism := m.details.Parallelism
+	if parallelism <= 0 {
+		parallelism = defaultParallelism
+	}
+
+	log.Printf("[GIT MULTI SYNC] Starting sync of %d repositories into %s (parallelism: %d)", len(m.details.Repos), m.targetDir, parallelism)
+
+	if err := utils.EnsureDir(m.targetDir); err != nil {
+		log.Printf("[GIT MULTI SYNC] ERROR: Failed to create target directory: %v", err)
+		return fmt.Errorf("failed to create target directory: %w", err)
+	}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		errs    []error
+		workers = make(chan struct{}, parallelism)
+	)
+
+	for i := range m.details.Repos {
+		repo := m.details.Repos[i]
+		repoDetails := mergeRepoDefaults(m.details, &repo.GitCloneDetails)
+		repoTarget := filepath.Join(m.targetDir, repo.Path)
+
+		wg.Add(1)
+		workers <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-workers }()
+
+			log.Printf("[GIT MULTI SYNC] Syncing %s into %s", maskCredentials(repoDetails.URL), repoTarget)
+			repoSyncer := NewGitSyncer(repoDetails, repoTarget, m.timeout, m.options)
+			err := repoSyncer.Sync(ctx)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil || repoSyncer.Changed() {
+				m.changed = true
+			}
+			if err != nil {
+				log.Printf("[GIT MULTI SYNC] ERROR: Repository %s failed: %v", repo.Path, err)
+				errs = append(errs, fmt.Errorf("repository %s: %w", repo.Path, err))
+				return
+			}
+			log.Printf("[GIT MULTI SYNC] Repository %s synced successfully", repo.Path)
+		}()
+	}
+
+	wg.Wait()
+
+	if len(errs) > 0 {
+		log.Printf("[GIT MULTI SYNC] ERROR: %d of %d repositories failed", len(errs), len(m.details.Repos))
+		return fmt.Errorf("%d of %d repositories failed to sync: %w", len(errs), len(m.details.Repos), errors.Join(errs...))
+	}
+
+	log.Printf("[GIT MULTI SYNC] All %d repositories synced successfully", len(m.details.Repos))
+	return nil
+}
+
+// mergeRepoDefaults returns the repository details with unset fields taken from the defaults
+func mergeRepoDefaults(defaults, repo *models.GitCloneDetails) *models.GitCloneDetails {
+	merged := *repo
+	merged.Repos = nil
+	merged.Filters = defaults.Filters
+	merged.Proxy = defaults.Proxy
+	merged.FileHandling = defaults.FileHandling
+
+	if merged.Branch == "" {
+		merged.Branch = defaults.Branch
+	}
+	if merged.Depth == 0 {
+		merged.Depth = defaults.Depth
+	}
+	// Credentials are inherited as a unit so a repository can override the authentication method
+	if merged.User == "" && merged.Password == "" && merged.Token == "" && merged.PrivateKey == "" && merged.AgentSocket == "" {
+		merged.User = defaults.User
+		merged.Password = defaults.Password
+		merged.Token = defaults.Token
+		merged.PrivateKey = defaults.PrivateKey
+		merged.AgentSocket = defaults.AgentSocket
+	}
+	if merged.KnownHosts == "" && merged.HostKeyFingerprint == "" {
+		merged.KnownHosts = defaults.KnownHosts
+		merged.HostKeyFingerprint = defaults.HostKeyFingerprint
+	}
+	if merged.TLS == nil {
+		merged.TLS = defaults.TLS
+	}
+	if merged.Submodules == "" {
+		merged.Submodules = defaults.Submodules
+	}
+	if merged.Filter == "" {
+		merged.Filter = defaults.Filter
+	}
+	if !merged.Export {
+		merged.Export = defaults.Export
+	}
+
+	return &merged
+}
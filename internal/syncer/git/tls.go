@@ -0,0 +1,114 @@
+package git
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/sharedvolume/volume-syncer/internal/utils"
+)
+
+// gitSSLVersions maps the accepted TLS version names to the values of git's http.sslVersion
+var gitSSLVersions = map[string]string{
+	"1.0": "tlsv1.0",
+	"1.1": "tlsv1.1",
+	"1.2": "tlsv1.2",
+	"1.3": "tlsv1.3",
+}
+
+// setupCredentials sets up the TLS settings and the SSH authentication of the job. The returned
+// function removes the key material and resets the job environment.
+func (g *GitSyncer) setupCredentials() (func(), error) {
+	cleanupTLS, err := g.setupTLS()
+	if err != nil {
+		return nil, err
+	}
+	cleanupSSH, err := g.setupSSHKey()
+	if err != nil {
+		cleanupTLS()
+		return nil, err
+	}
+	return func() {
+		cleanupSSH()
+		cleanupTLS()
+	}, nil
+}
+
+// setupTLS writes the CA certificates and the client certificate of the repository to a private
+// directory and scopes git's http.ssl* settings to the repository host, so that submodules on
+// other hosts keep verifying against the system roots. The GIT_SSL_* variables of the server
+// would override the settings, they are left out of the environment of git.
+func (g *GitSyncer) setupTLS() (func(), error) {
+	options := g.details.TLS
+	if options == nil {
+		return utils.NoCleanup, nil
+	}
+	scope, err := g.tlsScope()
+	if err != nil {
+		return nil, err
+	}
+
+	var config, unset []string
+	if options.MinVersion != "" {
+		version, ok := gitSSLVersions[options.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("tls.minVersion %q is invalid, expected 1.0, 1.1, 1.2 or 1.3", options.MinVersion)
+		}
+		config = append(config, "http."+scope+".sslVersion="+version)
+		unset = append(unset, "GIT_SSL_VERSION")
+	}
+	if (options.ClientCert == "") != (options.ClientKey == "") {
+		return nil, fmt.Errorf("tls.clientCert and tls.clientKey must be given together")
+	}
+
+	var dir string
+	cleanupFiles := func() {
+		if dir != "" {
+			os.RemoveAll(dir)
+		}
+	}
+	if options.CACert != "" || options.ClientCert != "" {
+		if dir, err = os.MkdirTemp(g.options.WorkDirs.Credentials(), "volume-syncer-git-tls-*"); err != nil {
+			return nil, fmt.Errorf("failed to create TLS working directory: %w", err)
+		}
+	}
+	for _, file := range []struct{ name, key, env, content string }{
+		{"ca.pem", "sslCAInfo", "GIT_SSL_CAINFO", options.CACert},
+		{"client.pem", "sslCert", "GIT_SSL_CERT", options.ClientCert},
+		{"client.key", "sslKey", "GIT_SSL_KEY", options.ClientKey},
+	} {
+		if file.content == "" {
+			continue
+		}
+		path := filepath.Join(dir, file.name)
+		if err := os.WriteFile(path, []byte(file.content), 0600); err != nil {
+			cleanupFiles()
+			return nil, fmt.Errorf("failed to write TLS file: %w", err)
+		}
+		config = append(config, "http."+scope+"."+file.key+"="+path)
+		unset = append(unset, file.env)
+	}
+
+	if options.CACert != "" {
+		log.Printf("[GIT SYNC] Trusting the provided CA certificates for %s", scope)
+	}
+	if options.ClientCert != "" {
+		log.Printf("[GIT SYNC] Presenting the provided client certificate to %s", scope)
+	}
+	g.config, g.unsetEnv = config, unset
+	return func() {
+		cleanupFiles()
+		g.config, g.unsetEnv = nil, nil
+	}, nil
+}
+
+// tlsScope returns the https://host URL the TLS settings apply to
+func (g *GitSyncer) tlsScope() (string, error) {
+	parsedURL, err := url.Parse(g.details.URL)
+	if err != nil || parsedURL.Scheme != "https" {
+		return "", fmt.Errorf("tls settings require an https repository URL")
+	}
+	return "https://" + parsedURL.Host, nil
+}
@@ -0,0 +1,111 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+
+	"github.com/sharedvolume/volume-syncer/internal/models"
+)
+
+// maxIndexSize bounds the size of the index pages read by Browse
+const maxIndexSize = 8 << 20
+
+// Browse lists the links of the HTML index page at the URL, or at the directory under it, that
+// point below the listed directory. Links ending in a slash are directories. At most limit+1
+// entries are returned, so that a truncated listing can be told apart.
+func (h *HTTPSyncer) Browse(ctx context.Context, dir string, limit int) ([]models.BrowseEntry, error) {
+	indexURL := h.details.URL
+	if dir != "" {
+		indexURL = strings.TrimSuffix(indexURL, "/") + "/" + (&url.URL{Path: dir}).EscapedPath() + "/"
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, indexURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	req.Header.Set("User-Agent", userAgent)
+	client, err := h.client()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %s", maskHTTPCredentials(err.Error()))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server responded %s", resp.Status)
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != "text/html" {
+		return nil, fmt.Errorf("%s is not an HTML index page (content type %q)", maskHTTPCredentials(indexURL), mediaType)
+	}
+
+	// Relative links resolve against the URL the redirects ended at
+	base := resp.Request.URL
+	listed := *base
+	listed.Path = listed.Path[:strings.LastIndex(listed.Path, "/")+1]
+	listed.RawPath, listed.RawQuery, listed.Fragment = "", "", ""
+	return indexLinks(io.LimitReader(resp.Body, maxIndexSize), base, &listed, limit+1), nil
+}
+
+// indexLinks returns the distinct links of the page below the listed directory
+func indexLinks(page io.Reader, base, listed *url.URL, limit int) []models.BrowseEntry {
+	var entries []models.BrowseEntry
+	seen := map[string]bool{}
+	tokenizer := html.NewTokenizer(page)
+	for len(entries) < limit {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			break
+		}
+		if tokenType != html.StartTagToken && tokenType != html.SelfClosingTagToken {
+			continue
+		}
+		name, hasAttr := tokenizer.TagName()
+		if string(name) != "a" || !hasAttr {
+			continue
+		}
+		for {
+			key, value, more := tokenizer.TagAttr()
+			if string(key) == "href" {
+				if entry, ok := indexEntry(string(value), base, listed); ok && !seen[entry.Name] {
+					seen[entry.Name] = true
+					entries = append(entries, entry)
+				}
+				break
+			}
+			if !more {
+				break
+			}
+		}
+	}
+	return entries
+}
+
+// indexEntry converts a link into an entry, unless it points outside the listed directory, e.g.
+// to the parent directory, to sort orders or to other sites
+func indexEntry(href string, base, listed *url.URL) (models.BrowseEntry, bool) {
+	link, err := base.Parse(href)
+	if err != nil || link.RawQuery != "" || link.Scheme != listed.Scheme || link.Host != listed.Host {
+		return models.BrowseEntry{}, false
+	}
+	name, ok := strings.CutPrefix(link.Path, listed.Path)
+	if !ok || name == "" || name == "/" {
+		return models.BrowseEntry{}, false
+	}
+	link.Fragment = ""
+	entry := models.BrowseEntry{Name: strings.TrimSuffix(name, "/"), Type: models.BrowseEntryFile, URL: maskHTTPCredentials(link.String())}
+	if strings.HasSuffix(name, "/") {
+		entry.Type = models.BrowseEntryDir
+	}
+	return entry, true
+}
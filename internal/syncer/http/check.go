@@ -0,0 +1,129 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/sharedvolume/volume-syncer/internal/checks"
+	"github.com/sharedvolume/volume-syncer/internal/models"
+)
+
+// Check requests the headers of the URL with HEAD, following redirects like a download, and
+// checks the announced size. Servers rejecting HEAD are asked for the first byte instead.
+func (h *HTTPSyncer) Check(ctx context.Context) []models.SourceCheck {
+	var report checks.Report
+
+	size := int64(-1)
+	report.Run("request", func() (string, error) {
+		ctx, cancel := context.WithTimeout(ctx, h.timeout)
+		defer cancel()
+
+		resp, err := h.head(ctx)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+
+		size = resp.ContentLength
+		if resp.StatusCode == http.StatusPartialContent {
+			size = contentRangeTotal(resp)
+		}
+		message := resp.Status
+		if contentType := resp.Header.Get("Content-Type"); contentType != "" {
+			message += ", " + contentType
+		}
+		if size >= 0 {
+			message += fmt.Sprintf(", %d bytes", size)
+		}
+		return message, nil
+	})
+
+	if h.details.MaxSize > 0 {
+		report.Run("size", func() (string, error) {
+			if size < 0 {
+				return "the server does not announce the size, maxSize is enforced while downloading", nil
+			}
+			if size > h.details.MaxSize {
+				return "", fmt.Errorf("download size %d bytes exceeds maximum allowed size of %d bytes", size, h.details.MaxSize)
+			}
+			return fmt.Sprintf("%d bytes within maxSize of %d bytes", size, h.details.MaxSize), nil
+		})
+	}
+
+	return report.Checks
+}
+
+// Revision returns the validator of the URL: its ETag, or its Last-Modified date and size
+func (h *HTTPSyncer) Revision(ctx context.Context) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	resp, err := h.head(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		return "etag " + etag, nil
+	}
+	if modified := resp.Header.Get("Last-Modified"); modified != "" {
+		size := resp.ContentLength
+		if resp.StatusCode == http.StatusPartialContent {
+			size = contentRangeTotal(resp)
+		}
+		return fmt.Sprintf("modified %s, %d bytes", modified, size), nil
+	}
+	return "", errors.New("the server reports neither an ETag nor a Last-Modified date")
+}
+
+// head requests the headers of the URL with HEAD, asking servers rejecting HEAD for the first byte
+// instead, and checks the response status
+func (h *HTTPSyncer) head(ctx context.Context) (*http.Response, error) {
+	resp, err := h.probe(ctx, http.MethodHead)
+	if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+		resp.Body.Close()
+		resp, err = h.probe(ctx, http.MethodGet)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %s", maskHTTPCredentials(err.Error()))
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		resp.Body.Close()
+		return nil, fmt.Errorf("server responded %s", resp.Status)
+	}
+	return resp, nil
+}
+
+// probe sends a request for the headers of the URL. GET requests ask for the first byte only.
+func (h *HTTPSyncer) probe(ctx context.Context, method string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, h.details.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	if method == http.MethodGet {
+		req.Header.Set("Range", "bytes=0-0")
+	}
+	client, err := h.client()
+	if err != nil {
+		return nil, err
+	}
+	return client.Do(req)
+}
+
+// contentRangeTotal returns the complete size announced by a partial response, or -1
+func contentRangeTotal(resp *http.Response) int64 {
+	_, total, ok := strings.Cut(resp.Header.Get("Content-Range"), "/")
+	if !ok {
+		return -1
+	}
+	size, err := strconv.ParseInt(total, 10, 64)
+	if err != nil {
+		return -1
+	}
+	return size
+}
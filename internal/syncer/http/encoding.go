@@ -0,0 +1,98 @@
+package http
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// acceptEncoding lists the content encodings downloads are decoded from
+const acceptEncoding = "gzip, deflate, zstd"
+
+// contentEncoding returns the Content-Encoding of the response, or "" when it is not encoded
+func contentEncoding(resp *http.Response) string {
+	var encodings []string
+	for _, value := range resp.Header.Values("Content-Encoding") {
+		for _, encoding := range strings.Split(value, ",") {
+			encoding = strings.ToLower(strings.TrimSpace(encoding))
+			if encoding != "" && encoding != "identity" {
+				encodings = append(encodings, encoding)
+			}
+		}
+	}
+	return strings.Join(encodings, ", ")
+}
+
+// compressedName reports whether the file name has the suffix of a gzip file. Servers often send
+// such files with Content-Encoding gzip, although the client is meant to store them compressed.
+func compressedName(encoding, filename string) bool {
+	ext := strings.ToLower(path.Ext(filename))
+	return encoding == "gzip" && (ext == ".gz" || ext == ".tgz")
+}
+
+// decodeContent wraps the body with the decoders of the content encodings, which are listed in
+// the order they were applied. The returned function releases the decoders.
+func decodeContent(body io.Reader, encoding string) (io.Reader, func(), error) {
+	var closers []func()
+	release := func() {
+		for i := len(closers) - 1; i >= 0; i-- {
+			closers[i]()
+		}
+	}
+
+	encodings := strings.Split(encoding, ", ")
+	for i := len(encodings) - 1; i >= 0; i-- {
+		switch encodings[i] {
+		case "gzip", "x-gzip":
+			gz, err := gzip.NewReader(body)
+			if err != nil {
+				release()
+				return nil, nil, fmt.Errorf("invalid gzip response: %w", err)
+			}
+			body = gz
+			closers = append(closers, func() { gz.Close() })
+		case "deflate":
+			// deflate means zlib, but some servers send raw deflate data
+			buffered := bufio.NewReader(body)
+			if header, err := buffered.Peek(2); err == nil && isZlibHeader(header) {
+				zr, err := zlib.NewReader(buffered)
+				if err != nil {
+					release()
+					return nil, nil, fmt.Errorf("invalid deflate response: %w", err)
+				}
+				body = zr
+				closers = append(closers, func() { zr.Close() })
+			} else {
+				fr := flate.NewReader(buffered)
+				body = fr
+				closers = append(closers, func() { fr.Close() })
+			}
+		case "zstd":
+			zr, err := zstd.NewReader(body)
+			if err != nil {
+				release()
+				return nil, nil, fmt.Errorf("invalid zstd response: %w", err)
+			}
+			body = zr
+			closers = append(closers, zr.Close)
+		default:
+			release()
+			return nil, nil, fmt.Errorf("unsupported Content-Encoding %q, set decodeContent to false to store the response as sent", encodings[i])
+		}
+	}
+	return body, release, nil
+}
+
+// isZlibHeader reports whether the bytes start a zlib stream: deflate compression with a valid
+// header checksum
+func isZlibHeader(header []byte) bool {
+	return header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0
+}
@@ -6,21 +6,40 @@ import (
 	"io"
 	"log"
 	"net/http"
-	"os"
 	"path"
 	"regexp"
 	"strings"
 	"time"
 
+	"github.com/sharedvolume/volume-syncer/internal/bwlimit"
+	"github.com/sharedvolume/volume-syncer/internal/cache"
+	"github.com/sharedvolume/volume-syncer/internal/decrypt"
+	"github.com/sharedvolume/volume-syncer/internal/extract"
+	"github.com/sharedvolume/volume-syncer/internal/filter"
 	"github.com/sharedvolume/volume-syncer/internal/models"
+	"github.com/sharedvolume/volume-syncer/internal/progress"
+	"github.com/sharedvolume/volume-syncer/internal/proxy"
+	"github.com/sharedvolume/volume-syncer/internal/transport"
 	"github.com/sharedvolume/volume-syncer/internal/utils"
+	syncerrors "github.com/sharedvolume/volume-syncer/pkg/errors"
 )
 
+// defaultMaxRedirects mirrors the net/http default redirect limit
+const defaultMaxRedirects = 10
+
+// userAgent is sent with every request, as some servers reject clients they do not recognize
+const userAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/115.0.0.0 Safari/537.36"
+
 // HTTPSyncer handles HTTP download synchronization
 type HTTPSyncer struct {
 	details    *models.HTTPDownloadDetails
 	targetPath string
 	timeout    time.Duration
+	downloads  *cache.Cache       // shared download cache, nil when disabled
+	bandwidth  *bwlimit.Limiter   // limits the download, nil when unlimited
+	transports *transport.Factory // transports of the connections to the server, nil for the defaults
+
+	download *models.DownloadReport // Content-Encoding of the last download, nil when it was not encoded
 }
 
 // maskHTTPCredentials masks passwords and sensitive information in URLs
@@ -32,18 +51,38 @@ func maskHTTPCredentials(urlStr string) string {
 }
 
 // NewHTTPSyncer creates a new HTTP syncer
-func NewHTTPSyncer(details *models.HTTPDownloadDetails, targetPath string, timeout time.Duration) *HTTPSyncer {
+func NewHTTPSyncer(details *models.HTTPDownloadDetails, targetPath string, timeout time.Duration, downloads *cache.Cache, bandwidth *bwlimit.Limiter, transports *transport.Factory) *HTTPSyncer {
 	return &HTTPSyncer{
 		details:    details,
 		targetPath: targetPath,
 		timeout:    timeout,
+		downloads:  downloads,
+		bandwidth:  bandwidth,
+		transports: transports,
 	}
 }
 
 // Sync downloads the file from the URL to the target path
-func (h *HTTPSyncer) Sync() error {
+func (h *HTTPSyncer) Sync(ctx context.Context) (err error) {
 	log.Printf("[HTTP SYNC] Starting HTTP download from %s to %s", maskHTTPCredentials(h.details.URL), h.targetPath)
 	log.Printf("[HTTP SYNC] Timeout configured: %v", h.timeout)
+	h.download = nil
+
+	fileFilter, err := filter.New(h.details.Filters)
+	if err != nil {
+		log.Printf("[HTTP SYNC] ERROR: Invalid filters: %v", err)
+		return fmt.Errorf("invalid filters: %w", err)
+	}
+	extractor, err := extract.New(h.details.Extract)
+	if err != nil {
+		log.Printf("[HTTP SYNC] ERROR: Invalid extract options: %v", err)
+		return fmt.Errorf("invalid extract options: %w", err)
+	}
+	decryptor, err := decrypt.New(h.details.Decrypt)
+	if err != nil {
+		log.Printf("[HTTP SYNC] ERROR: Invalid decrypt options: %v", err)
+		return fmt.Errorf("invalid decrypt options: %w", err)
+	}
 
 	// Ensure the target directory exists
 	log.Printf("[HTTP SYNC] Creating target directory: %s", h.targetPath)
@@ -53,19 +92,52 @@ func (h *HTTPSyncer) Sync() error {
 	}
 	log.Printf("[HTTP SYNC] Target directory created successfully")
 
-	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
 	defer cancel()
 
+	reporter := progress.From(ctx)
+	reporter.Phase(progress.PhaseConnecting)
 	log.Printf("[HTTP SYNC] Creating HTTP request...")
 	req, err := http.NewRequestWithContext(ctx, "GET", h.details.URL, nil)
 	if err != nil {
 		log.Printf("[HTTP SYNC] ERROR: Failed to create HTTP request: %v", err)
 		return fmt.Errorf("failed to create HTTP request: %w", err)
 	}
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/115.0.0.0 Safari/537.36")
+	req.Header.Set("User-Agent", userAgent)
 	log.Printf("[HTTP SYNC] HTTP request created with User-Agent header")
 
-	client := &http.Client{}
+	// Encoded responses are decoded below instead of by the transport, which only decodes gzip and
+	// leaves the responses of range requests encoded
+	decode := h.details.DecodeContent == nil || *h.details.DecodeContent
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+	if !decode {
+		req.Header.Set("Accept-Encoding", "identity")
+	}
+
+	// An interrupted download of the URL continues where it stopped
+	partial := h.partialDownload()
+	offset, validator := partial.resumeFrom()
+	if offset > 0 {
+		log.Printf("[HTTP SYNC] Resuming interrupted download at byte %d", offset)
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		req.Header.Set("If-Range", validator)
+		// The partial download holds decoded bytes, the range must not apply to an encoding
+		req.Header.Set("Accept-Encoding", "identity")
+	}
+
+	// A cached version of the URL is revalidated instead of downloaded again
+	ref, cached := h.downloads.Ref(h.details.URL)
+	cached = cached && offset == 0
+	if cached {
+		log.Printf("[HTTP SYNC] Download cache holds ETag %s, revalidating", ref.ETag)
+		req.Header.Set("If-None-Match", ref.ETag)
+	}
+
+	client, err := h.client()
+	if err != nil {
+		log.Printf("[HTTP SYNC] ERROR: %v", err)
+		return err
+	}
 	log.Printf("[HTTP SYNC] Sending HTTP request...")
 	resp, err := client.Do(req)
 	if err != nil {
@@ -78,9 +150,53 @@ func (h *HTTPSyncer) Sync() error {
 	log.Printf("[HTTP SYNC] Response headers - Content-Type: %s, Content-Length: %s",
 		resp.Header.Get("Content-Type"), resp.Header.Get("Content-Length"))
 
-	if resp.StatusCode != http.StatusOK {
+	var body io.Reader = h.bandwidth.Reader(ctx, resp.Body)
+	contentLength := resp.ContentLength
+	var cacheKey string
+	if cached && resp.StatusCode == http.StatusNotModified {
+		cacheKey = cache.Key(h.details.URL, ref.ETag)
+		file, err := h.downloads.Open(cacheKey)
+		if err != nil {
+			log.Printf("[HTTP SYNC] ERROR: Cached download disappeared: %v", err)
+			return fmt.Errorf("failed to open cached download: %w", err)
+		}
+		defer file.Close()
+		if info, err := file.Stat(); err == nil {
+			contentLength = info.Size()
+		}
+		log.Printf("[HTTP SYNC] Not modified, using cached download (%d bytes)", contentLength)
+		body = file
+	} else if resp.StatusCode == http.StatusPartialContent && offset > 0 {
+		if start, err := contentRangeStart(resp); err != nil || start != offset {
+			partial.remove()
+			return syncerrors.NewNetworkError("server resumed the download at the wrong position, restarting", err)
+		}
+		if contentLength >= 0 {
+			contentLength += offset
+		}
+		log.Printf("[HTTP SYNC] Server resumed the download, %d bytes already downloaded", offset)
+	} else if resp.StatusCode != http.StatusOK {
 		log.Printf("[HTTP SYNC] ERROR: HTTP request failed with status: %s", resp.Status)
+		if offset > 0 {
+			// The next attempt downloads the whole file, e.g. after 416 Range Not Satisfiable
+			partial.remove()
+			return syncerrors.NewNetworkError(fmt.Sprintf("resuming download failed: %s", resp.Status), nil)
+		}
+		if resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusRequestTimeout {
+			// The server may recover, the sync is retried
+			return syncerrors.NewNetworkError(fmt.Sprintf("HTTP request failed: %s", resp.Status), nil)
+		}
 		return fmt.Errorf("HTTP request failed: %s", resp.Status)
+	} else if offset > 0 {
+		// The server ignored the range, e.g. because the file changed since the interruption
+		log.Printf("[HTTP SYNC] Server sent the complete file, restarting the download")
+		offset = 0
+	}
+
+	// Reject oversized downloads early when the server announces the size
+	if h.details.MaxSize > 0 && contentLength > h.details.MaxSize {
+		log.Printf("[HTTP SYNC] ERROR: Content-Length %d exceeds maxSize %d", contentLength, h.details.MaxSize)
+		return fmt.Errorf("download size %d bytes exceeds maximum allowed size of %d bytes", contentLength, h.details.MaxSize)
 	}
 
 	// Extract filename from URL
@@ -92,35 +208,311 @@ func (h *HTTPSyncer) Sync() error {
 	log.Printf("[HTTP SYNC] Initial filename from URL: %s", filename)
 
 	// If Content-Disposition header is present, prefer that filename
-	if cd := resp.Header.Get("Content-Disposition"); cd != "" {
+	if cacheKey != "" {
+		filename = ref.Filename
+	} else if cd := resp.Header.Get("Content-Disposition"); cd != "" {
 		log.Printf("[HTTP SYNC] Content-Disposition header found: %s", cd)
-		if idx := strings.Index(cd, "filename="); idx != -1 {
-			fn := cd[idx+len("filename="):]
-			fn = strings.Trim(fn, "\"'")
-			if fn != "" {
-				filename = fn
-				log.Printf("[HTTP SYNC] Using filename from Content-Disposition: %s", filename)
+		if fn := dispositionFilename(cd); fn != "" {
+			filename = fn
+			log.Printf("[HTTP SYNC] Using filename from Content-Disposition: %s", filename)
+		}
+	}
+	// The filename comes from the server, it must name a file directly in the target
+	filename = path.Base(filename)
+	if filename == "." || filename == ".." || filename == "/" {
+		log.Printf("[HTTP SYNC] ERROR: Invalid download filename %q", filename)
+		return fmt.Errorf("invalid download filename %q", filename)
+	}
+
+	// The downloaded file is subject to the same filters as files of other sources
+	if !fileFilter.MatchPath(filename) {
+		log.Printf("[HTTP SYNC] File %s excluded by filters, skipping download", filename)
+		return nil
+	}
+
+	// Encoded responses are stored decoded, except gzip files that servers mark as gzip encoded
+	if encoding := contentEncoding(resp); encoding != "" && cacheKey == "" {
+		if offset > 0 {
+			partial.remove()
+			return syncerrors.NewNetworkError(fmt.Sprintf("server resumed the download with Content-Encoding %s, restarting", encoding), nil)
+		}
+		report := &models.DownloadReport{ContentEncoding: encoding}
+		h.download = report
+		encoded := &countingReader{reader: body}
+		body = encoded
+		defer func() { report.EncodedBytes = encoded.count }()
+
+		if decode && !compressedName(encoding, filename) {
+			decoded, release, err := decodeContent(encoded, encoding)
+			if err != nil {
+				log.Printf("[HTTP SYNC] ERROR: Failed to decode response: %v", err)
+				return err
 			}
+			defer release()
+			counter := &countingReader{reader: decoded}
+			body = counter
+			report.Decoded = true
+			defer func() { report.DecodedBytes = counter.count }()
+			// The decoded size is unknown until the download completed
+			contentLength = -1
+			log.Printf("[HTTP SYNC] Decoding %s response", encoding)
+		} else {
+			log.Printf("[HTTP SYNC] Storing %s encoded response as sent", encoding)
 		}
 	}
 
+	maxFileSize := fileFilter.MaxFileSize()
+	if maxFileSize > 0 && contentLength > maxFileSize {
+		log.Printf("[HTTP SYNC] File %s (%d bytes) exceeds maxFileSize %d, skipping download", filename, contentLength, maxFileSize)
+		return nil
+	}
+
+	// A resumed download starts with the bytes of the interrupted one
+	reporter.Phase(progress.PhaseTransferring)
+	reporter.AddTotal(1, max(contentLength, 0))
+	reporter.File(filename)
+	reporter.Bytes(offset)
+	body = progress.Reader(body, reporter)
+	defer func() {
+		if err == nil {
+			reporter.FilesDone(1)
+		}
+	}()
+
+	readLimit := h.details.MaxSize
+	if maxFileSize > 0 && (readLimit == 0 || maxFileSize < readLimit) {
+		readLimit = maxFileSize
+	}
+	if readLimit > 0 {
+		// Read one byte past the limit so that oversized bodies can be detected
+		body = io.LimitReader(body, max(readLimit+1-offset, 0))
+	} else if etag := resp.Header.Get("ETag"); cacheKey == "" && offset == 0 && etag != "" && h.downloads != nil {
+		// Downloads with an ETag are stored in the download cache while they are written.
+		// Size-limited downloads are not cached, since they may be cut short.
+		entry, cacheErr := h.downloads.Create(cache.Key(h.details.URL, etag))
+		if cacheErr != nil {
+			log.Printf("[HTTP SYNC] WARNING: Download cache unavailable: %v", cacheErr)
+		} else {
+			tee := io.TeeReader(body, entry)
+			body = tee
+			defer func() {
+				if err != nil {
+					entry.Abort()
+					return
+				}
+				// Bytes the processing step did not read still belong to the cached download
+				if _, drainErr := io.Copy(io.Discard, tee); drainErr != nil {
+					entry.Abort()
+					return
+				}
+				if commitErr := entry.Commit(); commitErr != nil {
+					log.Printf("[HTTP SYNC] WARNING: Failed to store download in cache: %v", commitErr)
+					return
+				}
+				if refErr := h.downloads.SetRef(h.details.URL, cache.Ref{ETag: etag, Filename: filename}); refErr != nil {
+					log.Printf("[HTTP SYNC] WARNING: Failed to record cached download: %v", refErr)
+				}
+				log.Printf("[HTTP SYNC] Download stored in cache (ETag %s)", etag)
+			}()
+		}
+	}
+
+	// Encrypted files and archives are processed while downloading, without storing the download
+	name := path.Base(filename)
+	if offset > 0 && (decryptor.Selects(name) || extractor.Selects(name)) {
+		partial.remove()
+		return syncerrors.NewNetworkError("downloads processed while streaming cannot be resumed, restarting", nil)
+	}
+	if decryptor.Selects(name) {
+		log.Printf("[HTTP SYNC] Decrypting %s while downloading...", name)
+		return h.processDownload(body, readLimit, func(r io.Reader) error {
+			return decryptor.DecryptStream(r, h.targetPath, name, extractor)
+		})
+	} else if extractor.Selects(name) {
+		log.Printf("[HTTP SYNC] Extracting archive %s while downloading...", name)
+		if extractor.KeepArchive() {
+			log.Printf("[HTTP SYNC] WARNING: keepArchive has no effect for downloads extracted while streaming")
+		}
+		return h.processDownload(body, readLimit, func(r io.Reader) error {
+			return extractor.ExtractStream(r, h.targetPath, name)
+		})
+	}
+
 	outPath := path.Join(h.targetPath, filename)
+	if !strings.HasPrefix(outPath, path.Clean(h.targetPath)+"/") {
+		return fmt.Errorf("download filename %q is outside the target", filename)
+	}
+	sparse := h.details.FileHandling != nil && h.details.FileHandling.Sparse
+	if cacheKey != "" && !sparse && readLimit == 0 {
+		if linked, err := h.downloads.Populate(cacheKey, outPath); err != nil {
+			return fmt.Errorf("failed to link cached download: %w", err)
+		} else if linked {
+			partial.remove()
+			log.Printf("[HTTP SYNC] File linked from download cache: %s", outPath)
+			return nil
+		}
+	}
+
+	// The download is written next to the output file and replaces it once complete, so that a
+	// hard link into the download cache is never truncated
 	log.Printf("[HTTP SYNC] Creating output file: %s", outPath)
-	out, err := os.Create(outPath)
+	out, err := partial.create(offset)
 	if err != nil {
 		log.Printf("[HTTP SYNC] ERROR: Failed to create target file: %v", err)
 		return fmt.Errorf("failed to create target file: %w", err)
 	}
 	defer out.Close()
 
+	// Responses stored encoded cannot be resumed, as their ranges would not match the encoding
+	resumable := offset > 0
+	if resp.StatusCode == http.StatusOK && cacheKey == "" && (h.download == nil || h.download.Decoded) {
+		if validator := resumeValidator(resp); validator != "" {
+			resumable = partial.save(validator) == nil
+		}
+	}
+
 	log.Printf("[HTTP SYNC] Starting file download...")
-	bytesWritten, err := io.Copy(out, resp.Body)
+	var bytesWritten int64
+	if sparse {
+		// Runs of zeros become holes instead of allocated blocks
+		bytesWritten, err = utils.CopySparse(out, body)
+	} else {
+		bytesWritten, err = io.Copy(out, body)
+	}
+	bytesWritten += offset
 	if err != nil {
 		log.Printf("[HTTP SYNC] ERROR: Failed to write file: %v", err)
+		if !resumable {
+			out.Close()
+			partial.remove()
+		}
 		return fmt.Errorf("failed to write file: %w", err)
 	}
 
+	if maxFileSize > 0 && readLimit == maxFileSize && bytesWritten > maxFileSize {
+		log.Printf("[HTTP SYNC] Download exceeded maxFileSize %d, removing partial file: %s", maxFileSize, outPath)
+		out.Close()
+		partial.remove()
+		return nil
+	}
+
+	if h.details.MaxSize > 0 && bytesWritten > h.details.MaxSize {
+		log.Printf("[HTTP SYNC] ERROR: Download exceeded maxSize %d, removing partial file: %s", h.details.MaxSize, outPath)
+		out.Close()
+		partial.remove()
+		return fmt.Errorf("download exceeds maximum allowed size of %d bytes", h.details.MaxSize)
+	}
+
+	if err := out.Close(); err != nil {
+		partial.remove()
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := partial.complete(outPath); err != nil {
+		partial.remove()
+		return fmt.Errorf("failed to replace target file: %w", err)
+	}
+
 	log.Printf("[HTTP SYNC] Download completed successfully")
 	log.Printf("[HTTP SYNC] File saved: %s (%d bytes)", outPath, bytesWritten)
 	return nil
 }
+
+// Result reports the Content-Encoding of the download
+func (h *HTTPSyncer) Result() models.SyncResult {
+	return models.SyncResult{Download: h.download}
+}
+
+// processDownload passes the download body to the processing step and enforces the size limit
+func (h *HTTPSyncer) processDownload(body io.Reader, readLimit int64, process func(io.Reader) error) error {
+	counter := &countingReader{reader: body}
+	err := process(counter)
+	if readLimit > 0 && counter.count > readLimit {
+		log.Printf("[HTTP SYNC] ERROR: Download exceeded size limit of %d bytes", readLimit)
+		return fmt.Errorf("download exceeds maximum allowed size of %d bytes", readLimit)
+	}
+	if err != nil {
+		log.Printf("[HTTP SYNC] ERROR: Failed to process download: %v", err)
+		return err
+	}
+
+	log.Printf("[HTTP SYNC] Download processed successfully (%d bytes downloaded)", counter.count)
+	return nil
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	c.count += int64(n)
+	return n, err
+}
+
+// client returns a client on the transport of the source that enforces the redirect settings
+func (h *HTTPSyncer) client() (*http.Client, error) {
+	p, err := proxy.New(h.details.Proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy: %w", err)
+	}
+	roundTripper, err := h.transports.Transport(h.details.TLS, p)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TLS settings: %w", err)
+	}
+	return &http.Client{Transport: roundTripper, CheckRedirect: h.checkRedirect}, nil
+}
+
+// checkRedirect enforces the redirect limit and the redirect host allowlist
+func (h *HTTPSyncer) checkRedirect(req *http.Request, via []*http.Request) error {
+	maxRedirects := defaultMaxRedirects
+	if h.details.MaxRedirects != nil {
+		maxRedirects = *h.details.MaxRedirects
+	}
+
+	if len(via) > maxRedirects {
+		log.Printf("[HTTP SYNC] ERROR: Stopped after %d redirects", maxRedirects)
+		return fmt.Errorf("stopped after %d redirects", maxRedirects)
+	}
+
+	if len(h.details.AllowedRedirectHosts) > 0 && !isHostAllowed(req.URL.Hostname(), h.details.AllowedRedirectHosts) {
+		log.Printf("[HTTP SYNC] ERROR: Redirect to host %s is not allowed", req.URL.Hostname())
+		return fmt.Errorf("redirect to host %s is not allowed", req.URL.Hostname())
+	}
+
+	log.Printf("[HTTP SYNC] Following redirect to %s", maskHTTPCredentials(req.URL.String()))
+	return nil
+}
+
+// dispositionFilename returns the filename parameter of a Content-Disposition header, "" when
+// there is none. The parameters following it are not part of the name.
+func dispositionFilename(cd string) string {
+	idx := strings.Index(cd, "filename=")
+	if idx == -1 {
+		return ""
+	}
+	fn := cd[idx+len("filename="):]
+	if end := strings.IndexByte(fn, ';'); end != -1 {
+		fn = fn[:end]
+	}
+	return strings.Trim(strings.TrimSpace(fn), "\"'")
+}
+
+// isHostAllowed reports whether host matches one of the allowed host patterns
+func isHostAllowed(host string, allowedHosts []string) bool {
+	host = strings.ToLower(host)
+	for _, allowed := range allowedHosts {
+		allowed = strings.ToLower(allowed)
+		if strings.HasPrefix(allowed, "*.") {
+			if strings.HasSuffix(host, allowed[1:]) {
+				return true
+			}
+			continue
+		}
+		if host == allowed {
+			return true
+		}
+	}
+	return false
+}
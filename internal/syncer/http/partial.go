@@ -0,0 +1,130 @@
+package http
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// partialPrefix starts the names of the files holding interrupted downloads in the target
+const partialPrefix = ".sync-partial-"
+
+// partialDownload is the file a download is written to until it completes. It is kept when the
+// download is interrupted, so that the next attempt requests the remaining bytes only.
+type partialDownload struct {
+	path      string
+	statePath string
+	url       string
+}
+
+// partialState records the version of the URL held by a partial download
+type partialState struct {
+	URL       string `json:"url"`
+	Validator string `json:"validator"` // ETag or Last-Modified sent in If-Range
+}
+
+// partialDownload returns the partial download of the URL in the target directory
+func (h *HTTPSyncer) partialDownload() *partialDownload {
+	sum := sha256.Sum256([]byte(h.details.URL))
+	path := filepath.Join(h.targetPath, partialPrefix+hex.EncodeToString(sum[:8]))
+	return &partialDownload{path: path, statePath: path + ".json", url: h.details.URL}
+}
+
+// resumeFrom returns the size of the interrupted download and the validator of its version, or
+// 0 when there is no download to resume
+func (p *partialDownload) resumeFrom() (int64, string) {
+	data, err := os.ReadFile(p.statePath)
+	if errors.Is(err, os.ErrNotExist) {
+		os.Remove(p.path)
+		return 0, ""
+	}
+	var state partialState
+	info, statErr := os.Stat(p.path)
+	if err != nil || json.Unmarshal(data, &state) != nil || state.URL != p.url || state.Validator == "" || statErr != nil || info.Size() == 0 {
+		p.remove()
+		return 0, ""
+	}
+	return info.Size(), state.Validator
+}
+
+// create opens the partial download for writing at the offset, truncating it when offset is 0
+func (p *partialDownload) create(offset int64) (*os.File, error) {
+	if offset == 0 {
+		return os.Create(p.path)
+	}
+	file, err := os.OpenFile(p.path, os.O_WRONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+// save records the version of the download, which allows resuming it
+func (p *partialDownload) save(validator string) error {
+	data, err := json.Marshal(partialState{URL: p.url, Validator: validator})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(p.statePath, data, 0600)
+}
+
+// complete moves the finished download to its path in the target
+func (p *partialDownload) complete(outPath string) error {
+	if err := os.Rename(p.path, outPath); err != nil {
+		return err
+	}
+	os.Remove(p.statePath)
+	return nil
+}
+
+// remove deletes the partial download
+func (p *partialDownload) remove() {
+	os.Remove(p.path)
+	os.Remove(p.statePath)
+}
+
+// resumeValidator returns the validator sent in If-Range to resume the response body: a strong
+// ETag, or the Last-Modified date. Responses without one cannot be resumed.
+func resumeValidator(resp *http.Response) string {
+	if etag := resp.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return resp.Header.Get("Last-Modified")
+}
+
+// contentRangeStart returns the first byte position of a partial response
+func contentRangeStart(resp *http.Response) (int64, error) {
+	var start, end int64
+	var total string
+	if _, err := fmt.Sscanf(resp.Header.Get("Content-Range"), "bytes %d-%d/%s", &start, &end, &total); err != nil {
+		return 0, fmt.Errorf("invalid Content-Range %q", resp.Header.Get("Content-Range"))
+	}
+	return start, nil
+}
+
+// RemovePartialDownloads deletes the interrupted downloads kept in the target directory
+func RemovePartialDownloads(targetPath string) error {
+	matches, err := filepath.Glob(filepath.Join(targetPath, partialPrefix+"*"))
+	if err != nil {
+		return err
+	}
+	for _, match := range matches {
+		log.Printf("[HTTP SYNC] Removing interrupted download %s", match)
+		if err := os.Remove(match); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package syncer
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/sharedvolume/volume-syncer/internal/models"
+	"github.com/sharedvolume/volume-syncer/internal/ownership"
+)
+
+// ownershipSyncer applies the owner and permissions to the target once the wrapped syncer completed
+type ownershipSyncer struct {
+	syncer     Syncer
+	mapping    *ownership.Mapping
+	targetPath string
+}
+
+func newOwnershipSyncer(syncer Syncer, options *models.Ownership, targetPath string) *ownershipSyncer {
+	// The options were validated by resolveOwnership
+	mapping, _ := ownership.New(options)
+	return &ownershipSyncer{
+		syncer:     syncer,
+		mapping:    mapping,
+		targetPath: targetPath,
+	}
+}
+
+// Changed reports whether the wrapped syncer modified the target
+func (o *ownershipSyncer) Changed() bool {
+	if reporter, ok := o.syncer.(interface{ Changed() bool }); ok {
+		return reporter.Changed()
+	}
+	return true
+}
+
+// Result returns the source details reported by the wrapped syncer
+func (o *ownershipSyncer) Result() models.SyncResult {
+	if reporter, ok := o.syncer.(interface{ Result() models.SyncResult }); ok {
+		return reporter.Result()
+	}
+	return models.SyncResult{}
+}
+
+// Sync runs the wrapped syncer and applies the ownership to the whole target, so that files
+// changed outside of a sync are corrected as well
+func (o *ownershipSyncer) Sync(ctx context.Context) error {
+	if err := o.syncer.Sync(ctx); err != nil {
+		return err
+	}
+	if err := o.mapping.Apply(o.targetPath); err != nil {
+		log.Printf("[OWNERSHIP] ERROR: Failed to apply ownership: %v", err)
+		return fmt.Errorf("failed to apply ownership: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package syncer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sharedvolume/volume-syncer/internal/metrics"
+	"github.com/sharedvolume/volume-syncer/internal/models"
+	"github.com/sharedvolume/volume-syncer/internal/procgroup"
+	syncerrors "github.com/sharedvolume/volume-syncer/pkg/errors"
+)
+
+// pluginValidateTimeout limits the validation of the source details by a plugin
+const pluginValidateTimeout = 10 * time.Second
+
+// pluginTransientExitCode is the exit status (EX_TEMPFAIL) plugins report transient failures with
+const pluginTransientExitCode = 75
+
+// pluginRequest is written to the standard input of plugin commands
+type pluginRequest struct {
+	Details      interface{}          `json:"details"`
+	TargetPath   string               `json:"targetPath,omitempty"`
+	Filters      *models.Filters      `json:"filters,omitempty"`
+	FileHandling *models.FileHandling `json:"fileHandling,omitempty"`
+	Resume       bool                 `json:"resume,omitempty"`
+}
+
+// LoadPlugins registers every executable in the directory as an exec plugin providing the source
+// type named like the file. Files that are not executable are skipped.
+func LoadPlugins(dir string) ([]string, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plugin directory: %w", err)
+	}
+
+	var loaded []string
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() || info.Mode().Perm()&0111 == 0 {
+			continue
+		}
+		plugin := &execPlugin{name: entry.Name(), path: path}
+		err = RegisterSource(SourceType{
+			Name:     plugin.name,
+			Validate: plugin.validate,
+			Create: func(f *SyncerFactory, details interface{}, targetPath string, settings SourceSettings) (Syncer, error) {
+				return plugin.create(details, targetPath, settings)
+			},
+		})
+		if err != nil {
+			return loaded, fmt.Errorf("failed to register plugin %s: %w", path, err)
+		}
+		log.Printf("[PLUGIN] Registered source type %s provided by %s", plugin.name, path)
+		loaded = append(loaded, plugin.name)
+	}
+	return loaded, nil
+}
+
+// execPlugin provides a source type with an executable, run as "<path> validate" and
+// "<path> sync" with a pluginRequest on standard input
+type execPlugin struct {
+	name string
+	path string
+}
+
+// validate runs the validate command of the plugin, which reports invalid details on standard error
+func (p *execPlugin) validate(details interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), pluginValidateTimeout)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	if err := p.run(ctx, "validate", pluginRequest{Details: details}, io.Discard, &stderr); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return errors.New(message)
+		}
+		return fmt.Errorf("%s plugin rejected the details: %w", p.name, err)
+	}
+	return nil
+}
+
+// create returns the syncer running the sync command of the plugin. Decryption and extraction
+// are applied to the files the plugin wrote into the target.
+func (p *execPlugin) create(details interface{}, targetPath string, settings SourceSettings) (Syncer, error) {
+	created := Syncer(&pluginSyncer{plugin: p, targetPath: targetPath, timeout: settings.Timeout, request: pluginRequest{
+		Details:      details,
+		TargetPath:   targetPath,
+		Filters:      settings.Filters,
+		FileHandling: settings.FileHandling,
+		Resume:       settings.Resume,
+	}})
+	if settings.PostProcess.Decrypt != nil || settings.PostProcess.Extract != nil {
+		created = newPostProcessingSyncer(created, settings.PostProcess, targetPath)
+	}
+	return created, nil
+}
+
+// run executes a plugin command with the request on standard input
+func (p *execPlugin) run(ctx context.Context, command string, request pluginRequest, stdout, stderr io.Writer) error {
+	input, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("failed to encode plugin request: %w", err)
+	}
+	cmd := exec.CommandContext(ctx, p.path, command)
+	procgroup.Configure(cmd)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	err = cmd.Run()
+	metrics.CommandExited(ctx, "plugin", err)
+	return err
+}
+
+// pluginSyncer syncs a source provided by an exec plugin
+type pluginSyncer struct {
+	plugin     *execPlugin
+	targetPath string
+	timeout    time.Duration
+	request    pluginRequest
+}
+
+// Sync runs the sync command of the plugin, which writes the source into the target directory
+func (s *pluginSyncer) Sync(ctx context.Context) error {
+	log.Printf("[PLUGIN] Starting %s sync to %s", s.plugin.name, s.targetPath)
+	if err := os.MkdirAll(s.targetPath, 0755); err != nil {
+		return fmt.Errorf("failed to create target directory: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	err := s.plugin.run(ctx, "sync", s.request, os.Stdout, io.MultiWriter(os.Stderr, &stderr))
+	if err == nil {
+		log.Printf("[PLUGIN] %s sync completed successfully", s.plugin.name)
+		return nil
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		log.Printf("[PLUGIN] ERROR: %s sync timed out after %v", s.plugin.name, s.timeout)
+		return syncerrors.NewTimeoutError(fmt.Sprintf("%s sync timed out after %v", s.plugin.name, s.timeout), err)
+	}
+	log.Printf("[PLUGIN] ERROR: %s sync failed: %v", s.plugin.name, err)
+	message := fmt.Sprintf("%s sync failed", s.plugin.name)
+	if output := strings.TrimSpace(stderr.String()); output != "" {
+		message += ": " + lastLine(output)
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == pluginTransientExitCode {
+		return syncerrors.NewNetworkError(message, err)
+	}
+	return fmt.Errorf("%s: %w", message, err)
+}
+
+// lastLine returns the last line of the output, which holds the error of most commands
+func lastLine(output string) string {
+	return output[strings.LastIndex(output, "\n")+1:]
+}
@@ -0,0 +1,128 @@
+package syncer
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/sharedvolume/volume-syncer/internal/decrypt"
+	"github.com/sharedvolume/volume-syncer/internal/extract"
+	"github.com/sharedvolume/volume-syncer/internal/models"
+)
+
+// resolvePostProcess validates the post-processing options, resolves the referenced secrets and
+// applies the file handling. The request options are copied, so that shared definitions never
+// hold key material.
+func (f *SyncerFactory) resolvePostProcess(postProcess *models.PostProcess, fileHandling *models.FileHandling) (models.PostProcess, error) {
+	var post models.PostProcess
+	if postProcess == nil {
+		return post, nil
+	}
+
+	if postProcess.Decrypt != nil {
+		options := *postProcess.Decrypt
+		options.FileHandling = fileHandling
+		var err error
+		if options.KeyRef != "" {
+			if options.Key, err = f.secrets.Read(options.KeyRef); err != nil {
+				return post, fmt.Errorf("invalid postProcess.decrypt: keyRef: %w", err)
+			}
+		}
+		if options.PassphraseRef != "" {
+			if options.Passphrase, err = f.secrets.Read(options.PassphraseRef); err != nil {
+				return post, fmt.Errorf("invalid postProcess.decrypt: passphraseRef: %w", err)
+			}
+		}
+		if _, err := decrypt.New(&options); err != nil {
+			return post, fmt.Errorf("invalid postProcess.decrypt: %w", err)
+		}
+		post.Decrypt = &options
+	}
+
+	if postProcess.Extract != nil {
+		options := *postProcess.Extract
+		options.FileHandling = fileHandling
+		options.MaxSize = extractLimit(options.MaxSize, f.extractSize)
+		options.MaxEntries = int(extractLimit(int64(options.MaxEntries), int64(f.extractEntries)))
+		if _, err := extract.New(&options); err != nil {
+			return post, fmt.Errorf("invalid postProcess.extract: %w", err)
+		}
+		post.Extract = &options
+	}
+	return post, nil
+}
+
+// extractLimit returns the requested extraction limit, capped by the limit of the server; 0 stands
+// for no limit and negative limits are left to be rejected
+func extractLimit(requested, configured int64) int64 {
+	if requested == 0 || (configured > 0 && requested > configured) {
+		return configured
+	}
+	return requested
+}
+
+// postProcessingSyncer decrypts and extracts the files in the target once the wrapped syncer completed
+type postProcessingSyncer struct {
+	syncer     Syncer
+	decryptor  *decrypt.Decryptor
+	extractor  *extract.Extractor
+	targetPath string
+}
+
+func newPostProcessingSyncer(syncer Syncer, post models.PostProcess, targetPath string) *postProcessingSyncer {
+	// The options were validated by resolvePostProcess
+	decryptor, _ := decrypt.New(post.Decrypt)
+	extractor, _ := extract.New(post.Extract)
+	return &postProcessingSyncer{
+		syncer:     syncer,
+		decryptor:  decryptor,
+		extractor:  extractor,
+		targetPath: targetPath,
+	}
+}
+
+// Changed reports whether the wrapped syncer modified the target
+func (p *postProcessingSyncer) Changed() bool {
+	if reporter, ok := p.syncer.(interface{ Changed() bool }); ok {
+		return reporter.Changed()
+	}
+	return true
+}
+
+// Result returns the source details reported by the wrapped syncer
+func (p *postProcessingSyncer) Result() models.SyncResult {
+	if reporter, ok := p.syncer.(interface{ Result() models.SyncResult }); ok {
+		return reporter.Result()
+	}
+	return models.SyncResult{}
+}
+
+// Sync runs the wrapped syncer, then decrypts the synced encrypted files and extracts the synced archives
+func (p *postProcessingSyncer) Sync(ctx context.Context) error {
+	if err := p.syncer.Sync(ctx); err != nil {
+		return err
+	}
+	if !p.Changed() {
+		log.Printf("[POST PROCESS] Target unchanged, skipping post-processing")
+		return nil
+	}
+
+	if p.decryptor != nil {
+		count, err := p.decryptor.DecryptAll(p.targetPath, p.extractor)
+		if err != nil {
+			log.Printf("[POST PROCESS] ERROR: Decryption failed: %v", err)
+			return fmt.Errorf("decryption failed: %w", err)
+		}
+		log.Printf("[POST PROCESS] Decrypted %d files in %s", count, p.targetPath)
+	}
+
+	if p.extractor != nil {
+		count, err := p.extractor.ExtractAll(p.targetPath)
+		if err != nil {
+			log.Printf("[POST PROCESS] ERROR: Archive extraction failed: %v", err)
+			return fmt.Errorf("archive extraction failed: %w", err)
+		}
+		log.Printf("[POST PROCESS] Extracted %d archives in %s", count, p.targetPath)
+	}
+	return nil
+}
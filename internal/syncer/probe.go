@@ -0,0 +1,170 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sharedvolume/volume-syncer/internal/checks"
+	"github.com/sharedvolume/volume-syncer/internal/models"
+	"github.com/sharedvolume/volume-syncer/internal/probe"
+	"github.com/sharedvolume/volume-syncer/internal/proxy"
+	"github.com/sharedvolume/volume-syncer/internal/syncer/s3"
+)
+
+// probeTimeout bounds each network stage of a probe, which is meant to tell unreachable servers
+// apart quickly
+const probeTimeout = 10 * time.Second
+
+// ProbeSource times the stages of a connection to the server of the source: resolving its host,
+// connecting to it and the TLS or SSH handshake, followed by the connection checks of the source
+// type as the authentication stage. The stages stop at the first failure, and the endpoint
+// probed is returned with them.
+func (f *SyncerFactory) ProbeSource(ctx context.Context, source models.Source) (probe.Endpoint, []models.SourceCheck) {
+	var report checks.Report
+	var sourceType SourceType
+	var details interface{}
+	var endpoint probe.Endpoint
+	report.Run("details", func() (string, error) {
+		var ok bool
+		if sourceType, ok = lookupSource(source.Type); !ok {
+			return "", fmt.Errorf("unsupported source type: %s", source.Type)
+		}
+		if sourceType.Endpoint == nil {
+			return "", fmt.Errorf("%s sources cannot be probed", source.Type)
+		}
+		validated, err := f.CredentialPlaceholders(source)
+		if err != nil {
+			return "", err
+		}
+		if err := ValidateSource(validated); err != nil {
+			return "", err
+		}
+		if details, err = f.sourceDetails(ctx, source); err != nil {
+			return "", err
+		}
+		if endpoint, err = sourceType.Endpoint(f, details); err != nil {
+			return "", err
+		}
+		return "details are valid, probing " + endpoint.Address(), nil
+	})
+	if report.Failed() {
+		return endpoint, report.Checks
+	}
+
+	if !f.probeNetwork(ctx, &report, endpoint) || sourceType.Check == nil {
+		return endpoint, report.Checks
+	}
+	report.Run("authentication", func() (string, error) {
+		sourceChecks := sourceType.Check(f, ctx, details)
+		for _, check := range sourceChecks {
+			if check.Status == models.CheckFailed {
+				return "", fmt.Errorf("%s check failed: %s", check.Name, check.Error)
+			}
+		}
+		names := make([]string, 0, len(sourceChecks))
+		for _, check := range sourceChecks {
+			names = append(names, check.Name)
+		}
+		return "source checks passed: " + strings.Join(names, ", "), nil
+	})
+	return endpoint, report.Checks
+}
+
+// ProbeURL times the stages of a connection to the server of an http(s)://, ssh:// or tcp://
+// URL or of a scp-like Git URL, without authentication
+func (f *SyncerFactory) ProbeURL(ctx context.Context, raw string) (probe.Endpoint, []models.SourceCheck, error) {
+	endpoint, err := probe.ParseURL(raw)
+	if err != nil {
+		return probe.Endpoint{}, nil, err
+	}
+	if endpoint.Protocol == probe.ProtocolTLS {
+		if endpoint.TLS, err = f.transports.TLSConfig(nil); err != nil {
+			return probe.Endpoint{}, nil, err
+		}
+	}
+	var report checks.Report
+	f.probeNetwork(ctx, &report, endpoint)
+	return endpoint, report.Checks, nil
+}
+
+// probeNetwork adds the network stages of the endpoint to the report, through the proxy of the
+// request or the default proxy. It reports whether they passed.
+func (f *SyncerFactory) probeNetwork(ctx context.Context, report *checks.Report, endpoint probe.Endpoint) bool {
+	p, err := proxy.New(f.contextProxy(ctx))
+	if err != nil {
+		report.Run("proxy", func() (string, error) { return "", err })
+		return false
+	}
+	probe.Run(ctx, report, endpoint, p, min(f.timeout, probeTimeout))
+	return !report.Failed()
+}
+
+func (f *SyncerFactory) endpointSSH(details interface{}) (probe.Endpoint, error) {
+	sshDetails, err := parseSSHDetails(details)
+	if err != nil {
+		return probe.Endpoint{}, err
+	}
+	port := sshDetails.Port
+	if port == 0 {
+		port = 22
+	}
+	return probe.Endpoint{Host: sshDetails.Host, Port: port, Protocol: probe.ProtocolSSH}, nil
+}
+
+func (f *SyncerFactory) endpointGit(details interface{}) (probe.Endpoint, error) {
+	gitDetails, err := parseGitDetails(details)
+	if err != nil {
+		return probe.Endpoint{}, err
+	}
+	// A multi-repository source is probed at its first repository
+	url, tlsOptions := gitDetails.URL, gitDetails.TLS
+	if len(gitDetails.Repos) > 0 {
+		url, tlsOptions = gitDetails.Repos[0].URL, gitDetails.Repos[0].TLS
+	}
+	return f.urlEndpoint(url, tlsOptions)
+}
+
+func (f *SyncerFactory) endpointHTTP(details interface{}) (probe.Endpoint, error) {
+	httpDetails, err := parseHTTPDetails(details)
+	if err != nil {
+		return probe.Endpoint{}, err
+	}
+	return f.urlEndpoint(httpDetails.URL, httpDetails.TLS)
+}
+
+func (f *SyncerFactory) endpointS3(details interface{}) (probe.Endpoint, error) {
+	s3Details, err := parseS3Details(details)
+	if err != nil {
+		return probe.Endpoint{}, err
+	}
+	url, verify := s3.ProbeEndpoint(s3Details)
+	endpoint, err := f.urlEndpoint(url, nil)
+	if err != nil {
+		return probe.Endpoint{}, err
+	}
+	if endpoint.TLS != nil && !verify {
+		endpoint.TLS.InsecureSkipVerify = true
+	}
+	return endpoint, nil
+}
+
+// urlEndpoint returns the endpoint of a source URL, verifying HTTPS servers with the TLS settings
+// of the source on top of the shared ones
+func (f *SyncerFactory) urlEndpoint(url string, tlsOptions *models.TLSOptions) (probe.Endpoint, error) {
+	endpoint, err := probe.ParseURL(url)
+	if err != nil {
+		return probe.Endpoint{}, err
+	}
+	if endpoint.Protocol == probe.ProtocolTCP {
+		return probe.Endpoint{}, errors.New("tcp URLs are not source URLs")
+	}
+	if endpoint.Protocol == probe.ProtocolTLS {
+		if endpoint.TLS, err = f.transports.TLSConfig(tlsOptions); err != nil {
+			return probe.Endpoint{}, err
+		}
+	}
+	return endpoint, nil
+}
@@ -0,0 +1,111 @@
+package syncer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/sharedvolume/volume-syncer/internal/models"
+	"github.com/sharedvolume/volume-syncer/internal/quota"
+)
+
+// quotaSyncer checks the budget of the target once the wrapped syncer filled the staging
+// directory, before the staged contents are published
+type quotaSyncer struct {
+	syncer     Syncer
+	limit      *quota.Limit
+	stagingDir string
+	replaced   string // directory replaced by the staged contents, empty when they are added
+}
+
+func newQuotaSyncer(syncer Syncer, limit *quota.Limit, stagingDir, replaced string) *quotaSyncer {
+	return &quotaSyncer{
+		syncer:     syncer,
+		limit:      limit,
+		stagingDir: stagingDir,
+		replaced:   replaced,
+	}
+}
+
+// Changed reports whether the wrapped syncer modified the target
+func (q *quotaSyncer) Changed() bool {
+	if reporter, ok := q.syncer.(interface{ Changed() bool }); ok {
+		return reporter.Changed()
+	}
+	return true
+}
+
+// Result returns the source details reported by the wrapped syncer
+func (q *quotaSyncer) Result() models.SyncResult {
+	if reporter, ok := q.syncer.(interface{ Result() models.SyncResult }); ok {
+		return reporter.Result()
+	}
+	return models.SyncResult{}
+}
+
+// Results returns the per-source results of the wrapped composite syncer
+func (q *quotaSyncer) Results() []models.SourceResult {
+	if reporter, ok := q.syncer.(interface{ Results() []models.SourceResult }); ok {
+		return reporter.Results()
+	}
+	return nil
+}
+
+// Sync refuses to start when the budget is already used up and fails the sync when the
+// published contents would exceed it, so that the staged data is discarded
+func (q *quotaSyncer) Sync(ctx context.Context) error {
+	if err := q.check("before sync"); err != nil {
+		return err
+	}
+	if err := q.syncer.Sync(ctx); err != nil {
+		return err
+	}
+	return q.check("after sync")
+}
+
+// check compares the usage of the quota path after publishing the staging directory with the budget
+func (q *quotaSyncer) check(phase string) error {
+	used, err := q.projectedUsage()
+	if err != nil {
+		log.Printf("[QUOTA] ERROR: Failed to measure usage of %s: %v", q.limit.Path, err)
+		return fmt.Errorf("failed to measure quota usage: %w", err)
+	}
+	log.Printf("[QUOTA] Usage of %s %s: %d of %d bytes", q.limit.Path, phase, used, q.limit.Bytes)
+	if used > q.limit.Bytes {
+		log.Printf("[QUOTA] ERROR: Quota of %s exceeded, discarding staged contents", q.limit.Path)
+		return fmt.Errorf("%w: %s would use %d bytes of its %d byte budget", quota.ErrExceeded, q.limit.Path, used, q.limit.Bytes)
+	}
+	return nil
+}
+
+// projectedUsage returns the usage of the quota path once the staging directory replaced or
+// was added to the target
+func (q *quotaSyncer) projectedUsage() (int64, error) {
+	used, err := quota.Usage(q.limit.Path)
+	if err != nil {
+		return 0, err
+	}
+	if !within(q.stagingDir, q.limit.Path) {
+		staged, err := quota.Usage(q.stagingDir)
+		if err != nil {
+			return 0, err
+		}
+		used += staged
+	}
+	if q.replaced != "" {
+		replaced, err := quota.Usage(q.replaced)
+		if err != nil {
+			return 0, err
+		}
+		used -= replaced
+	}
+	return used, nil
+}
+
+// within reports whether the path is the root or below it
+func within(path, root string) bool {
+	path, root = filepath.Clean(path), filepath.Clean(root)
+	return path == root || strings.HasPrefix(path, root+string(filepath.Separator))
+}
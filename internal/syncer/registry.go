@@ -0,0 +1,200 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sharedvolume/volume-syncer/internal/bwlimit"
+	"github.com/sharedvolume/volume-syncer/internal/models"
+	"github.com/sharedvolume/volume-syncer/internal/probe"
+	"github.com/sharedvolume/volume-syncer/internal/secrets"
+	"github.com/sharedvolume/volume-syncer/internal/validation"
+)
+
+// sourceTypeNameRegex matches the names source types are registered under
+var sourceTypeNameRegex = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+
+// SourceType describes how the factory validates the details of a source type and creates its
+// syncers. Source types are registered with RegisterSource, in-tree from the init function of a
+// file guarded by a build tag, or as exec plugins discovered by LoadPlugins.
+type SourceType struct {
+	Name string
+
+	// Validate checks the source details when requests are validated. Optional.
+	Validate func(details interface{}) error
+
+	// Details returns a pointer to a new value of the typed details, which the 2.0 API decodes
+	// strictly and validates against its binding tags before Validate runs. Optional.
+	Details func() any
+
+	// Create parses the details and creates the syncer writing to the target path
+	Create func(f *SyncerFactory, details interface{}, targetPath string, settings SourceSettings) (Syncer, error)
+
+	// Check runs the connectivity, authentication and permission checks of validated details
+	// without transferring data. Optional.
+	Check func(f *SyncerFactory, ctx context.Context, details interface{}) []models.SourceCheck
+
+	// Browse lists the entries of the source at the relative path, "" for the top level, returning
+	// at most limit+1 entries. Optional.
+	Browse func(f *SyncerFactory, ctx context.Context, details interface{}, path string, limit int) ([]models.BrowseEntry, error)
+
+	// Revision returns an identifier of the current content of the source without transferring
+	// data, e.g. the commit a branch points to, which changes whenever the content does. Watches
+	// poll it to run syncs only when the source changed. Optional.
+	Revision func(f *SyncerFactory, ctx context.Context, details interface{}) (string, error)
+
+	// Endpoint returns the server the validated details connect to, whose reachability is probed
+	// by the probe endpoint. Optional.
+	Endpoint func(f *SyncerFactory, details interface{}) (probe.Endpoint, error)
+
+	// Destinations returns the servers the validated details send credentials to, as hosts with
+	// an optional path such as github.com/acme/app.git, which the allowed hosts of a credential the
+	// source references must all allow. Sources without it cannot reference credentials. Optional.
+	Destinations func(details interface{}) ([]string, error)
+
+	// Mirrors reports whether the details request the removal of target files the source does not
+	// provide, which stages the sync in an empty directory. Optional.
+	Mirrors func(details interface{}) bool
+
+	// AppliesOwnership is set when Create applies settings.Ownership itself instead of the
+	// factory changing the ownership of the synced files afterwards
+	AppliesOwnership bool
+}
+
+// SourceSettings holds the validated request settings passed to the source syncers
+type SourceSettings struct {
+	Filters      *models.Filters
+	PostProcess  models.PostProcess
+	Ownership    *models.Ownership
+	FileHandling *models.FileHandling
+	Resume       bool                 // continue a sync interrupted by a restart
+	Bandwidth    *bwlimit.Limiter     // limits the downloads, nil when unlimited
+	Proxy        *models.ProxyOptions // proxy of the connections to the source, nil to leave it to the environment
+	Timeout      time.Duration        // limit of each network operation, set by the factory
+}
+
+var (
+	sourceTypesMutex sync.RWMutex
+	sourceTypes      = map[string]SourceType{}
+)
+
+func init() {
+	for _, sourceType := range []SourceType{
+		{
+			Name:             "ssh",
+			Validate:         func(details interface{}) error { _, err := parseSSHDetails(details); return err },
+			Details:          func() any { return &models.SSHDetails{} },
+			Create:           (*SyncerFactory).createSSHSyncer,
+			Check:            (*SyncerFactory).checkSSH,
+			Browse:           (*SyncerFactory).browseSSH,
+			Endpoint:         (*SyncerFactory).endpointSSH,
+			Destinations:     destinationsSSH,
+			AppliesOwnership: true,
+		},
+		{
+			Name:         "git",
+			Validate:     func(details interface{}) error { _, err := parseGitDetails(details); return err },
+			Details:      func() any { return &models.GitCloneDetails{} },
+			Create:       (*SyncerFactory).createGitSyncer,
+			Check:        (*SyncerFactory).checkGit,
+			Browse:       (*SyncerFactory).browseGit,
+			Revision:     (*SyncerFactory).revisionGit,
+			Endpoint:     (*SyncerFactory).endpointGit,
+			Destinations: destinationsGit,
+		},
+		{
+			Name:         "http",
+			Validate:     func(details interface{}) error { _, err := parseHTTPDetails(details); return err },
+			Details:      func() any { return &models.HTTPDownloadDetails{} },
+			Create:       (*SyncerFactory).createHTTPSyncer,
+			Check:        (*SyncerFactory).checkHTTP,
+			Browse:       (*SyncerFactory).browseHTTP,
+			Revision:     (*SyncerFactory).revisionHTTP,
+			Endpoint:     (*SyncerFactory).endpointHTTP,
+			Destinations: destinationsHTTP,
+			Mirrors:      mirrorsWhenRequested,
+		},
+		{
+			Name:         "s3",
+			Validate:     func(details interface{}) error { _, err := parseS3Details(details); return err },
+			Details:      func() any { return &models.S3Details{} },
+			Create:       (*SyncerFactory).createS3Syncer,
+			Check:        (*SyncerFactory).checkS3,
+			Browse:       (*SyncerFactory).browseS3,
+			Revision:     (*SyncerFactory).revisionS3,
+			Endpoint:     (*SyncerFactory).endpointS3,
+			Destinations: destinationsS3,
+			Mirrors:      mirrorsWhenRequested,
+		},
+	} {
+		if err := RegisterSource(sourceType); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// RegisterSource adds a source type. Registered names cannot be replaced.
+func RegisterSource(sourceType SourceType) error {
+	if !sourceTypeNameRegex.MatchString(sourceType.Name) {
+		return fmt.Errorf("invalid source type name %q", sourceType.Name)
+	}
+	if sourceType.Create == nil {
+		return fmt.Errorf("source type %s has no Create function", sourceType.Name)
+	}
+
+	sourceTypesMutex.Lock()
+	defer sourceTypesMutex.Unlock()
+	if _, exists := sourceTypes[sourceType.Name]; exists {
+		return fmt.Errorf("source type %s is already registered", sourceType.Name)
+	}
+	sourceTypes[sourceType.Name] = sourceType
+	return nil
+}
+
+// lookupSource returns the registered source type
+func lookupSource(name string) (SourceType, bool) {
+	sourceTypesMutex.RLock()
+	defer sourceTypesMutex.RUnlock()
+	sourceType, ok := sourceTypes[name]
+	return sourceType, ok
+}
+
+// SourceTypes returns the names of the registered source types, sorted
+func SourceTypes() []string {
+	sourceTypesMutex.RLock()
+	defer sourceTypesMutex.RUnlock()
+	names := make([]string, 0, len(sourceTypes))
+	for name := range sourceTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ValidateSource checks that the source type is registered and validates its details
+func ValidateSource(source models.Source) error {
+	sourceType, ok := lookupSource(source.Type)
+	if !ok {
+		return fmt.Errorf("unsupported source type: %s", source.Type)
+	}
+	if sourceType.Validate == nil {
+		return nil
+	}
+	// Referenced values are only resolved when the syncer is created
+	details, err := secrets.Placeholders(source.Details)
+	if err != nil {
+		return fmt.Errorf("invalid secret reference: %w", err)
+	}
+	err = sourceType.Validate(details)
+	var fieldErr *models.FieldError
+	if errors.As(err, &fieldErr) {
+		// Fields are reported relative to the source, like those of DecodeDetails
+		return validation.Field("details."+fieldErr.Field, errors.New(fieldErr.Message))
+	}
+	return err
+}
@@ -0,0 +1,51 @@
+package syncer
+
+import (
+	"context"
+	"time"
+
+	"github.com/sharedvolume/volume-syncer/internal/models"
+)
+
+// sourceDetailsTimeout bounds loading the details of a source when its sync starts
+const sourceDetailsTimeout = time.Minute
+
+// resolvingSyncer loads the details of the source once the sync starts and runs the syncer
+// created from them. Decrypting a credential may call a KMS and resolving a secret reference a
+// secret manager, which must not happen while the sync service holds its lock to queue the job.
+type resolvingSyncer struct {
+	factory *SyncerFactory
+	source  models.Source
+	create  func(details interface{}) (Syncer, error)
+	syncer  Syncer
+}
+
+// Changed reports whether the created syncer modified the target
+func (r *resolvingSyncer) Changed() bool {
+	if reporter, ok := r.syncer.(interface{ Changed() bool }); ok {
+		return reporter.Changed()
+	}
+	return true
+}
+
+// Result returns the source details reported by the created syncer
+func (r *resolvingSyncer) Result() models.SyncResult {
+	if reporter, ok := r.syncer.(interface{ Result() models.SyncResult }); ok {
+		return reporter.Result()
+	}
+	return models.SyncResult{}
+}
+
+// Sync loads the details of the source, creates its syncer and runs it
+func (r *resolvingSyncer) Sync(ctx context.Context) error {
+	detailsCtx, cancel := context.WithTimeout(ctx, sourceDetailsTimeout)
+	details, err := r.factory.sourceDetails(detailsCtx, r.source)
+	cancel()
+	if err != nil {
+		return err
+	}
+	if r.syncer, err = r.create(details); err != nil {
+		return err
+	}
+	return r.syncer.Sync(ctx)
+}
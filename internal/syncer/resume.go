@@ -0,0 +1,62 @@
+package syncer
+
+import (
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sharedvolume/volume-syncer/internal/syncer/http"
+	"github.com/sharedvolume/volume-syncer/internal/versions"
+)
+
+// RemoveInterrupted deletes what an interrupted sync left in and next to the target: the staging
+// directories of atomic and versioned syncs, including those of the sources of composite syncs and
+// those in the configured staging directory, and partial downloads
+func (f *SyncerFactory) RemoveInterrupted(targetPath string) error {
+	for _, dir := range []string{stagingPath(targetPath), versions.New(targetPath).StagingPath()} {
+		if err := removeStaging(dir); err != nil {
+			return err
+		}
+	}
+
+	staging := f.dirs.Staging()
+	err := filepath.WalkDir(targetPath, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.IsDir() {
+			return err
+		}
+		if entry.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if strings.HasSuffix(entry.Name(), stagingSuffix) {
+			if err := removeStaging(path); err != nil {
+				return err
+			}
+			return filepath.SkipDir
+		}
+		if staging != "" {
+			if err := removeStaging(filepath.Join(staging, stagingName(path))); err != nil {
+				return err
+			}
+		}
+		return http.RemovePartialDownloads(path)
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		err = nil
+		if staging != "" {
+			err = removeStaging(filepath.Join(staging, stagingName(targetPath)))
+		}
+	}
+	return err
+}
+
+// removeStaging removes the staging directory of an interrupted sync, if any
+func removeStaging(dir string) error {
+	if _, err := os.Lstat(dir); err != nil {
+		return nil
+	}
+	log.Printf("[SYNCER] Removing staging directory %s of an interrupted sync", dir)
+	return os.RemoveAll(dir)
+}
@@ -0,0 +1,75 @@
+package syncer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sharedvolume/volume-syncer/internal/models"
+	"github.com/sharedvolume/volume-syncer/internal/syncer/git"
+	"github.com/sharedvolume/volume-syncer/internal/syncer/http"
+	"github.com/sharedvolume/volume-syncer/internal/syncer/s3"
+)
+
+// HasRevision reports whether the source type reports revisions, which watches poll
+func HasRevision(sourceType string) bool {
+	registered, ok := lookupSource(sourceType)
+	return ok && registered.Revision != nil
+}
+
+// SourceRevision validates the source details and returns the current revision of the source.
+// Nothing is transferred or written to any target.
+func (f *SyncerFactory) SourceRevision(ctx context.Context, source models.Source) (string, error) {
+	sourceType, ok := lookupSource(source.Type)
+	if !ok {
+		return "", fmt.Errorf("unsupported source type: %s", source.Type)
+	}
+	if sourceType.Revision == nil {
+		return "", fmt.Errorf("source type %s does not report revisions", source.Type)
+	}
+	validated, err := f.CredentialPlaceholders(source)
+	if err != nil {
+		return "", err
+	}
+	if err := ValidateSource(validated); err != nil {
+		return "", err
+	}
+	details, err := f.sourceDetails(ctx, source)
+	if err != nil {
+		return "", err
+	}
+	return sourceType.Revision(f, ctx, details)
+}
+
+func (f *SyncerFactory) revisionGit(ctx context.Context, details interface{}) (string, error) {
+	gitDetails, err := parseGitDetails(details)
+	if err != nil {
+		return "", err
+	}
+	gitDetails.Proxy = f.contextProxy(ctx)
+	if len(gitDetails.Repos) > 0 {
+		return git.NewMultiGitSyncer(gitDetails, "", f.timeout, f.gitOptions).Revision(ctx)
+	}
+	return git.NewGitSyncer(gitDetails, "", f.timeout, f.gitOptions).Revision(ctx)
+}
+
+func (f *SyncerFactory) revisionHTTP(ctx context.Context, details interface{}) (string, error) {
+	httpDetails, err := parseHTTPDetails(details)
+	if err != nil {
+		return "", err
+	}
+	httpDetails.Proxy = f.contextProxy(ctx)
+	return http.NewHTTPSyncer(httpDetails, "", f.timeout, nil, nil, f.transports).Revision(ctx)
+}
+
+func (f *SyncerFactory) revisionS3(ctx context.Context, details interface{}) (string, error) {
+	s3Details, err := parseS3Details(details)
+	if err != nil {
+		return "", err
+	}
+	s3Details.Proxy = f.contextProxy(ctx)
+	s3Syncer, err := s3.NewS3Syncer(s3Details, "", f.timeout, nil, nil)
+	if err != nil {
+		return "", err
+	}
+	return s3Syncer.Revision(ctx)
+}
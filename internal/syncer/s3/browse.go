@@ -0,0 +1,56 @@
+package s3
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/sharedvolume/volume-syncer/internal/models"
+)
+
+// Browse lists the objects and common prefixes directly under the path prefix, or under the
+// directory below it. At most limit+1 entries are returned, so that a truncated listing can be
+// told apart.
+func (s *S3Syncer) Browse(ctx context.Context, dir string, limit int) ([]models.BrowseEntry, error) {
+	prefix := s.details.Path
+	if dir != "" {
+		if prefix != "" && !strings.HasSuffix(prefix, "/") {
+			prefix += "/"
+		}
+		prefix += dir + "/"
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	output, err := s.s3Client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+		Bucket:    aws.String(s.details.BucketName),
+		Prefix:    aws.String(prefix),
+		Delimiter: aws.String("/"),
+		MaxKeys:   aws.Int32(int32(limit + 1)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []models.BrowseEntry
+	for _, common := range output.CommonPrefixes {
+		name := strings.TrimSuffix(strings.TrimPrefix(aws.ToString(common.Prefix), prefix), "/")
+		entries = append(entries, models.BrowseEntry{Name: name, Type: models.BrowseEntryDir})
+	}
+	for _, obj := range output.Contents {
+		name := strings.TrimPrefix(aws.ToString(obj.Key), prefix)
+		// The marker object of the directory itself
+		if name == "" {
+			continue
+		}
+		entries = append(entries, models.BrowseEntry{
+			Name:     name,
+			Type:     models.BrowseEntryFile,
+			Size:     obj.Size,
+			ModTime:  obj.LastModified,
+			Revision: strings.Trim(aws.ToString(obj.ETag), `"`),
+		})
+	}
+	return entries, nil
+}
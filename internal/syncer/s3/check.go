@@ -0,0 +1,66 @@
+package s3
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/sharedvolume/volume-syncer/internal/checks"
+	"github.com/sharedvolume/volume-syncer/internal/models"
+)
+
+// Check lists the bucket and the path prefix without downloading objects
+func (s *S3Syncer) Check(ctx context.Context) []models.SourceCheck {
+	var report checks.Report
+
+	report.Run("bucket", func() (string, error) {
+		if err := s.testConnection(); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("bucket %s listed at %s", s.details.BucketName, s.details.EndpointURL), nil
+	})
+
+	report.Run("prefix", func() (string, error) {
+		ctx, cancel := context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+		output, err := s.s3Client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+			Bucket:  aws.String(s.details.BucketName),
+			Prefix:  aws.String(s.details.Path),
+			MaxKeys: aws.Int32(1),
+		})
+		if err != nil {
+			return "", err
+		}
+		if len(output.Contents) == 0 {
+			return fmt.Sprintf("no objects under prefix %q", s.details.Path), nil
+		}
+		return fmt.Sprintf("objects found under prefix %q", s.details.Path), nil
+	})
+
+	return report.Checks
+}
+
+// Revision returns a digest of the keys, ETags and sizes of the objects under the path prefix
+func (s *S3Syncer) Revision(ctx context.Context) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	digest := sha256.New()
+	paginator := s3.NewListObjectsV2Paginator(s.s3Client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.details.BucketName),
+		Prefix: aws.String(s.details.Path),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return "", fmt.Errorf("failed to list objects: %w", err)
+		}
+		for _, obj := range page.Contents {
+			fmt.Fprintf(digest, "%s\t%s\t%d\n", aws.ToString(obj.Key), aws.ToString(obj.ETag), aws.ToInt64(obj.Size))
+		}
+	}
+	return "sha256:" + hex.EncodeToString(digest.Sum(nil)), nil
+}
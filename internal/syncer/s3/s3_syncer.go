@@ -3,35 +3,80 @@ package s3
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/credentials"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/s3"
-	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsretry "github.com/aws/aws-sdk-go-v2/aws/retry"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/sharedvolume/volume-syncer/internal/bwlimit"
+	"github.com/sharedvolume/volume-syncer/internal/cache"
+	"github.com/sharedvolume/volume-syncer/internal/decrypt"
+	"github.com/sharedvolume/volume-syncer/internal/extract"
+	"github.com/sharedvolume/volume-syncer/internal/filter"
 	"github.com/sharedvolume/volume-syncer/internal/models"
+	"github.com/sharedvolume/volume-syncer/internal/netdial"
+	"github.com/sharedvolume/volume-syncer/internal/progress"
+	"github.com/sharedvolume/volume-syncer/internal/proxy"
+	"github.com/sharedvolume/volume-syncer/internal/quota"
+	"github.com/sharedvolume/volume-syncer/internal/retry"
 	"github.com/sharedvolume/volume-syncer/internal/utils"
 )
 
+// DefaultObjectRetry is how often and how fast an object is downloaded again unless the details
+// set objectRetry
+var DefaultObjectRetry = retry.Policy{Attempts: 3, Backoff: time.Second, MaxBackoff: 30 * time.Second}
+
+// DefaultParallelism is the number of objects downloaded concurrently unless the details set parallelism
+const DefaultParallelism = 4
+
+// MaxParallelism bounds the concurrent downloads of a sync
+const MaxParallelism = 64
+
+// MaxPartConcurrency bounds the parts of an object downloaded concurrently
+const MaxPartConcurrency = 64
+
+// MinPartSize is the smallest part size of the downloads
+const MinPartSize = manager.MinUploadPartSize
+
+// objectQueueSize bounds the listed objects waiting for a download, which keeps the memory use of a
+// sync constant whatever the number of objects
+const objectQueueSize = 1000
+
 // S3Syncer handles S3 synchronization
 type S3Syncer struct {
-	details    *models.S3Details
-	targetPath string
-	timeout    time.Duration
-	session    *session.Session
-	s3Client   *s3.S3
-	downloader *s3manager.Downloader
+	details     *models.S3Details
+	targetPath  string
+	timeout     time.Duration
+	s3Client    *s3.Client
+	downloader  *manager.Downloader
+	filter      *filter.Matcher
+	extractor   *extract.Extractor
+	decryptor   *decrypt.Decryptor
+	retry       retry.Policy     // attempts of each object
+	parallelism int              // objects downloaded concurrently
+	objects     int              // objects listed by the last Sync
+	downloads   *cache.Cache     // shared download cache, nil when disabled
+	bandwidth   *bwlimit.Limiter // limits the downloads, nil when unlimited
 }
 
 // NewS3Syncer creates a new S3 syncer
-func NewS3Syncer(details *models.S3Details, targetPath string, timeout time.Duration) (*S3Syncer, error) {
+func NewS3Syncer(details *models.S3Details, targetPath string, timeout time.Duration, downloads *cache.Cache, bandwidth *bwlimit.Limiter) (*S3Syncer, error) {
 	log.Printf("[S3 SYNC] Initializing S3 syncer")
 	log.Printf("[S3 SYNC] Endpoint: %s", details.EndpointURL)
 	log.Printf("[S3 SYNC] Bucket: %s", details.BucketName)
@@ -55,59 +100,62 @@ func NewS3Syncer(details *models.S3Details, targetPath string, timeout time.Dura
 		log.Printf("[S3 SYNC] Detected S3-compatible service, using path style")
 	}
 
-	// Auto-detect SSL preference
-	disableSSL := false
-	if details.DisableSSL != nil {
-		disableSSL = *details.DisableSSL
-		log.Printf("[S3 SYNC] Using explicit SSL setting - disabled: %v", disableSSL)
-	} else if strings.HasPrefix(details.EndpointURL, "http://") {
-		disableSSL = true
-		log.Printf("[S3 SYNC] Detected HTTP endpoint, disabling SSL")
-	} else {
-		log.Printf("[S3 SYNC] Using SSL (HTTPS)")
-	}
+	endpoint := endpointURL(details)
+	log.Printf("[S3 SYNC] Using endpoint %s", endpoint)
 
-	// Create AWS session
-	log.Printf("[S3 SYNC] Creating AWS session...")
-	config := &aws.Config{
-		Region:           aws.String(details.Region),
-		Endpoint:         aws.String(details.EndpointURL),
-		Credentials:      credentials.NewStaticCredentials(details.AccessKey, details.SecretKey, ""),
-		S3ForcePathStyle: aws.Bool(forcePathStyle),
-		DisableSSL:       aws.Bool(disableSSL),
+	// Load the AWS configuration
+	log.Printf("[S3 SYNC] Loading AWS configuration...")
+	awsConfig, err := loadConfig(details, isAWSS3)
+	if err != nil {
+		log.Printf("[S3 SYNC] ERROR: Failed to load AWS configuration: %v", err)
+		return nil, fmt.Errorf("failed to load AWS configuration: %w", err)
 	}
+	log.Printf("[S3 SYNC] AWS configuration loaded successfully")
 
-	// Additional settings for better compatibility
-	if !isAWSS3 {
-		// For S3-compatible services, disable SSL certificate verification for self-signed certs
-		// This is common in development/private cloud environments
-		config.HTTPClient = &http.Client{
-			Transport: &http.Transport{
-				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-			},
-		}
-		log.Printf("[S3 SYNC] Configured for S3-compatible service with relaxed SSL verification")
+	fileFilter, err := filter.New(details.Filters)
+	if err != nil {
+		log.Printf("[S3 SYNC] ERROR: Invalid filters: %v", err)
+		return nil, fmt.Errorf("invalid filters: %w", err)
 	}
-
-	sess, err := session.NewSession(config)
+	extractor, err := extract.New(details.Extract)
 	if err != nil {
-		log.Printf("[S3 SYNC] ERROR: Failed to create AWS session: %v", err)
-		return nil, fmt.Errorf("failed to create AWS session: %w", err)
+		log.Printf("[S3 SYNC] ERROR: Invalid extract options: %v", err)
+		return nil, fmt.Errorf("invalid extract options: %w", err)
+	}
+	decryptor, err := decrypt.New(details.Decrypt)
+	if err != nil {
+		log.Printf("[S3 SYNC] ERROR: Invalid decrypt options: %v", err)
+		return nil, fmt.Errorf("invalid decrypt options: %w", err)
+	}
+	objectRetry, err := retry.Resolve(DefaultObjectRetry, details.ObjectRetry)
+	if err != nil {
+		log.Printf("[S3 SYNC] ERROR: Invalid object retry options: %v", err)
+		return nil, fmt.Errorf("invalid objectRetry: %w", err)
+	}
+	var partSize int64
+	if details.PartSize != "" {
+		if partSize, err = quota.ParseSize(details.PartSize); err != nil {
+			return nil, fmt.Errorf("invalid partSize: %w", err)
+		}
 	}
-	log.Printf("[S3 SYNC] AWS session created successfully")
-
-	s3Client := s3.New(sess)
-	downloader := s3manager.NewDownloader(sess)
 
 	// Test the connection to ensure compatibility
 	syncer := &S3Syncer{
-		details:    details,
-		targetPath: targetPath,
-		timeout:    timeout,
-		session:    sess,
-		s3Client:   s3Client,
-		downloader: downloader,
+		details:     details,
+		targetPath:  targetPath,
+		timeout:     timeout,
+		filter:      fileFilter,
+		extractor:   extractor,
+		decryptor:   decryptor,
+		retry:       objectRetry,
+		parallelism: DefaultParallelism,
+		downloads:   downloads,
+		bandwidth:   bandwidth,
+	}
+	if details.Parallelism > 0 {
+		syncer.parallelism = details.Parallelism
 	}
+	syncer.setClient(awsConfig, endpoint, forcePathStyle, partSize)
 
 	log.Printf("[S3 SYNC] Testing S3 connection...")
 	if err := syncer.testConnection(); err != nil {
@@ -116,19 +164,7 @@ func NewS3Syncer(details *models.S3Details, targetPath string, timeout time.Dura
 		// If it's not AWS S3 and we failed, try the opposite path style
 		if !isAWSS3 {
 			log.Printf("[S3 SYNC] Retrying with virtual-hosted style...")
-			config.S3ForcePathStyle = aws.Bool(false)
-
-			sess, err = session.NewSession(config)
-			if err != nil {
-				log.Printf("[S3 SYNC] ERROR: Failed to create fallback AWS session: %v", err)
-				return nil, fmt.Errorf("failed to create fallback AWS session: %w", err)
-			}
-
-			s3Client = s3.New(sess)
-			downloader = s3manager.NewDownloader(sess)
-			syncer.session = sess
-			syncer.s3Client = s3Client
-			syncer.downloader = downloader
+			syncer.setClient(awsConfig, endpoint, false, partSize)
 
 			if err := syncer.testConnection(); err != nil {
 				log.Printf("[S3 SYNC] ERROR: Both path styles failed: %v", err)
@@ -147,6 +183,111 @@ func NewS3Syncer(details *models.S3Details, targetPath string, timeout time.Dura
 	return syncer, nil
 }
 
+// ProbeEndpoint returns the endpoint URL of the details and whether the client verifies the
+// certificate of the endpoint, which it only does for AWS S3
+func ProbeEndpoint(details *models.S3Details) (string, bool) {
+	return endpointURL(details), strings.Contains(details.EndpointURL, "amazonaws.com")
+}
+
+// endpointURL returns the endpoint URL with a scheme, which is http when SSL is disabled
+func endpointURL(details *models.S3Details) string {
+	endpoint := details.EndpointURL
+	disableSSL := details.DisableSSL != nil && *details.DisableSSL
+	if rest, ok := strings.CutPrefix(endpoint, "https://"); ok && disableSSL {
+		log.Printf("[S3 SYNC] SSL disabled, using HTTP")
+		return "http://" + rest
+	}
+	if !strings.Contains(endpoint, "://") {
+		if disableSSL {
+			return "http://" + endpoint
+		}
+		return "https://" + endpoint
+	}
+	return endpoint
+}
+
+// loadConfig loads the AWS configuration of the source. Static credentials are used when the
+// details provide an access key, otherwise the default credential chain resolves them from the
+// environment, web identity tokens, the shared configuration (including SSO profiles) or the
+// instance metadata service.
+func loadConfig(details *models.S3Details, isAWSS3 bool) (aws.Config, error) {
+	p, err := proxy.New(details.Proxy)
+	if err != nil {
+		return aws.Config{}, fmt.Errorf("invalid proxy: %w", err)
+	}
+	options := []func(*config.LoadOptions) error{
+		config.WithRegion(details.Region),
+		config.WithRetryer(func() aws.Retryer {
+			return awsretry.NewStandard(func(o *awsretry.StandardOptions) {
+				if details.APIRetry != nil && details.APIRetry.MaxAttempts > 0 {
+					o.MaxAttempts = details.APIRetry.MaxAttempts
+				}
+				if details.APIRetry != nil && details.APIRetry.MaxBackoff != "" {
+					// Validated with the details
+					if maxBackoff, err := time.ParseDuration(details.APIRetry.MaxBackoff); err == nil {
+						o.MaxBackoff = maxBackoff
+					}
+				}
+			})
+		}),
+	}
+	switch {
+	case details.AccessKey != "":
+		log.Printf("[S3 SYNC] Using static credentials")
+		options = append(options, config.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(details.AccessKey, details.SecretKey, details.SessionToken)))
+	case details.Profile != "":
+		log.Printf("[S3 SYNC] Using credentials of profile %s", details.Profile)
+		options = append(options, config.WithSharedConfigProfile(details.Profile))
+	default:
+		log.Printf("[S3 SYNC] Using the default AWS credential chain")
+	}
+
+	// Additional settings for better compatibility
+	options = append(options, config.WithHTTPClient(awshttp.NewBuildableClient().WithTransportOptions(func(transport *http.Transport) {
+		if !isAWSS3 {
+			// For S3-compatible services, disable SSL certificate verification for self-signed certs
+			// This is common in development/private cloud environments
+			transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		}
+		if p != nil {
+			// The credential chain, e.g. STS and the instance metadata service, uses the proxy too
+			transport.Proxy = p.ForRequest
+		}
+		// Endpoints are resolved with the DNS server and address family of the configuration
+		dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+		transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+			return netdial.DialContext(ctx, dialer, network, address)
+		}
+	})))
+	if !isAWSS3 {
+		log.Printf("[S3 SYNC] Configured for S3-compatible service with relaxed SSL verification")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return config.LoadDefaultConfig(ctx, options...)
+}
+
+// setClient creates the S3 client and the downloader with the addressing style
+func (s *S3Syncer) setClient(awsConfig aws.Config, endpoint string, forcePathStyle bool, partSize int64) {
+	s.s3Client = s3.NewFromConfig(awsConfig, func(o *s3.Options) {
+		o.BaseEndpoint = aws.String(endpoint)
+		o.UsePathStyle = forcePathStyle
+		// S3-compatible services do not all support the flexible checksums of recent SDKs
+		o.RequestChecksumCalculation = aws.RequestChecksumCalculationWhenRequired
+		o.ResponseChecksumValidation = aws.ResponseChecksumValidationWhenRequired
+	})
+	s.downloader = manager.NewDownloader(s.s3Client, func(d *manager.Downloader) {
+		if partSize > 0 {
+			d.PartSize = partSize
+		}
+		if s.details.PartConcurrency > 0 {
+			d.Concurrency = s.details.PartConcurrency
+		}
+	})
+}
+
 // testConnection tests the S3 connection by attempting to list bucket contents
 func (s *S3Syncer) testConnection() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -155,21 +296,24 @@ func (s *S3Syncer) testConnection() error {
 	// Try to list just one object to test connectivity
 	input := &s3.ListObjectsV2Input{
 		Bucket:  aws.String(s.details.BucketName),
-		MaxKeys: aws.Int64(1),
+		MaxKeys: aws.Int32(1),
 	}
 
-	_, err := s.s3Client.ListObjectsV2WithContext(ctx, input)
+	_, err := s.s3Client.ListObjectsV2(ctx, input)
 	return err
 }
 
 // Sync synchronizes data from S3 bucket to local target path
-func (s *S3Syncer) Sync() error {
+func (s *S3Syncer) Sync(ctx context.Context) error {
+	s.objects = 0
 	log.Printf("[S3 SYNC] Starting S3 sync from s3://%s/%s to %s", s.details.BucketName, s.details.Path, s.targetPath)
 	log.Printf("[S3 SYNC] Sync timeout: %v", s.timeout)
 
 	// Create context with timeout for all S3 operations
-	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
 	defer cancel()
+	reporter := progress.From(ctx)
+	reporter.Phase(progress.PhaseListing)
 
 	// Ensure target directory exists
 	log.Printf("[S3 SYNC] Creating target directory: %s", s.targetPath)
@@ -179,9 +323,72 @@ func (s *S3Syncer) Sync() error {
 	}
 	log.Printf("[S3 SYNC] Target directory created successfully")
 
-	// List objects in the bucket with the given prefix
-	log.Printf("[S3 SYNC] Listing objects in bucket with prefix: %s", s.details.Path)
-	objects, err := s.listObjects(ctx)
+	// Objects are downloaded while the listing continues, through a bounded queue, so that the
+	// memory use does not grow with the size of the bucket
+	log.Printf("[S3 SYNC] Listing objects in bucket with prefix: %s (parallelism: %d)", s.details.Path, s.parallelism)
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	var (
+		wg       sync.WaitGroup
+		failOnce sync.Once
+		failure  error
+		counts   syncProgress
+		queue    = make(chan types.Object, objectQueueSize)
+	)
+	fail := func(err error) {
+		failOnce.Do(func() {
+			failure = err
+			stop()
+		})
+	}
+	for i := 0; i < s.parallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for obj := range queue {
+				if ctx.Err() != nil {
+					continue
+				}
+				if err := s.downloadWithRetries(ctx, obj); err != nil {
+					if ctx.Err() == context.DeadlineExceeded {
+						log.Printf("[S3 SYNC] ERROR: S3 download operation timed out after %v", s.timeout)
+						fail(fmt.Errorf("S3 download operation timed out after %v", s.timeout))
+						continue
+					}
+					log.Printf("[S3 SYNC] ERROR: Failed to download object %s: %v", *obj.Key, err)
+					fail(fmt.Errorf("failed to download object %s: %w", *obj.Key, err))
+					continue
+				}
+				counts.downloaded(aws.ToInt64(obj.Size))
+				reporter.FilesDone(1)
+			}
+		}()
+	}
+
+	err := s.listObjects(ctx, func(obj types.Object) bool {
+		select {
+		case queue <- obj:
+			counts.listed.Add(1)
+			reporter.AddTotal(1, aws.ToInt64(obj.Size))
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}, func(page int) {
+		// Downloads start with the first page, while the listing continues
+		if page == 1 {
+			reporter.Phase(progress.PhaseTransferring)
+		}
+		counts.log(page)
+	})
+	close(queue)
+	wg.Wait()
+
+	s.objects = int(counts.listed.Load())
+	if failure != nil {
+		return failure
+	}
 	if err != nil {
 		if ctx.Err() == context.DeadlineExceeded {
 			log.Printf("[S3 SYNC] ERROR: S3 listing operation timed out after %v", s.timeout)
@@ -191,34 +398,43 @@ func (s *S3Syncer) Sync() error {
 		return fmt.Errorf("failed to list S3 objects: %w", err)
 	}
 
-	if len(objects) == 0 {
+	if s.objects == 0 {
 		log.Printf("[S3 SYNC] No objects found in s3://%s/%s", s.details.BucketName, s.details.Path)
 		return nil
 	}
+	log.Printf("[S3 SYNC] Successfully synced %d objects (%d bytes)", s.objects, counts.bytes.Load())
+	return nil
+}
 
-	log.Printf("[S3 SYNC] Found %d objects to sync", len(objects))
+// syncProgress counts the objects of a sync while they are listed and downloaded
+type syncProgress struct {
+	listed atomic.Int64
+	done   atomic.Int64
+	bytes  atomic.Int64
+}
 
-	// Download each object
-	for i, obj := range objects {
-		log.Printf("[S3 SYNC] Processing object %d/%d: %s", i+1, len(objects), *obj.Key)
-		if err := s.downloadObject(ctx, obj); err != nil {
-			if ctx.Err() == context.DeadlineExceeded {
-				log.Printf("[S3 SYNC] ERROR: S3 download operation timed out after %v", s.timeout)
-				return fmt.Errorf("S3 download operation timed out after %v", s.timeout)
-			}
-			log.Printf("[S3 SYNC] ERROR: Failed to download object %s: %v", *obj.Key, err)
-			return fmt.Errorf("failed to download object %s: %w", *obj.Key, err)
-		}
-	}
+func (p *syncProgress) downloaded(size int64) {
+	p.done.Add(1)
+	p.bytes.Add(size)
+}
 
-	log.Printf("[S3 SYNC] Successfully synced %d objects", len(objects))
-	return nil
+// log reports the progress once a page of the listing was queued
+func (p *syncProgress) log(page int) {
+	log.Printf("[S3 SYNC] Progress: %d objects listed in %d pages, %d downloaded (%d bytes)",
+		p.listed.Load(), page, p.done.Load(), p.bytes.Load())
+}
+
+// Result reports the number of objects listed by the last Sync
+func (s *S3Syncer) Result() models.SyncResult {
+	return models.SyncResult{Objects: s.objects}
 }
 
-// listObjects lists all objects in the bucket with the given prefix
-func (s *S3Syncer) listObjects(ctx context.Context) ([]*s3.Object, error) {
+// listObjects lists the objects in the bucket with the given prefix page by page and passes
+// those selected by the filters to the callback, until it returns false. The page callback is
+// called once the objects of a page were passed.
+func (s *S3Syncer) listObjects(ctx context.Context, object func(types.Object) bool, page func(int)) error {
 	log.Printf("[S3 SYNC] Starting object listing operation")
-	var objects []*s3.Object
+	filtered := 0
 
 	input := &s3.ListObjectsV2Input{
 		Bucket: aws.String(s.details.BucketName),
@@ -227,42 +443,91 @@ func (s *S3Syncer) listObjects(ctx context.Context) ([]*s3.Object, error) {
 
 	log.Printf("[S3 SYNC] Listing objects with prefix: %s", s.details.Path)
 	pageNum := 0
-	err := s.s3Client.ListObjectsV2PagesWithContext(ctx, input, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+	paginator := s3.NewListObjectsV2Paginator(s.s3Client, input)
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			log.Printf("[S3 SYNC] ERROR: Failed to list objects: %v", err)
+			return err
+		}
 		pageNum++
-		log.Printf("[S3 SYNC] Processing page %d (last page: %v)", pageNum, lastPage)
+		log.Printf("[S3 SYNC] Processing page %d (last page: %v)", pageNum, !paginator.HasMorePages())
 
-		for _, obj := range page.Contents {
+		for _, obj := range output.Contents {
 			// Skip directories (objects ending with /)
-			if !strings.HasSuffix(*obj.Key, "/") {
-				objects = append(objects, obj)
-				log.Printf("[S3 SYNC] Added object: %s (size: %d bytes)", *obj.Key, *obj.Size)
-			} else {
+			if strings.HasSuffix(*obj.Key, "/") {
 				log.Printf("[S3 SYNC] Skipping directory: %s", *obj.Key)
+				continue
+			}
+			if !s.filter.Match(s.relativeKey(*obj.Key), aws.ToInt64(obj.Size)) {
+				log.Printf("[S3 SYNC] Skipping filtered object: %s", *obj.Key)
+				filtered++
+				continue
+			}
+			log.Printf("[S3 SYNC] Queueing object: %s (size: %d bytes)", *obj.Key, aws.ToInt64(obj.Size))
+			if !object(obj) {
+				return ctx.Err()
 			}
 		}
-		return !lastPage
-	})
+		page(pageNum)
+	}
 
-	if err != nil {
-		log.Printf("[S3 SYNC] ERROR: Failed to list objects: %v", err)
-		return nil, err
+	log.Printf("[S3 SYNC] Object listing completed across %d pages", pageNum)
+	if filtered > 0 {
+		log.Printf("[S3 SYNC] %d objects excluded by filters", filtered)
 	}
+	return nil
+}
 
-	log.Printf("[S3 SYNC] Object listing completed - found %d objects across %d pages", len(objects), pageNum)
-	return objects, nil
+// relativeKey returns the object key relative to the configured prefix
+func (s *S3Syncer) relativeKey(key string) string {
+	relativePath := strings.TrimPrefix(key, s.details.Path)
+	if relativePath == "" {
+		relativePath = filepath.Base(key)
+	}
+	return relativePath
 }
 
-// downloadObject downloads a single object from S3
-func (s *S3Syncer) downloadObject(ctx context.Context, obj *s3.Object) error {
+// cacheKey returns the download cache key of the object version, or "" when the object is not cached
+func (s *S3Syncer) cacheKey(obj types.Object) string {
+	if s.downloads == nil || aws.ToString(obj.ETag) == "" {
+		return ""
+	}
+	return cache.Key(s.details.EndpointURL, s.details.BucketName, *obj.Key, *obj.ETag)
+}
+
+// downloadObject downloads a single object from S3, or copies it from the download cache
+func (s *S3Syncer) downloadObject(ctx context.Context, obj types.Object) error {
 	log.Printf("[S3 SYNC] Starting download of object: %s", *obj.Key)
 
 	// Calculate relative path by removing the prefix
-	relativePath := strings.TrimPrefix(*obj.Key, s.details.Path)
-	if relativePath == "" {
-		relativePath = filepath.Base(*obj.Key)
-	}
+	relativePath := s.relativeKey(*obj.Key)
 	log.Printf("[S3 SYNC] Relative path: %s", relativePath)
 
+	cacheKey := s.cacheKey(obj)
+	var cached *os.File
+	if cacheKey != "" {
+		if file, err := s.downloads.Open(cacheKey); err == nil {
+			log.Printf("[S3 SYNC] Object %s found in download cache", *obj.Key)
+			defer file.Close()
+			cached = file
+		}
+	}
+
+	// Encrypted files and archives are processed while downloading, without storing the object
+	if s.decryptor.Selects(relativePath) {
+		log.Printf("[S3 SYNC] Decrypting s3://%s/%s while downloading...", s.details.BucketName, *obj.Key)
+		return s.processObject(ctx, obj, cached, cacheKey, func(r io.Reader) error {
+			return s.decryptor.DecryptStream(r, s.targetPath, relativePath, s.extractor)
+		})
+	}
+	if s.extractor.Selects(relativePath) {
+		log.Printf("[S3 SYNC] Extracting archive s3://%s/%s while downloading...", s.details.BucketName, *obj.Key)
+		return s.processObject(ctx, obj, cached, cacheKey, func(r io.Reader) error {
+			return s.extractor.ExtractStream(r, s.targetPath, relativePath)
+		})
+	}
+
 	// Create the full local path
 	localPath := filepath.Join(s.targetPath, relativePath)
 	log.Printf("[S3 SYNC] Local path: %s", localPath)
@@ -274,8 +539,30 @@ func (s *S3Syncer) downloadObject(ctx context.Context, obj *s3.Object) error {
 		return fmt.Errorf("failed to create directory for %s: %w", localPath, err)
 	}
 
-	// Create the local file
+	reporter := progress.From(ctx)
+	if s.details.Resume && downloaded(localPath, obj) {
+		log.Printf("[S3 SYNC] Object %s was downloaded before the interruption, skipping", *obj.Key)
+		reporter.Bytes(aws.ToInt64(obj.Size))
+		return nil
+	}
+
+	sparse := s.details.FileHandling != nil && s.details.FileHandling.Sparse
+	if cached != nil && !sparse {
+		if linked, err := s.downloads.Populate(cacheKey, localPath); err != nil {
+			return fmt.Errorf("failed to link cached object: %w", err)
+		} else if linked {
+			log.Printf("[S3 SYNC] Linked %s from download cache", localPath)
+			reporter.Bytes(aws.ToInt64(obj.Size))
+			return nil
+		}
+	}
+
+	// Create the local file. It is replaced rather than truncated, since it may be a hard link
+	// into the download cache.
 	log.Printf("[S3 SYNC] Creating local file: %s", localPath)
+	if err := os.Remove(localPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to replace local file %s: %w", localPath, err)
+	}
 	file, err := os.Create(localPath)
 	if err != nil {
 		log.Printf("[S3 SYNC] ERROR: Failed to create local file %s: %v", localPath, err)
@@ -286,10 +573,29 @@ func (s *S3Syncer) downloadObject(ctx context.Context, obj *s3.Object) error {
 	// Download the object with context
 	log.Printf("[S3 SYNC] Downloading s3://%s/%s -> %s", s.details.BucketName, *obj.Key, localPath)
 
-	bytesWritten, err := s.downloader.DownloadWithContext(ctx, file, &s3.GetObjectInput{
-		Bucket: aws.String(s.details.BucketName),
-		Key:    obj.Key,
-	})
+	var bytesWritten int64
+	if sparse || cached != nil {
+		// The concurrent downloader writes parts at their offsets, so sparse files are streamed sequentially
+		err = s.processObject(ctx, obj, cached, cacheKey, func(r io.Reader) error {
+			var copyErr error
+			if sparse {
+				bytesWritten, copyErr = utils.CopySparse(file, r)
+			} else {
+				bytesWritten, copyErr = io.Copy(file, r)
+			}
+			return copyErr
+		})
+	} else {
+		bytesWritten, err = s.downloader.Download(ctx, progress.WriterAt(s.bandwidth.WriterAt(ctx, file), reporter), &s3.GetObjectInput{
+			Bucket: aws.String(s.details.BucketName),
+			Key:    obj.Key,
+		})
+		if err == nil && cacheKey != "" {
+			if cacheErr := s.downloads.Put(cacheKey, localPath); cacheErr != nil {
+				log.Printf("[S3 SYNC] WARNING: Failed to store %s in download cache: %v", *obj.Key, cacheErr)
+			}
+		}
+	}
 
 	if err != nil {
 		// Clean up the file if download failed
@@ -299,6 +605,122 @@ func (s *S3Syncer) downloadObject(ctx context.Context, obj *s3.Object) error {
 		return fmt.Errorf("failed to download object: %w", err)
 	}
 
+	// The modification time of the object marks the download as complete
+	if obj.LastModified != nil {
+		if err := os.Chtimes(localPath, *obj.LastModified, *obj.LastModified); err != nil {
+			log.Printf("[S3 SYNC] WARNING: Failed to set modification time of %s: %v", localPath, err)
+		}
+	}
+
 	log.Printf("[S3 SYNC] Successfully downloaded %s (%d bytes written, %d bytes expected)", *obj.Key, bytesWritten, *obj.Size)
 	return nil
 }
+
+// downloadWithRetries downloads the object, attempting it again with a jittered backoff after
+// failures that another attempt can fix
+func (s *S3Syncer) downloadWithRetries(ctx context.Context, obj types.Object) error {
+	reporter := progress.From(ctx)
+	reporter.File(*obj.Key)
+	for attempt := 1; ; attempt++ {
+		attemptProgress := progress.NewAttempt(reporter)
+		err := s.downloadObject(progress.WithReporter(ctx, attemptProgress), obj)
+		if err == nil || attempt >= s.retry.Attempts || ctx.Err() != nil || !retryableObjectError(err) {
+			return err
+		}
+		attemptProgress.Discard()
+		delay := retry.Jitter(s.retry.Delay(attempt))
+		log.Printf("[S3 SYNC] WARNING: Attempt %d of %d to download %s failed, retrying in %v: %v", attempt, s.retry.Attempts, *obj.Key, delay, err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// retryableObjectError reports whether downloading an object failed for a reason another attempt
+// can fix: throttling such as SlowDown, server errors, and the request and connection errors the
+// SDK classifies as retryable
+func retryableObjectError(err error) bool {
+	for _, throttle := range awsretry.DefaultThrottles {
+		if throttle.IsErrorThrottle(err) == aws.TrueTernary {
+			return true
+		}
+	}
+	for _, retryable := range awsretry.DefaultRetryables {
+		if retryable.IsErrorRetryable(err) == aws.TrueTernary {
+			return true
+		}
+	}
+	var responseErr *awshttp.ResponseError
+	if errors.As(err, &responseErr) && responseErr.HTTPStatusCode() >= 500 {
+		return true
+	}
+	return retry.IsTransient(err)
+}
+
+// downloaded reports whether the local file is a complete download of the object, i.e. it has the
+// size of the object and its modification time
+func downloaded(localPath string, obj types.Object) bool {
+	info, err := os.Lstat(localPath)
+	if err != nil || !info.Mode().IsRegular() || obj.LastModified == nil {
+		return false
+	}
+	return info.Size() == aws.ToInt64(obj.Size) && info.ModTime().Equal(*obj.LastModified)
+}
+
+// processObject streams an object into the processing step, from the cached copy when given.
+// Objects streamed from S3 are stored in the download cache under the cache key, if any.
+func (s *S3Syncer) processObject(ctx context.Context, obj types.Object, cached *os.File, cacheKey string, process func(io.Reader) error) error {
+	reporter := progress.From(ctx)
+	if cached != nil {
+		if err := process(progress.Reader(cached, reporter)); err != nil {
+			log.Printf("[S3 SYNC] ERROR: Failed to process cached object: %v", err)
+			return err
+		}
+		log.Printf("[S3 SYNC] Successfully processed %s from download cache", *obj.Key)
+		return nil
+	}
+
+	resp, err := s.s3Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.details.BucketName),
+		Key:    obj.Key,
+	})
+	if err != nil {
+		log.Printf("[S3 SYNC] ERROR: Failed to get object: %v", err)
+		return fmt.Errorf("failed to get object: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body := progress.Reader(s.bandwidth.Reader(ctx, resp.Body), reporter)
+	var entry *cache.Entry
+	if cacheKey != "" {
+		if entry, err = s.downloads.Create(cacheKey); err != nil {
+			log.Printf("[S3 SYNC] WARNING: Download cache unavailable: %v", err)
+			entry = nil
+		} else {
+			body = io.TeeReader(body, entry)
+		}
+	}
+
+	if err := process(body); err != nil {
+		if entry != nil {
+			entry.Abort()
+		}
+		log.Printf("[S3 SYNC] ERROR: Failed to process object: %v", err)
+		return err
+	}
+	if entry != nil {
+		// Bytes the processing step did not read still belong to the cached object
+		if _, err := io.Copy(io.Discard, body); err != nil {
+			entry.Abort()
+		} else if err := entry.Commit(); err != nil {
+			log.Printf("[S3 SYNC] WARNING: Failed to store %s in download cache: %v", *obj.Key, err)
+		}
+	}
+
+	log.Printf("[S3 SYNC] Successfully processed %s", *obj.Key)
+	return nil
+}
@@ -0,0 +1,74 @@
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/sharedvolume/volume-syncer/internal/models"
+)
+
+// browseScript lists the entries of the current directory as NUL-terminated type<TAB>name
+// records with the POSIX shell of the remote host, stopping after %d entries
+const browseScript = `n=0; for f in .* *; do ` +
+	`case "$f" in .|..) continue;; esac; ` +
+	`if [ -L "$f" ]; then t=link; elif [ -d "$f" ]; then t=dir; elif [ -e "$f" ]; then t=file; else continue; fi; ` +
+	`n=$((n+1)); [ $n -gt %d ] && break; ` +
+	`printf '%%s\t%%s\0' "$t" "$f"; done`
+
+// Browse lists the entries of a directory under the remote path, returning at most limit+1
+// entries so that a truncated listing can be told apart
+func (s *SSHSyncer) Browse(_ context.Context, dir string, limit int) ([]models.BrowseEntry, error) {
+	cleanupKnownHosts, err := s.setupKnownHosts()
+	if err != nil {
+		return nil, fmt.Errorf("host key verification setup failed: %w", err)
+	}
+	defer cleanupKnownHosts()
+	cleanupCertificate, err := s.setupCertificate()
+	if err != nil {
+		return nil, fmt.Errorf("certificate setup failed: %w", err)
+	}
+	defer cleanupCertificate()
+	privateKey, password, _, err := s.checkCredentials()
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := s.dial(privateKey, password)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create SSH session: %w", err)
+	}
+	defer session.Close()
+
+	remoteDir := s.sshDetails.Path
+	if remoteDir == "" {
+		remoteDir = "."
+	}
+	remoteDir = path.Join(remoteDir, dir)
+	var stdout, stderr bytes.Buffer
+	session.Stdout, session.Stderr = &stdout, &stderr
+	command := "cd -- " + shellQuote(remoteDir) + " && " + fmt.Sprintf(browseScript, limit+1)
+	if err := session.Run(command); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return nil, fmt.Errorf("failed to list %s: %s", remoteDir, message)
+		}
+		return nil, fmt.Errorf("failed to list %s: %w", remoteDir, err)
+	}
+
+	var entries []models.BrowseEntry
+	for _, record := range strings.Split(stdout.String(), "\x00") {
+		entryType, name, ok := strings.Cut(record, "\t")
+		if !ok {
+			continue
+		}
+		entries = append(entries, models.BrowseEntry{Name: name, Type: entryType})
+	}
+	return entries, nil
+}
@@ -0,0 +1,121 @@
+package ssh
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sharedvolume/volume-syncer/internal/checks"
+	"github.com/sharedvolume/volume-syncer/internal/models"
+	"github.com/sharedvolume/volume-syncer/internal/sshutil"
+	"github.com/sharedvolume/volume-syncer/internal/utils"
+	"golang.org/x/crypto/ssh"
+)
+
+// Check verifies the host key, the authentication and the access to the remote path without
+// transferring files
+func (s *SSHSyncer) Check(_ context.Context) []models.SourceCheck {
+	var report checks.Report
+
+	cleanupKnownHosts := utils.NoCleanup
+	report.Run("hostKey", func() (string, error) {
+		cleanup, err := s.setupKnownHosts()
+		if err != nil {
+			return "", err
+		}
+		cleanupKnownHosts = cleanup
+		if s.knownHostsFile == "" {
+			return "no host key material provided, the host key is not verified", nil
+		}
+		return "host key material prepared", nil
+	})
+	defer cleanupKnownHosts()
+
+	cleanupCertificate := utils.NoCleanup
+	var privateKey []byte
+	var password, method string
+	report.Run("credentials", func() (string, error) {
+		cleanup, err := s.setupCertificate()
+		if err != nil {
+			return "", err
+		}
+		cleanupCertificate = cleanup
+		privateKey, password, method, err = s.checkCredentials()
+		return method, err
+	})
+	defer cleanupCertificate()
+
+	var client *ssh.Client
+	report.Run("authentication", func() (string, error) {
+		var err error
+		if client, err = s.dial(privateKey, password); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("authenticated as %s on %s:%d", s.sshDetails.User, s.sshDetails.Host, s.sshDetails.Port), nil
+	})
+	if client != nil {
+		defer client.Close()
+	}
+
+	report.Run("remotePath", func() (string, error) {
+		session, err := client.NewSession()
+		if err != nil {
+			return "", fmt.Errorf("failed to create SSH session: %w", err)
+		}
+		defer session.Close()
+
+		remotePath := s.sshDetails.Path
+		if remotePath == "" {
+			remotePath = "."
+		}
+		// Pulled paths must be readable, pushed paths writable
+		access, flag := "readable", "-r"
+		if s.isPush() {
+			access, flag = "writable", "-w"
+		}
+		quoted := shellQuote(remotePath)
+		if err := session.Run(fmt.Sprintf("test -d %s && test %s %s", quoted, flag, quoted)); err != nil {
+			return "", fmt.Errorf("remote path %s is not a %s directory", remotePath, access)
+		}
+		return fmt.Sprintf("remote path %s is a %s directory", remotePath, access), nil
+	})
+
+	return report.Checks
+}
+
+// checkCredentials loads the authentication material of the source and describes the method
+func (s *SSHSyncer) checkCredentials() ([]byte, string, string, error) {
+	switch {
+	case s.sshDetails.KeyPath != "":
+		privateKey, err := os.ReadFile(s.sshDetails.KeyPath)
+		if err != nil {
+			return nil, "", "", fmt.Errorf("failed to read private key file: %w", err)
+		}
+		return privateKey, "", "private key authentication with " + s.sshDetails.KeyPath, nil
+	case s.sshDetails.PrivateKey != "":
+		privateKey, err := base64.StdEncoding.DecodeString(s.sshDetails.PrivateKey)
+		if err != nil {
+			return nil, "", "", fmt.Errorf("failed to decode base64 private key: %w", err)
+		}
+		return privateKey, "", "private key authentication", nil
+	case s.sshDetails.Password != "":
+		return nil, s.sshDetails.Password, "password authentication", nil
+	}
+
+	var err error
+	s.agentSocket, err = sshutil.ResolveAgentSocket(s.sshDetails.AgentSocket)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("ssh-agent setup failed: %w", err)
+	}
+	if s.agentSocket != "" {
+		return nil, "", "ssh-agent authentication", nil
+	}
+	return nil, "", "no credentials provided", nil
+}
+
+// shellQuote quotes the argument for the remote shell
+func shellQuote(arg string) string {
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
@@ -0,0 +1,99 @@
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sharedvolume/volume-syncer/internal/procgroup"
+	"github.com/sharedvolume/volume-syncer/internal/utils"
+)
+
+// controlMasterTimeout bounds the time the master connection takes to connect and authenticate
+const controlMasterTimeout = 30 * time.Second
+
+// setupControlMaster starts an ssh master connection shared by the rsync processes of the sync,
+// e.g. the listings of file filters and parallel pulls and the parts of a parallel pull, so that
+// the server is connected and authenticated once. When the master cannot be established, every
+// process connects on its own. The returned function stops the master.
+func (s *SSHSyncer) setupControlMaster(ctx context.Context, keyFile string, env []string) func() {
+	if s.sshDetails.ControlMaster == nil || !*s.sshDetails.ControlMaster {
+		return utils.NoCleanup
+	}
+
+	// The socket path is kept short, unix sockets are limited to about 100 bytes
+	dir, err := os.MkdirTemp(s.sshDetails.CredentialsDir, "volume-syncer-ssh-*")
+	if err != nil {
+		log.Printf("[SSH SYNC] WARNING: SSH connection sharing disabled: %v", err)
+		return utils.NoCleanup
+	}
+	controlPath := filepath.Join(dir, "master")
+
+	// The ssh command of rsync is a command line, sh splits it the same way; the destination is
+	// passed as an argument so that it is not interpreted by the shell
+	masterCmd := s.rsyncSSHCommand(keyFile) + " -o ControlMaster=yes -o ControlPath=" + controlPath + " -N"
+	masterCtx, stop := context.WithCancel(ctx)
+	cmd := exec.CommandContext(masterCtx, "sh", "-c", "exec "+masterCmd+` -- "$1"`, "sh", s.sshDetails.User+"@"+s.sshDetails.Host)
+	cmd.Env = env
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	procgroup.Configure(cmd)
+	cleanup := func() {
+		stop()
+		os.RemoveAll(dir)
+	}
+	if err := cmd.Start(); err != nil {
+		log.Printf("[SSH SYNC] WARNING: SSH connection sharing disabled, failed to start ssh: %v", err)
+		cleanup()
+		return utils.NoCleanup
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+	stopMaster := func() {
+		s.controlPath = ""
+		stop()
+		<-exited
+		os.RemoveAll(dir)
+	}
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	deadline := time.After(controlMasterTimeout)
+	for {
+		select {
+		case err := <-exited:
+			log.Printf("[SSH SYNC] WARNING: SSH connection sharing disabled, the master connection failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+			stop()
+			os.RemoveAll(dir)
+			return utils.NoCleanup
+		case <-deadline:
+			log.Printf("[SSH SYNC] WARNING: SSH connection sharing disabled, the master connection was not established within %v", controlMasterTimeout)
+			stopMaster()
+			return utils.NoCleanup
+		case <-ctx.Done():
+			stopMaster()
+			return utils.NoCleanup
+		case <-ticker.C:
+			if _, err := os.Stat(controlPath); err == nil {
+				log.Printf("[SSH SYNC] Sharing one SSH connection to %s between the rsync processes", s.address())
+				s.controlPath = controlPath
+				return stopMaster
+			}
+		}
+	}
+}
+
+// controlOptions returns the ssh options connecting through the master connection, if any
+func (s *SSHSyncer) controlOptions() string {
+	if s.controlPath == "" {
+		return ""
+	}
+	// Without the master, e.g. when it was closed by the server, ssh connects on its own
+	return fmt.Sprintf("-o ControlMaster=no -o ControlPath=%s", s.controlPath)
+}
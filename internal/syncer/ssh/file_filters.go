@@ -0,0 +1,202 @@
+package ssh
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/sharedvolume/volume-syncer/internal/filter"
+	"github.com/sharedvolume/volume-syncer/internal/metrics"
+	"github.com/sharedvolume/volume-syncer/internal/procgroup"
+	"github.com/sharedvolume/volume-syncer/internal/utils"
+)
+
+// listOnlyLineRegex matches a file entry of rsync --list-only output: permissions, size, date, time and name
+var listOnlyLineRegex = regexp.MustCompile(`^([-dlcbps])\S*\s+([0-9][0-9,.]*)\s+\S+\s+\S+\s(.+)$`)
+
+// setupFileFilters compiles the request filters. Regular expressions have no rsync equivalent,
+// so with a regex filter the source tree is listed first and the selected files are written
+// to a temporary rsync merge file.
+func (s *SSHSyncer) setupFileFilters(ctx context.Context, keyFile string, env []string) (func(), error) {
+	matcher, err := filter.New(s.sshDetails.Filters)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filters: %w", err)
+	}
+	s.fileFilter = matcher
+	if !matcher.HasRegex() {
+		return utils.NoCleanup, nil
+	}
+
+	var files []string
+	if s.isPush() {
+		files, err = s.listLocalFiles()
+	} else {
+		files, err = s.listRemoteFiles(ctx, keyFile, env)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var rules strings.Builder
+	for _, file := range files {
+		rules.WriteString("+ /" + rsyncLiteral(file) + "\n")
+	}
+	rules.WriteString("+ */\n- *\n")
+
+	filterFile, err := os.CreateTemp("", "rsync_filter_*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create filter file: %w", err)
+	}
+	defer filterFile.Close()
+
+	if _, err := filterFile.WriteString(rules.String()); err != nil {
+		os.Remove(filterFile.Name())
+		return nil, fmt.Errorf("failed to write filter file: %w", err)
+	}
+
+	log.Printf("[SSH SYNC] File filters selected %d files", len(files))
+	s.filterFile = filterFile.Name()
+	return func() {
+		log.Printf("[SSH SYNC] Cleaning up temporary filter file: %s", s.filterFile)
+		os.Remove(s.filterFile)
+		s.filterFile = ""
+	}, nil
+}
+
+// fileFilterArgs maps the request filters to rsync arguments
+func (s *SSHSyncer) fileFilterArgs() []string {
+	if s.fileFilter == nil {
+		return nil
+	}
+
+	var args []string
+	if s.filterFile != "" {
+		// The listed files already passed every filter, including the size limit
+		args = append(args, "--filter=merge "+s.filterFile)
+	} else {
+		for _, pattern := range s.sshDetails.Filters.Exclude {
+			args = append(args, "--filter=- "+rsyncPattern(pattern))
+		}
+		if len(s.sshDetails.Filters.Include) > 0 {
+			for _, pattern := range s.sshDetails.Filters.Include {
+				// Including a directory includes everything below it
+				args = append(args,
+					"--filter=+ "+rsyncPattern(pattern),
+					"--filter=+ "+rsyncPattern(strings.TrimRight(pattern, "/")+"/**"))
+			}
+			args = append(args, "--filter=+ */", "--filter=- *")
+		}
+		if maxFileSize := s.fileFilter.MaxFileSize(); maxFileSize > 0 {
+			args = append(args, "--max-size="+strconv.FormatInt(maxFileSize, 10))
+		}
+	}
+	args = append(args, "--prune-empty-dirs")
+
+	log.Printf("[SSH SYNC] Applying file filters: %v", args)
+	return args
+}
+
+// rsyncPattern anchors patterns containing a slash at the transfer root, as the filters define them
+func rsyncPattern(pattern string) string {
+	if strings.Contains(strings.TrimRight(pattern, "/"), "/") && !strings.HasPrefix(pattern, "/") {
+		return "/" + pattern
+	}
+	return pattern
+}
+
+// rsyncLiteral escapes a path for use as an rsync pattern matching only that path.
+// rsync only interprets backslashes in patterns containing wildcard characters.
+func rsyncLiteral(path string) string {
+	if !strings.ContainsAny(path, "*?[") {
+		return path
+	}
+	var b strings.Builder
+	for _, r := range path {
+		if strings.ContainsRune(`*?[\`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// listRemoteFiles lists the remote source tree with rsync and returns the files passing the filters
+func (s *SSHSyncer) listRemoteFiles(ctx context.Context, keyFile string, env []string) ([]string, error) {
+	log.Printf("[SSH SYNC] Listing remote files to apply file filters...")
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "rsync", "--list-only", "-r", "-e", s.rsyncSSHCommand(keyFile), s.remoteSpec())
+	procgroup.Configure(cmd)
+	cmd.Env = env
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	metrics.CommandExited(ctx, "rsync", err)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("remote file listing timed out after %v", s.timeout)
+		}
+		return nil, fmt.Errorf("remote file listing failed: %w, output: %s", err, stderr.String())
+	}
+
+	var files []string
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		match := listOnlyLineRegex.FindStringSubmatch(scanner.Text())
+		if match == nil || match[1] == "d" {
+			continue
+		}
+		name := match[3]
+		if match[1] == "l" {
+			name, _, _ = strings.Cut(name, " -> ")
+		}
+		size, _ := strconv.ParseInt(strings.NewReplacer(",", "", ".", "").Replace(match[2]), 10, 64)
+		if s.fileFilter.Match(name, size) {
+			files = append(files, name)
+		}
+	}
+	return files, scanner.Err()
+}
+
+// listLocalFiles walks the local source tree of a push and returns the files passing the filters
+func (s *SSHSyncer) listLocalFiles() ([]string, error) {
+	log.Printf("[SSH SYNC] Listing local files to apply file filters...")
+
+	var files []string
+	err := filepath.WalkDir(s.targetPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(s.targetPath, path)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+		if s.fileFilter.Match(relPath, info.Size()) {
+			files = append(files, relPath)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list local files: %w", err)
+	}
+	return files, nil
+}
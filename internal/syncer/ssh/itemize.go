@@ -0,0 +1,152 @@
+package ssh
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/sharedvolume/volume-syncer/internal/models"
+	"github.com/sharedvolume/volume-syncer/internal/progress"
+)
+
+// maxItemizedFiles bounds each file list of the transfer report, so that the job record stays
+// small for large transfers
+const maxItemizedFiles = 1000
+
+// itemizeArgs make rsync print one line per changed item, the overall progress of the transfer and
+// the transfer statistics instead of its verbose progress output
+var itemizeArgs = []string{"--itemize-changes", "--stats", "--info=progress2"}
+
+// progressLineRegex matches the --info=progress2 lines, e.g.
+// "  1,238,099  42%  146.38MB/s  0:00:00 (xfr#3, to-chk=2/5)". The file counts are missing until
+// the first file was transferred, ir-chk counts files still being listed.
+var progressLineRegex = regexp.MustCompile(`^\s*([0-9][0-9,.]*)\s+[0-9]+%\s+\S+\s+[0-9:]+(?:\s+\(xfr#[0-9]+, (?:to|ir)-chk=([0-9]+)/([0-9]+)\))?`)
+
+// transferStatistics maps the --stats lines to the byte counts of the report
+var transferStatistics = map[string]func(*models.TransferReport) *int64{
+	"Total bytes sent":            func(r *models.TransferReport) *int64 { return &r.BytesSent },
+	"Total bytes received":        func(r *models.TransferReport) *int64 { return &r.BytesReceived },
+	"Total transferred file size": func(r *models.TransferReport) *int64 { return &r.TransferredBytes },
+	"Literal data":                func(r *models.TransferReport) *int64 { return &r.LiteralBytes },
+	"Matched data":                func(r *models.TransferReport) *int64 { return &r.MatchedBytes },
+}
+
+// parseTransfer reads the --itemize-changes, --info=progress2 and --stats output of rsync and
+// reports the progress of the transfer. Directories are left out of the file lists, as are items
+// whose attributes changed but not their contents.
+func parseTransfer(output io.Reader, reporter progress.Reporter) (*models.TransferReport, error) {
+	report := &models.TransferReport{}
+	add := func(list []string, name string) []string {
+		if len(list) >= maxItemizedFiles {
+			report.Truncated = true
+			return list
+		}
+		return append(list, name)
+	}
+
+	// The progress lines are cumulative for the process, the reporter receives the increments
+	var reportedBytes, reportedFiles, reportedTotal int64
+	reportProgress := func(match []string) {
+		if transferred := statisticValue(match[1]); transferred > reportedBytes {
+			reporter.Bytes(transferred - reportedBytes)
+			reportedBytes = transferred
+		}
+		if match[3] == "" {
+			return
+		}
+		remaining, _ := strconv.ParseInt(match[2], 10, 64)
+		total, _ := strconv.ParseInt(match[3], 10, 64)
+		if total > reportedTotal {
+			reporter.AddTotal(int(total-reportedTotal), 0)
+			reportedTotal = total
+		}
+		if checked := total - remaining; checked > reportedFiles {
+			reporter.FilesDone(int(checked - reportedFiles))
+			reportedFiles = checked
+		}
+	}
+
+	scanner := bufio.NewScanner(output)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	scanner.Split(scanOutputLines)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if match := progressLineRegex.FindStringSubmatch(line); match != nil {
+			reportProgress(match)
+			continue
+		}
+		if name, ok := strings.CutPrefix(line, "*deleting "); ok {
+			if name = strings.TrimLeft(name, " "); !strings.HasSuffix(name, "/") {
+				report.Deleted = add(report.Deleted, name)
+			}
+			continue
+		}
+		if label, value, ok := strings.Cut(line, ": "); ok {
+			if field, ok := transferStatistics[label]; ok {
+				*field(report) = statisticValue(value)
+				continue
+			}
+		}
+
+		// YXcstpoguax name: update type, file type and attribute changes, "+" for created items
+		flags, name, ok := strings.Cut(line, " ")
+		if !ok || len(flags) < 11 || !strings.ContainsRune("<>ch.", rune(flags[0])) || !strings.ContainsRune("fLDS", rune(flags[1])) {
+			continue
+		}
+		// Symbolic and hard links are followed by their target
+		name, _, _ = strings.Cut(name, " -> ")
+		name, _, _ = strings.Cut(name, " => ")
+		switch {
+		case strings.Trim(flags[2:], "+") == "":
+			report.Added = add(report.Added, name)
+			reporter.File(name)
+		case flags[0] != '.':
+			report.Updated = add(report.Updated, name)
+			reporter.File(name)
+		}
+	}
+	return report, scanner.Err()
+}
+
+// scanOutputLines splits the rsync output into lines ended by a newline or by the carriage
+// return that precedes each update of the progress line
+func scanOutputLines(data []byte, atEOF bool) (int, []byte, error) {
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// statisticValue parses a byte count of the --stats output, e.g. "1,234 bytes"
+func statisticValue(value string) int64 {
+	digits, _, _ := strings.Cut(strings.TrimSpace(value), " ")
+	digits = strings.NewReplacer(",", "", ".", "").Replace(digits)
+	number, _ := strconv.ParseInt(digits, 10, 64)
+	return number
+}
+
+// mergeTransfer adds the report of a parallel transfer to the merged report
+func mergeTransfer(merged, part *models.TransferReport) {
+	appendFiles := func(list, files []string) []string {
+		if room := maxItemizedFiles - len(list); len(files) > room {
+			merged.Truncated = true
+			files = files[:room]
+		}
+		return append(list, files...)
+	}
+	merged.Added = appendFiles(merged.Added, part.Added)
+	merged.Updated = appendFiles(merged.Updated, part.Updated)
+	merged.Deleted = appendFiles(merged.Deleted, part.Deleted)
+	merged.Truncated = merged.Truncated || part.Truncated
+	merged.BytesSent += part.BytesSent
+	merged.BytesReceived += part.BytesReceived
+	merged.TransferredBytes += part.TransferredBytes
+	merged.LiteralBytes += part.LiteralBytes
+	merged.MatchedBytes += part.MatchedBytes
+}
@@ -0,0 +1,140 @@
+package ssh
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/sharedvolume/volume-syncer/internal/metrics"
+	"github.com/sharedvolume/volume-syncer/internal/models"
+	"github.com/sharedvolume/volume-syncer/internal/procgroup"
+	"github.com/sharedvolume/volume-syncer/internal/progress"
+)
+
+// MaxParallelism bounds the rsync processes of a parallel sync
+const MaxParallelism = 16
+
+// parallelRsync splits the transfer by top-level directory of the remote path and runs up to
+// parallelism rsync processes at once. Every directory is transferred by its own process from
+// the same transfer root, restricted to the directory by a leading exclude rule, so that the
+// transfer rules and --delete keep their meaning. A final part transfers the top-level files
+// and removes the target entries missing on the source, leaving the directories to their parts.
+func (s *SSHSyncer) parallelRsync(ctx context.Context, keyFile string, env []string) (*models.TransferReport, error) {
+	reporter := progress.From(ctx)
+	reporter.Phase(progress.PhaseListing)
+	dirs, err := s.listTopLevelDirs(ctx, keyFile, env)
+	if err != nil {
+		return nil, err
+	}
+	reporter.Phase(progress.PhaseTransferring)
+	if len(dirs) < 2 {
+		log.Printf("[SSH SYNC] Remote path has %d top-level directories, transferring with a single rsync", len(dirs))
+		return s.runRsync(ctx, s.buildRsyncCommand(keyFile), env)
+	}
+	log.Printf("[SSH SYNC] Transferring %d top-level directories with up to %d rsync processes", len(dirs), s.sshDetails.Parallelism)
+
+	// The top-level part excludes the directories through a merge file, since the arguments
+	// could exceed the command line limit for wide trees
+	var rules strings.Builder
+	for _, dir := range dirs {
+		rules.WriteString("- /" + rsyncLiteral(dir) + "/\n")
+	}
+	excludeFile, err := os.CreateTemp("", "rsync_partition_*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create partition file: %w", err)
+	}
+	defer os.Remove(excludeFile.Name())
+	if _, err := excludeFile.WriteString(rules.String()); err != nil {
+		excludeFile.Close()
+		return nil, fmt.Errorf("failed to write partition file: %w", err)
+	}
+	if err := excludeFile.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write partition file: %w", err)
+	}
+
+	parts := make(chan []string, len(dirs)+1)
+	for _, dir := range dirs {
+		parts <- []string{"-! /" + rsyncLiteral(dir) + "/***"}
+	}
+	parts <- []string{"merge " + excludeFile.Name()}
+	close(parts)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// The processes share the bandwidth limit
+	s.processes = min(s.sshDetails.Parallelism, len(dirs)+1)
+
+	var (
+		merged   = &models.TransferReport{}
+		mu       sync.Mutex
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	for range s.processes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for partition := range parts {
+				if ctx.Err() != nil {
+					return
+				}
+				transfer, err := s.runRsync(ctx, s.buildRsyncCommand(keyFile, partition...), env)
+				mu.Lock()
+				if err != nil {
+					// The first failure is reported, the other parts are cancelled
+					if firstErr == nil {
+						firstErr = err
+						cancel()
+					}
+				} else {
+					mergeTransfer(merged, transfer)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return merged, nil
+}
+
+// listTopLevelDirs lists the directories directly below the remote path. Symbolic links are
+// transferred with the top-level files.
+func (s *SSHSyncer) listTopLevelDirs(ctx context.Context, keyFile string, env []string) ([]string, error) {
+	log.Printf("[SSH SYNC] Listing top-level directories of the remote path...")
+
+	cmd := exec.CommandContext(ctx, "rsync", "--list-only", "-e", s.rsyncSSHCommand(keyFile), s.remoteSpec())
+	procgroup.Configure(cmd)
+	cmd.Env = env
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	metrics.CommandExited(ctx, "rsync", err)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("remote directory listing timed out after %v", s.timeout)
+		}
+		return nil, fmt.Errorf("remote directory listing failed: %w, output: %s", err, stderr.String())
+	}
+
+	var dirs []string
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		match := listOnlyLineRegex.FindStringSubmatch(scanner.Text())
+		if match == nil || match[1] != "d" || match[3] == "." {
+			continue
+		}
+		dirs = append(dirs, match[3])
+	}
+	return dirs, scanner.Err()
+}
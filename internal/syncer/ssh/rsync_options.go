@@ -0,0 +1,189 @@
+package ssh
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/sharedvolume/volume-syncer/internal/models"
+)
+
+// NoDeleteOption disables the default --delete behavior
+const NoDeleteOption = "--no-delete"
+
+// allowedRsyncFlags lists the rsync flags users may add to SSH syncs.
+// Options that change the remote shell, read or write local files outside the target
+// or remove source files are deliberately not allowed.
+var allowedRsyncFlags = map[string]bool{
+	NoDeleteOption:        true,
+	"--delete-before":     true,
+	"--delete-during":     true,
+	"--delete-after":      true,
+	"--delete-excluded":   true,
+	"--checksum":          true,
+	"-c":                  true,
+	"--copy-links":        true,
+	"-L":                  true,
+	"--copy-unsafe-links": true,
+	"--safe-links":        true,
+	"--hard-links":        true,
+	"-H":                  true,
+	"--acls":              true,
+	"--xattrs":            true,
+	"--no-perms":          true,
+	"--no-owner":          true,
+	"--no-group":          true,
+	"--no-times":          true,
+	"--omit-dir-times":    true,
+	"--numeric-ids":       true,
+	"--size-only":         true,
+	"--ignore-times":      true,
+	"--ignore-existing":   true,
+	"--existing":          true,
+	"--update":            true,
+	"--sparse":            true,
+	"--inplace":           true,
+	"--partial":           true,
+	"--whole-file":        true,
+	"--no-compress":       true,
+	"--prune-empty-dirs":  true,
+}
+
+// allowedRsyncValueOptions lists the rsync options taking a value (--name=value) and their value format
+var allowedRsyncValueOptions = map[string]*regexp.Regexp{
+	"--chmod":          regexp.MustCompile(`^[DFugoa0-7+=,rwxXst-]+$`),
+	"--bwlimit":        regexp.MustCompile(`^[0-9]+(\.[0-9]+)?[KMGkmg]?$`),
+	"--max-size":       regexp.MustCompile(`^[0-9]+(\.[0-9]+)?[KMGkmg]?$`),
+	"--min-size":       regexp.MustCompile(`^[0-9]+(\.[0-9]+)?[KMGkmg]?$`),
+	"--compress-level": regexp.MustCompile(`^[0-9]$`),
+	"--timeout":        regexp.MustCompile(`^[0-9]+$`),
+	"--modify-window":  regexp.MustCompile(`^-?[0-9]+$`),
+}
+
+// ValidateRsyncOptions checks user supplied rsync options against the allowlist
+func ValidateRsyncOptions(options []string) error {
+	noDelete := false
+	deleteVariant := ""
+
+	for _, option := range options {
+		if name, value, hasValue := strings.Cut(option, "="); hasValue {
+			pattern, ok := allowedRsyncValueOptions[name]
+			if !ok {
+				return fmt.Errorf("rsync option %q is not allowed", option)
+			}
+			if !pattern.MatchString(value) {
+				return fmt.Errorf("invalid value for rsync option %s: %q", name, value)
+			}
+			continue
+		}
+
+		if !allowedRsyncFlags[option] {
+			if _, ok := allowedRsyncValueOptions[option]; ok {
+				return fmt.Errorf("rsync option %s requires a value (%s=<value>)", option, option)
+			}
+			return fmt.Errorf("rsync option %q is not allowed", option)
+		}
+
+		if option == NoDeleteOption {
+			noDelete = true
+		} else if strings.HasPrefix(option, "--delete-") {
+			deleteVariant = option
+		}
+	}
+
+	if noDelete && deleteVariant != "" {
+		return fmt.Errorf("rsync options %s and %s cannot be combined", NoDeleteOption, deleteVariant)
+	}
+	return nil
+}
+
+// rsyncBaseArgs returns the default rsync flags adjusted by the user supplied options
+func (s *SSHSyncer) rsyncBaseArgs() []string {
+	args := []string{
+		"-az",      // archive, compress
+		"--delete", // delete files that don't exist on source
+	}
+	args = append(args, itemizeArgs...)
+
+	for _, option := range s.sshDetails.RsyncOptions {
+		if option == NoDeleteOption {
+			// Keep files in the target that no longer exist on the source
+			args = append(args[:1], args[2:]...)
+			break
+		}
+	}
+
+	for _, option := range s.sshDetails.RsyncOptions {
+		if option != NoDeleteOption {
+			args = append(args, option)
+		}
+	}
+	return args
+}
+
+// bandwidthArgs maps the bandwidth limit of the sync to --bwlimit, shared by the rsync processes
+// of a parallel sync. A lower --bwlimit in the rsync options is kept.
+func (s *SSHSyncer) bandwidthArgs() []string {
+	limit := s.sshDetails.BandwidthLimit
+	if limit <= 0 {
+		return nil
+	}
+	if s.processes > 1 {
+		limit /= int64(s.processes)
+	}
+	kib := limit / 1024
+	if kib < 1 {
+		kib = 1
+	}
+	for _, option := range s.sshDetails.RsyncOptions {
+		if value, ok := strings.CutPrefix(option, "--bwlimit="); ok {
+			if own := rsyncRate(value); own > 0 && own <= kib {
+				return nil
+			}
+		}
+	}
+	return []string{fmt.Sprintf("--bwlimit=%d", kib)}
+}
+
+// rsyncRate converts a --bwlimit value to KiB per second, 0 when it is unlimited or invalid
+func rsyncRate(value string) int64 {
+	multiplier := 1.0
+	switch strings.ToUpper(value[len(value)-1:]) {
+	case "K":
+		value = value[:len(value)-1]
+	case "M":
+		multiplier, value = 1024, value[:len(value)-1]
+	case "G":
+		multiplier, value = 1024*1024, value[:len(value)-1]
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0
+	}
+	return int64(number * multiplier)
+}
+
+// fileHandlingArgs maps the file handling options to rsync flags. The archive mode already
+// preserves symbolic links, so only deviations from it are added.
+func (s *SSHSyncer) fileHandlingArgs() []string {
+	fileHandling := s.sshDetails.FileHandling
+	if fileHandling == nil {
+		return nil
+	}
+
+	var args []string
+	switch fileHandling.Symlinks {
+	case models.SymlinksFollow:
+		args = append(args, "--copy-links")
+	case models.SymlinksSkip:
+		args = append(args, "--no-links")
+	}
+	if fileHandling.Hardlinks {
+		args = append(args, "--hard-links")
+	}
+	if fileHandling.Sparse {
+		args = append(args, "--sparse")
+	}
+	return args
+}
@@ -0,0 +1,114 @@
+package ssh
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/sharedvolume/volume-syncer/internal/models"
+)
+
+func TestValidateRsyncOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []string
+		errText string // contained in the error, "" when the options are valid
+	}{
+		{name: "no options"},
+		{name: "allowed flags", options: []string{"--checksum", "-L", "--hard-links", "--numeric-ids", "--partial"}},
+		{name: "no-delete", options: []string{NoDeleteOption, "--checksum"}},
+		{name: "delete variant", options: []string{"--delete-after", "--delete-excluded"}},
+		{name: "chmod", options: []string{"--chmod=Du+rwx,Fgo-w", "--chmod=644"}},
+		{name: "sizes and rates", options: []string{"--bwlimit=1.5M", "--max-size=100k", "--min-size=1", "--compress-level=9"}},
+		{name: "timeouts", options: []string{"--timeout=30", "--modify-window=-1"}},
+
+		{name: "remote shell", options: []string{"--rsh=sh -c id"}, errText: `rsync option "--rsh=sh -c id" is not allowed`},
+		{name: "short remote shell", options: []string{"-e"}, errText: `rsync option "-e" is not allowed`},
+		{name: "remote rsync path", options: []string{"--rsync-path=sudo rsync"}, errText: "is not allowed"},
+		{name: "remove source files", options: []string{"--remove-source-files"}, errText: "is not allowed"},
+		{name: "local file list", options: []string{"--files-from=/etc/passwd"}, errText: "is not allowed"},
+		{name: "filter rules", options: []string{"--filter=merge /etc/rules"}, errText: "is not allowed"},
+		{name: "plain delete", options: []string{"--delete"}, errText: "is not allowed"},
+		{name: "value option without value", options: []string{"--chmod"}, errText: "rsync option --chmod requires a value (--chmod=<value>)"},
+		{name: "flag with value", options: []string{"--checksum=yes"}, errText: "is not allowed"},
+		{name: "chmod with a shell command", options: []string{"--chmod=755;id"}, errText: `invalid value for rsync option --chmod: "755;id"`},
+		{name: "rate with a unit suffix", options: []string{"--bwlimit=10MB"}, errText: "invalid value for rsync option --bwlimit"},
+		{name: "compress level out of range", options: []string{"--compress-level=10"}, errText: "invalid value for rsync option --compress-level"},
+		{name: "timeout with a line break", options: []string{"--timeout=30\n--rsh=sh"}, errText: "invalid value for rsync option --timeout"},
+		{name: "empty value", options: []string{"--max-size="}, errText: "invalid value for rsync option --max-size"},
+		{name: "no-delete with a delete variant", options: []string{NoDeleteOption, "--delete-after"}, errText: "rsync options --no-delete and --delete-after cannot be combined"},
+		{name: "delete variant before no-delete", options: []string{"--delete-excluded", NoDeleteOption}, errText: "cannot be combined"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateRsyncOptions(tt.options)
+			if tt.errText == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errText) {
+				t.Fatalf("error = %v, want one containing %q", err, tt.errText)
+			}
+		})
+	}
+}
+
+func TestRsyncBaseArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []string
+		want    []string
+	}{
+		{
+			name: "defaults",
+			want: append([]string{"-az", "--delete"}, itemizeArgs...),
+		},
+		{
+			name:    "options are appended",
+			options: []string{"--checksum", "--chmod=644"},
+			want:    append(append([]string{"-az", "--delete"}, itemizeArgs...), "--checksum", "--chmod=644"),
+		},
+		{
+			name:    "no-delete removes --delete and is not passed to rsync",
+			options: []string{"--checksum", NoDeleteOption},
+			want:    append(append([]string{"-az"}, itemizeArgs...), "--checksum"),
+		},
+		{
+			name:    "repeated no-delete",
+			options: []string{NoDeleteOption, NoDeleteOption},
+			want:    append([]string{"-az"}, itemizeArgs...),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &SSHSyncer{sshDetails: &models.SSHDetails{RsyncOptions: tt.options}}
+			if got := s.rsyncBaseArgs(); !slices.Equal(got, tt.want) {
+				t.Errorf("rsyncBaseArgs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRsyncRate(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int64
+	}{
+		{"100", 100},
+		{"100k", 100},
+		{"1.5M", 1536},
+		{"2G", 2 * 1024 * 1024},
+		{"0", 0},
+		{"fast", 0},
+	}
+
+	for _, tt := range tests {
+		if got := rsyncRate(tt.value); got != tt.want {
+			t.Errorf("rsyncRate(%q) = %d, want %d", tt.value, got, tt.want)
+		}
+	}
+}
@@ -4,16 +4,27 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"os"
 	"os/exec"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/sharedvolume/volume-syncer/internal/filter"
+	"github.com/sharedvolume/volume-syncer/internal/metrics"
 	"github.com/sharedvolume/volume-syncer/internal/models"
+	"github.com/sharedvolume/volume-syncer/internal/ownership"
+	"github.com/sharedvolume/volume-syncer/internal/procgroup"
+	"github.com/sharedvolume/volume-syncer/internal/progress"
+	"github.com/sharedvolume/volume-syncer/internal/proxy"
+	"github.com/sharedvolume/volume-syncer/internal/sshutil"
 	"github.com/sharedvolume/volume-syncer/internal/utils"
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
 )
 
 const (
@@ -30,10 +41,6 @@ func maskSSHCredentials(args []string) []string {
 		credentialRegex := regexp.MustCompile(`([^:@]+):([^@]+)@`)
 		maskedArgs[i] = credentialRegex.ReplaceAllString(arg, "${1}:***@")
 
-		// Mask sshpass password arguments: sshpass -p 'password'
-		sshpassRegex := regexp.MustCompile(`sshpass -p '([^']+)'`)
-		maskedArgs[i] = sshpassRegex.ReplaceAllString(maskedArgs[i], "sshpass -p '***'")
-
 		// Also mask any arguments that look like passwords
 		if strings.Contains(strings.ToLower(arg), "password") && len(arg) > 8 {
 			maskedArgs[i] = "***"
@@ -42,37 +49,88 @@ func maskSSHCredentials(args []string) []string {
 	return maskedArgs
 } // SSHSyncer handles SSH-based synchronization
 type SSHSyncer struct {
-	sshDetails *models.SSHDetails
-	targetPath string
-	timeout    time.Duration
+	sshDetails     *models.SSHDetails
+	targetPath     string
+	timeout        time.Duration
+	strictHostKeys bool
+	knownHostsFile string // set while a sync with host key verification is running
+	agentSocket    string // set when authenticating through ssh-agent
+	certificate    *ssh.Certificate
+	keyAgent       *sshutil.KeyAgent
+	certFile       string // temporary certificate file passed to rsync's ssh command
+	fileFilter     *filter.Matcher
+	filterFile     string                 // temporary rsync merge file listing the files selected by a regex filter
+	transfer       *models.TransferReport // changes itemized by the last Sync
+	processes      int                    // concurrent rsync processes sharing the bandwidth limit
+	proxyOption    string                 // ssh option routing rsync's ssh command through the proxy
+	proxyEnv       []string               // environment of the ProxyCommand
+	controlPath    string                 // socket of the master connection shared by rsync's ssh, set while it runs
 }
 
 // NewSSHSyncer creates a new SSH syncer
-func NewSSHSyncer(sshDetails *models.SSHDetails, targetPath string, timeout time.Duration) *SSHSyncer {
+func NewSSHSyncer(sshDetails *models.SSHDetails, targetPath string, timeout time.Duration, strictHostKeys bool) *SSHSyncer {
 	return &SSHSyncer{
-		sshDetails: sshDetails,
-		targetPath: targetPath,
-		timeout:    timeout,
+		sshDetails:     sshDetails,
+		targetPath:     targetPath,
+		timeout:        timeout,
+		strictHostKeys: strictHostKeys,
 	}
 }
 
 // Sync performs the synchronization using rsync over SSH
-func (s *SSHSyncer) Sync() error {
-	log.Printf("[SSH SYNC] Starting SSH sync from %s@%s:%d to %s", s.sshDetails.User, s.sshDetails.Host, s.sshDetails.Port, s.targetPath)
+func (s *SSHSyncer) Sync(ctx context.Context) error {
+	s.transfer = nil
+	s.processes = 1
+	progress.From(ctx).Phase(progress.PhaseConnecting)
+	if s.isPush() {
+		log.Printf("[SSH SYNC] Starting SSH push from %s to %s@%s:%d", s.targetPath, s.sshDetails.User, s.sshDetails.Host, s.sshDetails.Port)
+	} else {
+		log.Printf("[SSH SYNC] Starting SSH sync from %s@%s:%d to %s", s.sshDetails.User, s.sshDetails.Host, s.sshDetails.Port, s.targetPath)
+	}
 	log.Printf("[SSH SYNC] SSH Details - Host: %s, Port: %d, User: %s, Path: '%s'", s.sshDetails.Host, s.sshDetails.Port, s.sshDetails.User, s.sshDetails.Path)
 	log.Printf("[SSH SYNC] Timeout configured: %v", s.timeout)
 
-	// Ensure target directory exists
-	log.Printf("[SSH SYNC] Creating target directory: %s", s.targetPath)
-	if err := utils.EnsureDir(s.targetPath); err != nil {
-		log.Printf("[SSH SYNC] ERROR: Failed to create target directory: %v", err)
-		return fmt.Errorf("failed to create target directory: %w", err)
+	if s.isPush() {
+		// The local path is the data source in push mode and must already exist
+		log.Printf("[SSH SYNC] Checking local source directory: %s", s.targetPath)
+		if stat, err := os.Stat(s.targetPath); err != nil || !stat.IsDir() {
+			log.Printf("[SSH SYNC] ERROR: Local source directory %s does not exist", s.targetPath)
+			return fmt.Errorf("local source directory %s does not exist or is not a directory", s.targetPath)
+		}
+	} else {
+		// Ensure target directory exists
+		log.Printf("[SSH SYNC] Creating target directory: %s", s.targetPath)
+		if err := utils.EnsureDir(s.targetPath); err != nil {
+			log.Printf("[SSH SYNC] ERROR: Failed to create target directory: %v", err)
+			return fmt.Errorf("failed to create target directory: %w", err)
+		}
+		log.Printf("[SSH SYNC] Target directory created successfully")
+	}
+
+	cleanupProxy, err := s.setupProxy()
+	if err != nil {
+		log.Printf("[SSH SYNC] ERROR: Proxy setup failed: %v", err)
+		return fmt.Errorf("proxy setup failed: %w", err)
+	}
+	defer cleanupProxy()
+
+	// Host keys are verified by both the connection test and rsync's ssh command
+	cleanupKnownHosts, err := s.setupKnownHosts()
+	if err != nil {
+		log.Printf("[SSH SYNC] ERROR: Host key verification setup failed: %v", err)
+		return fmt.Errorf("host key verification setup failed: %w", err)
 	}
-	log.Printf("[SSH SYNC] Target directory created successfully")
+	defer cleanupKnownHosts()
+
+	cleanupCertificate, err := s.setupCertificate()
+	if err != nil {
+		log.Printf("[SSH SYNC] ERROR: Certificate setup failed: %v", err)
+		return fmt.Errorf("certificate setup failed: %w", err)
+	}
+	defer cleanupCertificate()
 
 	var tmpKeyFile string
 	var privateKeyBytes []byte
-	var err error
 
 	// If private key from file is provided, use key auth
 	if s.sshDetails.KeyPath != "" {
@@ -92,17 +150,13 @@ func (s *SSHSyncer) Sync() error {
 
 		log.Printf("[SSH SYNC] Private key loaded successfully (%d bytes)", len(privateKeyBytes))
 
-		log.Printf("[SSH SYNC] Creating temporary key file for rsync")
-		tmpKeyFile, err = s.createTempKeyFile(privateKeyBytes)
+		var cleanupKey func()
+		tmpKeyFile, cleanupKey, err = s.provideKey(privateKeyBytes)
 		if err != nil {
 			log.Printf("[SSH SYNC] ERROR: Failed to create temporary key file: %v", err)
 			return fmt.Errorf("failed to create temporary key file: %w", err)
 		}
-		defer func() {
-			log.Printf("[SSH SYNC] Cleaning up temporary key file: %s", tmpKeyFile)
-			os.Remove(tmpKeyFile)
-		}()
-		log.Printf("[SSH SYNC] Temporary key file created: %s", tmpKeyFile)
+		defer cleanupKey()
 
 		// Test SSH connection with key
 		log.Printf("[SSH SYNC] Testing SSH connection with private key...")
@@ -142,17 +196,13 @@ func (s *SSHSyncer) Sync() error {
 			log.Printf("[SSH SYNC] WARNING: Decoded key doesn't contain expected OpenSSH footer")
 		}
 
-		log.Printf("[SSH SYNC] Creating temporary key file for rsync")
-		tmpKeyFile, err = s.createTempKeyFile(privateKeyBytes)
+		var cleanupKey func()
+		tmpKeyFile, cleanupKey, err = s.provideKey(privateKeyBytes)
 		if err != nil {
 			log.Printf("[SSH SYNC] ERROR: Failed to create temporary key file: %v", err)
 			return fmt.Errorf("failed to create temporary key file: %w", err)
 		}
-		defer func() {
-			log.Printf("[SSH SYNC] Cleaning up temporary key file: %s", tmpKeyFile)
-			os.Remove(tmpKeyFile)
-		}()
-		log.Printf("[SSH SYNC] Temporary key file created: %s", tmpKeyFile)
+		defer cleanupKey()
 
 		// Test SSH connection with key
 		log.Printf("[SSH SYNC] Testing SSH connection with private key...")
@@ -164,13 +214,6 @@ func (s *SSHSyncer) Sync() error {
 	} else if s.sshDetails.Password != "" {
 		log.Printf("[SSH SYNC] Using password authentication")
 
-		// Check if sshpass is available
-		if _, err := exec.LookPath("sshpass"); err != nil {
-			log.Printf("[SSH SYNC] ERROR: Password authentication requires 'sshpass' utility, but it's not installed")
-			log.Printf("[SSH SYNC] Please install sshpass or use SSH key authentication instead")
-			return fmt.Errorf("password authentication requires 'sshpass' utility, but it's not available. Please install sshpass or use SSH key authentication")
-		}
-
 		// Test SSH connection with password
 		log.Printf("[SSH SYNC] Testing SSH connection with password...")
 		if err := s.testSSHConnection(nil, s.sshDetails.Password); err != nil {
@@ -179,8 +222,17 @@ func (s *SSHSyncer) Sync() error {
 		}
 		log.Printf(logSSHConnTestSuccess)
 	} else {
-		log.Printf("[SSH SYNC] Using no authentication (public key from ssh-agent)")
-		// Test SSH connection with no auth
+		s.agentSocket, err = sshutil.ResolveAgentSocket(s.sshDetails.AgentSocket)
+		if err != nil {
+			log.Printf("[SSH SYNC] ERROR: ssh-agent setup failed: %v", err)
+			return fmt.Errorf("ssh-agent setup failed: %w", err)
+		}
+		if s.agentSocket != "" {
+			log.Printf("[SSH SYNC] Using ssh-agent authentication via %s", s.agentSocket)
+		} else {
+			log.Printf("[SSH SYNC] Using no authentication (default SSH identities)")
+		}
+		// Test SSH connection with agent or no auth
 		log.Printf("[SSH SYNC] Testing SSH connection...")
 		if err := s.testSSHConnection(nil, ""); err != nil {
 			log.Printf(logSSHConnTestFailed, err)
@@ -212,85 +264,381 @@ func (s *SSHSyncer) Sync() error {
 		log.Printf("[SSH SYNC] Found ssh command at: %s", sshPath)
 	}
 
-	rsyncCmd := s.buildRsyncCommand(tmpKeyFile)
-	log.Printf("[SSH SYNC] Rsync command built with %d arguments", len(rsyncCmd))
+	rsyncEnv, err := s.rsyncEnv(tmpKeyFile)
+	if err != nil {
+		log.Printf("[SSH SYNC] ERROR: Failed to set up password authentication: %v", err)
+		return fmt.Errorf("failed to set up password authentication: %w", err)
+	}
+
+	stopControlMaster := s.setupControlMaster(ctx, tmpKeyFile, rsyncEnv)
+	defer stopControlMaster()
+
+	cleanupFilters, err := s.setupFileFilters(ctx, tmpKeyFile, rsyncEnv)
+	if err != nil {
+		log.Printf("[SSH SYNC] ERROR: File filter setup failed: %v", err)
+		return fmt.Errorf("file filter setup failed: %w", err)
+	}
+	defer cleanupFilters()
 
 	// Create context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
 	defer cancel()
 
-	// Execute rsync command
-	cmd := exec.CommandContext(ctx, "rsync", rsyncCmd...)
-	cmd.Stdout = os.Stdout
+	var transfer *models.TransferReport
+	if s.sshDetails.Parallelism > 1 {
+		transfer, err = s.parallelRsync(ctx, tmpKeyFile, rsyncEnv)
+	} else {
+		progress.From(ctx).Phase(progress.PhaseTransferring)
+		rsyncCmd := s.buildRsyncCommand(tmpKeyFile)
+		log.Printf("[SSH SYNC] Rsync command built with %d arguments", len(rsyncCmd))
+		transfer, err = s.runRsync(ctx, rsyncCmd, rsyncEnv)
+	}
+	if err != nil {
+		return err
+	}
+	s.transfer = transfer
+
+	log.Printf("[SSH SYNC] Data transfer completed successfully: %d added, %d updated, %d deleted, %d bytes sent, %d bytes received",
+		len(transfer.Added), len(transfer.Updated), len(transfer.Deleted), transfer.BytesSent, transfer.BytesReceived)
+	log.Printf("[SSH SYNC] SSH sync completed successfully")
+	return nil
+}
+
+// runRsync runs rsync with the arguments and parses its itemized output into the transfer report
+func (s *SSHSyncer) runRsync(ctx context.Context, args []string, env []string) (*models.TransferReport, error) {
+	cmd := exec.CommandContext(ctx, "rsync", args...)
+	procgroup.Configure(cmd)
 	cmd.Stderr = os.Stderr
+	cmd.Env = env
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to capture rsync output: %w", err)
+	}
 
 	// Mask credentials in the command logging
 	maskedArgs := maskSSHCredentials(cmd.Args)
 	log.Printf("[SSH SYNC] Executing rsync command: %v", maskedArgs)
 	log.Printf("[SSH SYNC] Starting data transfer...")
 
-	if err := cmd.Run(); err != nil {
+	if err := cmd.Start(); err != nil {
+		log.Printf("[SSH SYNC] ERROR: Failed to start rsync: %v", err)
+		return nil, fmt.Errorf("rsync failed: %w", err)
+	}
+	transfer, parseErr := parseTransfer(stdout, progress.From(ctx))
+	if parseErr != nil {
+		// Drain the output, so that rsync does not block on a full pipe
+		_, _ = io.Copy(io.Discard, stdout)
+	}
+	err = cmd.Wait()
+	metrics.CommandExited(ctx, "rsync", err)
+	if err != nil {
 		if ctx.Err() == context.DeadlineExceeded {
 			log.Printf("[SSH SYNC] ERROR: Sync operation timed out after %v", s.timeout)
-			return fmt.Errorf("sync operation timed out after %v", s.timeout)
+			return nil, fmt.Errorf("sync operation timed out after %v", s.timeout)
 		}
 		log.Printf("[SSH SYNC] ERROR: Rsync failed: %v", err)
-		return fmt.Errorf("rsync failed: %w", err)
+		return nil, fmt.Errorf("rsync failed: %w", err)
+	}
+	if parseErr != nil {
+		log.Printf("[SSH SYNC] WARNING: Failed to parse rsync output: %v", parseErr)
 	}
+	return transfer, nil
+}
 
-	log.Printf("[SSH SYNC] Data transfer completed successfully")
-	log.Printf("[SSH SYNC] SSH sync completed successfully")
-	return nil
+// Result returns the changes itemized by rsync during the last sync
+func (s *SSHSyncer) Result() models.SyncResult {
+	return models.SyncResult{Transfer: s.transfer}
 }
 
 // testSSHConnection tests the SSH connection
 func (s *SSHSyncer) testSSHConnection(privateKeyBytes []byte, password string) error {
+	client, err := s.dial(privateKeyBytes, password)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	// Create session to test connection
+	session, err := client.NewSession()
+	if err != nil {
+		return fmt.Errorf("failed to create SSH session: %w", err)
+	}
+	defer session.Close()
+
+	// Run a simple command to verify connection
+	if err := session.Run("echo 'connection test'"); err != nil {
+		return fmt.Errorf("SSH connection test command failed: %w", err)
+	}
+
+	return nil
+}
+
+// dial connects and authenticates to the SSH server
+func (s *SSHSyncer) dial(privateKeyBytes []byte, password string) (*ssh.Client, error) {
 	var authMethods []ssh.AuthMethod
 	if len(privateKeyBytes) > 0 {
 		signer, err := ssh.ParsePrivateKey(privateKeyBytes)
 		if err != nil {
-			return fmt.Errorf("failed to parse private key: %w", err)
+			return nil, fmt.Errorf("failed to parse private key: %w", err)
+		}
+		if s.certificate != nil {
+			certSigner, err := ssh.NewCertSigner(s.certificate, signer)
+			if err != nil {
+				return nil, fmt.Errorf("certificate does not match private key: %w", err)
+			}
+			signer = certSigner
 		}
 		authMethods = append(authMethods, ssh.PublicKeys(signer))
 	}
 	if password != "" {
 		authMethods = append(authMethods, ssh.Password(password))
 	}
+	if len(authMethods) == 0 && s.agentSocket != "" {
+		agentClient, closeAgent, err := sshutil.DialAgent(s.agentSocket)
+		if err != nil {
+			return nil, err
+		}
+		defer closeAgent()
+		authMethods = append(authMethods, ssh.PublicKeysCallback(agentClient.Signers))
+	}
+
+	hostKeyCallback := ssh.InsecureIgnoreHostKey()
+	if s.knownHostsFile != "" {
+		callback, err := knownhosts.New(s.knownHostsFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load known hosts: %w", err)
+		}
+		hostKeyCallback = callback
+	}
 
 	// If no auth methods, try empty list (let SSH try agent, etc.)
 	config := &ssh.ClientConfig{
 		User:            s.sshDetails.User,
 		Auth:            authMethods,
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(), // In production, use proper host key verification
-		Timeout:         10 * time.Second,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         s.connectTimeout(),
+		Config: ssh.Config{
+			Ciphers:      s.sshDetails.Ciphers,
+			KeyExchanges: s.sshDetails.KexAlgorithms,
+		},
+	}
+
+	p, err := proxy.New(s.sshDetails.Proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy: %w", err)
 	}
 
 	// Connect to SSH server
-	addr := fmt.Sprintf("%s:%d", s.sshDetails.Host, s.sshDetails.Port)
-	client, err := ssh.Dial("tcp", addr, config)
+	client, err := sshutil.Dial(p, s.address(), config)
 	if err != nil {
-		return fmt.Errorf("failed to connect to SSH server: %w", err)
+		return nil, fmt.Errorf("failed to connect to SSH server: %w", err)
 	}
-	defer client.Close()
+	if interval, err := time.ParseDuration(s.sshDetails.ServerAliveInterval); err == nil {
+		sshutil.KeepAlive(client, interval, s.sshDetails.ServerAliveCountMax)
+	}
+	return client, nil
+}
 
-	// Create session to test connection
-	session, err := client.NewSession()
+// connectTimeout returns the timeout of connecting to the server and the SSH handshake
+func (s *SSHSyncer) connectTimeout() time.Duration {
+	// Validated with the details
+	if timeout, err := time.ParseDuration(s.sshDetails.ConnectTimeout); err == nil {
+		return timeout
+	}
+	return 10 * time.Second
+}
+
+// connectionOptions returns the ssh options of the connection tuning for rsync
+func (s *SSHSyncer) connectionOptions() string {
+	var options []string
+	if timeout, err := time.ParseDuration(s.sshDetails.ConnectTimeout); err == nil {
+		options = append(options, fmt.Sprintf("-o ConnectTimeout=%d", seconds(timeout)))
+	}
+	if interval, err := time.ParseDuration(s.sshDetails.ServerAliveInterval); err == nil {
+		options = append(options, fmt.Sprintf("-o ServerAliveInterval=%d", seconds(interval)))
+	}
+	if s.sshDetails.ServerAliveCountMax > 0 {
+		options = append(options, fmt.Sprintf("-o ServerAliveCountMax=%d", s.sshDetails.ServerAliveCountMax))
+	}
+	if len(s.sshDetails.Ciphers) > 0 {
+		options = append(options, "-o Ciphers="+strings.Join(s.sshDetails.Ciphers, ","))
+	}
+	if len(s.sshDetails.KexAlgorithms) > 0 {
+		options = append(options, "-o KexAlgorithms="+strings.Join(s.sshDetails.KexAlgorithms, ","))
+	}
+	return strings.Join(options, " ")
+}
+
+// seconds returns the duration in whole seconds for ssh options, rounded up
+func seconds(duration time.Duration) int {
+	return int((duration + time.Second - 1) / time.Second)
+}
+
+// address returns the host:port of the SSH server
+func (s *SSHSyncer) address() string {
+	return net.JoinHostPort(s.sshDetails.Host, strconv.Itoa(s.sshDetails.Port))
+}
+
+// setupProxy prepares the ssh option and the environment routing rsync's ssh command through
+// the proxy
+func (s *SSHSyncer) setupProxy() (func(), error) {
+	p, err := proxy.New(s.sshDetails.Proxy)
 	if err != nil {
-		return fmt.Errorf("failed to create SSH session: %w", err)
+		return nil, fmt.Errorf("invalid proxy: %w", err)
 	}
-	defer session.Close()
+	option, env, err := p.SSHOptions(s.address())
+	if err != nil {
+		return nil, err
+	}
+	if p.Route(s.address()) != nil {
+		log.Printf("[SSH SYNC] Connecting to %s through proxy %s", s.address(), p)
+	}
+	s.proxyOption, s.proxyEnv = option, env
+	return func() { s.proxyOption, s.proxyEnv = "", nil }, nil
+}
 
-	// Run a simple command to verify connection
-	if err := session.Run("echo 'connection test'"); err != nil {
-		return fmt.Errorf("SSH connection test command failed: %w", err)
+// isPush reports whether the local path is pushed to the remote host
+func (s *SSHSyncer) isPush() bool {
+	return s.sshDetails.Direction == models.SSHDirectionPush
+}
+
+// setupKnownHosts writes a temporary known_hosts file from the configured host key material.
+// Without host key material verification is skipped, unless strict host key checking is enabled.
+func (s *SSHSyncer) setupKnownHosts() (func(), error) {
+	if s.sshDetails.KnownHosts == "" && s.sshDetails.HostKeyFingerprint == "" {
+		if s.strictHostKeys {
+			return nil, fmt.Errorf("strict host key checking is enabled: knownHosts or hostKeyFingerprint is required")
+		}
+		log.Printf("[SSH SYNC] WARNING: No host key material provided, host key verification is disabled")
+		return utils.NoCleanup, nil
 	}
 
-	return nil
+	var content string
+	var err error
+	if s.sshDetails.KnownHosts != "" {
+		log.Printf("[SSH SYNC] Using provided known hosts for %s:%d", s.sshDetails.Host, s.sshDetails.Port)
+		content, err = sshutil.BuildKnownHosts(s.sshDetails.KnownHosts, s.sshDetails.Host, s.sshDetails.Port)
+	} else {
+		log.Printf("[SSH SYNC] Verifying host key fingerprint for %s:%d", s.sshDetails.Host, s.sshDetails.Port)
+		var p *proxy.Proxy
+		if p, err = proxy.New(s.sshDetails.Proxy); err != nil {
+			return nil, fmt.Errorf("invalid proxy: %w", err)
+		}
+		content, err = sshutil.KnownHostsForFingerprint(s.sshDetails.Host, s.sshDetails.Port, s.sshDetails.HostKeyFingerprint, s.connectTimeout(), p)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	knownHostsFile, err := sshutil.WriteKnownHostsFile(s.sshDetails.CredentialsDir, content)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("[SSH SYNC] Temporary known_hosts file created: %s", knownHostsFile)
+	s.knownHostsFile = knownHostsFile
+
+	return func() {
+		log.Printf("[SSH SYNC] Cleaning up temporary known_hosts file: %s", knownHostsFile)
+		os.Remove(knownHostsFile)
+		s.knownHostsFile = ""
+	}, nil
+}
+
+// hostKeyOptions returns the ssh options controlling host key verification for rsync
+func (s *SSHSyncer) hostKeyOptions() string {
+	if s.knownHostsFile != "" {
+		return fmt.Sprintf("-o StrictHostKeyChecking=yes -o UserKnownHostsFile=%s", s.knownHostsFile)
+	}
+	return "-o StrictHostKeyChecking=no -o UserKnownHostsFile=/dev/null"
+}
+
+// setupCertificate decodes the OpenSSH user certificate and writes it to a temporary file for rsync
+func (s *SSHSyncer) setupCertificate() (func(), error) {
+	if s.sshDetails.Certificate == "" {
+		return utils.NoCleanup, nil
+	}
+
+	certBytes, err := base64.StdEncoding.DecodeString(s.sshDetails.Certificate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode base64 certificate: %w", err)
+	}
+
+	pub, _, _, _, err := ssh.ParseAuthorizedKey(certBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+	cert, ok := pub.(*ssh.Certificate)
+	if !ok {
+		return nil, fmt.Errorf("certificate is a plain public key, not an OpenSSH certificate")
+	}
+	if cert.CertType != ssh.UserCert {
+		return nil, fmt.Errorf("certificate is not a user certificate")
+	}
+
+	now := uint64(time.Now().Unix())
+	if cert.ValidBefore != ssh.CertTimeInfinity && now >= cert.ValidBefore {
+		return nil, fmt.Errorf("certificate expired at %s", time.Unix(int64(cert.ValidBefore), 0).UTC().Format(time.RFC3339))
+	}
+	if now < cert.ValidAfter {
+		return nil, fmt.Errorf("certificate is not valid before %s", time.Unix(int64(cert.ValidAfter), 0).UTC().Format(time.RFC3339))
+	}
+	log.Printf("[SSH SYNC] Using SSH certificate (key ID: %s, principals: %v)", cert.KeyId, cert.ValidPrincipals)
+
+	certFile, err := os.CreateTemp(s.sshDetails.CredentialsDir, "ssh_cert_*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create certificate file: %w", err)
+	}
+	defer certFile.Close()
+
+	if _, err := certFile.Write(ssh.MarshalAuthorizedKey(cert)); err != nil {
+		os.Remove(certFile.Name())
+		return nil, fmt.Errorf("failed to write certificate file: %w", err)
+	}
+
+	s.certificate = cert
+	s.certFile = certFile.Name()
+	return func() {
+		log.Printf("[SSH SYNC] Cleaning up temporary certificate file: %s", s.certFile)
+		os.Remove(s.certFile)
+		s.certificate = nil
+		s.certFile = ""
+	}, nil
+}
+
+// provideKey makes the private key available to rsync's ssh command. The key is served by an
+// in-process agent, so that it is not written to disk, unless key files are configured or the
+// agent cannot be started; then it is written to a temporary file, whose path is returned.
+func (s *SSHSyncer) provideKey(privateKeyBytes []byte) (string, func(), error) {
+	if !s.sshDetails.KeyFiles {
+		keyAgent, err := sshutil.StartKeyAgent(s.sshDetails.CredentialsDir, privateKeyBytes, s.certificate)
+		if err == nil {
+			log.Printf("[SSH SYNC] Serving private key to rsync through in-process agent %s", keyAgent.Socket())
+			s.keyAgent = keyAgent
+			s.agentSocket = keyAgent.Socket()
+			return "", func() {
+				keyAgent.Close()
+				s.keyAgent = nil
+				s.agentSocket = ""
+			}, nil
+		}
+		log.Printf("[SSH SYNC] WARNING: Failed to start in-process key agent, falling back to a temporary key file: %v", err)
+	}
+
+	log.Printf("[SSH SYNC] Creating temporary key file for rsync")
+	tmpKeyFile, err := s.createTempKeyFile(privateKeyBytes)
+	if err != nil {
+		return "", nil, err
+	}
+	log.Printf("[SSH SYNC] Temporary key file created: %s", tmpKeyFile)
+	return tmpKeyFile, func() {
+		log.Printf("[SSH SYNC] Cleaning up temporary key file: %s", tmpKeyFile)
+		os.Remove(tmpKeyFile)
+	}, nil
 }
 
 // createTempKeyFile creates a temporary file with the private key
 func (s *SSHSyncer) createTempKeyFile(privateKeyBytes []byte) (string, error) {
-	tmpFile, err := os.CreateTemp("", "ssh_key_*")
+	tmpFile, err := os.CreateTemp(s.sshDetails.CredentialsDir, "ssh_key_*")
 	if err != nil {
 		return "", err
 	}
@@ -311,8 +659,28 @@ func (s *SSHSyncer) createTempKeyFile(privateKeyBytes []byte) (string, error) {
 	return tmpFile.Name(), nil
 }
 
-// buildRsyncCommand builds the rsync command arguments
-func (s *SSHSyncer) buildRsyncCommand(keyFile string) []string {
+// rsyncEnv returns the environment for rsync and its ssh command, or nil to inherit the process environment
+func (s *SSHSyncer) rsyncEnv(keyFile string) ([]string, error) {
+	var env []string
+	if s.agentSocket != "" {
+		env = append(env, "SSH_AUTH_SOCK="+s.agentSocket)
+	} else if keyFile == "" && s.sshDetails.Password != "" {
+		// ssh reads the password from the SSH_ASKPASS helper instead of the command line
+		askPassEnv, err := sshutil.AskPassEnv(s.sshDetails.Password)
+		if err != nil {
+			return nil, err
+		}
+		env = append(env, askPassEnv...)
+	}
+	env = append(env, s.proxyEnv...)
+	if len(env) == 0 {
+		return nil, nil
+	}
+	return append(os.Environ(), env...), nil
+}
+
+// rsyncSSHCommand builds the remote shell command passed to rsync with -e
+func (s *SSHSyncer) rsyncSSHCommand(keyFile string) string {
 	// Detect SSH path
 	sshPath := "ssh" // default fallback
 	if detectedPath, err := exec.LookPath("ssh"); err == nil {
@@ -333,47 +701,109 @@ func (s *SSHSyncer) buildRsyncCommand(keyFile string) []string {
 	var sshCmd string
 	if keyFile != "" {
 		// Use private key authentication with detected ssh path
-		sshCmd = fmt.Sprintf("%s -i %s -p %d -o StrictHostKeyChecking=no -o UserKnownHostsFile=/dev/null",
-			sshPath, keyFile, s.sshDetails.Port)
-	} else if s.sshDetails.Password != "" {
-		// Use password authentication with sshpass (if available)
-		// Escape single quotes in password to prevent shell injection
-		escapedPassword := strings.ReplaceAll(s.sshDetails.Password, "'", "'\"'\"'")
-
-		// Detect sshpass path
-		sshpassPath := "sshpass"
-		if detectedPath, err := exec.LookPath("sshpass"); err == nil {
-			sshpassPath = detectedPath
+		sshCmd = fmt.Sprintf("%s -i %s -p %d %s",
+			sshPath, keyFile, s.sshDetails.Port, s.hostKeyOptions())
+		if s.certFile != "" {
+			sshCmd += " -o CertificateFile=" + s.certFile
 		}
-
-		sshCmd = fmt.Sprintf("%s -p '%s' %s -p %d -o StrictHostKeyChecking=no -o UserKnownHostsFile=/dev/null",
-			sshpassPath, escapedPassword, sshPath, s.sshDetails.Port)
+	} else if s.keyAgent != nil {
+		// Use the private key (and certificate) served by the in-process agent through SSH_AUTH_SOCK
+		sshCmd = fmt.Sprintf("%s -p %d -o PreferredAuthentications=publickey %s",
+			sshPath, s.sshDetails.Port, s.hostKeyOptions())
+	} else if s.sshDetails.Password != "" {
+		// Use password authentication; the password is supplied through SSH_ASKPASS
+		sshCmd = fmt.Sprintf("%s -p %d -o PreferredAuthentications=password,keyboard-interactive -o NumberOfPasswordPrompts=1 %s",
+			sshPath, s.sshDetails.Port, s.hostKeyOptions())
 	} else {
 		// Use ssh-agent or default SSH authentication
-		sshCmd = fmt.Sprintf("%s -p %d -o StrictHostKeyChecking=no -o UserKnownHostsFile=/dev/null",
-			sshPath, s.sshDetails.Port)
+		sshCmd = fmt.Sprintf("%s -p %d %s",
+			sshPath, s.sshDetails.Port, s.hostKeyOptions())
+	}
+	if options := s.connectionOptions(); options != "" {
+		sshCmd += " " + options
+	}
+	if s.proxyOption != "" {
+		sshCmd += " " + s.proxyOption
+	}
+	if options := s.controlOptions(); options != "" {
+		sshCmd += " " + options
 	}
 
-	// Build the full source string using the specified path
-	log.Printf("[SSH SYNC] Building source path - User: %s, Host: %s, Path: '%s'", s.sshDetails.User, s.sshDetails.Host, s.sshDetails.Path)
+	return sshCmd
+}
+
+// remoteSpec returns the user@host:path/ argument for rsync
+func (s *SSHSyncer) remoteSpec() string {
+	// Build the full remote string using the specified path
+	log.Printf("[SSH SYNC] Building remote path - User: %s, Host: %s, Path: '%s'", s.sshDetails.User, s.sshDetails.Host, s.sshDetails.Path)
 
-	// Add trailing slash to source path to copy contents of directory, not the directory itself
-	sourcePath := s.sshDetails.Path
-	if !strings.HasSuffix(sourcePath, "/") {
-		sourcePath += "/"
+	// Add trailing slash to remote path to copy contents of directory, not the directory itself
+	remotePath := s.sshDetails.Path
+	if !strings.HasSuffix(remotePath, "/") {
+		remotePath += "/"
 	}
 
-	fullSource := fmt.Sprintf("%s@%s:%s", s.sshDetails.User, s.sshDetails.Host, sourcePath)
-	log.Printf("[SSH SYNC] Full source string: %s", fullSource)
+	fullRemote := fmt.Sprintf("%s@%s:%s", s.sshDetails.User, s.sshDetails.Host, remotePath)
+	log.Printf("[SSH SYNC] Full remote string: %s", fullRemote)
+	return fullRemote
+}
+
+// buildRsyncCommand builds the rsync command arguments. The partition rules restrict a parallel
+// transfer to its part of the tree and precede the transfer rules.
+func (s *SSHSyncer) buildRsyncCommand(keyFile string, partition ...string) []string {
+	sshCmd := s.rsyncSSHCommand(keyFile)
+	fullRemote := s.remoteSpec()
 
 	// Build rsync arguments
-	args := []string{
-		"-avz",       // archive, verbose, compress
-		"--delete",   // delete files that don't exist on source
-		"--progress", // show progress
-		"-e", sshCmd, // specify SSH command
-		fullSource,         // source
-		s.targetPath + "/", // target (ensure trailing slash)
+	args := append(s.rsyncBaseArgs(), "-e", sshCmd)
+	if len(s.sshDetails.RsyncOptions) > 0 {
+		log.Printf("[SSH SYNC] Using rsync options: %v", s.sshDetails.RsyncOptions)
+	}
+
+	// Transfer rules; rsync applies the first matching rule
+	for _, rule := range partition {
+		args = append(args, "--filter="+rule)
+	}
+	for _, rule := range s.sshDetails.Filter {
+		args = append(args, "--filter="+rule)
+	}
+	for _, pattern := range s.sshDetails.Include {
+		args = append(args, "--include="+pattern)
+	}
+	for _, pattern := range s.sshDetails.Exclude {
+		args = append(args, "--exclude="+pattern)
+	}
+	if n := len(s.sshDetails.Filter) + len(s.sshDetails.Include) + len(s.sshDetails.Exclude); n > 0 {
+		log.Printf("[SSH SYNC] Applying %d rsync filter rules", n)
+	}
+	args = append(args, s.fileFilterArgs()...)
+
+	if bandwidthArgs := s.bandwidthArgs(); len(bandwidthArgs) > 0 {
+		log.Printf("[SSH SYNC] Applying bandwidth limit: %v", bandwidthArgs)
+		args = append(args, bandwidthArgs...)
+	}
+
+	if fileHandlingArgs := s.fileHandlingArgs(); len(fileHandlingArgs) > 0 {
+		log.Printf("[SSH SYNC] Applying file handling: %v", fileHandlingArgs)
+		args = append(args, fileHandlingArgs...)
+	}
+
+	// Owner and permissions of the synced files, validated by the syncer factory
+	if mapping, _ := ownership.New(s.sshDetails.Ownership); mapping != nil {
+		log.Printf("[SSH SYNC] Applying ownership: %v", mapping.RsyncArgs())
+		args = append(args, mapping.RsyncArgs()...)
+	}
+
+	if s.isPush() {
+		args = append(args,
+			s.targetPath+"/", // local source (ensure trailing slash)
+			fullRemote,       // remote destination
+		)
+	} else {
+		args = append(args,
+			fullRemote,       // source
+			s.targetPath+"/", // target (ensure trailing slash)
+		)
 	}
 
 	// Log the command for debugging
@@ -1,61 +1,381 @@
 package syncer
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/sharedvolume/volume-syncer/internal/bwlimit"
+	"github.com/sharedvolume/volume-syncer/internal/cache"
+	"github.com/sharedvolume/volume-syncer/internal/config"
+	"github.com/sharedvolume/volume-syncer/internal/credentials"
+	"github.com/sharedvolume/volume-syncer/internal/filter"
 	"github.com/sharedvolume/volume-syncer/internal/models"
+	"github.com/sharedvolume/volume-syncer/internal/ownership"
+	"github.com/sharedvolume/volume-syncer/internal/proxy"
+	"github.com/sharedvolume/volume-syncer/internal/quota"
+	"github.com/sharedvolume/volume-syncer/internal/retry"
+	"github.com/sharedvolume/volume-syncer/internal/secrets"
 	"github.com/sharedvolume/volume-syncer/internal/syncer/git"
 	"github.com/sharedvolume/volume-syncer/internal/syncer/http"
 	"github.com/sharedvolume/volume-syncer/internal/syncer/s3"
 	"github.com/sharedvolume/volume-syncer/internal/syncer/ssh"
+	"github.com/sharedvolume/volume-syncer/internal/transport"
+	"github.com/sharedvolume/volume-syncer/internal/validation"
+	"github.com/sharedvolume/volume-syncer/internal/versions"
+	"github.com/sharedvolume/volume-syncer/internal/workdirs"
 )
 
+// gitFilterRegex matches the partial clone filter specs accepted for Git sources
+var gitFilterRegex = regexp.MustCompile(`^(blob:none|tree:0|blob:limit=[0-9]+[kmgKMG]?)$`)
+
+// rsyncFilterRuleRegex matches the rsync filter rules accepted for SSH sources.
+// merge and dir-merge rules are rejected since they read rule files from the local filesystem.
+var rsyncFilterRuleRegex = regexp.MustCompile(`^(([-+PHSR]|include|exclude|protect|hide|show|risk)(,[!/Cnrsepx]+)? .+|!|clear)$`)
+
+// sshAlgorithmRegex matches the names of SSH ciphers and key exchange algorithms, e.g.
+// aes256-gcm@openssh.com
+var sshAlgorithmRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9.+_@-]*$`)
+
 // Syncer interface defines the contract for all synchronization implementations
 type Syncer interface {
-	Sync() error
+	Sync(ctx context.Context) error
 }
 
 // SyncerFactory creates syncers based on source type
 type SyncerFactory struct {
-	timeout time.Duration
+	timeout        time.Duration
+	strictHostKeys bool
+	keyFiles       bool // pass private keys to ssh in temporary files instead of an in-process agent
+	controlMaster  bool // share one SSH connection between the rsync processes of a sync by default
+	gitOptions     git.Options
+	secrets        *secrets.Store
+	credentials    *credentials.Store // stored credentials referenced by sources, nil when disabled
+	references     *secrets.Resolver  // resolves the secret references of source details
+	ownership      models.Ownership   // default ownership
+	quotas         *quota.Quotas
+	downloads      *cache.Cache   // shared download cache of HTTP and S3 sources, nil when disabled
+	dirs           *workdirs.Dirs // directories of staging directories and key material, nil for the defaults
+	hashAlgorithm  string         // algorithm comparing the contents of files hard-linked between versions, if any
+	transports     *transport.Factory
+	proxy          *models.ProxyOptions // proxy of requests setting none, nil to leave it to the environment
+	extractSize    int64                // bytes extracted from each archive at most, 0 for no limit
+	extractEntries int                  // entries extracted from each archive at most, 0 for no limit
 }
 
 // NewSyncerFactory creates a new syncer factory
-func NewSyncerFactory(timeout time.Duration) *SyncerFactory {
+func NewSyncerFactory(cfg *config.SyncConfig, quotas *quota.Quotas, downloads *cache.Cache, dirs *workdirs.Dirs, credentialStore *credentials.Store, transports *transport.Factory) *SyncerFactory {
+	// The size was validated by the server at startup
+	extractSize, _ := quota.ParseSize(cfg.ExtractMaxSize)
 	return &SyncerFactory{
-		timeout: timeout,
+		timeout:        cfg.DefaultTimeout,
+		strictHostKeys: cfg.StrictHostKeys,
+		keyFiles:       cfg.SSHKeyFiles,
+		controlMaster:  cfg.SSHControlMaster,
+		gitOptions: git.Options{
+			Implementation:  cfg.GitImplementation,
+			CacheDir:        cfg.GitCacheDir,
+			CacheDissociate: cfg.GitCacheDissociate,
+			StrictHostKeys:  cfg.StrictHostKeys,
+			KeyFiles:        cfg.SSHKeyFiles,
+			WorkDirs:        dirs,
+		},
+		secrets:     secrets.NewStore(cfg.SecretsDir),
+		credentials: credentialStore,
+		references:  secrets.NewResolver(cfg.SecretEnvNames, cfg.SecretFileRoots, cfg.SecretManagerNames, cfg.SecretsDir),
+		ownership:   defaultOwnership(cfg),
+		quotas:      quotas,
+		downloads:   downloads,
+		dirs:        dirs,
+
+		hashAlgorithm: cfg.HashAlgorithm,
+		transports:    transports,
+		proxy:         DefaultProxy(cfg),
+
+		extractSize:    extractSize,
+		extractEntries: cfg.ExtractMaxEntries,
+	}
+}
+
+// DefaultProxy returns the proxy of the configuration applying to requests setting none, nil when
+// SYNC_PROXY is not set
+func DefaultProxy(cfg *config.SyncConfig) *models.ProxyOptions {
+	if cfg.Proxy == "" {
+		return nil
+	}
+	options := &models.ProxyOptions{URL: cfg.Proxy}
+	for _, entry := range strings.Split(cfg.NoProxy, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			options.NoProxy = append(options.NoProxy, entry)
+		}
 	}
+	return options
+}
+
+// defaultOwnership returns the configured default ownership
+func defaultOwnership(cfg *config.SyncConfig) models.Ownership {
+	defaults := models.Ownership{Chmod: cfg.DefaultChmod}
+	if cfg.DefaultUID >= 0 {
+		uid := cfg.DefaultUID
+		defaults.UID = &uid
+	}
+	if cfg.DefaultGID >= 0 {
+		gid := cfg.DefaultGID
+		defaults.GID = &gid
+	}
+	return defaults
+}
+
+// resolveOwnership applies the request ownership on top of the defaults and validates it.
+// nil is returned when no ownership is configured.
+func (f *SyncerFactory) resolveOwnership(requested *models.Ownership) (*models.Ownership, error) {
+	resolved := f.ownership
+	if requested != nil {
+		if requested.UID != nil {
+			resolved.UID = requested.UID
+		}
+		if requested.GID != nil {
+			resolved.GID = requested.GID
+		}
+		if requested.Chmod != "" {
+			resolved.Chmod = requested.Chmod
+		}
+	}
+
+	mapping, err := ownership.New(&resolved)
+	if err != nil || mapping == nil {
+		return nil, err
+	}
+	log.Printf("[SYNCER FACTORY] Applying ownership: %v", mapping.RsyncArgs())
+	return &resolved, nil
+}
+
+// SourceOptions holds the request settings applied on top of the source details
+type SourceOptions struct {
+	Filters     *models.Filters     // restricts the files synced from the source
+	PostProcess *models.PostProcess // processing of the synced files
+	Ownership   *models.Ownership   // owner and permissions of the synced files, on top of the defaults
+
+	FileHandling *models.FileHandling   // handling of symbolic links, hard links and sparse files
+	Atomic       bool                   // stage the sync next to the target and swap it into place
+	Versions     *models.VersionOptions // keep the synced contents as versions with a current link
+	Resume       bool                   // continue a sync interrupted by a restart, keeping its staged contents
+	Bandwidth    *bwlimit.Limiter       // limits the downloads of the sources, nil when unlimited
+	Proxy        *models.ProxyOptions   // proxy of the connections to the sources, on top of the default
+
+	staged bool // the target is the staging directory of an atomic or versioned sync
+	mirror bool // the source provides the complete target, so the staging directory starts empty
 }
 
 // CreateSyncer creates a syncer based on the source type and details
-func (f *SyncerFactory) CreateSyncer(source models.Source, targetPath string) (Syncer, error) {
+func (f *SyncerFactory) CreateSyncer(source models.Source, targetPath string, opts SourceOptions) (Syncer, error) {
 	log.Printf("[SYNCER FACTORY] Creating syncer for type: %s", source.Type)
 	log.Printf("[SYNCER FACTORY] Target path: %s", targetPath)
 	log.Printf("[SYNCER FACTORY] Timeout: %v", f.timeout)
 
-	switch source.Type {
-	case "ssh":
-		log.Printf("[SYNCER FACTORY] Creating SSH syncer")
-		return f.createSSHSyncer(source.Details, targetPath)
-	case "git":
-		log.Printf("[SYNCER FACTORY] Creating Git syncer")
-		return f.createGitSyncer(source.Details, targetPath)
-	case "http":
-		log.Printf("[SYNCER FACTORY] Creating HTTP syncer")
-		return f.createHTTPSyncer(source.Details, targetPath)
-	case "s3":
-		log.Printf("[SYNCER FACTORY] Creating S3 syncer")
-		return f.createS3Syncer(source.Details, targetPath)
-	default:
+	var settings SourceSettings
+	settings.Filters = opts.Filters
+	if _, err := filter.New(settings.Filters); err != nil {
+		log.Printf("[SYNCER FACTORY] ERROR: Invalid filters: %v", err)
+		return nil, fmt.Errorf("invalid filters: %w", err)
+	}
+	if filter.IsEmpty(settings.Filters) {
+		settings.Filters = nil
+	} else {
+		log.Printf("[SYNCER FACTORY] Applying file filters: %+v", *settings.Filters)
+	}
+
+	var err error
+	settings.FileHandling, err = resolveFileHandling(opts.FileHandling)
+	if err != nil {
+		log.Printf("[SYNCER FACTORY] ERROR: Invalid file handling options: %v", err)
+		return nil, fmt.Errorf("invalid fileHandling: %w", err)
+	}
+
+	settings.PostProcess, err = f.resolvePostProcess(opts.PostProcess, settings.FileHandling)
+	if err != nil {
+		log.Printf("[SYNCER FACTORY] ERROR: Invalid post-processing options: %v", err)
+		return nil, err
+	}
+
+	settings.Resume = opts.Resume
+	settings.Bandwidth = opts.Bandwidth
+	settings.Proxy, err = f.resolveProxy(opts.Proxy)
+	if err != nil {
+		log.Printf("[SYNCER FACTORY] ERROR: Invalid proxy options: %v", err)
+		return nil, fmt.Errorf("invalid proxy: %w", err)
+	}
+	settings.Ownership, err = f.resolveOwnership(opts.Ownership)
+	if err != nil {
+		log.Printf("[SYNCER FACTORY] ERROR: Invalid ownership options: %v", err)
+		return nil, fmt.Errorf("invalid ownership: %w", err)
+	}
+
+	limit := f.quotaFor(targetPath, opts)
+	opts.mirror = deletesExtraneous(source)
+	if !opts.Atomic && opts.Versions == nil && limit == nil && !opts.mirror {
+		return f.createSourceSyncer(source, targetPath, settings)
+	}
+	if source.Type == "ssh" {
+		if details, err := parseSSHDetails(source.Details); err == nil && details.Direction == models.SSHDirectionPush {
+			if opts.Atomic || opts.Versions != nil {
+				return nil, errors.New("atomic and versions are not supported for SSH push")
+			}
+			log.Printf("[SYNCER FACTORY] Quota is not applied to SSH push, the target is the source of the transfer")
+			return f.createSourceSyncer(source, targetPath, settings)
+		}
+	}
+	return f.stage(targetPath, opts, limit, func(stagingDir string) (Syncer, error) {
+		return f.createSourceSyncer(source, stagingDir, settings)
+	})
+}
+
+// deletesExtraneous reports whether the source removes target files it does not provide
+func deletesExtraneous(source models.Source) bool {
+	sourceType, ok := lookupSource(source.Type)
+	return ok && sourceType.Mirrors != nil && sourceType.Mirrors(source.Details)
+}
+
+// mirrorsWhenRequested reports whether the details of an HTTP or S3 source enable
+// deleteExtraneous. These syncers write every file on each sync, so they mirror by syncing into
+// an empty staging directory.
+func mirrorsWhenRequested(details interface{}) bool {
+	detailsMap, _ := details.(map[string]interface{})
+	deleteExtraneous, _ := detailsMap["deleteExtraneous"].(bool)
+	return deleteExtraneous
+}
+
+// quotaFor returns the budget applying to the target path; syncers created for a staging
+// directory are checked by the staging syncer instead
+func (f *SyncerFactory) quotaFor(targetPath string, opts SourceOptions) *quota.Limit {
+	if opts.staged {
+		return nil
+	}
+	return f.quotas.For(targetPath)
+}
+
+// stage creates the syncer of an atomic or versioned sync for its staging directory and wraps it.
+// Syncs with a quota are staged atomically, so that contents exceeding the budget are discarded,
+// and so are mirroring syncs, whose staging directory is not seeded with the current contents.
+func (f *SyncerFactory) stage(targetPath string, opts SourceOptions, limit *quota.Limit, create func(stagingDir string) (Syncer, error)) (Syncer, error) {
+	createStaged := func(stagingDir, replaced string) (Syncer, error) {
+		staged, err := create(stagingDir)
+		if err != nil || limit == nil {
+			return staged, err
+		}
+		log.Printf("[SYNCER FACTORY] Enforcing quota of %s: %d bytes", limit.Path, limit.Bytes)
+		return newQuotaSyncer(staged, limit, stagingDir, replaced), nil
+	}
+
+	if opts.Versions != nil {
+		if opts.Atomic {
+			return nil, errors.New("atomic and versions cannot be combined, versions are always published atomically")
+		}
+		if opts.Versions.Keep < 0 {
+			return nil, errors.New("versions.keep must not be negative")
+		}
+		store := versions.New(targetPath)
+		log.Printf("[SYNCER FACTORY] Staging new version in %s", store.StagingPath())
+		staged, err := createStaged(store.StagingPath(), "")
+		if err != nil {
+			return nil, err
+		}
+		return newVersionedSyncer(staged, store, opts.Versions, f.hashAlgorithm, !opts.mirror, opts.Resume), nil
+	}
+
+	if !opts.Atomic {
+		if limit != nil {
+			log.Printf("[SYNCER FACTORY] Quota applies to %s, staging the sync atomically", targetPath)
+		}
+		if opts.mirror {
+			log.Printf("[SYNCER FACTORY] Extraneous files are deleted from %s, staging the sync atomically", targetPath)
+		}
+	}
+	stagingDir := f.stagingPath(targetPath)
+	log.Printf("[SYNCER FACTORY] Staging atomic sync in %s", stagingDir)
+	staged, err := createStaged(stagingDir, targetPath)
+	if err != nil {
+		return nil, err
+	}
+	return newAtomicSyncer(staged, targetPath, stagingDir, !opts.mirror, opts.Resume), nil
+}
+
+// createSourceSyncer creates the syncer of the registered source type writing to the target path
+func (f *SyncerFactory) createSourceSyncer(source models.Source, targetPath string, settings SourceSettings) (Syncer, error) {
+	sourceType, ok := lookupSource(source.Type)
+	if !ok {
 		log.Printf("[SYNCER FACTORY] ERROR: Unsupported source type: %s", source.Type)
 		return nil, fmt.Errorf("unsupported source type: %s", source.Type)
 	}
+	log.Printf("[SYNCER FACTORY] Creating %s syncer", source.Type)
+	settings.Timeout = f.timeout
+	create := func(details interface{}) (Syncer, error) {
+		return f.createDetailsSyncer(sourceType, details, targetPath, settings)
+	}
+	if source.CredentialRef != "" || hasReferences(source.Details) {
+		log.Printf("[SYNCER FACTORY] Loading the credential and secret references of the %s source when the sync starts", source.Type)
+		return &resolvingSyncer{factory: f, source: source, create: create}, nil
+	}
+	return create(source.Details)
+}
+
+// createDetailsSyncer creates the syncer of the source type from the loaded details
+func (f *SyncerFactory) createDetailsSyncer(sourceType SourceType, details interface{}, targetPath string, settings SourceSettings) (Syncer, error) {
+	created, err := sourceType.Create(f, details, targetPath, settings)
+	if err != nil || settings.Ownership == nil || sourceType.AppliesOwnership {
+		return created, err
+	}
+	return newOwnershipSyncer(created, settings.Ownership, targetPath), nil
 }
 
-func (f *SyncerFactory) createSSHSyncer(details interface{}, targetPath string) (Syncer, error) {
+// resolveProxy returns the proxy of the request, the default proxy when it sets none, and
+// validates it
+func (f *SyncerFactory) resolveProxy(requested *models.ProxyOptions) (*models.ProxyOptions, error) {
+	resolved := f.proxy
+	if requested != nil {
+		resolved = requested
+	}
+	p, err := proxy.New(resolved)
+	if err != nil || p == nil {
+		return nil, err
+	}
+	log.Printf("[SYNCER FACTORY] Using proxy: %s", p)
+	return resolved, nil
+}
+
+// contextProxy returns the proxy of the request carried by the context, the default proxy when
+// there is none
+func (f *SyncerFactory) contextProxy(ctx context.Context) *models.ProxyOptions {
+	if options, ok := proxy.FromContext(ctx); ok {
+		return options
+	}
+	return f.proxy
+}
+
+// resolveFileHandling validates the file handling options; nil is returned for the defaults
+func resolveFileHandling(fileHandling *models.FileHandling) (*models.FileHandling, error) {
+	if fileHandling == nil {
+		return nil, nil
+	}
+	switch fileHandling.Symlinks {
+	case "", models.SymlinksPreserve, models.SymlinksFollow, models.SymlinksSkip:
+	default:
+		return nil, fmt.Errorf("symlinks must be %q, %q or %q", models.SymlinksPreserve, models.SymlinksFollow, models.SymlinksSkip)
+	}
+	if *fileHandling == (models.FileHandling{}) || *fileHandling == (models.FileHandling{Symlinks: models.SymlinksPreserve}) {
+		return nil, nil
+	}
+	log.Printf("[SYNCER FACTORY] Applying file handling: %+v", *fileHandling)
+	return fileHandling, nil
+}
+
+func (f *SyncerFactory) createSSHSyncer(details interface{}, targetPath string, settings SourceSettings) (Syncer, error) {
 	log.Printf("[SYNCER FACTORY] Parsing SSH details...")
 	sshDetails, err := parseSSHDetails(details)
 	if err != nil {
@@ -64,22 +384,66 @@ func (f *SyncerFactory) createSSHSyncer(details interface{}, targetPath string)
 	}
 	log.Printf("[SYNCER FACTORY] SSH details parsed successfully - Host: %s, User: %s, Port: %d",
 		sshDetails.Host, sshDetails.User, sshDetails.Port)
-	return ssh.NewSSHSyncer(sshDetails, targetPath, f.timeout), nil
+	sshDetails.Filters = settings.Filters
+	sshDetails.FileHandling = settings.FileHandling
+	sshDetails.BandwidthLimit = settings.Bandwidth.Rate()
+	sshDetails.CredentialsDir = f.dirs.Credentials()
+	sshDetails.KeyFiles = f.keyFiles
+	if sshDetails.ControlMaster == nil {
+		controlMaster := f.controlMaster
+		sshDetails.ControlMaster = &controlMaster
+	}
+	sshDetails.Proxy = settings.Proxy
+	if sshDetails.Direction == models.SSHDirectionPush {
+		if settings.Ownership != nil {
+			log.Printf("[SYNCER FACTORY] Ownership is not applied to SSH push, the target is the source of the transfer")
+		}
+	} else {
+		sshDetails.Ownership = settings.Ownership
+	}
+	sshSyncer := ssh.NewSSHSyncer(sshDetails, targetPath, f.timeout, f.strictHostKeys)
+	if settings.PostProcess.Decrypt == nil && settings.PostProcess.Extract == nil {
+		return sshSyncer, nil
+	}
+	if sshDetails.Direction == models.SSHDirectionPush {
+		return nil, errors.New("postProcess is not supported for SSH push")
+	}
+	// rsync writes the files to the target, so they are processed once the transfer completed
+	processed := Syncer(newPostProcessingSyncer(sshSyncer, settings.PostProcess, targetPath))
+	if settings.Ownership != nil {
+		// Decrypted and extracted files are not created by rsync
+		processed = newOwnershipSyncer(processed, settings.Ownership, targetPath)
+	}
+	return processed, nil
 }
 
-func (f *SyncerFactory) createGitSyncer(details interface{}, targetPath string) (Syncer, error) {
+func (f *SyncerFactory) createGitSyncer(details interface{}, targetPath string, settings SourceSettings) (Syncer, error) {
 	log.Printf("[SYNCER FACTORY] Parsing Git details...")
 	gitDetails, err := parseGitDetails(details)
 	if err != nil {
 		log.Printf("[SYNCER FACTORY] ERROR: Failed to parse Git details: %v", err)
 		return nil, err
 	}
+	gitDetails.Filters = settings.Filters
+	gitDetails.FileHandling = settings.FileHandling
+	gitDetails.Proxy = settings.Proxy
+	if settings.PostProcess.Decrypt != nil || settings.PostProcess.Extract != nil {
+		return nil, errors.New("postProcess is not supported for Git sources")
+	}
+	if settings.FileHandling != nil && settings.FileHandling.Symlinks == models.SymlinksFollow {
+		return nil, errors.New("fileHandling.symlinks follow is not supported for Git sources")
+	}
+	if len(gitDetails.Repos) > 0 {
+		log.Printf("[SYNCER FACTORY] Git details parsed successfully - %d repositories, parallelism: %d",
+			len(gitDetails.Repos), gitDetails.Parallelism)
+		return git.NewMultiGitSyncer(gitDetails, targetPath, f.timeout, f.gitOptions), nil
+	}
 	log.Printf("[SYNCER FACTORY] Git details parsed successfully - URL: %s, Branch: %s, Depth: %d",
 		gitDetails.URL, gitDetails.Branch, gitDetails.Depth)
-	return git.NewGitSyncer(gitDetails, targetPath, f.timeout), nil
+	return git.NewGitSyncer(gitDetails, targetPath, f.timeout, f.gitOptions), nil
 }
 
-func (f *SyncerFactory) createHTTPSyncer(details interface{}, targetPath string) (Syncer, error) {
+func (f *SyncerFactory) createHTTPSyncer(details interface{}, targetPath string, settings SourceSettings) (Syncer, error) {
 	log.Printf("[SYNCER FACTORY] Parsing HTTP details...")
 	httpDetails, err := parseHTTPDetails(details)
 	if err != nil {
@@ -87,10 +451,15 @@ func (f *SyncerFactory) createHTTPSyncer(details interface{}, targetPath string)
 		return nil, err
 	}
 	log.Printf("[SYNCER FACTORY] HTTP details parsed successfully - URL: %s", httpDetails.URL)
-	return http.NewHTTPSyncer(httpDetails, targetPath, f.timeout), nil
+	httpDetails.Filters = settings.Filters
+	httpDetails.Decrypt = settings.PostProcess.Decrypt
+	httpDetails.Extract = settings.PostProcess.Extract
+	httpDetails.FileHandling = settings.FileHandling
+	httpDetails.Proxy = settings.Proxy
+	return http.NewHTTPSyncer(httpDetails, targetPath, f.timeout, f.downloads, settings.Bandwidth, f.transports), nil
 }
 
-func (f *SyncerFactory) createS3Syncer(details interface{}, targetPath string) (Syncer, error) {
+func (f *SyncerFactory) createS3Syncer(details interface{}, targetPath string, settings SourceSettings) (Syncer, error) {
 	log.Printf("[SYNCER FACTORY] Parsing S3 details...")
 	s3Details, err := parseS3Details(details)
 	if err != nil {
@@ -99,7 +468,13 @@ func (f *SyncerFactory) createS3Syncer(details interface{}, targetPath string) (
 	}
 	log.Printf("[SYNCER FACTORY] S3 details parsed successfully - Endpoint: %s, Bucket: %s, Path: %s",
 		s3Details.EndpointURL, s3Details.BucketName, s3Details.Path)
-	return s3.NewS3Syncer(s3Details, targetPath, f.timeout)
+	s3Details.Filters = settings.Filters
+	s3Details.Decrypt = settings.PostProcess.Decrypt
+	s3Details.Extract = settings.PostProcess.Extract
+	s3Details.FileHandling = settings.FileHandling
+	s3Details.Resume = settings.Resume
+	s3Details.Proxy = settings.Proxy
+	return s3.NewS3Syncer(s3Details, targetPath, f.timeout, f.downloads, settings.Bandwidth)
 }
 
 // parseSSHDetails parses SSH details from interface{}
@@ -125,8 +500,15 @@ func parseSSHDetails(details interface{}) (*models.SSHDetails, error) {
 		Port: 22, // default port
 	}
 
-	if port, ok := detailsMap["port"].(float64); ok {
-		sshDetails.Port = int(port)
+	if port, ok := detailsMap["port"]; ok && port != nil {
+		number, ok := port.(float64)
+		if !ok {
+			return nil, validation.Field("port", errors.New("must be a port number"))
+		}
+		if err := validation.Port(number); err != nil {
+			return nil, validation.Field("port", err)
+		}
+		sshDetails.Port = int(number)
 	}
 
 	if password, ok := detailsMap["password"].(string); ok {
@@ -146,6 +528,88 @@ func parseSSHDetails(details interface{}) (*models.SSHDetails, error) {
 		sshDetails.Path = path
 	}
 
+	if direction, ok := detailsMap["direction"].(string); ok && direction != "" {
+		if direction != models.SSHDirectionPull && direction != models.SSHDirectionPush {
+			return nil, fmt.Errorf("SSH direction must be %q or %q", models.SSHDirectionPull, models.SSHDirectionPush)
+		}
+		sshDetails.Direction = direction
+	}
+
+	if agentSocket, ok := detailsMap["agentSocket"].(string); ok {
+		sshDetails.AgentSocket = agentSocket
+	}
+
+	if certificate, ok := detailsMap["certificate"].(string); ok {
+		sshDetails.Certificate = certificate
+	}
+
+	if sshDetails.Certificate != "" && sshDetails.PrivateKey == "" && sshDetails.KeyPath == "" {
+		return nil, errors.New("certificate requires privateKey or key_path")
+	}
+
+	if knownHosts, ok := detailsMap["knownHosts"].(string); ok {
+		sshDetails.KnownHosts = knownHosts
+	}
+
+	if fingerprint, ok := detailsMap["hostKeyFingerprint"].(string); ok {
+		sshDetails.HostKeyFingerprint = fingerprint
+	}
+
+	if sshDetails.KnownHosts != "" && sshDetails.HostKeyFingerprint != "" {
+		return nil, errors.New("knownHosts and hostKeyFingerprint cannot be provided at the same time")
+	}
+
+	var err error
+	if sshDetails.Include, err = parseStringList(detailsMap, "include", "SSH include"); err != nil {
+		return nil, err
+	}
+	if sshDetails.Exclude, err = parseStringList(detailsMap, "exclude", "SSH exclude"); err != nil {
+		return nil, err
+	}
+	if sshDetails.Filter, err = parseStringList(detailsMap, "filter", "SSH filter"); err != nil {
+		return nil, err
+	}
+	for _, rule := range sshDetails.Filter {
+		if !rsyncFilterRuleRegex.MatchString(rule) {
+			return nil, fmt.Errorf("invalid SSH filter rule %q: only include/exclude/protect/hide/show/risk/clear rules are allowed", rule)
+		}
+	}
+
+	if sshDetails.RsyncOptions, err = parseStringList(detailsMap, "rsyncOptions", "SSH rsyncOptions"); err != nil {
+		return nil, err
+	}
+	if err := ssh.ValidateRsyncOptions(sshDetails.RsyncOptions); err != nil {
+		return nil, fmt.Errorf("invalid SSH rsyncOptions: %w", err)
+	}
+
+	if raw, ok := detailsMap["parallelism"]; ok {
+		number, ok := raw.(float64)
+		if !ok || number != float64(int(number)) || number < 0 || number > ssh.MaxParallelism {
+			return nil, fmt.Errorf("SSH parallelism must be an integer between 0 and %d", ssh.MaxParallelism)
+		}
+		sshDetails.Parallelism = int(number)
+	}
+	if sshDetails.Parallelism > 1 {
+		// Every part excludes the rest of the tree, which --delete-excluded would remove
+		if sshDetails.Direction == models.SSHDirectionPush {
+			return nil, errors.New("SSH parallelism is only supported for pulls")
+		}
+		if slices.Contains(sshDetails.RsyncOptions, "--delete-excluded") {
+			return nil, errors.New("SSH parallelism cannot be combined with --delete-excluded")
+		}
+	}
+
+	if err := parseSSHConnection(detailsMap, sshDetails); err != nil {
+		return nil, err
+	}
+	if raw, ok := detailsMap["controlMaster"]; ok {
+		controlMaster, ok := raw.(bool)
+		if !ok {
+			return nil, errors.New("SSH controlMaster must be a boolean")
+		}
+		sshDetails.ControlMaster = &controlMaster
+	}
+
 	// Validate that password and privateKey are not both provided
 	if sshDetails.Password != "" && (sshDetails.PrivateKey != "" || sshDetails.KeyPath != "") {
 		return nil, errors.New("password and privateKey/key_path cannot be provided at the same time")
@@ -154,6 +618,48 @@ func parseSSHDetails(details interface{}) (*models.SSHDetails, error) {
 	return sshDetails, nil
 }
 
+// parseSSHConnection parses the connection tuning of the SSH details
+func parseSSHConnection(detailsMap map[string]interface{}, sshDetails *models.SSHDetails) error {
+	for field, value := range map[string]*string{"connectTimeout": &sshDetails.ConnectTimeout, "serverAliveInterval": &sshDetails.ServerAliveInterval} {
+		raw, ok := detailsMap[field]
+		if !ok {
+			continue
+		}
+		text, ok := raw.(string)
+		if !ok {
+			return fmt.Errorf("SSH %s must be a duration", field)
+		}
+		if err := validation.Duration(text); err != nil {
+			return validation.Field(field, err)
+		}
+		*value = text
+	}
+	if raw, ok := detailsMap["serverAliveCountMax"]; ok {
+		number, ok := raw.(float64)
+		if !ok || number != float64(int(number)) || number < 1 {
+			return errors.New("SSH serverAliveCountMax must be a positive integer")
+		}
+		sshDetails.ServerAliveCountMax = int(number)
+	}
+
+	var err error
+	if sshDetails.Ciphers, err = parseStringList(detailsMap, "ciphers", "SSH ciphers"); err != nil {
+		return err
+	}
+	if sshDetails.KexAlgorithms, err = parseStringList(detailsMap, "kexAlgorithms", "SSH kexAlgorithms"); err != nil {
+		return err
+	}
+	for field, algorithms := range map[string][]string{"ciphers": sshDetails.Ciphers, "kexAlgorithms": sshDetails.KexAlgorithms} {
+		for _, algorithm := range algorithms {
+			// The names end up in the ssh command of rsync
+			if !sshAlgorithmRegex.MatchString(algorithm) {
+				return validation.Field(field, fmt.Errorf("%q is not an SSH algorithm name", algorithm))
+			}
+		}
+	}
+	return nil
+}
+
 // parseGitDetails parses Git details from interface{}
 func parseGitDetails(details interface{}) (*models.GitCloneDetails, error) {
 	detailsMap, ok := details.(map[string]interface{})
@@ -161,10 +667,83 @@ func parseGitDetails(details interface{}) (*models.GitCloneDetails, error) {
 		return nil, errors.New("Git details must be an object")
 	}
 
-	url, ok := detailsMap["url"].(string)
-	if !ok || url == "" {
+	repos, hasRepos := detailsMap["repos"].([]interface{})
+
+	// With a repos list the top-level fields only provide defaults, so the URL is optional
+	gitDetails, err := parseGitFields(detailsMap, !hasRepos)
+	if err != nil {
+		return nil, err
+	}
+
+	if !hasRepos {
+		return gitDetails, nil
+	}
+
+	if gitDetails.URL != "" {
+		return nil, errors.New("Git url and repos cannot be provided at the same time")
+	}
+	if len(repos) == 0 {
+		return nil, errors.New("Git repos must contain at least one repository")
+	}
+
+	seenPaths := make(map[string]bool)
+	for i, repo := range repos {
+		repoMap, ok := repo.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("Git repos[%d] must be an object", i)
+		}
+		if _, nested := repoMap["repos"]; nested {
+			return nil, fmt.Errorf("Git repos[%d] cannot contain nested repos", i)
+		}
+
+		repoDetails, err := parseGitFields(repoMap, true)
+		var fieldErr *models.FieldError
+		if errors.As(err, &fieldErr) {
+			return nil, validation.Field(fmt.Sprintf("repos[%d].%s", i, fieldErr.Field), errors.New(fieldErr.Message))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Git repos[%d]: %w", i, err)
+		}
+
+		repoPath, _ := repoMap["path"].(string)
+		cleanPath, err := cleanRelativePath(repoPath)
+		if err != nil {
+			return nil, fmt.Errorf("Git repos[%d]: invalid path %q: %w", i, repoPath, err)
+		}
+		for existing := range seenPaths {
+			if existing == cleanPath || strings.HasPrefix(cleanPath, existing+"/") || strings.HasPrefix(existing, cleanPath+"/") {
+				return nil, fmt.Errorf("Git repos[%d]: path %q overlaps with another repository path", i, repoPath)
+			}
+		}
+		seenPaths[cleanPath] = true
+
+		gitDetails.Repos = append(gitDetails.Repos, models.GitRepository{
+			GitCloneDetails: *repoDetails,
+			Path:            cleanPath,
+		})
+	}
+
+	if parallelism, ok := detailsMap["parallelism"].(float64); ok {
+		if parallelism < 1 {
+			return nil, errors.New("Git parallelism must be at least 1")
+		}
+		gitDetails.Parallelism = int(parallelism)
+	}
+
+	return gitDetails, nil
+}
+
+// parseGitFields parses the per-repository Git fields from a details object
+func parseGitFields(detailsMap map[string]interface{}, requireURL bool) (*models.GitCloneDetails, error) {
+	url, _ := detailsMap["url"].(string)
+	if requireURL && url == "" {
 		return nil, errors.New("Git URL is required")
 	}
+	if url != "" {
+		if err := checkGitURL(url); err != nil {
+			return nil, validation.Field("url", err)
+		}
+	}
 
 	gitDetails := &models.GitCloneDetails{
 		URL: url,
@@ -186,18 +765,160 @@ func parseGitDetails(details interface{}) (*models.GitCloneDetails, error) {
 		gitDetails.Password = password
 	}
 
+	if token, ok := detailsMap["token"].(string); ok {
+		gitDetails.Token = token
+	}
+	// The credential helper prints the username and password as lines of the credential protocol
+	for _, credential := range [][2]string{{"user", gitDetails.User}, {"password", gitDetails.Password}, {"token", gitDetails.Token}} {
+		if strings.ContainsAny(credential[1], "\r\n\x00") {
+			return nil, validation.Field(credential[0], errors.New("must not contain line breaks or NUL characters"))
+		}
+	}
+
 	if privateKey, ok := detailsMap["privateKey"].(string); ok {
 		gitDetails.PrivateKey = privateKey
 	}
 
+	if agentSocket, ok := detailsMap["agentSocket"].(string); ok {
+		gitDetails.AgentSocket = agentSocket
+	}
+
+	if knownHosts, ok := detailsMap["knownHosts"].(string); ok {
+		gitDetails.KnownHosts = knownHosts
+	}
+
+	if fingerprint, ok := detailsMap["hostKeyFingerprint"].(string); ok {
+		gitDetails.HostKeyFingerprint = fingerprint
+	}
+
+	if submodules, ok := detailsMap["submodules"].(string); ok {
+		switch submodules {
+		case "", models.GitSubmodulesNone, models.GitSubmodulesShallow, models.GitSubmodulesRecursive:
+			gitDetails.Submodules = submodules
+		default:
+			return nil, fmt.Errorf("invalid Git submodules mode: %s (must be none, shallow or recursive)", submodules)
+		}
+	}
+
+	if paths, ok := detailsMap["paths"].([]interface{}); ok {
+		for _, p := range paths {
+			pathStr, ok := p.(string)
+			if !ok || pathStr == "" {
+				return nil, errors.New("Git paths must be a list of non-empty strings")
+			}
+			cleanPath, err := cleanRelativePath(pathStr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid Git sparse checkout path %q: %w", pathStr, err)
+			}
+			gitDetails.Paths = append(gitDetails.Paths, cleanPath)
+		}
+	}
+
+	if export, ok := detailsMap["export"].(bool); ok {
+		gitDetails.Export = export
+	}
+
+	tlsOptions, err := parseTLSOptions(detailsMap, "Git")
+	if err != nil {
+		return nil, err
+	}
+	gitDetails.TLS = tlsOptions
+	if gitDetails.TLS != nil && url != "" && url != secrets.Placeholder && !strings.HasPrefix(strings.ToLower(url), "https://") {
+		return nil, validation.Field("tls", errors.New("requires an https repository URL"))
+	}
+
+	if filter, ok := detailsMap["filter"].(string); ok && filter != "" {
+		if !gitFilterRegex.MatchString(filter) {
+			return nil, fmt.Errorf("invalid Git filter: %s (must be blob:none, tree:0 or blob:limit=<size>)", filter)
+		}
+		gitDetails.Filter = filter
+	}
+
 	// Validate that username/password and privateKey are not both provided
-	if (gitDetails.User != "" || gitDetails.Password != "") && gitDetails.PrivateKey != "" {
-		return nil, errors.New("username/password and privateKey cannot be provided at the same time")
+	if (gitDetails.User != "" || gitDetails.Password != "" || gitDetails.Token != "") && gitDetails.PrivateKey != "" {
+		return nil, errors.New("username/password/token and privateKey cannot be provided at the same time")
+	}
+
+	if gitDetails.Token != "" && gitDetails.Password != "" {
+		return nil, errors.New("password and token cannot be provided at the same time")
+	}
+
+	if gitDetails.KnownHosts != "" && gitDetails.HostKeyFingerprint != "" {
+		return nil, errors.New("knownHosts and hostKeyFingerprint cannot be provided at the same time")
 	}
 
 	return gitDetails, nil
 }
 
+// cleanRelativePath normalizes a path that must stay inside its parent directory
+func cleanRelativePath(p string) (string, error) {
+	if p == "" {
+		return "", errors.New("path is required")
+	}
+	if filepath.IsAbs(p) {
+		return "", errors.New("path must be relative")
+	}
+	cleanPath := filepath.ToSlash(filepath.Clean(strings.Trim(p, "/")))
+	if cleanPath == "." || cleanPath == ".." || strings.HasPrefix(cleanPath, "../") {
+		return "", errors.New("path must point inside the parent directory")
+	}
+	return cleanPath, nil
+}
+
+// scpLikeURLRegex matches the scp-like addresses of Git repositories, e.g. git@github.com:org/repo.git
+var scpLikeURLRegex = regexp.MustCompile(`^([^@/:\s]+@)?[A-Za-z0-9.-]+:[^\s]+$`)
+
+// checkURL checks that the URL is absolute with one of the schemes. Secret references are
+// checked once they are resolved.
+func checkURL(rawURL string, schemes ...string) error {
+	if rawURL == secrets.Placeholder {
+		return nil
+	}
+	return validation.URL(rawURL, schemes...)
+}
+
+// checkGitURL checks that the URL is one Git clones from: an https, http, ssh, git or file URL, an
+// scp-like address or an absolute path
+func checkGitURL(rawURL string) error {
+	switch {
+	case rawURL == secrets.Placeholder:
+		return nil
+	case strings.HasPrefix(rawURL, "file://"):
+		return nil
+	case strings.Contains(rawURL, "://"):
+		return validation.URL(rawURL, "https", "http", "ssh", "git")
+	case strings.HasPrefix(rawURL, "/") && !strings.ContainsAny(rawURL, "\r\n"):
+		return nil
+	case strings.Contains(rawURL, "::"):
+		// <transport>::<address> runs a remote helper, e.g. ext::<command>
+	case scpLikeURLRegex.MatchString(rawURL) && !strings.HasPrefix(rawURL, "-"):
+		return nil
+	}
+	return errors.New("must be an https, http, ssh or git URL, an scp-like address such as git@github.com:org/repo.git or an absolute path")
+}
+
+// parseStringList parses an optional list of non-empty strings without line breaks
+func parseStringList(detailsMap map[string]interface{}, key, label string) ([]string, error) {
+	raw, ok := detailsMap[key]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	items, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s must be a list of non-empty strings", label)
+	}
+
+	var values []string
+	for _, item := range items {
+		value, ok := item.(string)
+		if !ok || value == "" || strings.ContainsAny(value, "\r\n") {
+			return nil, fmt.Errorf("%s must be a list of non-empty strings", label)
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
 // parseHTTPDetails parses HTTP details from interface{}
 func parseHTTPDetails(details interface{}) (*models.HTTPDownloadDetails, error) {
 	detailsMap, ok := details.(map[string]interface{})
@@ -210,7 +931,86 @@ func parseHTTPDetails(details interface{}) (*models.HTTPDownloadDetails, error)
 		return nil, errors.New("HTTP URL is required")
 	}
 
-	return &models.HTTPDownloadDetails{URL: url}, nil
+	if err := checkURL(url, "http", "https"); err != nil {
+		return nil, validation.Field("url", err)
+	}
+
+	httpDetails := &models.HTTPDownloadDetails{URL: url}
+
+	if maxSize, ok := detailsMap["maxSize"].(float64); ok {
+		if maxSize < 0 {
+			return nil, errors.New("HTTP maxSize cannot be negative")
+		}
+		httpDetails.MaxSize = int64(maxSize)
+	}
+
+	if maxRedirects, ok := detailsMap["maxRedirects"].(float64); ok {
+		if maxRedirects < 0 {
+			return nil, errors.New("HTTP maxRedirects cannot be negative")
+		}
+		redirects := int(maxRedirects)
+		httpDetails.MaxRedirects = &redirects
+	}
+
+	if hosts, ok := detailsMap["allowedRedirectHosts"].([]interface{}); ok {
+		for _, host := range hosts {
+			hostStr, ok := host.(string)
+			if !ok || hostStr == "" {
+				return nil, errors.New("HTTP allowedRedirectHosts must be a list of non-empty strings")
+			}
+			httpDetails.AllowedRedirectHosts = append(httpDetails.AllowedRedirectHosts, hostStr)
+		}
+	}
+
+	if deleteExtraneous, ok := detailsMap["deleteExtraneous"].(bool); ok {
+		httpDetails.DeleteExtraneous = deleteExtraneous
+	}
+
+	if decodeContent, ok := detailsMap["decodeContent"].(bool); ok {
+		httpDetails.DecodeContent = &decodeContent
+	}
+
+	tlsOptions, err := parseTLSOptions(detailsMap, "HTTP")
+	if err != nil {
+		return nil, err
+	}
+	httpDetails.TLS = tlsOptions
+
+	return httpDetails, nil
+}
+
+// parseTLSOptions parses the optional tls object of the details of an HTTP or Git source
+func parseTLSOptions(detailsMap map[string]interface{}, label string) (*models.TLSOptions, error) {
+	tlsOptions, ok := detailsMap["tls"].(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+	options := &models.TLSOptions{}
+	for field, value := range map[string]*string{"caCert": &options.CACert, "clientCert": &options.ClientCert, "clientKey": &options.ClientKey, "minVersion": &options.MinVersion} {
+		if _, set := tlsOptions[field]; !set {
+			continue
+		}
+		text, ok := tlsOptions[field].(string)
+		if !ok {
+			return nil, fmt.Errorf("%s tls.%s must be a string", label, field)
+		}
+		*value = text
+	}
+	// Secret references are only resolved when the sync runs, their values are not checked
+	checked := *options
+	if checked.CACert == secrets.Placeholder {
+		checked.CACert = ""
+	}
+	if checked.MinVersion == secrets.Placeholder {
+		checked.MinVersion = ""
+	}
+	if checked.ClientCert != "" && checked.ClientKey != "" && (checked.ClientCert == secrets.Placeholder || checked.ClientKey == secrets.Placeholder) {
+		checked.ClientCert, checked.ClientKey = "", ""
+	}
+	if err := transport.ValidateTLS(&checked); err != nil {
+		return nil, validation.Field("tls", err)
+	}
+	return options, nil
 }
 
 // parseS3Details parses S3 details from interface{}
@@ -224,6 +1024,9 @@ func parseS3Details(details interface{}) (*models.S3Details, error) {
 	if !ok || endpointURL == "" {
 		return nil, errors.New("S3 endpoint URL is required")
 	}
+	if err := checkURL(endpointURL, "http", "https"); err != nil {
+		return nil, validation.Field("endpointUrl", err)
+	}
 
 	bucketName, ok := detailsMap["bucketName"].(string)
 	if !ok || bucketName == "" {
@@ -235,27 +1038,149 @@ func parseS3Details(details interface{}) (*models.S3Details, error) {
 		return nil, errors.New("S3 path is required")
 	}
 
-	accessKey, ok := detailsMap["accessKey"].(string)
-	if !ok || accessKey == "" {
-		return nil, errors.New("S3 access key is required")
+	region, ok := detailsMap["region"].(string)
+	if !ok || region == "" {
+		return nil, errors.New("S3 region is required")
 	}
 
-	secretKey, ok := detailsMap["secretKey"].(string)
-	if !ok || secretKey == "" {
-		return nil, errors.New("S3 secret key is required")
+	accessKey, _ := detailsMap["accessKey"].(string)
+	secretKey, _ := detailsMap["secretKey"].(string)
+	if (accessKey == "") != (secretKey == "") {
+		return nil, errors.New("S3 access key and secret key must be given together")
+	}
+	sessionToken, _ := detailsMap["sessionToken"].(string)
+	if sessionToken != "" && accessKey == "" {
+		return nil, errors.New("S3 session token requires an access key and a secret key")
+	}
+	profile, _ := detailsMap["profile"].(string)
+	if profile != "" && accessKey != "" {
+		return nil, errors.New("S3 profile and static credentials are mutually exclusive")
 	}
 
-	region, ok := detailsMap["region"].(string)
-	if !ok || region == "" {
-		return nil, errors.New("S3 region is required")
+	deleteExtraneous, _ := detailsMap["deleteExtraneous"].(bool)
+
+	var forcePathStyle, disableSSL *bool
+	if value, ok := detailsMap["forcePathStyle"].(bool); ok {
+		forcePathStyle = &value
+	}
+	if value, ok := detailsMap["disableSSL"].(bool); ok {
+		disableSSL = &value
+	}
+
+	objectRetry, err := parseRetryOptions(detailsMap, "objectRetry")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := retry.Resolve(s3.DefaultObjectRetry, objectRetry); err != nil {
+		return nil, validation.Nest("objectRetry", err)
+	}
+
+	parallelism := 0
+	if raw, ok := detailsMap["parallelism"]; ok {
+		number, ok := raw.(float64)
+		if !ok || number != float64(int(number)) || number < 0 || number > s3.MaxParallelism {
+			return nil, fmt.Errorf("S3 parallelism must be an integer between 0 and %d", s3.MaxParallelism)
+		}
+		parallelism = int(number)
+	}
+
+	partSize, _ := detailsMap["partSize"].(string)
+	if partSize != "" {
+		size, err := quota.ParseSize(partSize)
+		if err != nil || size < s3.MinPartSize {
+			return nil, fmt.Errorf("S3 partSize must be a size of at least %d bytes, e.g. \"16Mi\"", s3.MinPartSize)
+		}
+	}
+
+	partConcurrency := 0
+	if raw, ok := detailsMap["partConcurrency"]; ok {
+		number, ok := raw.(float64)
+		if !ok || number != float64(int(number)) || number < 0 || number > s3.MaxPartConcurrency {
+			return nil, fmt.Errorf("S3 partConcurrency must be an integer between 0 and %d", s3.MaxPartConcurrency)
+		}
+		partConcurrency = int(number)
+	}
+
+	apiRetry, err := parseS3APIRetry(detailsMap)
+	if err != nil {
+		return nil, err
 	}
 
 	return &models.S3Details{
-		EndpointURL: endpointURL,
-		BucketName:  bucketName,
-		Path:        path,
-		AccessKey:   accessKey,
-		SecretKey:   secretKey,
-		Region:      region,
+		EndpointURL:      endpointURL,
+		BucketName:       bucketName,
+		Path:             path,
+		AccessKey:        accessKey,
+		SecretKey:        secretKey,
+		SessionToken:     sessionToken,
+		Profile:          profile,
+		Region:           region,
+		ForcePathStyle:   forcePathStyle,
+		DisableSSL:       disableSSL,
+		DeleteExtraneous: deleteExtraneous,
+		ObjectRetry:      objectRetry,
+		Parallelism:      parallelism,
+		PartSize:         partSize,
+		PartConcurrency:  partConcurrency,
+		APIRetry:         apiRetry,
 	}, nil
 }
+
+// parseS3APIRetry parses the optional retry options of the S3 API requests
+func parseS3APIRetry(detailsMap map[string]interface{}) (*models.S3APIRetry, error) {
+	raw, ok := detailsMap["apiRetry"]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	optionsMap, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("S3 apiRetry must be an object")
+	}
+	options := &models.S3APIRetry{}
+	if attempts, ok := optionsMap["maxAttempts"]; ok {
+		number, ok := attempts.(float64)
+		if !ok || number != float64(int(number)) || number < 1 {
+			return nil, errors.New("S3 apiRetry.maxAttempts must be a positive integer")
+		}
+		options.MaxAttempts = int(number)
+	}
+	if maxBackoff, ok := optionsMap["maxBackoff"]; ok {
+		value, ok := maxBackoff.(string)
+		if !ok {
+			return nil, errors.New("S3 apiRetry.maxBackoff must be a duration")
+		}
+		if err := validation.Duration(value); err != nil {
+			return nil, validation.Field("apiRetry.maxBackoff", err)
+		}
+		options.MaxBackoff = value
+	}
+	return options, nil
+}
+
+// parseRetryOptions parses the optional retry options stored under the key of the details
+func parseRetryOptions(detailsMap map[string]interface{}, key string) (*models.RetryOptions, error) {
+	raw, ok := detailsMap[key]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	optionsMap, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s must be an object", key)
+	}
+	options := &models.RetryOptions{}
+	if attempts, ok := optionsMap["attempts"]; ok {
+		number, ok := attempts.(float64)
+		if !ok || number != float64(int(number)) {
+			return nil, fmt.Errorf("%s.attempts must be an integer", key)
+		}
+		options.Attempts = int(number)
+	}
+	for field, value := range map[string]*string{"backoff": &options.Backoff, "maxBackoff": &options.MaxBackoff} {
+		if raw, ok := optionsMap[field]; ok {
+			if *value, ok = raw.(string); !ok {
+				return nil, fmt.Errorf("%s.%s must be a duration string", key, field)
+			}
+		}
+	}
+	return options, nil
+}
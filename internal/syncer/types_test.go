@@ -0,0 +1,266 @@
+package syncer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sharedvolume/volume-syncer/internal/secrets"
+)
+
+func TestRsyncFilterRuleRegex(t *testing.T) {
+	tests := []struct {
+		rule string
+		want bool
+	}{
+		{"- *.tmp", true},
+		{"+ /docs/", true},
+		{"include /src/***", true},
+		{"exclude,s .git/", true},
+		{"-,! *.log", true},
+		{"P /keep", true},
+		{"protect /keep", true},
+		{"hide secret", true},
+		{"show public", true},
+		{"R /cache", true},
+		{"risk /cache", true},
+		{"!", true},
+		{"clear", true},
+
+		{"merge /etc/rsync-rules", false},
+		{". /etc/rsync-rules", false},
+		{"dir-merge .rsync-filter", false},
+		{": .rsync-filter", false},
+		{"merge,- /etc/rsync-rules", false},
+		{"exclude,m /etc/rules", false},
+		{"-", false},
+		{"exclude", false},
+		{"- *.tmp\n. /etc/rsync-rules", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := rsyncFilterRuleRegex.MatchString(tt.rule); got != tt.want {
+			t.Errorf("rsyncFilterRuleRegex.MatchString(%q) = %v, want %v", tt.rule, got, tt.want)
+		}
+	}
+}
+
+func TestGitFilterRegex(t *testing.T) {
+	tests := []struct {
+		filter string
+		want   bool
+	}{
+		{"blob:none", true},
+		{"tree:0", true},
+		{"blob:limit=1024", true},
+		{"blob:limit=10k", true},
+		{"blob:limit=1G", true},
+
+		{"blob:limit=", false},
+		{"blob:limit=1T", false},
+		{"tree:1", false},
+		{"sparse:oid=main:.sparse", false},
+		{"blob:none --upload-pack=touch /tmp/x", false},
+		{"blob:none\ntree:0", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := gitFilterRegex.MatchString(tt.filter); got != tt.want {
+			t.Errorf("gitFilterRegex.MatchString(%q) = %v, want %v", tt.filter, got, tt.want)
+		}
+	}
+}
+
+func TestCheckGitURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://github.com/org/repo.git", true},
+		{"http://git.internal/repo.git", true},
+		{"ssh://git@github.com/org/repo.git", true},
+		{"git://git.internal/repo.git", true},
+		{"file:///srv/git/repo.git", true},
+		{"/srv/git/repo.git", true},
+		{"git@github.com:org/repo.git", true},
+		{"github.com:org/repo.git", true},
+		{secrets.Placeholder, true},
+
+		{"ext::sh -c touch% /tmp/pwned", false},
+		{"ext::sh", false},
+		{"fd::17", false},
+		{"-oProxyCommand:repo", false},
+		{"--upload-pack:repo", false},
+		{"ftp://git.internal/repo.git", false},
+		{"/srv/git/repo.git\n--upload-pack=touch", false},
+		{"git@github.com:org/repo .git", false},
+		{"relative/repo.git", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if err := checkGitURL(tt.url); (err == nil) != tt.want {
+			t.Errorf("checkGitURL(%q) = %v, want valid %v", tt.url, err, tt.want)
+		}
+	}
+}
+
+func TestCleanRelativePath(t *testing.T) {
+	tests := []struct {
+		path    string
+		want    string
+		errText string
+	}{
+		{path: "docs", want: "docs"},
+		{path: "a/b/", want: "a/b"},
+		{path: "a//b/./c", want: "a/b/c"},
+		{path: "a/../b", want: "b"},
+
+		{path: "", errText: "path is required"},
+		{path: "/etc", errText: "path must be relative"},
+		{path: "..", errText: "path must point inside the parent directory"},
+		{path: "../etc", errText: "path must point inside the parent directory"},
+		{path: "a/../../etc", errText: "path must point inside the parent directory"},
+		{path: ".", errText: "path must point inside the parent directory"},
+		{path: "a/..", errText: "path must point inside the parent directory"},
+	}
+
+	for _, tt := range tests {
+		got, err := cleanRelativePath(tt.path)
+		if tt.errText != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.errText) {
+				t.Errorf("cleanRelativePath(%q) error = %v, want one containing %q", tt.path, err, tt.errText)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("cleanRelativePath(%q) = %q, %v, want %q", tt.path, got, err, tt.want)
+		}
+	}
+}
+
+func TestParseSSHDetailsFilters(t *testing.T) {
+	tests := []struct {
+		name    string
+		details map[string]interface{}
+		errText string
+	}{
+		{
+			name:    "accepted rules and options",
+			details: map[string]interface{}{"filter": []interface{}{"- *.tmp", "+ /docs/"}, "rsyncOptions": []interface{}{"--no-delete", "--checksum"}},
+		},
+		{
+			name:    "merge rule",
+			details: map[string]interface{}{"filter": []interface{}{"merge /etc/rsync-rules"}},
+			errText: `invalid SSH filter rule "merge /etc/rsync-rules"`,
+		},
+		{
+			name:    "dir-merge rule",
+			details: map[string]interface{}{"filter": []interface{}{"dir-merge .rsync-filter"}},
+			errText: `invalid SSH filter rule "dir-merge .rsync-filter"`,
+		},
+		{
+			name:    "short merge rule",
+			details: map[string]interface{}{"filter": []interface{}{". /etc/rsync-rules"}},
+			errText: "invalid SSH filter rule",
+		},
+		{
+			name:    "rule with a line break",
+			details: map[string]interface{}{"filter": []interface{}{"- *.tmp\n. /etc/rsync-rules"}},
+			errText: "SSH filter must be a list of non-empty strings",
+		},
+		{
+			name:    "disallowed rsync option",
+			details: map[string]interface{}{"rsyncOptions": []interface{}{"--rsh=sh"}},
+			errText: "invalid SSH rsyncOptions",
+		},
+		{
+			name:    "no-delete with a delete variant",
+			details: map[string]interface{}{"rsyncOptions": []interface{}{"--no-delete", "--delete-after"}},
+			errText: "cannot be combined",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			details := map[string]interface{}{"host": "backup.internal", "user": "sync", "path": "/data", "password": "secret"}
+			for key, value := range tt.details {
+				details[key] = value
+			}
+			_, err := parseSSHDetails(details)
+			if tt.errText == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errText) {
+				t.Fatalf("error = %v, want one containing %q", err, tt.errText)
+			}
+		})
+	}
+}
+
+func TestParseGitDetails(t *testing.T) {
+	tests := []struct {
+		name    string
+		details map[string]interface{}
+		errText string
+	}{
+		{
+			name:    "https with a token",
+			details: map[string]interface{}{"url": "https://github.com/org/repo.git", "user": "ci", "token": "ghp_token"},
+		},
+		{
+			name:    "remote helper URL",
+			details: map[string]interface{}{"url": "ext::sh -c id"},
+			errText: "must be an https, http, ssh or git URL",
+		},
+		{
+			name:    "line break in the password",
+			details: map[string]interface{}{"url": "https://github.com/org/repo.git", "user": "ci", "password": "secret\nurl=https://evil.example"},
+			errText: "must not contain line breaks or NUL characters",
+		},
+		{
+			name:    "line break in the user",
+			details: map[string]interface{}{"url": "https://github.com/org/repo.git", "user": "ci\nprotocol=http", "token": "ghp_token"},
+			errText: "must not contain line breaks or NUL characters",
+		},
+		{
+			name:    "NUL character in the token",
+			details: map[string]interface{}{"url": "https://github.com/org/repo.git", "token": "ghp\x00token"},
+			errText: "must not contain line breaks or NUL characters",
+		},
+		{
+			name:    "partial clone filter",
+			details: map[string]interface{}{"url": "https://github.com/org/repo.git", "filter": "blob:none --upload-pack=id"},
+			errText: "invalid Git filter",
+		},
+		{
+			name:    "repository path outside the target",
+			details: map[string]interface{}{"repos": []interface{}{map[string]interface{}{"url": "https://github.com/org/repo.git", "path": "../outside"}}},
+			errText: `invalid path "../outside"`,
+		},
+		{
+			name:    "sparse checkout path outside the repository",
+			details: map[string]interface{}{"url": "https://github.com/org/repo.git", "paths": []interface{}{"../outside"}},
+			errText: "invalid Git sparse checkout path",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseGitDetails(tt.details)
+			if tt.errText == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errText) {
+				t.Fatalf("error = %v, want one containing %q", err, tt.errText)
+			}
+		})
+	}
+}
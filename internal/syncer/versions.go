@@ -0,0 +1,129 @@
+package syncer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/sharedvolume/volume-syncer/internal/models"
+	"github.com/sharedvolume/volume-syncer/internal/utils"
+	"github.com/sharedvolume/volume-syncer/internal/versions"
+)
+
+// defaultVersionsKept is the number of versions kept when not configured
+const defaultVersionsKept = 5
+
+// versionedSyncer runs the wrapped syncer against the staging directory of the target's
+// version store, then publishes the result as a new version and removes the oldest ones
+type versionedSyncer struct {
+	syncer      Syncer
+	store       *versions.Store
+	keep        int
+	seed        bool   // copy the active version into the staging directory before the sync
+	resume      bool   // continue in the staging directory left by an interrupted sync
+	links       bool   // hard-link the files unchanged since the active version
+	algorithm   string // hash algorithm comparing the contents of the files before linking them, if any
+	linked      int    // files hard-linked by the last sync
+	linkedBytes int64
+}
+
+func newVersionedSyncer(syncer Syncer, store *versions.Store, options *models.VersionOptions, algorithm string, seed, resume bool) *versionedSyncer {
+	keep := options.Keep
+	if keep <= 0 {
+		keep = defaultVersionsKept
+	}
+	return &versionedSyncer{
+		syncer:    syncer,
+		store:     store,
+		keep:      keep,
+		seed:      seed,
+		resume:    resume,
+		links:     options.HardLinks == nil || *options.HardLinks,
+		algorithm: algorithm,
+	}
+}
+
+// Changed reports whether the wrapped syncer modified the target
+func (v *versionedSyncer) Changed() bool {
+	if reporter, ok := v.syncer.(interface{ Changed() bool }); ok {
+		return reporter.Changed()
+	}
+	return true
+}
+
+// Result returns the source details reported by the wrapped syncer and the files hard-linked to
+// the previous version
+func (v *versionedSyncer) Result() models.SyncResult {
+	result := models.SyncResult{}
+	if reporter, ok := v.syncer.(interface{ Result() models.SyncResult }); ok {
+		result = reporter.Result()
+	}
+	result.HardLinkedFiles, result.HardLinkedBytes = v.linked, v.linkedBytes
+	return result
+}
+
+// Results returns the per-source results of the wrapped composite syncer
+func (v *versionedSyncer) Results() []models.SourceResult {
+	if reporter, ok := v.syncer.(interface{ Results() []models.SourceResult }); ok {
+		return reporter.Results()
+	}
+	return nil
+}
+
+// Sync seeds the staging directory with the active version, so that incremental syncers only
+// transfer changes, runs the wrapped syncer and publishes the staging directory. The staged files
+// are copies until the sync completed, so that syncers writing files in place cannot change
+// earlier versions; only then are the unchanged ones hard-linked. A resumed sync continues in the
+// staging directory it left.
+func (v *versionedSyncer) Sync(ctx context.Context) error {
+	staging := v.store.StagingPath()
+	log.Printf("[VERSIONS] Staging new version in %s", staging)
+	_, statErr := os.Stat(staging)
+	resuming := v.resume && statErr == nil
+	if resuming {
+		log.Printf("[VERSIONS] Resuming interrupted sync in %s", staging)
+	} else if err := os.RemoveAll(staging); err != nil {
+		return fmt.Errorf("failed to remove stale staging directory: %w", err)
+	}
+	defer os.RemoveAll(staging)
+
+	current, err := v.store.Current()
+	if err != nil {
+		return err
+	}
+	if current != "" && v.seed && !resuming {
+		if err := utils.CopyDir(v.store.Path(current), staging); err != nil {
+			log.Printf("[VERSIONS] ERROR: Failed to seed staging directory: %v", err)
+			return fmt.Errorf("failed to seed staging directory: %w", err)
+		}
+	}
+
+	if err := v.syncer.Sync(ctx); err != nil {
+		log.Printf("[VERSIONS] Sync failed, version %s stays active", current)
+		return err
+	}
+	if current != "" && !v.Changed() {
+		log.Printf("[VERSIONS] Target unchanged, version %s stays active", current)
+		return nil
+	}
+	if current != "" && v.links {
+		// Files left as copies only cost space, the version is complete either way
+		files, bytes, err := v.store.LinkUnchanged(current, v.algorithm)
+		if err != nil {
+			log.Printf("[VERSIONS] WARNING: Failed to hard-link unchanged files, keeping copies: %v", err)
+		}
+		log.Printf("[VERSIONS] Hard-linked %d unchanged files (%d bytes) to version %s", files, bytes, current)
+		v.linked, v.linkedBytes = files, bytes
+	}
+
+	if _, err := v.store.Publish(); err != nil {
+		log.Printf("[VERSIONS] ERROR: %v", err)
+		return err
+	}
+	// The previously active version is kept until the next sync, so that a failed verification can roll back to it
+	if err := v.store.Prune(v.keep, current); err != nil {
+		log.Printf("[VERSIONS] WARNING: Failed to remove old versions: %v", err)
+	}
+	return nil
+}
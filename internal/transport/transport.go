@@ -0,0 +1,253 @@
+package transport
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sharedvolume/volume-syncer/internal/config"
+	"github.com/sharedvolume/volume-syncer/internal/models"
+	"github.com/sharedvolume/volume-syncer/internal/netdial"
+	"github.com/sharedvolume/volume-syncer/internal/proxy"
+)
+
+// tlsVersions maps the accepted TLS version names to their versions
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// Factory creates the HTTP clients of HTTP sources, HTTP hooks and notifications. The clients
+// share one transport, so that connections to the same servers are pooled across syncs; sources
+// with their own TLS settings or proxy share a transport per distinct setting. A nil factory uses the
+// defaults of net/http.
+type Factory struct {
+	base     *http.Transport
+	mutex    sync.Mutex
+	byTLS    map[string]*http.Transport // transports of sources with their own TLS settings or proxy, by their hash
+	settings []string                   // descriptions of the configured settings, for the log
+}
+
+// New creates the factory of the transport configuration
+func New(cfg config.HTTPConfig) (*Factory, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	var settings []string
+	if cfg.TLSMinVersion != "" {
+		version, ok := tlsVersions[cfg.TLSMinVersion]
+		if !ok {
+			return nil, fmt.Errorf("invalid TLS version %q, expected 1.0, 1.1, 1.2 or 1.3", cfg.TLSMinVersion)
+		}
+		tlsConfig.MinVersion = version
+	}
+	settings = append(settings, "TLS "+versionName(tlsConfig.MinVersion)+"+")
+
+	if cfg.CAFile != "" {
+		bundle, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		if tlsConfig.RootCAs, err = rootCAs(bundle); err != nil {
+			return nil, fmt.Errorf("CA bundle %s: %w", cfg.CAFile, err)
+		}
+		settings = append(settings, "CA bundle "+cfg.CAFile)
+	}
+	if (cfg.ClientCertFile == "") != (cfg.ClientKeyFile == "") {
+		return nil, fmt.Errorf("client certificate and key must be given together")
+	}
+	if cfg.ClientCertFile != "" {
+		certificate, err := tls.LoadX509KeyPair(cfg.ClientCertFile, cfg.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+		settings = append(settings, "client certificate "+cfg.ClientCertFile)
+	}
+
+	for name, value := range map[string]int{"max idle connections per host": cfg.MaxIdleConnsPerHost, "max connections per host": cfg.MaxConnsPerHost} {
+		if value < 0 {
+			return nil, fmt.Errorf("%s cannot be negative", name)
+		}
+	}
+	for name, value := range map[string]time.Duration{"dial": cfg.DialTimeout, "TLS handshake": cfg.TLSHandshakeTimeout, "response header": cfg.ResponseHeaderTimeout, "idle connection": cfg.IdleConnTimeout} {
+		if value < 0 {
+			return nil, fmt.Errorf("%s timeout cannot be negative", name)
+		}
+	}
+
+	dialer := &net.Dialer{Timeout: cfg.DialTimeout, KeepAlive: 30 * time.Second}
+	base := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+			return netdial.DialContext(ctx, dialer, network, address)
+		},
+		ForceAttemptHTTP2:     true,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   cfg.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: cfg.ResponseHeaderTimeout,
+		IdleConnTimeout:       cfg.IdleConnTimeout,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       cfg.MaxConnsPerHost,
+		DisableKeepAlives:     !cfg.KeepAlives,
+		ExpectContinueTimeout: time.Second,
+	}
+	if cfg.ResponseHeaderTimeout > 0 {
+		settings = append(settings, fmt.Sprintf("response header timeout %v", cfg.ResponseHeaderTimeout))
+	}
+	if !cfg.KeepAlives {
+		settings = append(settings, "keep-alives disabled")
+	}
+	return &Factory{base: base, byTLS: map[string]*http.Transport{}, settings: settings}, nil
+}
+
+// String describes the transport settings, e.g. "TLS 1.2+, CA bundle /etc/ssl/internal.pem"
+func (f *Factory) String() string {
+	if f == nil {
+		return "defaults"
+	}
+	return strings.Join(f.settings, ", ")
+}
+
+// Client returns a client on the shared transport whose requests time out after the timeout,
+// 0 for none
+func (f *Factory) Client(timeout time.Duration) *http.Client {
+	if f == nil {
+		return &http.Client{Timeout: timeout}
+	}
+	return &http.Client{Transport: f.base, Timeout: timeout}
+}
+
+// Transport returns the transport of a source with the TLS settings and the proxy, the shared
+// transport when it sets neither
+func (f *Factory) Transport(options *models.TLSOptions, p *proxy.Proxy) (http.RoundTripper, error) {
+	if options == nil && p == nil {
+		if f == nil {
+			return http.DefaultTransport, nil
+		}
+		return f.base, nil
+	}
+
+	var settings []string
+	if options != nil {
+		settings = append(settings, options.CACert, options.ClientCert, options.ClientKey, options.MinVersion)
+	}
+	if p != nil {
+		settings = append(settings, "proxy", p.URL(), p.NoProxy())
+	}
+	key := fmt.Sprintf("%x", sha256.Sum256([]byte(strings.Join(settings, "\x00"))))
+	if f != nil {
+		f.mutex.Lock()
+		defer f.mutex.Unlock()
+		if transport, ok := f.byTLS[key]; ok {
+			return transport, nil
+		}
+	}
+
+	var transport *http.Transport
+	if f != nil {
+		transport = f.base.Clone()
+	} else {
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	}
+	if options != nil {
+		tlsConfig, err := sourceTLS(transport.TLSClientConfig, options)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+	if p != nil {
+		transport.Proxy = p.ForRequest
+	}
+	if f != nil {
+		f.byTLS[key] = transport
+	}
+	return transport, nil
+}
+
+// TLSConfig returns the TLS configuration of a source with the TLS settings, the shared
+// configuration when it sets none
+func (f *Factory) TLSConfig(options *models.TLSOptions) (*tls.Config, error) {
+	shared := &tls.Config{MinVersion: tls.VersionTLS12}
+	if f != nil {
+		shared = f.base.TLSClientConfig
+	}
+	if options == nil {
+		return shared.Clone(), nil
+	}
+	return sourceTLS(shared, options)
+}
+
+// ValidateTLS checks the TLS settings of a source
+func ValidateTLS(options *models.TLSOptions) error {
+	if options == nil {
+		return nil
+	}
+	_, err := sourceTLS(nil, options)
+	return err
+}
+
+// sourceTLS applies the TLS settings of a source on top of the shared configuration
+func sourceTLS(shared *tls.Config, options *models.TLSOptions) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if shared != nil {
+		tlsConfig = shared.Clone()
+	}
+	if options.MinVersion != "" {
+		version, ok := tlsVersions[options.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("tls.minVersion %q is invalid, expected 1.0, 1.1, 1.2 or 1.3", options.MinVersion)
+		}
+		tlsConfig.MinVersion = version
+	}
+	if options.CACert != "" {
+		pool, err := rootCAs([]byte(options.CACert))
+		if err != nil {
+			return nil, fmt.Errorf("tls.caCert %w", err)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if (options.ClientCert == "") != (options.ClientKey == "") {
+		return nil, fmt.Errorf("tls.clientCert and tls.clientKey must be given together")
+	}
+	if options.ClientCert != "" {
+		certificate, err := tls.X509KeyPair([]byte(options.ClientCert), []byte(options.ClientKey))
+		if err != nil {
+			return nil, fmt.Errorf("tls.clientCert is invalid: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+	return tlsConfig, nil
+}
+
+// rootCAs returns the system roots with the PEM certificates of the bundle added
+func rootCAs(bundle []byte) (*x509.CertPool, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(bundle) {
+		return nil, fmt.Errorf("holds no PEM certificates")
+	}
+	return pool, nil
+}
+
+// versionName returns the name of the TLS version, e.g. 1.2
+func versionName(version uint16) string {
+	for name, v := range tlsVersions {
+		if v == version {
+			return name
+		}
+	}
+	return fmt.Sprintf("%#x", version)
+}
@@ -0,0 +1,12 @@
+//go:build !unix
+
+package trigger
+
+import "os"
+
+// notifyResync reports that SIGUSR1 is not available on this platform
+func notifyResync(chan<- os.Signal) bool {
+	return false
+}
+
+func stopResync(chan<- os.Signal) {}
@@ -0,0 +1,19 @@
+//go:build unix
+
+package trigger
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// notifyResync relays SIGUSR1 to the channel
+func notifyResync(signals chan<- os.Signal) bool {
+	signal.Notify(signals, syscall.SIGUSR1)
+	return true
+}
+
+func stopResync(signals chan<- os.Signal) {
+	signal.Stop(signals)
+}
@@ -0,0 +1,90 @@
+package trigger
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"time"
+
+	"github.com/sharedvolume/volume-syncer/internal/models"
+	"github.com/sharedvolume/volume-syncer/internal/service"
+)
+
+// pollInterval is the interval at which the modification time of the trigger file is checked
+const pollInterval = time.Second
+
+// Trigger resyncs on SIGUSR1 and when the trigger file is touched, for sidecars whose neighbours
+// cannot reach the API. It repeats the last submitted sync, or runs the configured definition.
+type Trigger struct {
+	syncService *service.SyncService
+	file        string                 // touched to resync, disabled when empty
+	definition  *models.SyncDefinition // run instead of the last sync, if set
+}
+
+// New creates a trigger watching the file, if any, and running the definition, if set
+func New(syncService *service.SyncService, file string, definition *models.SyncDefinition) *Trigger {
+	return &Trigger{syncService: syncService, file: file, definition: definition}
+}
+
+// Run resyncs on the triggers until the context is done
+func (t *Trigger) Run(ctx context.Context) {
+	signals := make(chan os.Signal, 1)
+	if notifyResync(signals) {
+		defer stopResync(signals)
+		log.Printf("[TRIGGER] Resyncing on SIGUSR1")
+	}
+
+	var poll <-chan time.Time
+	var modified time.Time
+	if t.file != "" {
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+		poll = ticker.C
+		// An existing file does not trigger a resync at startup
+		if info, err := os.Stat(t.file); err == nil {
+			modified = info.ModTime()
+		}
+		log.Printf("[TRIGGER] Resyncing when %s is touched", t.file)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-signals:
+			t.resync("signal SIGUSR1")
+		case <-poll:
+			info, err := os.Stat(t.file)
+			if err != nil || info.ModTime().Equal(modified) {
+				continue
+			}
+			modified = info.ModTime()
+			t.resync("trigger file " + t.file)
+		}
+	}
+}
+
+// resync submits the definition or the last sync again, queued behind a sync in progress
+func (t *Trigger) resync(initiator string) {
+	var job *models.SyncJob
+	var err error
+	if t.definition != nil {
+		log.Printf("[TRIGGER] Running sync definition %s, triggered by %s", t.definition.Name, initiator)
+		req := t.definition.Request()
+		req.Initiator = initiator
+		job, _, err = t.syncService.QueueSync(req)
+	} else {
+		log.Printf("[TRIGGER] Repeating the last sync, triggered by %s", initiator)
+		job, _, err = t.syncService.Resync(initiator)
+	}
+	if errors.Is(err, service.ErrNoLastSync) {
+		log.Printf("[TRIGGER] WARNING: Nothing to resync, %v", err)
+		return
+	}
+	if err != nil {
+		log.Printf("[TRIGGER] ERROR: Failed to resync: %v", err)
+		return
+	}
+	log.Printf("[TRIGGER] Resync submitted as %s job %s", job.Status, job.ID)
+}
@@ -0,0 +1,4 @@
+package utils
+
+// NoCleanup is the cleanup function returned by setups that created nothing to remove
+func NoCleanup() {}
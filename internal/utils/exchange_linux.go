@@ -0,0 +1,19 @@
+//go:build linux
+
+package utils
+
+import (
+	"errors"
+
+	"golang.org/x/sys/unix"
+)
+
+// ExchangePaths atomically swaps two paths on the same filesystem. errors.ErrUnsupported is
+// returned when the filesystem does not support exchanging paths.
+func ExchangePaths(a, b string) error {
+	err := unix.Renameat2(unix.AT_FDCWD, a, unix.AT_FDCWD, b, unix.RENAME_EXCHANGE)
+	if errors.Is(err, unix.EINVAL) || errors.Is(err, unix.ENOSYS) || errors.Is(err, unix.EOPNOTSUPP) {
+		return errors.ErrUnsupported
+	}
+	return err
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package utils
+
+import "errors"
+
+// ExchangePaths atomically swaps two paths on the same filesystem. Exchanging paths is only
+// supported on Linux; errors.ErrUnsupported is returned elsewhere.
+func ExchangePaths(a, b string) error {
+	return errors.ErrUnsupported
+}
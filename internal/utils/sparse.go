@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// sparseBlockSize is the granularity at which runs of zeros are turned into holes
+const sparseBlockSize = 4096
+
+// zeroBlock is compared against the copied blocks
+var zeroBlock = make([]byte, sparseBlockSize)
+
+// CopySparse copies src to dst from its current offset, seeking over blocks of zeros instead of
+// writing them so that the file system stores them as holes
+func CopySparse(dst *os.File, src io.Reader) (int64, error) {
+	start, err := dst.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+
+	buf := make([]byte, 32*sparseBlockSize)
+	var written int64
+	holeAtEnd := false
+	for {
+		n, readErr := io.ReadFull(src, buf)
+		for offset := 0; offset < n; offset += sparseBlockSize {
+			block := buf[offset:min(offset+sparseBlockSize, n)]
+			if bytes.Equal(block, zeroBlock[:len(block)]) {
+				if _, err := dst.Seek(int64(len(block)), io.SeekCurrent); err != nil {
+					return written, err
+				}
+				holeAtEnd = true
+			} else {
+				if _, err := dst.Write(block); err != nil {
+					return written, err
+				}
+				holeAtEnd = false
+			}
+			written += int64(len(block))
+		}
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			break
+		}
+		if readErr != nil {
+			return written, readErr
+		}
+	}
+
+	// A trailing hole only extends the file once its size is set
+	if holeAtEnd {
+		if err := dst.Truncate(start + written); err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
@@ -0,0 +1,130 @@
+package validation
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/sharedvolume/volume-syncer/internal/models"
+)
+
+// validate checks the binding tags of the models and names fields by their JSON names
+var validate = func() *validator.Validate {
+	v := validator.New()
+	v.SetTagName("binding")
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	return v
+}()
+
+// Decode decodes the JSON document into v, rejecting unknown fields, values of the wrong type and
+// trailing data
+func Decode(data []byte, v any) []models.FieldError {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return []models.FieldError{decodeError(err)}
+	}
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return []models.FieldError{{Message: "unexpected data after the JSON document"}}
+	}
+	return nil
+}
+
+// decodeError converts a decoding error into a field error
+func decodeError(err error) models.FieldError {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return models.FieldError{Field: typeErr.Field, Message: fmt.Sprintf("must be %s, not %s", kind(typeErr.Type), typeErr.Value)}
+	}
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return models.FieldError{Message: fmt.Sprintf("invalid JSON at offset %d: %v", syntaxErr.Offset, err)}
+	}
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		if unquoted, err := strconv.Unquote(field); err == nil {
+			field = unquoted
+		}
+		return models.FieldError{Field: field, Message: "unknown field"}
+	}
+	if errors.Is(err, io.EOF) {
+		return models.FieldError{Message: "request body is empty"}
+	}
+	return models.FieldError{Message: err.Error()}
+}
+
+// kind describes the JSON type expected for the Go type
+func kind(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.String:
+		return "a string"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	}
+	return "an object"
+}
+
+// Struct checks the binding tags of the struct, e.g. required fields
+func Struct(v any) []models.FieldError {
+	err := validate.Struct(v)
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		if err != nil {
+			return []models.FieldError{{Message: err.Error()}}
+		}
+		return nil
+	}
+	fieldErrs := make([]models.FieldError, 0, len(validationErrs))
+	for _, fieldErr := range validationErrs {
+		message := fmt.Sprintf("failed the %s check", fieldErr.Tag())
+		if strings.HasPrefix(fieldErr.Tag(), "required") {
+			message = "is required"
+		}
+		fieldErrs = append(fieldErrs, models.FieldError{Field: fieldPath(fieldErr.Namespace()), Message: message})
+	}
+	return fieldErrs
+}
+
+// fieldPath returns the JSON path of a validator namespace, leaving out the struct type and the
+// embedded structs, whose names are capitalized unlike JSON names
+func fieldPath(namespace string) string {
+	var path []string
+	for _, segment := range strings.Split(namespace, ".") {
+		if segment != "" && unicode.IsUpper(rune(segment[0])) {
+			continue
+		}
+		path = append(path, segment)
+	}
+	return strings.Join(path, ".")
+}
+
+// Prefix returns the field errors with their fields nested below the path
+func Prefix(path string, fieldErrs []models.FieldError) []models.FieldError {
+	for i := range fieldErrs {
+		if fieldErrs[i].Field == "" {
+			fieldErrs[i].Field = path
+		} else {
+			fieldErrs[i].Field = path + "." + fieldErrs[i].Field
+		}
+	}
+	return fieldErrs
+}
@@ -0,0 +1,172 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/sharedvolume/volume-syncer/internal/models"
+)
+
+// Field returns the error as the field error of the field, which request validation reports at
+// the path of the field
+func Field(field string, err error) error {
+	return &models.FieldError{Field: field, Message: err.Error()}
+}
+
+// Nest returns the error as a field error of the path, with the field of a field error nested
+// below the path
+func Nest(path string, err error) error {
+	fieldErrs := Errors(path, err)
+	return &fieldErrs[0]
+}
+
+// Errors returns the field errors of the error at the path. Field errors are nested below the
+// path, other errors are reported for the path itself.
+func Errors(path string, err error) []models.FieldError {
+	var fieldErr *models.FieldError
+	if errors.As(err, &fieldErr) {
+		return Prefix(path, []models.FieldError{*fieldErr})
+	}
+	return []models.FieldError{{Field: path, Message: err.Error()}}
+}
+
+// Duration checks that the value is a positive duration, e.g. "30s" or "2h"
+func Duration(value string) error {
+	if duration, err := time.ParseDuration(value); err != nil || duration <= 0 {
+		return fmt.Errorf("must be a positive duration such as 30s or 2h, not %q", value)
+	}
+	return nil
+}
+
+// Port checks that the number is a TCP port
+func Port(port float64) error {
+	if port != math.Trunc(port) || port < 1 || port > math.MaxUint16 {
+		return fmt.Errorf("must be a port number from 1 to %d, not %v", math.MaxUint16, port)
+	}
+	return nil
+}
+
+// URL checks that the value is an absolute URL of one of the schemes with a host. Credentials
+// of the URL are left out of the messages.
+func URL(value string, schemes ...string) error {
+	u, err := url.Parse(value)
+	if err != nil || strings.ContainsAny(value, " \t\r\n") {
+		return fmt.Errorf("must be a valid %s URL", orList(schemes))
+	}
+	if !slices.Contains(schemes, u.Scheme) {
+		return fmt.Errorf("must be an absolute %s URL, not %q", orList(schemes), u.Redacted())
+	}
+	if u.Hostname() == "" {
+		return fmt.Errorf("must name a host, not %q", u.Redacted())
+	}
+	if port := u.Port(); port != "" {
+		number, err := strconv.Atoi(port)
+		if err != nil || Port(float64(number)) != nil {
+			return fmt.Errorf("must have a port number from 1 to %d, not %s", math.MaxUint16, port)
+		}
+	}
+	return nil
+}
+
+// Limits of the labels of a sync request
+const (
+	MaxLabels        = 32
+	maxLabelKeyLen   = 128
+	maxLabelValueLen = 256
+)
+
+// labelKey matches label keys: letters, digits, '.', '_', '-' and '/', starting and ending with
+// a letter or digit, e.g. "volume" or "sharedvolume.io/namespace"
+var labelKey = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9._/-]*[A-Za-z0-9])?$`)
+
+// Label checks the key and value of a job label
+func Label(key, value string) error {
+	if len(key) > maxLabelKeyLen || !labelKey.MatchString(key) {
+		return fmt.Errorf("key must be at most %d letters, digits, '.', '_', '-' or '/', starting and ending with a letter or digit", maxLabelKeyLen)
+	}
+	if len(value) > maxLabelValueLen {
+		return fmt.Errorf("must be at most %d characters", maxLabelValueLen)
+	}
+	if strings.ContainsFunc(value, unicode.IsControl) {
+		return errors.New("cannot contain control characters")
+	}
+	return nil
+}
+
+// Items checks that no list of the value, e.g. a sync request with its source details, holds
+// more than max items. Lists are named by their JSON paths; max 0 disables the check.
+func Items(v any, max int) []models.FieldError {
+	if max <= 0 {
+		return nil
+	}
+	var fieldErrs []models.FieldError
+	items(reflect.ValueOf(v), "", max, &fieldErrs)
+	sort.Slice(fieldErrs, func(i, j int) bool { return fieldErrs[i].Field < fieldErrs[j].Field })
+	return fieldErrs
+}
+
+func items(v reflect.Value, field string, max int, fieldErrs *[]models.FieldError) {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if !v.IsNil() {
+			items(v.Elem(), field, max, fieldErrs)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			structField := v.Type().Field(i)
+			name, _, _ := strings.Cut(structField.Tag.Get("json"), ",")
+			if !structField.IsExported() || name == "-" {
+				continue
+			}
+			switch {
+			case structField.Anonymous && name == "":
+				// Embedded structs share the JSON object of the outer struct
+				items(v.Field(i), field, max, fieldErrs)
+			case name == "":
+				items(v.Field(i), join(field, structField.Name), max, fieldErrs)
+			default:
+				items(v.Field(i), join(field, name), max, fieldErrs)
+			}
+		}
+	case reflect.Map:
+		for iter := v.MapRange(); iter.Next(); {
+			items(iter.Value(), join(field, fmt.Sprint(iter.Key())), max, fieldErrs)
+		}
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return
+		}
+		if v.Len() > max {
+			*fieldErrs = append(*fieldErrs, models.FieldError{Field: field, Message: fmt.Sprintf("has %d items, at most %d are allowed", v.Len(), max)})
+			return
+		}
+		for i := 0; i < v.Len(); i++ {
+			items(v.Index(i), fmt.Sprintf("%s[%d]", field, i), max, fieldErrs)
+		}
+	}
+}
+
+// orList joins the values as in "a, b or c"
+func orList(values []string) string {
+	if len(values) < 2 {
+		return strings.Join(values, "")
+	}
+	return strings.Join(values[:len(values)-1], ", ") + " or " + values[len(values)-1]
+}
+
+func join(field, key string) string {
+	if field == "" {
+		return key
+	}
+	return field + "." + key
+}
@@ -0,0 +1,93 @@
+package verify
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/sharedvolume/volume-syncer/internal/utils"
+)
+
+// partialSuffix marks a snapshot that is still being copied
+const partialSuffix = ".partial"
+
+// Snapshot is a copy of the target taken before a sync, restored when verification fails
+type Snapshot struct {
+	targetDir string
+	dir       string
+	existed   bool
+	keep      bool // a failed restore keeps the snapshot for manual recovery
+}
+
+// TakeSnapshot copies the target next to it. The target itself is never moved, since it is
+// usually a mount point.
+func TakeSnapshot(targetDir, jobID string) (*Snapshot, error) {
+	targetDir = filepath.Clean(targetDir)
+	snapshot := &Snapshot{
+		targetDir: targetDir,
+		dir:       targetDir + ".rollback-" + jobID,
+	}
+
+	if _, err := os.Stat(targetDir); errors.Is(err, os.ErrNotExist) {
+		log.Printf("[VERIFY] Target %s does not exist yet, rollback will clear it", targetDir)
+		return snapshot, nil
+	}
+	snapshot.existed = true
+
+	// The copy is renamed once complete, so that a snapshot interrupted by a restart is never restored
+	log.Printf("[VERIFY] Copying target to rollback snapshot %s", snapshot.dir)
+	partial := snapshot.dir + partialSuffix
+	os.RemoveAll(partial)
+	if err := utils.CopyDir(targetDir, partial); err != nil {
+		os.RemoveAll(partial)
+		return nil, fmt.Errorf("failed to create rollback snapshot: %w", err)
+	}
+	if err := os.Rename(partial, snapshot.dir); err != nil {
+		os.RemoveAll(partial)
+		return nil, fmt.Errorf("failed to create rollback snapshot: %w", err)
+	}
+	return snapshot, nil
+}
+
+// OpenSnapshot returns the complete snapshot the job took of the target, or nil when it has none,
+// e.g. because the job was interrupted while taking it
+func OpenSnapshot(targetDir, jobID string) *Snapshot {
+	targetDir = filepath.Clean(targetDir)
+	dir := targetDir + ".rollback-" + jobID
+	os.RemoveAll(dir + partialSuffix)
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return nil
+	}
+	return &Snapshot{targetDir: targetDir, dir: dir, existed: true}
+}
+
+// Restore replaces the target contents with the snapshot
+func (s *Snapshot) Restore() error {
+	log.Printf("[VERIFY] Rolling back %s to the previous contents", s.targetDir)
+
+	if err := utils.ClearDir(s.targetDir); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to clear target: %w", err)
+	}
+	if !s.existed {
+		return nil
+	}
+	if err := utils.CopyDir(s.dir, s.targetDir); err != nil {
+		s.keep = true
+		return fmt.Errorf("failed to restore snapshot, previous contents kept at %s: %w", s.dir, err)
+	}
+	log.Printf("[VERIFY] Target rolled back successfully")
+	return nil
+}
+
+// Remove deletes the snapshot
+func (s *Snapshot) Remove() {
+	if !s.existed || s.keep {
+		return
+	}
+	log.Printf("[VERIFY] Removing rollback snapshot %s", s.dir)
+	if err := os.RemoveAll(s.dir); err != nil {
+		log.Printf("[VERIFY] WARNING: Failed to remove rollback snapshot %s: %v", s.dir, err)
+	}
+}
@@ -0,0 +1,207 @@
+package verify
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/sharedvolume/volume-syncer/internal/digest"
+	"github.com/sharedvolume/volume-syncer/internal/models"
+)
+
+var (
+	// gnuLineRegex matches a line of sha256sum output: checksum, space, text or binary mode marker and path
+	gnuLineRegex = regexp.MustCompile(`^\\?([0-9a-fA-F]+) [ *](.+)$`)
+	// bsdLineRegex matches a line of BSD-style (--tag) checksum output: "SHA256 (path) = checksum"
+	bsdLineRegex = regexp.MustCompile(`^([A-Za-z0-9-]+) \((.+)\) = ([0-9a-fA-F]+)$`)
+)
+
+// Validate checks the verify options of a request
+func Validate(options *models.VerifyOptions) error {
+	if options == nil {
+		return nil
+	}
+	if len(options.Checksums) == 0 && options.ChecksumFile == "" {
+		return errors.New("verify requires checksums or checksumFile")
+	}
+
+	h, ok := digest.New(algorithm(options))
+	if !ok {
+		return fmt.Errorf("unsupported verify algorithm %q, must be one of %s", options.Algorithm, digest.Names)
+	}
+	if options.ChecksumFile != "" && !filepath.IsLocal(options.ChecksumFile) {
+		return fmt.Errorf("verify checksumFile must be a path inside the target")
+	}
+	size := h.Size()
+	for path, checksum := range options.Checksums {
+		if !filepath.IsLocal(path) {
+			return fmt.Errorf("verify checksums: %q must be a path inside the target", path)
+		}
+		if decoded, err := hex.DecodeString(checksum); err != nil || len(decoded) != size {
+			return fmt.Errorf("verify checksums: %q is not a valid %s checksum", checksum, algorithm(options))
+		}
+	}
+	return nil
+}
+
+// Verify compares the target contents with the manifest. The returned result is never nil;
+// an error is returned when verification fails.
+func Verify(targetDir string, options *models.VerifyOptions) (*models.VerifyResult, error) {
+	log.Printf("[VERIFY] Verifying %s against the manifest (algorithm: %s)", targetDir, algorithm(options))
+	result := &models.VerifyResult{Status: models.JobStatusFailed}
+
+	expected, err := manifest(targetDir, options)
+	if err != nil {
+		result.Error = err.Error()
+		return result, err
+	}
+
+	for _, path := range sortedKeys(expected) {
+		// The algorithm was validated with the request
+		h, _ := digest.New(algorithm(options))
+		checksum, err := digest.File(filepath.Join(targetDir, path), h)
+		if errors.Is(err, os.ErrNotExist) {
+			result.Missing = append(result.Missing, path)
+			continue
+		}
+		if err != nil {
+			err = fmt.Errorf("failed to compute checksum of %s: %w", path, err)
+			result.Error = err.Error()
+			return result, err
+		}
+		result.Checked++
+		if !strings.EqualFold(checksum, expected[path]) {
+			log.Printf("[VERIFY] Checksum mismatch: %s", path)
+			result.Mismatched = append(result.Mismatched, path)
+		}
+	}
+
+	if options.Strict {
+		unexpected, err := unexpectedFiles(targetDir, expected, options.ChecksumFile)
+		if err != nil {
+			result.Error = err.Error()
+			return result, err
+		}
+		result.Unexpected = unexpected
+	}
+
+	if len(result.Mismatched) > 0 || len(result.Missing) > 0 || len(result.Unexpected) > 0 {
+		err := fmt.Errorf("verification failed: %d mismatched, %d missing, %d unexpected files",
+			len(result.Mismatched), len(result.Missing), len(result.Unexpected))
+		log.Printf("[VERIFY] ERROR: %v", err)
+		result.Error = err.Error()
+		return result, err
+	}
+
+	log.Printf("[VERIFY] Verified %d files successfully", result.Checked)
+	result.Status = models.JobStatusSucceeded
+	return result, nil
+}
+
+// manifest returns the expected checksums by clean path relative to the target.
+// Inline checksums take precedence over the checksum file.
+func manifest(targetDir string, options *models.VerifyOptions) (map[string]string, error) {
+	expected := make(map[string]string)
+	if options.ChecksumFile != "" {
+		if err := readChecksumFile(targetDir, options.ChecksumFile, expected); err != nil {
+			return nil, err
+		}
+	}
+	for path, checksum := range options.Checksums {
+		expected[filepath.ToSlash(filepath.Clean(path))] = checksum
+	}
+	if len(expected) == 0 {
+		return nil, errors.New("the manifest does not list any files")
+	}
+	return expected, nil
+}
+
+// readChecksumFile parses a checksum file in GNU or BSD format. Paths are relative to the directory of the file.
+func readChecksumFile(targetDir, checksumFile string, expected map[string]string) error {
+	data, err := os.ReadFile(filepath.Join(targetDir, checksumFile))
+	if err != nil {
+		return fmt.Errorf("failed to read checksum file %s: %w", checksumFile, err)
+	}
+
+	baseDir := filepath.Dir(checksumFile)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(text) == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		var path, checksum string
+		if match := bsdLineRegex.FindStringSubmatch(text); match != nil {
+			path, checksum = match[2], match[3]
+		} else if match := gnuLineRegex.FindStringSubmatch(text); match != nil {
+			checksum, path = match[1], match[2]
+		} else {
+			return fmt.Errorf("checksum file %s: invalid line %d", checksumFile, line)
+		}
+
+		path = filepath.Join(baseDir, path)
+		if !filepath.IsLocal(path) {
+			return fmt.Errorf("checksum file %s: line %d: %q is outside the target", checksumFile, line, path)
+		}
+		expected[filepath.ToSlash(path)] = checksum
+	}
+	return scanner.Err()
+}
+
+// unexpectedFiles lists the files in the target missing from the manifest, ignoring
+// the checksum file and .git directories
+func unexpectedFiles(targetDir string, expected map[string]string, checksumFile string) ([]string, error) {
+	ignored := filepath.ToSlash(filepath.Clean(checksumFile))
+
+	var unexpected []string
+	err := filepath.WalkDir(targetDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		relPath, err := filepath.Rel(targetDir, path)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+		if _, ok := expected[relPath]; !ok && relPath != ignored {
+			unexpected = append(unexpected, relPath)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list target files: %w", err)
+	}
+	return unexpected, nil
+}
+
+func algorithm(options *models.VerifyOptions) string {
+	if options.Algorithm == "" {
+		return models.ChecksumSHA256
+	}
+	return strings.ToLower(options.Algorithm)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,84 @@
+package versions
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/sharedvolume/volume-syncer/internal/digest"
+)
+
+// LinkUnchanged replaces the regular files of the staging directory that are unchanged since the
+// version with hard links to the files of that version, so that versions only take the space of
+// their changes. Files count as unchanged when their size, modification time, mode and owner are
+// the same, and with an algorithm also their digests. It returns the number and size of the
+// linked files.
+func (s *Store) LinkUnchanged(version, algorithm string) (int, int64, error) {
+	previous := s.Path(version)
+	staging := s.StagingPath()
+	files, bytes := 0, int64(0)
+	err := filepath.WalkDir(staging, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(staging, path)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		old := filepath.Join(previous, rel)
+		oldInfo, err := os.Lstat(old)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !oldInfo.Mode().IsRegular() || oldInfo.Size() != info.Size() || !oldInfo.ModTime().Equal(info.ModTime()) ||
+			oldInfo.Mode() != info.Mode() || !sameOwner(oldInfo, info) || os.SameFile(oldInfo, info) {
+			return nil
+		}
+		if algorithm != "" {
+			if same, err := sameContents(old, path, algorithm); err != nil || !same {
+				return err
+			}
+		}
+
+		// The staged file is only replaced once the link exists
+		tmp := path + ".link.tmp"
+		os.Remove(tmp)
+		if err := os.Link(old, tmp); err != nil {
+			return fmt.Errorf("failed to link %s to version %s: %w", rel, version, err)
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			os.Remove(tmp)
+			return fmt.Errorf("failed to link %s to version %s: %w", rel, version, err)
+		}
+		files++
+		bytes += info.Size()
+		return nil
+	})
+	return files, bytes, err
+}
+
+// sameContents reports whether the files have the same digest
+func sameContents(a, b, algorithm string) (bool, error) {
+	var sums [2]string
+	for i, path := range []string{a, b} {
+		h, ok := digest.New(algorithm)
+		if !ok {
+			return false, fmt.Errorf("unsupported hash algorithm %q", algorithm)
+		}
+		sum, err := digest.File(path, h)
+		if err != nil {
+			return false, err
+		}
+		sums[i] = sum
+	}
+	return sums[0] == sums[1], nil
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package versions
+
+import "io/fs"
+
+// sameOwner reports true, owners are not available on this platform
+func sameOwner(a, b fs.FileInfo) bool {
+	return true
+}
@@ -0,0 +1,15 @@
+//go:build unix
+
+package versions
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// sameOwner reports whether the files have the same owner and group
+func sameOwner(a, b fs.FileInfo) bool {
+	statA, okA := a.Sys().(*syscall.Stat_t)
+	statB, okB := b.Sys().(*syscall.Stat_t)
+	return okA && okB && statA.Uid == statB.Uid && statA.Gid == statB.Gid
+}
@@ -0,0 +1,215 @@
+package versions
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"time"
+)
+
+const (
+	// CurrentLink is the symbolic link in the target pointing to the active version
+	CurrentLink = "current"
+
+	// stagingDir is the directory in the target the next version is synced into
+	stagingDir = ".staging"
+
+	// versionLayout names version directories by their UTC publication time, so that they sort chronologically
+	versionLayout = "20060102T150405Z"
+)
+
+// versionNameRegex matches version directory names, with a counter for versions published within the same second
+var versionNameRegex = regexp.MustCompile(`^[0-9]{8}T[0-9]{6}Z(-[0-9]+)?$`)
+
+// Store manages the timestamped versions kept in a target directory
+type Store struct {
+	root string
+}
+
+// New returns the version store of the target directory
+func New(root string) *Store {
+	return &Store{root: filepath.Clean(root)}
+}
+
+// CurrentPath returns the path consumers read the active version from
+func (s *Store) CurrentPath() string {
+	return filepath.Join(s.root, CurrentLink)
+}
+
+// Path returns the directory of the version
+func (s *Store) Path(version string) string {
+	return filepath.Join(s.root, version)
+}
+
+// StagingPath returns the directory the next version is synced into
+func (s *Store) StagingPath() string {
+	return filepath.Join(s.root, stagingDir)
+}
+
+// List returns the version names, oldest first
+func (s *Store) List() ([]string, error) {
+	entries, err := os.ReadDir(s.root)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []string
+	for _, entry := range entries {
+		if entry.IsDir() && versionNameRegex.MatchString(entry.Name()) {
+			versions = append(versions, entry.Name())
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool { return versionLess(versions[i], versions[j]) })
+	return versions, nil
+}
+
+// Current returns the name of the active version, or "" when no version was published yet
+func (s *Store) Current() (string, error) {
+	target, err := os.Readlink(s.CurrentPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s link: %w", CurrentLink, err)
+	}
+	return filepath.Base(target), nil
+}
+
+// Publish turns the staging directory into a new version and makes it the active one
+func (s *Store) Publish() (string, error) {
+	base := time.Now().UTC().Format(versionLayout)
+	name := base
+	for i := 1; ; i++ {
+		if _, err := os.Lstat(s.Path(name)); errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		name = fmt.Sprintf("%s-%d", base, i)
+	}
+
+	if err := os.Rename(s.StagingPath(), s.Path(name)); err != nil {
+		return "", fmt.Errorf("failed to publish version %s: %w", name, err)
+	}
+	if err := s.Switch(name); err != nil {
+		return "", err
+	}
+	log.Printf("[VERSIONS] Published version %s in %s", name, s.root)
+	return name, nil
+}
+
+// Switch points the current link to the version. The link is replaced by a rename, so that
+// consumers always see either the previous or the new version.
+func (s *Store) Switch(version string) error {
+	if !versionNameRegex.MatchString(version) {
+		return fmt.Errorf("invalid version %q", version)
+	}
+	if info, err := os.Stat(s.Path(version)); err != nil || !info.IsDir() {
+		return fmt.Errorf("version %s does not exist", version)
+	}
+
+	tmpLink := filepath.Join(s.root, "."+CurrentLink+".tmp")
+	os.Remove(tmpLink)
+	if err := os.Symlink(version, tmpLink); err != nil {
+		return fmt.Errorf("failed to create %s link: %w", CurrentLink, err)
+	}
+	if err := os.Rename(tmpLink, s.CurrentPath()); err != nil {
+		os.Remove(tmpLink)
+		return fmt.Errorf("failed to switch %s link: %w", CurrentLink, err)
+	}
+	return nil
+}
+
+// Unpublish removes the current link, leaving the target without an active version
+func (s *Store) Unpublish() error {
+	if err := os.Remove(s.CurrentPath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove %s link: %w", CurrentLink, err)
+	}
+	return nil
+}
+
+// Rollback activates the given version, or the version published before the active one when
+// version is empty, and returns the name of the activated version
+func (s *Store) Rollback(version string) (string, error) {
+	current, err := s.Current()
+	if err != nil {
+		return "", err
+	}
+	if version, err = s.RollbackVersion(version); err != nil {
+		return "", err
+	}
+
+	if err := s.Switch(version); err != nil {
+		return "", err
+	}
+	log.Printf("[VERSIONS] Rolled back %s from version %s to %s", s.root, current, version)
+	return version, nil
+}
+
+// RollbackVersion returns the version Rollback activates: the given version, or the version
+// before the active one when none is given
+func (s *Store) RollbackVersion(version string) (string, error) {
+	if version != "" {
+		return version, nil
+	}
+	current, err := s.Current()
+	if err != nil {
+		return "", err
+	}
+	versions, err := s.List()
+	if err != nil {
+		return "", err
+	}
+	for _, candidate := range versions {
+		if current != "" && !versionLess(candidate, current) {
+			break
+		}
+		version = candidate
+	}
+	if version == "" {
+		return "", errors.New("no previous version to roll back to")
+	}
+	return version, nil
+}
+
+// Prune removes the oldest versions beyond keep. The active version and the protected
+// versions are never removed.
+func (s *Store) Prune(keep int, protected ...string) error {
+	versions, err := s.List()
+	if err != nil {
+		return err
+	}
+	current, err := s.Current()
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < len(versions)-keep; i++ {
+		if versions[i] == current || slices.Contains(protected, versions[i]) {
+			continue
+		}
+		log.Printf("[VERSIONS] Removing old version %s", versions[i])
+		if err := os.RemoveAll(s.Path(versions[i])); err != nil {
+			return fmt.Errorf("failed to remove version %s: %w", versions[i], err)
+		}
+	}
+	return nil
+}
+
+// versionLess orders version names by publication time and counter
+func versionLess(a, b string) bool {
+	if len(a) != len(b) {
+		// Names of the same second only differ in the length of the counter suffix
+		if a[:len(versionLayout)] != b[:len(versionLayout)] {
+			return a < b
+		}
+		return len(a) < len(b)
+	}
+	return a < b
+}
@@ -0,0 +1,29 @@
+//go:build linux
+
+package workdirs
+
+import "syscall"
+
+// tmpfsMagic is the filesystem type of tmpfs reported by statfs
+const tmpfsMagic = 0x01021994
+
+// sameFilesystem reports whether both paths are on the same filesystem
+func sameFilesystem(a, b string) (bool, error) {
+	var statA, statB syscall.Stat_t
+	if err := syscall.Stat(a, &statA); err != nil {
+		return false, err
+	}
+	if err := syscall.Stat(b, &statB); err != nil {
+		return false, err
+	}
+	return statA.Dev == statB.Dev, nil
+}
+
+// memoryBacked reports whether the directory is on a tmpfs, whose files never reach a disk
+func memoryBacked(dir string) (bool, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return false, err
+	}
+	return stat.Type == tmpfsMagic, nil
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package workdirs
+
+import "errors"
+
+// sameFilesystem cannot compare filesystems on this platform, so syncs stage next to the target
+func sameFilesystem(a, b string) (bool, error) {
+	return false, errors.ErrUnsupported
+}
+
+// memoryBacked cannot tell the filesystem type on this platform
+func memoryBacked(dir string) (bool, error) {
+	return false, errors.ErrUnsupported
+}
@@ -0,0 +1,114 @@
+package workdirs
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// Dirs holds the directories the syncers keep temporary files in. Without a staging directory,
+// staging directories and temporary clones are created next to the targets; without a
+// credentials directory, key material is written to the system temp directory.
+type Dirs struct {
+	staging     string
+	credentials string
+}
+
+// New validates the directories, creating them when missing. Empty directories keep the defaults.
+func New(staging, credentials string) (*Dirs, error) {
+	d := &Dirs{}
+	if staging != "" {
+		if err := prepare(staging); err != nil {
+			return nil, fmt.Errorf("invalid staging directory: %w", err)
+		}
+		d.staging = filepath.Clean(staging)
+		log.Printf("[WORKDIRS] Staging syncs in %s", d.staging)
+	}
+	if credentials != "" {
+		if err := prepare(credentials); err != nil {
+			return nil, fmt.Errorf("invalid credentials directory: %w", err)
+		}
+		// Key files are created readable by the server only, the directory should not be listable
+		if err := os.Chmod(credentials, 0700); err != nil {
+			return nil, fmt.Errorf("invalid credentials directory: %w", err)
+		}
+		if memory, err := memoryBacked(credentials); err == nil && !memory {
+			log.Printf("[WORKDIRS] WARNING: Credentials directory %s is not a tmpfs, key material is written to disk", credentials)
+		}
+		d.credentials = filepath.Clean(credentials)
+		log.Printf("[WORKDIRS] Writing key material to %s", d.credentials)
+	}
+	return d, nil
+}
+
+// prepare creates the directory and checks that files can be created in it
+func prepare(dir string) error {
+	if !filepath.IsAbs(dir) {
+		return fmt.Errorf("%s is not an absolute path", dir)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	probe, err := os.CreateTemp(dir, ".probe-*")
+	if err != nil {
+		return fmt.Errorf("%s is not writable: %w", dir, err)
+	}
+	probe.Close()
+	return os.Remove(probe.Name())
+}
+
+// Staging returns the configured staging directory, empty when unset
+func (d *Dirs) Staging() string {
+	if d == nil {
+		return ""
+	}
+	return d.staging
+}
+
+// StagingFor returns the staging directory for syncs of the target, empty when unset or on
+// another filesystem than the target, as staged contents are renamed into place
+func (d *Dirs) StagingFor(targetPath string) string {
+	if d.Staging() == "" {
+		return ""
+	}
+	same, err := sameFilesystem(d.staging, existingAncestor(targetPath))
+	if err != nil || !same {
+		log.Printf("[WORKDIRS] WARNING: Staging directory %s is not on the filesystem of %s, staging next to the target", d.staging, targetPath)
+		return ""
+	}
+	return d.staging
+}
+
+// TempParent returns the directory temporary copies of the target are created in
+func (d *Dirs) TempParent(targetPath string) string {
+	if dir := d.StagingFor(targetPath); dir != "" {
+		return dir
+	}
+	return filepath.Dir(filepath.Clean(targetPath))
+}
+
+// Credentials returns the directory key material is written to, empty for the system temp directory
+func (d *Dirs) Credentials() string {
+	if d == nil {
+		return ""
+	}
+	return d.credentials
+}
+
+// existingAncestor returns the path or its closest ancestor that exists, e.g. the volume a
+// target is created in by its first sync
+func existingAncestor(path string) string {
+	path = filepath.Clean(path)
+	for {
+		if _, err := os.Lstat(path); err == nil || !errors.Is(err, os.ErrNotExist) {
+			return path
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path
+		}
+		path = parent
+	}
+}
@@ -27,6 +27,7 @@ const (
 	ErrTypeAuth       = "authentication"
 	ErrTypeFileSystem = "filesystem"
 	ErrTypeTimeout    = "timeout"
+	ErrTypeConflict   = "conflict"
 	ErrTypeUnknown    = "unknown"
 )
 
@@ -73,3 +74,26 @@ func NewTimeoutError(message string, err error) *SyncError {
 		Err:     err,
 	}
 }
+
+// NewConflictError creates a new conflict error
+func NewConflictError(message string) *SyncError {
+	return &SyncError{
+		Type:    ErrTypeConflict,
+		Message: message,
+	}
+}
+
+// Error codes reported by the API, stable across releases so that clients can branch on them
+const (
+	CodeValidation  = "VALIDATION"
+	CodeAuthFailed  = "AUTH_FAILED"
+	CodeNotFound    = "NOT_FOUND"
+	CodeTimeout     = "TIMEOUT"
+	CodeNetwork     = "NETWORK"
+	CodeDiskFull    = "DISK_FULL"
+	CodeBusy        = "BUSY"
+	CodeInterrupted = "INTERRUPTED"
+	CodeCanceled    = "CANCELED"
+	CodeConflict    = "CONFLICT"
+	CodeUnknown     = "UNKNOWN"
+)
@@ -0,0 +1,80 @@
+package sync
+
+import "time"
+
+// DefaultTimeout limits the operations of a sync when Options.Timeout is not set
+const DefaultTimeout = 5 * time.Minute
+
+// Options holds the settings shared by all source types
+type Options struct {
+	Target  string        // Directory the source is synced into, or pushed from for SSH push
+	Timeout time.Duration // Limit of each network operation (default: DefaultTimeout)
+	Filters *Filters      // Restricts the files synced from the source
+	Atomic  bool          // Stage the sync next to the target and swap it into place
+
+	GitImplementation string // Git implementation: exec (git binary, default) or go-git
+	StrictHostKeys    bool   // Reject SSH connections without host key material instead of skipping verification
+}
+
+// Filters selects the files synced from a source, independent of the source type
+type Filters struct {
+	Include     []string `json:"include,omitempty"`     // Glob patterns; when set, only matching files are synced
+	Exclude     []string `json:"exclude,omitempty"`     // Glob patterns of files that are never synced
+	Regex       string   `json:"regex,omitempty"`       // Regular expression the relative file path must match
+	MaxFileSize int64    `json:"maxFileSize,omitempty"` // Files larger than this many bytes are skipped
+}
+
+// GitOptions describes a Git repository to clone or update
+type GitOptions struct {
+	URL                string   `json:"url"`
+	Branch             string   `json:"branch,omitempty"`             // Remote default branch when empty
+	Depth              int      `json:"depth,omitempty"`              // Shallow clone depth, 0 for full history
+	User               string   `json:"user,omitempty"`               // For HTTP(S) authentication
+	Password           string   `json:"password,omitempty"`           // For HTTP(S) authentication
+	Token              string   `json:"token,omitempty"`              // Personal access token for HTTP(S) authentication
+	PrivateKey         string   `json:"privateKey,omitempty"`         // Base64 encoded private key for SSH
+	KnownHosts         string   `json:"knownHosts,omitempty"`         // known_hosts entries for SSH host key verification
+	HostKeyFingerprint string   `json:"hostKeyFingerprint,omitempty"` // Expected SSH host key fingerprint
+	Submodules         string   `json:"submodules,omitempty"`         // Submodule handling: none (default), shallow or recursive
+	Paths              []string `json:"paths,omitempty"`              // Directories to materialize via sparse checkout
+	Filter             string   `json:"filter,omitempty"`             // Partial clone filter: blob:none, tree:0 or blob:limit=<size>
+	Export             bool     `json:"export,omitempty"`             // Materialize the worktree only, without the .git directory
+}
+
+// SSHOptions describes a remote directory transferred with rsync over SSH
+type SSHOptions struct {
+	Host               string   `json:"host"`
+	Port               int      `json:"port,omitempty"` // default: 22
+	User               string   `json:"user"`
+	Password           string   `json:"password,omitempty"`
+	KeyPath            string   `json:"key_path,omitempty"`
+	PrivateKey         string   `json:"privateKey,omitempty"` // Base64 encoded private key
+	Path               string   `json:"path"`                 // Remote path to sync
+	Direction          string   `json:"direction,omitempty"`  // pull (remote to target, default) or push (target to remote)
+	KnownHosts         string   `json:"knownHosts,omitempty"`
+	HostKeyFingerprint string   `json:"hostKeyFingerprint,omitempty"`
+	RsyncOptions       []string `json:"rsyncOptions,omitempty"` // Additional rsync flags, validated against an allowlist
+	Parallelism        int      `json:"parallelism,omitempty"`  // rsync processes transferring the top-level directories of pulls
+}
+
+// HTTPOptions describes a file downloaded over HTTP(S)
+type HTTPOptions struct {
+	URL              string `json:"url"`
+	MaxSize          int64  `json:"maxSize,omitempty"`          // Maximum number of bytes to download, 0 for unlimited
+	DeleteExtraneous bool   `json:"deleteExtraneous,omitempty"` // Remove target files the download does not provide
+}
+
+// S3Options describes the objects below a prefix of an S3 bucket
+type S3Options struct {
+	Endpoint         string `json:"endpointUrl"`
+	Bucket           string `json:"bucketName"`
+	Path             string `json:"path"`                // Object key prefix
+	AccessKey        string `json:"accessKey,omitempty"` // Without keys the default AWS credential chain applies
+	SecretKey        string `json:"secretKey,omitempty"`
+	SessionToken     string `json:"sessionToken,omitempty"` // Temporary credentials
+	Profile          string `json:"profile,omitempty"`      // Profile of the shared AWS configuration
+	Region           string `json:"region"`
+	ForcePathStyle   bool   `json:"forcePathStyle,omitempty"`   // Useful for MinIO and some S3-compatible services
+	DisableSSL       bool   `json:"disableSSL,omitempty"`       // Useful for local development
+	DeleteExtraneous bool   `json:"deleteExtraneous,omitempty"` // Remove target files without a matching object
+}
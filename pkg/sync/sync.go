@@ -0,0 +1,170 @@
+// Package sync embeds the volume syncer source implementations in other programs. It syncs Git
+// repositories, SSH remotes, HTTP downloads and S3 buckets into local directories, like the sync
+// requests of the volume syncer server, without talking to a server. Import it under an alias,
+// since its name clashes with the standard library:
+//
+//	import vsync "github.com/sharedvolume/volume-syncer/pkg/sync"
+//
+//	syncer, err := vsync.NewGit(vsync.GitOptions{URL: "https://github.com/example/repo.git"}, vsync.Options{Target: "/data/repo"})
+//	if err != nil {
+//		return err
+//	}
+//	result, err := syncer.Sync(ctx)
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/sharedvolume/volume-syncer/internal/config"
+	"github.com/sharedvolume/volume-syncer/internal/inventory"
+	"github.com/sharedvolume/volume-syncer/internal/models"
+	"github.com/sharedvolume/volume-syncer/internal/syncer"
+)
+
+// Syncer synchronizes a source into its target directory. A Syncer can be run repeatedly;
+// incremental sources only transfer what changed since the last run. Cancelling the context
+// aborts the running transfer.
+type Syncer interface {
+	Sync(ctx context.Context) (*Result, error)
+}
+
+// Result reports what a successful sync did to the target
+type Result struct {
+	Changed          bool          // False when the source was unchanged and the target left as is
+	FilesAdded       int           // Files added to the target
+	FilesChanged     int           // Files whose size, modification time or type changed
+	FilesDeleted     int           // Files removed from the target
+	BytesTransferred int64         // Size of the added and changed files
+	TotalFiles       int           // Files in the target after the sync
+	TotalBytes       int64         // Size of the files in the target after the sync
+	Duration         time.Duration // Duration of the sync
+	Revision         string        // Commit checked out by Git syncs
+	Objects          int           // Objects listed by S3 syncs, after filters
+}
+
+// NewGit creates a syncer cloning or updating a Git repository
+func NewGit(source GitOptions, opts Options) (Syncer, error) {
+	return newSyncer("git", source, opts)
+}
+
+// NewSSH creates a syncer transferring a remote directory with rsync over SSH
+func NewSSH(source SSHOptions, opts Options) (Syncer, error) {
+	return newSyncer("ssh", source, opts)
+}
+
+// NewHTTP creates a syncer downloading a file over HTTP(S)
+func NewHTTP(source HTTPOptions, opts Options) (Syncer, error) {
+	return newSyncer("http", source, opts)
+}
+
+// NewS3 creates a syncer downloading the objects of an S3 bucket
+func NewS3(source S3Options, opts Options) (Syncer, error) {
+	return newSyncer("s3", source, opts)
+}
+
+// sourceSyncer adapts the syncers of the server to the public interface
+type sourceSyncer struct {
+	syncer syncer.Syncer
+	target string
+}
+
+// newSyncer validates the source like the details of a sync request and creates its syncer
+func newSyncer(sourceType string, source any, opts Options) (Syncer, error) {
+	if opts.Target == "" {
+		return nil, errors.New("target directory is required")
+	}
+	details, err := detailsMap(source)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &config.SyncConfig{
+		DefaultTimeout:    opts.Timeout,
+		GitImplementation: opts.GitImplementation,
+		StrictHostKeys:    opts.StrictHostKeys,
+		DefaultUID:        -1,
+		DefaultGID:        -1,
+	}
+	if cfg.DefaultTimeout <= 0 {
+		cfg.DefaultTimeout = DefaultTimeout
+	}
+	if cfg.GitImplementation == "" {
+		cfg.GitImplementation = "exec"
+	}
+
+	var filters *models.Filters
+	if opts.Filters != nil {
+		filters = &models.Filters{
+			Include:     opts.Filters.Include,
+			Exclude:     opts.Filters.Exclude,
+			Regex:       opts.Filters.Regex,
+			MaxFileSize: opts.Filters.MaxFileSize,
+		}
+	}
+
+	factory := syncer.NewSyncerFactory(cfg, nil, nil, nil, nil, nil)
+	created, err := factory.CreateSyncer(models.Source{Type: sourceType, Details: details}, opts.Target, syncer.SourceOptions{
+		Filters: filters,
+		Atomic:  opts.Atomic,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &sourceSyncer{syncer: created, target: opts.Target}, nil
+}
+
+// detailsMap converts the typed options to the JSON details of sync requests, which are validated
+// by the syncer factory
+func detailsMap(source any) (map[string]interface{}, error) {
+	data, err := json.Marshal(source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid source options: %w", err)
+	}
+	var details map[string]interface{}
+	if err := json.Unmarshal(data, &details); err != nil {
+		return nil, fmt.Errorf("invalid source options: %w", err)
+	}
+	return details, nil
+}
+
+// Sync runs the sync and compares the target files with those listed before
+func (s *sourceSyncer) Sync(ctx context.Context) (*Result, error) {
+	before, err := inventory.Scan(s.target)
+	if err != nil {
+		log.Printf("[SYNC] WARNING: Failed to list target files, the result will count every file as added: %v", err)
+		before = nil
+	}
+
+	started := time.Now()
+	if err := s.syncer.Sync(ctx); err != nil {
+		return nil, err
+	}
+	result := &Result{Changed: true, Duration: time.Since(started)}
+	if reporter, ok := s.syncer.(interface{ Changed() bool }); ok {
+		result.Changed = reporter.Changed()
+	}
+	if reporter, ok := s.syncer.(interface{ Result() models.SyncResult }); ok {
+		details := reporter.Result()
+		result.Revision = details.Revision
+		result.Objects = details.Objects
+	}
+
+	after, err := inventory.Scan(s.target)
+	if err != nil {
+		log.Printf("[SYNC] WARNING: Failed to list target files after the sync: %v", err)
+		return result, nil
+	}
+	changes := inventory.Compare(before, after)
+	result.FilesAdded = changes.Added
+	result.FilesChanged = changes.Changed
+	result.FilesDeleted = changes.Deleted
+	result.BytesTransferred = changes.Bytes
+	result.TotalFiles = changes.Files
+	result.TotalBytes = changes.Total
+	return result, nil
+}